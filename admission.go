@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// admissionDirName is the output subdirectory for admission policy stubs
+const admissionDirName = "admission"
+
+// Admission controllers policy stubs are generated for, selected with --admission-policies
+const (
+	admissionKyverno    = "kyverno"
+	admissionGatekeeper = "gatekeeper"
+)
+
+// admissionControllers lists the valid --admission-policies values
+var admissionControllers = []string{admissionKyverno, admissionGatekeeper}
+
+// isValidAdmissionController checks the --admission-policies value; empty disables the stubs
+func isValidAdmissionController(controller string) bool {
+	if controller == "" {
+		return true
+	}
+	for _, c := range admissionControllers {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// admissionInvariants are properties every converted service has, which the
+// policy stubs keep true for workloads deployed later
+type admissionInvariants struct {
+	// Namespaces are the namespaces of the generated objects
+	Namespaces []string
+	// ImagePrefixes are the registry hosts ("host/") of the images, or the
+	// repository of Docker Hub images
+	ImagePrefixes []string
+	// Labels are the keys of the labels added to every Deployment
+	Labels []string
+	// Unprivileged is set when no source container runs privileged
+	Unprivileged bool
+}
+
+// observeAdmissionInvariants collects the invariants of the converted services
+func observeAdmissionInvariants(taskDefInfos []*TaskDefInfo) admissionInvariants {
+	namespaces := map[string]bool{}
+	prefixes := map[string]bool{}
+	unprivileged := true
+
+	for _, taskDefInfo := range taskDefInfos {
+		if files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests); err == nil {
+			for _, doc := range files {
+				manifest, _ := doc.(map[string]interface{})
+				metadata, _ := manifest["metadata"].(map[string]interface{})
+				if ns, _ := metadata["namespace"].(string); ns != "" {
+					namespaces[ns] = true
+				}
+			}
+		}
+		if podSpec := taskDefInfo.Manifests.Deployment; podSpec != nil {
+			for _, c := range podSpec.InitContainers {
+				prefixes[imagePrefix(c.Image)] = true
+			}
+			for _, c := range podSpec.Containers {
+				prefixes[imagePrefix(c.Image)] = true
+			}
+		}
+		if taskDefInfo.Source != nil {
+			for _, def := range taskDefInfo.Source.ContainerDefinitions {
+				if def.Privileged != nil && *def.Privileged {
+					unprivileged = false
+				}
+			}
+		}
+	}
+
+	var labels []string
+	if metadata := conversionMetadata(taskDefInfos); metadata != nil {
+		for key := range metadataForKind(metadata.Labels, "Deployment") {
+			labels = append(labels, key)
+		}
+		sort.Strings(labels)
+	}
+
+	return admissionInvariants{
+		Namespaces:    sortedSet(namespaces),
+		ImagePrefixes: sortedSet(prefixes),
+		Labels:        labels,
+		Unprivileged:  unprivileged,
+	}
+}
+
+// imagePrefix returns the registry host of an image followed by "/", or the
+// repository of a Docker Hub image
+func imagePrefix(image string) string {
+	host, _, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return host + "/"
+	}
+	repository, _, _ := splitImageReference(image)
+	return repository
+}
+
+// admissionManifests builds the policy stubs of a controller encoding the
+// invariants of the converted services, keyed by file name. Kyverno policies
+// audit and Gatekeeper constraints run as dryrun until a team enforces them.
+func admissionManifests(controller, clusterName string, taskDefInfos []*TaskDefInfo) map[string]interface{} {
+	inv := observeAdmissionInvariants(taskDefInfos)
+	if controller == admissionGatekeeper {
+		return gatekeeperConstraints(clusterName, inv)
+	}
+	return kyvernoPolicies(clusterName, inv)
+}
+
+// kyvernoPolicies builds Kyverno ClusterPolicies for the invariants
+func kyvernoPolicies(clusterName string, inv admissionInvariants) map[string]interface{} {
+	docs := map[string]interface{}{}
+
+	policy := func(name, description string, kinds []string, message string, pattern map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": apiVersionKyverno,
+			"kind":       "ClusterPolicy",
+			"metadata": map[string]interface{}{
+				"name": sanitizeName(clusterName + "-" + name),
+				"annotations": map[string]string{
+					"policies.kyverno.io/description": description,
+				},
+			},
+			"spec": map[string]interface{}{
+				"background": true,
+				"rules": []map[string]interface{}{{
+					"name": name,
+					"match": map[string]interface{}{
+						"any": []map[string]interface{}{{
+							"resources": map[string]interface{}{
+								"kinds":      kinds,
+								"namespaces": inv.Namespaces,
+							},
+						}},
+					},
+					"validate": map[string]interface{}{
+						"failureAction": "Audit",
+						"message":       message,
+						"pattern":       pattern,
+					},
+				}},
+			},
+		}
+	}
+
+	if len(inv.ImagePrefixes) > 0 {
+		var patterns []string
+		for _, prefix := range inv.ImagePrefixes {
+			patterns = append(patterns, prefix+"*")
+		}
+		images := []map[string]string{{"image": strings.Join(patterns, " | ")}}
+		docs["kyverno-allowed-registries.yaml"] = policy("allowed-registries",
+			"Images come from the registries the ECS services pulled from",
+			[]string{"Pod"},
+			"Images must come from "+strings.Join(inv.ImagePrefixes, ", "),
+			map[string]interface{}{
+				"spec": map[string]interface{}{
+					"=(initContainers)": images,
+					"containers":        images,
+				},
+			})
+	}
+
+	if len(inv.Labels) > 0 {
+		labels := map[string]string{}
+		for _, key := range inv.Labels {
+			labels[key] = "?*"
+		}
+		docs["kyverno-required-labels.yaml"] = policy("required-labels",
+			"Deployments carry the labels added during the conversion",
+			[]string{"Deployment"},
+			"Deployments must have the labels "+strings.Join(inv.Labels, ", "),
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": labels},
+			})
+	}
+
+	if inv.Unprivileged {
+		unprivileged := []map[string]interface{}{{
+			"=(securityContext)": map[string]interface{}{"=(privileged)": false},
+		}}
+		docs["kyverno-disallow-privileged.yaml"] = policy("disallow-privileged",
+			"No ECS container ran privileged",
+			[]string{"Pod"},
+			"Privileged containers are not allowed",
+			map[string]interface{}{
+				"spec": map[string]interface{}{
+					"=(initContainers)": unprivileged,
+					"containers":        unprivileged,
+				},
+			})
+	}
+
+	return docs
+}
+
+// gatekeeperConstraints builds Gatekeeper constraints for the invariants. They
+// use the templates of the Gatekeeper policy library, which must be installed.
+func gatekeeperConstraints(clusterName string, inv admissionInvariants) map[string]interface{} {
+	docs := map[string]interface{}{}
+
+	constraint := func(kind, name string, kinds []map[string]interface{}, parameters map[string]interface{}) map[string]interface{} {
+		spec := map[string]interface{}{
+			"enforcementAction": "dryrun",
+			"match": map[string]interface{}{
+				"kinds":      kinds,
+				"namespaces": inv.Namespaces,
+			},
+		}
+		if parameters != nil {
+			spec["parameters"] = parameters
+		}
+		return map[string]interface{}{
+			"apiVersion": apiVersionGatekeeper,
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name": sanitizeName(clusterName + "-" + name),
+			},
+			"spec": spec,
+		}
+	}
+	pods := []map[string]interface{}{{"apiGroups": []string{""}, "kinds": []string{"Pod"}}}
+
+	if len(inv.ImagePrefixes) > 0 {
+		docs["gatekeeper-allowed-repos.yaml"] = constraint("K8sAllowedRepos", "allowed-repos", pods,
+			map[string]interface{}{"repos": inv.ImagePrefixes})
+	}
+
+	if len(inv.Labels) > 0 {
+		var labels []map[string]string
+		for _, key := range inv.Labels {
+			labels = append(labels, map[string]string{"key": key})
+		}
+		deployments := []map[string]interface{}{{"apiGroups": []string{"apps"}, "kinds": []string{"Deployment"}}}
+		docs["gatekeeper-required-labels.yaml"] = constraint("K8sRequiredLabels", "required-labels", deployments,
+			map[string]interface{}{
+				"message": "Deployments must have the labels " + strings.Join(inv.Labels, ", "),
+				"labels":  labels,
+			})
+	}
+
+	if inv.Unprivileged {
+		docs["gatekeeper-disallow-privileged.yaml"] = constraint("K8sPSPPrivilegedContainer", "disallow-privileged", pods, nil)
+	}
+
+	return docs
+}
+
+// writeAdmissionManifests writes the policy stubs into <output>/admission
+func writeAdmissionManifests(outputDir string, docs map[string]interface{}) error {
+	admissionDir := filepath.Join(outputDir, admissionDirName)
+	if err := os.MkdirAll(admissionDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create admission directory %s: %w", admissionDir, err)
+	}
+
+	for _, filename := range sortedDocKeys(docs) {
+		data, err := yaml.Marshal(docs[filename])
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
+		}
+		filePath := filepath.Join(admissionDir, filename)
+		if err := os.WriteFile(filePath, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+		}
+		log.Printf("Wrote: %s", filePath)
+	}
+
+	return nil
+}
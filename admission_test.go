@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestAdmissionManifests tests encoding the registries, labels and privileges of the source as policy stubs
+func TestAdmissionManifests(t *testing.T) {
+	metadata := &ObjectMetadata{Labels: []MetadataRule{{Key: "team", Value: "payments"}, {Kind: "service", Key: "tier", Value: "web"}}}
+	newInfo := func(name string, privileged bool, images ...string) *TaskDefInfo {
+		var containers []corev1.Container
+		for _, image := range images {
+			containers = append(containers, corev1.Container{Name: name, Image: image})
+		}
+		return &TaskDefInfo{
+			Name:   name,
+			Source: &types.TaskDefinition{ContainerDefinitions: []types.ContainerDefinition{{Privileged: aws.Bool(privileged)}}},
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: containers},
+				Metadata:   metadata,
+			},
+		}
+	}
+	taskDefInfos := []*TaskDefInfo{
+		newInfo("web", false, "123456789012.dkr.ecr.us-east-1.amazonaws.com/web:1", "public.ecr.aws/aws-observability/aws-for-fluent-bit:2"),
+		newInfo("api", false, "123456789012.dkr.ecr.us-east-1.amazonaws.com/api:4", "nginx:1.27"),
+	}
+
+	inv := observeAdmissionInvariants(taskDefInfos)
+	want := admissionInvariants{
+		Namespaces:    []string{"default"},
+		ImagePrefixes: []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com/", "nginx", "public.ecr.aws/"},
+		Labels:        []string{"team"},
+		Unprivileged:  true,
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Errorf("invariants = %+v, want %+v", inv, want)
+	}
+
+	kyverno := admissionManifests(admissionKyverno, "Prod_Cluster", taskDefInfos)
+	if len(kyverno) != 3 {
+		t.Fatalf("kyverno files = %v", sortedDocKeys(kyverno))
+	}
+	registries := kyverno["kyverno-allowed-registries.yaml"].(map[string]interface{})
+	if name := registries["metadata"].(map[string]interface{})["name"]; name != "prod-cluster-allowed-registries" {
+		t.Errorf("policy name = %v", name)
+	}
+	rule := registries["spec"].(map[string]interface{})["rules"].([]map[string]interface{})[0]
+	images := rule["validate"].(map[string]interface{})["pattern"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]map[string]string)
+	if got := images[0]["image"]; got != "123456789012.dkr.ecr.us-east-1.amazonaws.com/* | nginx* | public.ecr.aws/*" {
+		t.Errorf("image pattern = %q", got)
+	}
+
+	gatekeeper := admissionManifests(admissionGatekeeper, "prod", taskDefInfos)
+	repos := gatekeeper["gatekeeper-allowed-repos.yaml"].(map[string]interface{})
+	if repos["kind"] != "K8sAllowedRepos" || repos["spec"].(map[string]interface{})["enforcementAction"] != "dryrun" {
+		t.Errorf("allowed repos constraint = %v", repos)
+	}
+
+	// A privileged source container is not locked out
+	taskDefInfos = append(taskDefInfos, newInfo("agent", true, "datadog/agent:7"))
+	if _, ok := admissionManifests(admissionGatekeeper, "prod", taskDefInfos)["gatekeeper-disallow-privileged.yaml"]; ok {
+		t.Errorf("privileged containers disallowed although the source runs one")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// alarmMetrics maps the AWS/ECS service metrics an alarm can watch to the
+// per-pod usage series and the resource of the request they are a percentage of
+var alarmMetrics = map[string]struct {
+	usage    func(pods, window string) string
+	resource string
+}{
+	"CPUUtilization": {
+		usage: func(pods, window string) string {
+			return fmt.Sprintf(`sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{%s, container!=""}[%s]))`, pods, window)
+		},
+		resource: "cpu",
+	},
+	"MemoryUtilization": {
+		usage: func(pods, window string) string {
+			return fmt.Sprintf(`sum by (namespace, pod) (avg_over_time(container_memory_working_set_bytes{%s, container!=""}[%s]))`, pods, window)
+		},
+		resource: "memory",
+	},
+}
+
+// alarmOperators maps the static threshold comparison operators to PromQL
+var alarmOperators = map[cwtypes.ComparisonOperator]string{
+	cwtypes.ComparisonOperatorGreaterThanThreshold:          ">",
+	cwtypes.ComparisonOperatorGreaterThanOrEqualToThreshold: ">=",
+	cwtypes.ComparisonOperatorLessThanThreshold:             "<",
+	cwtypes.ComparisonOperatorLessThanOrEqualToThreshold:    "<=",
+}
+
+// alertNameInvalid matches the characters Prometheus alert names cannot contain
+var alertNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// alarmConverter translates the CloudWatch alarms on the CPU and memory
+// utilization of ECS services into Prometheus alerts
+type alarmConverter struct {
+	client  *cloudwatch.Client
+	cluster string
+}
+
+// apply adds a PrometheusRule with the converted alarms of the services
+// running a task definition. Alarms that cannot be converted are noted.
+func (a *alarmConverter) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	if taskDefInfo.Manifests.Deployment == nil || len(taskDefInfo.Services) == 0 {
+		return
+	}
+
+	var alarms []cwtypes.MetricAlarm
+	for _, svc := range taskDefInfo.Services {
+		for _, metric := range []string{"CPUUtilization", "MemoryUtilization"} {
+			out, err := a.client.DescribeAlarmsForMetric(ctx, &cloudwatch.DescribeAlarmsForMetricInput{
+				Namespace:  aws.String("AWS/ECS"),
+				MetricName: aws.String(metric),
+				Dimensions: []cwtypes.Dimension{
+					{Name: aws.String("ClusterName"), Value: aws.String(a.cluster)},
+					{Name: aws.String("ServiceName"), Value: svc.ServiceName},
+				},
+			})
+			if err != nil {
+				log.Printf("Warning: Failed to describe the %s alarms of service %s: %v", metric, aws.ToString(svc.ServiceName), err)
+				continue
+			}
+			alarms = append(alarms, out.MetricAlarms...)
+		}
+	}
+	applyAlarmRules(taskDefInfo, alarms)
+}
+
+// applyAlarmRules converts CloudWatch alarms into the alerts of a
+// PrometheusRule. The ECS utilization metrics are a percentage of the task
+// reservation, which became the container requests.
+func applyAlarmRules(taskDefInfo *TaskDefInfo, alarms []cwtypes.MetricAlarm) {
+	name := taskDefInfo.Name
+	pods := fmt.Sprintf(`pod=~"%s"`, workloadPodPattern(taskDefInfo))
+
+	var rules []map[string]interface{}
+	seen := map[string]bool{}
+	for _, alarm := range alarms {
+		alarmName := aws.ToString(alarm.AlarmName)
+		if seen[alarmName] {
+			continue
+		}
+		seen[alarmName] = true
+
+		expr, err := alarmExpression(alarm, pods)
+		if err != nil {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("CloudWatch alarm %s was not converted: %v", alarmName, err))
+			continue
+		}
+
+		period := aws.ToInt32(alarm.Period)
+		evaluations := aws.ToInt32(alarm.EvaluationPeriods)
+		if evaluations < 1 {
+			evaluations = 1
+		}
+		rule := map[string]interface{}{
+			"alert": alertName(alarmName),
+			"expr":  expr,
+			"labels": map[string]string{
+				"severity":         "warning",
+				"service":          name,
+				"cloudwatch_alarm": alarmName,
+			},
+			"annotations": map[string]string{
+				"summary": fmt.Sprintf("%s %s %g%% (converted from CloudWatch alarm %s)", aws.ToString(alarm.MetricName), alarmOperators[alarm.ComparisonOperator], aws.ToFloat64(alarm.Threshold), alarmName),
+			},
+		}
+		// Helm would evaluate alert templating in the chart's extras
+		if description := aws.ToString(alarm.AlarmDescription); description != "" && !strings.Contains(description, "{{") {
+			rule["annotations"].(map[string]string)["description"] = description
+		}
+		// The first breaching period fills the rate window; the others are pending
+		if evaluations > 1 {
+			rule["for"] = fmt.Sprintf("%ds", int64(period)*int64(evaluations-1))
+		}
+		rules = append(rules, rule)
+
+		if dp := aws.ToInt32(alarm.DatapointsToAlarm); dp > 0 && dp < evaluations {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("CloudWatch alarm %s fires on %d of %d datapoints; the alert needs every period to breach", alarmName, dp, evaluations))
+		}
+		if len(alarm.AlarmActions) > 0 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("CloudWatch alarm %s notifies %s; route its alert (cloudwatch_alarm=%s) in Alertmanager", alarmName, strings.Join(alarm.AlarmActions, ", "), alarmName))
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{
+		Suffix: "cloudwatch-alarms",
+		Object: map[string]interface{}{
+			"apiVersion": apiVersionPrometheus,
+			"kind":       "PrometheusRule",
+			"metadata": map[string]interface{}{
+				"name":   name + "-cloudwatch-alarms",
+				"labels": map[string]string{"app": name},
+			},
+			"spec": map[string]interface{}{
+				"groups": []map[string]interface{}{
+					{"name": "ecs2k8s-" + name + "-cloudwatch-alarms", "rules": rules},
+				},
+			},
+		},
+	})
+	log.Printf("✓ Converted %d CloudWatch alarm(s) of %s to Prometheus alerts", len(rules), name)
+}
+
+// alarmExpression returns the PromQL expression of a static threshold alarm
+// on the utilization of a service. Average compares the utilization of all
+// pods, like the ECS service metric; Maximum and Minimum that of each pod.
+func alarmExpression(alarm cwtypes.MetricAlarm, pods string) (string, error) {
+	metric, ok := alarmMetrics[aws.ToString(alarm.MetricName)]
+	if !ok {
+		return "", fmt.Errorf("metric %s has no Prometheus equivalent", aws.ToString(alarm.MetricName))
+	}
+	operator, ok := alarmOperators[alarm.ComparisonOperator]
+	if !ok {
+		return "", fmt.Errorf("comparison %s needs an anomaly detection band", alarm.ComparisonOperator)
+	}
+	if alarm.ExtendedStatistic != nil {
+		return "", fmt.Errorf("percentile statistic %s has no per-service equivalent", aws.ToString(alarm.ExtendedStatistic))
+	}
+	period := aws.ToInt32(alarm.Period)
+	if period <= 0 {
+		return "", fmt.Errorf("alarm has no period")
+	}
+
+	window := fmt.Sprintf("%ds", period)
+	usage := metric.usage(pods, window)
+	requests := fmt.Sprintf(`sum by (namespace, pod) (kube_pod_container_resource_requests{%s, resource="%s"})`, pods, metric.resource)
+	threshold := strconv.FormatFloat(aws.ToFloat64(alarm.Threshold), 'f', -1, 64)
+
+	switch alarm.Statistic {
+	case cwtypes.StatisticAverage, "":
+		return fmt.Sprintf("100 * sum by (namespace) (%s) / sum by (namespace) (%s) %s %s", usage, requests, operator, threshold), nil
+	case cwtypes.StatisticMaximum:
+		return fmt.Sprintf("max by (namespace) (100 * %s / %s) %s %s", usage, requests, operator, threshold), nil
+	case cwtypes.StatisticMinimum:
+		return fmt.Sprintf("min by (namespace) (100 * %s / %s) %s %s", usage, requests, operator, threshold), nil
+	default:
+		return "", fmt.Errorf("statistic %s has no utilization equivalent", alarm.Statistic)
+	}
+}
+
+// alertName derives a valid Prometheus alert name from a CloudWatch alarm name
+func alertName(alarmName string) string {
+	name := strings.Trim(alertNameInvalid.ReplaceAllString(alarmName, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "CloudWatch_" + name
+	}
+	return name
+}
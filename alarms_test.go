@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyAlarmRules tests converting CloudWatch utilization alarms into Prometheus alerts
+func TestApplyAlarmRules(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{Name: "web", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}}
+	alarms := []cwtypes.MetricAlarm{
+		{
+			AlarmName:          aws.String("web-cpu-high"),
+			AlarmDescription:   aws.String("CPU above 80%"),
+			MetricName:         aws.String("CPUUtilization"),
+			Statistic:          cwtypes.StatisticAverage,
+			ComparisonOperator: cwtypes.ComparisonOperatorGreaterThanThreshold,
+			Threshold:          aws.Float64(80),
+			Period:             aws.Int32(60),
+			EvaluationPeriods:  aws.Int32(3),
+			DatapointsToAlarm:  aws.Int32(2),
+			AlarmActions:       []string{"arn:aws:sns:us-east-1:123456789012:oncall"},
+		},
+		{
+			AlarmName:          aws.String("web memory max"),
+			MetricName:         aws.String("MemoryUtilization"),
+			Statistic:          cwtypes.StatisticMaximum,
+			ComparisonOperator: cwtypes.ComparisonOperatorGreaterThanOrEqualToThreshold,
+			Threshold:          aws.Float64(90.5),
+			Period:             aws.Int32(300),
+			EvaluationPeriods:  aws.Int32(1),
+		},
+		{
+			AlarmName:          aws.String("web-cpu-anomaly"),
+			MetricName:         aws.String("CPUUtilization"),
+			ComparisonOperator: cwtypes.ComparisonOperatorLessThanLowerOrGreaterThanUpperThreshold,
+			Period:             aws.Int32(60),
+		},
+		{
+			AlarmName:          aws.String("web-cpu-high"),
+			MetricName:         aws.String("CPUUtilization"),
+			ComparisonOperator: cwtypes.ComparisonOperatorGreaterThanThreshold,
+			Period:             aws.Int32(60),
+		},
+	}
+	applyAlarmRules(taskDefInfo, alarms)
+
+	if len(taskDefInfo.Manifests.Extras) != 1 || taskDefInfo.Manifests.Extras[0].Suffix != "cloudwatch-alarms" {
+		t.Fatalf("extras = %+v, want one cloudwatch-alarms PrometheusRule", taskDefInfo.Manifests.Extras)
+	}
+	groups := taskDefInfo.Manifests.Extras[0].Object["spec"].(map[string]interface{})["groups"].([]map[string]interface{})
+	rules := groups[0]["rules"].([]map[string]interface{})
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+
+	cpu := rules[0]
+	if cpu["alert"] != "web_cpu_high" || cpu["for"] != "120s" {
+		t.Errorf("cpu rule = %s for %v", cpu["alert"], cpu["for"])
+	}
+	if expr := cpu["expr"].(string); !strings.HasPrefix(expr, "100 * sum by (namespace) (") || !strings.Contains(expr, "[60s]") || !strings.HasSuffix(expr, "> 80") {
+		t.Errorf("cpu expr = %q", expr)
+	}
+	memory := rules[1]
+	if memory["alert"] != "web_memory_max" || memory["for"] != nil {
+		t.Errorf("memory rule = %s for %v", memory["alert"], memory["for"])
+	}
+	if expr := memory["expr"].(string); !strings.HasPrefix(expr, "max by (namespace) (100 * ") || !strings.Contains(expr, `resource="memory"`) || !strings.HasSuffix(expr, ">= 90.5") {
+		t.Errorf("memory expr = %q", expr)
+	}
+
+	notes := strings.Join(taskDefInfo.Notes, "\n")
+	for _, want := range []string{"web-cpu-anomaly was not converted", "2 of 3 datapoints", "oncall"} {
+		if !strings.Contains(notes, want) {
+			t.Errorf("notes %q do not mention %q", notes, want)
+		}
+	}
+
+	if got := alertName("5xx errors!"); got != "CloudWatch_5xx_errors" {
+		t.Errorf("alertName() = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import "fmt"
+
+const (
+	// alertRestartThreshold is the number of restarts within alertRestartWindow
+	// raising the high restart rate alert
+	alertRestartThreshold = 3
+	alertRestartWindow    = "15m"
+	// alertPendingPeriod is how long a condition holds before its alert fires
+	alertPendingPeriod = "15m"
+)
+
+// deploymentPodPattern matches the names of the pods of a Deployment, as
+// kube-state-metrics and cAdvisor do not export the app label by default
+func deploymentPodPattern(deployment string) string {
+	return deployment + "-[a-z0-9]+-[a-z0-9]{5}"
+}
+
+// workloadPodPattern matches the names of the pods of a service, which are
+// numbered for a StatefulSet
+func workloadPodPattern(taskDefInfo *TaskDefInfo) string {
+	if taskDefInfo.Manifests.StatefulSet != nil {
+		return taskDefInfo.Name + "-[0-9]+"
+	}
+	return deploymentPodPattern(taskDefInfo.Name)
+}
+
+// applyAlertRules adds a PrometheusRule with the basic alerts of a service:
+// crash-looping containers, a high restart rate and fewer available replicas
+// than desired. They take over from the CloudWatch alarms that usually guard
+// the running task count and task stops of ECS services. Annotations carry no
+// alert templating, which Helm would evaluate in the chart's extras.
+func applyAlertRules(taskDefInfo *TaskDefInfo) {
+	if taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	name := taskDefInfo.Name
+	pods := fmt.Sprintf(`pod=~"%s"`, workloadPodPattern(taskDefInfo))
+	labels := map[string]string{"service": name}
+	replicas := fmt.Sprintf(`kube_deployment_status_replicas_available{deployment="%s"} < on (namespace, deployment) kube_deployment_spec_replicas{deployment="%s"}`, name, name)
+	if taskDefInfo.Manifests.StatefulSet != nil {
+		replicas = fmt.Sprintf(`kube_statefulset_status_replicas_ready{statefulset="%s"} < on (namespace, statefulset) kube_statefulset_replicas{statefulset="%s"}`, name, name)
+	}
+	rules := []map[string]interface{}{
+		{
+			"alert":  "ECS2K8sPodCrashLooping",
+			"expr":   fmt.Sprintf(`max by (namespace, pod, container) (max_over_time(kube_pod_container_status_waiting_reason{%s, reason="CrashLoopBackOff"}[5m])) >= 1`, pods),
+			"for":    alertPendingPeriod,
+			"labels": withSeverity(labels, "warning"),
+			"annotations": map[string]string{
+				"summary":     fmt.Sprintf("Container of %s is crash looping", name),
+				"description": fmt.Sprintf("A container of %s has been in CrashLoopBackOff for %s; the namespace, pod and container labels identify it.", name, alertPendingPeriod),
+			},
+		},
+		{
+			"alert":  "ECS2K8sHighRestartRate",
+			"expr":   fmt.Sprintf(`sum by (namespace, pod) (increase(kube_pod_container_status_restarts_total{%s}[%s])) > %d`, pods, alertRestartWindow, alertRestartThreshold),
+			"labels": withSeverity(labels, "warning"),
+			"annotations": map[string]string{
+				"summary":     fmt.Sprintf("Pods of %s restart frequently", name),
+				"description": fmt.Sprintf("A pod of %s restarted more than %d times in the last %s, like ECS tasks that keep stopping.", name, alertRestartThreshold, alertRestartWindow),
+			},
+		},
+		{
+			"alert":  "ECS2K8sReplicasBelowDesired",
+			"expr":   replicas,
+			"for":    alertPendingPeriod,
+			"labels": withSeverity(labels, "critical"),
+			"annotations": map[string]string{
+				"summary":     fmt.Sprintf("%s runs fewer replicas than desired", name),
+				"description": fmt.Sprintf("%s %s has had fewer available replicas than desired for %s, like an ECS service running fewer tasks than desired.", workloadKind(taskDefInfo.Manifests), name, alertPendingPeriod),
+			},
+		},
+	}
+
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{
+		Suffix: "prometheusrule",
+		Object: map[string]interface{}{
+			"apiVersion": apiVersionPrometheus,
+			"kind":       "PrometheusRule",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]string{"app": name},
+			},
+			"spec": map[string]interface{}{
+				"groups": []map[string]interface{}{
+					{"name": "ecs2k8s-" + name, "rules": rules},
+				},
+			},
+		},
+	})
+}
+
+// withSeverity returns a copy of labels with the severity label set
+func withSeverity(labels map[string]string, severity string) map[string]string {
+	copied := map[string]string{"severity": severity}
+	for k, v := range labels {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyAlertRules tests the PrometheusRule generated per service
+func TestApplyAlertRules(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{Name: "web", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}}
+	applyAlertRules(taskDefInfo)
+
+	if len(taskDefInfo.Manifests.Extras) != 1 || taskDefInfo.Manifests.Extras[0].Suffix != "prometheusrule" {
+		t.Fatalf("extras = %+v, want one prometheusrule", taskDefInfo.Manifests.Extras)
+	}
+	rule := taskDefInfo.Manifests.Extras[0].Object
+	if rule["kind"] != "PrometheusRule" || rule["apiVersion"] != apiVersionPrometheus {
+		t.Errorf("object = %s %s", rule["apiVersion"], rule["kind"])
+	}
+	groups := rule["spec"].(map[string]interface{})["groups"].([]map[string]interface{})
+	rules := groups[0]["rules"].([]map[string]interface{})
+
+	alerts := map[string]string{}
+	for _, r := range rules {
+		alerts[r["alert"].(string)] = r["expr"].(string)
+		for _, text := range r["annotations"].(map[string]string) {
+			if strings.Contains(text, "{{") {
+				t.Errorf("annotation %q is templated, which Helm would evaluate", text)
+			}
+		}
+	}
+	for alert, want := range map[string]string{
+		"ECS2K8sPodCrashLooping":      `pod=~"web-[a-z0-9]+-[a-z0-9]{5}", reason="CrashLoopBackOff"`,
+		"ECS2K8sHighRestartRate":      "kube_pod_container_status_restarts_total",
+		"ECS2K8sReplicasBelowDesired": `kube_deployment_spec_replicas{deployment="web"}`,
+	} {
+		if !strings.Contains(alerts[alert], want) {
+			t.Errorf("%s expr = %q, want it to contain %q", alert, alerts[alert], want)
+		}
+	}
+
+	// A task definition running only as a migration Job has no Deployment to alert on
+	job := &TaskDefInfo{Name: "migrate"}
+	applyAlertRules(job)
+	if len(job.Manifests.Extras) != 0 {
+		t.Errorf("got alerts for a task definition without Deployment: %+v", job.Manifests.Extras)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+)
+
+// Migration complexity of a service, from least to most effort
+const (
+	complexityEasy     = "easy"
+	complexityModerate = "moderate"
+	complexityHard     = "hard"
+)
+
+// complexityRank orders the complexities
+var complexityRank = map[string]int{complexityEasy: 0, complexityModerate: 1, complexityHard: 2}
+
+// FeatureUsage is an ECS feature a service uses that needs work beyond the
+// generated manifests
+type FeatureUsage struct {
+	Feature    string `json:"feature"`
+	Complexity string `json:"complexity"`
+	Detail     string `json:"detail"`
+}
+
+// ServiceAnalysis is the migration complexity of one task definition and the
+// services running it
+type ServiceAnalysis struct {
+	Name           string         `json:"name"`
+	TaskDefinition string         `json:"taskDefinition"`
+	Services       []string       `json:"services,omitempty"`
+	Complexity     string         `json:"complexity"`
+	Features       []FeatureUsage `json:"features,omitempty"`
+	// Unmapped lists source fields the converter does not carry over
+	Unmapped []string `json:"unmapped,omitempty"`
+}
+
+// analyzeOptions holds the inputs of the analyze subcommand
+type analyzeOptions struct {
+	region  string
+	cluster string
+	json    bool
+	iac     iacInputs
+}
+
+// newAnalyzeCommand creates the `analyze` subcommand
+func newAnalyzeCommand() *cobra.Command {
+	opts := &analyzeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Report the migration complexity of a cluster's services without converting",
+		Long: `analyze reads the ECS services of a cluster and scores the migration of
+each task definition as easy, moderate or hard from the features it uses:
+
+  moderate  EFS volumes, FireLens log routing, GPUs, privileged containers,
+            host networking, Docker volumes, Service Connect, unconverted fields
+  hard      App Mesh, Windows containers, FSx for Windows volumes
+
+No manifests are written.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAnalyze(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (required unless reading IaC)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name or ARN")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print the analysis as JSON")
+	cmd.Flags().StringVar(&opts.iac.cfnTemplate, "from-cfn-template", "", "Analyze the services of a CloudFormation template instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.terraformState, "from-terraform-state", "", "Analyze the services of a Terraform state file instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.cdkOut, "from-cdk-out", "", "Analyze the services of a cdk.out directory instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.exportedJSON, "from-json", "", "Analyze ECS API responses exported with the AWS CLI (JSON file or directory) instead of ECS")
+
+	return cmd
+}
+
+// runAnalyze executes the analyze subcommand
+func runAnalyze(opts *analyzeOptions) error {
+	ctx := context.Background()
+
+	if err := opts.iac.validate(); err != nil {
+		return err
+	}
+
+	var taskDefInfos []*TaskDefInfo
+	var err error
+	if opts.iac.enabled() {
+		taskDefInfos, err = readIaCTaskDefInfos(opts.iac, opts.cluster)
+	} else {
+		if opts.region == "" || opts.cluster == "" {
+			return fmt.Errorf("--region and --cluster are required")
+		}
+		if err := validateRegion(opts.region); err != nil {
+			return err
+		}
+		taskDefInfos, err = readECSTaskDefInfos(ctx, opts.region, opts.cluster)
+	}
+	if err != nil {
+		return err
+	}
+
+	var analyses []ServiceAnalysis
+	for _, taskDefInfo := range taskDefInfos {
+		analyses = append(analyses, analyzeTaskDefInfo(taskDefInfo))
+	}
+
+	if opts.json {
+		data, err := json.MarshalIndent(analyses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode analysis: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printAnalysis(os.Stdout, analyses)
+	return nil
+}
+
+// analyzeTaskDefInfo detects the features of a task definition and its
+// services and scores the migration by the hardest one
+func analyzeTaskDefInfo(taskDefInfo *TaskDefInfo) ServiceAnalysis {
+	analysis := ServiceAnalysis{
+		Name:       taskDefInfo.Name,
+		Complexity: complexityEasy,
+		Unmapped:   taskDefInfo.Unmapped,
+	}
+	for _, svc := range taskDefInfo.Services {
+		analysis.Services = append(analysis.Services, aws.ToString(svc.ServiceName))
+	}
+
+	add := func(feature, complexity, detail string) {
+		analysis.Features = append(analysis.Features, FeatureUsage{Feature: feature, Complexity: complexity, Detail: detail})
+		if complexityRank[complexity] > complexityRank[analysis.Complexity] {
+			analysis.Complexity = complexity
+		}
+	}
+
+	if taskDef := taskDefInfo.Source; taskDef != nil {
+		analysis.TaskDefinition = aws.ToString(taskDef.TaskDefinitionArn)
+
+		if p := taskDef.RuntimePlatform; p != nil && strings.HasPrefix(string(p.OperatingSystemFamily), "WINDOWS") {
+			add("windows", complexityHard, fmt.Sprintf("%s needs Windows nodes and Windows container images", p.OperatingSystemFamily))
+		}
+		if p := taskDef.ProxyConfiguration; p != nil && p.Type == types.ProxyConfigurationTypeAppmesh {
+			add("appmesh", complexityHard, fmt.Sprintf("App Mesh proxy %s must be replaced by a Kubernetes service mesh", aws.ToString(p.ContainerName)))
+		}
+		if taskDef.NetworkMode == types.NetworkModeHost {
+			add("host-network", complexityModerate, "host networking needs hostNetwork pods and free node ports")
+		}
+
+		for _, v := range taskDef.Volumes {
+			name := aws.ToString(v.Name)
+			switch {
+			case v.EfsVolumeConfiguration != nil:
+				add("efs", complexityModerate, fmt.Sprintf("volume %s needs the EFS CSI driver and a PersistentVolume", name))
+			case v.FsxWindowsFileServerVolumeConfiguration != nil:
+				add("fsx-windows", complexityHard, fmt.Sprintf("volume %s needs the SMB CSI driver on Windows nodes", name))
+			case v.DockerVolumeConfiguration != nil:
+				add("docker-volume", complexityModerate, fmt.Sprintf("volume %s needs a PersistentVolumeClaim or emptyDir", name))
+			}
+		}
+
+		for _, def := range taskDef.ContainerDefinitions {
+			name := aws.ToString(def.Name)
+			if def.FirelensConfiguration != nil || (def.LogConfiguration != nil && def.LogConfiguration.LogDriver == types.LogDriverAwsfirelens) {
+				add("firelens", complexityModerate, fmt.Sprintf("container %s uses FireLens; route logs with a node-level Fluent Bit instead", name))
+			}
+			if def.Privileged != nil && *def.Privileged {
+				add("privileged", complexityModerate, fmt.Sprintf("container %s runs privileged, which Pod Security restricted/baseline reject", name))
+			}
+			for _, r := range def.ResourceRequirements {
+				if r.Type == types.ResourceTypeGpu {
+					add("gpu", complexityModerate, fmt.Sprintf("container %s uses %s GPU(s); needs GPU nodes and the device plugin", name, aws.ToString(r.Value)))
+				}
+			}
+			if strings.Contains(aws.ToString(def.Image), "aws-appmesh-envoy") && taskDef.ProxyConfiguration == nil {
+				add("appmesh", complexityHard, fmt.Sprintf("container %s runs the App Mesh Envoy", name))
+			}
+		}
+	}
+
+	for _, svc := range taskDefInfo.Services {
+		for _, d := range svc.Deployments {
+			if d.ServiceConnectConfiguration != nil && d.ServiceConnectConfiguration.Enabled {
+				add("service-connect", complexityModerate, fmt.Sprintf("service %s uses Service Connect; clients must use Kubernetes Service DNS names", aws.ToString(svc.ServiceName)))
+				break
+			}
+		}
+	}
+
+	if len(analysis.Unmapped) > 0 && analysis.Complexity == complexityEasy {
+		analysis.Complexity = complexityModerate
+	}
+	return analysis
+}
+
+// printAnalysis prints the analyses as a table followed by a count per complexity
+func printAnalysis(w io.Writer, analyses []ServiceAnalysis) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tCOMPLEXITY\tFEATURES\tUNCONVERTED FIELDS")
+
+	counts := map[string]int{}
+	for _, a := range analyses {
+		counts[a.Complexity]++
+		var features []string
+		for _, f := range a.Features {
+			features = append(features, f.Feature)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Name, a.Complexity, orNone(strings.Join(features, ", ")), orNone(strings.Join(a.Unmapped, ", ")))
+	}
+	tw.Flush()
+
+	for _, a := range analyses {
+		if len(a.Features) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s [%s]\n", a.Name, a.Complexity)
+		for _, f := range a.Features {
+			fmt.Fprintf(w, "  - %s (%s): %s\n", f.Feature, f.Complexity, f.Detail)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d easy, %d moderate, %d hard\n", counts[complexityEasy], counts[complexityModerate], counts[complexityHard])
+}
+
+// orNone returns s, or "-" when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestAnalyzeTaskDefInfo tests detecting features and scoring services by the hardest one
+func TestAnalyzeTaskDefInfo(t *testing.T) {
+	easy := analyzeTaskDefInfo(&TaskDefInfo{
+		Name:   "web",
+		Source: &types.TaskDefinition{ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String("web"), Image: aws.String("web:1")}}},
+	})
+	if easy.Complexity != complexityEasy || len(easy.Features) != 0 {
+		t.Errorf("web = %+v, want easy", easy)
+	}
+
+	moderate := analyzeTaskDefInfo(&TaskDefInfo{
+		Name: "api",
+		Source: &types.TaskDefinition{
+			Volumes: []types.Volume{{Name: aws.String("data"), EfsVolumeConfiguration: &types.EFSVolumeConfiguration{FileSystemId: aws.String("fs-1")}}},
+			ContainerDefinitions: []types.ContainerDefinition{{
+				Name:             aws.String("api"),
+				LogConfiguration: &types.LogConfiguration{LogDriver: types.LogDriverAwsfirelens},
+				ResourceRequirements: []types.ResourceRequirement{
+					{Type: types.ResourceTypeGpu, Value: aws.String("1")},
+				},
+			}},
+		},
+		Services: []types.Service{{
+			ServiceName: aws.String("api"),
+			Deployments: []types.Deployment{{ServiceConnectConfiguration: &types.ServiceConnectConfiguration{Enabled: true}}},
+		}},
+	})
+	var features []string
+	for _, f := range moderate.Features {
+		features = append(features, f.Feature)
+	}
+	if moderate.Complexity != complexityModerate || strings.Join(features, ",") != "efs,firelens,gpu,service-connect" {
+		t.Errorf("api = %s %v, want moderate with efs, firelens, gpu and service-connect", moderate.Complexity, features)
+	}
+
+	hard := analyzeTaskDefInfo(&TaskDefInfo{
+		Name: "legacy",
+		Source: &types.TaskDefinition{
+			RuntimePlatform:    &types.RuntimePlatform{OperatingSystemFamily: types.OSFamilyWindowsServer2019Core},
+			ProxyConfiguration: &types.ProxyConfiguration{Type: types.ProxyConfigurationTypeAppmesh, ContainerName: aws.String("envoy")},
+			ContainerDefinitions: []types.ContainerDefinition{
+				{Name: aws.String("envoy"), Image: aws.String("840364872350.dkr.ecr.us-east-1.amazonaws.com/aws-appmesh-envoy:v1.29"), Privileged: aws.Bool(true)},
+			},
+		},
+	})
+	if hard.Complexity != complexityHard || len(hard.Features) != 3 {
+		t.Errorf("legacy = %+v, want hard with windows, appmesh and privileged", hard)
+	}
+
+	// Unconverted fields alone make a service moderate
+	if unmapped := analyzeTaskDefInfo(&TaskDefInfo{Name: "batch", Unmapped: []string{"pidMode"}}); unmapped.Complexity != complexityModerate {
+		t.Errorf("batch complexity = %s, want moderate", unmapped.Complexity)
+	}
+
+	var out bytes.Buffer
+	printAnalysis(&out, []ServiceAnalysis{easy, moderate, hard})
+	if !strings.Contains(out.String(), "1 easy, 1 moderate, 1 hard") || !strings.Contains(out.String(), "legacy [hard]") {
+		t.Errorf("output = %s", out.String())
+	}
+}
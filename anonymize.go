@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Placeholders written by --anonymize
+const (
+	redactedValue       = "REDACTED"
+	placeholderRegistry = "registry.example.com"
+)
+
+var (
+	// arnPattern matches ARNs: partition, service, region, account, resource
+	arnPattern = regexp.MustCompile(`arn:(aws[a-z-]*):([a-z0-9-]+):([a-z0-9-]*):(\d{12})?:([A-Za-z0-9_+=,.@\-/:*]+)`)
+	// ecrRegistryPattern matches ECR registry hosts, which carry the account ID
+	ecrRegistryPattern = regexp.MustCompile(`\d{12}\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?`)
+	// accountIDPattern matches account IDs outside ARNs and registries
+	accountIDPattern = regexp.MustCompile(`\b\d{12}\b`)
+)
+
+// anonymizer removes identifying values from a conversion so it can be shared
+// (--anonymize). Account IDs are hashed with a salt chosen per run: the same
+// account maps to the same placeholder within a run, but hashes cannot be
+// reversed by trying all account IDs.
+type anonymizer struct {
+	salt []byte
+}
+
+// newAnonymizer creates an anonymizer with a random salt
+func newAnonymizer() (*anonymizer, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate anonymization salt: %w", err)
+	}
+	return &anonymizer{salt: salt}, nil
+}
+
+// hash is the salted short hash placeholders are built from
+func (a *anonymizer) hash(value string) string {
+	h := sha256.New()
+	h.Write(a.salt)
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// accountID returns the placeholder of an account ID
+func (a *anonymizer) accountID(id string) string {
+	return "acct-" + a.hash(id)
+}
+
+// text replaces ARNs, ECR registries and account IDs in generated text. ARNs
+// keep their partition, service, region and resource type so the output stays
+// readable; the resource name is replaced.
+func (a *anonymizer) text(s string) string {
+	s = arnPattern.ReplaceAllStringFunc(s, func(arn string) string {
+		m := arnPattern.FindStringSubmatch(arn)
+		account := ""
+		if m[4] != "" {
+			account = a.accountID(m[4])
+		}
+		resource := "redacted-" + a.hash(m[5])
+		if i := strings.IndexAny(m[5], "/:"); i > 0 {
+			resource = m[5][:i+1] + resource
+		}
+		return fmt.Sprintf("arn:%s:%s:%s:%s:%s", m[1], m[2], m[3], account, resource)
+	})
+	s = ecrRegistryPattern.ReplaceAllString(s, placeholderRegistry)
+	return accountIDPattern.ReplaceAllStringFunc(s, a.accountID)
+}
+
+// image replaces the registry host of an image reference. Images without a
+// registry (Docker Hub) are kept.
+func (a *anonymizer) image(image string) string {
+	host, rest, ok := strings.Cut(image, "/")
+	if !ok || !(strings.ContainsAny(host, ".:") || host == "localhost") {
+		return image
+	}
+	return placeholderRegistry + "/" + rest
+}
+
+// taskDefInfo redacts secret values and image registries before the manifests
+// are rendered. The source task definition is copied first, since it is shared
+// with the task definition cache.
+func (a *anonymizer) taskDefInfo(taskDefInfo *TaskDefInfo) error {
+	manifests := &taskDefInfo.Manifests
+	for _, secret := range manifests.Secrets {
+		for key := range secret.StringData {
+			secret.StringData[key] = redactedValue
+		}
+		for key := range secret.Data {
+			secret.Data[key] = []byte(redactedValue)
+		}
+	}
+	if manifests.Deployment != nil {
+		for i := range manifests.Deployment.Containers {
+			c := &manifests.Deployment.Containers[i]
+			c.Image = a.image(c.Image)
+			for j := range c.Env {
+				if c.Env[j].Value != "" && isSecretEnvVar(c.Env[j].Name) {
+					c.Env[j].Value = redactedValue
+				}
+			}
+		}
+	}
+
+	taskDefInfo.Image = a.image(taskDefInfo.Image)
+	for i := range taskDefInfo.Containers {
+		c := &taskDefInfo.Containers[i]
+		c.Image = a.image(c.Image)
+		for name := range c.EnvVars {
+			if isSecretEnvVar(name) {
+				c.EnvVars[name] = redactedValue
+			}
+		}
+	}
+
+	if taskDefInfo.Source == nil {
+		return nil
+	}
+	source, err := copyTaskDefinition(taskDefInfo.Source)
+	if err != nil {
+		return err
+	}
+	for i := range source.ContainerDefinitions {
+		c := &source.ContainerDefinitions[i]
+		if c.Image != nil {
+			image := a.image(*c.Image)
+			c.Image = &image
+		}
+		for j := range c.Environment {
+			if c.Environment[j].Name != nil && isSecretEnvVar(*c.Environment[j].Name) {
+				value := redactedValue
+				c.Environment[j].Value = &value
+			}
+		}
+	}
+	taskDefInfo.Source = source
+	return nil
+}
+
+// copyTaskDefinition returns a deep copy of a task definition
+func copyTaskDefinition(taskDef *types.TaskDefinition) (*types.TaskDefinition, error) {
+	data, err := json.Marshal(taskDef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy task definition: %w", err)
+	}
+	var copied types.TaskDefinition
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy task definition: %w", err)
+	}
+	return &copied, nil
+}
+
+// outputDir rewrites every generated file of an output directory with text.
+// The workspace lock, the conversion state and user-maintained overrides are
+// left untouched: the salt differs per run, so anonymized state would report
+// every field as drifted.
+func (a *anonymizer) outputDir(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && d.Name() == overridesDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == lockFileName || d.Name() == stateFileName {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		anonymized := a.text(string(data))
+		if anonymized == string(data) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(anonymized), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to anonymize %s: %w", path, err)
+		}
+		return nil
+	})
+}
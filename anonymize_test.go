@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestAnonymizerText tests replacement of ARNs, registries and account IDs
+func TestAnonymizerText(t *testing.T) {
+	anon, err := newAnonymizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := anon.accountID("123456789012")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			"eks.amazonaws.com/role-arn: arn:aws:iam::123456789012:role/payments-api",
+			"eks.amazonaws.com/role-arn: arn:aws:iam::" + account + ":role/redacted-" + anon.hash("role/payments-api"),
+		},
+		{
+			"arn:aws:ecs:us-east-1:123456789012:task-definition/web:3",
+			"arn:aws:ecs:us-east-1:" + account + ":task-definition/redacted-" + anon.hash("task-definition/web:3"),
+		},
+		{
+			"arn:aws:s3:::my-bucket",
+			"arn:aws:s3:::redacted-" + anon.hash("my-bucket"),
+		},
+		{
+			"image: 123456789012.dkr.ecr.us-east-1.amazonaws.com/web:1.0",
+			"image: registry.example.com/web:1.0",
+		},
+		{"account 123456789012 and port 8080", "account " + account + " and port 8080"},
+		{"no identifiers here", "no identifiers here"},
+	}
+	for _, tt := range tests {
+		if got := anon.text(tt.in); got != tt.want {
+			t.Errorf("text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	other, _ := newAnonymizer()
+	if other.accountID("123456789012") == account {
+		t.Errorf("account placeholders of different runs are equal; the salt is not applied")
+	}
+}
+
+// TestAnonymizerImage tests that only explicit registries are replaced
+func TestAnonymizerImage(t *testing.T) {
+	anon, _ := newAnonymizer()
+	tests := map[string]string{
+		"nginx:latest":                    "nginx:latest",
+		"bitnami/redis:7":                 "bitnami/redis:7",
+		"ghcr.io/acme/api:v2":             "registry.example.com/acme/api:v2",
+		"localhost/web":                   "registry.example.com/web",
+		"registry.internal:5000/team/web": "registry.example.com/team/web",
+	}
+	for in, want := range tests {
+		if got := anon.image(in); got != want {
+			t.Errorf("image(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestAnonymizerTaskDefInfo tests redaction of secret values and registries
+// without changing the cached source task definition
+func TestAnonymizerTaskDefInfo(t *testing.T) {
+	source := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:  aws.String("web"),
+			Image: aws.String("123456789012.dkr.ecr.us-east-1.amazonaws.com/web:1.0"),
+			Environment: []types.KeyValuePair{
+				{Name: aws.String("MODE"), Value: aws.String("prod")},
+				{Name: aws.String("SECRET_KEY"), Value: aws.String("hunter2")},
+			},
+		}},
+	}
+	info, err := buildTaskDefInfo(source, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	anon, _ := newAnonymizer()
+	if err := anon.taskDefInfo(info); err != nil {
+		t.Fatalf("taskDefInfo() error = %v", err)
+	}
+
+	container := info.Manifests.Deployment.Containers[0]
+	if container.Image != "registry.example.com/web:1.0" {
+		t.Errorf("container image = %q", container.Image)
+	}
+	for _, env := range container.Env {
+		if env.Name == "SECRET_KEY" && env.Value != redactedValue || env.Name == "MODE" && env.Value != "prod" {
+			t.Errorf("env %s = %q", env.Name, env.Value)
+		}
+	}
+	if got := info.Manifests.Secrets[0].StringData["SECRET_KEY"]; got != redactedValue {
+		t.Errorf("Secret value = %q, want %s", got, redactedValue)
+	}
+	if got := info.Containers[0].EnvVars["SECRET_KEY"]; got != redactedValue {
+		t.Errorf("helm env value = %q, want %s", got, redactedValue)
+	}
+	if got := aws.ToString(info.Source.ContainerDefinitions[0].Environment[1].Value); got != redactedValue {
+		t.Errorf("source env value = %q, want %s", got, redactedValue)
+	}
+	if got := aws.ToString(source.ContainerDefinitions[0].Environment[1].Value); got != "hunter2" {
+		t.Errorf("original source was modified: %q", got)
+	}
+}
+
+// TestAnonymizerOutputDir tests that generated files are rewritten, and the
+// lock, the state and overrides are not
+func TestAnonymizerOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	arn := "arn:aws:iam::123456789012:role/web"
+	files := map[string]string{
+		"web-serviceaccount.yaml":             "role-arn: " + arn + "\n",
+		"helm/prod/values.yaml":               "account: 123456789012\n",
+		lockFileName:                          arn,
+		stateFileName:                         arn,
+		filepath.Join(overridesDirName, "x"): arn,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	anon, _ := newAnonymizer()
+	if err := anon.outputDir(dir); err != nil {
+		t.Fatalf("outputDir() error = %v", err)
+	}
+
+	for name, content := range files {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		kept := name == lockFileName || name == stateFileName || strings.HasPrefix(name, overridesDirName)
+		if got := string(data) == content; got != kept {
+			t.Errorf("%s = %q, want kept %v", name, data, kept)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Default apiVersions of the generated objects. Generators use these instead
+// of literals so --api-version can override them in one place.
+const (
+	apiVersionCore             = "v1"
+	apiVersionApps             = "apps/v1"
+	apiVersionBatch            = "batch/v1"
+	apiVersionNetworking       = "networking.k8s.io/v1"
+	apiVersionPolicy           = "policy/v1"
+	apiVersionRBAC             = "rbac.authorization.k8s.io/v1"
+	apiVersionKEDA             = "keda.sh/v1alpha1"
+	apiVersionPrometheus       = "monitoring.coreos.com/v1"
+	apiVersionArgoRollouts     = "argoproj.io/v1alpha1"
+	apiVersionArgoWorkflows    = "argoproj.io/v1alpha1"
+	apiVersionFlagger          = "flagger.app/v1beta1"
+	apiVersionTargetGroup      = "elbv2.k8s.aws/v1beta1"
+	apiVersionKarpenter        = "karpenter.sh/v1"
+	apiVersionKarpenterAWS     = "karpenter.k8s.aws/v1"
+	apiVersionKnativeServing   = "serving.knative.dev/v1"
+	apiVersionOpenShiftRoute   = "route.openshift.io/v1"
+	apiVersionVaultSecrets     = "secrets.hashicorp.com/v1beta1"
+	apiVersionKyverno          = "kyverno.io/v1"
+	apiVersionGatekeeper       = "constraints.gatekeeper.sh/v1beta1"
+	apiVersionCrossplaneK8s    = "kubernetes.crossplane.io/v1alpha2"
+	apiVersionCrossplaneAPIExt = "apiextensions.crossplane.io/v1"
+	apiVersionCrossplanePT     = "pt.fn.crossplane.io/v1beta1"
+)
+
+// APIVersionRule overrides the version of the generated objects of an API
+// group, optionally limited to one kind
+type APIVersionRule struct {
+	// Kind restricts the rule to objects of this kind (case-insensitive); empty matches the whole group
+	Kind    string
+	Group   string
+	Version string
+}
+
+// apiVersionOverrides are the --api-version rules; later rules win
+type apiVersionOverrides []APIVersionRule
+
+// parseAPIVersionOverrides parses repeatable --api-version values of the form
+// [kind:]group/version, or [kind:]version for the core group
+func parseAPIVersionOverrides(flags []string) (apiVersionOverrides, error) {
+	var overrides apiVersionOverrides
+	for _, flag := range flags {
+		rule := APIVersionRule{}
+		value := flag
+		if kind, rest, ok := strings.Cut(flag, ":"); ok {
+			rule.Kind, value = kind, rest
+		}
+		if group, version, ok := strings.Cut(value, "/"); ok {
+			rule.Group, rule.Version = group, version
+		} else {
+			rule.Version = value
+		}
+		if rule.Version == "" || strings.Contains(rule.Version, "/") || (strings.Contains(flag, ":") && rule.Kind == "") {
+			return nil, fmt.Errorf("invalid --api-version %q (must be [kind:]group/version)", flag)
+		}
+		overrides = append(overrides, rule)
+	}
+	return overrides, nil
+}
+
+// resolve returns the apiVersion of an object of kind generated with apiVersion
+func (o apiVersionOverrides) resolve(kind, apiVersion string) string {
+	group := ""
+	if g, _, ok := strings.Cut(apiVersion, "/"); ok {
+		group = g
+	}
+	for _, rule := range o {
+		if rule.Group != group || (rule.Kind != "" && !strings.EqualFold(rule.Kind, kind)) {
+			continue
+		}
+		apiVersion = rule.Version
+		if group != "" {
+			apiVersion = group + "/" + rule.Version
+		}
+	}
+	return apiVersion
+}
+
+// apply overrides the apiVersion of a serialized manifest
+func (o apiVersionOverrides) apply(doc interface{}) {
+	manifest, ok := doc.(map[string]interface{})
+	if len(o) == 0 || !ok {
+		return
+	}
+	kind, _ := manifest["kind"].(string)
+	if apiVersion, ok := manifest["apiVersion"].(string); ok {
+		manifest["apiVersion"] = o.resolve(kind, apiVersion)
+	}
+}
+
+// conversionAPIVersions returns the overrides, which are shared by every service
+func conversionAPIVersions(taskDefInfos []*TaskDefInfo) apiVersionOverrides {
+	for _, taskDefInfo := range taskDefInfos {
+		if len(taskDefInfo.Manifests.APIVersions) > 0 {
+			return taskDefInfo.Manifests.APIVersions
+		}
+	}
+	return nil
+}
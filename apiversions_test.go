@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestAPIVersionOverrides tests overriding the apiVersions of a group or of one kind
+func TestAPIVersionOverrides(t *testing.T) {
+	for _, flag := range []string{"", "keda.sh/", ":apps/v1", "apps/v1/beta"} {
+		if _, err := parseAPIVersionOverrides([]string{flag}); err == nil {
+			t.Errorf("parseAPIVersionOverrides(%q) succeeded", flag)
+		}
+	}
+
+	overrides, err := parseAPIVersionOverrides([]string{"keda.sh/v1beta1", "scaledobject:keda.sh/v1alpha2", "ingress:networking.k8s.io/v1beta1"})
+	if err != nil {
+		t.Fatalf("parseAPIVersionOverrides() error = %v", err)
+	}
+	tests := []struct {
+		kind       string
+		apiVersion string
+		want       string
+	}{
+		{"ScaledObject", apiVersionKEDA, "keda.sh/v1alpha2"},
+		{"TriggerAuthentication", apiVersionKEDA, "keda.sh/v1beta1"},
+		{"Ingress", apiVersionNetworking, "networking.k8s.io/v1beta1"},
+		{"NetworkPolicy", apiVersionNetworking, apiVersionNetworking},
+		// A kind in another group keeps its version
+		{"Ingress", "example.com/v1", "example.com/v1"},
+		{"Deployment", apiVersionApps, apiVersionApps},
+	}
+	for _, tt := range tests {
+		if got := overrides.resolve(tt.kind, tt.apiVersion); got != tt.want {
+			t.Errorf("resolve(%s, %s) = %s, want %s", tt.kind, tt.apiVersion, got, tt.want)
+		}
+	}
+
+	manifests := K8sManifests{
+		Deployment:  &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+		Extras:      []ExtraObject{{Suffix: "ingress", Object: map[string]interface{}{"apiVersion": apiVersionNetworking, "kind": "Ingress", "metadata": map[string]interface{}{"name": "web"}}}},
+		APIVersions: overrides,
+	}
+	files, err := renderManifests("web", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	if got := files["web-ingress.yaml"].(map[string]interface{})["apiVersion"]; got != "networking.k8s.io/v1beta1" {
+		t.Errorf("Ingress apiVersion = %v", got)
+	}
+	if got := files["web-deployment.yaml"].(map[string]interface{})["apiVersion"]; got != apiVersionApps {
+		t.Errorf("Deployment apiVersion = %v", got)
+	}
+}
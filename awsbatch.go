@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// --aws-batch modes for task definitions managed by AWS Batch
+const (
+	awsBatchExclude = "exclude"
+	awsBatchJob     = "job"
+	awsBatchArgo    = "argo"
+)
+
+// awsBatchClusterPrefix starts the names of the ECS clusters AWS Batch creates
+// for its compute environments
+const awsBatchClusterPrefix = "AWSBatch-"
+
+// awsBatchTagPrefix starts the tags AWS Batch adds to the resources it manages
+const awsBatchTagPrefix = "aws:batch:"
+
+// AWSBatchTaskDefinition is a task definition managed by AWS Batch, which
+// runs it as jobs instead of a service
+type AWSBatchTaskDefinition struct {
+	Name              string
+	TaskDefinitionArn string
+	// Reason tells how the task definition was recognized
+	Reason string
+	// File is the generated Job or WorkflowTemplate; empty when excluded
+	File string
+}
+
+// awsBatchModes lists the accepted --aws-batch values
+var awsBatchModes = []string{awsBatchExclude, awsBatchJob, awsBatchArgo}
+
+// isValidAWSBatchMode checks an --aws-batch value
+func isValidAWSBatchMode(mode string) bool {
+	for _, m := range awsBatchModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// isAWSBatchCluster reports whether a cluster was created by AWS Batch for a
+// compute environment
+func isAWSBatchCluster(cluster string) bool {
+	return strings.HasPrefix(extractClusterName(cluster), awsBatchClusterPrefix)
+}
+
+// awsBatchReason tells why a task definition is managed by AWS Batch: it runs
+// on the cluster of a compute environment, was registered by the Batch
+// service-linked role, or carries the tags of Batch. Empty otherwise.
+func awsBatchReason(taskDefInfo *TaskDefInfo, cluster string) string {
+	if isAWSBatchCluster(cluster) {
+		return fmt.Sprintf("cluster %s belongs to an AWS Batch compute environment", extractClusterName(cluster))
+	}
+	if taskDefInfo.Source != nil {
+		registeredBy := aws.ToString(taskDefInfo.Source.RegisteredBy)
+		if strings.Contains(registeredBy, "AWSServiceRoleForBatch") || strings.Contains(registeredBy, "batch.amazonaws.com") {
+			return "registered by AWS Batch (" + registeredBy + ")"
+		}
+	}
+	for _, key := range sortedKeys(taskDefInfo.Tags) {
+		if strings.HasPrefix(key, awsBatchTagPrefix) {
+			return "tagged " + key + " by AWS Batch"
+		}
+	}
+	return ""
+}
+
+// withoutAWSBatch drops the task definitions managed by AWS Batch, which the
+// conversion and its state leave out
+func withoutAWSBatch(taskDefInfos []*TaskDefInfo, cluster string) []*TaskDefInfo {
+	var kept []*TaskDefInfo
+	for _, taskDefInfo := range taskDefInfos {
+		if reason := awsBatchReason(taskDefInfo, cluster); reason != "" {
+			log.Printf("Info: Skipping task definition %s: managed by AWS Batch (%s)", taskDefInfo.Name, reason)
+			continue
+		}
+		kept = append(kept, taskDefInfo)
+	}
+	return kept
+}
+
+// convertAWSBatch handles a task definition managed by AWS Batch: excluded, or
+// converted into a suspended Job or an Argo WorkflowTemplate running its
+// containers to completion. It returns the document, nil when excluded.
+func convertAWSBatch(taskDefInfo *TaskDefInfo, reason, mode string) (*AWSBatchTaskDefinition, map[string]interface{}) {
+	entry := &AWSBatchTaskDefinition{Name: taskDefInfo.Name, Reason: reason}
+	if taskDefInfo.Source != nil {
+		entry.TaskDefinitionArn = aws.ToString(taskDefInfo.Source.TaskDefinitionArn)
+	}
+	if mode == awsBatchExclude || taskDefInfo.Manifests.Deployment == nil || taskDefInfo.Source == nil {
+		log.Printf("Warning: Skipping task definition %s: managed by AWS Batch (%s); pass --aws-batch job or argo to convert it", taskDefInfo.Name, reason)
+		return entry, nil
+	}
+
+	podSpec := taskPodSpec(taskDefInfo)
+	annotations := map[string]string{"ecs2k8s.io/task-definition": entry.TaskDefinitionArn}
+	if mode == awsBatchJob {
+		entry.File = taskDefInfo.Name + "-job.yaml"
+		log.Printf("Info: Converting task definition %s managed by AWS Batch (%s) into a Job", taskDefInfo.Name, reason)
+		return entry, renderTaskJob(taskDefInfo.Name, taskDefInfo.Name, annotations, podSpec)
+	}
+
+	// Each container of a step runs in a containerSet, one container alone as
+	// the container of the template
+	containers, _ := serializePodSpec(podSpec)["containers"].([]map[string]interface{})
+	template := map[string]interface{}{"name": "main"}
+	if len(containers) == 1 {
+		template["container"] = containers[0]
+	} else {
+		template["containerSet"] = map[string]interface{}{"containers": containers}
+	}
+	spec := map[string]interface{}{
+		"entrypoint": "main",
+		"templates":  []map[string]interface{}{template},
+	}
+	if podSpec.ServiceAccountName != "" {
+		spec["serviceAccountName"] = podSpec.ServiceAccountName
+	}
+	if len(podSpec.Volumes) > 0 {
+		spec["volumes"] = toSerializable(podSpec.Volumes)
+	}
+
+	entry.File = taskDefInfo.Name + "-workflowtemplate.yaml"
+	log.Printf("Info: Converting task definition %s managed by AWS Batch (%s) into an Argo WorkflowTemplate", taskDefInfo.Name, reason)
+	return entry, map[string]interface{}{
+		"apiVersion": apiVersionArgoWorkflows,
+		"kind":       "WorkflowTemplate",
+		"metadata": map[string]interface{}{
+			"name":        taskDefInfo.Name,
+			"namespace":   "default",
+			"labels":      map[string]string{"app": taskDefInfo.Name},
+			"annotations": annotations,
+		},
+		"spec": spec,
+	}
+}
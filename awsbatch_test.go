@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// batchTaskDefInfo converts a task definition registered by AWS Batch
+func batchTaskDefInfo(t *testing.T, containers ...string) *TaskDefInfo {
+	t.Helper()
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/render:2"),
+		Family:            aws.String("render"),
+		RegisteredBy:      aws.String("arn:aws:sts::123456789012:assumed-role/AWSServiceRoleForBatch/aws-batch"),
+	}
+	for _, name := range containers {
+		taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, types.ContainerDefinition{
+			Name:    aws.String(name),
+			Image:   aws.String(name + ":1"),
+			Command: []string{"render", "--frames", "100"},
+		})
+	}
+	info, err := buildTaskDefInfo(taskDef, "render")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	return info
+}
+
+// TestAWSBatchReason tests recognizing the task definitions managed by AWS Batch
+func TestAWSBatchReason(t *testing.T) {
+	info := batchTaskDefInfo(t, "render")
+	if reason := awsBatchReason(info, "prod"); !strings.Contains(reason, "registered by AWS Batch") {
+		t.Errorf("awsBatchReason() = %q, want the Batch service-linked role", reason)
+	}
+
+	info.Source.RegisteredBy = aws.String("arn:aws:iam::123456789012:user/deployer")
+	if reason := awsBatchReason(info, "prod"); reason != "" {
+		t.Errorf("awsBatchReason() = %q, want none", reason)
+	}
+	if reason := awsBatchReason(info, "arn:aws:ecs:us-east-1:123456789012:cluster/AWSBatch-render-ce-1a2b3c"); !strings.Contains(reason, "AWSBatch-render-ce-1a2b3c") {
+		t.Errorf("awsBatchReason() = %q, want the compute environment cluster", reason)
+	}
+	info.Tags = map[string]string{"aws:batch:compute-environment": "render-ce"}
+	if reason := awsBatchReason(info, "prod"); !strings.Contains(reason, "aws:batch:compute-environment") {
+		t.Errorf("awsBatchReason() = %q, want the Batch tag", reason)
+	}
+}
+
+// TestWithoutAWSBatch tests that drift leaves out the task definitions the
+// conversion skipped
+func TestWithoutAWSBatch(t *testing.T) {
+	service, err := buildTaskDefInfo(appTaskDef("web"), "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	kept := withoutAWSBatch([]*TaskDefInfo{batchTaskDefInfo(t, "render"), service}, "prod")
+	if len(kept) != 1 || kept[0].Name != "web" {
+		t.Errorf("withoutAWSBatch() = %d task definitions, want web", len(kept))
+	}
+	if kept := withoutAWSBatch([]*TaskDefInfo{service}, "AWSBatch-render-ce-1a2b3c"); len(kept) != 0 {
+		t.Errorf("withoutAWSBatch() on a compute environment cluster = %d task definitions, want none", len(kept))
+	}
+}
+
+// TestConvertAWSBatch tests excluding task definitions managed by AWS Batch
+// or converting them into Jobs and Argo WorkflowTemplates
+func TestConvertAWSBatch(t *testing.T) {
+	entry, doc := convertAWSBatch(batchTaskDefInfo(t, "render"), "registered by AWS Batch", awsBatchExclude)
+	if doc != nil || entry.File != "" || entry.TaskDefinitionArn != "arn:aws:ecs:us-east-1:123456789012:task-definition/render:2" {
+		t.Errorf("exclude = %+v, %v, want an excluded entry", entry, doc)
+	}
+
+	entry, doc = convertAWSBatch(batchTaskDefInfo(t, "render"), "registered by AWS Batch", awsBatchJob)
+	if entry.File != "render-job.yaml" || doc["kind"] != "Job" {
+		t.Fatalf("job = %+v, %v, want render-job.yaml", entry, doc["kind"])
+	}
+	podSpec := doc["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	container := podSpec["containers"].([]map[string]interface{})[0]
+	if podSpec["restartPolicy"] != "Never" || strings.Join(container["args"].([]string), " ") != "render --frames 100" {
+		t.Errorf("Job pod spec = %v, want the command run to completion", podSpec)
+	}
+
+	entry, doc = convertAWSBatch(batchTaskDefInfo(t, "render"), "registered by AWS Batch", awsBatchArgo)
+	if entry.File != "render-workflowtemplate.yaml" || doc["kind"] != "WorkflowTemplate" {
+		t.Fatalf("argo = %+v, %v, want render-workflowtemplate.yaml", entry, doc["kind"])
+	}
+	template := doc["spec"].(map[string]interface{})["templates"].([]map[string]interface{})[0]
+	if _, ok := template["container"]; !ok {
+		t.Errorf("template = %v, want the single container", template)
+	}
+
+	_, doc = convertAWSBatch(batchTaskDefInfo(t, "render", "upload"), "registered by AWS Batch", awsBatchArgo)
+	template = doc["spec"].(map[string]interface{})["templates"].([]map[string]interface{})[0]
+	containerSet, ok := template["containerSet"].(map[string]interface{})
+	if !ok || len(containerSet["containers"].([]map[string]interface{})) != 2 {
+		t.Errorf("template = %v, want a containerSet of both containers", template)
+	}
+
+	dir := t.TempDir()
+	if err := writeConversionReport(dir, "prod", "us-east-1", nil, nil, []*AWSBatchTaskDefinition{entry}); err != nil {
+		t.Fatalf("writeConversionReport() error = %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "## AWS Batch") || !strings.Contains(string(report), "| registered by AWS Batch | jobs/render-workflowtemplate.yaml |") {
+		t.Errorf("report = %s, want the AWS Batch section", report)
+	}
+}
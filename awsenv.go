@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// implicitAWSEnv are the variables the ECS agent sets in every container. AWS
+// SDKs read the region from them, and outside ECS nothing sets them unless an
+// identity webhook does, so they are added explicitly (--aws-env).
+var implicitAWSEnv = []string{"AWS_REGION", "AWS_DEFAULT_REGION"}
+
+// injectAWSEnv adds the region variables ECS provides implicitly to every
+// container that does not define them
+func injectAWSEnv(taskDefInfo *TaskDefInfo, region string) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || region == "" {
+		return
+	}
+
+	added := map[string]bool{}
+	inject := func(containers []corev1.Container) {
+		for i := range containers {
+			c := &containers[i]
+			defined := map[string]bool{}
+			for _, env := range c.Env {
+				defined[env.Name] = true
+			}
+			for _, name := range implicitAWSEnv {
+				if defined[name] {
+					continue
+				}
+				c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: region})
+				added[c.Name] = true
+				for j := range taskDefInfo.Containers {
+					if containerConfig := &taskDefInfo.Containers[j]; containerConfig.Name == c.Name {
+						if containerConfig.EnvVars == nil {
+							containerConfig.EnvVars = map[string]string{}
+						}
+						containerConfig.EnvVars[name] = region
+					}
+				}
+			}
+		}
+	}
+	inject(podSpec.InitContainers)
+	inject(podSpec.Containers)
+
+	if len(added) > 0 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS sets %s implicitly; they were set to %s in container(s) %s (disable with --aws-env=false)", strings.Join(implicitAWSEnv, " and "), region, strings.Join(sortedSet(added), ", ")))
+	}
+}
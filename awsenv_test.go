@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestInjectAWSEnv tests adding the region variables ECS sets implicitly
+func TestInjectAWSEnv(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{
+		Name: "web",
+		Containers: []ContainerConfig{
+			{Name: "web", EnvVars: map[string]string{"AWS_REGION": "eu-west-1"}},
+			{Name: "sidecar"},
+		},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "web", Env: []corev1.EnvVar{{Name: "AWS_REGION", Value: "eu-west-1"}}},
+				{Name: "sidecar"},
+			}},
+		},
+	}
+
+	injectAWSEnv(taskDefInfo, "us-east-1")
+
+	env := func(c corev1.Container) map[string]string {
+		values := map[string]string{}
+		for _, e := range c.Env {
+			values[e.Name] = e.Value
+		}
+		return values
+	}
+	web := env(taskDefInfo.Manifests.Deployment.Containers[0])
+	if web["AWS_REGION"] != "eu-west-1" || web["AWS_DEFAULT_REGION"] != "us-east-1" || len(taskDefInfo.Manifests.Deployment.Containers[0].Env) != 2 {
+		t.Errorf("web env = %v", taskDefInfo.Manifests.Deployment.Containers[0].Env)
+	}
+	sidecar := env(taskDefInfo.Manifests.Deployment.Containers[1])
+	if sidecar["AWS_REGION"] != "us-east-1" || sidecar["AWS_DEFAULT_REGION"] != "us-east-1" {
+		t.Errorf("sidecar env = %v", sidecar)
+	}
+	if taskDefInfo.Containers[1].EnvVars["AWS_REGION"] != "us-east-1" || taskDefInfo.Containers[0].EnvVars["AWS_REGION"] != "eu-west-1" {
+		t.Errorf("container configs = %+v", taskDefInfo.Containers)
+	}
+	if len(taskDefInfo.Notes) != 1 {
+		t.Errorf("notes = %v", taskDefInfo.Notes)
+	}
+
+	// Running again adds nothing
+	injectAWSEnv(taskDefInfo, "us-east-1")
+	if len(taskDefInfo.Notes) != 1 || len(taskDefInfo.Manifests.Deployment.Containers[1].Env) != 2 {
+		t.Errorf("second injection changed the containers: %v", taskDefInfo.Manifests.Deployment.Containers)
+	}
+}
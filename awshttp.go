@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/krishnaduttPanchagnula/ecs2k8s/validators"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// awsHTTPSettings is the proxy and CA bundle of the AWS API calls (--proxy,
+// --no-proxy, --ca-bundle). Unset, the SDK defaults apply: the HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY variables, and the AWS_CA_BUNDLE variable or the
+// ca_bundle of the shared config file.
+type awsHTTPSettings struct {
+	proxy   string
+	noProxy string
+	// noProxySet overrides NO_PROXY, also with an empty --no-proxy
+	noProxySet bool
+	// caBundle holds the PEM certificates trusted next to the system roots
+	caBundle *x509.CertPool
+}
+
+// awsHTTP are the settings of every AWS client of the process
+var awsHTTP = &awsHTTPSettings{}
+
+// configure validates and sets the flag values. noProxySet tells an empty
+// --no-proxy, which proxies every host, from an unset one.
+func (s *awsHTTPSettings) configure(proxy, noProxy string, noProxySet bool, caBundle string) error {
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid --proxy %q (must be a URL such as http://proxy.example.com:3128)", proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid --proxy %q (scheme must be http, https or socks5)", proxy)
+		}
+	}
+	s.proxy, s.noProxy, s.noProxySet = proxy, noProxy, noProxySet
+
+	s.caBundle = nil
+	if caBundle == "" {
+		return nil
+	}
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return fmt.Errorf("failed to read --ca-bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("--ca-bundle %s holds no PEM certificate", caBundle)
+	}
+	s.caBundle = pool
+	return nil
+}
+
+// httpClient returns the HTTP client of the AWS clients, nil to keep the SDK's
+func (s *awsHTTPSettings) httpClient() *awshttp.BuildableClient {
+	if s.proxy == "" && !s.noProxySet && s.caBundle == nil {
+		return nil
+	}
+	return awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		if s.proxy != "" || s.noProxySet {
+			proxies := httpproxy.FromEnvironment()
+			if s.proxy != "" {
+				proxies.HTTPProxy, proxies.HTTPSProxy = s.proxy, s.proxy
+			}
+			if s.noProxySet {
+				proxies.NoProxy = s.noProxy
+			}
+			proxyFunc := proxies.ProxyFunc()
+			tr.Proxy = func(req *http.Request) (*url.URL, error) {
+				return proxyFunc(req.URL)
+			}
+		}
+		if s.caBundle != nil {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			tr.TLSClientConfig.RootCAs = s.caBundle
+		}
+	})
+}
+
+// awsEndpointSettings selects the FIPS and dualstack (IPv4 and IPv6)
+// endpoints of the AWS API calls (--use-fips-endpoint,
+// --use-dualstack-endpoint). Unset, AWS_USE_FIPS_ENDPOINT,
+// AWS_USE_DUALSTACK_ENDPOINT and the shared config file apply.
+type awsEndpointSettings struct {
+	fips      bool
+	dualStack bool
+}
+
+// awsEndpoints are the endpoint settings of every AWS client of the process
+var awsEndpoints = &awsEndpointSettings{}
+
+// validate checks that the region offers the selected endpoints
+func (e *awsEndpointSettings) validate(region string) error {
+	ev := &validators.EndpointValidator{Region: region, FIPS: e.fips, DualStack: e.dualStack}
+	return ev.Validate()
+}
+
+// loadAWSConfig loads the AWS config of a region with the proxy, CA bundle
+// and endpoints of the process. The endpoints are validated first, since a
+// region without them fails every call.
+func loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	if err := awsEndpoints.validate(region); err != nil {
+		return aws.Config{}, err
+	}
+
+	optFns := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if client := awsHTTP.httpClient(); client != nil {
+		optFns = append(optFns, config.WithHTTPClient(client))
+	}
+	if awsEndpoints.fips {
+		optFns = append(optFns, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if awsEndpoints.dualStack {
+		optFns = append(optFns, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	return config.LoadDefaultConfig(ctx, optFns...)
+}
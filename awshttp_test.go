@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// TestAWSHTTPSettings tests the proxy and CA bundle of the AWS API calls
+func TestAWSHTTPSettings(t *testing.T) {
+	s := &awsHTTPSettings{}
+	for _, proxy := range []string{"proxy.example.com:3128", "ftp://proxy.example.com", "http://"} {
+		if err := s.configure(proxy, "", false, ""); err == nil {
+			t.Errorf("configure(proxy %q) succeeded", proxy)
+		}
+	}
+	if err := s.configure("", "", false, ""); err != nil || s.httpClient() != nil {
+		t.Errorf("configure() without settings = %v, want the SDK client", err)
+	}
+
+	if err := s.configure("http://proxy.example.com:3128", "169.254.169.254,.vpce.amazonaws.com", true, ""); err != nil {
+		t.Fatalf("configure() error = %v", err)
+	}
+	tr := s.httpClient().GetTransport()
+	for target, want := range map[string]string{
+		"https://ecs.us-east-1.amazonaws.com/":             "http://proxy.example.com:3128",
+		"https://vpce-1.ecs.us-east-1.vpce.amazonaws.com/": "",
+		"http://169.254.169.254/latest/meta-data/iam/info": "",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		proxy, err := tr.Proxy(req)
+		if err != nil {
+			t.Fatalf("proxy of %s: %v", target, err)
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != want {
+			t.Errorf("proxy of %s = %q, want %q", target, got, want)
+		}
+	}
+
+	// A server with a certificate of a private CA is trusted with the bundle
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.configure("", "", false, bundle); err != nil {
+		t.Fatalf("configure(ca bundle) error = %v", err)
+	}
+	resp, err := s.httpClient().Do(mustRequest(t, server.URL))
+	if err != nil {
+		t.Fatalf("request with the CA bundle failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.configure("", "", false, bundle); err == nil {
+		t.Errorf("configure() accepted a bundle without certificates")
+	}
+}
+
+// TestAWSEndpoints tests the FIPS and dualstack endpoints of the loaded config
+func TestAWSEndpoints(t *testing.T) {
+	saved := *awsEndpoints
+	defer func() { *awsEndpoints = saved }()
+
+	*awsEndpoints = awsEndpointSettings{fips: true, dualStack: true}
+	cfg, err := loadAWSConfig(context.Background(), "us-gov-west-1")
+	if err != nil {
+		t.Fatalf("loadAWSConfig() error = %v", err)
+	}
+	var options config.LoadOptions
+	for _, source := range cfg.ConfigSources {
+		if o, ok := source.(config.LoadOptions); ok {
+			options = o
+		}
+	}
+	if options.UseFIPSEndpoint != aws.FIPSEndpointStateEnabled || options.UseDualStackEndpoint != aws.DualStackEndpointStateEnabled {
+		t.Errorf("loaded config FIPS = %v, dualstack = %v, want both enabled", options.UseFIPSEndpoint, options.UseDualStackEndpoint)
+	}
+
+	*awsEndpoints = awsEndpointSettings{fips: true}
+	if _, err := loadAWSConfig(context.Background(), "eu-west-1"); err == nil {
+		t.Errorf("loadAWSConfig() accepted FIPS endpoints in eu-west-1")
+	}
+}
+
+// mustRequest returns a GET request of a URL
+func mustRequest(t *testing.T, target string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bundleIndexName is the index at the root of a --bundle archive
+const bundleIndexName = "index.json"
+
+// bundleTimeFormat timestamps bundle archives, sorting chronologically
+const bundleTimeFormat = "20060102T150405Z"
+
+// BundleIndex describes the contents of a bundle archive for tooling that
+// consumes conversions without unpacking them
+type BundleIndex struct {
+	Cluster     string    `json:"cluster"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Files are the paths in the archive, relative to its root directory
+	Files   []string       `json:"files"`
+	Objects []BundleObject `json:"objects"`
+	// Kinds counts the objects per kind
+	Kinds map[string]int `json:"kinds"`
+}
+
+// BundleObject is a Kubernetes object written to a file of the bundle
+type BundleObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	File       string `json:"file"`
+}
+
+// writeBundle packages the output directory into <output>-<timestamp>.tar.gz
+// next to it, with index.json at the root of the archive. It returns the
+// path of the archive.
+func writeBundle(outputDir, clusterName string, now time.Time) (string, error) {
+	index, err := bundleIndex(outputDir, clusterName, now)
+	if err != nil {
+		return "", err
+	}
+	indexData, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode bundle index: %w", err)
+	}
+
+	path := fmt.Sprintf("%s-%s.tar.gz", outputDir, now.UTC().Format(bundleTimeFormat))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle %s: %w", path, err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	root := filepath.Base(outputDir)
+
+	indexHeader := &tar.Header{
+		Name:    root + "/" + bundleIndexName,
+		Mode:    0o644,
+		Size:    int64(len(indexData) + 1),
+		ModTime: now,
+	}
+	if err := tw.WriteHeader(indexHeader); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	if _, err := tw.Write(append(indexData, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+
+	for _, file := range index.Files {
+		if err := addBundleFile(tw, filepath.Join(outputDir, filepath.FromSlash(file)), root+"/"+file); err != nil {
+			return "", fmt.Errorf("failed to add %s to bundle %s: %w", file, path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// bundleIndex lists the files of the output directory and the objects of its
+// YAML files. Files that are not plain YAML, such as Helm templates, are
+// listed without objects.
+func bundleIndex(outputDir, clusterName string, now time.Time) (*BundleIndex, error) {
+	index := &BundleIndex{Cluster: clusterName, GeneratedAt: now.UTC(), Kinds: map[string]int{}}
+
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || d.Name() == lockFileName {
+			return nil
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		file := filepath.ToSlash(rel)
+		index.Files = append(index.Files, file)
+
+		if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, object := range yamlObjects(data) {
+			object.File = file
+			index.Objects = append(index.Objects, object)
+			index.Kinds[object.Kind]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", outputDir, err)
+	}
+
+	sort.Strings(index.Files)
+	return index, nil
+}
+
+// yamlObjects returns the Kubernetes objects of a YAML stream. Documents
+// without a kind, and streams that fail to parse, yield no objects.
+func yamlObjects(data []byte) []BundleObject {
+	var objects []BundleObject
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return objects
+		}
+		if err != nil {
+			return nil
+		}
+		if doc.Kind == "" || doc.APIVersion == "" {
+			continue
+		}
+		objects = append(objects, BundleObject{
+			APIVersion: doc.APIVersion,
+			Kind:       doc.Kind,
+			Name:       doc.Metadata.Name,
+			Namespace:  doc.Metadata.Namespace,
+		})
+	}
+}
+
+// addBundleFile copies a file into the archive
+func addBundleFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteBundle tests that the bundle holds the output tree and an index of
+// its files and objects
+func TestWriteBundle(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "prod")
+	files := map[string]string{
+		"web-deployment.yaml":          "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: default\n",
+		"infra/karpenter.yaml":         "apiVersion: karpenter.sh/v1\nkind: NodePool\nmetadata:\n  name: prod\n---\napiVersion: karpenter.k8s.aws/v1\nkind: EC2NodeClass\nmetadata:\n  name: prod\n",
+		"helm/prod/templates/svc.yaml": "{{- range .Values.services }}\nkind: Service\n{{- end }}\n",
+		"conversion-report.md":         "# Report\n",
+		lockFileName:                   "pid: 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	path, err := writeBundle(outputDir, "prod", now)
+	if err != nil {
+		t.Fatalf("writeBundle() error = %v", err)
+	}
+	if want := outputDir + "-20261016T093000Z.tar.gz"; path != want {
+		t.Errorf("writeBundle() = %s, want %s", path, want)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	var index BundleIndex
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Name == "prod/"+bundleIndexName {
+			if err := json.NewDecoder(tr).Decode(&index); err != nil {
+				t.Fatalf("failed to decode index: %v", err)
+			}
+		}
+	}
+
+	want := "prod/index.json,prod/conversion-report.md,prod/helm/prod/templates/svc.yaml,prod/infra/karpenter.yaml,prod/web-deployment.yaml"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("archive entries = %s, want %s", got, want)
+	}
+	if len(index.Files) != 4 || index.Cluster != "prod" {
+		t.Errorf("index = %+v", index)
+	}
+	if len(index.Objects) != 3 || index.Kinds["NodePool"] != 1 || index.Kinds["Deployment"] != 1 {
+		t.Errorf("index objects = %+v, kinds = %v", index.Objects, index.Kinds)
+	}
+	for _, object := range index.Objects {
+		if object.Kind == "Deployment" && (object.File != "web-deployment.yaml" || object.Namespace != "default") {
+			t.Errorf("Deployment = %+v", object)
+		}
+	}
+}
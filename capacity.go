@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// capacityMinNodes keeps at least two nodes so a node failure leaves capacity
+	capacityMinNodes = 2
+	// allocatableCPUFraction and allocatableMemoryFraction approximate the share
+	// of a node left for pods after kube-reserved, system-reserved and DaemonSets
+	allocatableCPUFraction    = 0.90
+	allocatableMemoryFraction = 0.80
+)
+
+// instanceType is an EC2 instance type considered by plan-capacity
+type instanceType struct {
+	Name      string
+	VCPU      int
+	MemoryGiB int
+	// HourlyUSD is the approximate us-east-1 on-demand price, used to rank candidates
+	HourlyUSD float64
+}
+
+// capacityInstanceTypes are the general purpose, compute and memory optimized
+// sizes plan-capacity chooses from
+var capacityInstanceTypes = []instanceType{
+	{Name: "m6i.large", VCPU: 2, MemoryGiB: 8, HourlyUSD: 0.096},
+	{Name: "m6i.xlarge", VCPU: 4, MemoryGiB: 16, HourlyUSD: 0.192},
+	{Name: "m6i.2xlarge", VCPU: 8, MemoryGiB: 32, HourlyUSD: 0.384},
+	{Name: "m6i.4xlarge", VCPU: 16, MemoryGiB: 64, HourlyUSD: 0.768},
+	{Name: "c6i.large", VCPU: 2, MemoryGiB: 4, HourlyUSD: 0.085},
+	{Name: "c6i.xlarge", VCPU: 4, MemoryGiB: 8, HourlyUSD: 0.170},
+	{Name: "c6i.2xlarge", VCPU: 8, MemoryGiB: 16, HourlyUSD: 0.340},
+	{Name: "c6i.4xlarge", VCPU: 16, MemoryGiB: 32, HourlyUSD: 0.680},
+	{Name: "r6i.large", VCPU: 2, MemoryGiB: 16, HourlyUSD: 0.126},
+	{Name: "r6i.xlarge", VCPU: 4, MemoryGiB: 32, HourlyUSD: 0.252},
+	{Name: "r6i.2xlarge", VCPU: 8, MemoryGiB: 64, HourlyUSD: 0.504},
+	{Name: "r6i.4xlarge", VCPU: 16, MemoryGiB: 128, HourlyUSD: 1.008},
+}
+
+// NamespaceCapacity sums the requests of the converted Deployments in a namespace
+type NamespaceCapacity struct {
+	Namespace   string
+	Deployments int
+	Pods        int32
+	// CPUMillis and MemoryBytes are the requests of all replicas
+	CPUMillis   int64
+	MemoryBytes int64
+	// LargestPodCPUMillis and LargestPodMemoryBytes bound the smallest usable node
+	LargestPodCPUMillis   int64
+	LargestPodMemoryBytes int64
+}
+
+// NodeRecommendation is a node group sizing fitting the workload
+type NodeRecommendation struct {
+	InstanceType instanceType
+	Nodes        int
+	// MonthlyUSD is the approximate on-demand cost of the nodes
+	MonthlyUSD float64
+}
+
+// capacityOptions holds the inputs of the plan-capacity subcommand
+type capacityOptions struct {
+	dir        string
+	cluster    string
+	headroom   float64
+	families   []string
+	desiredECS bool
+}
+
+// newPlanCapacityCommand creates the `plan-capacity` subcommand
+func newPlanCapacityCommand() *cobra.Command {
+	opts := &capacityOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "plan-capacity",
+		Short: "Size EKS node groups for a converted cluster",
+		Long: `plan-capacity sums the CPU and memory requests of the Deployments in an
+output directory per namespace and recommends EKS managed node group and
+Karpenter NodePool sizes fitting them with the requested headroom.
+
+Replica counts come from the ECS desiredCount recorded in ` + stateFileName + `
+when available, otherwise from the Deployments. Containers without requests
+count with their limits, as Kubernetes defaults requests to limits.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlanCapacity(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Output directory of a conversion (default: ./<cluster>)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name, used for the default --dir")
+	cmd.Flags().Float64Var(&opts.headroom, "headroom", 20, "Spare capacity in percent on top of the summed requests")
+	cmd.Flags().StringSliceVar(&opts.families, "instance-families", []string{"m6i", "c6i", "r6i"}, "Instance families to choose from")
+	cmd.Flags().BoolVar(&opts.desiredECS, "ecs-desired-count", true, "Use the ECS desiredCount from the state file as replica count")
+
+	return cmd
+}
+
+// runPlanCapacity executes the plan-capacity subcommand
+func runPlanCapacity(opts *capacityOptions) error {
+	dir := opts.dir
+	if dir == "" {
+		if opts.cluster == "" {
+			return fmt.Errorf("either --dir or --cluster is required")
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		dir = filepath.Join(cwd, opts.cluster)
+	}
+	if opts.headroom < 0 {
+		return fmt.Errorf("--headroom must not be negative (got %g)", opts.headroom)
+	}
+
+	deployments, err := readDeployments(dir)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		return fmt.Errorf("no Deployments found in %s", dir)
+	}
+
+	var desired map[string]int32
+	if opts.desiredECS {
+		if state, err := readConversionState(dir); err == nil {
+			desired = desiredCounts(state)
+		}
+	}
+
+	namespaces := sumNamespaceCapacity(deployments, desired)
+	total := totalCapacity(namespaces)
+	recommendations := recommendNodes(total, opts.headroom, opts.families)
+
+	printCapacityPlan(namespaces, total, opts.headroom, recommendations)
+	return nil
+}
+
+// readDeployments parses the Deployments of the YAML files in a directory
+func readDeployments(dir string) ([]*appsv1.Deployment, error) {
+	docs, err := readManifestDocs(dir, "Deployment")
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []*appsv1.Deployment
+	for _, doc := range docs {
+		var deployment appsv1.Deployment
+		if err := decodeManifestDoc(doc, &deployment); err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, &deployment)
+	}
+
+	return deployments, nil
+}
+
+// readManifestDocs parses the documents of one kind from the YAML files in a directory
+func readManifestDocs(dir, kind string) ([]map[string]interface{}, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var docs []map[string]interface{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc map[string]interface{}
+			if err := decoder.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			if doc["kind"] == kind {
+				docs = append(docs, doc)
+			}
+		}
+	}
+
+	return docs, nil
+}
+
+// decodeManifestDoc decodes a parsed document into a typed object, round-tripping
+// through JSON so resource quantities parse into typed values
+func decodeManifestDoc(doc map[string]interface{}, obj interface{}) error {
+	var name interface{}
+	if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+		name = metadata["name"]
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to read %s %v: %w", doc["kind"], name, err)
+	}
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return fmt.Errorf("failed to read %s %v: %w", doc["kind"], name, err)
+	}
+	return nil
+}
+
+// desiredCounts sums the ECS desiredCount of the services of each converted task definition
+func desiredCounts(state *ConversionState) map[string]int32 {
+	counts := map[string]int32{}
+	for name, svc := range state.Services {
+		for field, value := range svc.Fields {
+			if !strings.HasPrefix(field, "service[") || !strings.HasSuffix(field, "].DesiredCount") {
+				continue
+			}
+			if n, err := strconv.ParseInt(value, 10, 32); err == nil {
+				counts[name] += int32(n)
+			}
+		}
+	}
+	return counts
+}
+
+// sumNamespaceCapacity sums the pod requests times replicas per namespace
+func sumNamespaceCapacity(deployments []*appsv1.Deployment, desired map[string]int32) []NamespaceCapacity {
+	byNamespace := map[string]*NamespaceCapacity{}
+	for _, d := range deployments {
+		namespace := d.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		ns, ok := byNamespace[namespace]
+		if !ok {
+			ns = &NamespaceCapacity{Namespace: namespace}
+			byNamespace[namespace] = ns
+		}
+
+		replicas := deploymentReplicas(d, desired)
+		cpu, memory := podRequests(&d.Spec.Template.Spec)
+		ns.Deployments++
+		ns.Pods += replicas
+		ns.CPUMillis += cpu * int64(replicas)
+		ns.MemoryBytes += memory * int64(replicas)
+		ns.LargestPodCPUMillis = max(ns.LargestPodCPUMillis, cpu)
+		ns.LargestPodMemoryBytes = max(ns.LargestPodMemoryBytes, memory)
+	}
+
+	namespaces := make([]NamespaceCapacity, 0, len(byNamespace))
+	for _, ns := range byNamespace {
+		namespaces = append(namespaces, *ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Namespace < namespaces[j].Namespace })
+	return namespaces
+}
+
+// deploymentReplicas returns the replicas of a Deployment, preferring the
+// ECS desiredCount of its service
+func deploymentReplicas(d *appsv1.Deployment, desired map[string]int32) int32 {
+	if n, ok := desired[d.Name]; ok && n > 0 {
+		return n
+	}
+	if d.Spec.Replicas != nil {
+		return *d.Spec.Replicas
+	}
+	return 1
+}
+
+// podRequests returns the CPU (millicores) and memory (bytes) requests of a pod,
+// falling back to limits for containers without requests
+func podRequests(podSpec *corev1.PodSpec) (int64, int64) {
+	var cpu, memory int64
+	for _, c := range podSpec.Containers {
+		if q, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+			cpu += q.MilliValue()
+		} else if q, ok := c.Resources.Limits[corev1.ResourceCPU]; ok {
+			cpu += q.MilliValue()
+		}
+		if q, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+			memory += q.Value()
+		} else if q, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+			memory += q.Value()
+		}
+	}
+	return cpu, memory
+}
+
+// totalCapacity sums the namespaces into a cluster-wide total
+func totalCapacity(namespaces []NamespaceCapacity) NamespaceCapacity {
+	total := NamespaceCapacity{Namespace: "total"}
+	for _, ns := range namespaces {
+		total.Deployments += ns.Deployments
+		total.Pods += ns.Pods
+		total.CPUMillis += ns.CPUMillis
+		total.MemoryBytes += ns.MemoryBytes
+		total.LargestPodCPUMillis = max(total.LargestPodCPUMillis, ns.LargestPodCPUMillis)
+		total.LargestPodMemoryBytes = max(total.LargestPodMemoryBytes, ns.LargestPodMemoryBytes)
+	}
+	return total
+}
+
+// recommendNodes sizes a node group of each candidate instance type for the
+// total requests plus headroom, cheapest first. Types whose allocatable
+// capacity cannot fit the largest pod are skipped.
+func recommendNodes(total NamespaceCapacity, headroom float64, families []string) []NodeRecommendation {
+	factor := 1 + headroom/100
+	cpuNeeded := float64(total.CPUMillis) * factor
+	memoryNeeded := float64(total.MemoryBytes) * factor
+
+	var recommendations []NodeRecommendation
+	for _, it := range capacityInstanceTypes {
+		if !instanceFamilySelected(it.Name, families) {
+			continue
+		}
+
+		allocCPU := float64(it.VCPU*1000) * allocatableCPUFraction
+		allocMemory := float64(int64(it.MemoryGiB)<<30) * allocatableMemoryFraction
+		if float64(total.LargestPodCPUMillis) > allocCPU || float64(total.LargestPodMemoryBytes) > allocMemory {
+			continue
+		}
+
+		nodes := int(math.Ceil(max(cpuNeeded/allocCPU, memoryNeeded/allocMemory)))
+		nodes = max(nodes, capacityMinNodes)
+		recommendations = append(recommendations, NodeRecommendation{
+			InstanceType: it,
+			Nodes:        nodes,
+			MonthlyUSD:   float64(nodes) * it.HourlyUSD * 730,
+		})
+	}
+
+	// Cheapest first; on equal cost fewer, larger nodes waste less on per-node overhead
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if math.Abs(recommendations[i].MonthlyUSD-recommendations[j].MonthlyUSD) < 0.01 {
+			return recommendations[i].Nodes < recommendations[j].Nodes
+		}
+		return recommendations[i].MonthlyUSD < recommendations[j].MonthlyUSD
+	})
+	return recommendations
+}
+
+// instanceFamilySelected reports whether an instance type belongs to one of the families
+func instanceFamilySelected(name string, families []string) bool {
+	family, _, _ := strings.Cut(name, ".")
+	for _, f := range families {
+		if strings.EqualFold(strings.TrimSpace(f), family) {
+			return true
+		}
+	}
+	return false
+}
+
+// printCapacityPlan prints the per-namespace requests and the node recommendations
+func printCapacityPlan(namespaces []NamespaceCapacity, total NamespaceCapacity, headroom float64, recommendations []NodeRecommendation) {
+	fmt.Printf("%-24s %11s %6s %10s %12s\n", "NAMESPACE", "DEPLOYMENTS", "PODS", "CPU", "MEMORY")
+	for _, ns := range append(namespaces, total) {
+		fmt.Printf("%-24s %11d %6d %10s %12s\n", ns.Namespace, ns.Deployments, ns.Pods, formatMillis(ns.CPUMillis), formatGiB(ns.MemoryBytes))
+	}
+
+	if len(recommendations) == 0 {
+		fmt.Println("\nNo instance type of the selected families fits the largest pod.")
+		return
+	}
+
+	best := recommendations[0]
+	fmt.Printf("\nRecommended with %g%% headroom: %d x %s (~$%.0f/month on-demand)\n", headroom, best.Nodes, best.InstanceType.Name, best.MonthlyUSD)
+
+	fmt.Println("\nEKS managed node group:")
+	fmt.Printf("  instanceTypes: [%s]\n", best.InstanceType.Name)
+	fmt.Printf("  scalingConfig: {minSize: %d, desiredSize: %d, maxSize: %d}\n", capacityMinNodes, best.Nodes, best.Nodes*2)
+
+	factor := 1 + headroom/100
+	var familyNames []string
+	seen := map[string]bool{}
+	for _, r := range recommendations {
+		family, _, _ := strings.Cut(r.InstanceType.Name, ".")
+		if !seen[family] {
+			seen[family] = true
+			familyNames = append(familyNames, family)
+		}
+	}
+	// Limits leave room to double the workload before Karpenter stops provisioning
+	fmt.Println("\nKarpenter NodePool:")
+	fmt.Printf("  requirements: karpenter.k8s.aws/instance-family In [%s]\n", strings.Join(familyNames, ", "))
+	fmt.Printf("  limits: {cpu: %s, memory: %s}\n", formatMillis(int64(float64(total.CPUMillis)*factor*2)), formatGiB(int64(float64(total.MemoryBytes)*factor*2)))
+
+	fmt.Println("\nAlternatives:")
+	for _, r := range recommendations[1:] {
+		fmt.Printf("  %2d x %-12s ~$%.0f/month\n", r.Nodes, r.InstanceType.Name, r.MonthlyUSD)
+	}
+}
+
+// formatMillis formats millicores as cores
+func formatMillis(millis int64) string {
+	return strconv.FormatFloat(float64(millis)/1000, 'f', 2, 64)
+}
+
+// formatGiB formats bytes as GiB
+func formatGiB(n int64) string {
+	return strconv.FormatFloat(float64(n)/(1<<30), 'f', 2, 64) + "Gi"
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPlanCapacity tests summing Deployment requests and sizing node groups
+func TestPlanCapacity(t *testing.T) {
+	dir := t.TempDir()
+	manifests := map[string]string{
+		"web-deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: app
+          resources:
+            limits: {cpu: 1000m, memory: 2Gi}
+            requests: {cpu: 500m, memory: 1Gi}
+`,
+		"worker-deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: jobs
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: worker
+          resources:
+            limits: {cpu: 2000m, memory: 4Gi}
+`,
+		"web-service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+	}
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deployments, err := readDeployments(dir)
+	if err != nil {
+		t.Fatalf("readDeployments() error = %v", err)
+	}
+	if len(deployments) != 2 {
+		t.Fatalf("readDeployments() = %d deployments, want 2", len(deployments))
+	}
+
+	namespaces := sumNamespaceCapacity(deployments, map[string]int32{"web": 4})
+	if len(namespaces) != 2 {
+		t.Fatalf("namespaces = %+v, want default and jobs", namespaces)
+	}
+	web, jobs := namespaces[0], namespaces[1]
+	if web.Namespace != "default" || web.Pods != 4 || web.CPUMillis != 2000 || web.MemoryBytes != 4<<30 {
+		t.Errorf("default = %+v, want 4 pods, 2000m, 4Gi", web)
+	}
+	// Without requests, limits count
+	if jobs.Namespace != "jobs" || jobs.Pods != 2 || jobs.CPUMillis != 4000 || jobs.MemoryBytes != 8<<30 {
+		t.Errorf("jobs = %+v, want 2 pods, 4000m, 8Gi", jobs)
+	}
+
+	total := totalCapacity(namespaces)
+	recommendations := recommendNodes(total, 20, []string{"m6i"})
+	if len(recommendations) == 0 {
+		t.Fatal("recommendNodes() returned nothing")
+	}
+	for _, r := range recommendations {
+		allocCPU := float64(r.InstanceType.VCPU*1000) * allocatableCPUFraction
+		allocMemory := float64(int64(r.InstanceType.MemoryGiB)<<30) * allocatableMemoryFraction
+		if float64(r.Nodes)*allocCPU < float64(total.CPUMillis)*1.2 || float64(r.Nodes)*allocMemory < float64(total.MemoryBytes)*1.2 {
+			t.Errorf("%d x %s does not fit the workload", r.Nodes, r.InstanceType.Name)
+		}
+	}
+	for _, r := range recommendNodes(total, 20, []string{"c6i"}) {
+		if r.InstanceType.Name == "c6i.large" {
+			t.Errorf("c6i.large cannot fit a 4Gi pod with its allocatable memory")
+		}
+	}
+	for i := 1; i < len(recommendations); i++ {
+		if recommendations[i].MonthlyUSD < recommendations[i-1].MonthlyUSD-0.01 {
+			t.Errorf("recommendations not sorted by cost")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// Pod template annotations carrying a hash of the generated configuration, so
+// changing a ConfigMap or Secret rolls the Deployment like a new ECS task
+// definition revision did
+const (
+	checksumConfigAnnotation = "checksum/config"
+	checksumSecretAnnotation = "checksum/secret"
+)
+
+// applyConfigChecksums annotates the pod template with the checksums of the
+// service's ConfigMaps and Secrets. It runs after every step that changes their
+// content.
+func applyConfigChecksums(taskDefInfo *TaskDefInfo) {
+	manifests := &taskDefInfo.Manifests
+	if manifests.Deployment == nil {
+		return
+	}
+
+	configData := map[string]interface{}{}
+	for _, cm := range manifests.ConfigMaps {
+		if cm != nil {
+			configData[cm.Name] = cm.Data
+		}
+	}
+	secretData := map[string]interface{}{}
+	for _, secret := range manifests.Secrets {
+		if secret != nil {
+			secretData[secret.Name] = map[string]interface{}{"stringData": secret.StringData, "data": secret.Data}
+		}
+	}
+
+	for annotation, data := range map[string]map[string]interface{}{
+		checksumConfigAnnotation: configData,
+		checksumSecretAnnotation: secretData,
+	} {
+		if len(data) == 0 {
+			continue
+		}
+		sum, err := checksum(data)
+		if err != nil {
+			log.Printf("Warning: Failed to compute %s of %s: %v", annotation, taskDefInfo.Name, err)
+			continue
+		}
+		if manifests.PodAnnotations == nil {
+			manifests.PodAnnotations = map[string]string{}
+		}
+		manifests.PodAnnotations[annotation] = sum
+	}
+}
+
+// checksum returns the hex SHA-256 of a value's JSON encoding, which sorts map
+// keys and so is stable across runs
+func checksum(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// isChecksumAnnotation reports whether a pod annotation is a configuration
+// checksum, which Helm charts compute at render time instead
+func isChecksumAnnotation(key string) bool {
+	return strings.HasPrefix(key, "checksum/")
+}
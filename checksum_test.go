@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestApplyConfigChecksums tests that the pod template checksums follow the
+// content of the ConfigMaps and Secrets
+func TestApplyConfigChecksums(t *testing.T) {
+	newInfo := func(mode, password string) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{},
+				ConfigMaps: []*corev1.ConfigMap{{
+					ObjectMeta: metav1.ObjectMeta{Name: "app-config"},
+					Data:       map[string]string{"MODE": mode, "LOG_LEVEL": "info"},
+				}},
+				Secrets: []*corev1.Secret{{
+					ObjectMeta: metav1.ObjectMeta{Name: "app-secret"},
+					StringData: map[string]string{"PASSWORD": password},
+				}},
+			},
+		}
+	}
+
+	base := newInfo("prod", "s3cret")
+	applyConfigChecksums(base)
+	config := base.Manifests.PodAnnotations[checksumConfigAnnotation]
+	secret := base.Manifests.PodAnnotations[checksumSecretAnnotation]
+	if len(config) != 64 || len(secret) != 64 {
+		t.Fatalf("annotations = %v, want two SHA-256 checksums", base.Manifests.PodAnnotations)
+	}
+
+	same := newInfo("prod", "s3cret")
+	applyConfigChecksums(same)
+	if same.Manifests.PodAnnotations[checksumConfigAnnotation] != config || same.Manifests.PodAnnotations[checksumSecretAnnotation] != secret {
+		t.Errorf("checksums of identical content differ")
+	}
+
+	changed := newInfo("staging", "s3cret")
+	applyConfigChecksums(changed)
+	if changed.Manifests.PodAnnotations[checksumConfigAnnotation] == config {
+		t.Errorf("checksum/config unchanged after a ConfigMap change")
+	}
+	if changed.Manifests.PodAnnotations[checksumSecretAnnotation] != secret {
+		t.Errorf("checksum/secret changed without a Secret change")
+	}
+
+	files, err := renderManifests(base.Name, base.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	template := files["web-deployment.yaml"].(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})
+	if got := template["metadata"].(map[string]interface{})["annotations"].(map[string]string)[checksumConfigAnnotation]; got != config {
+		t.Errorf("rendered checksum/config = %q, want %q", got, config)
+	}
+
+	empty := &TaskDefInfo{Name: "worker", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}}
+	applyConfigChecksums(empty)
+	if empty.Manifests.PodAnnotations != nil {
+		t.Errorf("annotations without config = %v, want none", empty.Manifests.PodAnnotations)
+	}
+}
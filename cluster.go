@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// containerInsightsDisabled is the containerInsights value of clusters
+// without Container Insights
+const containerInsightsDisabled = "disabled"
+
+// ClusterSettings are the cluster-level ECS settings that shape the
+// conversion of its services
+type ClusterSettings struct {
+	// ContainerInsights is the containerInsights setting: enabled, enhanced or disabled
+	ContainerInsights string
+	CapacityProviders []string
+	// DefaultStrategy is the capacity provider strategy of services created
+	// without their own strategy or launch type
+	DefaultStrategy []types.CapacityProviderStrategyItem
+	// DefaultedServices are the services that run with the default strategy
+	DefaultedServices []string
+}
+
+// describeClusterSettings reads the settings and capacity providers of a cluster
+func describeClusterSettings(ctx context.Context, client *ecs.Client, clusterArn string) (*ClusterSettings, error) {
+	out, err := client.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+		Clusters: []string{clusterArn},
+		Include:  []types.ClusterField{types.ClusterFieldSettings},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster %s: %w", clusterArn, err)
+	}
+	if len(out.Clusters) == 0 {
+		return nil, fmt.Errorf("cluster %s not found", clusterArn)
+	}
+	return clusterSettingsOf(out.Clusters[0]), nil
+}
+
+// readClusterSettings reads the settings of a cluster, or returns nil with a
+// warning when they cannot be read
+func readClusterSettings(ctx context.Context, client *ecs.Client, clusterArn string) *ClusterSettings {
+	settings, err := describeClusterSettings(ctx, client, clusterArn)
+	if err != nil {
+		log.Printf("Warning: Failed to read the settings of cluster %s: %v", extractClusterName(clusterArn), err)
+	}
+	return settings
+}
+
+// clusterSettingsOf extracts the settings of a described cluster
+func clusterSettingsOf(cluster types.Cluster) *ClusterSettings {
+	settings := &ClusterSettings{
+		ContainerInsights: containerInsightsDisabled,
+		CapacityProviders: cluster.CapacityProviders,
+		DefaultStrategy:   cluster.DefaultCapacityProviderStrategy,
+	}
+	for _, setting := range cluster.Settings {
+		if setting.Name == types.ClusterSettingNameContainerInsights {
+			settings.ContainerInsights = aws.ToString(setting.Value)
+		}
+	}
+	return settings
+}
+
+// applyDefaultStrategy gives the services without a capacity provider strategy
+// or launch type the cluster's default strategy, which ECS runs them with, so
+// its spot share carries over like that of an explicit strategy
+func (s *ClusterSettings) applyDefaultStrategy(services []types.Service) {
+	if s == nil || len(s.DefaultStrategy) == 0 {
+		return
+	}
+	for i := range services {
+		svc := &services[i]
+		if len(svc.CapacityProviderStrategy) > 0 || svc.LaunchType != "" {
+			continue
+		}
+		svc.CapacityProviderStrategy = s.DefaultStrategy
+		s.DefaultedServices = append(s.DefaultedServices, aws.ToString(svc.ServiceName))
+	}
+	if len(s.DefaultedServices) > 0 {
+		log.Printf("Info: %d service(s) run with the default capacity provider strategy of the cluster", len(s.DefaultedServices))
+	}
+}
+
+// writeClusterSection lists the cluster-level settings and their Kubernetes
+// counterparts
+func writeClusterSection(b *strings.Builder, clusterName string, settings *ClusterSettings) {
+	if settings == nil {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## Cluster Settings\n\n")
+	fmt.Fprintf(b, "| Setting | ECS | Kubernetes |\n")
+	fmt.Fprintf(b, "|---------|-----|------------|\n")
+
+	insights := "Nothing to set up"
+	if settings.ContainerInsights != containerInsightsDisabled {
+		insights = fmt.Sprintf("Install the `amazon-cloudwatch-observability` EKS add-on (`aws eks create-addon --cluster-name <eks-cluster> --addon-name amazon-cloudwatch-observability`) to keep the %s metrics and logs", clusterName)
+		if settings.ContainerInsights == "enhanced" {
+			insights += "; it collects the enhanced observability metrics by default"
+		}
+	}
+	fmt.Fprintf(b, "| Container Insights | %s | %s |\n", settings.ContainerInsights, insights)
+
+	providers := "none"
+	if len(settings.CapacityProviders) > 0 {
+		providers = strings.Join(settings.CapacityProviders, ", ")
+	}
+	fmt.Fprintf(b, "| Capacity providers | %s | Karpenter NodePool (`--create-karpenter`) |\n", providers)
+
+	if len(settings.DefaultStrategy) > 0 {
+		var strategy []string
+		for _, item := range settings.DefaultStrategy {
+			strategy = append(strategy, fmt.Sprintf("%s:%d (base %d)", aws.ToString(item.CapacityProvider), item.Weight, item.Base))
+		}
+		mapped := "No service uses it"
+		if len(settings.DefaultedServices) > 0 {
+			mapped = fmt.Sprintf("Spot share mapped like an explicit strategy for %s", strings.Join(settings.DefaultedServices, ", "))
+		}
+		fmt.Fprintf(b, "| Default capacity provider strategy | %s | %s |\n", strings.Join(strategy, ", "), mapped)
+	}
+}
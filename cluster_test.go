@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestClusterSettings tests reading cluster settings and applying the default
+// capacity provider strategy
+func TestClusterSettings(t *testing.T) {
+	settings := clusterSettingsOf(types.Cluster{
+		CapacityProviders: []string{"FARGATE", "FARGATE_SPOT"},
+		DefaultCapacityProviderStrategy: []types.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		},
+		Settings: []types.ClusterSetting{{Name: types.ClusterSettingNameContainerInsights, Value: aws.String("enhanced")}},
+	})
+	if settings.ContainerInsights != "enhanced" {
+		t.Errorf("ContainerInsights = %q", settings.ContainerInsights)
+	}
+
+	services := []types.Service{
+		{ServiceName: aws.String("web")},
+		{ServiceName: aws.String("api"), LaunchType: types.LaunchTypeFargate},
+		{ServiceName: aws.String("worker"), CapacityProviderStrategy: []types.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE"), Weight: 1}}},
+	}
+	settings.applyDefaultStrategy(services)
+	if len(services[0].CapacityProviderStrategy) != 1 || aws.ToString(services[0].CapacityProviderStrategy[0].CapacityProvider) != "FARGATE_SPOT" {
+		t.Errorf("web strategy = %+v, want the default", services[0].CapacityProviderStrategy)
+	}
+	if len(services[1].CapacityProviderStrategy) != 0 || aws.ToString(services[2].CapacityProviderStrategy[0].CapacityProvider) != "FARGATE" {
+		t.Errorf("services with a launch type or strategy were changed: %+v", services[1:])
+	}
+
+	var b strings.Builder
+	writeClusterSection(&b, "prod", settings)
+	report := b.String()
+	for _, want := range []string{"## Cluster Settings", "amazon-cloudwatch-observability", "FARGATE_SPOT:1 (base 0)", "for web"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("cluster section %q does not contain %q", report, want)
+		}
+	}
+
+	// Without AWS access there are no settings
+	var none *ClusterSettings
+	none.applyDefaultStrategy(services)
+	b.Reset()
+	writeClusterSection(&b, "prod", none)
+	if b.Len() != 0 {
+		t.Errorf("cluster section without settings = %q", b.String())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConversionConfig is the optional --config file controlling conversion behavior
+//
+// Example:
+//
+//	defaults:
+//	  antiAffinity: soft
+//	services:
+//	  api-service:
+//	    antiAffinity: hard
+//	    rbac:
+//	      rules:
+//	        - apiGroups: [""]
+//	          resources: [configmaps]
+//	          verbs: [get, list, watch]
+//	    probes:
+//	      api:
+//	        readiness:
+//	          httpGet: {path: /healthz, port: 8080}
+//	          initialDelaySeconds: 5
+//	    migrations:
+//	      containers: [migrate]
+//	      hooks: [pre-upgrade]
+type ConversionConfig struct {
+	// Defaults apply to every service unless overridden
+	Defaults ServiceSettings `yaml:"defaults"`
+	// Services holds per-service overrides keyed by task definition name
+	Services map[string]ServiceSettings `yaml:"services"`
+}
+
+// ServiceSettings holds the tunable conversion settings for a service
+type ServiceSettings struct {
+	// AntiAffinity spreads replicas across nodes: "", "soft" or "hard"
+	AntiAffinity string `yaml:"antiAffinity,omitempty"`
+	// RBAC generates a Role/RoleBinding for the service's ServiceAccount
+	RBAC *RBACSettings `yaml:"rbac,omitempty"`
+	// Probes holds probe templates keyed by container name, or "*" for all containers
+	Probes map[string]ContainerProbes `yaml:"probes,omitempty"`
+	// Sidecars are the containers kept in every pod by --split-containers
+	Sidecars []string `yaml:"sidecars,omitempty"`
+	// Migrations controls the one-shot migration containers run as a Job
+	Migrations *MigrationSettings `yaml:"migrations,omitempty"`
+}
+
+// MigrationSettings selects the containers of a service that run as a Helm
+// hook Job instead of in its Deployment
+type MigrationSettings struct {
+	// Containers run in the Job instead of the detected migration containers
+	Containers []string `yaml:"containers,omitempty"`
+	// Hooks are the Helm hook events running the Job (default pre-install, pre-upgrade)
+	Hooks []string `yaml:"hooks,omitempty"`
+	// Disabled keeps every container in the Deployment
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// RBACSettings describes the permissions granted to a generated ServiceAccount
+type RBACSettings struct {
+	Rules []RBACRule `yaml:"rules"`
+	// ClusterRoleBinding grants the rules cluster-wide via a ClusterRole and
+	// ClusterRoleBinding instead of a namespaced Role and RoleBinding
+	ClusterRoleBinding bool `yaml:"clusterRoleBinding,omitempty"`
+}
+
+// RBACRule is a single policy rule of a generated Role
+type RBACRule struct {
+	APIGroups []string `yaml:"apiGroups"`
+	Resources []string `yaml:"resources"`
+	Verbs     []string `yaml:"verbs"`
+}
+
+// loadConversionConfig reads and validates a config file. An empty path
+// returns an empty config.
+func loadConversionConfig(path string) (*ConversionConfig, error) {
+	cfg := &ConversionConfig{Services: map[string]ServiceSettings{}}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if cfg.Services == nil {
+		cfg.Services = map[string]ServiceSettings{}
+	}
+
+	if err := cfg.Defaults.validate(); err != nil {
+		return nil, fmt.Errorf("invalid defaults in %s: %w", path, err)
+	}
+	for name, settings := range cfg.Services {
+		if err := settings.validate(); err != nil {
+			return nil, fmt.Errorf("invalid settings for service %s in %s: %w", name, path, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// validate checks the settings values
+func (s ServiceSettings) validate() error {
+	if !isValidAntiAffinity(s.AntiAffinity) {
+		return fmt.Errorf("antiAffinity must be one of: soft, hard (got %q)", s.AntiAffinity)
+	}
+	if s.RBAC != nil {
+		for i, rule := range s.RBAC.Rules {
+			if len(rule.Resources) == 0 || len(rule.Verbs) == 0 {
+				return fmt.Errorf("rbac rule %d must list at least one resource and one verb", i)
+			}
+		}
+	}
+	if s.Migrations != nil {
+		for _, hook := range s.Migrations.Hooks {
+			if !isValidHelmHook(hook) {
+				return fmt.Errorf("migrations hook must be one of: %s (got %q)", strings.Join(helmHooks, ", "), hook)
+			}
+		}
+	}
+	for container, probes := range s.Probes {
+		if err := probes.Liveness.validate(); err != nil {
+			return fmt.Errorf("liveness probe of container %s: %w", container, err)
+		}
+		if err := probes.Readiness.validate(); err != nil {
+			return fmt.Errorf("readiness probe of container %s: %w", container, err)
+		}
+	}
+	return nil
+}
+
+// forService returns the effective settings for a service, with per-service
+// values taking precedence over the defaults
+func (c *ConversionConfig) forService(name string) ServiceSettings {
+	settings := c.Defaults
+
+	override, ok := c.Services[name]
+	if !ok {
+		return settings
+	}
+
+	if override.AntiAffinity != "" {
+		settings.AntiAffinity = override.AntiAffinity
+	}
+	if override.RBAC != nil {
+		settings.RBAC = override.RBAC
+	}
+	if len(override.Probes) > 0 {
+		// Per-service probes replace the defaults container by container
+		probes := make(map[string]ContainerProbes, len(settings.Probes)+len(override.Probes))
+		for container, p := range settings.Probes {
+			probes[container] = p
+		}
+		for container, p := range override.Probes {
+			probes[container] = p
+		}
+		settings.Probes = probes
+	}
+	if len(override.Sidecars) > 0 {
+		settings.Sidecars = override.Sidecars
+	}
+	if override.Migrations != nil {
+		settings.Migrations = override.Migrations
+	}
+
+	return settings
+}
+
+// apply applies the effective service settings to a converted task definition
+func (c *ConversionConfig) apply(taskDefInfo *TaskDefInfo) {
+	settings := c.forService(taskDefInfo.Name)
+
+	// Migration containers leave the Deployment before it is tuned
+	applyMigrations(taskDefInfo, settings.Migrations)
+
+	if podSpec := taskDefInfo.Manifests.Deployment; podSpec != nil && settings.AntiAffinity != "" {
+		antiAffinity := buildAntiAffinity(taskDefInfo.Name, settings.AntiAffinity)
+		// Keep node affinity set from the capacity provider strategy
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = antiAffinity
+		} else {
+			podSpec.Affinity.PodAntiAffinity = antiAffinity.PodAntiAffinity
+		}
+	}
+
+	if settings.RBAC != nil && len(settings.RBAC.Rules) > 0 {
+		createRBAC(taskDefInfo.Name, settings.RBAC, &taskDefInfo.Manifests)
+	}
+
+	applyProbes(taskDefInfo, settings.Probes)
+}
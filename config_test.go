@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestLoadConversionConfig tests reading and validating a --config file
+func TestLoadConversionConfig(t *testing.T) {
+	cfg, err := loadConversionConfig("")
+	if err != nil || cfg.Services == nil {
+		t.Fatalf("loadConversionConfig(\"\") = %+v, %v, want an empty config", cfg, err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "defaults:\n  antiAffinity: soft\nservices:\n  api:\n    antiAffinity: hard\n  web: {}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = loadConversionConfig(path)
+	if err != nil {
+		t.Fatalf("loadConversionConfig() error = %v", err)
+	}
+	for name, want := range map[string]string{"api": "hard", "web": "soft", "worker": "soft"} {
+		if got := cfg.forService(name).AntiAffinity; got != want {
+			t.Errorf("forService(%s).AntiAffinity = %q, want %q", name, got, want)
+		}
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("services:\n  api:\n    antiAffinity: always\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConversionConfig(invalid); err == nil || !strings.Contains(err.Error(), "service api") {
+		t.Errorf("loadConversionConfig() error = %v, want the invalid service", err)
+	}
+	if _, err := loadConversionConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("loadConversionConfig() error = nil, want a read error")
+	}
+}
+
+// TestBuildAntiAffinity tests the soft and hard anti-affinity terms
+func TestBuildAntiAffinity(t *testing.T) {
+	soft := buildAntiAffinity("web", antiAffinitySoft)
+	if soft == nil || len(soft.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 || soft.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		t.Fatalf("soft = %+v, want one preferred term", soft)
+	}
+	term := soft.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+	if term.LabelSelector.MatchLabels["app"] != "web" || term.TopologyKey != "kubernetes.io/hostname" {
+		t.Errorf("soft term = %+v, want app=web per hostname", term)
+	}
+
+	hard := buildAntiAffinity("web", antiAffinityHard)
+	if hard == nil || len(hard.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("hard = %+v, want one required term", hard)
+	}
+	if got := buildAntiAffinity("web", ""); got != nil {
+		t.Errorf("none = %+v, want nil", got)
+	}
+}
+
+// TestConfigApplyKeepsAffinity tests that anti-affinity is merged into an
+// affinity already set on the pod spec
+func TestConfigApplyKeepsAffinity(t *testing.T) {
+	nodeAffinity := &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{},
+	}
+	info := &TaskDefInfo{
+		Name: "web",
+		Manifests: K8sManifests{Deployment: &corev1.PodSpec{
+			Affinity: &corev1.Affinity{NodeAffinity: nodeAffinity},
+		}},
+	}
+
+	cfg := &ConversionConfig{Defaults: ServiceSettings{AntiAffinity: antiAffinityHard}}
+	cfg.apply(info)
+
+	affinity := info.Manifests.Deployment.Affinity
+	if affinity.NodeAffinity != nodeAffinity {
+		t.Errorf("NodeAffinity = %+v, want it kept", affinity.NodeAffinity)
+	}
+	if affinity.PodAntiAffinity == nil || len(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("PodAntiAffinity = %+v, want the hard term", affinity.PodAntiAffinity)
+	}
+}
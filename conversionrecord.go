@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// conversionRecordAPIVersion is the API version of the ConversionRecord CRD
+const conversionRecordAPIVersion = "ecs2k8s.io/v1alpha1"
+
+// ObjectReference identifies an object generated for a service
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// applyConversionRecord adds a ConversionRecord custom resource recording
+// what a service was converted from and into: the source ARNs, the generated
+// objects and the task definition fields that were not mapped. The record is
+// applied next to the objects, so the operator and drift tooling can read the
+// inventory from the cluster.
+func applyConversionRecord(taskDefInfo *TaskDefInfo, cluster, region string) error {
+	// Overrides are logged when applied, so the objects are listed without them
+	manifests := taskDefInfo.Manifests
+	manifests.Overrides = nil
+	files, err := renderManifests(taskDefInfo.Name, manifests)
+	if err != nil {
+		return fmt.Errorf("failed to list the objects of %s: %w", taskDefInfo.Name, err)
+	}
+
+	spec := map[string]interface{}{
+		"cluster": cluster,
+		"region":  region,
+		"objects": generatedObjects(files),
+	}
+	if taskDefInfo.Source != nil {
+		spec["taskDefinitionArn"] = aws.ToString(taskDefInfo.Source.TaskDefinitionArn)
+		if unmapped := unmappedFields(taskDefInfo.Source); len(unmapped) > 0 {
+			spec["unmappedFields"] = unmapped
+		}
+	}
+	var serviceArns []string
+	for _, svc := range taskDefInfo.Services {
+		if arn := aws.ToString(svc.ServiceArn); arn != "" {
+			serviceArns = append(serviceArns, arn)
+		}
+	}
+	if len(serviceArns) > 0 {
+		spec["serviceArns"] = serviceArns
+	}
+
+	record := map[string]interface{}{
+		"apiVersion": conversionRecordAPIVersion,
+		"kind":       "ConversionRecord",
+		"metadata": map[string]interface{}{
+			"name":      taskDefInfo.Name,
+			"namespace": "default",
+			"labels":    map[string]string{"app": taskDefInfo.Name},
+		},
+		"spec": spec,
+	}
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: "conversionrecord", Object: record})
+	log.Printf("✓ Recorded %d generated object(s) of %s", len(spec["objects"].([]ObjectReference)), taskDefInfo.Name)
+	return nil
+}
+
+// generatedObjects returns the references of rendered documents in file order
+func generatedObjects(files map[string]interface{}) []ObjectReference {
+	objects := []ObjectReference{}
+	for _, filename := range sortedDocKeys(files) {
+		doc, ok := toSerializable(files[filename]).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref := ObjectReference{}
+		ref.APIVersion, _ = doc["apiVersion"].(string)
+		ref.Kind, _ = doc["kind"].(string)
+		if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+			ref.Name, _ = metadata["name"].(string)
+		}
+		objects = append(objects, ref)
+	}
+	return objects
+}
+
+// conversionRecordCRD is the CustomResourceDefinition for ConversionRecord
+const conversionRecordCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: conversionrecords.ecs2k8s.io
+spec:
+  group: ecs2k8s.io
+  names:
+    kind: ConversionRecord
+    listKind: ConversionRecordList
+    plural: conversionrecords
+    singular: conversionrecord
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      additionalPrinterColumns:
+        - name: Cluster
+          type: string
+          jsonPath: .spec.cluster
+        - name: Task Definition
+          type: string
+          jsonPath: .spec.taskDefinitionArn
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [cluster, region]
+              properties:
+                cluster:
+                  type: string
+                region:
+                  type: string
+                taskDefinitionArn:
+                  type: string
+                serviceArns:
+                  type: array
+                  items:
+                    type: string
+                objects:
+                  type: array
+                  items:
+                    type: object
+                    properties:
+                      apiVersion:
+                        type: string
+                      kind:
+                        type: string
+                      name:
+                        type: string
+                unmappedFields:
+                  type: array
+                  items:
+                    type: string
+`
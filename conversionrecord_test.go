@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestApplyConversionRecord tests recording the source ARNs, generated
+// objects and unmapped fields of a service
+func TestApplyConversionRecord(t *testing.T) {
+	taskDefInfo, err := buildTaskDefInfo(&types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:7"),
+		Family:            aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("web"),
+			Image:        aws.String("nginx:1.27"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			Links:        []string{"db"},
+		}},
+	}, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	taskDefInfo.Services = []types.Service{{
+		ServiceName: aws.String("web"),
+		ServiceArn:  aws.String("arn:aws:ecs:us-east-1:123456789012:service/prod/web"),
+	}}
+
+	if err := applyConversionRecord(taskDefInfo, "prod", "us-east-1"); err != nil {
+		t.Fatalf("applyConversionRecord() error = %v", err)
+	}
+	files, err := renderManifests("web", taskDefInfo.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	record, ok := files["web-conversionrecord.yaml"].(map[string]interface{})
+	if !ok || record["kind"] != "ConversionRecord" {
+		t.Fatalf("files = %v, want web-conversionrecord.yaml", sortedDocKeys(files))
+	}
+
+	spec := record["spec"].(map[string]interface{})
+	if spec["taskDefinitionArn"] != "arn:aws:ecs:us-east-1:123456789012:task-definition/web:7" || spec["cluster"] != "prod" {
+		t.Errorf("spec = %v, want the cluster and task definition ARN", spec)
+	}
+	if arns := spec["serviceArns"].([]string); len(arns) != 1 || arns[0] != "arn:aws:ecs:us-east-1:123456789012:service/prod/web" {
+		t.Errorf("serviceArns = %v, want the service ARN", arns)
+	}
+	if unmapped := spec["unmappedFields"].([]string); len(unmapped) != 1 || unmapped[0] != "containerDefinitions[web].links" {
+		t.Errorf("unmappedFields = %v, want the container links", unmapped)
+	}
+
+	kinds := map[string]bool{}
+	for _, ref := range spec["objects"].([]ObjectReference) {
+		if (ref.Kind == "Deployment" || ref.Kind == "Service") && ref.Name != "web" {
+			t.Errorf("object %+v, want the name web", ref)
+		}
+		kinds[ref.Kind] = true
+	}
+	if !kinds["Deployment"] || !kinds["Service"] || kinds["ConversionRecord"] {
+		t.Errorf("object kinds = %v, want the Deployment and Service without the record", kinds)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -34,17 +35,75 @@ type K8sManifests struct {
 	Secrets        []*corev1.Secret       `json:"secrets,omitempty"`
 	Services       []*corev1.Service      `json:"services,omitempty"`
 	ServiceAccount *corev1.ServiceAccount `json:"serviceaccount,omitempty"`
+	RBAC           *RBACManifests         `json:"rbac,omitempty"`
+	Extras         []ExtraObject          `json:"extras,omitempty"`
 	Containers     []ContainerResources   `json:"containers,omitempty"`
+	// DeploymentAnnotations are set on the Deployment itself
+	DeploymentAnnotations map[string]string `json:"deploymentAnnotations,omitempty"`
+	// PodAnnotations are set on the Deployment's pod template
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	// PodLabels are set on the Deployment's pod template besides the app label
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+	// StatefulSet renders the Deployment's pod spec as a StatefulSet (--smart-templates)
+	StatefulSet *StatefulSetSettings `json:"statefulSet,omitempty"`
+	// ProgressDeadlineSeconds is set on the Deployment, mapped from the ECS circuit breaker
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Knative renders the Deployment and Services as a Knative Service (--output=knative)
+	Knative *KnativeSettings `json:"knative,omitempty"`
+	// Overrides are user patches merged into the rendered documents
+	Overrides []OverridePatch `json:"-"`
+	// Metadata holds labels and annotations injected into every object
+	Metadata *ObjectMetadata `json:"-"`
+	// APIVersions override the apiVersions of the generated objects (--api-version)
+	APIVersions apiVersionOverrides `json:"-"`
+	// Kinds limits the kinds of the generated objects (--include-kinds, --exclude-kinds)
+	Kinds *kindFilter `json:"-"`
+	// Owner is stamped on every object as ownership labels
+	Owner *ObjectOwner `json:"-"`
+	// Migration runs one-shot migration containers as a Job before the Deployment
+	Migration *MigrationJob `json:"migration,omitempty"`
+}
+
+// ExtraObject is an additional serialized manifest, typically a custom
+// resource such as a KEDA ScaledObject, written as <task-def>-<suffix>.yaml
+type ExtraObject struct {
+	Suffix string                 `json:"suffix"`
+	Object map[string]interface{} `json:"object"`
 }
 
 // TaskDefInfo represents a task definition with its converted K8s manifests
 type TaskDefInfo struct {
-	Name             string
+	Name string
+	// SourceName is the ECS task definition name Name was sanitized from
+	SourceName       string
 	Image            string
 	Containers       []ContainerConfig
 	Manifests        K8sManifests
 	ExecutionRoleArn string
 	TaskRoleArn      string
+	// Source is the ECS task definition this info was converted from
+	Source *types.TaskDefinition
+	// Services are the ECS services running this task definition
+	Services []types.Service
+	// Canaries are the active task sets of the services running another task
+	// definition next to this one
+	Canaries []CanaryTaskSet
+	// Tags are the tags of the ECS task definition
+	Tags map[string]string
+	// Rightsizing lists the requests changed by --rightsize
+	Rightsizing []ResourceChange
+	// Notes are conversion decisions recorded in the report
+	Notes []string
+	// Unmapped lists source fields that have no equivalent in the output
+	Unmapped []string
+	// Identity is the workload identity replacing the ECS IAM role (--profile)
+	Identity *IdentityBinding
+	// Zones is the availability zone footprint of the ECS services
+	Zones *ZoneFootprint
+	// Lint lists the best-practice findings of the generated objects (--lint)
+	Lint []LintFinding
+	// CutoverScript shifts ALB traffic from ECS to Kubernetes (--cutover-weight)
+	CutoverScript string
 }
 
 // ContainerConfig represents configuration for a single container
@@ -55,6 +114,60 @@ type ContainerConfig struct {
 	Memory  string
 	Ports   []int32
 	EnvVars map[string]string
+	// RequestCPU and RequestMemory override the requests when they differ from
+	// the limits (e.g. after rightsizing)
+	RequestCPU    string
+	RequestMemory string
+	// ImagePullPolicy is pinned in every output format
+	ImagePullPolicy string
+	// LivenessProbe and ReadinessProbe are injected from the config file
+	LivenessProbe  *corev1.Probe
+	ReadinessProbe *corev1.Probe
+	// SecurityContext carries readonlyRootFilesystem and is set for the target platform (--profile)
+	SecurityContext *corev1.SecurityContext
+	// Stdin and TTY are mapped from the ECS interactive and pseudoTerminal flags
+	Stdin bool
+	TTY   bool
+	// Command and Args are set for the containers of a migration Job
+	Command []string
+	Args    []string
+}
+
+// defaultImagePullPolicy is used unless --image-pull-policy is set
+const defaultImagePullPolicy = corev1.PullIfNotPresent
+
+// isValidImagePullPolicy reports whether policy is a Kubernetes image pull policy
+func isValidImagePullPolicy(policy string) bool {
+	switch corev1.PullPolicy(policy) {
+	case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return true
+	}
+	return false
+}
+
+// setImagePullPolicy pins the image pull policy of every container
+func setImagePullPolicy(taskDefInfo *TaskDefInfo, policy string) {
+	if podSpec := taskDefInfo.Manifests.Deployment; podSpec != nil {
+		for i := range podSpec.Containers {
+			podSpec.Containers[i].ImagePullPolicy = corev1.PullPolicy(policy)
+		}
+		for i := range podSpec.InitContainers {
+			podSpec.InitContainers[i].ImagePullPolicy = corev1.PullPolicy(policy)
+		}
+	}
+	for i := range taskDefInfo.Containers {
+		taskDefInfo.Containers[i].ImagePullPolicy = policy
+	}
+}
+
+// containerSecurityContext returns the securityContext of the ECS container
+// settings it covers, or nil when none is set
+func containerSecurityContext(container types.ContainerDefinition) *corev1.SecurityContext {
+	if !aws.ToBool(container.ReadonlyRootFilesystem) {
+		return nil
+	}
+	readOnly := true
+	return &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnly}
 }
 
 func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
@@ -73,6 +186,10 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 	if taskDef.TaskDefinitionArn != nil {
 		taskDefName = extractTaskDefName(*taskDef.TaskDefinitionArn)
 	}
+	if taskDefName == "" && taskDef.Family != nil {
+		taskDefName = *taskDef.Family
+	}
+	taskDefName = sanitizeName(taskDefName)
 
 	var containers []corev1.Container
 	var containerResources []ContainerResources
@@ -80,8 +197,9 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 	var secrets []*corev1.Secret
 	var services []*corev1.Service
 	var serviceAccount *corev1.ServiceAccount
+	containerNames := nameClaims{}
 
-	for i, container := range taskDef.ContainerDefinitions {
+	for i, container := range orderContainerDefinitions(taskDef.ContainerDefinitions) {
 		if container.Name == nil || *container.Name == "" {
 			log.Printf("Warning: Container %d missing Name field, skipping", i)
 			continue
@@ -91,24 +209,34 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 			continue
 		}
 
-		containerName := *container.Name
+		containerName, err := containerNames.claim(*container.Name)
+		if err != nil {
+			return manifests, fmt.Errorf("container names collide: %w", err)
+		}
+		if containerName != *container.Name {
+			log.Printf("Info: Renamed container %s to %s for Kubernetes", *container.Name, containerName)
+		}
 
 		ports := convertPorts(container.PortMappings)
 		envVars := convertEnvVars(container.Environment)
 
 		cpuVal := container.Cpu
 		cpuQty := cpuToQuantity(&cpuVal)
-		memoryQty := memoryToQuantity(container.Memory)
+		memoryQty, memoryLimit := memoryRequirements(container.Memory, container.MemoryReservation)
 
 		c := corev1.Container{
-			Name:  containerName,
-			Image: *container.Image,
-			Ports: ports,
-			Env:   envVars,
+			Name:            containerName,
+			Image:           *container.Image,
+			ImagePullPolicy: defaultImagePullPolicy,
+			Ports:           ports,
+			Env:             envVars,
+			// Console-style containers keep their terminal
+			Stdin:           aws.ToBool(container.Interactive),
+			TTY:             aws.ToBool(container.PseudoTerminal),
+			SecurityContext: containerSecurityContext(container),
 			Resources: corev1.ResourceRequirements{
 				Limits: corev1.ResourceList{
-					corev1.ResourceCPU:    cpuQty,
-					corev1.ResourceMemory: memoryQty,
+					corev1.ResourceCPU: cpuQty,
 				},
 				Requests: corev1.ResourceList{
 					corev1.ResourceCPU:    cpuQty,
@@ -116,6 +244,9 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 				},
 			},
 		}
+		if memoryLimit != nil {
+			c.Resources.Limits[corev1.ResourceMemory] = *memoryLimit
+		}
 		containers = append(containers, c)
 
 		portList := make([]int32, 0)
@@ -156,6 +287,8 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 		Containers: containers,
 	}
 
+	applyNamespaceModes(podSpec, taskDef.IpcMode, taskDef.PidMode)
+
 	// Create ServiceAccount for image pull and IAM role support
 	if serviceAccount = createServiceAccount("", taskDef.TaskRoleArn, taskDef.ExecutionRoleArn); serviceAccount != nil {
 		// Attach ServiceAccount to PodSpec
@@ -172,6 +305,72 @@ func convertTaskDefToK8s(taskDef *types.TaskDefinition) (K8sManifests, error) {
 	return manifests, nil
 }
 
+// applyNamespaceModes maps ECS ipcMode/pidMode onto the pod's host and
+// process namespace settings
+func applyNamespaceModes(podSpec *corev1.PodSpec, ipcMode types.IpcMode, pidMode types.PidMode) {
+	switch ipcMode {
+	case types.IpcModeHost:
+		podSpec.HostIPC = true
+		log.Printf("Warning: Task definition uses ipcMode host, setting hostIPC: true (requires a privileged Pod Security level)")
+	case types.IpcModeTask:
+		// Containers in a pod always share an IPC namespace
+		log.Printf("Info: ipcMode task maps to the pod's shared IPC namespace")
+	case types.IpcModeNone:
+		log.Printf("Warning: ipcMode none has no Kubernetes equivalent, containers will share the pod IPC namespace")
+	}
+
+	switch pidMode {
+	case types.PidModeHost:
+		podSpec.HostPID = true
+		log.Printf("Warning: Task definition uses pidMode host, setting hostPID: true (requires a privileged Pod Security level)")
+	case types.PidModeTask:
+		shareProcessNamespace := true
+		podSpec.ShareProcessNamespace = &shareProcessNamespace
+	}
+}
+
+// Anti-affinity modes for spreading replicas of a service across nodes
+const (
+	antiAffinitySoft = "soft"
+	antiAffinityHard = "hard"
+)
+
+// isValidAntiAffinity checks if mode is empty or a supported anti-affinity mode
+func isValidAntiAffinity(mode string) bool {
+	return mode == "" || mode == antiAffinitySoft || mode == antiAffinityHard
+}
+
+// buildAntiAffinity creates a podAntiAffinity on the service's own app label,
+// mimicking ECS spread placement across instances. Soft anti-affinity is a
+// scheduling preference; hard anti-affinity refuses to co-locate replicas.
+func buildAntiAffinity(appName, mode string) *corev1.Affinity {
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": appName},
+		},
+		TopologyKey: "kubernetes.io/hostname",
+	}
+
+	switch mode {
+	case antiAffinitySoft:
+		return &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{Weight: 100, PodAffinityTerm: term},
+				},
+			},
+		}
+	case antiAffinityHard:
+		return &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+			},
+		}
+	}
+
+	return nil
+}
+
 // createServiceAccount creates a Kubernetes ServiceAccount with IRSA annotations
 // If taskRoleArn is provided, it's preferred over executionRoleArn
 func createServiceAccount(taskDefName string, taskRoleArn, executionRoleArn *string) *corev1.ServiceAccount {
@@ -179,7 +378,7 @@ func createServiceAccount(taskDefName string, taskRoleArn, executionRoleArn *str
 		taskDefName = "default"
 	}
 
-	saName := fmt.Sprintf("%s-sa", taskDefName)
+	saName := sanitizeName(fmt.Sprintf("%s-sa", taskDefName))
 
 	sa := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
@@ -204,7 +403,7 @@ func createServiceAccount(taskDefName string, taskRoleArn, executionRoleArn *str
 			sa.Annotations = make(map[string]string)
 		}
 		// Add IRSA annotation for EKS to associate IAM role with ServiceAccount
-		sa.Annotations["eks.amazonaws.com/role-arn"] = roleARN
+		sa.Annotations[irsaAnnotation] = roleARN
 		log.Printf("✓ Created ServiceAccount %s with IRSA annotation for role: %s", saName, roleARN)
 		return sa
 	}
@@ -217,7 +416,7 @@ func createServiceAccount(taskDefName string, taskRoleArn, executionRoleArn *str
 func createConfigMap(containerName string, envVars []types.KeyValuePair) *corev1.ConfigMap {
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("%s-config", containerName),
+			Name: sanitizeName(fmt.Sprintf("%s-config", containerName)),
 		},
 		Data: make(map[string]string),
 	}
@@ -249,7 +448,7 @@ func createConfigMap(containerName string, envVars []types.KeyValuePair) *corev1
 func createSecret(containerName string, envVars []types.KeyValuePair) *corev1.Secret {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("%s-secret", containerName),
+			Name: sanitizeName(fmt.Sprintf("%s-secret", containerName)),
 		},
 		Type:       corev1.SecretTypeOpaque,
 		StringData: make(map[string]string),
@@ -345,6 +544,7 @@ func createService(containerName, taskDefName string, portMappings []types.PortM
 			Type:  corev1.ServiceTypeClusterIP,
 		},
 	}
+	nameServicePorts(service)
 
 	return service
 }
@@ -371,6 +571,23 @@ func convertPorts(portMappings []types.PortMapping) []corev1.ContainerPort {
 	return ports
 }
 
+// orderContainerDefinitions returns the container definitions with the
+// essential ones first, each group in task definition order. kubectl exec,
+// logs and port-forward default to the first container of a pod, which is the
+// main container of an ECS task rather than one of its sidecars.
+func orderContainerDefinitions(defs []types.ContainerDefinition) []types.ContainerDefinition {
+	ordered := make([]types.ContainerDefinition, 0, len(defs))
+	for _, essential := range []bool{true, false} {
+		for _, def := range defs {
+			// Containers are essential unless marked otherwise
+			if (def.Essential == nil || *def.Essential) == essential {
+				ordered = append(ordered, def)
+			}
+		}
+	}
+	return ordered
+}
+
 func convertEnvVars(envs []types.KeyValuePair) []corev1.EnvVar {
 	var vars []corev1.EnvVar
 	for _, env := range envs {
@@ -400,7 +617,7 @@ func cpuToQuantity(cpu *int32) resource.Quantity {
 	cpuVal := *cpu
 
 	// Cap at reasonable maximum (16 cores = 16000 millicores)
-	if cpuVal > 16000 {
+	if cpuVal > maxCPUUnits {
 		log.Printf("Warning: CPU value %d exceeds reasonable maximum (16000m), capping at 16000m", cpuVal)
 		return resource.MustParse("16000m")
 	}
@@ -419,7 +636,7 @@ func memoryToQuantity(memory *int32) resource.Quantity {
 	memVal := *memory
 
 	// Cap at reasonable maximum (256GB)
-	if memVal > 262144 {
+	if memVal > maxMemoryMiB {
 		log.Printf("Warning: Memory value %d exceeds reasonable maximum (262144 MB = 256GB), capping at 256Gi", memVal)
 		return resource.MustParse("256Gi")
 	}
@@ -429,6 +646,23 @@ func memoryToQuantity(memory *int32) resource.Quantity {
 	return *mib
 }
 
+// memoryRequirements maps the ECS hard memory limit (memory) to the memory
+// limit and the soft limit (memoryReservation) to the request. Without a hard
+// limit an ECS container may use the task's memory, so no limit is returned.
+func memoryRequirements(memory, reservation *int32) (resource.Quantity, *resource.Quantity) {
+	if reservation == nil || *reservation <= 0 {
+		limit := memoryToQuantity(memory)
+		return limit, &limit
+	}
+
+	request := memoryToQuantity(reservation)
+	if memory == nil || *memory <= 0 {
+		return request, nil
+	}
+	limit := memoryToQuantity(memory)
+	return request, &limit
+}
+
 // convertTaskDefToInfo converts an ECS task definition to TaskDefInfo
 func convertTaskDefToInfo(taskDef *types.TaskDefinition, taskDefName string) (*TaskDefInfo, error) {
 	if taskDef == nil {
@@ -450,7 +684,8 @@ func convertTaskDefToInfo(taskDef *types.TaskDefinition, taskDefName string) (*T
 		taskDefInfo.TaskRoleArn = *taskDef.TaskRoleArn
 	}
 
-	for _, container := range taskDef.ContainerDefinitions {
+	containerDefinitions := orderContainerDefinitions(taskDef.ContainerDefinitions)
+	for _, container := range containerDefinitions {
 		if container.Name == nil || *container.Name == "" {
 			continue
 		}
@@ -467,11 +702,15 @@ func convertTaskDefToInfo(taskDef *types.TaskDefinition, taskDefName string) (*T
 			cpu = cpuQty.String()
 		}
 
-		memory := ""
+		memory, requestMemory := "", ""
 		if container.Memory != nil && *container.Memory > 0 {
 			memQty := memoryToQuantity(container.Memory)
 			memory = memQty.String()
 		}
+		if container.MemoryReservation != nil && *container.MemoryReservation > 0 {
+			reservationQty := memoryToQuantity(container.MemoryReservation)
+			requestMemory = reservationQty.String()
+		}
 
 		// Extract ports
 		var ports []int32
@@ -490,20 +729,52 @@ func convertTaskDefToInfo(taskDef *types.TaskDefinition, taskDefName string) (*T
 		}
 
 		containerConfig := ContainerConfig{
-			Name:    *container.Name,
-			Image:   image,
-			CPU:     cpu,
-			Memory:  memory,
-			Ports:   ports,
-			EnvVars: envVars,
+			Name:            sanitizeName(*container.Name),
+			Image:           image,
+			CPU:             cpu,
+			Memory:          memory,
+			RequestMemory:   requestMemory,
+			Ports:           ports,
+			EnvVars:         envVars,
+			ImagePullPolicy: string(defaultImagePullPolicy),
+			Stdin:           aws.ToBool(container.Interactive),
+			TTY:             aws.ToBool(container.PseudoTerminal),
+			SecurityContext: containerSecurityContext(container),
 		}
 
 		taskDefInfo.Containers = append(taskDefInfo.Containers, containerConfig)
 	}
 
-	if len(taskDef.ContainerDefinitions) > 0 && taskDef.ContainerDefinitions[0].Image != nil {
-		taskDefInfo.Image = *taskDef.ContainerDefinitions[0].Image
+	if len(containerDefinitions) > 0 && containerDefinitions[0].Image != nil {
+		taskDefInfo.Image = *containerDefinitions[0].Image
+	}
+
+	return taskDefInfo, nil
+}
+
+// buildTaskDefInfo converts an ECS task definition into a TaskDefInfo with its
+// Kubernetes manifests attached
+func buildTaskDefInfo(taskDef *types.TaskDefinition, taskDefName string) (*TaskDefInfo, error) {
+	taskDefInfo, err := convertTaskDefToInfo(taskDef, taskDefName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert task definition %s to info: %w", taskDefName, err)
+	}
+
+	manifests, err := convertTaskDefToK8s(taskDef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert task definition %s: %w", taskDefName, err)
+	}
+
+	// Kubernetes names must be RFC 1123 labels, ECS families may not be
+	taskDefInfo.Name = sanitizeName(taskDefName)
+	taskDefInfo.SourceName = taskDefName
+	if taskDefInfo.Name != taskDefName {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Task definition %s is named %s in Kubernetes", taskDefName, taskDefInfo.Name))
 	}
 
+	taskDefInfo.Manifests = manifests
+	taskDefInfo.Source = taskDef
+	taskDefInfo.Unmapped = unmappedFields(taskDef)
+	applyLinks(taskDefInfo)
 	return taskDefInfo, nil
 }
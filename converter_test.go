@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestInteractiveContainer tests mapping the interactive, pseudoTerminal and
+// readonlyRootFilesystem flags of a console container
+func TestInteractiveContainer(t *testing.T) {
+	taskDefInfo, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("console"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:                   aws.String("console"),
+			Image:                  aws.String("rails:7"),
+			Interactive:            aws.Bool(true),
+			PseudoTerminal:         aws.Bool(true),
+			ReadonlyRootFilesystem: aws.Bool(true),
+		}},
+	}, "console")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	c := taskDefInfo.Manifests.Deployment.Containers[0]
+	if !c.Stdin || !c.TTY {
+		t.Errorf("stdin = %v, tty = %v, want both", c.Stdin, c.TTY)
+	}
+	if c.SecurityContext == nil || !aws.ToBool(c.SecurityContext.ReadOnlyRootFilesystem) {
+		t.Errorf("securityContext = %+v, want a read-only root filesystem", c.SecurityContext)
+	}
+	if config := taskDefInfo.Containers[0]; !config.Stdin || !config.TTY || config.SecurityContext == nil {
+		t.Errorf("container config = %+v, want stdin, tty and the securityContext", config)
+	}
+
+	files, err := renderManifests("console", taskDefInfo.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	doc := files["console-deployment.yaml"].(map[string]interface{})
+	containers := doc["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]map[string]interface{})
+	if containers[0]["stdin"] != true || containers[0]["tty"] != true {
+		t.Errorf("container = %v, want stdin and tty", containers[0])
+	}
+
+	values := helmContainerValues(taskDefInfo.Containers[0])
+	if values["stdin"] != true || values["tty"] != true {
+		t.Errorf("helm values = %v, want stdin and tty", values)
+	}
+}
+
+// TestContainerOrder tests that essential containers come first, in task
+// definition order, in the pod spec, the rendered manifest and the Helm values
+func TestContainerOrder(t *testing.T) {
+	taskDefInfo, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("log-router"), Image: aws.String("fluent-bit:2"), Essential: aws.Bool(false)},
+			{Name: aws.String("app"), Image: aws.String("app:1")},
+			{Name: aws.String("xray"), Image: aws.String("xray:3"), Essential: aws.Bool(false)},
+			{Name: aws.String("proxy"), Image: aws.String("envoy:1"), Essential: aws.Bool(true), Environment: []types.KeyValuePair{
+				{Name: aws.String("ZONE"), Value: aws.String("a")},
+				{Name: aws.String("ADMIN_PORT"), Value: aws.String("9901")},
+				{Name: aws.String("MODE"), Value: aws.String("edge")},
+			}},
+		},
+	}, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	want := []string{"app", "proxy", "log-router", "xray"}
+	var podNames, configNames []string
+	for _, c := range taskDefInfo.Manifests.Deployment.Containers {
+		podNames = append(podNames, c.Name)
+	}
+	for _, c := range taskDefInfo.Containers {
+		configNames = append(configNames, c.Name)
+	}
+	if strings.Join(podNames, ",") != strings.Join(want, ",") || strings.Join(configNames, ",") != strings.Join(want, ",") {
+		t.Errorf("containers = %v (pod) and %v (config), want %v", podNames, configNames, want)
+	}
+	if taskDefInfo.Image != "app:1" {
+		t.Errorf("image = %q, want the first essential container's", taskDefInfo.Image)
+	}
+
+	for i := 0; i < 5; i++ {
+		env := helmContainerValues(taskDefInfo.Containers[1])["env"].([]map[string]string)
+		if env[0]["name"] != "ADMIN_PORT" || env[1]["name"] != "MODE" || env[2]["name"] != "ZONE" {
+			t.Fatalf("helm env = %v, want it sorted by name", env)
+		}
+	}
+}
+
+// largeTaskDefinition returns a synthetic task definition with many
+// containers, each with a long environment and some secrets
+func largeTaskDefinition(containers, envVars int) *types.TaskDefinition {
+	taskDef := &types.TaskDefinition{
+		Family:   aws.String("large"),
+		Revision: 1,
+		Cpu:      aws.String("16384"),
+		Memory:   aws.String("65536"),
+	}
+	for i := 0; i < containers; i++ {
+		def := types.ContainerDefinition{
+			Name:         aws.String(fmt.Sprintf("sidecar-%03d", i)),
+			Image:        aws.String(fmt.Sprintf("123456789012.dkr.ecr.us-east-1.amazonaws.com/sidecar-%03d:1.0.%d", i, i)),
+			Cpu:          128,
+			Memory:       aws.Int32(256),
+			Essential:    aws.Bool(i == 0),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(int32(8000 + i))}},
+		}
+		for j := 0; j < envVars; j++ {
+			def.Environment = append(def.Environment, types.KeyValuePair{
+				Name:  aws.String(fmt.Sprintf("SETTING_%03d_%03d", i, j)),
+				Value: aws.String(strings.Repeat("v", 64)),
+			})
+		}
+		def.Secrets = append(def.Secrets, types.Secret{
+			Name:      aws.String("DATABASE_PASSWORD"),
+			ValueFrom: aws.String(fmt.Sprintf("arn:aws:ssm:us-east-1:123456789012:parameter/large/%03d", i)),
+		})
+		taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, def)
+	}
+	return taskDef
+}
+
+// BenchmarkConvertLargeTaskDefinition converts and writes a task definition
+// with 100 containers of 200 environment variables each. Profile it with
+// go test -bench ConvertLarge -benchmem -memprofile mem.out.
+func BenchmarkConvertLargeTaskDefinition(b *testing.B) {
+	taskDef := largeTaskDefinition(100, 200)
+	dir := b.TempDir()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		taskDefInfo, err := buildTaskDefInfo(taskDef, "large")
+		if err != nil {
+			b.Fatalf("buildTaskDefInfo() error = %v", err)
+		}
+		if err := writeManifests(dir, taskDefInfo.Name, taskDefInfo.Manifests); err != nil {
+			b.Fatalf("writeManifests() error = %v", err)
+		}
+	}
+}
+
+// TestLargeTaskDefinitionMemory tests converting and writing a task
+// definition with 100 containers within a memory budget. Marshaling its
+// Deployment as one document allocates about 290MB.
+func TestLargeTaskDefinitionMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("converts a 100-container task definition")
+	}
+	const budget = 240 << 20
+
+	taskDef := largeTaskDefinition(100, 200)
+	dir := t.TempDir()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	taskDefInfo, err := buildTaskDefInfo(taskDef, "large")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	if err := writeManifests(dir, taskDefInfo.Name, taskDefInfo.Manifests); err != nil {
+		t.Fatalf("writeManifests() error = %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > budget {
+		t.Errorf("conversion allocated %dMB, want at most %dMB", allocated>>20, budget>>20)
+	}
+
+	files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	want, err := yaml.Marshal(files["large-deployment.yaml"])
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "large-deployment.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read the Deployment: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("written Deployment differs from yaml.Marshal (%d and %d bytes)", len(got), len(want))
+	}
+}
+
+// TestApplyNamespaceModes tests mapping the ipcMode and pidMode of a task
+// definition onto the namespace fields of the pod spec
+func TestApplyNamespaceModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipcMode   types.IpcMode
+		pidMode   types.PidMode
+		hostIPC   bool
+		hostPID   bool
+		sharePIDs bool
+	}{
+		{"unset", "", "", false, false, false},
+		{"ipc host", types.IpcModeHost, "", true, false, false},
+		{"ipc task", types.IpcModeTask, "", false, false, false},
+		{"ipc none", types.IpcModeNone, "", false, false, false},
+		{"pid host", "", types.PidModeHost, false, true, false},
+		{"pid task", "", types.PidModeTask, false, false, true},
+		{"both host", types.IpcModeHost, types.PidModeHost, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSpec := &corev1.PodSpec{}
+			applyNamespaceModes(podSpec, tt.ipcMode, tt.pidMode)
+			if podSpec.HostIPC != tt.hostIPC {
+				t.Errorf("hostIPC = %v, want %v", podSpec.HostIPC, tt.hostIPC)
+			}
+			if podSpec.HostPID != tt.hostPID {
+				t.Errorf("hostPID = %v, want %v", podSpec.HostPID, tt.hostPID)
+			}
+			sharePIDs := podSpec.ShareProcessNamespace != nil && *podSpec.ShareProcessNamespace
+			if sharePIDs != tt.sharePIDs {
+				t.Errorf("shareProcessNamespace = %v, want %v", podSpec.ShareProcessNamespace, tt.sharePIDs)
+			}
+		})
+	}
+}
+
+// TestIsValidImagePullPolicy tests the accepted --image-pull-policy values
+func TestIsValidImagePullPolicy(t *testing.T) {
+	for policy, want := range map[string]bool{
+		"Always":       true,
+		"IfNotPresent": true,
+		"Never":        true,
+		"always":       false,
+		"":             false,
+		"Sometimes":    false,
+	} {
+		if got := isValidImagePullPolicy(policy); got != want {
+			t.Errorf("isValidImagePullPolicy(%q) = %v, want %v", policy, got, want)
+		}
+	}
+}
+
+// TestSetImagePullPolicy tests that the policy reaches every container of the
+// pod, the raw manifests and the Helm values
+func TestSetImagePullPolicy(t *testing.T) {
+	taskDefInfo, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("app"), Image: aws.String("app:1")},
+			{Name: aws.String("proxy"), Image: aws.String("envoy:1")},
+		},
+	}, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	podSpec := taskDefInfo.Manifests.Deployment
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{Name: "init", Image: "busybox"})
+
+	setImagePullPolicy(taskDefInfo, "Always")
+
+	for _, c := range append(append([]corev1.Container{}, podSpec.Containers...), podSpec.InitContainers...) {
+		if c.ImagePullPolicy != corev1.PullAlways {
+			t.Errorf("container %s imagePullPolicy = %q, want Always", c.Name, c.ImagePullPolicy)
+		}
+	}
+	for _, c := range taskDefInfo.Containers {
+		if got := helmContainerValues(c)["imagePullPolicy"]; got != "Always" {
+			t.Errorf("helm values of %s imagePullPolicy = %v, want Always", c.Name, got)
+		}
+	}
+
+	docs, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	data, err := yaml.Marshal(docs["web-deployment.yaml"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "imagePullPolicy: Always"); got != 3 {
+		t.Errorf("deployment has %d imagePullPolicy: Always, want 3:\n%s", got, data)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// mappedTaskDefFields are task definition fields the converter carries into the output
+var mappedTaskDefFields = map[string]bool{
+	"ContainerDefinitions": true,
+	"Family":               true,
+	"TaskRoleArn":          true,
+	"ExecutionRoleArn":     true,
+	"IpcMode":              true,
+	"PidMode":              true,
+}
+
+// ignoredTaskDefFields are registration metadata with no runtime meaning
+var ignoredTaskDefFields = map[string]bool{
+	"Compatibilities":         true,
+	"DeregisteredAt":          true,
+	"RegisteredAt":            true,
+	"RegisteredBy":            true,
+	"RequiresAttributes":      true,
+	"RequiresCompatibilities": true,
+	"Revision":                true,
+	"Status":                  true,
+	"TaskDefinitionArn":       true,
+}
+
+// mappedContainerFields are container definition fields the converter carries into the output
+var mappedContainerFields = map[string]bool{
+	"Name":              true,
+	"Image":             true,
+	"Cpu":               true,
+	"Memory":            true,
+	"MemoryReservation": true,
+	"PortMappings":      true,
+	"Environment":       true,
+	"HealthCheck":       true,
+	// stdin, tty and securityContext.readOnlyRootFilesystem
+	"Interactive":            true,
+	"PseudoTerminal":         true,
+	"ReadonlyRootFilesystem": true,
+}
+
+// ignoredContainerFields are container settings specific to how ECS resolves images
+var ignoredContainerFields = map[string]bool{
+	"VersionConsistency": true,
+}
+
+// coveredTaskDefValues lists fields that are only covered for some values
+var coveredTaskDefValues = map[string]func(taskDef *types.TaskDefinition) bool{
+	// Pods get their own network namespace, like awsvpc tasks
+	"NetworkMode": func(taskDef *types.TaskDefinition) bool {
+		return taskDef.NetworkMode == types.NetworkModeAwsvpc
+	},
+}
+
+// coveredContainerValues lists container fields that are only covered for some values
+var coveredContainerValues = map[string]func(c *types.ContainerDefinition) bool{
+	// Every container of a pod is effectively essential
+	"Essential": func(c *types.ContainerDefinition) bool {
+		return c.Essential == nil || *c.Essential
+	},
+}
+
+// unmappedFields lists the task definition and container fields set in the
+// source that are not represented in the generated manifests
+func unmappedFields(taskDef *types.TaskDefinition) []string {
+	if taskDef == nil {
+		return nil
+	}
+
+	var fields []string
+	for _, name := range setFields(reflect.ValueOf(*taskDef)) {
+		if mappedTaskDefFields[name] || ignoredTaskDefFields[name] {
+			continue
+		}
+		if covered, ok := coveredTaskDefValues[name]; ok && covered(taskDef) {
+			continue
+		}
+		fields = append(fields, fieldJSONName(name))
+	}
+
+	for i := range taskDef.ContainerDefinitions {
+		c := &taskDef.ContainerDefinitions[i]
+		containerName := aws.ToString(c.Name)
+		if containerName == "" {
+			containerName = fmt.Sprintf("%d", i)
+		}
+
+		for _, name := range setFields(reflect.ValueOf(*c)) {
+			if mappedContainerFields[name] || ignoredContainerFields[name] {
+				continue
+			}
+			if covered, ok := coveredContainerValues[name]; ok && covered(c) {
+				continue
+			}
+			fields = append(fields, fmt.Sprintf("containerDefinitions[%s].%s", containerName, fieldJSONName(name)))
+		}
+	}
+
+	return fields
+}
+
+// setFields returns the sorted names of the exported, non-empty fields of a struct
+func setFields(v reflect.Value) []string {
+	var names []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		value := v.Field(i)
+		switch value.Kind() {
+		case reflect.Slice, reflect.Map:
+			if value.Len() == 0 {
+				continue
+			}
+		default:
+			if value.IsZero() {
+				continue
+			}
+		}
+		names = append(names, field.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fieldJSONName converts an SDK field name to its ECS JSON name (HealthCheck -> healthCheck)
+func fieldJSONName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestUnmappedFields tests that only set, unconverted fields are reported
+func TestUnmappedFields(t *testing.T) {
+	registeredAt := time.Now()
+	taskDef := &types.TaskDefinition{
+		Family:       aws.String("web"),
+		NetworkMode:  types.NetworkModeBridge,
+		Revision:     3,
+		RegisteredAt: &registeredAt,
+		Volumes:      []types.Volume{{Name: aws.String("data")}},
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:        aws.String("app"),
+				Image:       aws.String("nginx:1.25"),
+				Essential:   aws.Bool(true),
+				Environment: []types.KeyValuePair{{Name: aws.String("A"), Value: aws.String("1")}},
+				HealthCheck: &types.HealthCheck{Command: []string{"CMD", "true"}},
+				DnsServers:  []string{},
+				Links:       []string{"db"},
+			},
+			{
+				Name:      aws.String("sidecar"),
+				Image:     aws.String("envoy"),
+				Essential: aws.Bool(false),
+			},
+		},
+	}
+
+	want := []string{
+		"networkMode",
+		"volumes",
+		"containerDefinitions[app].links",
+		"containerDefinitions[sidecar].essential",
+	}
+	if got := unmappedFields(taskDef); !reflect.DeepEqual(got, want) {
+		t.Errorf("unmappedFields() = %v, want %v", got, want)
+	}
+
+	taskDef.NetworkMode = types.NetworkModeAwsvpc
+	taskDef.Volumes = nil
+	taskDef.ContainerDefinitions = taskDef.ContainerDefinitions[:1]
+	taskDef.ContainerDefinitions[0].Links = nil
+	if got := unmappedFields(taskDef); len(got) != 0 {
+		t.Errorf("unmappedFields() = %v, want none", got)
+	}
+}
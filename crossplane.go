@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Crossplane export modes for --crossplane
+const (
+	crossplaneObjects     = "objects"
+	crossplaneComposition = "composition"
+)
+
+// crossplaneModes lists the accepted --crossplane values
+var crossplaneModes = []string{crossplaneObjects, crossplaneComposition}
+
+// crossplaneGroup is the API group of the generated composite resource
+const crossplaneGroup = "ecs2k8s.io"
+
+// isValidCrossplaneMode checks the --crossplane value; empty disables the export
+func isValidCrossplaneMode(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range crossplaneModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateCrossplanePackage writes the converted services as provider-kubernetes
+// Objects, or as a CompositeResourceDefinition with one Composition and claim
+// per service, into <output>/crossplane
+func CreateCrossplanePackage(clusterName string, taskDefInfos []*TaskDefInfo, outputDir, mode, providerConfig string) error {
+	model, err := newConversionModel(clusterName, taskDefInfos)
+	if err != nil {
+		return err
+	}
+	return createCrossplanePackage(model, outputDir, mode, providerConfig)
+}
+
+// createCrossplanePackage writes the Crossplane package of the conversion model
+func createCrossplanePackage(model *ConversionModel, outputDir, mode, providerConfig string) error {
+	clusterName := model.Cluster
+	crossplaneDir := filepath.Join(outputDir, "crossplane")
+
+	files := map[string]interface{}{}
+	for _, workload := range model.Workloads {
+		taskDefInfo, docs := workload.Info, workload.Docs
+
+		switch mode {
+		case crossplaneObjects:
+			for _, filename := range sortedDocKeys(docs) {
+				name := strings.TrimSuffix(filename, ".yaml")
+				files[filepath.Join("objects", filename)] = crossplaneObject(clusterName+"-"+name, providerConfig, docs[filename])
+			}
+		case crossplaneComposition:
+			// Compositions are cluster-scoped, so they carry the cluster name
+			compositionName := sanitizeName(clusterName + "-" + taskDefInfo.Name)
+			files[filepath.Join("compositions", taskDefInfo.Name+".yaml")] = buildCrossplaneComposition(compositionName, workload, providerConfig)
+			files[filepath.Join("claims", taskDefInfo.Name+".yaml")] = buildCrossplaneClaim(compositionName, taskDefInfo)
+		}
+	}
+	if mode == crossplaneComposition {
+		files["definition.yaml"] = crossplaneDefinition()
+	}
+
+	for _, filename := range sortedDocKeys(files) {
+		path := filepath.Join(crossplaneDir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create crossplane directory %s: %w", filepath.Dir(path), err)
+		}
+		data, err := yaml.Marshal(files[filename])
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+		log.Printf("Wrote: %s", path)
+	}
+
+	log.Printf("✓ Created Crossplane %s at: %s", mode, crossplaneDir)
+	return nil
+}
+
+// crossplaneObject wraps a manifest in a provider-kubernetes Object
+func crossplaneObject(name, providerConfig string, manifest interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": apiVersionCrossplaneK8s,
+		"kind":       "Object",
+		"metadata": map[string]interface{}{
+			"name": sanitizeName(name),
+		},
+		"spec": crossplaneObjectSpec(providerConfig, manifest),
+	}
+}
+
+// crossplaneObjectSpec is the spec of a provider-kubernetes Object
+func crossplaneObjectSpec(providerConfig string, manifest interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"forProvider": map[string]interface{}{
+			"manifest": manifest,
+		},
+		"providerConfigRef": map[string]interface{}{
+			"name": providerConfig,
+		},
+	}
+}
+
+// crossplaneDefinition is the XRD of the composite resource every service
+// Composition implements; claims choose the service by compositionRef
+func crossplaneDefinition() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": apiVersionCrossplaneAPIExt,
+		"kind":       "CompositeResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": "xecsworkloads." + crossplaneGroup,
+		},
+		"spec": map[string]interface{}{
+			"group": crossplaneGroup,
+			"names": map[string]interface{}{
+				"kind":   "XECSWorkload",
+				"plural": "xecsworkloads",
+			},
+			"claimNames": map[string]interface{}{
+				"kind":   "ECSWorkload",
+				"plural": "ecsworkloads",
+			},
+			"versions": []map[string]interface{}{{
+				"name":          "v1alpha1",
+				"served":        true,
+				"referenceable": true,
+				"schema": map[string]interface{}{
+					"openAPIV3Schema": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"spec": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"namespace": map[string]interface{}{
+										"type":        "string",
+										"description": "Namespace the service is deployed to",
+									},
+									"replicas": map[string]interface{}{
+										"type":        "integer",
+										"description": "Replicas of the Deployment",
+									},
+									"images": map[string]interface{}{
+										"type":                 "object",
+										"description":          "Image per container name",
+										"additionalProperties": map[string]interface{}{"type": "string"},
+									},
+								},
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// buildCrossplaneComposition composes the manifests of one service as Objects,
+// patching namespace, replicas and images from the composite resource
+func buildCrossplaneComposition(name string, workload *Workload, providerConfig string) map[string]interface{} {
+	taskDefInfo, docs := workload.Info, workload.Docs
+	var resources []map[string]interface{}
+	for _, filename := range sortedDocKeys(docs) {
+		doc, _ := docs[filename].(map[string]interface{})
+		var patches []map[string]interface{}
+		switch doc["kind"] {
+		case "ClusterRole", "ClusterRoleBinding":
+		default:
+			patches = append(patches, crossplanePatch("spec.namespace", "spec.forProvider.manifest.metadata.namespace"))
+		}
+		if doc["kind"] == workloadKind(taskDefInfo.Manifests) {
+			patches = append(patches, crossplanePatch("spec.replicas", "spec.forProvider.manifest.spec.replicas"))
+			for i, container := range taskDefInfo.Manifests.Deployment.Containers {
+				patches = append(patches, crossplanePatch(
+					fmt.Sprintf("spec.images[%s]", container.Name),
+					fmt.Sprintf("spec.forProvider.manifest.spec.template.spec.containers[%d].image", i),
+				))
+			}
+		}
+
+		resources = append(resources, map[string]interface{}{
+			"name": strings.TrimSuffix(filename, ".yaml"),
+			"base": map[string]interface{}{
+				"apiVersion": apiVersionCrossplaneK8s,
+				"kind":       "Object",
+				"spec":       crossplaneObjectSpec(providerConfig, docs[filename]),
+			},
+			"patches": patches,
+		})
+	}
+
+	return map[string]interface{}{
+		"apiVersion": apiVersionCrossplaneAPIExt,
+		"kind":       "Composition",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]string{"app": taskDefInfo.Name},
+		},
+		"spec": map[string]interface{}{
+			"compositeTypeRef": map[string]interface{}{
+				"apiVersion": crossplaneGroup + "/v1alpha1",
+				"kind":       "XECSWorkload",
+			},
+			"mode": "Pipeline",
+			"pipeline": []map[string]interface{}{{
+				"step":        "patch-and-transform",
+				"functionRef": map[string]interface{}{"name": "function-patch-and-transform"},
+				"input": map[string]interface{}{
+					"apiVersion": apiVersionCrossplanePT,
+					"kind":       "Resources",
+					"resources":  resources,
+				},
+			}},
+		},
+	}
+}
+
+// crossplanePatch copies a composite field into a composed Object
+func crossplanePatch(from, to string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":          "FromCompositeFieldPath",
+		"fromFieldPath": from,
+		"toFieldPath":   to,
+	}
+}
+
+// buildCrossplaneClaim claims a service with the values of the conversion
+func buildCrossplaneClaim(compositionName string, taskDefInfo *TaskDefInfo) map[string]interface{} {
+	images := map[string]string{}
+	if taskDefInfo.Manifests.Deployment != nil {
+		for _, container := range taskDefInfo.Manifests.Deployment.Containers {
+			images[container.Name] = container.Image
+		}
+	}
+
+	return map[string]interface{}{
+		"apiVersion": crossplaneGroup + "/v1alpha1",
+		"kind":       "ECSWorkload",
+		"metadata": map[string]interface{}{
+			"name":      taskDefInfo.Name,
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"compositionRef": map[string]interface{}{"name": compositionName},
+			"namespace":      "default",
+			"replicas":       1,
+			"images":         images,
+		},
+	}
+}
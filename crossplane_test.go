@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestCreateCrossplanePackage tests both Crossplane export modes
+func TestCreateCrossplanePackage(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("app"), Image: aws.String("nginx:1.27"), PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}}},
+			{Name: aws.String("sidecar"), Image: aws.String("envoy:1.30")},
+		},
+	}
+	taskDefInfo, err := buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := CreateCrossplanePackage("Prod", []*TaskDefInfo{taskDefInfo}, dir, crossplaneObjects, "in-cluster"); err != nil {
+		t.Fatalf("CreateCrossplanePackage(objects) error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "crossplane", "objects", "web-deployment.yaml"))
+	if err != nil {
+		t.Fatalf("Object for the Deployment not written: %v", err)
+	}
+	for _, want := range []string{"kind: Object", "name: prod-web-deployment", "name: in-cluster", "kind: Deployment"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Object missing %q:\n%s", want, data)
+		}
+	}
+
+	if err := CreateCrossplanePackage("Prod", []*TaskDefInfo{taskDefInfo}, dir, crossplaneComposition, "default"); err != nil {
+		t.Fatalf("CreateCrossplanePackage(composition) error = %v", err)
+	}
+	for _, file := range []string{"definition.yaml", "claims/web.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, "crossplane", file)); err != nil {
+			t.Errorf("%s not written: %v", file, err)
+		}
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "crossplane", "compositions", "web.yaml"))
+	if err != nil {
+		t.Fatalf("Composition not written: %v", err)
+	}
+	for _, want := range []string{
+		"name: prod-web",
+		"fromFieldPath: spec.images[sidecar]",
+		"toFieldPath: spec.forProvider.manifest.spec.template.spec.containers[1].image",
+		"fromFieldPath: spec.replicas",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Composition missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// cutoverDirName is the output subdirectory for weighted cutover scripts
+const cutoverDirName = "cutover"
+
+// cutoverTargetGroupPlaceholder stands for the Kubernetes target group ARN in
+// generated actions until the script substitutes the shell variable
+const cutoverTargetGroupPlaceholder = "__K8S_TARGET_GROUP_ARN__"
+
+// maxTargetGroupNameLength is the ELB limit for target group names
+const maxTargetGroupNameLength = 32
+
+// isValidCutoverWeight checks the percentage of traffic shifted to Kubernetes
+func isValidCutoverWeight(weight int) bool {
+	return weight >= 0 && weight <= 100
+}
+
+// cutoverDisabled is the cutover weight when no cutover is requested
+const cutoverDisabled = -1
+
+// buildTargetGroupBinding creates an AWS Load Balancer Controller
+// TargetGroupBinding registering a Service's pods in a target group. target
+// holds "targetGroupARN" or "targetGroupName", and may override "targetType".
+func buildTargetGroupBinding(name, taskDefName, serviceName string, port int32, target map[string]string) map[string]interface{} {
+	spec := map[string]interface{}{
+		"serviceRef": map[string]interface{}{
+			"name": serviceName,
+			"port": port,
+		},
+		"targetType": "ip",
+	}
+	for key, value := range target {
+		spec[key] = value
+	}
+
+	return map[string]interface{}{
+		"apiVersion": apiVersionTargetGroup,
+		"kind":       "TargetGroupBinding",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": map[string]string{"app": taskDefName},
+		},
+		"spec": spec,
+	}
+}
+
+// cutoverTargetGroupName derives the name of the Kubernetes target group from
+// the ECS one, within the ELB name length limit
+func cutoverTargetGroupName(ecsName string) string {
+	const suffix = "-k8s"
+	if len(ecsName)+len(suffix) > maxTargetGroupNameLength {
+		ecsName = strings.TrimRight(ecsName[:maxTargetGroupNameLength-len(suffix)], "-")
+	}
+	return ecsName + suffix
+}
+
+// applyCutover prepares a gradual traffic shift from ECS to Kubernetes: for
+// each target group of the service a TargetGroupBinding registers the pods in
+// a new "<name>-k8s" target group, and a script makes the ALB listener rules
+// forward to both target groups with the requested weight
+func applyCutover(taskDefInfo *TaskDefInfo, attachments []targetGroupAttachment, targetGroups map[string]*targetGroupInfo, weight int) {
+	var script strings.Builder
+	fmt.Fprintf(&script, "#!/bin/sh\n")
+	fmt.Fprintf(&script, "# Weighted cutover of %s from ECS to Kubernetes: %d%% of traffic to Kubernetes.\n", taskDefInfo.Name, weight)
+	fmt.Fprintf(&script, "# Apply the %s TargetGroupBindings after running this script the first time,\n", taskDefInfo.Name)
+	fmt.Fprintf(&script, "# then re-generate with a higher --cutover-weight to shift more traffic.\n")
+	fmt.Fprintf(&script, "# Rewritten rules forward to the two target groups only; other action settings are replaced.\n")
+	fmt.Fprintf(&script, "set -eu\n")
+
+	done := map[string]bool{}
+	for _, a := range attachments {
+		if done[a.TargetGroupArn] {
+			continue
+		}
+		done[a.TargetGroupArn] = true
+
+		tg, ok := targetGroups[a.TargetGroupArn]
+		if !ok || tg.Source == nil {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Target group %s could not be described; no cutover generated for it", targetGroupName(a.TargetGroupArn)))
+			continue
+		}
+		if len(tg.Rules) == 0 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("No listener rules forward to target group %s; no cutover generated for it", tg.Name))
+			continue
+		}
+
+		k8sName := cutoverTargetGroupName(tg.Name)
+		binding := buildTargetGroupBinding(sanitizeName(a.ContainerName+"-cutover"), taskDefInfo.Name, a.ContainerName, a.ContainerPort, map[string]string{"targetGroupName": k8sName})
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: a.ContainerName + "-cutover-targetgroupbinding", Object: binding})
+
+		writeCutoverTargetGroup(&script, tg, k8sName)
+		actions := weightedForwardActions(tg.Arn, weight)
+		for _, rule := range tg.Rules {
+			switch {
+			case len(rule.TargetGroupArns) > 1:
+				fmt.Fprintf(&script, "# Rule %s already forwards to several target groups; add $K8S_TG to it manually\n", rule.Arn)
+			case rule.IsDefault:
+				fmt.Fprintf(&script, "aws elbv2 modify-listener --listener-arn %s --default-actions %s >/dev/null\n", rule.ListenerArn, actions)
+			default:
+				fmt.Fprintf(&script, "aws elbv2 modify-rule --rule-arn %s --actions %s >/dev/null\n", rule.Arn, actions)
+			}
+		}
+	}
+
+	if len(done) == 0 || !strings.Contains(script.String(), "K8S_TG=") {
+		return
+	}
+
+	taskDefInfo.CutoverScript = script.String()
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Generated %s/%s.sh shifting %d%% of the ALB traffic to Kubernetes through TargetGroupBindings", cutoverDirName, taskDefInfo.Name, weight))
+	log.Printf("✓ Created weighted cutover (%d%% to Kubernetes) for %s", weight, taskDefInfo.Name)
+}
+
+// writeCutoverTargetGroup writes the commands looking up or creating the
+// Kubernetes target group, copying protocol, port and health check of the ECS one
+func writeCutoverTargetGroup(script *strings.Builder, tg *targetGroupInfo, k8sName string) {
+	src := tg.Source
+	args := []string{
+		"--name " + k8sName,
+		"--target-type ip",
+		"--protocol " + string(src.Protocol),
+		fmt.Sprintf("--port %d", aws.ToInt32(src.Port)),
+		"--vpc-id " + aws.ToString(src.VpcId),
+	}
+	if src.ProtocolVersion != nil {
+		args = append(args, "--protocol-version "+*src.ProtocolVersion)
+	}
+	if src.HealthCheckProtocol != "" {
+		args = append(args, "--health-check-protocol "+string(src.HealthCheckProtocol))
+	}
+	if path := aws.ToString(src.HealthCheckPath); path != "" {
+		args = append(args, "--health-check-path "+shellQuote(path))
+	}
+	if src.Matcher != nil && src.Matcher.HttpCode != nil {
+		args = append(args, "--matcher HttpCode="+shellQuote(*src.Matcher.HttpCode))
+	}
+
+	fmt.Fprintf(script, "\n# %s -> %s\n", tg.Name, k8sName)
+	fmt.Fprintf(script, "K8S_TG=$(aws elbv2 describe-target-groups --names %s --query 'TargetGroups[0].TargetGroupArn' --output text 2>/dev/null || \\\n", k8sName)
+	fmt.Fprintf(script, "  aws elbv2 create-target-group %s --query 'TargetGroups[0].TargetGroupArn' --output text)\n", strings.Join(args, " "))
+}
+
+// weightedForwardActions returns the shell-quoted listener actions forwarding
+// to the ECS target group and $K8S_TG with the given Kubernetes weight
+func weightedForwardActions(ecsTargetGroupArn string, weight int) string {
+	actions := []map[string]interface{}{{
+		"Type": "forward",
+		"ForwardConfig": map[string]interface{}{
+			"TargetGroups": []map[string]interface{}{
+				{"TargetGroupArn": ecsTargetGroupArn, "Weight": 100 - weight},
+				{"TargetGroupArn": cutoverTargetGroupPlaceholder, "Weight": weight},
+			},
+		},
+	}}
+	data, _ := json.Marshal(actions)
+
+	// Close the single quotes around the variable so the shell expands it
+	return "'" + strings.Replace(string(data), cutoverTargetGroupPlaceholder, `'"$K8S_TG"'`, 1) + "'"
+}
+
+// shellQuote single-quotes a value for the shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// writeCutoverScript writes a task definition's cutover script into <output>/cutover
+func writeCutoverScript(outputDir, taskDefName, script string) error {
+	dir := filepath.Join(outputDir, cutoverDirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cutover directory %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, taskDefName+".sh")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return fmt.Errorf("failed to write cutover script %s: %w", path, err)
+	}
+	log.Printf("Wrote: %s", path)
+	return nil
+}
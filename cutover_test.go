@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// TestCutoverTargetGroupName tests the ELB name length limit
+func TestCutoverTargetGroupName(t *testing.T) {
+	if got := cutoverTargetGroupName("web-tg"); got != "web-tg-k8s" {
+		t.Errorf("cutoverTargetGroupName(web-tg) = %q", got)
+	}
+	got := cutoverTargetGroupName("a-very-long-target-group-name-xyz")
+	if len(got) > maxTargetGroupNameLength || !strings.HasSuffix(got, "-k8s") {
+		t.Errorf("cutoverTargetGroupName(long) = %q (%d chars)", got, len(got))
+	}
+}
+
+// TestWeightedForwardActions tests splitting the forward action between the target groups
+func TestWeightedForwardActions(t *testing.T) {
+	got := weightedForwardActions("arn:ecs-tg", 20)
+	want := `'[{"ForwardConfig":{"TargetGroups":[{"TargetGroupArn":"arn:ecs-tg","Weight":80},{"TargetGroupArn":"'"$K8S_TG"'","Weight":20}]},"Type":"forward"}]'`
+	if got != want {
+		t.Errorf("weightedForwardActions() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestApplyCutover tests generating the TargetGroupBinding and the listener updates
+func TestApplyCutover(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{Name: "web"}
+	attachments := []targetGroupAttachment{{TargetGroupArn: "arn:tg/web", ContainerName: "app", ContainerPort: 8080}}
+	targetGroups := map[string]*targetGroupInfo{
+		"arn:tg/web": {
+			Arn:  "arn:tg/web",
+			Name: "web",
+			Source: &elbv2types.TargetGroup{
+				Protocol:        elbv2types.ProtocolEnumHttp,
+				Port:            aws.Int32(8080),
+				VpcId:           aws.String("vpc-1"),
+				HealthCheckPath: aws.String("/health"),
+			},
+			Rules: []listenerRule{
+				{Arn: "arn:rule/1", ListenerArn: "arn:listener/1", TargetGroupArns: []string{"arn:tg/web"}},
+				{Arn: "arn:rule/default", ListenerArn: "arn:listener/1", IsDefault: true, TargetGroupArns: []string{"arn:tg/web"}},
+			},
+		},
+	}
+
+	applyCutover(taskDefInfo, attachments, targetGroups, 10)
+
+	if len(taskDefInfo.Manifests.Extras) != 1 {
+		t.Fatalf("extras = %d, want 1 TargetGroupBinding", len(taskDefInfo.Manifests.Extras))
+	}
+	spec := taskDefInfo.Manifests.Extras[0].Object["spec"].(map[string]interface{})
+	if spec["targetGroupName"] != "web-k8s" {
+		t.Errorf("targetGroupName = %v, want web-k8s", spec["targetGroupName"])
+	}
+
+	script := taskDefInfo.CutoverScript
+	for _, want := range []string{
+		"--name web-k8s --target-type ip --protocol HTTP --port 8080 --vpc-id vpc-1",
+		"--health-check-path '/health'",
+		"modify-rule --rule-arn arn:rule/1",
+		"modify-listener --listener-arn arn:listener/1 --default-actions",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+// TestApplyCutoverWithoutRules tests that no script is generated without listener rules
+func TestApplyCutoverWithoutRules(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{Name: "web"}
+	attachments := []targetGroupAttachment{{TargetGroupArn: "arn:tg/web", ContainerName: "app", ContainerPort: 8080}}
+	targetGroups := map[string]*targetGroupInfo{"arn:tg/web": {Arn: "arn:tg/web", Name: "web", Source: &elbv2types.TargetGroup{}}}
+
+	applyCutover(taskDefInfo, attachments, targetGroups, 10)
+
+	if taskDefInfo.CutoverScript != "" || len(taskDefInfo.Manifests.Extras) != 0 {
+		t.Errorf("cutover generated without listener rules")
+	}
+	if len(taskDefInfo.Notes) != 1 {
+		t.Errorf("notes = %v, want one", taskDefInfo.Notes)
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aatypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// Decommission plan files written into the output directory
+const (
+	decommissionPlanFileName     = "decommission-plan.md"
+	decommissionPlanJSONFileName = "decommission-plan.json"
+)
+
+// Kinds of source resources, in the order they are decommissioned
+const (
+	decommissionScalingPolicy  = "scaling-policy"
+	decommissionScalableTarget = "scalable-target"
+	decommissionService        = "ecs-service"
+	decommissionTargetGroup    = "target-group"
+	decommissionLoadBalancer   = "load-balancer"
+	decommissionTaskDefinition = "task-definition"
+)
+
+// decommissionSteps describes each kind of resource in the checklist
+var decommissionSteps = []struct {
+	kind, title string
+}{
+	{decommissionScalingPolicy, "Delete scaling policies"},
+	{decommissionScalableTarget, "Deregister scalable targets"},
+	{decommissionService, "Scale ECS services to 0 and delete them"},
+	{decommissionTargetGroup, "Delete target groups"},
+	{decommissionLoadBalancer, "Delete load balancers"},
+	{decommissionTaskDefinition, "Deregister task definitions"},
+}
+
+// DecommissionResource is a source AWS resource made obsolete by the migration
+type DecommissionResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	ARN  string `json:"arn"`
+	// Services are the converted services depending on the resource
+	Services []string `json:"services"`
+	// IaC is the stack or module defining the resource, detected from its tags
+	IaC  string `json:"iac,omitempty"`
+	Note string `json:"note,omitempty"`
+}
+
+// DecommissionPlan lists the source resources of a converted cluster
+type DecommissionPlan struct {
+	Cluster   string                 `json:"cluster"`
+	Region    string                 `json:"region"`
+	Resources []DecommissionResource `json:"resources"`
+}
+
+// decommissionPlanner looks up the AWS resources behind the converted services
+type decommissionPlanner struct {
+	ecs         *ecs.Client
+	elbv2       *elbv2.Client
+	autoscaling *applicationautoscaling.Client
+	cluster     string
+}
+
+// newDecommissionPlanner creates a planner for one cluster
+func newDecommissionPlanner(cfg aws.Config, cluster string) *decommissionPlanner {
+	return &decommissionPlanner{
+		ecs:         ecs.NewFromConfig(cfg),
+		elbv2:       elbv2.NewFromConfig(cfg),
+		autoscaling: applicationautoscaling.NewFromConfig(cfg),
+		cluster:     cluster,
+	}
+}
+
+// plan collects the source resources of the converted services, their load
+// balancers and scaling policies, and the IaC owning each of them
+func (p *decommissionPlanner) plan(ctx context.Context, region string, taskDefInfos []*TaskDefInfo) *DecommissionPlan {
+	resources := collectDecommissionResources(taskDefInfos)
+	resources = append(resources, p.scalingResources(ctx, taskDefInfos)...)
+	resources = append(resources, p.loadBalancerResources(ctx, resources)...)
+
+	tags := p.resourceTags(ctx, resources)
+	for i := range resources {
+		resources[i].IaC = iacOwnerFromTags(tags[resources[i].ARN])
+	}
+
+	sortDecommissionResources(resources)
+	return &DecommissionPlan{Cluster: p.cluster, Region: region, Resources: resources}
+}
+
+// collectDecommissionResources lists the services, task definitions and
+// target groups known from the conversion itself
+func collectDecommissionResources(taskDefInfos []*TaskDefInfo) []DecommissionResource {
+	var resources []DecommissionResource
+	targetGroups := map[string]*DecommissionResource{}
+	var targetGroupArns []string
+	// Containers split by --split-containers share their task definition and services
+	listed := map[string]int{}
+	add := func(r DecommissionResource) {
+		if i, ok := listed[r.ARN]; ok && r.ARN != "" {
+			for _, name := range r.Services {
+				resources[i].Services = appendUnique(resources[i].Services, name)
+			}
+			return
+		}
+		listed[r.ARN] = len(resources)
+		resources = append(resources, r)
+	}
+
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Source != nil {
+			if arn := aws.ToString(taskDefInfo.Source.TaskDefinitionArn); arn != "" {
+				add(DecommissionResource{
+					Kind:     decommissionTaskDefinition,
+					Name:     extractTaskDefName(arn) + ":" + fmt.Sprint(taskDefInfo.Source.Revision),
+					ARN:      arn,
+					Services: []string{taskDefInfo.Name},
+					Note:     "Other active revisions of the family are not listed",
+				})
+			}
+		}
+
+		for _, svc := range taskDefInfo.Services {
+			add(DecommissionResource{
+				Kind:     decommissionService,
+				Name:     aws.ToString(svc.ServiceName),
+				ARN:      aws.ToString(svc.ServiceArn),
+				Services: []string{taskDefInfo.Name},
+			})
+
+			for _, lb := range svc.LoadBalancers {
+				arn := aws.ToString(lb.TargetGroupArn)
+				if arn == "" {
+					continue
+				}
+				if tg, ok := targetGroups[arn]; ok {
+					tg.Services = appendUnique(tg.Services, taskDefInfo.Name)
+					continue
+				}
+				targetGroups[arn] = &DecommissionResource{
+					Kind:     decommissionTargetGroup,
+					Name:     targetGroupName(arn),
+					ARN:      arn,
+					Services: []string{taskDefInfo.Name},
+				}
+				targetGroupArns = append(targetGroupArns, arn)
+			}
+		}
+	}
+
+	for _, arn := range targetGroupArns {
+		resources = append(resources, *targetGroups[arn])
+	}
+	return resources
+}
+
+// scalingResources lists the scalable targets and scaling policies of the services
+func (p *decommissionPlanner) scalingResources(ctx context.Context, taskDefInfos []*TaskDefInfo) []DecommissionResource {
+	var resources []DecommissionResource
+	described := map[string]bool{}
+	for _, taskDefInfo := range taskDefInfos {
+		for _, svc := range taskDefInfo.Services {
+			resourceID := fmt.Sprintf("service/%s/%s", p.cluster, aws.ToString(svc.ServiceName))
+			if described[resourceID] {
+				// Already listed for another container split from the same service
+				continue
+			}
+			described[resourceID] = true
+
+			targets, err := p.autoscaling.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+				ServiceNamespace: aatypes.ServiceNamespaceEcs,
+				ResourceIds:      []string{resourceID},
+			})
+			if err != nil {
+				log.Printf("Warning: Failed to describe scalable target %s: %v", resourceID, err)
+				continue
+			}
+			for _, target := range targets.ScalableTargets {
+				resources = append(resources, DecommissionResource{
+					Kind:     decommissionScalableTarget,
+					Name:     resourceID,
+					ARN:      aws.ToString(target.ScalableTargetARN),
+					Services: []string{taskDefInfo.Name},
+				})
+			}
+
+			paginator := applicationautoscaling.NewDescribeScalingPoliciesPaginator(p.autoscaling, &applicationautoscaling.DescribeScalingPoliciesInput{
+				ServiceNamespace: aatypes.ServiceNamespaceEcs,
+				ResourceId:       aws.String(resourceID),
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Warning: Failed to describe scaling policies of %s: %v", resourceID, err)
+					break
+				}
+				for _, policy := range page.ScalingPolicies {
+					resources = append(resources, DecommissionResource{
+						Kind:     decommissionScalingPolicy,
+						Name:     aws.ToString(policy.PolicyName),
+						ARN:      aws.ToString(policy.PolicyARN),
+						Services: []string{taskDefInfo.Name},
+						Note:     scalingPolicyAlarmNote(policy),
+					})
+				}
+			}
+		}
+	}
+	return resources
+}
+
+// scalingPolicyAlarmNote tells whether a policy's CloudWatch alarms must be deleted separately
+func scalingPolicyAlarmNote(policy aatypes.ScalingPolicy) string {
+	if policy.PolicyType == aatypes.PolicyTypeTargetTrackingScaling || len(policy.Alarms) == 0 {
+		return ""
+	}
+	var names []string
+	for _, alarm := range policy.Alarms {
+		names = append(names, aws.ToString(alarm.AlarmName))
+	}
+	return "Also delete its CloudWatch alarms: " + strings.Join(names, ", ")
+}
+
+// loadBalancerResources lists the load balancers of the target groups and
+// flags those that also serve target groups outside the plan
+func (p *decommissionPlanner) loadBalancerResources(ctx context.Context, resources []DecommissionResource) []DecommissionResource {
+	planned := map[string]DecommissionResource{}
+	var arns []string
+	for _, r := range resources {
+		if r.Kind == decommissionTargetGroup {
+			planned[r.ARN] = r
+			arns = append(arns, r.ARN)
+		}
+	}
+	if len(arns) == 0 {
+		return nil
+	}
+
+	loadBalancers := map[string]*DecommissionResource{}
+	var lbArns []string
+	// DescribeTargetGroups accepts up to 20 ARNs per call
+	for start := 0; start < len(arns); start += 20 {
+		end := min(start+20, len(arns))
+		out, err := p.elbv2.DescribeTargetGroups(ctx, &elbv2.DescribeTargetGroupsInput{TargetGroupArns: arns[start:end]})
+		if err != nil {
+			log.Printf("Warning: Failed to describe target groups: %v", err)
+			continue
+		}
+		for _, tg := range out.TargetGroups {
+			for _, lbArn := range tg.LoadBalancerArns {
+				lb, ok := loadBalancers[lbArn]
+				if !ok {
+					lb = &DecommissionResource{Kind: decommissionLoadBalancer, Name: loadBalancerName(lbArn), ARN: lbArn}
+					loadBalancers[lbArn] = lb
+					lbArns = append(lbArns, lbArn)
+				}
+				for _, service := range planned[aws.ToString(tg.TargetGroupArn)].Services {
+					lb.Services = appendUnique(lb.Services, service)
+				}
+			}
+		}
+	}
+
+	var lbResources []DecommissionResource
+	for _, lbArn := range lbArns {
+		lb := loadBalancers[lbArn]
+		out, err := p.elbv2.DescribeTargetGroups(ctx, &elbv2.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(lbArn)})
+		if err != nil {
+			log.Printf("Warning: Failed to describe target groups of %s: %v", lb.Name, err)
+		} else {
+			var others []string
+			for _, tg := range out.TargetGroups {
+				if _, ok := planned[aws.ToString(tg.TargetGroupArn)]; !ok {
+					others = append(others, aws.ToString(tg.TargetGroupName))
+				}
+			}
+			if len(others) > 0 {
+				lb.Note = fmt.Sprintf("Shared: also serves %s; keep it until those are migrated", strings.Join(others, ", "))
+			}
+		}
+		lbResources = append(lbResources, *lb)
+	}
+	return lbResources
+}
+
+// resourceTags fetches the tags of the planned resources by ARN
+func (p *decommissionPlanner) resourceTags(ctx context.Context, resources []DecommissionResource) map[string]map[string]string {
+	tags := map[string]map[string]string{}
+	var elbArns []string
+
+	for _, r := range resources {
+		switch r.Kind {
+		case decommissionService, decommissionTaskDefinition:
+			if r.ARN == "" {
+				continue
+			}
+			out, err := p.ecs.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{ResourceArn: aws.String(r.ARN)})
+			if err != nil {
+				log.Printf("Warning: Failed to list tags of %s: %v", r.Name, err)
+				continue
+			}
+			tags[r.ARN] = map[string]string{}
+			for _, tag := range out.Tags {
+				tags[r.ARN][aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		case decommissionTargetGroup, decommissionLoadBalancer:
+			elbArns = append(elbArns, r.ARN)
+		}
+	}
+
+	// DescribeTags accepts up to 20 resources per call
+	for start := 0; start < len(elbArns); start += 20 {
+		end := min(start+20, len(elbArns))
+		out, err := p.elbv2.DescribeTags(ctx, &elbv2.DescribeTagsInput{ResourceArns: elbArns[start:end]})
+		if err != nil {
+			log.Printf("Warning: Failed to describe load balancer tags: %v", err)
+			continue
+		}
+		for _, desc := range out.TagDescriptions {
+			arn := aws.ToString(desc.ResourceArn)
+			tags[arn] = map[string]string{}
+			for _, tag := range desc.Tags {
+				tags[arn][aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+	}
+
+	return tags
+}
+
+// iacOwnerFromTags names the CloudFormation stack or Terraform module that
+// manages a resource, from the tags CloudFormation sets automatically or the
+// module tags common in Terraform setups
+func iacOwnerFromTags(tags map[string]string) string {
+	if stack := tags["aws:cloudformation:stack-name"]; stack != "" {
+		if logicalID := tags["aws:cloudformation:logical-id"]; logicalID != "" {
+			return fmt.Sprintf("CloudFormation stack %s (%s)", stack, logicalID)
+		}
+		return "CloudFormation stack " + stack
+	}
+
+	managedByTerraform := false
+	for key, value := range tags {
+		normalized := strings.NewReplacer("_", "-", ":", "-").Replace(strings.ToLower(key))
+		switch normalized {
+		case "terraform-module", "tf-module":
+			return "Terraform module " + value
+		case "managed-by", "managedby", "terraform":
+			if strings.EqualFold(value, "terraform") || strings.EqualFold(value, "true") {
+				managedByTerraform = true
+			}
+		}
+	}
+	if managedByTerraform {
+		return "Terraform"
+	}
+	return ""
+}
+
+// sortDecommissionResources orders resources by decommissioning step, then name
+func sortDecommissionResources(resources []DecommissionResource) {
+	step := map[string]int{}
+	for i, s := range decommissionSteps {
+		step[s.kind] = i
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		if step[resources[i].Kind] != step[resources[j].Kind] {
+			return step[resources[i].Kind] < step[resources[j].Kind]
+		}
+		return resources[i].Name < resources[j].Name
+	})
+}
+
+// renderDecommissionChecklist renders the plan as a Markdown checklist
+func renderDecommissionChecklist(plan *DecommissionPlan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Decommission Plan\n\n")
+	fmt.Fprintf(&b, "- **Cluster:** %s\n", plan.Cluster)
+	fmt.Fprintf(&b, "- **Region:** %s\n\n", plan.Region)
+	fmt.Fprintf(&b, "Remove these resources once the Kubernetes workloads serve all traffic. Resources managed by IaC should be removed from their stack or module instead of deleted by hand.\n")
+
+	n := 0
+	for _, s := range decommissionSteps {
+		var items []DecommissionResource
+		for _, r := range plan.Resources {
+			if r.Kind == s.kind {
+				items = append(items, r)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		n++
+		fmt.Fprintf(&b, "\n## %d. %s\n\n", n, s.title)
+		for _, r := range items {
+			fmt.Fprintf(&b, "- [ ] **%s** `%s` (services: %s)\n", r.Name, r.ARN, strings.Join(r.Services, ", "))
+			if r.IaC != "" {
+				fmt.Fprintf(&b, "  - Managed by: %s\n", r.IaC)
+			}
+			if r.Note != "" {
+				fmt.Fprintf(&b, "  - %s\n", r.Note)
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeDecommissionPlan writes the plan as a Markdown checklist and as JSON
+func writeDecommissionPlan(outputDir string, plan *DecommissionPlan) error {
+	path := filepath.Join(outputDir, decommissionPlanFileName)
+	if err := os.WriteFile(path, []byte(renderDecommissionChecklist(plan)), 0o644); err != nil {
+		return fmt.Errorf("failed to write decommission plan %s: %w", path, err)
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode decommission plan: %w", err)
+	}
+	jsonPath := filepath.Join(outputDir, decommissionPlanJSONFileName)
+	if err := os.WriteFile(jsonPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write decommission plan %s: %w", jsonPath, err)
+	}
+	return nil
+}
+
+// loadBalancerName extracts the name from a load balancer ARN
+// (arn:...:loadbalancer/app/<name>/<id>)
+func loadBalancerName(arn string) string {
+	parts := strings.Split(arn, "/")
+	if len(parts) >= 3 {
+		return parts[len(parts)-2]
+	}
+	return arn
+}
+
+// appendUnique appends a value that is not in the list yet
+func appendUnique(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestCollectDecommissionResources tests listing services, task definitions and shared target groups
+func TestCollectDecommissionResources(t *testing.T) {
+	const tgArn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/shared/1"
+	newInfo := func(name string, revision int32) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: name,
+			Source: &types.TaskDefinition{
+				TaskDefinitionArn: aws.String(fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task-definition/%s:%d", name, revision)),
+				Revision:          revision,
+			},
+			Services: []types.Service{{
+				ServiceName:   aws.String(name),
+				ServiceArn:    aws.String("arn:aws:ecs:us-east-1:123456789012:service/prod/" + name),
+				LoadBalancers: []types.LoadBalancer{{TargetGroupArn: aws.String(tgArn)}},
+			}},
+		}
+	}
+
+	resources := collectDecommissionResources([]*TaskDefInfo{newInfo("web", 3), newInfo("api", 5)})
+	sortDecommissionResources(resources)
+
+	var kinds []string
+	for _, r := range resources {
+		kinds = append(kinds, r.Kind+":"+r.Name)
+	}
+	want := "ecs-service:api,ecs-service:web,target-group:shared,task-definition:api:5,task-definition:web:3"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Errorf("resources = %s, want %s", got, want)
+	}
+	if tg := resources[2]; strings.Join(tg.Services, ",") != "web,api" {
+		t.Errorf("target group services = %v, want web and api", tg.Services)
+	}
+}
+
+// TestIaCOwnerFromTags tests detecting the owning stack or module
+func TestIaCOwnerFromTags(t *testing.T) {
+	tests := []struct {
+		tags map[string]string
+		want string
+	}{
+		{map[string]string{"aws:cloudformation:stack-name": "web", "aws:cloudformation:logical-id": "Service"}, "CloudFormation stack web (Service)"},
+		{map[string]string{"TF_Module": "module.web"}, "Terraform module module.web"},
+		{map[string]string{"ManagedBy": "Terraform"}, "Terraform"},
+		{map[string]string{"team": "payments"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := iacOwnerFromTags(tt.tags); got != tt.want {
+			t.Errorf("iacOwnerFromTags(%v) = %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+// TestRenderDecommissionChecklist tests numbering the steps that have resources
+func TestRenderDecommissionChecklist(t *testing.T) {
+	plan := &DecommissionPlan{
+		Cluster: "prod",
+		Region:  "us-east-1",
+		Resources: []DecommissionResource{
+			{Kind: decommissionService, Name: "web", ARN: "arn:svc", Services: []string{"web"}, IaC: "Terraform"},
+			{Kind: decommissionLoadBalancer, Name: "public", ARN: "arn:lb", Services: []string{"web"}, Note: "Shared"},
+		},
+	}
+
+	got := renderDecommissionChecklist(plan)
+	for _, want := range []string{
+		"## 1. Scale ECS services to 0 and delete them",
+		"- [ ] **web** `arn:svc` (services: web)\n  - Managed by: Terraform",
+		"## 2. Delete load balancers",
+		"  - Shared\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("checklist missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// doctorTimeout bounds each network check, so an unreachable endpoint is
+// reported instead of waiting out the SDK retries
+const doctorTimeout = 15 * time.Second
+
+// DoctorCheck is the outcome of one check of the environment
+type DoctorCheck struct {
+	Name string
+	OK   bool
+	// Optional checks, such as tools only some flags need, don't fail doctor
+	Optional bool
+	Detail   string
+	// Fix tells how to resolve a failed check
+	Fix string
+}
+
+// doctorTool is an external tool some flags run
+type doctorTool struct {
+	name string
+	// neededBy lists the flags and subcommands running the tool
+	neededBy string
+	install  string
+}
+
+// doctorTools are the optional tools ecs2k8s runs
+var doctorTools = []doctorTool{
+	{"kubectl", "operator, verify, prune, drift", "https://kubernetes.io/docs/tasks/tools/"},
+	{"helm", "--helm-tests (helm unittest)", "https://helm.sh/docs/intro/install/"},
+	{"opa", "--policy", "https://www.openpolicyagent.org/docs/latest/#running-opa"},
+	{"cosign", "--cosign", "https://docs.sigstore.dev/cosign/system_config/installation/"},
+}
+
+// doctorOptions holds the inputs of the doctor subcommand
+type doctorOptions struct {
+	region      string
+	dir         string
+	kubernetes  bool
+	kubeContext string
+}
+
+// newDoctorCommand creates the `doctor` subcommand
+func newDoctorCommand() *cobra.Command {
+	opts := &doctorOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment ecs2k8s runs in and print how to fix problems",
+		Long: `doctor checks what a conversion needs before running one: AWS credentials,
+the region and whether its ECS endpoint is reachable (through --proxy and
+--ca-bundle when set), and that the output directory is writable. With
+--kubernetes it also checks kubectl and the kubeconfig context the operator,
+verify, prune and drift use.
+
+Tools only some flags run (kubectl, helm, opa, cosign) are reported without
+failing the check. Each failed check is followed by a fix. Use preflight to
+check the IAM permissions of every API call.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (default: from the AWS configuration)")
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Directory the cluster output directories are written to (default: current directory)")
+	cmd.Flags().BoolVar(&opts.kubernetes, "kubernetes", false, "Also check kubectl and the Kubernetes cluster the manifests are applied to")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context to check with --kubernetes (default: current context)")
+
+	return cmd
+}
+
+// runDoctor executes the doctor subcommand
+func runDoctor(ctx context.Context, opts *doctorOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var checks []DoctorCheck
+	checks = append(checks, doctorAWSChecks(ctx, opts.region)...)
+
+	dir := opts.dir
+	if dir == "" {
+		dir = "."
+	}
+	checks = append(checks, checkOutputDir(dir))
+
+	if opts.kubernetes {
+		checks = append(checks, checkKubernetes(ctx, opts.kubeContext))
+	}
+	checks = append(checks, checkTools(exec.LookPath, opts.kubernetes)...)
+
+	fmt.Fprintf(os.Stdout, "ecs2k8s %s\n\n", buildInfo().Version)
+	failed := printDoctorSummary(os.Stdout, checks)
+	if len(failed) > 0 {
+		return fmt.Errorf("doctor found %d problem(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// doctorAWSChecks checks the AWS credentials, the region and the reachability
+// of its ECS endpoint. Later checks are skipped once one fails.
+func doctorAWSChecks(ctx context.Context, region string) []DoctorCheck {
+	regionCheck := DoctorCheck{Name: "AWS region"}
+	if region != "" {
+		if err := validateRegion(region); err != nil {
+			regionCheck.Detail = err.Error()
+			regionCheck.Fix = "Pass a valid --region, e.g. us-east-1"
+			return []DoctorCheck{regionCheck}
+		}
+	}
+
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		regionCheck.Detail = err.Error()
+		regionCheck.Fix = "Fix the AWS config file, or the --use-fips-endpoint/--use-dualstack-endpoint region"
+		return []DoctorCheck{regionCheck}
+	}
+	if cfg.Region == "" {
+		regionCheck.Detail = "no region configured"
+		regionCheck.Fix = "Pass --region, or set AWS_REGION or the region of the AWS profile"
+		return []DoctorCheck{regionCheck}
+	}
+	regionCheck.OK, regionCheck.Detail = true, cfg.Region
+
+	credentials := DoctorCheck{Name: "AWS credentials"}
+	callCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(callCtx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		credentials.Detail = err.Error()
+		credentials.Fix = "Configure credentials with aws configure or aws sso login, or set AWS_PROFILE; see \"AWS Credentials Not Found\" in the Readme"
+		return []DoctorCheck{regionCheck, credentials}
+	}
+	credentials.OK, credentials.Detail = true, aws.ToString(identity.Arn)
+
+	reachable := DoctorCheck{Name: "ECS endpoint"}
+	_, err = ecs.NewFromConfig(cfg).ListClusters(callCtx, &ecs.ListClustersInput{MaxResults: aws.Int32(1)})
+	reachable.OK, reachable.Detail = endpointReachable(err)
+	if !reachable.OK {
+		reachable.Fix = "Check the network route to the ECS API of " + cfg.Region + "; behind a proxy pass --proxy and --ca-bundle"
+	} else if reachable.Detail == "" {
+		reachable.Detail = "ecs." + cfg.Region + " reachable"
+	}
+	return []DoctorCheck{regionCheck, credentials, reachable}
+}
+
+// endpointReachable tells whether an API call reached its endpoint: any
+// response of the API, even an error such as a denied action, proves it
+func endpointReachable(err error) (bool, string) {
+	if err == nil {
+		return true, ""
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return true, "reachable, call returned " + apiErr.ErrorCode()
+	}
+	return false, err.Error()
+}
+
+// checkOutputDir checks that files can be created in the output directory
+func checkOutputDir(dir string) DoctorCheck {
+	check := DoctorCheck{Name: "Output directory", Detail: dir}
+	info, err := os.Stat(dir)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "Create the directory, or pass an existing one with --dir"
+		return check
+	}
+	if !info.IsDir() {
+		check.Detail = dir + " is not a directory"
+		check.Fix = "Pass a directory with --dir"
+		return check
+	}
+	f, err := os.CreateTemp(dir, ".ecs2k8s-doctor-*")
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "Make the directory writable by the current user, or run from another directory"
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+	check.OK = true
+	return check
+}
+
+// checkKubernetes checks that kubectl reaches the API server of the context
+func checkKubernetes(ctx context.Context, kubeContext string) DoctorCheck {
+	check := DoctorCheck{Name: "Kubernetes cluster"}
+	callCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	kubectl := &kubectlRunner{Context: kubeContext}
+	out, err := kubectl.run(callCtx, nil, "version", "--output=yaml")
+	if err != nil {
+		check.Detail = err.Error()
+		check.Fix = "Install kubectl, and check the kubeconfig (KUBECONFIG) and its context with kubectl config get-contexts; pass --kubecontext for another one"
+		return check
+	}
+	check.OK = true
+	check.Detail = "API server reachable"
+	var versions struct {
+		ServerVersion struct {
+			GitVersion string `yaml:"gitVersion"`
+		} `yaml:"serverVersion"`
+	}
+	if yaml.Unmarshal(out, &versions) == nil && versions.ServerVersion.GitVersion != "" {
+		check.Detail = "API server " + versions.ServerVersion.GitVersion
+	}
+	return check
+}
+
+// checkTools checks the optional tools are in PATH. kubectl is required
+// with requireKubectl.
+func checkTools(lookPath func(string) (string, error), requireKubectl bool) []DoctorCheck {
+	var checks []DoctorCheck
+	for _, tool := range doctorTools {
+		check := DoctorCheck{Name: tool.name, Optional: !(tool.name == "kubectl" && requireKubectl)}
+		if path, err := lookPath(tool.name); err != nil {
+			check.Detail = "not in PATH, needed by " + tool.neededBy
+			check.Fix = "Install " + tool.name + ": " + tool.install
+		} else {
+			check.OK, check.Detail = true, path
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// printDoctorSummary writes the checks as a table followed by the fixes of
+// the failed ones, and returns the failed required checks
+func printDoctorSummary(w io.Writer, checks []DoctorCheck) []string {
+	var failed []string
+	var fixes []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, check := range checks {
+		result := "ok"
+		if !check.OK {
+			result = "FAILED"
+			if check.Optional {
+				result = "missing"
+			} else {
+				failed = append(failed, check.Name)
+			}
+			if check.Fix != "" {
+				fixes = append(fixes, fmt.Sprintf("  %s: %s", check.Name, check.Fix))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Name, result, check.Detail)
+	}
+	tw.Flush()
+
+	if len(fixes) > 0 {
+		fmt.Fprintf(w, "\nFixes:\n%s\n", strings.Join(fixes, "\n"))
+	}
+	return failed
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+// TestDoctorChecks tests the local checks of doctor and their summary
+func TestDoctorChecks(t *testing.T) {
+	dir := t.TempDir()
+	if check := checkOutputDir(dir); !check.OK {
+		t.Errorf("checkOutputDir(writable) = %+v", check)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("checkOutputDir() left %d file(s) behind", len(entries))
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(dir, "missing"), file} {
+		if check := checkOutputDir(path); check.OK || check.Fix == "" {
+			t.Errorf("checkOutputDir(%s) = %+v, want a failure with a fix", path, check)
+		}
+	}
+
+	lookPath := func(name string) (string, error) {
+		if name == "helm" {
+			return "/usr/local/bin/helm", nil
+		}
+		return "", errors.New("not found")
+	}
+	tools := checkTools(lookPath, false)
+	if len(tools) != len(doctorTools) || tools[0].OK || !tools[0].Optional || !tools[1].OK {
+		t.Errorf("checkTools() = %+v, want kubectl missing and helm found", tools)
+	}
+	if tools := checkTools(lookPath, true); tools[0].Optional {
+		t.Errorf("checkTools(kubernetes) kubectl is optional, want required")
+	}
+
+	if ok, _ := endpointReachable(&smithy.GenericAPIError{Code: "AccessDeniedException"}); !ok {
+		t.Errorf("endpointReachable(denied) = false, want the endpoint reached")
+	}
+	if ok, _ := endpointReachable(errors.New("dial tcp: i/o timeout")); ok {
+		t.Errorf("endpointReachable(timeout) = true")
+	}
+
+	var out bytes.Buffer
+	checks := append([]DoctorCheck{
+		{Name: "AWS credentials", Detail: "no credentials", Fix: "Run aws sso login"},
+		{Name: "Output directory", OK: true, Detail: "."},
+	}, checkTools(lookPath, false)...)
+	failed := printDoctorSummary(&out, checks)
+	if strings.Join(failed, ",") != "AWS credentials" {
+		t.Errorf("printDoctorSummary() failed = %v, want only the credentials", failed)
+	}
+	for _, want := range []string{"FAILED", "kubectl", "missing", "Fixes:", "Run aws sso login", "Install cosign"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary has no %q:\n%s", want, out.String())
+		}
+	}
+}
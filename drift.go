@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FieldChange describes a single field that differs between two snapshots
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// ServiceDrift lists the changes detected for one converted service
+type ServiceDrift struct {
+	Name    string        `json:"name"`
+	Status  string        `json:"status"` // changed, added, removed
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// driftOptions holds the inputs of the drift subcommand
+type driftOptions struct {
+	dir         string
+	region      string
+	cluster     string
+	live        bool
+	kubeContext string
+	namespace   string
+	exitCode    bool
+	iac         iacInputs
+}
+
+// newDriftCommand creates the `drift` subcommand
+func newDriftCommand() *cobra.Command {
+	opts := &driftOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Detect ECS changes since the last conversion",
+		Long: `drift re-reads the ECS services of a previously converted cluster and compares
+them against the state recorded in the output directory (` + stateFileName + `),
+listing every task definition or service field that changed on the ECS side
+since the last conversion.
+
+With --from-cfn-template, --from-terraform-state or --from-cdk-out, the state
+is compared against the services declared in IaC instead of the ECS API.
+
+With --live, the current ECS definitions are also compared against the
+Deployments running in the Kubernetes cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDrift(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Output directory of the previous conversion (default: ./<cluster>)")
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (default: region recorded in the state file)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name (default: cluster recorded in the state file)")
+	cmd.Flags().BoolVar(&opts.live, "live", false, "Also compare against Deployments in the live Kubernetes cluster")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context for --live (default: current context)")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "default", "Namespace of the applied Deployments for --live")
+	cmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "Exit with an error when drift is detected")
+	cmd.Flags().StringVar(&opts.iac.cfnTemplate, "from-cfn-template", "", "Compare against the services of a CloudFormation template instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.terraformState, "from-terraform-state", "", "Compare against the services of a Terraform state file instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.cdkOut, "from-cdk-out", "", "Compare against the services of a cdk.out directory instead of ECS")
+	cmd.Flags().StringVar(&opts.iac.exportedJSON, "from-json", "", "Compare against ECS API responses exported with the AWS CLI (JSON file or directory) instead of ECS")
+
+	return cmd
+}
+
+// convertedCluster is a previously converted cluster, read back from the
+// state file of its output directory
+type convertedCluster struct {
+	dir     string
+	region  string
+	cluster string
+	state   *ConversionState
+}
+
+// readConvertedCluster reads the state of the output directory of a previous
+// conversion. The region and cluster default to the ones it recorded.
+func readConvertedCluster(dir, region, clusterName string) (*convertedCluster, error) {
+	if dir == "" {
+		if clusterName == "" {
+			return nil, fmt.Errorf("either --dir or --cluster is required")
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		dir = filepath.Join(cwd, clusterName)
+	}
+
+	previous, err := readConversionState(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if region == "" {
+		region = previous.Region
+	}
+	if clusterName == "" {
+		// Prefer the ARN so clusters sharing a name across accounts stay unambiguous
+		clusterName = previous.ClusterARN
+	}
+	if clusterName == "" {
+		clusterName = previous.Cluster
+	}
+	if region == "" || clusterName == "" {
+		return nil, fmt.Errorf("region and cluster could not be determined; pass --region and --cluster")
+	}
+	if err := validateRegion(region); err != nil {
+		return nil, err
+	}
+
+	return &convertedCluster{dir: dir, region: region, cluster: clusterName, state: previous}, nil
+}
+
+// runDrift executes the drift subcommand
+func runDrift(opts *driftOptions) error {
+	ctx := context.Background()
+
+	target, err := readConvertedCluster(opts.dir, opts.region, opts.cluster)
+	if err != nil {
+		return err
+	}
+	previous, region, clusterName := target.state, target.region, target.cluster
+	if err := opts.iac.validate(); err != nil {
+		return err
+	}
+
+	var taskDefInfos []*TaskDefInfo
+	if opts.iac.enabled() {
+		taskDefInfos, err = readIaCTaskDefInfos(opts.iac, clusterName)
+	} else {
+		taskDefInfos, err = readECSTaskDefInfos(ctx, region, clusterName)
+	}
+	if err != nil {
+		return err
+	}
+	taskDefInfos = previous.splitServices(withoutAWSBatch(taskDefInfos, clusterName))
+
+	current, err := newConversionState(region, clusterName, taskDefInfos)
+	if err != nil {
+		return err
+	}
+
+	drifts := compareConversionStates(previous, current)
+
+	if opts.live {
+		liveDrifts, err := compareLiveDeployments(ctx, &kubectlRunner{Context: opts.kubeContext}, opts.namespace, taskDefInfos)
+		if err != nil {
+			return err
+		}
+		drifts = append(drifts, liveDrifts...)
+	}
+
+	printDriftReport(previous, drifts)
+
+	if opts.exitCode && len(drifts) > 0 {
+		return fmt.Errorf("drift detected in %d service(s)", len(drifts))
+	}
+
+	return nil
+}
+
+// readECSTaskDefInfos converts the task definitions currently run by the ECS services of a cluster
+func readECSTaskDefInfos(ctx context.Context, region, clusterName string) ([]*TaskDefInfo, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	ecsClient := ecs.NewFromConfig(cfg)
+
+	log.Printf("Reading current ECS services from cluster %s...", clusterName)
+	services, err := describeClusterServices(ctx, ecsClient, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := readClusterSettings(ctx, ecsClient, clusterName)
+	fetch := func(taskDefArn string, services []types.Service) (*TaskDefInfo, error) {
+		return fetchTaskDefInfo(ctx, ecsClient, taskDefArn, services)
+	}
+	return clusterTaskDefInfos(clusterName, services, settings, fetch), nil
+}
+
+// clusterTaskDefInfos converts the task definitions of the services of a
+// cluster. Services without a strategy get the default capacity provider
+// strategy of the cluster, as in convert, so they match the recorded state.
+func clusterTaskDefInfos(clusterName string, services []types.Service, settings *ClusterSettings, fetch taskDefInfoFetcher) []*TaskDefInfo {
+	settings.applyDefaultStrategy(services)
+
+	servicesByTaskDef := servicesByTaskDefinition(services)
+	var taskDefInfos []*TaskDefInfo
+	for _, taskDefArn := range serviceTaskDefinitions(clusterName, services) {
+		taskDefInfo, err := fetch(taskDefArn, servicesByTaskDef[taskDefArn])
+		if err != nil {
+			log.Printf("Error: %v", err)
+			continue
+		}
+		taskDefInfos = append(taskDefInfos, taskDefInfo)
+	}
+	return taskDefInfos
+}
+
+// readIaCTaskDefInfos converts the task definitions declared for a cluster in IaC
+func readIaCTaskDefInfos(iac iacInputs, clusterName string) ([]*TaskDefInfo, error) {
+	clusters, err := iac.load()
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectIaCClusters(clusters, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Reading services of cluster %s declared in %s...", selected[0].Name, selected[0].Source)
+	return selected[0].taskDefInfos(), nil
+}
+
+// compareConversionStates lists the differences between two conversion states
+func compareConversionStates(previous, current *ConversionState) []ServiceDrift {
+	names := map[string]bool{}
+	for name := range previous.Services {
+		names[name] = true
+	}
+	for name := range current.Services {
+		names[name] = true
+	}
+
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	var drifts []ServiceDrift
+	for _, name := range sortedNames {
+		prev, hadPrev := previous.Services[name]
+		curr, hasCurr := current.Services[name]
+
+		switch {
+		case !hadPrev:
+			drifts = append(drifts, ServiceDrift{Name: name, Status: "added"})
+		case !hasCurr:
+			drifts = append(drifts, ServiceDrift{Name: name, Status: "removed"})
+		default:
+			if changes := diffFields(prev.Fields, curr.Fields); len(changes) > 0 {
+				drifts = append(drifts, ServiceDrift{Name: name, Status: "changed", Changes: changes})
+			}
+		}
+	}
+
+	return drifts
+}
+
+// diffFields compares two flattened field maps
+func diffFields(before, after map[string]string) []FieldChange {
+	keys := map[string]string{}
+	for key := range before {
+		keys[key] = ""
+	}
+	for key := range after {
+		keys[key] = ""
+	}
+
+	var changes []FieldChange
+	for _, key := range sortedKeys(keys) {
+		if before[key] != after[key] {
+			changes = append(changes, FieldChange{Field: key, Old: before[key], New: after[key]})
+		}
+	}
+	return changes
+}
+
+// compareLiveDeployments compares the converted pod specs against the Deployments in a cluster
+func compareLiveDeployments(ctx context.Context, kubectl *kubectlRunner, namespace string, taskDefInfos []*TaskDefInfo) ([]ServiceDrift, error) {
+	var drifts []ServiceDrift
+
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Manifests.Deployment == nil {
+			continue
+		}
+
+		out, err := kubectl.run(ctx, nil, "get", "deployment", taskDefInfo.Name, "--namespace", namespace, "-o", "json", "--ignore-not-found")
+		if err != nil {
+			return nil, err
+		}
+
+		live := ServiceDrift{Name: taskDefInfo.Name + " (live)", Status: "changed"}
+		if len(out) == 0 {
+			live.Status = "not-applied"
+			drifts = append(drifts, live)
+			continue
+		}
+
+		var deployment struct {
+			Spec struct {
+				Template struct {
+					Spec corev1.PodSpec `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		}
+		if err := json.Unmarshal(out, &deployment); err != nil {
+			return nil, fmt.Errorf("failed to parse deployment %s: %w", taskDefInfo.Name, err)
+		}
+
+		live.Changes = diffLivePodSpec(&deployment.Spec.Template.Spec, taskDefInfo.Manifests.Deployment)
+		if len(live.Changes) > 0 {
+			drifts = append(drifts, live)
+		}
+	}
+
+	return drifts, nil
+}
+
+// diffLivePodSpec lists the fields of a live pod spec that differ from the
+// pod spec converted from ECS. The region variables conversion adds with
+// --aws-env are not in the task definition, so they are left out unless the
+// task definition sets them itself.
+func diffLivePodSpec(live, converted *corev1.PodSpec) []FieldChange {
+	liveFields, convertedFields := podSpecFields(live), podSpecFields(converted)
+	for _, c := range live.Containers {
+		for _, name := range implicitAWSEnv {
+			key := fmt.Sprintf("container[%s].env.%s", c.Name, name)
+			if _, ok := convertedFields[key]; !ok {
+				delete(liveFields, key)
+			}
+		}
+	}
+	return diffFields(liveFields, convertedFields)
+}
+
+// podSpecFields flattens the container fields ecs2k8s derives from ECS
+func podSpecFields(podSpec *corev1.PodSpec) map[string]string {
+	fields := map[string]string{}
+	for _, c := range podSpec.Containers {
+		prefix := fmt.Sprintf("container[%s].", c.Name)
+		fields[prefix+"image"] = c.Image
+		for name, qty := range c.Resources.Limits {
+			fields[prefix+"limits."+string(name)] = qty.String()
+		}
+		for name, qty := range c.Resources.Requests {
+			fields[prefix+"requests."+string(name)] = qty.String()
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				fields[prefix+"env."+env.Name] = env.Value
+			}
+		}
+		for _, port := range c.Ports {
+			fields[fmt.Sprintf("%sport.%d", prefix, port.ContainerPort)] = string(port.Protocol)
+		}
+	}
+	return fields
+}
+
+// printDriftReport prints the detected drift to stdout
+func printDriftReport(previous *ConversionState, drifts []ServiceDrift) {
+	fmt.Printf("Drift report for cluster %s (last conversion: %s)\n", previous.Cluster, previous.GeneratedAt)
+
+	if len(drifts) == 0 {
+		fmt.Println("No drift detected.")
+		return
+	}
+
+	for _, drift := range drifts {
+		fmt.Printf("\n%s [%s]\n", drift.Name, drift.Status)
+		for _, change := range drift.Changes {
+			switch {
+			case change.Old == "":
+				fmt.Printf("  + %s: %s\n", change.Field, change.New)
+			case change.New == "":
+				fmt.Printf("  - %s: %s\n", change.Field, change.Old)
+			default:
+				fmt.Printf("  ~ %s: %s -> %s\n", change.Field, change.Old, change.New)
+			}
+		}
+	}
+}
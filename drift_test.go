@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestCompareConversionStates tests drift detection between two conversion states
+func TestCompareConversionStates(t *testing.T) {
+	previous := &ConversionState{
+		Services: map[string]ServiceState{
+			"api":     {Fields: map[string]string{"taskDefinition.Cpu": "256", "taskDefinition.Memory": "512"}},
+			"worker":  {Fields: map[string]string{"taskDefinition.Cpu": "128"}},
+			"stable":  {Fields: map[string]string{"taskDefinition.Cpu": "64"}},
+			"removed": {Fields: map[string]string{}},
+		},
+	}
+	current := &ConversionState{
+		Services: map[string]ServiceState{
+			"api":    {Fields: map[string]string{"taskDefinition.Cpu": "512", "taskDefinition.Family": "api"}},
+			"worker": {Fields: map[string]string{"taskDefinition.Cpu": "128"}},
+			"stable": {Fields: map[string]string{"taskDefinition.Cpu": "64"}},
+			"added":  {Fields: map[string]string{}},
+		},
+	}
+
+	drifts := compareConversionStates(previous, current)
+
+	want := map[string]string{
+		"added":   "added",
+		"api":     "changed",
+		"removed": "removed",
+	}
+	if len(drifts) != len(want) {
+		t.Fatalf("compareConversionStates() returned %d drifts, want %d: %+v", len(drifts), len(want), drifts)
+	}
+
+	for _, drift := range drifts {
+		if want[drift.Name] != drift.Status {
+			t.Errorf("drift for %s has status %q, want %q", drift.Name, drift.Status, want[drift.Name])
+		}
+		if drift.Name == "api" && len(drift.Changes) != 3 {
+			t.Errorf("drift for api has %d changes, want 3: %+v", len(drift.Changes), drift.Changes)
+		}
+	}
+}
+
+// TestDiffLivePodSpec tests that the region variables added by --aws-env are
+// not reported as live drift
+func TestDiffLivePodSpec(t *testing.T) {
+	converted, err := buildTaskDefInfo(appTaskDef("web"), "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	applied, err := buildTaskDefInfo(appTaskDef("web"), "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	injectAWSEnv(applied, "us-east-1")
+
+	live := applied.Manifests.Deployment
+	if changes := diffLivePodSpec(live, converted.Manifests.Deployment); len(changes) != 0 {
+		t.Errorf("diffLivePodSpec() = %+v, want no drift", changes)
+	}
+
+	live.Containers[0].Image = "nginx:1.27"
+	changes := diffLivePodSpec(live, converted.Manifests.Deployment)
+	if len(changes) != 1 || changes[0].Field != "container[app].image" {
+		t.Errorf("diffLivePodSpec() = %+v, want the image", changes)
+	}
+
+	// A region set by the task definition itself is still compared
+	taskDef := appTaskDef("web")
+	taskDef.ContainerDefinitions[0].Environment = append(taskDef.ContainerDefinitions[0].Environment, types.KeyValuePair{Name: aws.String("AWS_REGION"), Value: aws.String("eu-west-1")})
+	converted, err = buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	changes = diffLivePodSpec(live, converted.Manifests.Deployment)
+	if len(changes) != 2 || changes[0].Field != "container[app].env.AWS_REGION" {
+		t.Errorf("diffLivePodSpec() = %+v, want AWS_REGION and the image", changes)
+	}
+}
+
+// TestClusterTaskDefInfosDefaultStrategy tests that services running with the
+// default capacity provider strategy of the cluster show no drift against the
+// state recorded by convert
+func TestClusterTaskDefInfosDefaultStrategy(t *testing.T) {
+	cluster := "arn:aws:ecs:us-east-1:123456789012:cluster/prod"
+	taskDefArn := "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3"
+	services := func() []types.Service {
+		return []types.Service{{ServiceName: aws.String("web"), TaskDefinition: aws.String(taskDefArn), DesiredCount: 2}}
+	}
+	settings := func() *ClusterSettings {
+		return &ClusterSettings{DefaultStrategy: []types.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		}}
+	}
+	fetch := func(arn string, services []types.Service) (*TaskDefInfo, error) {
+		taskDef := appTaskDef("web")
+		taskDef.TaskDefinitionArn = aws.String(arn)
+		return newServiceTaskDefInfo(taskDef, "web", services)
+	}
+
+	// convert applies the default strategy before recording the state
+	converted := services()
+	settings().applyDefaultStrategy(converted)
+	info, _ := fetch(taskDefArn, converted)
+	previous, err := newConversionState("us-east-1", cluster, []*TaskDefInfo{info})
+	if err != nil {
+		t.Fatalf("newConversionState() error = %v", err)
+	}
+
+	current, err := newConversionState("us-east-1", cluster, clusterTaskDefInfos(cluster, services(), settings(), fetch))
+	if err != nil {
+		t.Fatalf("newConversionState() error = %v", err)
+	}
+	if drifts := compareConversionStates(previous, current); len(drifts) != 0 {
+		t.Errorf("compareConversionStates() = %+v, want no drift", drifts)
+	}
+
+	// Without the settings the defaulted strategy shows as removed
+	current, _ = newConversionState("us-east-1", cluster, clusterTaskDefInfos(cluster, services(), nil, fetch))
+	if drifts := compareConversionStates(previous, current); len(drifts) != 1 {
+		t.Errorf("compareConversionStates() without settings = %+v, want the strategy change", drifts)
+	}
+}
+
+// TestSplitServicesDrift tests that the services of a task definition split
+// by --split-containers are read split, so they match the recorded state
+func TestSplitServicesDrift(t *testing.T) {
+	read := func() *TaskDefInfo {
+		taskDef := appTaskDef("web")
+		taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions,
+			types.ContainerDefinition{Name: aws.String("worker"), Image: aws.String("worker:1")},
+			types.ContainerDefinition{Name: aws.String("envoy"), Image: aws.String("envoy:1")},
+		)
+		info, err := newServiceTaskDefInfo(taskDef, "web", []types.Service{{ServiceName: aws.String("web"), DesiredCount: 2}})
+		if err != nil {
+			t.Fatalf("newServiceTaskDefInfo() error = %v", err)
+		}
+		return info
+	}
+
+	converted, err := splitContainers(read(), []string{"envoy"})
+	if err != nil {
+		t.Fatalf("splitContainers() error = %v", err)
+	}
+	previous, err := newConversionState("us-east-1", "prod", converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.Split = map[string][]string{"web": {"envoy"}}
+
+	current, err := newConversionState("us-east-1", "prod", previous.splitServices([]*TaskDefInfo{read()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drifts := compareConversionStates(previous, current); len(drifts) != 0 {
+		t.Errorf("compareConversionStates() = %+v, want no drift", drifts)
+	}
+	if _, ok := current.Services["web-worker"]; !ok {
+		t.Errorf("current services = %v, want web-app and web-worker", current.Services)
+	}
+
+	// Read unsplit, the split services show as removed and web as added
+	previous.Split = nil
+	current, _ = newConversionState("us-east-1", "prod", previous.splitServices([]*TaskDefInfo{read()}))
+	if drifts := compareConversionStates(previous, current); len(drifts) != 3 {
+		t.Errorf("compareConversionStates() unsplit = %+v, want 3 drifts", drifts)
+	}
+}
@@ -4,14 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
-	"github.com/manifoldco/promptui"
 )
 
-// listClusters lists ECS clusters in the region (by name)
+// listClusters lists the ARNs of the ECS clusters in the region
 func listClusters(ctx context.Context, client *ecs.Client) ([]string, error) {
 	var clusters []string
 	input := &ecs.ListClustersInput{MaxResults: aws.Int32(100)}
@@ -24,12 +25,11 @@ func listClusters(ctx context.Context, client *ecs.Client) ([]string, error) {
 			return nil, err
 		}
 		for _, arn := range page.ClusterArns {
-			clusterName := extractClusterName(arn)
-			if clusterName == "" {
+			if extractClusterName(arn) == "" {
 				log.Printf("Warning: Failed to extract cluster name from ARN: %s", arn)
 				continue
 			}
-			clusters = append(clusters, clusterName)
+			clusters = append(clusters, arn)
 		}
 	}
 
@@ -40,31 +40,75 @@ func listClusters(ctx context.Context, client *ecs.Client) ([]string, error) {
 	return clusters, nil
 }
 
-func selectCluster(clusters []string) (string, error) {
-	if len(clusters) == 0 {
+// selectCluster prompts for a cluster and returns its ARN. Cluster names are
+// shown unless showARNs is set or several clusters share the same name.
+func selectCluster(clusterArns []string, showARNs bool, sel selector) (string, error) {
+	if len(clusterArns) == 0 {
 		return "", fmt.Errorf("no clusters available to select")
 	}
 
-	prompt := promptui.Select{
-		Label: "Select ECS cluster",
-		Items: clusters,
-		Templates: &promptui.SelectTemplates{
-			Label:    "{{ . }}?",
-			Active:   "➤ {{ . }}",
-			Inactive: "  {{ . }}",
-		},
+	items := make([]string, len(clusterArns))
+	nameCounts := map[string]int{}
+	for _, arn := range clusterArns {
+		nameCounts[extractClusterName(arn)]++
+	}
+	for i, arn := range clusterArns {
+		name := extractClusterName(arn)
+		if showARNs || nameCounts[name] > 1 {
+			items[i] = arn
+		} else {
+			items[i] = name
+		}
 	}
 
-	_, clusterName, err := prompt.Run()
+	index, err := sel.choose("Select ECS cluster", items)
 	if err != nil {
 		return "", fmt.Errorf("cluster selection failed: %w", err)
 	}
 
-	if clusterName == "" {
+	if index < 0 || index >= len(clusterArns) || clusterArns[index] == "" {
 		return "", fmt.Errorf("selected cluster name is empty")
 	}
 
-	return clusterName, nil
+	return clusterArns[index], nil
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal that can answer prompts
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// resolveClusterRef resolves a cluster name or ARN against the discovered
+// cluster ARNs, failing when a bare name is ambiguous
+func resolveClusterRef(ref string, clusterArns []string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("cluster cannot be empty")
+	}
+
+	var matches []string
+	for _, arn := range clusterArns {
+		if arn == ref || extractClusterName(arn) == ref {
+			matches = append(matches, arn)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		if strings.HasPrefix(ref, "arn:") {
+			// Clusters in other accounts are not listed but can still be described by ARN
+			return ref, nil
+		}
+		return "", fmt.Errorf("cluster %s not found in this region", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("cluster name %s is ambiguous, use one of the ARNs: %s", ref, strings.Join(matches, ", "))
+	}
 }
 
 // listTaskDefinitions lists the task definition ARNs that are actually used
@@ -72,6 +116,58 @@ func selectCluster(clusters []string) (string, error) {
 // describes those services and collects their TaskDefinition ARNs, returning
 // a deduplicated list.
 func listTaskDefinitions(ctx context.Context, client *ecs.Client, clusterName string) ([]string, error) {
+	services, err := describeClusterServices(ctx, client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(services) == 0 {
+		return []string{}, nil
+	}
+
+	return serviceTaskDefinitions(clusterName, services), nil
+}
+
+// serviceTaskDefinitions collects the deduplicated task definition ARNs used by services
+func serviceTaskDefinitions(clusterName string, services []types.Service) []string {
+	taskDefSet := make(map[string]struct{})
+	var taskDefs []string
+	for _, svc := range services {
+		taskDef := serviceTaskDefinition(svc)
+		if taskDef == "" {
+			log.Printf("Warning: Service %s has empty task definition", aws.ToString(svc.ServiceArn))
+			continue
+		}
+		if _, seen := taskDefSet[taskDef]; seen {
+			continue
+		}
+		taskDefSet[taskDef] = struct{}{}
+		taskDefs = append(taskDefs, taskDef)
+	}
+
+	if len(taskDefs) == 0 {
+		log.Printf("Warning: No task definitions found for services in cluster %s", clusterName)
+		return []string{}
+	}
+
+	return taskDefs
+}
+
+// servicesByTaskDefinition groups services by the task definition ARN they run
+func servicesByTaskDefinition(services []types.Service) map[string][]types.Service {
+	grouped := make(map[string][]types.Service)
+	for _, svc := range services {
+		taskDef := serviceTaskDefinition(svc)
+		if taskDef == "" {
+			continue
+		}
+		grouped[taskDef] = append(grouped[taskDef], svc)
+	}
+	return grouped
+}
+
+// describeClusterServices lists and describes all services in the provided cluster
+func describeClusterServices(ctx context.Context, client *ecs.Client, clusterName string) ([]types.Service, error) {
 	if clusterName == "" {
 		return nil, fmt.Errorf("cluster name cannot be empty")
 	}
@@ -94,11 +190,11 @@ func listTaskDefinitions(ctx context.Context, client *ecs.Client, clusterName st
 
 	if len(serviceArns) == 0 {
 		log.Printf("Info: No services found in cluster %s (cluster may be empty)", clusterName)
-		return []string{}, nil
+		return nil, nil
 	}
 
-	// 2) Describe services in batches and collect TaskDefinition ARNs
-	taskDefSet := make(map[string]struct{})
+	// 2) Describe services in batches
+	var services []types.Service
 	const batchSize = 10 // DescribeServices accepts up to 10 services per call
 	for i := 0; i < len(serviceArns); i += batchSize {
 		j := i + batchSize
@@ -110,6 +206,8 @@ func listTaskDefinitions(ctx context.Context, client *ecs.Client, clusterName st
 		descInput := &ecs.DescribeServicesInput{
 			Cluster:  aws.String(clusterName),
 			Services: batch,
+			// Service tags land on the tasks of services propagating them
+			Include: []types.ServiceField{types.ServiceFieldTags},
 		}
 
 		descOutput, err := client.DescribeServices(ctx, descInput)
@@ -126,50 +224,31 @@ func listTaskDefinitions(ctx context.Context, client *ecs.Client, clusterName st
 			}
 		}
 
-		for _, svc := range descOutput.Services {
-			if svc.TaskDefinition == nil || *svc.TaskDefinition == "" {
-				log.Printf("Warning: Service %s has empty task definition", aws.ToString(svc.ServiceArn))
-				continue
-			}
-			taskDefSet[*svc.TaskDefinition] = struct{}{}
-		}
-	}
-
-	// 3) Convert set to slice
-	var taskDefs []string
-	for arn := range taskDefSet {
-		if arn == "" {
-			log.Printf("Warning: Empty task definition ARN found in set, skipping")
-			continue
-		}
-		taskDefs = append(taskDefs, arn)
-	}
-
-	if len(taskDefs) == 0 {
-		log.Printf("Warning: No task definitions found for services in cluster %s", clusterName)
-		return []string{}, nil
+		services = append(services, descOutput.Services...)
 	}
 
-	return taskDefs, nil
+	return services, nil
 }
 
-func getTaskDefinition(ctx context.Context, client *ecs.Client, taskDefArn string) (*types.TaskDefinition, error) {
+// getTaskDefinition describes a task definition with its tags
+func getTaskDefinition(ctx context.Context, client *ecs.Client, taskDefArn string) (*types.TaskDefinition, []types.Tag, error) {
 	if taskDefArn == "" {
-		return nil, fmt.Errorf("task definition ARN cannot be empty")
+		return nil, nil, fmt.Errorf("task definition ARN cannot be empty")
 	}
 
 	input := &ecs.DescribeTaskDefinitionInput{
 		TaskDefinition: aws.String(taskDefArn),
+		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
 	}
 
 	output, err := client.DescribeTaskDefinition(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe task definition %s: %w", taskDefArn, err)
+		return nil, nil, fmt.Errorf("failed to describe task definition %s: %w", taskDefArn, err)
 	}
 
 	if output.TaskDefinition == nil {
-		return nil, fmt.Errorf("task definition %s returned nil from AWS API", taskDefArn)
+		return nil, nil, fmt.Errorf("task definition %s returned nil from AWS API", taskDefArn)
 	}
 
-	return output.TaskDefinition, nil
+	return output.TaskDefinition, output.Tags, nil
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestResolveClusterRef tests resolving cluster names and ARNs against the
+// clusters discovered in the region
+func TestResolveClusterRef(t *testing.T) {
+	clusterArns := []string{
+		"arn:aws:ecs:us-east-1:111111111111:cluster/prod",
+		"arn:aws:ecs:us-east-1:222222222222:cluster/prod",
+		"arn:aws:ecs:us-east-1:111111111111:cluster/staging",
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{"unique name", "staging", "arn:aws:ecs:us-east-1:111111111111:cluster/staging", ""},
+		{"name with spaces", "  staging ", "arn:aws:ecs:us-east-1:111111111111:cluster/staging", ""},
+		{"listed arn", "arn:aws:ecs:us-east-1:222222222222:cluster/prod", "arn:aws:ecs:us-east-1:222222222222:cluster/prod", ""},
+		{"cross-account arn", "arn:aws:ecs:us-east-1:333333333333:cluster/prod", "arn:aws:ecs:us-east-1:333333333333:cluster/prod", ""},
+		{"ambiguous name", "prod", "", "ambiguous"},
+		{"unknown name", "dev", "", "not found"},
+		{"empty", " ", "", "cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveClusterRef(tt.ref, clusterArns)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveClusterRef(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveClusterRef(%q) error = %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveClusterRef(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+
+	// The error of an ambiguous name lists the ARNs to choose from
+	_, err := resolveClusterRef("prod", clusterArns)
+	if err == nil || !strings.Contains(err.Error(), clusterArns[0]) || !strings.Contains(err.Error(), clusterArns[1]) {
+		t.Errorf("resolveClusterRef(prod) error = %v, want both prod ARNs", err)
+	}
+}
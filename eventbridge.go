@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// eventsTokenEnv is the environment variable holding the bearer token POST
+// /events requires, kept out of the command line
+const eventsTokenEnv = "ECS2K8S_EVENTS_TOKEN"
+
+// maxEventBytes limits the size of a /events request body
+const maxEventBytes = 1 << 20
+
+// ecsAPICallEvent is the EventBridge event of an ECS API call recorded by
+// CloudTrail
+type ecsAPICallEvent struct {
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+	Region     string `json:"region"`
+	Detail     struct {
+		EventSource      string `json:"eventSource"`
+		EventName        string `json:"eventName"`
+		ErrorCode        string `json:"errorCode"`
+		ResponseElements struct {
+			TaskDefinition struct {
+				TaskDefinitionArn string `json:"taskDefinitionArn"`
+				Family            string `json:"family"`
+			} `json:"taskDefinition"`
+		} `json:"responseElements"`
+	} `json:"detail"`
+}
+
+// EventResponse is returned by POST /events
+type EventResponse struct {
+	Family         string   `json:"family,omitempty"`
+	TaskDefinition string   `json:"taskDefinition,omitempty"`
+	Updated        []string `json:"updated,omitempty"`
+	// Ignored tells why the event triggered no conversion
+	Ignored string `json:"ignored,omitempty"`
+}
+
+// eventConverter regenerates the output of a cluster for the task definition
+// families registered in the events it receives
+type eventConverter struct {
+	// mu serializes the events, which share the output directory and its state
+	mu      sync.Mutex
+	watcher *watcher
+	token   string
+	// fetch converts the services of the cluster, those of the family running
+	// the registered task definition revision; none when no service runs the
+	// family
+	fetch func(ctx context.Context, family, taskDefArn string) ([]*TaskDefInfo, error)
+}
+
+// newEventConverter creates the converter of the events of the cluster
+// converted into dir
+func newEventConverter(ctx context.Context, dir, region string, push bool) (*eventConverter, error) {
+	target, err := readConvertedCluster(dir, region, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadAWSConfig(ctx, target.region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	ecsClient := ecs.NewFromConfig(cfg)
+	pipeline, err := newStatePipeline(&cfg, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventConverter{
+		watcher: &watcher{
+			target:   target,
+			pipeline: pipeline,
+			commit:   true,
+			push:     push,
+			trigger:  "event",
+		},
+		token: os.Getenv(eventsTokenEnv),
+		fetch: func(ctx context.Context, family, taskDefArn string) ([]*TaskDefInfo, error) {
+			services, err := describeClusterServices(ctx, ecsClient, target.cluster)
+			if err != nil {
+				return nil, err
+			}
+			settings := readClusterSettings(ctx, ecsClient, target.cluster)
+			if !runRegisteredRevision(services, family, taskDefArn) {
+				return nil, nil
+			}
+			fetch := func(taskDefArn string, services []types.Service) (*TaskDefInfo, error) {
+				return fetchTaskDefInfo(ctx, ecsClient, taskDefArn, services)
+			}
+			return target.state.splitServices(clusterTaskDefInfos(target.cluster, services, settings, fetch)), nil
+		},
+	}, nil
+}
+
+// runRegisteredRevision points the services running a revision of a task
+// definition family at a newly registered revision, reporting whether any does
+func runRegisteredRevision(services []types.Service, family, taskDefArn string) bool {
+	matched := false
+	for i := range services {
+		if extractTaskDefName(aws.ToString(services[i].TaskDefinition)) == family {
+			services[i].TaskDefinition = aws.String(taskDefArn)
+			matched = true
+		}
+	}
+	return matched
+}
+
+// registration returns the family and ARN of the task definition registered
+// by an event, or why the event is ignored
+func (e *ecsAPICallEvent) registration(region string) (family, taskDefArn, ignored string) {
+	switch {
+	case e.Source != "aws.ecs" || e.Detail.EventName != "RegisterTaskDefinition":
+		return "", "", fmt.Sprintf("not an ECS RegisterTaskDefinition event (%s %s)", e.Source, e.Detail.EventName)
+	case e.Detail.ErrorCode != "":
+		return "", "", "the registration failed with " + e.Detail.ErrorCode
+	case e.Region != "" && e.Region != region:
+		return "", "", fmt.Sprintf("event of region %s, the cluster is in %s", e.Region, region)
+	}
+
+	taskDef := e.Detail.ResponseElements.TaskDefinition
+	family = taskDef.Family
+	if family == "" {
+		family = extractTaskDefName(taskDef.TaskDefinitionArn)
+	}
+	if family == "" || taskDef.TaskDefinitionArn == "" {
+		return "", "", "the event has no task definition"
+	}
+	return family, taskDef.TaskDefinitionArn, ""
+}
+
+// handleEvents handles POST /events
+func (e *eventConverter) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	if e.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+e.token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	var event ecsAPICallEvent
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventBytes))
+	if err := decoder.Decode(&event); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid event: %v", err))
+		return
+	}
+
+	family, taskDefArn, ignored := event.registration(e.watcher.target.region)
+	if ignored != "" {
+		writeJSON(w, http.StatusAccepted, EventResponse{Ignored: ignored})
+		return
+	}
+	resp := EventResponse{Family: family, TaskDefinition: taskDefArn}
+	log.Printf("Task definition %s registered", taskDefArn)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	taskDefInfos, err := e.fetch(r.Context(), family, taskDefArn)
+	if err != nil {
+		// A server error makes EventBridge retry the event
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if len(taskDefInfos) == 0 {
+		resp.Ignored = fmt.Sprintf("no service of cluster %s runs family %s", e.watcher.target.cluster, family)
+		writeJSON(w, http.StatusAccepted, resp)
+		return
+	}
+
+	resp.Updated, err = e.watcher.sync(r.Context(), taskDefInfos, family)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
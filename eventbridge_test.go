@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// registrationEvent returns the EventBridge event of a task definition registration
+func registrationEvent(region, family, revision string) string {
+	return `{
+  "source": "aws.ecs",
+  "detail-type": "AWS API Call via CloudTrail",
+  "region": "` + region + `",
+  "detail": {
+    "eventSource": "ecs.amazonaws.com",
+    "eventName": "RegisterTaskDefinition",
+    "responseElements": {"taskDefinition": {
+      "taskDefinitionArn": "arn:aws:ecs:us-east-1:123456789012:task-definition/` + family + `:` + revision + `",
+      "family": "` + family + `"
+    }}
+  }
+}`
+}
+
+// TestHandleEvents tests that a registration event regenerates and commits
+// only the services of its family
+func TestHandleEvents(t *testing.T) {
+	dir := initGitRepo(t)
+	previous, err := newConversionState("us-east-1", "prod", []*TaskDefInfo{watchTaskDef("web", "web:1"), watchTaskDef("db", "db:1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConversionState(dir, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	target := &convertedCluster{dir: dir, region: "us-east-1", cluster: "prod", state: previous}
+	pipeline, err := newStatePipeline(nil, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetched []string
+	events := &eventConverter{
+		watcher: &watcher{
+			target:   target,
+			pipeline: pipeline,
+			commit:   true,
+			trigger:  "event",
+		},
+		token: "secret",
+		fetch: func(_ context.Context, family, taskDefArn string) ([]*TaskDefInfo, error) {
+			fetched = append(fetched, taskDefArn)
+			if family != "web" {
+				return nil, nil
+			}
+			return []*TaskDefInfo{watchTaskDef("web", "web:2"), watchTaskDef("db", "db:2")}, nil
+		},
+	}
+
+	post := func(body, token string) (int, EventResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		events.handleEvents(rec, req)
+		var resp EventResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	if code, _ := post(registrationEvent("us-east-1", "web", "13"), "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	for _, body := range []string{
+		`{"source": "aws.ecs", "detail-type": "ECS Task State Change", "detail": {}}`,
+		registrationEvent("eu-west-1", "web", "13"),
+		registrationEvent("us-east-1", "batch", "2"),
+	} {
+		if code, resp := post(body, "secret"); code != http.StatusAccepted || resp.Ignored == "" {
+			t.Errorf("event %s: status = %d, response = %+v, want it ignored", body, code, resp)
+		}
+	}
+
+	code, resp := post(registrationEvent("us-east-1", "web", "13"), "secret")
+	if code != http.StatusOK || resp.Family != "web" || strings.Join(resp.Updated, ",") != "web" {
+		t.Fatalf("status = %d, response = %+v, want web updated", code, resp)
+	}
+	if len(fetched) != 2 || !strings.HasSuffix(fetched[1], "task-definition/web:13") {
+		t.Errorf("fetched %v, want the batch and web registrations", fetched)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web-deployment.yaml")); err != nil {
+		t.Errorf("web wasn't regenerated: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db-deployment.yaml")); err == nil {
+		t.Errorf("the db service of another family was regenerated")
+	}
+	state, err := readConversionState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Services["db"]; !ok {
+		t.Errorf("state services = %v, want db kept", state.Services)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// eventsFileName is the conversion timeline written into the output directory (--events)
+const eventsFileName = "conversion-events.json"
+
+// Types of conversion events
+const (
+	eventDiscovered = "discovered"
+	eventWarning    = "warning"
+	eventConverted  = "converted"
+	eventFailed     = "failed"
+)
+
+// ConversionEvent is one step of a service's conversion
+type ConversionEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// Service is the Kubernetes name of the task definition; empty when it
+	// failed before it was named
+	Service        string   `json:"service,omitempty"`
+	TaskDefinition string   `json:"taskDefinition,omitempty"`
+	ECSServices    []string `json:"ecsServices,omitempty"`
+	Message        string   `json:"message,omitempty"`
+	// Outputs are the files written for the service, relative to the output directory
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+// ConversionEvents is the timeline of a cluster's conversion, for import into
+// migration tracking tools
+type ConversionEvents struct {
+	Cluster    string            `json:"cluster"`
+	Region     string            `json:"region"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Events     []ConversionEvent `json:"events"`
+}
+
+// eventRecorder collects conversion events. A nil recorder records nothing.
+type eventRecorder struct {
+	events ConversionEvents
+	now    func() time.Time
+}
+
+// newEventRecorder starts the timeline of a cluster's conversion
+func newEventRecorder(cluster, region string) *eventRecorder {
+	r := &eventRecorder{now: func() time.Time { return time.Now().UTC() }}
+	r.events = ConversionEvents{Cluster: cluster, Region: region, StartedAt: r.now()}
+	return r
+}
+
+// record appends an event stamped with the current time
+func (r *eventRecorder) record(event ConversionEvent) {
+	if r == nil {
+		return
+	}
+	event.Time = r.now()
+	r.events.Events = append(r.events.Events, event)
+}
+
+// discovered records a task definition selected for conversion
+func (r *eventRecorder) discovered(taskDefInfo *TaskDefInfo) {
+	r.record(serviceEvent(eventDiscovered, taskDefInfo))
+}
+
+// converted records the notes and unmapped fields of a converted service as
+// warnings, followed by the files written for it
+func (r *eventRecorder) converted(taskDefInfo *TaskDefInfo, outputs []string) {
+	for _, note := range taskDefInfo.Notes {
+		event := serviceEvent(eventWarning, taskDefInfo)
+		event.Message = note
+		r.record(event)
+	}
+	for _, field := range taskDefInfo.Unmapped {
+		event := serviceEvent(eventWarning, taskDefInfo)
+		event.Message = fmt.Sprintf("Unconverted field %s", field)
+		r.record(event)
+	}
+
+	event := serviceEvent(eventConverted, taskDefInfo)
+	event.Outputs = outputs
+	r.record(event)
+}
+
+// failed records a task definition that could not be converted. taskDefInfo
+// is nil when the task definition could not be read.
+func (r *eventRecorder) failed(taskDefInfo *TaskDefInfo, taskDefArn string, err error) {
+	event := ConversionEvent{TaskDefinition: taskDefArn}
+	if taskDefInfo != nil {
+		event = serviceEvent(eventFailed, taskDefInfo)
+	}
+	event.Type = eventFailed
+	event.Message = err.Error()
+	r.record(event)
+}
+
+// write finishes the timeline and writes it into the output directory
+func (r *eventRecorder) write(outputDir string) error {
+	if r == nil {
+		return nil
+	}
+	r.events.FinishedAt = r.now()
+
+	data, err := json.MarshalIndent(r.events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode conversion events: %w", err)
+	}
+	path := filepath.Join(outputDir, eventsFileName)
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write conversion events %s: %w", path, err)
+	}
+	return nil
+}
+
+// serviceEvent returns an event identifying a service and its sources
+func serviceEvent(eventType string, taskDefInfo *TaskDefInfo) ConversionEvent {
+	event := ConversionEvent{Type: eventType, Service: taskDefInfo.Name}
+	if taskDefInfo.Source != nil {
+		event.TaskDefinition = aws.ToString(taskDefInfo.Source.TaskDefinitionArn)
+	}
+	for _, svc := range taskDefInfo.Services {
+		event.ECSServices = append(event.ECSServices, aws.ToString(svc.ServiceName))
+	}
+	return event
+}
+
+// serviceOutputs returns the files written for a service, relative to the output directory
+func serviceOutputs(taskDefInfo *TaskDefInfo, saveSource bool) []string {
+	var outputs []string
+	if files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests); err == nil {
+		for filename := range files {
+			outputs = append(outputs, filename)
+		}
+	}
+	if taskDefInfo.CutoverScript != "" {
+		outputs = append(outputs, filepath.Join(cutoverDirName, taskDefInfo.Name+".sh"))
+	}
+	if saveSource {
+		outputs = append(outputs, taskDefInfo.Name+"-source.json")
+	}
+	sort.Strings(outputs)
+	return outputs
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestEventRecorder tests the timeline of discovered, converted and failed services
+func TestEventRecorder(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	clock := start
+	r := newEventRecorder("prod", "us-east-1")
+	r.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	info := &TaskDefInfo{
+		Name:     "web",
+		Source:   &types.TaskDefinition{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:3")},
+		Services: []types.Service{{ServiceName: aws.String("web")}},
+		Notes:    []string{"Container web: ECS cpu 10 maps to 10m"},
+		Unmapped: []string{"volumes"},
+	}
+	r.discovered(info)
+	r.converted(info, []string{"web-deployment.yaml"})
+	r.failed(nil, "arn:aws:ecs:us-east-1:123456789012:task-definition/api:1", errors.New("access denied"))
+
+	dir := t.TempDir()
+	if err := r.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, eventsFileName))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var got ConversionEvents
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	var kinds []string
+	for _, e := range got.Events {
+		kinds = append(kinds, e.Type)
+	}
+	want := []string{eventDiscovered, eventWarning, eventWarning, eventConverted, eventFailed}
+	if len(kinds) != len(want) {
+		t.Fatalf("event types = %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("event %d type = %s, want %s", i, kinds[i], want[i])
+		}
+	}
+
+	converted := got.Events[3]
+	if converted.Service != "web" || converted.TaskDefinition != aws.ToString(info.Source.TaskDefinitionArn) || converted.ECSServices[0] != "web" || converted.Outputs[0] != "web-deployment.yaml" {
+		t.Errorf("converted event = %+v", converted)
+	}
+	if got.Events[2].Message != "Unconverted field volumes" {
+		t.Errorf("unmapped warning = %q", got.Events[2].Message)
+	}
+	if failed := got.Events[4]; failed.Service != "" || failed.Message != "access denied" {
+		t.Errorf("failed event = %+v", failed)
+	}
+	if !converted.Time.Equal(start.Add(4*time.Second)) || !got.FinishedAt.After(got.Events[4].Time) {
+		t.Errorf("converted at %s, finished at %s", converted.Time, got.FinishedAt)
+	}
+
+	// A nil recorder records nothing
+	var none *eventRecorder
+	none.discovered(info)
+	if err := none.write(dir); err != nil {
+		t.Errorf("nil write() error = %v", err)
+	}
+}
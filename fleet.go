@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"gopkg.in/yaml.v3"
+)
+
+// Fleet report files written into the fleet output directory
+const (
+	fleetReportFileName     = "fleet-report.md"
+	fleetReportJSONFileName = "fleet-report.json"
+)
+
+// defaultFleetConcurrency is the number of accounts converted at once
+const defaultFleetConcurrency = 4
+
+// FleetConfig lists the accounts of a --fleet conversion
+type FleetConfig struct {
+	// Concurrency is the number of accounts converted at once
+	Concurrency int            `yaml:"concurrency"`
+	Accounts    []FleetAccount `yaml:"accounts"`
+}
+
+// FleetAccount is an account converted with an assumed role
+type FleetAccount struct {
+	// Name is the account's output directory (default: the account ID of RoleARN)
+	Name       string `yaml:"name"`
+	RoleARN    string `yaml:"roleArn"`
+	ExternalID string `yaml:"externalId"`
+	// Region overrides --region for this account
+	Region string `yaml:"region"`
+	// Clusters are names or ARNs to convert; empty converts every cluster
+	Clusters []string `yaml:"clusters"`
+}
+
+// FleetClusterResult is the outcome of converting one cluster of the fleet
+type FleetClusterResult struct {
+	Account   string `json:"account"`
+	Region    string `json:"region"`
+	Cluster   string `json:"cluster,omitempty"`
+	OutputDir string `json:"outputDir,omitempty"`
+	Services  int    `json:"services"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FleetReport summarizes a --fleet conversion across accounts
+type FleetReport struct {
+	GeneratedAt string               `json:"generatedAt"`
+	Accounts    int                  `json:"accounts"`
+	Results     []FleetClusterResult `json:"results"`
+}
+
+// loadFleetConfig reads and validates a --fleet file
+func loadFleetConfig(path string) (*FleetConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet file %s: %w", path, err)
+	}
+
+	fleet := &FleetConfig{}
+	if err := yaml.Unmarshal(data, fleet); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet file %s: %w", path, err)
+	}
+	if len(fleet.Accounts) == 0 {
+		return nil, fmt.Errorf("fleet file %s lists no accounts", path)
+	}
+	if fleet.Concurrency < 0 {
+		return nil, fmt.Errorf("invalid concurrency %d in %s", fleet.Concurrency, path)
+	}
+	if fleet.Concurrency == 0 {
+		fleet.Concurrency = defaultFleetConcurrency
+	}
+
+	names := map[string]bool{}
+	for i := range fleet.Accounts {
+		account := &fleet.Accounts[i]
+		if account.RoleARN == "" {
+			return nil, fmt.Errorf("account %d in %s has no roleArn", i+1, path)
+		}
+		if account.Name == "" {
+			account.Name = extractAccountID(account.RoleARN)
+		}
+		if account.Name == "" || !isValidFilename(account.Name) {
+			return nil, fmt.Errorf("account %d in %s needs a name usable as a directory (roleArn %s)", i+1, path, account.RoleARN)
+		}
+		if names[account.Name] {
+			return nil, fmt.Errorf("account name %s is used twice in %s", account.Name, path)
+		}
+		names[account.Name] = true
+	}
+
+	return fleet, nil
+}
+
+// convertFleet converts the clusters of every fleet account concurrently, each
+// account into <cwd>/<account name>, and writes the fleet report into <cwd>
+func convertFleet(ctx context.Context, base aws.Config, fleet *FleetConfig, opts *runOptions) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current working directory: %w", err)
+	}
+
+	log.Printf("Converting %d account(s), %d at a time", len(fleet.Accounts), fleet.Concurrency)
+	results := make([][]FleetClusterResult, len(fleet.Accounts))
+	sem := make(chan struct{}, fleet.Concurrency)
+	var wg sync.WaitGroup
+	for i, account := range fleet.Accounts {
+		wg.Add(1)
+		go func(i int, account FleetAccount) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			accountOpts := *opts
+			accountOpts.outputRoot = filepath.Join(cwd, account.Name)
+			if opts.anonymizer != nil {
+				accountOpts.outputRoot = filepath.Join(cwd, opts.anonymizer.text(account.Name))
+			}
+			results[i] = convertFleetAccount(ctx, base, account, &accountOpts)
+		}(i, account)
+	}
+	wg.Wait()
+
+	report := &FleetReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Accounts:    len(fleet.Accounts),
+	}
+	for _, accountResults := range results {
+		report.Results = append(report.Results, accountResults...)
+	}
+	if opts.anonymizer != nil {
+		for i := range report.Results {
+			r := &report.Results[i]
+			r.Account = opts.anonymizer.text(r.Account)
+			r.OutputDir = opts.anonymizer.text(r.OutputDir)
+			r.Error = opts.anonymizer.text(r.Error)
+		}
+	}
+	if err := writeFleetReport(cwd, report); err != nil {
+		return err
+	}
+	log.Printf("Fleet report: %s", filepath.Join(cwd, fleetReportFileName))
+
+	var failed []string
+	for _, r := range report.Results {
+		if r.Error != "" {
+			failed = append(failed, strings.TrimSuffix(r.Account+"/"+r.Cluster, "/"))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("fleet conversion failed for %d of %d cluster(s): %s", len(failed), len(report.Results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// convertFleetAccount assumes the role of an account and converts its clusters
+// one after another. Account-level failures are returned as a single result.
+func convertFleetAccount(ctx context.Context, base aws.Config, account FleetAccount, opts *runOptions) []FleetClusterResult {
+	cfg := base.Copy()
+	if account.Region != "" {
+		cfg.Region = account.Region
+		opts.region = account.Region
+	}
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(base), account.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = "ecs2k8s"
+		if account.ExternalID != "" {
+			o.ExternalID = aws.String(account.ExternalID)
+		}
+	}))
+	accountFailure := func(err error) []FleetClusterResult {
+		log.Printf("Error: Account %s: %v", account.Name, err)
+		return []FleetClusterResult{{Account: account.Name, Region: cfg.Region, Error: err.Error()}}
+	}
+
+	ecsClient := ecs.NewFromConfig(cfg)
+	if err := validateAWSCredentials(ctx, ecsClient); err != nil {
+		return accountFailure(fmt.Errorf("failed to assume %s: %w", account.RoleARN, err))
+	}
+	clusterArns, err := listClusters(ctx, ecsClient)
+	if err != nil {
+		return accountFailure(fmt.Errorf("failed to list clusters: %w", err))
+	}
+
+	selected := clusterArns
+	if len(account.Clusters) > 0 {
+		selected = nil
+		for _, ref := range account.Clusters {
+			clusterArn, err := resolveClusterRef(ref, clusterArns)
+			if err != nil {
+				return accountFailure(err)
+			}
+			selected = append(selected, clusterArn)
+		}
+	}
+	if len(selected) == 0 {
+		log.Printf("Info: Account %s has no ECS clusters in %s", account.Name, cfg.Region)
+		return nil
+	}
+
+	var results []FleetClusterResult
+	for _, clusterArn := range selected {
+		outputDir := filepath.Join(opts.outputRoot, clusterOutputName(clusterArn, opts.outputNaming))
+		result := FleetClusterResult{
+			Account:   account.Name,
+			Region:    cfg.Region,
+			Cluster:   extractClusterName(clusterArn),
+			OutputDir: outputDir,
+		}
+		if err := convertCluster(ctx, cfg, ecsClient, clusterArn, opts); err != nil {
+			log.Printf("Error: Account %s, cluster %s: %v", account.Name, result.Cluster, err)
+			result.Error = err.Error()
+		} else if state, err := readConversionState(outputDir); err == nil {
+			result.Services = len(state.Services)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// renderFleetReport renders the fleet report as Markdown
+func renderFleetReport(report *FleetReport) string {
+	results := append([]FleetClusterResult(nil), report.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Account != results[j].Account {
+			return results[i].Account < results[j].Account
+		}
+		return results[i].Cluster < results[j].Cluster
+	})
+
+	clusters, services, failed := 0, 0, 0
+	for _, r := range results {
+		if r.Cluster != "" {
+			clusters++
+		}
+		services += r.Services
+		if r.Error != "" {
+			failed++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# ecs2k8s Fleet Report\n\n")
+	fmt.Fprintf(&b, "- **Generated:** %s\n", report.GeneratedAt)
+	fmt.Fprintf(&b, "- **Accounts:** %d\n", report.Accounts)
+	fmt.Fprintf(&b, "- **Clusters:** %d\n", clusters)
+	fmt.Fprintf(&b, "- **Services converted:** %d\n", services)
+	fmt.Fprintf(&b, "- **Failures:** %d\n\n", failed)
+
+	fmt.Fprintf(&b, "| Account | Region | Cluster | Services | Status |\n")
+	fmt.Fprintf(&b, "|---------|--------|---------|----------|--------|\n")
+	for _, r := range results {
+		cluster, status := r.Cluster, "converted"
+		if cluster == "" {
+			cluster = "-"
+		} else if r.OutputDir != "" {
+			cluster = fmt.Sprintf("[%s](%s/%s/%s)", r.Cluster, r.Account, filepath.Base(r.OutputDir), reportFileName)
+		}
+		if r.Error != "" {
+			status = "failed: " + strings.ReplaceAll(r.Error, "|", "\\|")
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", r.Account, r.Region, cluster, r.Services, status)
+	}
+	return b.String()
+}
+
+// writeFleetReport writes the fleet report as Markdown and as JSON
+func writeFleetReport(dir string, report *FleetReport) error {
+	path := filepath.Join(dir, fleetReportFileName)
+	if err := os.WriteFile(path, []byte(renderFleetReport(report)), 0o644); err != nil {
+		return fmt.Errorf("failed to write fleet report %s: %w", path, err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fleet report: %w", err)
+	}
+	jsonPath := filepath.Join(dir, fleetReportJSONFileName)
+	if err := os.WriteFile(jsonPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write fleet report %s: %w", jsonPath, err)
+	}
+	return nil
+}
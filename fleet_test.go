@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadFleetConfig tests defaults and validation of --fleet files
+func TestLoadFleetConfig(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "fleet.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	fleet, err := loadFleetConfig(write(t, `
+accounts:
+  - roleArn: arn:aws:iam::111111111111:role/ecs2k8s
+  - name: payments-prod
+    roleArn: arn:aws:iam::222222222222:role/ecs2k8s
+    externalId: shared-secret
+    region: eu-west-1
+    clusters: [prod]
+`))
+	if err != nil {
+		t.Fatalf("loadFleetConfig() error = %v", err)
+	}
+	if fleet.Concurrency != defaultFleetConcurrency {
+		t.Errorf("Concurrency = %d, want %d", fleet.Concurrency, defaultFleetConcurrency)
+	}
+	if fleet.Accounts[0].Name != "111111111111" {
+		t.Errorf("default account name = %q, want the account ID", fleet.Accounts[0].Name)
+	}
+	if a := fleet.Accounts[1]; a.Name != "payments-prod" || a.Region != "eu-west-1" || a.ExternalID != "shared-secret" || len(a.Clusters) != 1 {
+		t.Errorf("account = %+v", a)
+	}
+
+	invalid := map[string]string{
+		"no accounts":    "accounts: []\n",
+		"missing role":   "accounts:\n  - name: a\n",
+		"duplicate name": "accounts:\n  - name: a\n    roleArn: arn:aws:iam::111111111111:role/x\n  - name: a\n    roleArn: arn:aws:iam::222222222222:role/x\n",
+		"invalid name":   "accounts:\n  - name: a/b\n    roleArn: arn:aws:iam::111111111111:role/x\n",
+		"no account id":  "accounts:\n  - roleArn: ecs2k8s\n",
+		"negative":       "concurrency: -1\naccounts:\n  - roleArn: arn:aws:iam::111111111111:role/x\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadFleetConfig(write(t, content)); err == nil {
+				t.Errorf("loadFleetConfig() succeeded, want error")
+			}
+		})
+	}
+}
+
+// TestRenderFleetReport tests the fleet totals and per-cluster rows
+func TestRenderFleetReport(t *testing.T) {
+	report := &FleetReport{
+		GeneratedAt: "2026-01-01T00:00:00Z",
+		Accounts:    2,
+		Results: []FleetClusterResult{
+			{Account: "shared", Region: "us-east-1", Error: "failed to assume role"},
+			{Account: "payments", Region: "us-east-1", Cluster: "prod", OutputDir: "/out/payments/prod", Services: 4},
+			{Account: "payments", Region: "us-east-1", Cluster: "api", OutputDir: "/out/payments/api", Error: "no task definitions | were converted"},
+		},
+	}
+
+	got := renderFleetReport(report)
+	for _, want := range []string{
+		"- **Clusters:** 2\n",
+		"- **Services converted:** 4\n",
+		"- **Failures:** 2\n",
+		"| payments | us-east-1 | [prod](payments/prod/conversion-report.md) | 4 | converted |",
+		"| payments | us-east-1 | [api](payments/api/conversion-report.md) | 0 | failed: no task definitions \\| were converted |",
+		"| shared | us-east-1 | - | 0 | failed: failed to assume role |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "[api]") > strings.Index(got, "[prod]") {
+		t.Errorf("rows are not sorted by account and cluster:\n%s", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// grafanaDashboardFileName is the starter dashboard written into the output
+// directory (--grafana-dashboard)
+const grafanaDashboardFileName = "grafana-dashboard.json"
+
+// grafanaPodSelector matches the pods of the selected Deployment
+var grafanaPodSelector = `namespace="$namespace", pod=~"` + deploymentPodPattern("$service") + `"`
+
+// grafanaPanel is a time series panel of the starter dashboard
+type grafanaPanel struct {
+	Title string
+	Unit  string
+	// Queries are PromQL expressions by legend
+	Queries [][2]string
+}
+
+// grafanaPanels mirror the metrics of the ECS console's service view: CPU,
+// memory, restarts (ECS task stops) and running versus desired tasks
+var grafanaPanels = []grafanaPanel{
+	{
+		Title: "CPU usage (cores)",
+		Unit:  "short",
+		Queries: [][2]string{
+			{"{{pod}}", `sum by (pod) (rate(container_cpu_usage_seconds_total{` + grafanaPodSelector + `, container!=""}[5m]))`},
+			{"limit {{pod}}", `sum by (pod) (kube_pod_container_resource_limits{` + grafanaPodSelector + `, resource="cpu"})`},
+		},
+	},
+	{
+		Title: "Memory working set",
+		Unit:  "bytes",
+		Queries: [][2]string{
+			{"{{pod}}", `sum by (pod) (container_memory_working_set_bytes{` + grafanaPodSelector + `, container!=""})`},
+			{"limit {{pod}}", `sum by (pod) (kube_pod_container_resource_limits{` + grafanaPodSelector + `, resource="memory"})`},
+		},
+	},
+	{
+		Title: "Container restarts",
+		Unit:  "short",
+		Queries: [][2]string{
+			{"{{pod}}/{{container}}", `sum by (pod, container) (increase(kube_pod_container_status_restarts_total{` + grafanaPodSelector + `}[1h]))`},
+		},
+	},
+	{
+		Title: "Replicas",
+		Unit:  "short",
+		Queries: [][2]string{
+			{"desired", `kube_deployment_spec_replicas{namespace="$namespace", deployment="$service"}`},
+			{"available", `kube_deployment_status_replicas_available{namespace="$namespace", deployment="$service"}`},
+			{"unavailable", `kube_deployment_status_replicas_unavailable{namespace="$namespace", deployment="$service"}`},
+		},
+	},
+}
+
+// grafanaDashboard builds a Grafana dashboard with a service variable listing
+// the converted Deployments, reading cAdvisor and kube-state-metrics series
+// from a Prometheus data source
+func grafanaDashboard(clusterName string, taskDefInfos []*TaskDefInfo) map[string]interface{} {
+	var services []string
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Manifests.Deployment != nil {
+			services = append(services, taskDefInfo.Name)
+		}
+	}
+
+	var options []map[string]interface{}
+	for i, service := range services {
+		options = append(options, map[string]interface{}{"text": service, "value": service, "selected": i == 0})
+	}
+	var current map[string]interface{}
+	if len(services) > 0 {
+		current = map[string]interface{}{"text": services[0], "value": services[0]}
+	}
+
+	datasource := map[string]string{"type": "prometheus", "uid": "${datasource}"}
+	var panels []map[string]interface{}
+	for i, panel := range grafanaPanels {
+		var targets []map[string]interface{}
+		for j, query := range panel.Queries {
+			targets = append(targets, map[string]interface{}{
+				"refId":        string(rune('A' + j)),
+				"datasource":   datasource,
+				"expr":         query[1],
+				"legendFormat": query[0],
+			})
+		}
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      panel.Title,
+			"datasource": datasource,
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": (i % 2) * 12, "y": (i / 2) * 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": panel.Unit},
+				"overrides": []interface{}{},
+			},
+			"targets": targets,
+		})
+	}
+
+	// Grafana limits dashboard UIDs to 40 characters
+	uid := safeLabelValue("ecs2k8s-" + strings.ToLower(clusterName))
+	if len(uid) > 40 {
+		uid = uid[:40]
+	}
+	return map[string]interface{}{
+		"title":         fmt.Sprintf("ECS services of %s", clusterName),
+		"uid":           uid,
+		"tags":          []string{managedByValue, clusterName},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+				{
+					"name":    "namespace",
+					"label":   "Namespace",
+					"type":    "textbox",
+					"query":   "default",
+					"current": map[string]string{"text": "default", "value": "default"},
+				},
+				{
+					"name":    "service",
+					"label":   "Service",
+					"type":    "custom",
+					"query":   strings.Join(services, ","),
+					"current": current,
+					"options": options,
+				},
+			},
+		},
+		"panels": panels,
+	}
+}
+
+// writeGrafanaDashboard writes the starter dashboard into the output directory
+func writeGrafanaDashboard(outputDir, clusterName string, taskDefInfos []*TaskDefInfo) error {
+	data, err := json.MarshalIndent(grafanaDashboard(clusterName, taskDefInfos), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode Grafana dashboard: %w", err)
+	}
+	path := filepath.Join(outputDir, grafanaDashboardFileName)
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write Grafana dashboard %s: %w", path, err)
+	}
+	return nil
+}
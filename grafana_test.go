@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestWriteGrafanaDashboard tests the starter dashboard of the converted services
+func TestWriteGrafanaDashboard(t *testing.T) {
+	infos := []*TaskDefInfo{
+		{Name: "web", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}},
+		{Name: "migrate"},
+		{Name: "worker", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}},
+	}
+
+	dir := t.TempDir()
+	if err := writeGrafanaDashboard(dir, "Prod", infos); err != nil {
+		t.Fatalf("writeGrafanaDashboard() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, grafanaDashboardFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dashboard struct {
+		UID        string `json:"uid"`
+		Templating struct {
+			List []struct {
+				Name  string `json:"name"`
+				Query string `json:"query"`
+			} `json:"list"`
+		} `json:"templating"`
+		Panels []struct {
+			Title   string `json:"title"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("dashboard is not JSON: %v", err)
+	}
+	if dashboard.UID != "ecs2k8s-prod" {
+		t.Errorf("uid = %q", dashboard.UID)
+	}
+	var services string
+	for _, variable := range dashboard.Templating.List {
+		if variable.Name == "service" {
+			services = variable.Query
+		}
+	}
+	if services != "web,worker" {
+		t.Errorf("service variable = %q, want the services with a Deployment", services)
+	}
+	if len(dashboard.Panels) != len(grafanaPanels) {
+		t.Fatalf("got %d panels, want %d", len(dashboard.Panels), len(grafanaPanels))
+	}
+	for _, panel := range dashboard.Panels {
+		for _, target := range panel.Targets {
+			if !strings.Contains(target.Expr, `namespace="$namespace"`) || !strings.Contains(target.Expr, "$service") {
+				t.Errorf("panel %s query %q is not scoped to the service", panel.Title, target.Expr)
+			}
+		}
+	}
+}
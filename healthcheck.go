@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// healthCheckTrafficPort is the target group health check port meaning "the target's port"
+const healthCheckTrafficPort = "traffic-port"
+
+// Sources of the probes of a container with both an ECS container health
+// check and a target group health check (--probe-source)
+const (
+	// probeSourceContainer makes the container health check the readiness and
+	// liveness probe
+	probeSourceContainer = "container"
+	// probeSourceALB makes the target group health check the readiness probe
+	probeSourceALB = "alb"
+	// probeSourceBoth makes the container health check the liveness probe and
+	// the target group health check the readiness probe, as on ECS
+	probeSourceBoth = "both"
+)
+
+// probeSources lists the accepted --probe-source values
+var probeSources = []string{probeSourceContainer, probeSourceALB, probeSourceBoth}
+
+// isValidProbeSource checks a --probe-source value
+func isValidProbeSource(source string) bool {
+	for _, s := range probeSources {
+		if source == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHealthCheckProbes turns the health check of each attached target group
+// into a readinessProbe on the container the target group routes to, so pods
+// only receive traffic when the load balancer would have considered them healthy
+func applyHealthCheckProbes(taskDefInfo *TaskDefInfo, attachments []targetGroupAttachment, targetGroups map[string]*targetGroupInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return
+	}
+
+	gracePeriod := healthCheckGracePeriod(taskDefInfo.Services)
+	probed := map[string]string{}
+
+	for _, a := range attachments {
+		tg, ok := targetGroups[a.TargetGroupArn]
+		if !ok || tg.Source == nil {
+			continue
+		}
+
+		var container *corev1.Container
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == a.ContainerName {
+				container = &podSpec.Containers[i]
+			}
+		}
+		if container == nil {
+			continue
+		}
+
+		if first, ok := probed[a.ContainerName]; ok {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s is in several target groups; its readinessProbe follows the health check of %s and ignores %s", a.ContainerName, first, tg.Name))
+			continue
+		}
+
+		probe, notes := healthCheckProbe(tg.Source, a.ContainerPort, gracePeriod)
+		for _, note := range notes {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Target group %s: %s", tg.Name, note))
+		}
+		if probe == nil {
+			continue
+		}
+
+		container.ReadinessProbe = probe
+		probed[a.ContainerName] = tg.Name
+		log.Printf("✓ Derived readinessProbe for %s/%s from target group %s", taskDefInfo.Name, a.ContainerName, tg.Name)
+	}
+
+	syncContainerProbes(taskDefInfo)
+}
+
+// healthCheckProbe translates a target group health check into a readiness
+// probe. It returns nil when health checks are disabled or the protocol has no
+// probe equivalent, and notes about semantics that could not be preserved.
+func healthCheckProbe(tg *elbv2types.TargetGroup, containerPort, gracePeriod int32) (*corev1.Probe, []string) {
+	var notes []string
+
+	if tg.HealthCheckEnabled != nil && !*tg.HealthCheckEnabled {
+		return nil, nil
+	}
+
+	port := containerPort
+	if p := aws.ToString(tg.HealthCheckPort); p != "" && p != healthCheckTrafficPort {
+		parsed, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return nil, []string{fmt.Sprintf("health check port %q is not a number, no readinessProbe generated", p)}
+		}
+		port = int32(parsed)
+	}
+
+	probe := &corev1.Probe{
+		InitialDelaySeconds: gracePeriod,
+		PeriodSeconds:       aws.ToInt32(tg.HealthCheckIntervalSeconds),
+		TimeoutSeconds:      aws.ToInt32(tg.HealthCheckTimeoutSeconds),
+		SuccessThreshold:    aws.ToInt32(tg.HealthyThresholdCount),
+		FailureThreshold:    aws.ToInt32(tg.UnhealthyThresholdCount),
+	}
+
+	switch tg.HealthCheckProtocol {
+	case elbv2types.ProtocolEnumHttp, elbv2types.ProtocolEnumHttps:
+		if strings.EqualFold(aws.ToString(tg.ProtocolVersion), "GRPC") {
+			probe.GRPC = &corev1.GRPCAction{Port: port}
+			notes = append(notes, fmt.Sprintf("gRPC health check %s became a grpc probe, which calls the standard grpc.health.v1 service instead", aws.ToString(tg.HealthCheckPath)))
+			break
+		}
+
+		path := aws.ToString(tg.HealthCheckPath)
+		if path == "" {
+			path = "/"
+		}
+		scheme := corev1.URISchemeHTTP
+		if tg.HealthCheckProtocol == elbv2types.ProtocolEnumHttps {
+			scheme = corev1.URISchemeHTTPS
+		}
+		probe.HTTPGet = &corev1.HTTPGetAction{
+			Path:   path,
+			Port:   intstr.FromInt32(port),
+			Scheme: scheme,
+		}
+
+		if tg.Matcher != nil && httpCodesOutsideProbeRange(aws.ToString(tg.Matcher.HttpCode)) {
+			notes = append(notes, fmt.Sprintf("health check accepts HTTP codes %s, but probes only treat 200-399 as healthy", aws.ToString(tg.Matcher.HttpCode)))
+		}
+	case elbv2types.ProtocolEnumTcp, elbv2types.ProtocolEnumTls, elbv2types.ProtocolEnumTcpUdp:
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt32(port)}
+	default:
+		return nil, []string{fmt.Sprintf("health check protocol %s has no probe equivalent, no readinessProbe generated", tg.HealthCheckProtocol)}
+	}
+
+	return probe, notes
+}
+
+// applyContainerHealthChecks turns the ECS container health checks into exec
+// probes. ECS replaces a task whose essential container is unhealthy, like a
+// failing livenessProbe, while the load balancer only stops routing to an
+// unhealthy target, like a failing readinessProbe. A container without a
+// target group health check gets its health check as both probes; otherwise
+// the source decides which check becomes which probe. It runs after the target
+// group health checks became readiness probes.
+func applyContainerHealthChecks(taskDefInfo *TaskDefInfo, source string) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || taskDefInfo.Source == nil {
+		return
+	}
+
+	worker := isWorker(taskDefInfo)
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		if def.HealthCheck == nil || len(def.HealthCheck.Command) == 0 {
+			continue
+		}
+		name := sanitizeName(aws.ToString(def.Name))
+		var container *corev1.Container
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == name {
+				container = &podSpec.Containers[i]
+			}
+		}
+		if container == nil {
+			continue
+		}
+
+		probe, err := containerHealthCheckProbe(def.HealthCheck)
+		if err != nil {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: %v, no probe generated", name, err))
+			continue
+		}
+		if container.ReadinessProbe == nil {
+			container.LivenessProbe = probe
+			// Workers receive no traffic to hold back from unready pods
+			if !worker {
+				container.ReadinessProbe = probe.DeepCopy()
+			}
+			continue
+		}
+
+		switch source {
+		case probeSourceContainer:
+			container.LivenessProbe = probe
+			container.ReadinessProbe = probe.DeepCopy()
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: the container health check replaces the target group health check as readinessProbe (--probe-source=%s)", name, source))
+		case probeSourceALB:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: the container health check is dropped for the target group health check, and failing pods are no longer restarted (--probe-source=%s)", name, source))
+		default:
+			container.LivenessProbe = probe
+		}
+	}
+
+	syncContainerProbes(taskDefInfo)
+}
+
+// containerHealthCheckProbe translates an ECS container health check into an
+// exec probe. CMD-SHELL commands run through /bin/sh, which the image must
+// provide, as on ECS.
+func containerHealthCheckProbe(hc *types.HealthCheck) (*corev1.Probe, error) {
+	var command []string
+	switch hc.Command[0] {
+	case "CMD":
+		command = hc.Command[1:]
+	case "CMD-SHELL":
+		command = []string{"/bin/sh", "-c", strings.Join(hc.Command[1:], " ")}
+	default:
+		return nil, fmt.Errorf("health check command %q does not start with CMD or CMD-SHELL", strings.Join(hc.Command, " "))
+	}
+	if len(command) == 0 {
+		return nil, fmt.Errorf("health check has an empty command")
+	}
+
+	// Unset values take the ECS defaults, which differ from the probe defaults
+	orDefault := func(value *int32, def int32) int32 {
+		if value == nil || *value == 0 {
+			return def
+		}
+		return *value
+	}
+	return &corev1.Probe{
+		ProbeHandler:        corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: command}},
+		InitialDelaySeconds: aws.ToInt32(hc.StartPeriod),
+		PeriodSeconds:       orDefault(hc.Interval, 30),
+		TimeoutSeconds:      orDefault(hc.Timeout, 5),
+		FailureThreshold:    orDefault(hc.Retries, 3),
+	}, nil
+}
+
+// healthCheckGracePeriod returns the longest ECS health check grace period of
+// the services, used as the probe's initial delay
+func healthCheckGracePeriod(services []types.Service) int32 {
+	var grace int32
+	for _, svc := range services {
+		if g := aws.ToInt32(svc.HealthCheckGracePeriodSeconds); g > grace {
+			grace = g
+		}
+	}
+	return grace
+}
+
+// httpCodesOutsideProbeRange reports whether an ELB matcher such as "200",
+// "200-499" or "200,404" accepts codes an httpGet probe would treat as failures
+func httpCodesOutsideProbeRange(codes string) bool {
+	for _, part := range strings.Split(codes, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(part, "-")
+		if !isRange {
+			high = low
+		}
+		lo, errLow := strconv.Atoi(strings.TrimSpace(low))
+		hi, errHigh := strconv.Atoi(strings.TrimSpace(high))
+		if errLow != nil || errHigh != nil {
+			continue
+		}
+		if lo < 200 || hi > 399 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestHealthCheckProbe tests translating target group health checks into readiness probes
+func TestHealthCheckProbe(t *testing.T) {
+	httpTG := &elbv2types.TargetGroup{
+		HealthCheckProtocol:        elbv2types.ProtocolEnumHttp,
+		HealthCheckPath:            aws.String("/health"),
+		HealthCheckPort:            aws.String(healthCheckTrafficPort),
+		HealthCheckIntervalSeconds: aws.Int32(30),
+		HealthCheckTimeoutSeconds:  aws.Int32(5),
+		HealthyThresholdCount:      aws.Int32(3),
+		UnhealthyThresholdCount:    aws.Int32(2),
+		Matcher:                    &elbv2types.Matcher{HttpCode: aws.String("200-499")},
+	}
+
+	probe, notes := healthCheckProbe(httpTG, 8080, 60)
+	if probe == nil || probe.HTTPGet == nil {
+		t.Fatalf("healthCheckProbe() = %+v, want httpGet", probe)
+	}
+	if probe.HTTPGet.Path != "/health" || probe.HTTPGet.Port.IntValue() != 8080 {
+		t.Errorf("httpGet = %s:%d, want /health:8080", probe.HTTPGet.Path, probe.HTTPGet.Port.IntValue())
+	}
+	if probe.PeriodSeconds != 30 || probe.TimeoutSeconds != 5 || probe.SuccessThreshold != 3 || probe.FailureThreshold != 2 || probe.InitialDelaySeconds != 60 {
+		t.Errorf("probe timings = %+v", probe)
+	}
+	if len(notes) != 1 {
+		t.Errorf("notes = %v, want a note about the 200-499 matcher", notes)
+	}
+
+	tcpTG := &elbv2types.TargetGroup{
+		HealthCheckProtocol: elbv2types.ProtocolEnumTcp,
+		HealthCheckPort:     aws.String("9000"),
+	}
+	probe, _ = healthCheckProbe(tcpTG, 8080, 0)
+	if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != 9000 {
+		t.Errorf("healthCheckProbe(tcp) = %+v, want tcpSocket 9000", probe)
+	}
+
+	disabled := &elbv2types.TargetGroup{HealthCheckEnabled: aws.Bool(false), HealthCheckProtocol: elbv2types.ProtocolEnumHttp}
+	if probe, _ := healthCheckProbe(disabled, 8080, 0); probe != nil {
+		t.Errorf("healthCheckProbe(disabled) = %+v, want nil", probe)
+	}
+}
+
+// TestHTTPCodesOutsideProbeRange tests ELB matcher parsing
+func TestHTTPCodesOutsideProbeRange(t *testing.T) {
+	tests := map[string]bool{
+		"200":         false,
+		"200-299":     false,
+		"200,302":     false,
+		"200-499":     true,
+		"200,404":     true,
+		"":            false,
+		"100-200,301": true,
+	}
+	for codes, want := range tests {
+		if got := httpCodesOutsideProbeRange(codes); got != want {
+			t.Errorf("httpCodesOutsideProbeRange(%q) = %v, want %v", codes, got, want)
+		}
+	}
+}
+
+// TestApplyContainerHealthChecks tests the precedence of container and target
+// group health checks
+func TestApplyContainerHealthChecks(t *testing.T) {
+	albProbe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/health"}}}
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Source: &types.TaskDefinition{ContainerDefinitions: []types.ContainerDefinition{
+				{Name: aws.String("web"), HealthCheck: &types.HealthCheck{Command: []string{"CMD-SHELL", "curl -f localhost/ || exit 1"}, Interval: aws.Int32(10)}},
+				{Name: aws.String("worker"), HealthCheck: &types.HealthCheck{Command: []string{"CMD", "pgrep", "worker"}}},
+			}},
+			Containers: []ContainerConfig{{Name: "web"}, {Name: "worker"}},
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "web", Ports: []corev1.ContainerPort{{ContainerPort: 80}}, ReadinessProbe: albProbe.DeepCopy()},
+					{Name: "worker"},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		source       string
+		webLiveness  bool
+		webReadiness string
+	}{
+		{probeSourceBoth, true, "httpGet"},
+		{probeSourceContainer, true, "exec"},
+		{probeSourceALB, false, "httpGet"},
+	}
+	for _, tt := range tests {
+		taskDefInfo := newInfo()
+		applyContainerHealthChecks(taskDefInfo, tt.source)
+		web, worker := taskDefInfo.Manifests.Deployment.Containers[0], taskDefInfo.Manifests.Deployment.Containers[1]
+
+		if (web.LivenessProbe != nil) != tt.webLiveness {
+			t.Errorf("%s: web livenessProbe = %+v", tt.source, web.LivenessProbe)
+		}
+		readiness := "httpGet"
+		if web.ReadinessProbe.Exec != nil {
+			readiness = "exec"
+		}
+		if readiness != tt.webReadiness {
+			t.Errorf("%s: web readinessProbe = %+v, want %s", tt.source, web.ReadinessProbe, tt.webReadiness)
+		}
+		// Without a target group health check the container check is both probes
+		if worker.LivenessProbe == nil || worker.ReadinessProbe == nil || strings.Join(worker.LivenessProbe.Exec.Command, " ") != "pgrep worker" {
+			t.Errorf("%s: worker probes = %+v, %+v", tt.source, worker.LivenessProbe, worker.ReadinessProbe)
+		}
+		if taskDefInfo.Containers[0].LivenessProbe != web.LivenessProbe {
+			t.Errorf("%s: container config probes were not synced", tt.source)
+		}
+	}
+
+	probe, err := containerHealthCheckProbe(&types.HealthCheck{Command: []string{"CMD-SHELL", "curl -f localhost/"}, Interval: aws.Int32(10)})
+	if err != nil || strings.Join(probe.Exec.Command, "|") != "/bin/sh|-c|curl -f localhost/" {
+		t.Fatalf("containerHealthCheckProbe() = %+v, %v", probe, err)
+	}
+	if probe.PeriodSeconds != 10 || probe.TimeoutSeconds != 5 || probe.FailureThreshold != 3 {
+		t.Errorf("probe timings = %d/%d/%d, want the ECS defaults", probe.PeriodSeconds, probe.TimeoutSeconds, probe.FailureThreshold)
+	}
+	if _, err := containerHealthCheckProbe(&types.HealthCheck{Command: []string{"NONE"}}); err == nil {
+		t.Error("containerHealthCheckProbe() accepted a command without CMD or CMD-SHELL")
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // HelmChart represents a Helm chart structure
@@ -39,13 +41,26 @@ type ChartYAML struct {
 	Keywords    []string            `yaml:"keywords,omitempty"`
 }
 
+// helmChartOptions holds the optional features of a generated Helm chart
+type helmChartOptions struct {
+	// overrides are applied to the generated values.yaml (--values-override)
+	overrides valuesOverrides
+	// tests adds helm-unittest suites of the services (--helm-tests)
+	tests bool
+	// byFamily groups the services by task definition family in values.yaml
+	// (--helm-group-by-family)
+	byFamily bool
+}
+
 // createHelmChart creates a Helm chart from the task definition
-func createHelmChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir string) error {
+func createHelmChart(model *ConversionModel, outputDir string, opts helmChartOptions) error {
+	clusterName, taskDefInfos := model.Cluster, model.infos()
 	if !strings.Contains(outputDir, clusterName) {
 		outputDir = filepath.Join(outputDir, clusterName)
 	}
 
-	helmChartPath := filepath.Join(outputDir, "helm", clusterName)
+	chartName := helmChartName(clusterName)
+	helmChartPath := filepath.Join(outputDir, "helm", chartName)
 
 	// Create directory structure
 	directories := []string{
@@ -56,6 +71,8 @@ func createHelmChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir
 		filepath.Join(helmChartPath, "templates", "configmap"),
 		filepath.Join(helmChartPath, "templates", "secret"),
 		filepath.Join(helmChartPath, "templates", "serviceaccount"),
+		filepath.Join(helmChartPath, "templates", "rbac"),
+		filepath.Join(helmChartPath, "templates", "job"),
 	}
 
 	for _, dir := range directories {
@@ -66,29 +83,47 @@ func createHelmChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir
 	}
 
 	// Create Chart.yaml
-	if err := createChartYAML(helmChartPath, clusterName); err != nil {
+	if err := createChartYAML(helmChartPath, chartName, clusterName); err != nil {
 		return fmt.Errorf("failed to create Chart.yaml: %w", err)
 	}
 
 	// Create single values.yaml with all task definitions
-	if err := createCombinedValuesYAML(helmChartPath, taskDefInfos); err != nil {
+	if err := createCombinedValuesYAML(helmChartPath, model, opts.overrides, opts.byFamily); err != nil {
 		return fmt.Errorf("failed to create combined values.yaml: %w", err)
 	}
 
 	// Create Helm template files
-	if err := createHelmTemplates(helmChartPath, taskDefInfos); err != nil {
+	if err := createHelmTemplates(helmChartPath, taskDefInfos, opts.byFamily); err != nil {
 		return fmt.Errorf("failed to create helm templates: %w", err)
 	}
 
+	// Copy additional objects (custom resources) into the chart
+	if err := createHelmExtras(helmChartPath, taskDefInfos, opts.byFamily); err != nil {
+		return fmt.Errorf("failed to create helm extras: %w", err)
+	}
+
+	if opts.tests {
+		if err := createHelmTests(helmChartPath, taskDefInfos); err != nil {
+			return fmt.Errorf("failed to create helm tests: %w", err)
+		}
+	}
+
 	log.Printf("✓ Created Helm chart at: %s", helmChartPath)
 	return nil
 }
 
+// helmChartName returns the chart name of a cluster. Helm chart names are
+// lowercase alphanumerics and dashes; the chart directory and the
+// _helpers.tpl template names use the same name.
+func helmChartName(clusterName string) string {
+	return sanitizeName(clusterName)
+}
+
 // createChartYAML creates the Chart.yaml file
-func createChartYAML(chartPath, clusterName string) error {
+func createChartYAML(chartPath, chartName, clusterName string) error {
 	chart := ChartYAML{
 		APIVersion:  "v2",
-		Name:        clusterName,
+		Name:        chartName,
 		Description: fmt.Sprintf("Helm chart for ECS cluster %s converted from AWS ECS to Kubernetes", clusterName),
 		Type:        "application",
 		Version:     "1.0.0",
@@ -116,54 +151,32 @@ func createChartYAML(chartPath, clusterName string) error {
 	return nil
 }
 
-// createCombinedValuesYAML creates a single values.yaml file with all task definitions
-func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) error {
+// createCombinedValuesYAML creates a single values.yaml file with all task
+// definitions, with the --values-override settings applied. With byFamily the
+// services are nested under their task definition family.
+func createCombinedValuesYAML(chartPath string, model *ConversionModel, overrides valuesOverrides, byFamily bool) error {
+	clusterName, taskDefInfos := model.Cluster, model.infos()
 	values := map[string]interface{}{
 		"defaultNamespace": "default",
 		"defaultReplicas":  1,
 	}
+	// The app.kubernetes.io/name label keeps the cluster name the chart name was sanitized from
+	if filepath.Base(chartPath) != clusterName {
+		values["nameOverride"] = safeLabelValue(clusterName)
+	}
 
 	// Build configurations for each service
 	services := map[string]interface{}{}
 
-	for _, taskDefInfo := range taskDefInfos {
-		serviceName := taskDefInfo.Name
+	for _, workload := range model.Workloads {
+		taskDefInfo := workload.Info
+		serviceName := workload.Name
 
 		// Build container configurations for this service
 		var containers []map[string]interface{}
 
 		for _, container := range taskDefInfo.Containers {
-			containerConfig := map[string]interface{}{
-				"name":  container.Name,
-				"image": container.Image,
-				"resources": map[string]interface{}{
-					"limits": map[string]interface{}{
-						"cpu":    container.CPU,
-						"memory": container.Memory,
-					},
-					"requests": map[string]interface{}{
-						"cpu":    container.CPU,
-						"memory": container.Memory,
-					},
-				},
-			}
-
-			if len(container.Ports) > 0 {
-				containerConfig["ports"] = container.Ports
-			}
-
-			if len(container.EnvVars) > 0 {
-				envList := []map[string]string{}
-				for key, value := range container.EnvVars {
-					envList = append(envList, map[string]string{
-						"name":  key,
-						"value": value,
-					})
-				}
-				containerConfig["env"] = envList
-			}
-
-			containers = append(containers, containerConfig)
+			containers = append(containers, helmContainerValues(container))
 		}
 
 		// Build service configuration with namespace and replicas
@@ -174,20 +187,104 @@ func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) err
 		}
 
 		// Add IAM role ARN if available (for IRSA support)
-		if taskDefInfo.TaskRoleArn != "" {
-			serviceConfig["iamRoleArn"] = taskDefInfo.TaskRoleArn
-			serviceConfig["serviceAccount"] = map[string]interface{}{
-				"annotations": map[string]string{
-					"eks.amazonaws.com/role-arn": taskDefInfo.TaskRoleArn,
-				},
+		if roleArn := workload.RoleArn; roleArn != "" {
+			serviceConfig["iamRoleArn"] = roleArn
+			// A --profile may bind the role with another identity annotation
+			annotations := map[string]string{irsaAnnotation: roleArn}
+			if sa := taskDefInfo.Manifests.ServiceAccount; sa != nil && len(sa.Annotations) > 0 {
+				annotations = sa.Annotations
 			}
-		} else if taskDefInfo.ExecutionRoleArn != "" {
-			serviceConfig["iamRoleArn"] = taskDefInfo.ExecutionRoleArn
 			serviceConfig["serviceAccount"] = map[string]interface{}{
-				"annotations": map[string]string{
-					"eks.amazonaws.com/role-arn": taskDefInfo.ExecutionRoleArn,
-				},
+				"annotations": annotations,
+			}
+		}
+
+		// Carry over host/process namespace sharing from ECS ipcMode/pidMode
+		if podSpec := taskDefInfo.Manifests.Deployment; podSpec != nil {
+			if podSpec.Affinity != nil {
+				serviceConfig["affinity"] = toSerializable(podSpec.Affinity)
+			}
+			if len(podSpec.NodeSelector) > 0 {
+				serviceConfig["nodeSelector"] = podSpec.NodeSelector
+			}
+			if len(podSpec.Tolerations) > 0 {
+				serviceConfig["tolerations"] = toSerializable(podSpec.Tolerations)
+			}
+			if len(podSpec.TopologySpreadConstraints) > 0 {
+				serviceConfig["topologySpreadConstraints"] = toSerializable(podSpec.TopologySpreadConstraints)
+			}
+			if taskDefInfo.Manifests.ProgressDeadlineSeconds != nil {
+				serviceConfig["progressDeadlineSeconds"] = *taskDefInfo.Manifests.ProgressDeadlineSeconds
+			}
+			if len(taskDefInfo.Manifests.DeploymentAnnotations) > 0 {
+				serviceConfig["annotations"] = taskDefInfo.Manifests.DeploymentAnnotations
+			}
+			// Databases of --smart-templates keep their data on a claim per pod
+			if statefulSet := taskDefInfo.Manifests.StatefulSet; statefulSet != nil {
+				serviceConfig["kind"] = "StatefulSet"
+				serviceConfig["statefulSet"] = map[string]interface{}{
+					"serviceName":          statefulSet.ServiceName,
+					"volumeClaimTemplates": statefulSet.volumeClaimTemplates(),
+				}
+			}
+			// Claims and volumes are mounted as in the pod spec
+			if len(podSpec.Volumes) > 0 {
+				serviceConfig["volumes"] = toSerializable(podSpec.Volumes)
+			}
+			for _, podContainer := range podSpec.Containers {
+				for _, c := range containers {
+					if c["name"] == podContainer.Name && len(podContainer.VolumeMounts) > 0 {
+						c["volumeMounts"] = toSerializable(podContainer.VolumeMounts)
+					}
+				}
+			}
+			// Checksums are computed by the template from the rendered values
+			podAnnotations := map[string]string{}
+			for key, value := range taskDefInfo.Manifests.PodAnnotations {
+				if !isChecksumAnnotation(key) {
+					podAnnotations[key] = value
+				}
+			}
+			if len(podAnnotations) > 0 {
+				serviceConfig["podAnnotations"] = podAnnotations
+			}
+			if len(taskDefInfo.Manifests.PodLabels) > 0 {
+				serviceConfig["podLabels"] = taskDefInfo.Manifests.PodLabels
+			}
+			if podSpec.HostIPC {
+				serviceConfig["hostIPC"] = true
+			}
+			if podSpec.HostPID {
+				serviceConfig["hostPID"] = true
 			}
+			if podSpec.ShareProcessNamespace != nil && *podSpec.ShareProcessNamespace {
+				serviceConfig["shareProcessNamespace"] = true
+			}
+			if len(podSpec.HostAliases) > 0 {
+				serviceConfig["hostAliases"] = toSerializable(podSpec.HostAliases)
+			}
+		}
+
+		// ECS task definition tags, rendered as Deployment labels
+		if labels := tagLabels(taskDefInfo.Tags); len(labels) > 0 {
+			serviceConfig["tags"] = labels
+		}
+
+		// One-shot migration containers, run by the Job hook template
+		if job := taskDefInfo.Manifests.Migration; job != nil {
+			var jobContainers []map[string]interface{}
+			for _, container := range job.Containers {
+				jobContainers = append(jobContainers, helmContainerValues(container))
+			}
+			serviceConfig["migration"] = map[string]interface{}{
+				"hooks":      job.Hooks,
+				"containers": jobContainers,
+			}
+		}
+
+		// Add RBAC rules configured for the service's ServiceAccount
+		if rbac := rbacValues(taskDefInfo.Manifests.RBAC); rbac != nil {
+			serviceConfig["rbac"] = rbac
 		}
 
 		if len(taskDefInfo.Manifests.Services) > 0 {
@@ -200,6 +297,13 @@ func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) err
 			if len(svc.Spec.Ports) > 0 {
 				serviceMeta["port"] = svc.Spec.Ports[0].Port
 			}
+			// Ports added for target groups aren't container ports of the values
+			if extraPorts := helmExtraServicePorts(taskDefInfo); len(extraPorts) > 0 {
+				serviceMeta["extraPorts"] = extraPorts
+			}
+			if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+				serviceMeta["headless"] = true
+			}
 
 			serviceConfig["service"] = serviceMeta
 		}
@@ -207,10 +311,24 @@ func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) err
 		services[serviceName] = serviceConfig
 	}
 
-	values["services"] = services
+	if byFamily {
+		values["families"] = familyValues(model.Workloads, services)
+	} else {
+		values["services"] = services
+	}
+
+	// Labels and annotations from --label/--annotation, keyed by kind
+	if objectMetadata := conversionMetadata(taskDefInfos).helmValues(); len(objectMetadata) > 0 {
+		values["objectMetadata"] = objectMetadata
+	}
+
+	values, err := overrideValues(values, overrides)
+	if err != nil {
+		return err
+	}
 
 	// Serialize to YAML with comments
-	data, err := yaml.Marshal(values)
+	data, err := marshalYAML(values)
 	if err != nil {
 		return fmt.Errorf("failed to marshal values.yaml: %w", err)
 	}
@@ -220,6 +338,8 @@ func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) err
 #
 # This file contains configurations for all services in the cluster.
 # Each service is organized by name with its containers, resources, and service configuration.
+# Container images are split into repository, tag and optional digest; a digest
+# takes precedence over the tag when both are set, so clear it to roll out a new tag.
 #
 # Example usage:
 #   helm install my-release ./ -f values.yaml
@@ -238,26 +358,165 @@ func createCombinedValuesYAML(chartPath string, taskDefInfos []*TaskDefInfo) err
 	return nil
 }
 
-// CreateHelmChart is a wrapper for createHelmChart with reordered parameters
-func CreateHelmChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir string) error {
-	return createHelmChart(clusterName, taskDefInfos, outputDir)
+// helmContainerValues returns the values of a container rendered by the
+// Deployment and migration Job templates
+func helmContainerValues(container ContainerConfig) map[string]interface{} {
+	requestCPU, requestMemory := container.CPU, container.Memory
+	if container.RequestCPU != "" {
+		requestCPU = container.RequestCPU
+	}
+	if container.RequestMemory != "" {
+		requestMemory = container.RequestMemory
+	}
+
+	containerConfig := map[string]interface{}{
+		"name":            container.Name,
+		"image":           imageValues(container.Image),
+		"imagePullPolicy": container.ImagePullPolicy,
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{
+				"cpu":    container.CPU,
+				"memory": container.Memory,
+			},
+			"requests": map[string]interface{}{
+				"cpu":    requestCPU,
+				"memory": requestMemory,
+			},
+		},
+	}
+
+	if len(container.Ports) > 0 {
+		containerConfig["ports"] = container.Ports
+	}
+
+	if container.LivenessProbe != nil {
+		containerConfig["livenessProbe"] = toSerializable(container.LivenessProbe)
+	}
+	if container.ReadinessProbe != nil {
+		containerConfig["readinessProbe"] = toSerializable(container.ReadinessProbe)
+	}
+	if container.SecurityContext != nil {
+		containerConfig["securityContext"] = toSerializable(container.SecurityContext)
+	}
+	if container.Stdin {
+		containerConfig["stdin"] = true
+	}
+	if container.TTY {
+		containerConfig["tty"] = true
+	}
+
+	if len(container.EnvVars) > 0 {
+		// Sorted by name, so regenerating the chart doesn't reorder values.yaml
+		names := make([]string, 0, len(container.EnvVars))
+		for name := range container.EnvVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		envList := make([]map[string]string, 0, len(names))
+		for _, name := range names {
+			envList = append(envList, map[string]string{
+				"name":  name,
+				"value": container.EnvVars[name],
+			})
+		}
+		containerConfig["env"] = envList
+	}
+
+	if len(container.Command) > 0 {
+		containerConfig["command"] = container.Command
+	}
+	if len(container.Args) > 0 {
+		containerConfig["args"] = container.Args
+	}
+	return containerConfig
+}
+
+// imageValues splits an image reference into repository, tag and digest values
+// so CI can override the tag per environment
+func imageValues(image string) map[string]interface{} {
+	repository, tag, digest := splitImageReference(image)
+	values := map[string]interface{}{
+		"repository": repository,
+		"tag":        tag,
+	}
+	if digest != "" {
+		values["digest"] = digest
+	}
+	return values
+}
+
+// splitImageReference splits "registry/repo:tag@sha256:..." into its parts
+func splitImageReference(image string) (repository, tag, digest string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		digest = repository[i+1:]
+		repository = repository[:i]
+	}
+
+	// A colon after the last slash separates the tag (a registry port comes before it)
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		tag = repository[i+1:]
+		repository = repository[:i]
+	}
+
+	return repository, tag, digest
+}
+
+// CreateHelmChart creates a Helm chart from the converted services of a cluster
+func CreateHelmChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir string, opts helmChartOptions) error {
+	model, err := newConversionModel(clusterName, taskDefInfos)
+	if err != nil {
+		return err
+	}
+	return createHelmChart(model, outputDir, opts)
 }
 
 // createHelmTemplates creates the Helm template files
-func createHelmTemplates(chartPath string, taskDefInfos []*TaskDefInfo) error {
+func createHelmTemplates(chartPath string, taskDefInfos []*TaskDefInfo, byFamily bool) error {
+	apiVersions := conversionAPIVersions(taskDefInfos)
+	kinds := conversionKinds(taskDefInfos)
+	servicesValues := helmServicesValues(filepath.Base(chartPath), byFamily)
+
 	// Create deployment template - creates deployments for each service
-	deploymentTemplate := `{{- range $serviceName, $serviceConfig := .Values.services }}
----
-apiVersion: apps/v1
-kind: Deployment
+	workloadGuard, workloadGuardEnd := kinds.helmGuard("$kind", "Deployment", "StatefulSet")
+	deploymentTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
+{{- if $serviceConfig.containers }}
+{{- $kind := $serviceConfig.kind | default "Deployment" }}
+` + workloadGuard + `---
+apiVersion: ` + apiVersions.resolve("Deployment", apiVersionApps) + `
+kind: {{ $kind }}
 metadata:
   name: {{ $serviceName }}
   namespace: {{ $serviceConfig.namespace | default $.Values.defaultNamespace }}
   labels:
     app: {{ $serviceName }}
     {{- include "` + filepath.Base(chartPath) + `.labels" . | nindent 4 }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ $kind) }}
+    {{- . | nindent 4 }}
+    {{- end }}
+    {{- with $serviceConfig.tags }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
+  {{- $annotations := include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ $kind) }}
+  {{- if or $annotations $serviceConfig.annotations }}
+  annotations:
+    {{- with $serviceConfig.annotations }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
+    {{- with $annotations }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- end }}
 spec:
   replicas: {{ $serviceConfig.replicas | default $.Values.defaultReplicas }}
+  {{- with $serviceConfig.progressDeadlineSeconds }}
+  progressDeadlineSeconds: {{ . }}
+  {{- end }}
+  {{- with $serviceConfig.statefulSet }}
+  serviceName: {{ .serviceName }}
+  volumeClaimTemplates:
+    {{- toYaml .volumeClaimTemplates | nindent 4 }}
+  {{- end }}
   selector:
     matchLabels:
       app: {{ $serviceName }}
@@ -266,15 +525,60 @@ spec:
       labels:
         app: {{ $serviceName }}
         {{- include "` + filepath.Base(chartPath) + `.selectorLabels" . | nindent 8 }}
+        {{- with $serviceConfig.podLabels }}
+        {{- toYaml . | nindent 8 }}
+        {{- end }}
+      {{- $env := list }}
+      {{- range $serviceConfig.containers }}
+      {{- $env = append $env .env }}
+      {{- end }}
+      annotations:
+        checksum/config: {{ toJson $env | sha256sum }}
+        {{- with $serviceConfig.podAnnotations }}
+        {{- toYaml . | nindent 8 }}
+        {{- end }}
     spec:
       {{- if or $serviceConfig.serviceAccount $serviceConfig.iamRoleArn }}
       serviceAccountName: {{ $serviceName }}-sa
       {{- end }}
+      {{- if $serviceConfig.hostIPC }}
+      hostIPC: true
+      {{- end }}
+      {{- if $serviceConfig.hostPID }}
+      hostPID: true
+      {{- end }}
+      {{- if $serviceConfig.shareProcessNamespace }}
+      shareProcessNamespace: true
+      {{- end }}
+      {{- with $serviceConfig.hostAliases }}
+      hostAliases:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with $serviceConfig.affinity }}
+      affinity:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with $serviceConfig.nodeSelector }}
+      nodeSelector:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with $serviceConfig.tolerations }}
+      tolerations:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with $serviceConfig.topologySpreadConstraints }}
+      topologySpreadConstraints:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with $serviceConfig.volumes }}
+      volumes:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
       containers:
       {{- range $serviceConfig.containers }}
       - name: {{ .name }}
-        image: {{ .image }}
-        imagePullPolicy: IfNotPresent
+        image: "{{ .image.repository }}{{ with .image.tag }}:{{ . }}{{ end }}{{ with .image.digest }}@{{ . }}{{ end }}"
+        imagePullPolicy: {{ .imagePullPolicy | default "IfNotPresent" }}
         {{- if .ports }}
         ports:
         {{- range .ports }}
@@ -289,12 +593,38 @@ spec:
           value: "{{ .value }}"
         {{- end }}
         {{- end }}
+        {{- with .livenessProbe }}
+        livenessProbe:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- with .readinessProbe }}
+        readinessProbe:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- with .securityContext }}
+        securityContext:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- if .stdin }}
+        stdin: true
+        {{- end }}
+        {{- if .tty }}
+        tty: true
+        {{- end }}
+        {{- with .volumeMounts }}
+        volumeMounts:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
         {{- if .resources }}
         resources:
           {{- if .resources.limits }}
           limits:
-            cpu: {{ .resources.limits.cpu }}
-            memory: {{ .resources.limits.memory }}
+            {{- with .resources.limits.cpu }}
+            cpu: {{ . }}
+            {{- end }}
+            {{- with .resources.limits.memory }}
+            memory: {{ . }}
+            {{- end }}
           {{- end }}
           {{- if .resources.requests }}
           requests:
@@ -303,6 +633,7 @@ spec:
           {{- end }}
         {{- end }}
       {{- end }}
+` + workloadGuardEnd + `{{- end }}
 {{- end }}
 `
 
@@ -314,10 +645,10 @@ spec:
 	log.Printf("Created deployment template at: %s", deploymentFile)
 
 	// Create service template - creates services for each service config
-	serviceTemplate := `{{- range $serviceName, $serviceConfig := .Values.services }}
+	serviceTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
 {{- if $serviceConfig.service }}
 ---
-apiVersion: v1
+apiVersion: ` + apiVersions.resolve("Service", apiVersionCore) + `
 kind: Service
 metadata:
   name: {{ $serviceName }}
@@ -325,8 +656,18 @@ metadata:
   labels:
     app: {{ $serviceName }}
     {{- include "` + filepath.Base(chartPath) + `.labels" . | nindent 4 }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ "Service") }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- with include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ "Service") }}
+  annotations:
+    {{- . | nindent 4 }}
+  {{- end }}
 spec:
   type: {{ $serviceConfig.service.type | default "ClusterIP" }}
+  {{- if $serviceConfig.service.headless }}
+  clusterIP: None
+  {{- end }}
   ports:
   {{- range $serviceConfig.containers }}
     {{- if .ports }}
@@ -337,25 +678,32 @@ spec:
     {{- end }}
     {{- end }}
   {{- end }}
+  {{- range $serviceConfig.service.extraPorts }}
+    - port: {{ . }}
+      targetPort: {{ . }}
+      protocol: TCP
+  {{- end }}
   selector:
     app: {{ $serviceName }}
 {{- end }}
 {{- end }}
 `
 
-	serviceFile := filepath.Join(chartPath, "templates", "service", "service.yaml")
-	if err := os.WriteFile(serviceFile, []byte(serviceTemplate), 0o644); err != nil {
-		return fmt.Errorf("failed to write service template: %w", err)
-	}
+	if kinds.allows("Service") {
+		serviceFile := filepath.Join(chartPath, "templates", "service", "service.yaml")
+		if err := os.WriteFile(serviceFile, []byte(serviceTemplate), 0o644); err != nil {
+			return fmt.Errorf("failed to write service template: %w", err)
+		}
 
-	log.Printf("Created service template at: %s", serviceFile)
+		log.Printf("Created service template at: %s", serviceFile)
+	}
 
 	// Create configmap template - creates configmaps for each service
-	configmapTemplate := `{{- range $serviceName, $serviceConfig := .Values.services }}
+	configmapTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
 {{- range $serviceConfig.containers }}
 {{- if .env }}
 ---
-apiVersion: v1
+apiVersion: ` + apiVersions.resolve("ConfigMap", apiVersionCore) + `
 kind: ConfigMap
 metadata:
   name: {{ $serviceName }}-{{ .name }}-config
@@ -363,6 +711,13 @@ metadata:
   labels:
     app: {{ $serviceName }}
     {{- include "` + filepath.Base(chartPath) + `.labels" . | nindent 4 }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ "ConfigMap") }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- with include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ "ConfigMap") }}
+  annotations:
+    {{- . | nindent 4 }}
+  {{- end }}
 data:
   {{- range .env }}
   {{ .name }}: "{{ .value }}"
@@ -372,18 +727,20 @@ data:
 {{- end }}
 `
 
-	configmapFile := filepath.Join(chartPath, "templates", "configmap", "configmap.yaml")
-	if err := os.WriteFile(configmapFile, []byte(configmapTemplate), 0o644); err != nil {
-		return fmt.Errorf("failed to write configmap template: %w", err)
-	}
+	if kinds.allows("ConfigMap") {
+		configmapFile := filepath.Join(chartPath, "templates", "configmap", "configmap.yaml")
+		if err := os.WriteFile(configmapFile, []byte(configmapTemplate), 0o644); err != nil {
+			return fmt.Errorf("failed to write configmap template: %w", err)
+		}
 
-	log.Printf("Created configmap template at: %s", configmapFile)
+		log.Printf("Created configmap template at: %s", configmapFile)
+	}
 
 	// Create ServiceAccount template for IRSA support
-	serviceAccountTemplate := `{{- range $serviceName, $serviceConfig := .Values.services }}
+	serviceAccountTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
 {{- if or $serviceConfig.serviceAccount $serviceConfig.iamRoleArn }}
 ---
-apiVersion: v1
+apiVersion: ` + apiVersions.resolve("ServiceAccount", apiVersionCore) + `
 kind: ServiceAccount
 metadata:
   name: {{ $serviceName }}-sa
@@ -391,25 +748,191 @@ metadata:
   labels:
     app: {{ $serviceName }}
     {{- include "` + filepath.Base(chartPath) + `.labels" . | nindent 4 }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ "ServiceAccount") }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- $objectAnnotations := include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ "ServiceAccount") }}
   {{- if $serviceConfig.serviceAccount }}
-  {{- if $serviceConfig.serviceAccount.annotations }}
+  {{- if or $serviceConfig.serviceAccount.annotations $objectAnnotations }}
   annotations:
-    {{- toYaml $serviceConfig.serviceAccount.annotations | nindent 4 }}
+    {{- with $serviceConfig.serviceAccount.annotations }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
+    {{- with $objectAnnotations }}
+    {{- . | nindent 4 }}
+    {{- end }}
   {{- end }}
   {{- else if $serviceConfig.iamRoleArn }}
   annotations:
     eks.amazonaws.com/role-arn: {{ $serviceConfig.iamRoleArn }}
+    {{- with $objectAnnotations }}
+    {{- . | nindent 4 }}
+    {{- end }}
   {{- end }}
 {{- end }}
 {{- end }}
 `
 
-	serviceAccountFile := filepath.Join(chartPath, "templates", "serviceaccount", "serviceaccount.yaml")
-	if err := os.WriteFile(serviceAccountFile, []byte(serviceAccountTemplate), 0o644); err != nil {
-		return fmt.Errorf("failed to write serviceaccount template: %w", err)
+	if kinds.allows("ServiceAccount") {
+		serviceAccountFile := filepath.Join(chartPath, "templates", "serviceaccount", "serviceaccount.yaml")
+		if err := os.WriteFile(serviceAccountFile, []byte(serviceAccountTemplate), 0o644); err != nil {
+			return fmt.Errorf("failed to write serviceaccount template: %w", err)
+		}
+
+		log.Printf("Created serviceaccount template at: %s", serviceAccountFile)
 	}
 
-	log.Printf("Created serviceaccount template at: %s", serviceAccountFile)
+	// Create RBAC template granting configured permissions to the ServiceAccount
+	roleGuard, roleGuardEnd := kinds.helmGuard("$kind", "ClusterRole", "Role")
+	bindingGuard, bindingGuardEnd := kinds.helmGuard(`(printf "%sBinding" $kind)`, "ClusterRoleBinding", "RoleBinding")
+	rbacTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
+{{- with $serviceConfig.rbac }}
+{{- $namespace := $serviceConfig.namespace | default $.Values.defaultNamespace }}
+{{- $kind := ternary "ClusterRole" "Role" (.clusterRoleBinding | default false) }}
+` + roleGuard + `---
+apiVersion: ` + apiVersions.resolve("Role", apiVersionRBAC) + `
+kind: {{ $kind }}
+metadata:
+  name: {{ $serviceName }}-role
+  {{- if not .clusterRoleBinding }}
+  namespace: {{ $namespace }}
+  {{- end }}
+  labels:
+    app: {{ $serviceName }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ $kind) }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- with include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ $kind) }}
+  annotations:
+    {{- . | nindent 4 }}
+  {{- end }}
+rules:
+  {{- toYaml .rules | nindent 2 }}
+` + roleGuardEnd + bindingGuard + `---
+apiVersion: ` + apiVersions.resolve("RoleBinding", apiVersionRBAC) + `
+kind: {{ $kind }}Binding
+metadata:
+  name: {{ $serviceName }}-rolebinding
+  {{- if not .clusterRoleBinding }}
+  namespace: {{ $namespace }}
+  {{- end }}
+  labels:
+    app: {{ $serviceName }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ (printf "%sBinding" $kind)) }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  {{- with include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ (printf "%sBinding" $kind)) }}
+  annotations:
+    {{- . | nindent 4 }}
+  {{- end }}
+subjects:
+  - kind: ServiceAccount
+    name: {{ $serviceName }}-sa
+    namespace: {{ $namespace }}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: {{ $kind }}
+  name: {{ $serviceName }}-role
+` + bindingGuardEnd + `{{- end }}
+{{- end }}
+`
+
+	rbacFile := filepath.Join(chartPath, "templates", "rbac", "rbac.yaml")
+	if err := os.WriteFile(rbacFile, []byte(rbacTemplate), 0o644); err != nil {
+		return fmt.Errorf("failed to write rbac template: %w", err)
+	}
+
+	log.Printf("Created rbac template at: %s", rbacFile)
+
+	// Create migration Job template, run as a Helm hook before the services
+	// are installed or upgraded. On pre-install the chart's ServiceAccount does
+	// not exist yet, so the Job only uses it on later hooks.
+	jobTemplate := `{{- range $serviceName, $serviceConfig := ` + servicesValues + ` }}
+{{- with $serviceConfig.migration }}
+---
+apiVersion: ` + apiVersions.resolve("Job", apiVersionBatch) + `
+kind: Job
+metadata:
+  name: {{ $serviceName }}-migration
+  namespace: {{ $serviceConfig.namespace | default $.Values.defaultNamespace }}
+  labels:
+    app: {{ $serviceName }}
+    {{- include "` + filepath.Base(chartPath) + `.labels" $ | nindent 4 }}
+    {{- with include "` + filepath.Base(chartPath) + `.objectLabels" (list $ "Job") }}
+    {{- . | nindent 4 }}
+    {{- end }}
+  annotations:
+    helm.sh/hook: {{ join "," .hooks }}
+    helm.sh/hook-weight: "-5"
+    helm.sh/hook-delete-policy: before-hook-creation
+    {{- with include "` + filepath.Base(chartPath) + `.objectAnnotations" (list $ "Job") }}
+    {{- . | nindent 4 }}
+    {{- end }}
+spec:
+  backoffLimit: 0
+  template:
+    metadata:
+      labels:
+        app: {{ $serviceName }}-migration
+    spec:
+      restartPolicy: Never
+      {{- if and (or $serviceConfig.serviceAccount $serviceConfig.iamRoleArn) (not (has "pre-install" .hooks)) }}
+      serviceAccountName: {{ $serviceName }}-sa
+      {{- end }}
+      containers:
+      {{- range .containers }}
+      - name: {{ .name }}
+        image: "{{ .image.repository }}{{ with .image.tag }}:{{ . }}{{ end }}{{ with .image.digest }}@{{ . }}{{ end }}"
+        imagePullPolicy: {{ .imagePullPolicy | default "IfNotPresent" }}
+        {{- with .command }}
+        command:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- with .args }}
+        args:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- if .env }}
+        env:
+        {{- range .env }}
+        - name: {{ .name }}
+          value: "{{ .value }}"
+        {{- end }}
+        {{- end }}
+        {{- with .securityContext }}
+        securityContext:
+          {{- toYaml . | nindent 10 }}
+        {{- end }}
+        {{- if .resources }}
+        resources:
+          {{- if .resources.limits }}
+          limits:
+            {{- with .resources.limits.cpu }}
+            cpu: {{ . }}
+            {{- end }}
+            {{- with .resources.limits.memory }}
+            memory: {{ . }}
+            {{- end }}
+          {{- end }}
+          {{- if .resources.requests }}
+          requests:
+            cpu: {{ .resources.requests.cpu }}
+            memory: {{ .resources.requests.memory }}
+          {{- end }}
+        {{- end }}
+      {{- end }}
+{{- end }}
+{{- end }}
+`
+
+	if kinds.allows("Job") {
+		jobFile := filepath.Join(chartPath, "templates", "job", "migration.yaml")
+		if err := os.WriteFile(jobFile, []byte(jobTemplate), 0o644); err != nil {
+			return fmt.Errorf("failed to write migration job template: %w", err)
+		}
+
+		log.Printf("Created migration job template at: %s", jobFile)
+	}
 
 	// Create helpers template
 	helpersTemplate := `{{/*
@@ -454,6 +977,32 @@ app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
 app.kubernetes.io/managed-by: {{ .Release.Service }}
 {{- end }}
 
+{{/*
+Labels and annotations added with --label/--annotation, looked up by kind
+Usage: include "` + filepath.Base(chartPath) + `.objectLabels" (list $ "Deployment")
+*/}}
+{{- define "` + filepath.Base(chartPath) + `.objectLabels" -}}
+{{- $kind := index . 1 }}
+{{- with (index . 0).Values.objectMetadata }}
+{{- with index . $kind }}
+{{- with .labels }}
+{{- toYaml . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "` + filepath.Base(chartPath) + `.objectAnnotations" -}}
+{{- $kind := index . 1 }}
+{{- with (index . 0).Values.objectMetadata }}
+{{- with index . $kind }}
+{{- with .annotations }}
+{{- toYaml . }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- end }}
+
 {{/*
 Selector labels
 */}}
@@ -462,6 +1011,9 @@ app.kubernetes.io/name: {{ include "` + filepath.Base(chartPath) + `.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end }}
 `
+	if byFamily {
+		helpersTemplate += helmFamilyServicesHelper(filepath.Base(chartPath))
+	}
 
 	helpersFile := filepath.Join(chartPath, "templates", "_helpers.tpl")
 	if err := os.WriteFile(helpersFile, []byte(helpersTemplate), 0o644); err != nil {
@@ -472,3 +1024,121 @@ app.kubernetes.io/instance: {{ .Release.Name }}
 
 	return nil
 }
+
+// helmExtraServicePorts returns the ports of the Services of a task
+// definition that none of its containers maps, which the chart's Service
+// renders next to the container ports
+func helmExtraServicePorts(taskDefInfo *TaskDefInfo) []int32 {
+	containerPorts := map[int32]bool{}
+	for _, container := range taskDefInfo.Containers {
+		for _, port := range container.Ports {
+			containerPorts[port] = true
+		}
+	}
+	var extraPorts []int32
+	for _, svc := range taskDefInfo.Manifests.Services {
+		if svc == nil {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if !containerPorts[port.Port] {
+				containerPorts[port.Port] = true
+				extraPorts = append(extraPorts, port.Port)
+			}
+		}
+	}
+	return extraPorts
+}
+
+// helmServiceRefs points the references of an object to the Services of the
+// raw manifests, one per container, at the single Service the chart renders
+// for the task definition
+func helmServiceRefs(obj map[string]interface{}, taskDefInfo *TaskDefInfo) {
+	for _, svc := range taskDefInfo.Manifests.Services {
+		if svc != nil && svc.Name != taskDefInfo.Name {
+			renameServiceRefs(obj, svc.Name, taskDefInfo.Name)
+		}
+	}
+}
+
+// copyObject returns a deep copy of an unstructured object
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		copied[k] = copyValue(v)
+	}
+	return copied
+}
+
+// copyValue returns a deep copy of a value of an unstructured object
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyObject(v)
+	case []map[string]interface{}:
+		copied := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyObject(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return v
+}
+
+// createHelmExtras writes each service's additional objects as static templates
+// placed in the service's configured namespace
+func createHelmExtras(chartPath string, taskDefInfos []*TaskDefInfo, byFamily bool) error {
+	servicesValues := helmServicesValues(filepath.Base(chartPath), byFamily)
+	for _, taskDefInfo := range taskDefInfos {
+		if len(taskDefInfo.Manifests.Extras) == 0 {
+			continue
+		}
+
+		extrasDir := filepath.Join(chartPath, "templates", "extras")
+		if err := os.MkdirAll(extrasDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", extrasDir, err)
+		}
+
+		namespace := fmt.Sprintf(`{{ (index %s %q).namespace | default .Values.defaultNamespace }}`, servicesValues, taskDefInfo.Name)
+		for _, extra := range taskDefInfo.Manifests.Extras {
+			if !taskDefInfo.Manifests.Kinds.allows(documentKind(extra.Object)) {
+				continue
+			}
+			// Copy the object so the template namespace doesn't leak into other outputs
+			obj := copyObject(extra.Object)
+			switch documentKind(obj) {
+			case "Ingress", "TargetGroupBinding":
+				helmServiceRefs(obj, taskDefInfo)
+			}
+			metadata := map[string]interface{}{}
+			if m, ok := extra.Object["metadata"].(map[string]interface{}); ok {
+				for k, v := range m {
+					metadata[k] = v
+				}
+			}
+			metadata["namespace"] = namespace
+			obj["metadata"] = metadata
+			taskDefInfo.Manifests.Metadata.apply(obj)
+			taskDefInfo.Manifests.APIVersions.apply(obj)
+
+			data, err := yaml.Marshal(obj)
+			if err != nil {
+				return fmt.Errorf("failed to marshal %s for %s: %w", extra.Suffix, taskDefInfo.Name, err)
+			}
+
+			extraFile := filepath.Join(extrasDir, fmt.Sprintf("%s-%s.yaml", taskDefInfo.Name, extra.Suffix))
+			if err := os.WriteFile(extraFile, data, 0o644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", extraFile, err)
+			}
+			log.Printf("Created extra template at: %s", extraFile)
+		}
+	}
+
+	return nil
+}
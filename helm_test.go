@@ -0,0 +1,202 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestSplitImageReference tests splitting image references for Helm values
+func TestSplitImageReference(t *testing.T) {
+	tests := []struct {
+		image      string
+		repository string
+		tag        string
+		digest     string
+	}{
+		{image: "nginx", repository: "nginx"},
+		{image: "nginx:1.27", repository: "nginx", tag: "1.27"},
+		{image: "myrepo/api-service:v2.1.0", repository: "myrepo/api-service", tag: "v2.1.0"},
+		{image: "registry.local:5000/team/app", repository: "registry.local:5000/team/app"},
+		{image: "registry.local:5000/team/app:1.0", repository: "registry.local:5000/team/app", tag: "1.0"},
+		{image: "123456789012.dkr.ecr.us-east-1.amazonaws.com/app@sha256:abc", repository: "123456789012.dkr.ecr.us-east-1.amazonaws.com/app", digest: "sha256:abc"},
+		{image: "app:1.0@sha256:abc", repository: "app", tag: "1.0", digest: "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		repository, tag, digest := splitImageReference(tt.image)
+		if repository != tt.repository || tag != tt.tag || digest != tt.digest {
+			t.Errorf("splitImageReference(%q) = (%q, %q, %q), want (%q, %q, %q)",
+				tt.image, repository, tag, digest, tt.repository, tt.tag, tt.digest)
+		}
+	}
+}
+
+// TestHelmChartName tests charts of cluster names Helm does not accept as
+// chart names
+func TestHelmChartName(t *testing.T) {
+	for _, cluster := range []string{"Prod_Cluster", "team.api", "prod"} {
+		outputDir := t.TempDir()
+		taskDefInfo := &TaskDefInfo{Name: "web", Containers: []ContainerConfig{{Name: "web", Image: "nginx:1.27"}}}
+		if err := CreateHelmChart(cluster, []*TaskDefInfo{taskDefInfo}, outputDir, helmChartOptions{}); err != nil {
+			t.Fatalf("%s: CreateHelmChart failed: %v", cluster, err)
+		}
+
+		chartName := helmChartName(cluster)
+		chartPath := filepath.Join(outputDir, cluster, "helm", chartName)
+		var chart ChartYAML
+		readYAML(t, filepath.Join(chartPath, "Chart.yaml"), &chart)
+		if chart.Name != chartName || chartName != sanitizeName(cluster) {
+			t.Errorf("%s: chart name = %q, want %q", cluster, chart.Name, sanitizeName(cluster))
+		}
+
+		helpers, err := os.ReadFile(filepath.Join(chartPath, "templates", "_helpers.tpl"))
+		if err != nil {
+			t.Fatalf("%s: %v", cluster, err)
+		}
+		if !strings.Contains(string(helpers), `define "`+chartName+`.name"`) {
+			t.Errorf("%s: _helpers.tpl does not define %s.name", cluster, chartName)
+		}
+
+		var values map[string]interface{}
+		readYAML(t, filepath.Join(chartPath, "values.yaml"), &values)
+		wantOverride := cluster
+		if chartName == cluster {
+			wantOverride = ""
+		}
+		if got, _ := values["nameOverride"].(string); got != wantOverride {
+			t.Errorf("%s: nameOverride = %q, want %q", cluster, got, wantOverride)
+		}
+
+		// Installing the chart needs the helm CLI
+		if _, err := exec.LookPath("helm"); err != nil {
+			continue
+		}
+		if out, err := exec.Command("helm", "template", "release", chartPath).CombinedOutput(); err != nil {
+			t.Errorf("%s: helm template failed: %v\n%s", cluster, err, out)
+		}
+	}
+}
+
+// readYAML decodes a YAML file
+func readYAML(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+}
+
+// TestHelmTargetGroupIngress tests that the Ingress of a service attached to
+// several target groups routes to the chart's Service, which exposes the
+// target group ports the containers don't map
+func TestHelmTargetGroupIngress(t *testing.T) {
+	info, err := buildTaskDefInfo(appTaskDef("web"), "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	attachments := []targetGroupAttachment{
+		{TargetGroupArn: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-http/1", ContainerName: "app", ContainerPort: 80},
+		{TargetGroupArn: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-admin/2", ContainerName: "app", ContainerPort: 8080},
+	}
+	targetGroups := map[string]*targetGroupInfo{
+		attachments[0].TargetGroupArn: {Routes: []ingressRoute{{Path: "/"}}},
+		attachments[1].TargetGroupArn: {Routes: []ingressRoute{{Path: "/admin"}}},
+	}
+	applyTargetGroups(info, attachments, targetGroups)
+
+	outputDir := t.TempDir()
+	if err := CreateHelmChart("prod", []*TaskDefInfo{info}, outputDir, helmChartOptions{}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+	chartPath := filepath.Join(outputDir, "prod", "helm", "prod")
+
+	var values struct {
+		Services map[string]struct {
+			Service struct {
+				ExtraPorts []int32 `yaml:"extraPorts"`
+			} `yaml:"service"`
+		} `yaml:"services"`
+	}
+	readYAML(t, filepath.Join(chartPath, "values.yaml"), &values)
+	if got := values.Services["web"].Service.ExtraPorts; len(got) != 1 || got[0] != 8080 {
+		t.Errorf("service extraPorts = %v, want [8080]", got)
+	}
+
+	var ingress struct {
+		Spec struct {
+			Rules []struct {
+				HTTP struct {
+					Paths []struct {
+						Backend struct {
+							Service struct {
+								Name string `yaml:"name"`
+							} `yaml:"service"`
+						} `yaml:"backend"`
+					} `yaml:"paths"`
+				} `yaml:"http"`
+			} `yaml:"rules"`
+		} `yaml:"spec"`
+	}
+	readYAML(t, filepath.Join(chartPath, "templates", "extras", "web-ingress.yaml"), &ingress)
+	var backends []string
+	for _, rule := range ingress.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			backends = append(backends, path.Backend.Service.Name)
+		}
+	}
+	if strings.Join(backends, ",") != "web,web" {
+		t.Errorf("Ingress backends = %v, want the chart Service web", backends)
+	}
+
+	// The raw Ingress keeps routing to the container's Service
+	data, err := yaml.Marshal(info.Manifests.Extras[0].Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "name: app\n") != 2 {
+		t.Errorf("the raw Ingress was changed by the chart:\n%s", data)
+	}
+}
+
+// TestHelmTargetGroupBinding tests that the TargetGroupBindings of the chart
+// register the pods through the chart's Service
+func TestHelmTargetGroupBinding(t *testing.T) {
+	info, err := buildTaskDefInfo(appTaskDef("web"), "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	attachments := []targetGroupAttachment{
+		{TargetGroupArn: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-http/1", ContainerName: "app", ContainerPort: 80},
+	}
+	applyTargetGroupBindings(info, attachments, nil)
+
+	outputDir := t.TempDir()
+	if err := CreateHelmChart("prod", []*TaskDefInfo{info}, outputDir, helmChartOptions{}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+
+	var binding struct {
+		Spec struct {
+			ServiceRef struct {
+				Name string `yaml:"name"`
+				Port int32  `yaml:"port"`
+			} `yaml:"serviceRef"`
+		} `yaml:"spec"`
+	}
+	readYAML(t, filepath.Join(outputDir, "prod", "helm", "prod", "templates", "extras", "web-web-http-targetgroupbinding.yaml"), &binding)
+	if ref := binding.Spec.ServiceRef; ref.Name != "web" || ref.Port != 80 {
+		t.Errorf("serviceRef = %+v, want the chart Service web on port 80", ref)
+	}
+	raw := info.Manifests.Extras[0].Object["spec"].(map[string]interface{})["serviceRef"].(map[string]interface{})
+	if raw["name"] != "app" {
+		t.Errorf("raw serviceRef = %v, want the container's Service app", raw["name"])
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// familyServiceKeys are the service values that stay on each service when
+// the services of a family are grouped: they describe the workload itself
+// rather than where and how it runs
+var familyServiceKeys = map[string]bool{
+	"containers":  true,
+	"service":     true,
+	"kind":        true,
+	"statefulSet": true,
+	"migration":   true,
+	"volumes":     true,
+}
+
+// familyValues groups the services of values.yaml by task definition family
+// (--helm-group-by-family). Each family lists the active revisions of its
+// task definition, the values shared by all of its services under defaults,
+// and the services with the values that differ. Split containers share the
+// family of the task definition they were split from.
+func familyValues(workloads []*Workload, services map[string]interface{}) map[string]interface{} {
+	members := map[string][]string{}
+	revisions := map[string]map[int]bool{}
+	for _, workload := range workloads {
+		taskDefInfo, family := workload.Info, workload.Family
+		members[family] = append(members[family], workload.Name)
+		if revisions[family] == nil {
+			revisions[family] = map[int]bool{}
+		}
+		if taskDefInfo.Source != nil && taskDefInfo.Source.Revision > 0 {
+			revisions[family][int(taskDefInfo.Source.Revision)] = true
+		}
+		for _, canary := range taskDefInfo.Canaries {
+			if revision, ok := taskDefRevision(canary.TaskDefinition, family); ok {
+				revisions[family][revision] = true
+			}
+		}
+	}
+
+	families := map[string]interface{}{}
+	for family, names := range members {
+		defaults := familyDefaults(names, services)
+		familyServices := map[string]interface{}{}
+		for _, name := range names {
+			serviceConfig := map[string]interface{}{}
+			for key, value := range services[name].(map[string]interface{}) {
+				if _, shared := defaults[key]; !shared {
+					serviceConfig[key] = value
+				}
+			}
+			familyServices[name] = serviceConfig
+		}
+
+		familyConfig := map[string]interface{}{
+			"defaults": defaults,
+			"services": familyServices,
+		}
+		if len(revisions[family]) > 0 {
+			sorted := make([]int, 0, len(revisions[family]))
+			for revision := range revisions[family] {
+				sorted = append(sorted, revision)
+			}
+			sort.Ints(sorted)
+			familyConfig["revisions"] = sorted
+		}
+		families[family] = familyConfig
+	}
+	return families
+}
+
+// familyDefaults returns the values set to the same value on every service
+// of a family
+func familyDefaults(names []string, services map[string]interface{}) map[string]interface{} {
+	defaults := map[string]interface{}{}
+	first := services[names[0]].(map[string]interface{})
+	for key, value := range first {
+		if familyServiceKeys[key] {
+			continue
+		}
+		shared := true
+		for _, name := range names[1:] {
+			other, ok := services[name].(map[string]interface{})[key]
+			if !ok || !reflect.DeepEqual(other, value) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			defaults[key] = value
+		}
+	}
+	return defaults
+}
+
+// taskDefRevision returns the revision of a task definition ARN or
+// family:revision of the given family
+func taskDefRevision(taskDef, family string) (int, bool) {
+	name := taskDef[strings.LastIndex(taskDef, "/")+1:]
+	prefix := family + ":"
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	revision, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	return revision, err == nil
+}
+
+// flattenServiceValues returns the services of chart values, with the
+// services of each family merged over the family defaults as the chart's
+// services helper does
+func flattenServiceValues(values map[string]interface{}) map[string]interface{} {
+	services := map[string]interface{}{}
+	if flat, ok := values["services"].(map[string]interface{}); ok {
+		for name, serviceConfig := range flat {
+			services[name] = serviceConfig
+		}
+	}
+	families, _ := values["families"].(map[string]interface{})
+	for _, f := range families {
+		familyConfig, _ := f.(map[string]interface{})
+		defaults, _ := familyConfig["defaults"].(map[string]interface{})
+		familyServices, _ := familyConfig["services"].(map[string]interface{})
+		for name, s := range familyServices {
+			serviceConfig, _ := s.(map[string]interface{})
+			services[name] = mergeValues(defaults, serviceConfig)
+		}
+	}
+	return services
+}
+
+// mergeValues returns the values of override merged over base, recursing
+// into the maps both set
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		baseMap, baseOK := merged[key].(map[string]interface{})
+		overrideMap, overrideOK := value.(map[string]interface{})
+		if baseOK && overrideOK {
+			value = mergeValues(baseMap, overrideMap)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// helmServicesValues returns the template expression of the services of the
+// chart values: .Values.services, or the services helper flattening the
+// families
+func helmServicesValues(chartName string, byFamily bool) string {
+	if !byFamily {
+		return ".Values.services"
+	}
+	return `(include "` + chartName + `.services" . | fromYaml)`
+}
+
+// helmFamilyServicesHelper returns the _helpers.tpl template flattening the
+// families of the chart values into services, each merged over the defaults
+// of its family. Services outside any family are kept as they are.
+func helmFamilyServicesHelper(chartName string) string {
+	return `
+{{/*
+Services of the chart, merged over the defaults of their task definition family
+Usage: include "` + chartName + `.services" . | fromYaml
+*/}}
+{{- define "` + chartName + `.services" -}}
+{{- $services := deepCopy (.Values.services | default dict) }}
+{{- range $family, $familyConfig := .Values.families }}
+{{- range $serviceName, $serviceConfig := $familyConfig.services }}
+{{- $_ := set $services $serviceName (mergeOverwrite (deepCopy ($familyConfig.defaults | default dict)) ($serviceConfig | default dict)) }}
+{{- end }}
+{{- end }}
+{{- toYaml $services }}
+{{- end }}
+`
+}
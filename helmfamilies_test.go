@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestHelmGroupByFamily tests the values.yaml grouped by task definition family
+func TestHelmGroupByFamily(t *testing.T) {
+	source := &types.TaskDefinition{Family: aws.String("web"), Revision: 12}
+	web := &TaskDefInfo{
+		Name:        "web",
+		Source:      source,
+		TaskRoleArn: "arn:aws:iam::123456789012:role/web",
+		Containers:  []ContainerConfig{{Name: "web", Image: "nginx:1.27"}},
+		Canaries:    []CanaryTaskSet{{TaskDefinition: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:13"}},
+	}
+	sidecar := &TaskDefInfo{
+		Name:        "web-envoy",
+		Source:      source,
+		TaskRoleArn: "arn:aws:iam::123456789012:role/web",
+		Containers:  []ContainerConfig{{Name: "envoy", Image: "envoy:1.30"}},
+	}
+	worker := &TaskDefInfo{Name: "worker", SourceName: "worker", Containers: []ContainerConfig{{Name: "worker", Image: "worker:1"}}}
+	infos := []*TaskDefInfo{web, sidecar, worker}
+
+	outputDir := t.TempDir()
+	if err := CreateHelmChart("prod", infos, outputDir, helmChartOptions{byFamily: true}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+	chartPath := filepath.Join(outputDir, "prod", "helm", "prod")
+	var values map[string]interface{}
+	readYAML(t, filepath.Join(chartPath, "values.yaml"), &values)
+	if _, ok := values["services"]; ok {
+		t.Errorf("grouped values.yaml keeps the services key")
+	}
+
+	family := values["families"].(map[string]interface{})["web"].(map[string]interface{})
+	if revisions := family["revisions"].([]interface{}); len(revisions) != 2 || revisions[0] != 12 || revisions[1] != 13 {
+		t.Errorf("web revisions = %v, want [12 13]", revisions)
+	}
+	defaults := family["defaults"].(map[string]interface{})
+	if defaults["namespace"] != "default" || defaults["iamRoleArn"] != web.TaskRoleArn {
+		t.Errorf("web defaults = %v, want the shared namespace and role", defaults)
+	}
+	services := family["services"].(map[string]interface{})
+	envoy := services["web-envoy"].(map[string]interface{})
+	if _, ok := envoy["namespace"]; ok || envoy["containers"] == nil {
+		t.Errorf("web-envoy values = %v, want its containers without the family defaults", envoy)
+	}
+
+	// Flattened, the chart renders the services it would without grouping
+	ungrouped := t.TempDir()
+	if err := CreateHelmChart("prod", infos, ungrouped, helmChartOptions{}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+	var flat map[string]interface{}
+	readYAML(t, filepath.Join(ungrouped, "prod", "helm", "prod", "values.yaml"), &flat)
+	if got, want := flattenServiceValues(values), flat["services"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("flattened services = %v, want %v", got, want)
+	}
+
+	deployment, err := os.ReadFile(filepath.Join(chartPath, "templates", "deployment", "deployment.yaml"))
+	if err != nil || !strings.Contains(string(deployment), `(include "prod.services" . | fromYaml)`) {
+		t.Errorf("deployment template doesn't range over the services helper: %v", err)
+	}
+	helpers, err := os.ReadFile(filepath.Join(chartPath, "templates", "_helpers.tpl"))
+	if err != nil || !strings.Contains(string(helpers), `define "prod.services"`) {
+		t.Errorf("_helpers.tpl doesn't define the services helper: %v", err)
+	}
+
+	merged := mergeValues(
+		map[string]interface{}{"serviceAccount": map[string]interface{}{"annotations": "a"}, "replicas": 1},
+		map[string]interface{}{"serviceAccount": map[string]interface{}{"name": "b"}},
+	)
+	if sa := merged["serviceAccount"].(map[string]interface{}); sa["annotations"] != "a" || sa["name"] != "b" || merged["replicas"] != 1 {
+		t.Errorf("mergeValues() = %v, want the nested maps merged", merged)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Chart paths of the templates the generated unit tests render
+const (
+	helmDeploymentTemplate     = "templates/deployment/deployment.yaml"
+	helmServiceTemplate        = "templates/service/service.yaml"
+	helmServiceAccountTemplate = "templates/serviceaccount/serviceaccount.yaml"
+)
+
+// createHelmTests writes a helm-unittest suite per service to the tests
+// directory of the chart (--helm-tests). The suites assert that the Deployment,
+// Service and ServiceAccount of each service render the values captured in
+// values.yaml, so changes to the templates that drop them fail `helm unittest`.
+func createHelmTests(chartPath string, taskDefInfos []*TaskDefInfo) error {
+	// The suites check what the chart renders from its values, after --values-override
+	var values map[string]interface{}
+	data, err := os.ReadFile(filepath.Join(chartPath, "values.yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read values.yaml: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to read values.yaml: %w", err)
+	}
+
+	testsPath := filepath.Join(chartPath, "tests")
+	if err := os.MkdirAll(testsPath, 0o755); err != nil {
+		return fmt.Errorf("failed to create helm tests directory %s: %w", testsPath, err)
+	}
+	// Packaged charts don't need the suites
+	if err := os.WriteFile(filepath.Join(chartPath, ".helmignore"), []byte("tests/\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write .helmignore: %w", err)
+	}
+
+	kinds := conversionKinds(taskDefInfos)
+	// Services grouped by family render merged over the family defaults
+	services := flattenServiceValues(values)
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		serviceConfig, _ := services[name].(map[string]interface{})
+		suite := helmTestSuite(name, serviceConfig, values, kinds)
+		if suite == nil {
+			continue
+		}
+		data, err := marshalYAML(suite)
+		if err != nil {
+			return fmt.Errorf("failed to marshal helm tests of %s: %w", name, err)
+		}
+		testFile := filepath.Join(testsPath, sanitizeName(name)+"_test.yaml")
+		if err := os.WriteFile(testFile, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write helm tests of %s: %w", name, err)
+		}
+		log.Printf("Created helm-unittest suite at: %s", testFile)
+	}
+	return nil
+}
+
+// helmTestSuite returns the helm-unittest suite of a service, nil when none of
+// its objects is rendered
+func helmTestSuite(name string, serviceConfig, values map[string]interface{}, kinds *kindFilter) map[string]interface{} {
+	namespace := valueOr(serviceConfig["namespace"], values["defaultNamespace"])
+	var tests []interface{}
+	templates := map[string]bool{}
+	addTest := func(it, template, objectName string, asserts []interface{}) {
+		templates[template] = true
+		tests = append(tests, map[string]interface{}{
+			"it":       it,
+			"template": template,
+			"documentSelector": map[string]interface{}{
+				"path":  "metadata.name",
+				"value": objectName,
+			},
+			"asserts": asserts,
+		})
+	}
+
+	containers, _ := serviceConfig["containers"].([]interface{})
+	kind, _ := valueOr(serviceConfig["kind"], "Deployment").(string)
+	if len(containers) > 0 && kinds.allows(kind) {
+		asserts := []interface{}{
+			helmAssert("isKind", map[string]interface{}{"of": kind}),
+			helmEqual("metadata.namespace", namespace),
+			helmEqual("spec.replicas", valueOr(serviceConfig["replicas"], values["defaultReplicas"])),
+		}
+		for i, item := range containers {
+			container, _ := item.(map[string]interface{})
+			asserts = append(asserts,
+				helmEqual(fmt.Sprintf("spec.template.spec.containers[%d].name", i), container["name"]),
+				helmEqual(fmt.Sprintf("spec.template.spec.containers[%d].image", i), helmImage(container["image"])),
+			)
+		}
+		addTest(fmt.Sprintf("renders the %s of %s", kind, name), helmDeploymentTemplate, name, asserts)
+	}
+
+	if service, ok := serviceConfig["service"].(map[string]interface{}); ok && kinds.allows("Service") {
+		asserts := []interface{}{
+			helmAssert("isKind", map[string]interface{}{"of": "Service"}),
+			helmEqual("metadata.namespace", namespace),
+			helmEqual("spec.type", valueOr(service["type"], "ClusterIP")),
+		}
+		port := 0
+		for _, item := range containers {
+			container, _ := item.(map[string]interface{})
+			ports, _ := container["ports"].([]interface{})
+			for _, p := range ports {
+				asserts = append(asserts, helmEqual(fmt.Sprintf("spec.ports[%d].port", port), p))
+				port++
+			}
+		}
+		addTest("renders the Service of "+name, helmServiceTemplate, name, asserts)
+	}
+
+	if (serviceConfig["serviceAccount"] != nil || serviceConfig["iamRoleArn"] != nil) && kinds.allows("ServiceAccount") {
+		asserts := []interface{}{
+			helmAssert("isKind", map[string]interface{}{"of": "ServiceAccount"}),
+			helmEqual("metadata.namespace", namespace),
+		}
+		annotations := map[string]interface{}{}
+		if serviceAccount, ok := serviceConfig["serviceAccount"].(map[string]interface{}); ok {
+			annotations, _ = serviceAccount["annotations"].(map[string]interface{})
+		} else {
+			annotations[irsaAnnotation] = serviceConfig["iamRoleArn"]
+		}
+		keys := make([]string, 0, len(annotations))
+		for key := range annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			asserts = append(asserts, helmEqual(fmt.Sprintf("metadata.annotations[%q]", key), annotations[key]))
+		}
+		addTest("renders the ServiceAccount of "+name, helmServiceAccountTemplate, name+"-sa", asserts)
+	}
+
+	if len(tests) == 0 {
+		return nil
+	}
+	var suiteTemplates []string
+	for _, template := range []string{helmDeploymentTemplate, helmServiceTemplate, helmServiceAccountTemplate} {
+		if templates[template] {
+			suiteTemplates = append(suiteTemplates, template)
+		}
+	}
+	return map[string]interface{}{
+		"suite":     "service " + name,
+		"templates": suiteTemplates,
+		"tests":     tests,
+	}
+}
+
+// helmImage renders image values as the Deployment template does
+func helmImage(value interface{}) string {
+	image, _ := value.(map[string]interface{})
+	ref := fmt.Sprint(valueOr(image["repository"], ""))
+	if tag := valueOr(image["tag"], ""); tag != "" {
+		ref += fmt.Sprintf(":%v", tag)
+	}
+	if digest := valueOr(image["digest"], ""); digest != "" {
+		ref += fmt.Sprintf("@%v", digest)
+	}
+	return ref
+}
+
+// helmAssert returns a helm-unittest assertion
+func helmAssert(assertion string, args map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{assertion: args}
+}
+
+// helmEqual returns a helm-unittest assertion that path renders value
+func helmEqual(path string, value interface{}) map[string]interface{} {
+	return helmAssert("equal", map[string]interface{}{"path": path, "value": value})
+}
+
+// valueOr returns value, or fallback when it is empty as for the default
+// template function
+func valueOr(value, fallback interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return fallback
+	case string:
+		if v == "" {
+			return fallback
+		}
+	case int:
+		if v == 0 {
+			return fallback
+		}
+	case bool:
+		if !v {
+			return fallback
+		}
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCreateHelmTests tests the helm-unittest suites written with --helm-tests
+func TestCreateHelmTests(t *testing.T) {
+	outputDir := t.TempDir()
+	web := &TaskDefInfo{
+		Name:        "web",
+		TaskRoleArn: "arn:aws:iam::123456789012:role/web",
+		Containers:  []ContainerConfig{{Name: "web", Image: "nginx:1.27", Ports: []int32{80, 443}}},
+	}
+	web.Manifests.Services = []*corev1.Service{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: []corev1.ServicePort{{Port: 80}}},
+	}}
+	worker := &TaskDefInfo{Name: "worker", Containers: []ContainerConfig{{Name: "worker", Image: "worker@sha256:abc"}}}
+	overrides, err := parseValuesOverrides([]string{"services.web.replicas=3"})
+	if err != nil {
+		t.Fatalf("parseValuesOverrides() error = %v", err)
+	}
+	if err := CreateHelmChart("prod", []*TaskDefInfo{web, worker}, outputDir, helmChartOptions{overrides: overrides, tests: true}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+
+	chartPath := filepath.Join(outputDir, "prod", "helm", "prod")
+	var suite struct {
+		Templates []string `yaml:"templates"`
+		Tests     []struct {
+			It               string                   `yaml:"it"`
+			Template         string                   `yaml:"template"`
+			DocumentSelector map[string]string        `yaml:"documentSelector"`
+			Asserts          []map[string]interface{} `yaml:"asserts"`
+		} `yaml:"tests"`
+	}
+	readYAML(t, filepath.Join(chartPath, "tests", "web_test.yaml"), &suite)
+	if len(suite.Templates) != 3 || len(suite.Tests) != 3 {
+		t.Fatalf("web suite has %d templates and %d tests, want 3 of each", len(suite.Templates), len(suite.Tests))
+	}
+
+	equals := map[string]interface{}{}
+	for _, test := range suite.Tests {
+		for _, assert := range test.Asserts {
+			if equal, ok := assert["equal"].(map[string]interface{}); ok {
+				equals[test.DocumentSelector["value"]+" "+equal["path"].(string)] = equal["value"]
+			}
+		}
+	}
+	want := map[string]interface{}{
+		"web spec.replicas":                                         3,
+		"web spec.template.spec.containers[0].image":                "nginx:1.27",
+		"web spec.type":                                             "LoadBalancer",
+		"web spec.ports[1].port":                                    443,
+		`web-sa metadata.annotations["eks.amazonaws.com/role-arn"]`: "arn:aws:iam::123456789012:role/web",
+		"web metadata.namespace":                                    "default",
+	}
+	for key, value := range want {
+		if equals[key] != value {
+			t.Errorf("web suite asserts %s = %v, want %v", key, equals[key], value)
+		}
+	}
+
+	readYAML(t, filepath.Join(chartPath, "tests", "worker_test.yaml"), &suite)
+	if len(suite.Tests) != 1 || suite.Tests[0].Template != helmDeploymentTemplate {
+		t.Errorf("worker suite tests = %+v, want only the Deployment", suite.Tests)
+	}
+	if image := helmImage(map[string]interface{}{"repository": "worker", "tag": "", "digest": "sha256:abc"}); image != "worker@sha256:abc" {
+		t.Errorf("helmImage() = %q, want worker@sha256:abc", image)
+	}
+
+	ignore, err := os.ReadFile(filepath.Join(chartPath, ".helmignore"))
+	if err != nil || string(ignore) != "tests/\n" {
+		t.Errorf(".helmignore = %q (%v), want tests/", ignore, err)
+	}
+
+	// Running the suites needs the helm CLI with the helm-unittest plugin
+	if _, err := exec.LookPath("helm"); err != nil {
+		return
+	}
+	if out, err := exec.Command("helm", "unittest", chartPath).CombinedOutput(); err != nil {
+		t.Logf("helm unittest unavailable or failed: %v\n%s", err, out)
+	}
+}
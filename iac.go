@@ -0,0 +1,830 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gopkg.in/yaml.v3"
+)
+
+// iacCluster is an ECS cluster as declared in infrastructure-as-code artifacts
+// rather than read from the ECS API
+type iacCluster struct {
+	Name     string
+	Services []types.Service
+	// TaskDefinitions are keyed by every reference services may use for them
+	// (ARN, family, family:revision or template logical ID)
+	TaskDefinitions map[string]*types.TaskDefinition
+	// Tags are the tags of the task definitions, keyed like TaskDefinitions,
+	// for inputs that carry them
+	Tags map[string]map[string]string
+	// Source is the artifact the cluster was read from
+	Source string
+}
+
+// iacInputs holds the --from-* flags
+type iacInputs struct {
+	cfnTemplate    string
+	terraformState string
+	cdkOut         string
+	// exportedJSON holds ECS API responses exported with the AWS CLI (--from-json)
+	exportedJSON string
+}
+
+// enabled reports whether an IaC input was given
+func (in iacInputs) enabled() bool {
+	return in.cfnTemplate != "" || in.terraformState != "" || in.cdkOut != "" || in.exportedJSON != ""
+}
+
+// validate checks that at most one IaC input is given
+func (in iacInputs) validate() error {
+	count := 0
+	for _, path := range []string{in.cfnTemplate, in.terraformState, in.cdkOut, in.exportedJSON} {
+		if path != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("--from-cfn-template, --from-terraform-state, --from-cdk-out and --from-json are mutually exclusive")
+	}
+	return nil
+}
+
+// load reads the clusters declared by the IaC input
+func (in iacInputs) load() ([]*iacCluster, error) {
+	switch {
+	case in.cfnTemplate != "":
+		return loadCloudFormationTemplate(in.cfnTemplate)
+	case in.terraformState != "":
+		return loadTerraformState(in.terraformState)
+	case in.cdkOut != "":
+		return loadCDKOut(in.cdkOut)
+	case in.exportedJSON != "":
+		return loadExportedJSON(in.exportedJSON)
+	}
+	return nil, nil
+}
+
+// iacRegion returns the region of the first service or task definition ARN
+// declared by the clusters, or "" when they declare none
+func iacRegion(clusters []*iacCluster) string {
+	var arns []string
+	for _, cluster := range clusters {
+		for _, svc := range cluster.Services {
+			arns = append(arns, aws.ToString(svc.ServiceArn), aws.ToString(svc.TaskDefinition))
+		}
+		refs := make([]string, 0, len(cluster.TaskDefinitions))
+		for ref := range cluster.TaskDefinitions {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		arns = append(arns, refs...)
+	}
+	for _, arn := range arns {
+		if parts := strings.SplitN(arn, ":", 5); len(parts) == 5 && parts[0] == "arn" && parts[3] != "" {
+			return parts[3]
+		}
+	}
+	return ""
+}
+
+// taskDefInfo converts a task definition declared by the cluster into a
+// TaskDefInfo with the services using it attached
+func (c *iacCluster) taskDefInfo(taskDefRef string, services []types.Service) (*TaskDefInfo, error) {
+	taskDef, ok := c.TaskDefinitions[taskDefRef]
+	if !ok {
+		return nil, fmt.Errorf("task definition %s is not declared in %s", taskDefRef, c.Source)
+	}
+
+	name := aws.ToString(taskDef.Family)
+	if name == "" {
+		name = extractTaskDefName(taskDefRef)
+	}
+	taskDefInfo, err := newServiceTaskDefInfo(taskDef, name, services)
+	if err != nil {
+		return nil, err
+	}
+	taskDefInfo.Tags = c.Tags[taskDefRef]
+	return taskDefInfo, nil
+}
+
+// taskDefInfos converts the task definitions of all declared services
+func (c *iacCluster) taskDefInfos() []*TaskDefInfo {
+	servicesByTaskDef := servicesByTaskDefinition(c.Services)
+	var taskDefInfos []*TaskDefInfo
+	for _, taskDefRef := range serviceTaskDefinitions(c.Name, c.Services) {
+		taskDefInfo, err := c.taskDefInfo(taskDefRef, servicesByTaskDef[taskDefRef])
+		if err != nil {
+			log.Printf("Error: %v", err)
+			continue
+		}
+		taskDefInfos = append(taskDefInfos, taskDefInfo)
+	}
+	return taskDefInfos
+}
+
+// selectIaCClusters picks the clusters to convert, by name when --cluster is set
+func selectIaCClusters(clusters []*iacCluster, name string) ([]*iacCluster, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no ECS services found in the IaC input")
+	}
+	if name == "" {
+		return clusters, nil
+	}
+	for _, cluster := range clusters {
+		if cluster.Name == name || cluster.Name == extractClusterName(name) {
+			return []*iacCluster{cluster}, nil
+		}
+	}
+
+	var names []string
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return nil, fmt.Errorf("cluster %q is not declared in the IaC input (found: %s)", name, strings.Join(names, ", "))
+}
+
+// groupIaCServices groups declared services into clusters by cluster name
+func groupIaCServices(source, defaultCluster string, services []types.Service, taskDefs map[string]*types.TaskDefinition) []*iacCluster {
+	byName := map[string]*iacCluster{}
+	for _, svc := range services {
+		name := extractClusterName(aws.ToString(svc.ClusterArn))
+		if name == "" {
+			// Services without a cluster run in the account's default cluster
+			name = defaultCluster
+		}
+		cluster, ok := byName[name]
+		if !ok {
+			cluster = &iacCluster{Name: name, TaskDefinitions: taskDefs, Source: source}
+			byName[name] = cluster
+		}
+		cluster.Services = append(cluster.Services, svc)
+	}
+
+	clusters := make([]*iacCluster, 0, len(byName))
+	for _, cluster := range byName {
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters
+}
+
+// loadCloudFormationTemplate reads the ECS services and task definitions of a
+// CloudFormation template in JSON or YAML
+func loadCloudFormationTemplate(path string) ([]*iacCluster, error) {
+	template, err := readCloudFormationTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	services, taskDefs, err := parseCloudFormationTemplate(template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = strings.TrimSuffix(name, ".template")
+	return groupIaCServices(path, name, services, taskDefs), nil
+}
+
+// loadCDKOut reads every synthesized CloudFormation template of a cdk.out directory
+func loadCDKOut(dir string) ([]*iacCluster, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.template.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates in %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.template.json files found in %s (run cdk synth first)", dir)
+	}
+	sort.Strings(paths)
+
+	var clusters []*iacCluster
+	for _, path := range paths {
+		stackClusters, err := loadCloudFormationTemplate(path)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, stackClusters...)
+	}
+	return clusters, nil
+}
+
+// exportedECSResponse is an ECS API response exported with the AWS CLI: the
+// output of describe-services, or of describe-task-definition --include TAGS
+// (also written by --save-source)
+type exportedECSResponse struct {
+	Services       []types.Service       `json:"services"`
+	TaskDefinition *types.TaskDefinition `json:"taskDefinition"`
+	Tags           []types.Tag           `json:"tags"`
+}
+
+// loadExportedJSON reads the services and task definitions of exported ECS
+// API responses, from one JSON file or every *.json file of a directory
+func loadExportedJSON(path string) ([]*iacCluster, error) {
+	paths := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	} else if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, fmt.Errorf("failed to list JSON files in %s: %w", path, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no *.json files found in %s", path)
+		}
+		sort.Strings(paths)
+	}
+
+	var services []types.Service
+	taskDefs := map[string]*types.TaskDefinition{}
+	tags := map[string]map[string]string{}
+	for _, file := range paths {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var response exportedECSResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if len(response.Services) == 0 && response.TaskDefinition == nil {
+			log.Printf("Warning: %s holds neither describe-services nor describe-task-definition output, skipping", file)
+			continue
+		}
+		services = append(services, response.Services...)
+
+		taskDef := response.TaskDefinition
+		if taskDef == nil {
+			continue
+		}
+		family := aws.ToString(taskDef.Family)
+		refs := []string{family, fmt.Sprintf("%s:%d", family, taskDef.Revision)}
+		if arn := aws.ToString(taskDef.TaskDefinitionArn); arn != "" {
+			refs = append(refs, arn)
+		}
+		for _, ref := range refs {
+			taskDefs[ref] = taskDef
+			if len(response.Tags) > 0 {
+				tags[ref] = map[string]string{}
+				for _, tag := range response.Tags {
+					tags[ref][aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+			}
+		}
+	}
+
+	clusters := groupIaCServices(path, "default", services, taskDefs)
+	for _, cluster := range clusters {
+		cluster.Tags = tags
+	}
+	return clusters, nil
+}
+
+// readCloudFormationTemplate decodes a template, turning short-form intrinsic
+// function tags such as !Ref into their long form
+func readCloudFormationTemplate(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("template %s is empty", path)
+	}
+
+	template, ok := cloudFormationNodeValue(root.Content[0]).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template %s is not a mapping", path)
+	}
+	return template, nil
+}
+
+// cloudFormationNodeValue converts a YAML node into plain values
+func cloudFormationNodeValue(node *yaml.Node) interface{} {
+	var value interface{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m[node.Content[i].Value] = cloudFormationNodeValue(node.Content[i+1])
+		}
+		value = m
+	case yaml.SequenceNode:
+		list := []interface{}{}
+		for _, item := range node.Content {
+			list = append(list, cloudFormationNodeValue(item))
+		}
+		value = list
+	case yaml.AliasNode:
+		return cloudFormationNodeValue(node.Alias)
+	default:
+		if err := node.Decode(&value); err != nil {
+			value = node.Value
+		}
+	}
+
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return value
+	}
+	// Short form: !Ref X, !GetAtt A.B, !Sub "...", !Join [...]
+	fn := strings.TrimPrefix(node.Tag, "!")
+	if fn == "Ref" {
+		return map[string]interface{}{"Ref": node.Value}
+	}
+	if fn == "GetAtt" && node.Kind == yaml.ScalarNode {
+		parts := strings.SplitN(node.Value, ".", 2)
+		value = []interface{}{parts[0], parts[len(parts)-1]}
+	}
+	return map[string]interface{}{"Fn::" + fn: value}
+}
+
+// cloudFormationResolver evaluates the intrinsic functions of a template as
+// far as possible without deploying it
+type cloudFormationResolver struct {
+	parameters map[string]interface{}
+	// refs are the values of Ref to ECS resources
+	refs map[string]string
+}
+
+// parseCloudFormationTemplate extracts the ECS services and task definitions of a template
+func parseCloudFormationTemplate(template map[string]interface{}) ([]types.Service, map[string]*types.TaskDefinition, error) {
+	resources, _ := template["Resources"].(map[string]interface{})
+	resolver := &cloudFormationResolver{parameters: map[string]interface{}{}, refs: map[string]string{}}
+
+	if params, ok := template["Parameters"].(map[string]interface{}); ok {
+		for name, param := range params {
+			if p, ok := param.(map[string]interface{}); ok && p["Default"] != nil {
+				resolver.parameters[name] = p["Default"]
+			}
+		}
+	}
+
+	logicalIDs := make([]string, 0, len(resources))
+	for logicalID := range resources {
+		logicalIDs = append(logicalIDs, logicalID)
+	}
+	sort.Strings(logicalIDs)
+
+	// Ref to a task definition is used as the service's task definition, so
+	// name it after the family; clusters resolve to their name
+	for _, logicalID := range logicalIDs {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		props, _ := resource["Properties"].(map[string]interface{})
+		switch resource["Type"] {
+		case "AWS::ECS::TaskDefinition":
+			resolver.refs[logicalID] = logicalID
+			if family, ok := props["Family"].(string); ok && family != "" {
+				resolver.refs[logicalID] = family
+			}
+		case "AWS::ECS::Cluster":
+			resolver.refs[logicalID] = logicalID
+			if name, ok := props["ClusterName"].(string); ok && name != "" {
+				resolver.refs[logicalID] = name
+			}
+		}
+	}
+
+	var services []types.Service
+	taskDefs := map[string]*types.TaskDefinition{}
+	for _, logicalID := range logicalIDs {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		props, _ := resolver.resolve(resource["Properties"]).(map[string]interface{})
+		if props == nil {
+			props = map[string]interface{}{}
+		}
+
+		switch resource["Type"] {
+		case "AWS::ECS::TaskDefinition":
+			var taskDef types.TaskDefinition
+			if err := decodeIaCValue(props, &taskDef); err != nil {
+				return nil, nil, fmt.Errorf("task definition %s: %w", logicalID, err)
+			}
+			if taskDef.Family == nil {
+				taskDef.Family = aws.String(logicalID)
+			}
+			taskDefs[logicalID] = &taskDef
+			taskDefs[resolver.refs[logicalID]] = &taskDef
+
+		case "AWS::ECS::Service":
+			renameKey(props, "Cluster", "ClusterArn")
+			renameKey(props, "PlacementStrategies", "PlacementStrategy")
+			var svc types.Service
+			if err := decodeIaCValue(props, &svc); err != nil {
+				return nil, nil, fmt.Errorf("service %s: %w", logicalID, err)
+			}
+			if svc.ServiceName == nil {
+				svc.ServiceName = aws.String(logicalID)
+			}
+			services = append(services, svc)
+		}
+	}
+
+	return services, taskDefs, nil
+}
+
+// cloudFormationPlaceholder matches the ${Name} references of Fn::Sub
+var cloudFormationPlaceholder = regexp.MustCompile(`\$\{([^}!]+)\}`)
+
+// resolve evaluates the intrinsic functions in a template value. Values only
+// known after deployment become "${Name}" placeholders.
+func (r *cloudFormationResolver) resolve(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			out = append(out, r.resolve(item))
+		}
+		return out
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for fn, arg := range v {
+				if fn == "Ref" || strings.HasPrefix(fn, "Fn::") {
+					return r.resolveFunction(fn, arg)
+				}
+			}
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = r.resolve(item)
+		}
+		return out
+	}
+	return value
+}
+
+// resolveFunction evaluates one intrinsic function
+func (r *cloudFormationResolver) resolveFunction(fn string, arg interface{}) interface{} {
+	switch fn {
+	case "Ref":
+		name, _ := arg.(string)
+		return r.ref(name)
+
+	case "Fn::Sub":
+		var text string
+		vars := map[string]interface{}{}
+		switch a := arg.(type) {
+		case string:
+			text = a
+		case []interface{}:
+			if len(a) > 0 {
+				text, _ = a[0].(string)
+			}
+			if len(a) > 1 {
+				vars, _ = r.resolve(a[1]).(map[string]interface{})
+			}
+		}
+		return cloudFormationPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+			name := match[2 : len(match)-1]
+			if value, ok := vars[name]; ok {
+				return fmt.Sprint(value)
+			}
+			if strings.Contains(name, ".") {
+				return match
+			}
+			return fmt.Sprint(r.ref(name))
+		})
+
+	case "Fn::Join":
+		a, _ := arg.([]interface{})
+		if len(a) != 2 {
+			break
+		}
+		delimiter, _ := a[0].(string)
+		items, _ := r.resolve(a[1]).([]interface{})
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, delimiter)
+
+	case "Fn::Select":
+		a, _ := arg.([]interface{})
+		if len(a) != 2 {
+			break
+		}
+		index, err := strconv.Atoi(fmt.Sprint(r.resolve(a[0])))
+		items, _ := r.resolve(a[1]).([]interface{})
+		if err == nil && index >= 0 && index < len(items) {
+			return items[index]
+		}
+
+	case "Fn::If":
+		// Conditions are evaluated at deploy time; assume the true branch
+		a, _ := arg.([]interface{})
+		if len(a) == 3 {
+			return r.resolve(a[1])
+		}
+
+	case "Fn::GetAtt":
+		a, _ := arg.([]interface{})
+		if len(a) != 2 {
+			break
+		}
+		// Attributes of ECS resources (Cluster.Arn, TaskDef.TaskDefinitionArn)
+		// identify the same resource as its Ref
+		if name, ok := r.refs[fmt.Sprint(a[0])]; ok {
+			return name
+		}
+		return fmt.Sprintf("${%v.%v}", a[0], a[1])
+	}
+
+	return "${" + fn + "}"
+}
+
+// ref resolves a Ref to a parameter default, an ECS resource or a placeholder
+func (r *cloudFormationResolver) ref(name string) interface{} {
+	if value, ok := r.parameters[name]; ok {
+		return r.resolve(value)
+	}
+	if value, ok := r.refs[name]; ok {
+		return value
+	}
+	return "${" + name + "}"
+}
+
+// terraformState is the subset of a Terraform state file (format version 4) that is read
+type terraformState struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Module    string `json:"module"`
+		Instances []struct {
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// loadTerraformState reads the aws_ecs_service and aws_ecs_task_definition
+// resources of a Terraform state file
+func loadTerraformState(path string) ([]*iacCluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Terraform state %s: %w", path, err)
+	}
+
+	var state terraformState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse Terraform state %s: %w", path, err)
+	}
+	if state.Version != 4 {
+		log.Printf("Warning: Terraform state %s has format version %d, expected 4", path, state.Version)
+	}
+
+	var services []types.Service
+	taskDefs := map[string]*types.TaskDefinition{}
+	for _, resource := range state.Resources {
+		if resource.Mode != "managed" {
+			continue
+		}
+		address := strings.TrimPrefix(resource.Module+"."+resource.Type+"."+resource.Name, ".")
+
+		for _, instance := range resource.Instances {
+			attrs := instance.Attributes
+			switch resource.Type {
+			case "aws_ecs_task_definition":
+				taskDef, err := terraformTaskDefinition(attrs)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", address, err)
+				}
+				family := aws.ToString(taskDef.Family)
+				taskDefs[family] = taskDef
+				taskDefs[fmt.Sprintf("%s:%d", family, taskDef.Revision)] = taskDef
+				if arn := aws.ToString(taskDef.TaskDefinitionArn); arn != "" {
+					taskDefs[arn] = taskDef
+				}
+
+			case "aws_ecs_service":
+				svc, err := terraformService(attrs)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", address, err)
+				}
+				services = append(services, *svc)
+			}
+		}
+	}
+
+	return groupIaCServices(path, "default", services, taskDefs), nil
+}
+
+// terraformTaskDefinition converts aws_ecs_task_definition attributes
+func terraformTaskDefinition(attrs map[string]interface{}) (*types.TaskDefinition, error) {
+	props := map[string]interface{}{}
+	for key, value := range attrs {
+		props[key] = value
+	}
+	renameKey(props, "arn", "TaskDefinitionArn")
+	renameKey(props, "volume", "Volumes")
+
+	// Container definitions are stored as the JSON of the ECS API
+	if raw, ok := props["container_definitions"].(string); ok {
+		var containers []interface{}
+		if err := json.Unmarshal([]byte(raw), &containers); err != nil {
+			return nil, fmt.Errorf("invalid container_definitions: %w", err)
+		}
+		props["container_definitions"] = containers
+	}
+
+	var taskDef types.TaskDefinition
+	if err := decodeIaCValue(props, &taskDef); err != nil {
+		return nil, err
+	}
+	return &taskDef, nil
+}
+
+// terraformService converts aws_ecs_service attributes
+func terraformService(attrs map[string]interface{}) (*types.Service, error) {
+	props := map[string]interface{}{}
+	for key, value := range attrs {
+		props[key] = value
+	}
+	renameKey(props, "id", "ServiceArn")
+	renameKey(props, "name", "ServiceName")
+	renameKey(props, "cluster", "ClusterArn")
+	renameKey(props, "load_balancer", "LoadBalancers")
+	renameKey(props, "ordered_placement_strategy", "PlacementStrategy")
+
+	// Terraform stores tags as a map, the ECS API as a list
+	if tags, ok := props["tags"].(map[string]interface{}); ok {
+		var list []interface{}
+		for _, key := range sortedDocKeys(tags) {
+			list = append(list, map[string]interface{}{"Key": key, "Value": tags[key]})
+		}
+		props["tags"] = list
+	}
+
+	deployment := map[string]interface{}{
+		"MaximumPercent":        props["deployment_maximum_percent"],
+		"MinimumHealthyPercent": props["deployment_minimum_healthy_percent"],
+	}
+	if breaker := firstBlock(props["deployment_circuit_breaker"]); breaker != nil {
+		deployment["DeploymentCircuitBreaker"] = breaker
+	}
+	props["DeploymentConfiguration"] = deployment
+
+	if network := firstBlock(props["network_configuration"]); network != nil {
+		assignPublicIP := "DISABLED"
+		if network["assign_public_ip"] == true {
+			assignPublicIP = "ENABLED"
+		}
+		props["network_configuration"] = map[string]interface{}{
+			"AwsvpcConfiguration": map[string]interface{}{
+				"Subnets":        network["subnets"],
+				"SecurityGroups": network["security_groups"],
+				"AssignPublicIp": assignPublicIP,
+			},
+		}
+	}
+
+	var svc types.Service
+	if err := decodeIaCValue(props, &svc); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
+
+// firstBlock returns the single nested block Terraform stores as a list
+func firstBlock(value interface{}) map[string]interface{} {
+	if list, ok := value.([]interface{}); ok && len(list) > 0 {
+		block, _ := list[0].(map[string]interface{})
+		return block
+	}
+	return nil
+}
+
+// renameKey moves a map entry to a new key
+func renameKey(m map[string]interface{}, from, to string) {
+	if value, ok := m[from]; ok {
+		delete(m, from)
+		m[to] = value
+	}
+}
+
+// decodeIaCValue decodes IaC properties into an ECS API type. Keys match
+// fields case-insensitively and without underscores (Cpu, cpu, container_port),
+// scalars are converted to the field's type, and values that do not fit are
+// dropped.
+func decodeIaCValue(value interface{}, out interface{}) error {
+	coerced, ok := coerceIaCValue(value, reflect.TypeOf(out).Elem())
+	if !ok {
+		return fmt.Errorf("unexpected value %v", value)
+	}
+	data, err := json.Marshal(coerced)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// coerceIaCValue shapes a plain value after the given type
+func coerceIaCValue(value interface{}, t reflect.Type) (interface{}, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if value == nil {
+		return nil, false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// Terraform stores nested blocks as single-element lists
+		if list, ok := value.([]interface{}); ok && len(list) == 1 {
+			value = list[0]
+		}
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		out := map[string]interface{}{}
+		for key, item := range m {
+			field, ok := iacField(t, strings.ReplaceAll(key, "_", ""))
+			if !ok {
+				continue
+			}
+			if coerced, ok := coerceIaCValue(item, field.Type); ok {
+				out[field.Name] = coerced
+			}
+		}
+		return out, true
+
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, false
+		}
+		out := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			if coerced, ok := coerceIaCValue(item, t.Elem()); ok {
+				out = append(out, coerced)
+			}
+		}
+		return out, true
+
+	case reflect.Map:
+		m, ok := value.(map[string]interface{})
+		if !ok || t.Key().Kind() != reflect.String {
+			return nil, false
+		}
+		out := map[string]interface{}{}
+		for key, item := range m {
+			if coerced, ok := coerceIaCValue(item, t.Elem()); ok {
+				out[key] = coerced
+			}
+		}
+		return out, true
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case float64, int, bool:
+			return fmt.Sprint(v), true
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := value.(type) {
+		case float64, int:
+			return v, true
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n, true
+			}
+		}
+
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// iacField finds the exported struct field matching a normalized key
+func iacField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && strings.EqualFold(field.Name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
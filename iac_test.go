@@ -0,0 +1,256 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const testCloudFormationTemplate = `
+Parameters:
+  ImageTag:
+    Type: String
+    Default: "1.4"
+Resources:
+  Cluster:
+    Type: AWS::ECS::Cluster
+    Properties:
+      ClusterName: prod
+  WebTask:
+    Type: AWS::ECS::TaskDefinition
+    Properties:
+      Family: web
+      Cpu: 256
+      Memory: "512"
+      ContainerDefinitions:
+        - Name: app
+          Image: !Sub "nginx:${ImageTag}"
+          Cpu: "128"
+          Essential: true
+          PortMappings:
+            - ContainerPort: 8080
+          Environment:
+            - Name: REGION
+              Value: !Ref AWS::Region
+  WebService:
+    Type: AWS::ECS::Service
+    Properties:
+      ServiceName: web
+      Cluster: !GetAtt Cluster.Arn
+      TaskDefinition: !Ref WebTask
+      DesiredCount: 3
+      LoadBalancers:
+        - TargetGroupArn: !Ref WebTargetGroup
+          ContainerName: app
+          ContainerPort: 8080
+`
+
+// TestLoadCloudFormationTemplate tests reading services and task definitions from a YAML template
+func TestLoadCloudFormationTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stack.yaml")
+	if err := os.WriteFile(path, []byte(testCloudFormationTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := loadCloudFormationTemplate(path)
+	if err != nil {
+		t.Fatalf("loadCloudFormationTemplate() error = %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].Name != "prod" {
+		t.Fatalf("clusters = %+v, want prod", clusters)
+	}
+
+	cluster := clusters[0]
+	svc := cluster.Services[0]
+	if aws.ToString(svc.TaskDefinition) != "web" || svc.DesiredCount != 3 || len(svc.LoadBalancers) != 1 {
+		t.Errorf("service = %+v", svc)
+	}
+
+	taskDefInfo, err := cluster.taskDefInfo("web", cluster.Services)
+	if err != nil {
+		t.Fatalf("taskDefInfo() error = %v", err)
+	}
+	taskDef := taskDefInfo.Source
+	if aws.ToString(taskDef.Cpu) != "256" || aws.ToString(taskDef.Memory) != "512" {
+		t.Errorf("task cpu/memory = %v/%v, want 256/512", aws.ToString(taskDef.Cpu), aws.ToString(taskDef.Memory))
+	}
+	container := taskDef.ContainerDefinitions[0]
+	if aws.ToString(container.Image) != "nginx:1.4" || container.Cpu != 128 || aws.ToInt32(container.PortMappings[0].ContainerPort) != 8080 {
+		t.Errorf("container = %+v", container)
+	}
+	if value := aws.ToString(container.Environment[0].Value); value != "${AWS::Region}" {
+		t.Errorf("unresolved Ref = %q, want placeholder", value)
+	}
+}
+
+const testTerraformState = `{
+  "version": 4,
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_ecs_task_definition",
+      "name": "api",
+      "instances": [{"attributes": {
+        "arn": "arn:aws:ecs:us-east-1:123456789012:task-definition/api:7",
+        "family": "api",
+        "revision": 7,
+        "cpu": "512",
+        "memory": "1024",
+        "network_mode": "awsvpc",
+        "runtime_platform": [{"cpu_architecture": "ARM64", "operating_system_family": "LINUX"}],
+        "container_definitions": "[{\"name\":\"api\",\"image\":\"api:1\",\"portMappings\":[{\"containerPort\":80}]}]"
+      }}]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_ecs_service",
+      "name": "api",
+      "module": "module.api",
+      "instances": [{"attributes": {
+        "id": "arn:aws:ecs:us-east-1:123456789012:service/staging/api",
+        "name": "api",
+        "cluster": "arn:aws:ecs:us-east-1:123456789012:cluster/staging",
+        "task_definition": "arn:aws:ecs:us-east-1:123456789012:task-definition/api:7",
+        "desired_count": 2,
+        "deployment_circuit_breaker": [{"enable": true, "rollback": true}],
+        "network_configuration": [{"subnets": ["subnet-1"], "security_groups": ["sg-1"], "assign_public_ip": false}],
+        "capacity_provider_strategy": [{"capacity_provider": "FARGATE_SPOT", "weight": 1, "base": 0}],
+        "propagate_tags": "SERVICE",
+        "tags": {"team": "platform"}
+      }}]
+    },
+    {"mode": "data", "type": "aws_ecs_service", "name": "ignored", "instances": []}
+  ]
+}`
+
+// TestLoadTerraformState tests reading services and task definitions from a Terraform state file
+func TestLoadTerraformState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	if err := os.WriteFile(path, []byte(testTerraformState), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := loadTerraformState(path)
+	if err != nil {
+		t.Fatalf("loadTerraformState() error = %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].Name != "staging" {
+		t.Fatalf("clusters = %+v, want staging", clusters)
+	}
+
+	taskDefInfos := clusters[0].taskDefInfos()
+	if len(taskDefInfos) != 1 || taskDefInfos[0].Name != "api" {
+		t.Fatalf("taskDefInfos = %+v, want api", taskDefInfos)
+	}
+	info := taskDefInfos[0]
+	if info.Source.RuntimePlatform == nil || info.Source.RuntimePlatform.CpuArchitecture != "ARM64" {
+		t.Errorf("runtime platform = %+v, want ARM64", info.Source.RuntimePlatform)
+	}
+
+	svc := info.Services[0]
+	if svc.DesiredCount != 2 || svc.DeploymentConfiguration == nil || svc.DeploymentConfiguration.DeploymentCircuitBreaker == nil || !svc.DeploymentConfiguration.DeploymentCircuitBreaker.Rollback {
+		t.Errorf("service = %+v", svc)
+	}
+	if vpc := svc.NetworkConfiguration.AwsvpcConfiguration; vpc == nil || vpc.AssignPublicIp != "DISABLED" || vpc.Subnets[0] != "subnet-1" {
+		t.Errorf("network configuration = %+v", svc.NetworkConfiguration)
+	}
+	if info.Manifests.Deployment.Tolerations == nil {
+		t.Errorf("spot capacity provider strategy not applied")
+	}
+	if svc.PropagateTags != types.PropagateTagsService || len(svc.Tags) != 1 || aws.ToString(svc.Tags[0].Value) != "platform" {
+		t.Errorf("propagateTags = %q, tags = %+v, want the propagated team tag", svc.PropagateTags, svc.Tags)
+	}
+}
+
+// TestLoadExportedJSON tests reading services, task definitions and tags
+// exported with the AWS CLI
+func TestLoadExportedJSON(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"services.json": `{"services": [{
+  "serviceName": "web",
+  "clusterArn": "arn:aws:ecs:us-east-1:123456789012:cluster/prod",
+  "taskDefinition": "arn:aws:ecs:us-east-1:123456789012:task-definition/web:7",
+  "desiredCount": 3,
+  "launchType": "FARGATE"
+}], "failures": []}`,
+		"web.json": `{"taskDefinition": {
+  "taskDefinitionArn": "arn:aws:ecs:us-east-1:123456789012:task-definition/web:7",
+  "family": "web",
+  "revision": 7,
+  "networkMode": "awsvpc",
+  "containerDefinitions": [{"name": "web", "image": "nginx:1.27", "cpu": 256, "memory": 512,
+    "portMappings": [{"containerPort": 80, "protocol": "tcp"}]}],
+  "registeredAt": "2026-10-01T09:00:00.123000+00:00"
+}, "tags": [{"key": "team", "value": "payments"}]}`,
+		"notes.json": `{"comment": "not an ECS response"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := loadExportedJSON(dir)
+	if err != nil {
+		t.Fatalf("loadExportedJSON() error = %v", err)
+	}
+	if len(clusters) != 1 || clusters[0].Name != "prod" {
+		t.Fatalf("clusters = %+v, want prod", clusters)
+	}
+	taskDefInfos := clusters[0].taskDefInfos()
+	if len(taskDefInfos) != 1 || taskDefInfos[0].Name != "web" {
+		t.Fatalf("taskDefInfos = %+v, want web", taskDefInfos)
+	}
+	info := taskDefInfos[0]
+	if info.Services[0].DesiredCount != 3 || aws.ToString(info.Source.ContainerDefinitions[0].Image) != "nginx:1.27" {
+		t.Errorf("taskDefInfo = %+v", info)
+	}
+	if info.Tags["team"] != "payments" {
+		t.Errorf("tags = %v, want team=payments", info.Tags)
+	}
+	if got := iacRegion(clusters); got != "us-east-1" {
+		t.Errorf("iacRegion() = %q, want us-east-1", got)
+	}
+
+	if _, err := loadExportedJSON(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("loadExportedJSON() succeeded for a missing file")
+	}
+}
+
+// TestIaCRegion tests defaulting the region to the one of the declared ARNs
+func TestIaCRegion(t *testing.T) {
+	withARNs := []*iacCluster{{
+		Name:            "prod",
+		Services:        []types.Service{{ServiceName: aws.String("web"), TaskDefinition: aws.String("web")}},
+		TaskDefinitions: map[string]*types.TaskDefinition{"web": {}, "arn:aws-cn:ecs:cn-north-1:123456789012:task-definition/web:1": {}},
+	}}
+	if got := iacRegion(withARNs); got != "cn-north-1" {
+		t.Errorf("iacRegion() = %q, want cn-north-1", got)
+	}
+
+	// CloudFormation templates reference task definitions by logical ID
+	withoutARNs := []*iacCluster{{
+		Name:            "prod",
+		Services:        []types.Service{{ServiceName: aws.String("web"), TaskDefinition: aws.String("WebTaskDef")}},
+		TaskDefinitions: map[string]*types.TaskDefinition{"WebTaskDef": {}},
+	}}
+	if got := iacRegion(withoutARNs); got != "" {
+		t.Errorf("iacRegion() = %q, want none", got)
+	}
+}
+
+// TestSelectIaCClusters tests choosing a declared cluster by name or ARN
+func TestSelectIaCClusters(t *testing.T) {
+	clusters := []*iacCluster{{Name: "prod"}, {Name: "staging"}}
+	selected, err := selectIaCClusters(clusters, "arn:aws:ecs:us-east-1:123456789012:cluster/staging")
+	if err != nil || len(selected) != 1 || selected[0].Name != "staging" {
+		t.Errorf("selectIaCClusters(staging ARN) = %+v, %v", selected, err)
+	}
+	if _, err := selectIaCClusters(clusters, "dev"); err == nil {
+		t.Errorf("selectIaCClusters(dev) expected an error")
+	}
+}
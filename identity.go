@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// identityMap maps ECS IAM roles, by ARN or by role name, to the cloud
+// identities that replace them outside EKS: Google service account emails on
+// GKE and managed identity client IDs on AKS (--identity-map)
+type identityMap map[string]string
+
+// IdentityBinding records how a ServiceAccount replaces the ECS IAM role of a
+// service, listed in the report
+type IdentityBinding struct {
+	ServiceAccount string
+	RoleARN        string
+	Annotation     string
+	Identity       string
+	// Mapped reports whether Identity came from the identity map rather than a placeholder
+	Mapped bool
+}
+
+// loadIdentityMap reads a YAML mapping of role ARNs or names to identities.
+// It returns nil when path is empty.
+func loadIdentityMap(path string) (identityMap, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity map %s: %w", path, err)
+	}
+
+	identities := identityMap{}
+	if err := yaml.Unmarshal(data, &identities); err != nil {
+		return nil, fmt.Errorf("failed to parse identity map %s: %w", path, err)
+	}
+	for role, identity := range identities {
+		if identity == "" {
+			return nil, fmt.Errorf("role %s in %s has no identity", role, path)
+		}
+	}
+	return identities, nil
+}
+
+// lookup returns the identity of a role ARN, matching the full ARN before the role name
+func (m identityMap) lookup(roleARN string) (string, bool) {
+	if identity, ok := m[roleARN]; ok {
+		return identity, true
+	}
+	identity, ok := m[roleName(roleARN)]
+	return identity, ok
+}
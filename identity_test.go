@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestIdentityMap tests mapping ECS IAM roles to GKE and AKS workload identities
+func TestIdentityMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identities.yaml")
+	content := "arn:aws:iam::123456789012:role/web-role: web@shop.iam.gserviceaccount.com\nworker-role: worker@shop.iam.gserviceaccount.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	identities, err := loadIdentityMap(path)
+	if err != nil {
+		t.Fatalf("loadIdentityMap() error = %v", err)
+	}
+	if got, ok := identities.lookup("arn:aws:iam::123456789012:role/web-role"); !ok || got != "web@shop.iam.gserviceaccount.com" {
+		t.Errorf("lookup(ARN) = %q, %v", got, ok)
+	}
+	if got, ok := identities.lookup("arn:aws:iam::123456789012:role/jobs/worker-role"); !ok || got != "worker@shop.iam.gserviceaccount.com" {
+		t.Errorf("lookup(role name) = %q, %v", got, ok)
+	}
+	if none, err := loadIdentityMap(""); none != nil || err != nil {
+		t.Errorf("loadIdentityMap(\"\") = %v, %v, want no map", none, err)
+	}
+
+	newInfo := func(name, roleARN string) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: name,
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{},
+				ServiceAccount: &corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{Name: name + "-sa", Annotations: map[string]string{irsaAnnotation: roleARN}},
+				},
+			},
+		}
+	}
+	web := newInfo("web", "arn:aws:iam::123456789012:role/web-role")
+	api := newInfo("api", "arn:aws:iam::123456789012:role/api-role")
+	for _, info := range []*TaskDefInfo{web, api} {
+		platformProfiles[profileGKE].apply(info, identities)
+	}
+
+	if got := web.Manifests.ServiceAccount.Annotations["iam.gke.io/gcp-service-account"]; got != "web@shop.iam.gserviceaccount.com" || !web.Identity.Mapped {
+		t.Errorf("mapped identity = %q (%+v)", got, web.Identity)
+	}
+	if api.Identity.Mapped || api.Identity.Identity != "api-role@PROJECT_ID.iam.gserviceaccount.com" {
+		t.Errorf("unmapped identity = %+v, want a placeholder", api.Identity)
+	}
+
+	dir := t.TempDir()
+	if err := writeConversionReport(dir, "shop", "us-east-1", nil, []*TaskDefInfo{web, api}, nil); err != nil {
+		t.Fatalf("writeConversionReport() error = %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## Workload Identity",
+		"| web | web-sa | arn:aws:iam::123456789012:role/web-role | `iam.gke.io/gcp-service-account` | `web@shop.iam.gserviceaccount.com` |",
+		"`api-role@PROJECT_ID.iam.gserviceaccount.com` (placeholder)",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
@@ -178,7 +178,7 @@ func TestHelmChartGeneration(t *testing.T) {
 		t.Fatalf("writeManifests failed: %v", err)
 	}
 
-	if err := CreateHelmChart("my-cluster", []*TaskDefInfo{taskDefInfo}, tmpDir); err != nil {
+	if err := CreateHelmChart("my-cluster", []*TaskDefInfo{taskDefInfo}, tmpDir, helmChartOptions{}); err != nil {
 		t.Fatalf("CreateHelmChart failed: %v", err)
 	}
 
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"gopkg.in/yaml.v3"
+)
+
+// infraDirName is the output subdirectory for cluster-level infrastructure manifests
+const infraDirName = "infra"
+
+// karpenterNodeClassName is the name of the generated EC2NodeClass
+const karpenterNodeClassName = "default"
+
+// defaultInstanceFamilies are used when no instance type placement constraint
+// restricts the families, per architecture
+var defaultInstanceFamilies = map[string][]string{
+	"amd64": {"m6i", "c6i", "r6i"},
+	"arm64": {"m7g", "c7g", "r7g"},
+}
+
+// placementAttributePattern matches the ECS built-in attributes of cluster
+// query language expressions, e.g. "attribute:ecs.instance-type =~ m5.*"
+var placementAttributePattern = regexp.MustCompile(`attribute:ecs\.(instance-type|availability-zone|cpu-architecture)\s*(==|=~|in)\s*(\[[^\]]*\]|\S+)`)
+
+// nodeRequirements are the node properties observed across a source cluster
+type nodeRequirements struct {
+	Architectures map[string]bool
+	Families      map[string]bool
+	Zones         map[string]bool
+	Spot          bool
+	OnDemand      bool
+}
+
+// isSpotCapacityProvider reports whether a capacity provider runs on spot
+// capacity: FARGATE_SPOT, or an EC2 capacity provider named after spot
+func isSpotCapacityProvider(name string) bool {
+	return name == "FARGATE_SPOT" || strings.Contains(strings.ToLower(name), "spot")
+}
+
+// observeNodeRequirements collects architectures, instance families, zones and
+// capacity types from the task definitions and services of a cluster
+func observeNodeRequirements(taskDefInfos []*TaskDefInfo) nodeRequirements {
+	req := nodeRequirements{
+		Architectures: map[string]bool{},
+		Families:      map[string]bool{},
+		Zones:         map[string]bool{},
+	}
+
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDef := taskDefInfo.Source; taskDef != nil {
+			arch := "amd64"
+			if taskDef.RuntimePlatform != nil && taskDef.RuntimePlatform.CpuArchitecture == types.CPUArchitectureArm64 {
+				arch = "arm64"
+			}
+			req.Architectures[arch] = true
+
+			for _, c := range taskDef.PlacementConstraints {
+				req.addPlacementExpression(aws.ToString(c.Expression))
+			}
+		}
+
+		for _, svc := range taskDefInfo.Services {
+			for _, c := range svc.PlacementConstraints {
+				req.addPlacementExpression(aws.ToString(c.Expression))
+			}
+
+			if len(svc.CapacityProviderStrategy) == 0 {
+				req.OnDemand = true
+				continue
+			}
+			for _, item := range svc.CapacityProviderStrategy {
+				if isSpotCapacityProvider(aws.ToString(item.CapacityProvider)) {
+					req.Spot = true
+				} else {
+					req.OnDemand = true
+				}
+			}
+		}
+	}
+
+	if len(req.Architectures) == 0 {
+		req.Architectures["amd64"] = true
+	}
+	if !req.Spot {
+		req.OnDemand = true
+	}
+	return req
+}
+
+// addPlacementExpression records the instance families, zones and
+// architectures required by a memberOf placement expression
+func (r *nodeRequirements) addPlacementExpression(expression string) {
+	for _, match := range placementAttributePattern.FindAllStringSubmatch(expression, -1) {
+		attribute, values := match[1], placementValues(match[3])
+		for _, value := range values {
+			switch attribute {
+			case "instance-type":
+				// "m5.large", "m5.*" and "m5*" all select the m5 family
+				family, _, _ := strings.Cut(value, ".")
+				family = strings.TrimRight(family, "*")
+				if family != "" {
+					r.Families[family] = true
+				}
+			case "availability-zone":
+				r.Zones[value] = true
+			case "cpu-architecture":
+				if strings.EqualFold(value, "arm64") {
+					r.Architectures["arm64"] = true
+				} else {
+					r.Architectures["amd64"] = true
+				}
+			}
+		}
+	}
+}
+
+// placementValues splits a single value or a [a, b] list
+func placementValues(raw string) []string {
+	raw = strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.Trim(strings.TrimSpace(v), `"'`); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// sortedSet returns the members of a set in sorted order
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// karpenterManifests builds a NodePool and EC2NodeClass matching the node
+// requirements observed in the source cluster, keyed by file name
+func karpenterManifests(clusterName string, taskDefInfos []*TaskDefInfo) map[string]interface{} {
+	req := observeNodeRequirements(taskDefInfos)
+
+	architectures := sortedSet(req.Architectures)
+	families := sortedSet(req.Families)
+	if len(families) == 0 {
+		for _, arch := range architectures {
+			families = append(families, defaultInstanceFamilies[arch]...)
+		}
+	}
+
+	var capacityTypes []string
+	if req.OnDemand {
+		capacityTypes = append(capacityTypes, "on-demand")
+	}
+	if req.Spot {
+		capacityTypes = append(capacityTypes, "spot")
+	}
+
+	requirements := []map[string]interface{}{
+		{"key": "kubernetes.io/arch", "operator": "In", "values": architectures},
+		{"key": "kubernetes.io/os", "operator": "In", "values": []string{"linux"}},
+		{"key": "karpenter.sh/capacity-type", "operator": "In", "values": capacityTypes},
+		{"key": "karpenter.k8s.aws/instance-family", "operator": "In", "values": families},
+	}
+	if zones := sortedSet(req.Zones); len(zones) > 0 {
+		requirements = append(requirements, map[string]interface{}{"key": zoneLabel, "operator": "In", "values": zones})
+	}
+
+	nodePool := map[string]interface{}{
+		"apiVersion": apiVersionKarpenter,
+		"kind":       "NodePool",
+		"metadata": map[string]interface{}{
+			"name": clusterName,
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"requirements": requirements,
+					"nodeClassRef": map[string]string{
+						"group": "karpenter.k8s.aws",
+						"kind":  "EC2NodeClass",
+						"name":  karpenterNodeClassName,
+					},
+				},
+			},
+			"disruption": map[string]interface{}{
+				"consolidationPolicy": "WhenEmptyOrUnderutilized",
+				"consolidateAfter":    "1m",
+			},
+		},
+	}
+
+	// Subnets and security groups are discovered by the tag the Karpenter
+	// getting-started guide puts on the EKS cluster's resources
+	discovery := []map[string]interface{}{
+		{"tags": map[string]string{"karpenter.sh/discovery": clusterName}},
+	}
+	nodeClass := map[string]interface{}{
+		"apiVersion": apiVersionKarpenterAWS,
+		"kind":       "EC2NodeClass",
+		"metadata": map[string]interface{}{
+			"name": karpenterNodeClassName,
+		},
+		"spec": map[string]interface{}{
+			"amiSelectorTerms":           []map[string]string{{"alias": "al2023@latest"}},
+			"role":                       "KarpenterNodeRole-" + clusterName,
+			"subnetSelectorTerms":        discovery,
+			"securityGroupSelectorTerms": discovery,
+		},
+	}
+
+	return map[string]interface{}{
+		"karpenter-nodepool.yaml":     nodePool,
+		"karpenter-ec2nodeclass.yaml": nodeClass,
+	}
+}
+
+// writeInfraManifests writes cluster-level manifests into <output>/infra
+func writeInfraManifests(outputDir string, docs map[string]interface{}) error {
+	infraDir := filepath.Join(outputDir, infraDirName)
+	if err := os.MkdirAll(infraDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create infra directory %s: %w", infraDir, err)
+	}
+
+	for _, filename := range sortedDocKeys(docs) {
+		data, err := yaml.Marshal(docs[filename])
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
+		}
+		filePath := filepath.Join(infraDir, filename)
+		if err := os.WriteFile(filePath, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+		}
+		log.Printf("Wrote: %s", filePath)
+	}
+
+	return nil
+}
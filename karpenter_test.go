@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestKarpenterManifests tests deriving NodePool requirements from the source cluster
+func TestKarpenterManifests(t *testing.T) {
+	taskDefInfos := []*TaskDefInfo{
+		{
+			Name: "api",
+			Source: &types.TaskDefinition{
+				RuntimePlatform: &types.RuntimePlatform{CpuArchitecture: types.CPUArchitectureArm64},
+			},
+			Services: []types.Service{{
+				CapacityProviderStrategy: []types.CapacityProviderStrategyItem{
+					{CapacityProvider: aws.String("FARGATE"), Weight: 1},
+					{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 3},
+				},
+			}},
+		},
+		{
+			Name:   "worker",
+			Source: &types.TaskDefinition{},
+			Services: []types.Service{{
+				PlacementConstraints: []types.PlacementConstraint{{
+					Type:       types.PlacementConstraintTypeMemberOf,
+					Expression: aws.String("attribute:ecs.instance-type =~ m5.* and attribute:ecs.availability-zone in [us-east-1a, us-east-1b]"),
+				}},
+			}},
+		},
+	}
+
+	docs := karpenterManifests("prod", taskDefInfos)
+	nodePool := docs["karpenter-nodepool.yaml"].(map[string]interface{})
+	spec := nodePool["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+
+	got := map[string][]string{}
+	for _, r := range spec["requirements"].([]map[string]interface{}) {
+		got[r["key"].(string)] = r["values"].([]string)
+	}
+
+	want := map[string][]string{
+		"kubernetes.io/arch":                {"amd64", "arm64"},
+		"kubernetes.io/os":                  {"linux"},
+		"karpenter.sh/capacity-type":        {"on-demand", "spot"},
+		"karpenter.k8s.aws/instance-family": {"m5"},
+		"topology.kubernetes.io/zone":       {"us-east-1a", "us-east-1b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requirements = %v, want %v", got, want)
+	}
+
+	if _, ok := docs["karpenter-ec2nodeclass.yaml"]; !ok {
+		t.Errorf("EC2NodeClass not generated")
+	}
+}
+
+// TestKarpenterDefaultFamilies tests the instance families used without placement constraints
+func TestKarpenterDefaultFamilies(t *testing.T) {
+	req := observeNodeRequirements([]*TaskDefInfo{{Name: "web", Source: &types.TaskDefinition{}}})
+	if !req.OnDemand || req.Spot {
+		t.Errorf("capacity types = on-demand %v, spot %v, want on-demand only", req.OnDemand, req.Spot)
+	}
+	if !reflect.DeepEqual(sortedSet(req.Architectures), []string{"amd64"}) {
+		t.Errorf("architectures = %v, want amd64", sortedSet(req.Architectures))
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aatypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	// kedaDefaultQueueLength is the target messages per replica without a scaling policy
+	kedaDefaultQueueLength = "5"
+	// kedaDefaultLagThreshold is the target Kafka consumer lag per replica
+	kedaDefaultLagThreshold = "10"
+	// kedaDefaultMaxReplicas is used when the service has no scalable target
+	kedaDefaultMaxReplicas = 10
+)
+
+// sqsQueueURLPattern matches SQS queue URLs in environment variable values
+var sqsQueueURLPattern = regexp.MustCompile(`^https://sqs\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?/(\d{12})/([A-Za-z0-9_.-]+)$`)
+
+// kedaTrigger is a queue trigger detected for a service
+type kedaTrigger struct {
+	Type     string
+	Metadata map[string]string
+}
+
+// kedaGenerator creates KEDA ScaledObjects for queue-driven services
+type kedaGenerator struct {
+	client  *applicationautoscaling.Client
+	cluster string
+	region  string
+}
+
+// apply adds a ScaledObject (and TriggerAuthentication for SQS) to a converted
+// task definition when queue consumption is detected
+func (g *kedaGenerator) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	if taskDefInfo.Source == nil || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	var triggers []kedaTrigger
+	var targets []aatypes.ScalableTarget
+
+	for _, svc := range taskDefInfo.Services {
+		resourceID := fmt.Sprintf("service/%s/%s", g.cluster, aws.ToString(svc.ServiceName))
+
+		if target, err := g.scalableTarget(ctx, resourceID); err != nil {
+			log.Printf("Warning: Failed to describe scalable target %s: %v", resourceID, err)
+		} else if target != nil {
+			targets = append(targets, *target)
+		}
+
+		policyTriggers, err := g.policyTriggers(ctx, resourceID, aws.ToString(svc.ServiceArn))
+		if err != nil {
+			log.Printf("Warning: Failed to describe scaling policies of %s: %v", resourceID, err)
+		}
+		triggers = append(triggers, policyTriggers...)
+	}
+
+	// Scaling policies carry the real targets; env vars are only a fallback
+	if len(triggers) == 0 {
+		triggers = envTriggers(taskDefInfo.Source.ContainerDefinitions, taskDefInfo.Name)
+	}
+	if len(triggers) == 0 {
+		return
+	}
+
+	minReplicas, maxReplicas := kedaReplicaRange(targets)
+	if len(targets) > 1 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("The ScaledObject scales between %d and %d replicas, the sums of the scalable targets of the %d ECS services sharing the Deployment", minReplicas, maxReplicas, len(targets)))
+	}
+
+	var triggerList []map[string]interface{}
+	needsAWSAuth := false
+	for _, trigger := range triggers {
+		t := map[string]interface{}{
+			"type":     trigger.Type,
+			"metadata": trigger.Metadata,
+		}
+		if trigger.Type == "aws-sqs-queue" {
+			t["authenticationRef"] = map[string]string{"name": taskDefInfo.Name + "-keda-aws"}
+			needsAWSAuth = true
+		}
+		triggerList = append(triggerList, t)
+	}
+
+	labels := map[string]string{"app": taskDefInfo.Name}
+	scaledObject := map[string]interface{}{
+		"apiVersion": apiVersionKEDA,
+		"kind":       "ScaledObject",
+		"metadata": map[string]interface{}{
+			"name":   taskDefInfo.Name,
+			"labels": labels,
+		},
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]string{
+				"kind": workloadKind(taskDefInfo.Manifests),
+				"name": taskDefInfo.Name,
+			},
+			"minReplicaCount": minReplicas,
+			"maxReplicaCount": maxReplicas,
+			"triggers":        triggerList,
+		},
+	}
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: "scaledobject", Object: scaledObject})
+
+	if needsAWSAuth {
+		// Reuse the workload's IRSA role to read queue attributes
+		triggerAuth := map[string]interface{}{
+			"apiVersion": apiVersionKEDA,
+			"kind":       "TriggerAuthentication",
+			"metadata": map[string]interface{}{
+				"name":   taskDefInfo.Name + "-keda-aws",
+				"labels": labels,
+			},
+			"spec": map[string]interface{}{
+				"podIdentity": map[string]string{
+					"provider":      "aws",
+					"identityOwner": "workload",
+				},
+			},
+		}
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: "triggerauthentication", Object: triggerAuth})
+	}
+
+	log.Printf("✓ Created KEDA ScaledObject for %s with %d trigger(s) (replicas %d-%d)", taskDefInfo.Name, len(triggers), minReplicas, maxReplicas)
+}
+
+// kedaReplicaRange returns the replica range of a ScaledObject from the
+// scalable targets of the ECS services of a task definition. The services
+// run their tasks side by side in one Deployment, so their capacities add up,
+// as their desired counts do.
+func kedaReplicaRange(targets []aatypes.ScalableTarget) (minReplicas, maxReplicas int32) {
+	if len(targets) == 0 {
+		return 1, kedaDefaultMaxReplicas
+	}
+	for _, target := range targets {
+		minReplicas += aws.ToInt32(target.MinCapacity)
+		maxReplicas += aws.ToInt32(target.MaxCapacity)
+	}
+	return minReplicas, maxReplicas
+}
+
+// scalableTarget returns the Application Auto Scaling target of an ECS service, if any
+func (g *kedaGenerator) scalableTarget(ctx context.Context, resourceID string) (*aatypes.ScalableTarget, error) {
+	return describeScalableTarget(ctx, g.client, resourceID)
+}
+
+// describeScalableTarget returns the desired count scalable target of an ECS
+// service resource ID, if any
+func describeScalableTarget(ctx context.Context, client *applicationautoscaling.Client, resourceID string) (*aatypes.ScalableTarget, error) {
+	out, err := client.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace:  aatypes.ServiceNamespaceEcs,
+		ResourceIds:       []string{resourceID},
+		ScalableDimension: aatypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.ScalableTargets) == 0 {
+		return nil, nil
+	}
+	return &out.ScalableTargets[0], nil
+}
+
+// policyTriggers derives queue triggers from target tracking policies on SQS
+// metrics of the service with the given ARN
+func (g *kedaGenerator) policyTriggers(ctx context.Context, resourceID, serviceArn string) ([]kedaTrigger, error) {
+	var triggers []kedaTrigger
+
+	paginator := applicationautoscaling.NewDescribeScalingPoliciesPaginator(g.client, &applicationautoscaling.DescribeScalingPoliciesInput{
+		ServiceNamespace:  aatypes.ServiceNamespaceEcs,
+		ResourceId:        aws.String(resourceID),
+		ScalableDimension: aatypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return triggers, err
+		}
+
+		for _, policy := range page.ScalingPolicies {
+			if trigger, ok := sqsPolicyTrigger(policy, g.region, serviceArn); ok {
+				log.Printf("Info: Scaling policy %s tracks SQS queue %s", aws.ToString(policy.PolicyName), trigger.Metadata["queueURL"])
+				triggers = append(triggers, trigger)
+			}
+		}
+	}
+
+	return triggers, nil
+}
+
+// sqsPolicyTrigger derives a queue trigger from a target tracking policy on
+// an SQS metric. The queue is in the account and partition of the service.
+func sqsPolicyTrigger(policy aatypes.ScalingPolicy, region, serviceArn string) (kedaTrigger, bool) {
+	tracking := policy.TargetTrackingScalingPolicyConfiguration
+	if tracking == nil || tracking.CustomizedMetricSpecification == nil {
+		return kedaTrigger{}, false
+	}
+	metric := tracking.CustomizedMetricSpecification
+	if aws.ToString(metric.Namespace) != "AWS/SQS" {
+		return kedaTrigger{}, false
+	}
+
+	queueName := ""
+	for _, dim := range metric.Dimensions {
+		if aws.ToString(dim.Name) == "QueueName" {
+			queueName = aws.ToString(dim.Value)
+		}
+	}
+	accountID := extractAccountID(serviceArn)
+	if queueName == "" || accountID == "" {
+		return kedaTrigger{}, false
+	}
+
+	queueLength := kedaDefaultQueueLength
+	if tracking.TargetValue != nil {
+		queueLength = strconv.FormatFloat(*tracking.TargetValue, 'f', -1, 64)
+	}
+
+	return kedaTrigger{
+		Type: "aws-sqs-queue",
+		Metadata: map[string]string{
+			"queueURL":    fmt.Sprintf("https://sqs.%s.%s/%s/%s", region, partitionDNSSuffix(arnPartition(serviceArn)), accountID, queueName),
+			"queueLength": queueLength,
+			"awsRegion":   region,
+		},
+	}, true
+}
+
+// envTriggers derives queue triggers from SQS queue URLs and Kafka settings in
+// container environment variables
+func envTriggers(containers []types.ContainerDefinition, taskDefName string) []kedaTrigger {
+	env := map[string]string{}
+	for _, c := range containers {
+		for _, kv := range c.Environment {
+			env[strings.ToUpper(aws.ToString(kv.Name))] = aws.ToString(kv.Value)
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var triggers []kedaTrigger
+	var kafkaBrokers, kafkaTopic, kafkaGroup string
+
+	for _, name := range names {
+		value := env[name]
+
+		if m := sqsQueueURLPattern.FindStringSubmatch(value); m != nil {
+			triggers = append(triggers, kedaTrigger{
+				Type: "aws-sqs-queue",
+				Metadata: map[string]string{
+					"queueURL":    value,
+					"queueLength": kedaDefaultQueueLength,
+					"awsRegion":   m[1],
+				},
+			})
+			continue
+		}
+
+		if !strings.Contains(name, "KAFKA") {
+			continue
+		}
+		switch {
+		case strings.Contains(name, "BOOTSTRAP") || strings.Contains(name, "BROKER"):
+			kafkaBrokers = value
+		case strings.Contains(name, "TOPIC"):
+			kafkaTopic = value
+		case strings.Contains(name, "GROUP"):
+			kafkaGroup = value
+		}
+	}
+
+	if kafkaBrokers != "" && kafkaTopic != "" {
+		if kafkaGroup == "" {
+			kafkaGroup = taskDefName
+		}
+		triggers = append(triggers, kedaTrigger{
+			Type: "kafka",
+			Metadata: map[string]string{
+				"bootstrapServers": kafkaBrokers,
+				"topic":            kafkaTopic,
+				"consumerGroup":    kafkaGroup,
+				"lagThreshold":     kedaDefaultLagThreshold,
+			},
+		})
+	}
+
+	return triggers
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aatypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestEnvTriggers tests detecting SQS queues and Kafka topics in the
+// environment of the containers
+func TestEnvTriggers(t *testing.T) {
+	env := func(pairs ...string) []types.ContainerDefinition {
+		var kvs []types.KeyValuePair
+		for i := 0; i < len(pairs); i += 2 {
+			kvs = append(kvs, types.KeyValuePair{Name: aws.String(pairs[i]), Value: aws.String(pairs[i+1])})
+		}
+		return []types.ContainerDefinition{{Name: aws.String("app"), Environment: kvs}}
+	}
+
+	tests := []struct {
+		name       string
+		containers []types.ContainerDefinition
+		want       []kedaTrigger
+	}{
+		{
+			name:       "sqs",
+			containers: env("QUEUE_URL", "https://sqs.eu-west-1.amazonaws.com/123456789012/orders"),
+			want: []kedaTrigger{{Type: "aws-sqs-queue", Metadata: map[string]string{
+				"queueURL": "https://sqs.eu-west-1.amazonaws.com/123456789012/orders", "queueLength": "5", "awsRegion": "eu-west-1",
+			}}},
+		},
+		{
+			name:       "sqs in aws-cn",
+			containers: env("queue_url", "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/orders"),
+			want: []kedaTrigger{{Type: "aws-sqs-queue", Metadata: map[string]string{
+				"queueURL": "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/orders", "queueLength": "5", "awsRegion": "cn-north-1",
+			}}},
+		},
+		{
+			name:       "kafka with default group",
+			containers: env("KAFKA_BOOTSTRAP_SERVERS", "b-1.msk:9092", "KAFKA_TOPIC", "orders"),
+			want: []kedaTrigger{{Type: "kafka", Metadata: map[string]string{
+				"bootstrapServers": "b-1.msk:9092", "topic": "orders", "consumerGroup": "worker", "lagThreshold": "10",
+			}}},
+		},
+		{
+			name:       "kafka with group",
+			containers: env("KAFKA_BROKERS", "b-1.msk:9092", "KAFKA_TOPIC", "orders", "KAFKA_GROUP_ID", "billing"),
+			want: []kedaTrigger{{Type: "kafka", Metadata: map[string]string{
+				"bootstrapServers": "b-1.msk:9092", "topic": "orders", "consumerGroup": "billing", "lagThreshold": "10",
+			}}},
+		},
+		{"kafka without topic", env("KAFKA_BROKERS", "b-1.msk:9092"), nil},
+		{"not a queue url", env("QUEUE_URL", "https://example.com/123456789012/orders"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envTriggers(tt.containers, "worker"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envTriggers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSQSPolicyTrigger tests deriving queue triggers from target tracking
+// policies, with the queue URL in the partition of the service
+func TestSQSPolicyTrigger(t *testing.T) {
+	policy := func(namespace, queue string, target *float64) aatypes.ScalingPolicy {
+		metric := &aatypes.CustomizedMetricSpecification{Namespace: aws.String(namespace)}
+		if queue != "" {
+			metric.Dimensions = []aatypes.MetricDimension{{Name: aws.String("QueueName"), Value: aws.String(queue)}}
+		}
+		return aatypes.ScalingPolicy{TargetTrackingScalingPolicyConfiguration: &aatypes.TargetTrackingScalingPolicyConfiguration{
+			CustomizedMetricSpecification: metric,
+			TargetValue:                   target,
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		policy     aatypes.ScalingPolicy
+		region     string
+		serviceArn string
+		wantURL    string
+		wantLength string
+	}{
+		{"aws", policy("AWS/SQS", "orders", aws.Float64(20)), "us-east-1", "arn:aws:ecs:us-east-1:123456789012:service/prod/worker", "https://sqs.us-east-1.amazonaws.com/123456789012/orders", "20"},
+		{"aws-cn", policy("AWS/SQS", "orders", nil), "cn-north-1", "arn:aws-cn:ecs:cn-north-1:123456789012:service/prod/worker", "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/orders", "5"},
+		{"govcloud", policy("AWS/SQS", "orders", aws.Float64(7.5)), "us-gov-west-1", "arn:aws-us-gov:ecs:us-gov-west-1:123456789012:service/prod/worker", "https://sqs.us-gov-west-1.amazonaws.com/123456789012/orders", "7.5"},
+		{"not sqs", policy("AWS/ECS", "orders", nil), "us-east-1", "arn:aws:ecs:us-east-1:123456789012:service/prod/worker", "", ""},
+		{"no queue", policy("AWS/SQS", "", nil), "us-east-1", "arn:aws:ecs:us-east-1:123456789012:service/prod/worker", "", ""},
+		{"no account", policy("AWS/SQS", "orders", nil), "us-east-1", "", "", ""},
+		{"step scaling", aatypes.ScalingPolicy{}, "us-east-1", "arn:aws:ecs:us-east-1:123456789012:service/prod/worker", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger, ok := sqsPolicyTrigger(tt.policy, tt.region, tt.serviceArn)
+			if ok != (tt.wantURL != "") {
+				t.Fatalf("sqsPolicyTrigger() ok = %v, want %v", ok, tt.wantURL != "")
+			}
+			if !ok {
+				return
+			}
+			if trigger.Metadata["queueURL"] != tt.wantURL || trigger.Metadata["queueLength"] != tt.wantLength || trigger.Metadata["awsRegion"] != tt.region {
+				t.Errorf("sqsPolicyTrigger() = %+v, want %s with length %s", trigger.Metadata, tt.wantURL, tt.wantLength)
+			}
+		})
+	}
+}
+
+// TestKEDAReplicaRange tests that the scalable targets of the services
+// sharing a Deployment add up
+func TestKEDAReplicaRange(t *testing.T) {
+	target := func(minCapacity, maxCapacity int32) aatypes.ScalableTarget {
+		return aatypes.ScalableTarget{MinCapacity: aws.Int32(minCapacity), MaxCapacity: aws.Int32(maxCapacity)}
+	}
+	tests := []struct {
+		name     string
+		targets  []aatypes.ScalableTarget
+		min, max int32
+	}{
+		{"no target", nil, 1, kedaDefaultMaxReplicas},
+		{"one service", []aatypes.ScalableTarget{target(2, 8)}, 2, 8},
+		{"two services", []aatypes.ScalableTarget{target(2, 8), target(1, 20)}, 3, 28},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if minReplicas, maxReplicas := kedaReplicaRange(tt.targets); minReplicas != tt.min || maxReplicas != tt.max {
+				t.Errorf("kedaReplicaRange() = %d-%d, want %d-%d", minReplicas, maxReplicas, tt.min, tt.max)
+			}
+		})
+	}
+}
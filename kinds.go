@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kindFilter limits the generated objects to some kinds (--include-kinds,
+// --exclude-kinds). A nil filter allows every kind.
+type kindFilter struct {
+	// include lists the allowed kinds, lowercased; empty allows all but exclude
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// parseKindFilter parses the --include-kinds and --exclude-kinds values.
+// Kinds are matched case-insensitively; a kind in both lists is excluded.
+func parseKindFilter(include, exclude []string) (*kindFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &kindFilter{include: map[string]bool{}, exclude: map[string]bool{}}
+	for _, list := range []struct {
+		flag  string
+		kinds []string
+		set   map[string]bool
+	}{
+		{"--include-kinds", include, f.include},
+		{"--exclude-kinds", exclude, f.exclude},
+	} {
+		for _, kind := range list.kinds {
+			kind = strings.TrimSpace(kind)
+			if kind == "" || strings.ContainsAny(kind, "/. ") {
+				return nil, fmt.Errorf("invalid %s kind %q (must be a kind such as Deployment)", list.flag, kind)
+			}
+			list.set[strings.ToLower(kind)] = true
+		}
+	}
+	return f, nil
+}
+
+// allows reports whether objects of a kind are generated
+func (f *kindFilter) allows(kind string) bool {
+	if f == nil {
+		return true
+	}
+	kind = strings.ToLower(kind)
+	if f.exclude[kind] {
+		return false
+	}
+	return len(f.include) == 0 || f.include[kind]
+}
+
+// filter removes the documents of kinds that are not generated
+func (f *kindFilter) filter(docs map[string]interface{}) {
+	if f == nil {
+		return
+	}
+	for name, doc := range docs {
+		if !f.allows(documentKind(doc)) {
+			delete(docs, name)
+		}
+	}
+}
+
+// helmGuard returns template lines rendering what they enclose only for the
+// allowed kinds, evaluated from kindExpr. Both are empty when all are allowed.
+func (f *kindFilter) helmGuard(kindExpr string, kinds ...string) (open, end string) {
+	var allowed []string
+	for _, kind := range kinds {
+		if f.allows(kind) {
+			allowed = append(allowed, fmt.Sprintf("%q", kind))
+		}
+	}
+	if len(allowed) == len(kinds) {
+		return "", ""
+	}
+	sort.Strings(allowed)
+	return fmt.Sprintf("{{- if has %s (list %s) }}\n", kindExpr, strings.Join(allowed, " ")), "{{- end }}\n"
+}
+
+// documentKind returns the kind of a rendered document
+func documentKind(doc interface{}) string {
+	if manifest, ok := doc.(map[string]interface{}); ok {
+		kind, _ := manifest["kind"].(string)
+		return kind
+	}
+	manifest, _ := toSerializable(doc).(map[string]interface{})
+	kind, _ := manifest["kind"].(string)
+	return kind
+}
+
+// conversionKinds returns the kind filter, which is shared by every service
+func conversionKinds(taskDefInfos []*TaskDefInfo) *kindFilter {
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Manifests.Kinds != nil {
+			return taskDefInfo.Manifests.Kinds
+		}
+	}
+	return nil
+}
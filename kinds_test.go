@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestKindFilter tests including and excluding kinds of generated objects
+func TestKindFilter(t *testing.T) {
+	for _, flag := range []string{"", "apps/v1", "Deployment.apps"} {
+		if _, err := parseKindFilter([]string{flag}, nil); err == nil {
+			t.Errorf("parseKindFilter(%q) succeeded", flag)
+		}
+	}
+
+	var none *kindFilter
+	if !none.allows("Secret") {
+		t.Errorf("nil filter excludes Secret")
+	}
+
+	filter, err := parseKindFilter([]string{"deployment", "Service", "Secret"}, []string{"secret"})
+	if err != nil {
+		t.Fatalf("parseKindFilter() error = %v", err)
+	}
+	for kind, want := range map[string]bool{"Deployment": true, "Service": true, "Secret": false, "ConfigMap": false} {
+		if got := filter.allows(kind); got != want {
+			t.Errorf("allows(%s) = %v, want %v", kind, got, want)
+		}
+	}
+
+	manifests := K8sManifests{
+		Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+		ConfigMaps: []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "web-config"}, Data: map[string]string{"A": "1"}}},
+		Secrets:    []*corev1.Secret{{ObjectMeta: metav1.ObjectMeta{Name: "web-secrets"}, StringData: map[string]string{"B": "2"}}},
+		Kinds:      filter,
+	}
+	files, err := renderManifests("web", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	for name, doc := range files {
+		if kind := documentKind(doc); kind != "Deployment" {
+			t.Errorf("%s of kind %s rendered", name, kind)
+		}
+	}
+	if _, ok := files["web-deployment.yaml"]; !ok {
+		t.Errorf("files = %v, want web-deployment.yaml", sortedDocKeys(files))
+	}
+
+	if open, end := none.helmGuard("$kind", "Deployment", "StatefulSet"); open != "" || end != "" {
+		t.Errorf("nil filter guard = %q, %q", open, end)
+	}
+	if open, _ := filter.helmGuard("$kind", "StatefulSet", "Deployment"); open != "{{- if has $kind (list \"Deployment\") }}\n" {
+		t.Errorf("guard = %q", open)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aatypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Workloads generated for a service, selected with --output
+const (
+	outputDeployment = "deployment"
+	outputKnative    = "knative"
+)
+
+// outputWorkloads lists the valid --output values
+var outputWorkloads = []string{outputDeployment, outputKnative}
+
+// Knative Pod Autoscaler annotations of a revision template, see
+// https://knative.dev/docs/serving/autoscaling/
+const (
+	knativeClassAnnotation    = "autoscaling.knative.dev/class"
+	knativeMetricAnnotation   = "autoscaling.knative.dev/metric"
+	knativeTargetAnnotation   = "autoscaling.knative.dev/target"
+	knativeMinScaleAnnotation = "autoscaling.knative.dev/min-scale"
+	knativeMaxScaleAnnotation = "autoscaling.knative.dev/max-scale"
+	// knativeHPAClass scales on CPU and memory through an HPA
+	knativeHPAClass = "hpa.autoscaling.knative.dev"
+)
+
+// isValidOutputWorkload checks if kind is a supported --output value
+func isValidOutputWorkload(kind string) bool {
+	for _, w := range outputWorkloads {
+		if kind == w {
+			return true
+		}
+	}
+	return false
+}
+
+// KnativeSettings renders a service's Deployment and Services as one Knative Service
+type KnativeSettings struct {
+	// Container is the container requests are routed to
+	Container string
+	// Port is the container port Knative routes requests to
+	Port int32
+	// Annotations configure the autoscaler on the revision template
+	Annotations map[string]string
+}
+
+// knativeConverter converts request-driven HTTP services into Knative Services
+type knativeConverter struct {
+	// client reads the ECS service's autoscaling; nil for IaC inputs
+	client  *applicationautoscaling.Client
+	cluster string
+}
+
+// apply converts a service whose pod serves a single container port into a
+// Knative Service, scaled like its ECS service. Other services stay Deployments.
+func (k *knativeConverter) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return
+	}
+
+	var serving []corev1.Container
+	for _, c := range podSpec.Containers {
+		if len(c.Ports) > 0 {
+			serving = append(serving, c)
+		}
+	}
+	if len(serving) != 1 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Kept as a Deployment: Knative routes requests to one container port, but %d containers expose ports", len(serving)))
+		return
+	}
+	container := serving[0]
+	if len(container.Ports) > 1 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Knative routes requests to port %d of %s only", container.Ports[0].ContainerPort, container.Name))
+	}
+
+	settings := &KnativeSettings{
+		Container:   container.Name,
+		Port:        container.Ports[0].ContainerPort,
+		Annotations: map[string]string{},
+	}
+	if k.client != nil {
+		k.applyScaling(ctx, taskDefInfo, settings)
+	}
+
+	// Knative serves requests through its own ingress
+	var extras []ExtraObject
+	for _, extra := range taskDefInfo.Manifests.Extras {
+		if extra.Object["kind"] == "Ingress" {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, "The ALB Ingress is replaced by the Knative Service's route; map the hosts with a DomainMapping")
+			continue
+		}
+		extras = append(extras, extra)
+	}
+	taskDefInfo.Manifests.Extras = extras
+	taskDefInfo.Manifests.Knative = settings
+
+	log.Printf("✓ Converted %s to a Knative Service on port %d", taskDefInfo.Name, settings.Port)
+}
+
+// applyScaling maps the scalable target and the first target tracking policy
+// of the ECS services onto Knative autoscaling annotations
+func (k *knativeConverter) applyScaling(ctx context.Context, taskDefInfo *TaskDefInfo, settings *KnativeSettings) {
+	for _, svc := range taskDefInfo.Services {
+		resourceID := fmt.Sprintf("service/%s/%s", k.cluster, aws.ToString(svc.ServiceName))
+
+		target, err := describeScalableTarget(ctx, k.client, resourceID)
+		if err != nil {
+			log.Printf("Warning: Failed to describe scalable target %s: %v", resourceID, err)
+			continue
+		}
+		if target == nil {
+			continue
+		}
+		settings.Annotations[knativeMinScaleAnnotation] = strconv.Itoa(int(aws.ToInt32(target.MinCapacity)))
+		settings.Annotations[knativeMaxScaleAnnotation] = strconv.Itoa(int(aws.ToInt32(target.MaxCapacity)))
+
+		paginator := applicationautoscaling.NewDescribeScalingPoliciesPaginator(k.client, &applicationautoscaling.DescribeScalingPoliciesInput{
+			ServiceNamespace:  aatypes.ServiceNamespaceEcs,
+			ResourceId:        aws.String(resourceID),
+			ScalableDimension: aatypes.ScalableDimensionECSServiceDesiredCount,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				log.Printf("Warning: Failed to describe scaling policies of %s: %v", resourceID, err)
+				break
+			}
+			for _, policy := range page.ScalingPolicies {
+				if policy.TargetTrackingScalingPolicyConfiguration == nil {
+					continue
+				}
+				if note := knativeScalingAnnotations(policy.TargetTrackingScalingPolicyConfiguration, taskDefInfo.Manifests.Deployment, settings.Annotations); note != "" {
+					taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Scaling policy %s: %s", aws.ToString(policy.PolicyName), note))
+				}
+				if settings.Annotations[knativeMetricAnnotation] != "" {
+					return
+				}
+			}
+		}
+		return
+	}
+}
+
+// knativeScalingAnnotations sets the autoscaler metric and target of a target
+// tracking policy. ALB request counts per target and minute become a
+// requests-per-second target; CPU and memory utilization use the HPA class.
+// It returns a note when the policy cannot be mapped.
+func knativeScalingAnnotations(tracking *aatypes.TargetTrackingScalingPolicyConfiguration, podSpec *corev1.PodSpec, annotations map[string]string) string {
+	if tracking.PredefinedMetricSpecification == nil || tracking.TargetValue == nil {
+		return "custom metrics have no Knative equivalent, scaling on concurrency"
+	}
+	value := *tracking.TargetValue
+
+	switch tracking.PredefinedMetricSpecification.PredefinedMetricType {
+	case aatypes.MetricTypeALBRequestCountPerTarget:
+		annotations[knativeMetricAnnotation] = "rps"
+		annotations[knativeTargetAnnotation] = strconv.Itoa(int(math.Max(1, math.Round(value/60))))
+	case aatypes.MetricTypeECSServiceAverageCPUUtilization:
+		annotations[knativeClassAnnotation] = knativeHPAClass
+		annotations[knativeMetricAnnotation] = "cpu"
+		annotations[knativeTargetAnnotation] = strconv.Itoa(int(math.Round(value)))
+	case aatypes.MetricTypeECSServiceAverageMemoryUtilization:
+		// The memory target is an average in Mi, not a percentage
+		var requested int64
+		for _, c := range podSpec.Containers {
+			requested += c.Resources.Requests.Memory().Value()
+		}
+		annotations[knativeClassAnnotation] = knativeHPAClass
+		annotations[knativeMetricAnnotation] = "memory"
+		annotations[knativeTargetAnnotation] = strconv.FormatInt(int64(math.Round(float64(requested)*value/100/(1<<20))), 10)
+	default:
+		return fmt.Sprintf("metric %s has no Knative equivalent, scaling on concurrency", tracking.PredefinedMetricSpecification.PredefinedMetricType)
+	}
+	return ""
+}
+
+// renderKnativeService builds the Knative Service replacing a service's
+// Deployment and Services. Only the serving container keeps a port.
+func renderKnativeService(taskDefName string, manifests K8sManifests) map[string]interface{} {
+	knative := manifests.Knative
+
+	podSpec := serializePodSpec(manifests.Deployment)
+	containers, _ := podSpec["containers"].([]map[string]interface{})
+	for _, c := range containers {
+		delete(c, "ports")
+		if c["name"] == knative.Container {
+			c["ports"] = []map[string]interface{}{{"containerPort": knative.Port}}
+		}
+	}
+
+	annotations := map[string]string{}
+	for key, value := range manifests.PodAnnotations {
+		annotations[key] = value
+	}
+	for key, value := range knative.Annotations {
+		annotations[key] = value
+	}
+
+	metadata := map[string]interface{}{
+		"name":      taskDefName,
+		"namespace": "default",
+		"labels": map[string]string{
+			"app": taskDefName,
+		},
+	}
+	if len(manifests.DeploymentAnnotations) > 0 {
+		metadata["annotations"] = manifests.DeploymentAnnotations
+	}
+
+	return map[string]interface{}{
+		"apiVersion": apiVersionKnativeServing,
+		"kind":       "Service",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": podTemplateMetadata(taskDefName, manifests.PodLabels, annotations),
+				"spec":     podSpec,
+			},
+		},
+	}
+}
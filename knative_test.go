@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aatypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestKnativeService tests rendering a single-port service as a Knative Service
+func TestKnativeService(t *testing.T) {
+	newInfo := func(containers ...corev1.Container) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: containers},
+				Services:   []*corev1.Service{{ObjectMeta: metav1.ObjectMeta{Name: "web"}}},
+				Extras:     []ExtraObject{{Suffix: "ingress", Object: map[string]interface{}{"kind": "Ingress"}}},
+			},
+		}
+	}
+	web := corev1.Container{Name: "web", Image: "web:1", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}}
+	sidecar := corev1.Container{Name: "envoy", Image: "envoy:1"}
+
+	info := newInfo(web, sidecar)
+	(&knativeConverter{}).apply(context.Background(), info)
+	if info.Manifests.Knative == nil || info.Manifests.Knative.Port != 8080 || len(info.Manifests.Extras) != 0 {
+		t.Fatalf("Knative = %+v, extras = %v", info.Manifests.Knative, info.Manifests.Extras)
+	}
+	info.Manifests.Knative.Annotations[knativeMinScaleAnnotation] = "2"
+
+	files, err := renderManifests(info.Name, info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	if _, ok := files["web-deployment.yaml"]; ok {
+		t.Errorf("Deployment rendered with --output=knative")
+	}
+	if _, ok := files["web-service.yaml"]; ok {
+		t.Errorf("Service rendered with --output=knative")
+	}
+	ksvc := files["web-knative-service.yaml"].(map[string]interface{})
+	if ksvc["apiVersion"] != "serving.knative.dev/v1" {
+		t.Errorf("apiVersion = %v", ksvc["apiVersion"])
+	}
+	template := ksvc["spec"].(map[string]interface{})["template"].(map[string]interface{})
+	if got := template["metadata"].(map[string]interface{})["annotations"].(map[string]string)[knativeMinScaleAnnotation]; got != "2" {
+		t.Errorf("min-scale = %q, want 2", got)
+	}
+	if len(template["spec"].(map[string]interface{})["containers"].([]map[string]interface{})) != 2 {
+		t.Errorf("containers = %v", template["spec"])
+	}
+
+	// Knative routes to a single container port
+	multi := newInfo(web, corev1.Container{Name: "admin", Ports: []corev1.ContainerPort{{ContainerPort: 9000}}})
+	(&knativeConverter{}).apply(context.Background(), multi)
+	if multi.Manifests.Knative != nil || !hasNote(multi.Notes, "Kept as a Deployment") {
+		t.Errorf("multi-port service converted: %+v, notes %v", multi.Manifests.Knative, multi.Notes)
+	}
+}
+
+// TestKnativeScalingAnnotations tests mapping target tracking policies onto the Knative autoscaler
+func TestKnativeScalingAnnotations(t *testing.T) {
+	podSpec := &corev1.PodSpec{Containers: []corev1.Container{{
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")}},
+	}}}
+	policy := func(metric aatypes.MetricType, target float64) *aatypes.TargetTrackingScalingPolicyConfiguration {
+		return &aatypes.TargetTrackingScalingPolicyConfiguration{
+			PredefinedMetricSpecification: &aatypes.PredefinedMetricSpecification{PredefinedMetricType: metric},
+			TargetValue:                   aws.Float64(target),
+		}
+	}
+
+	tests := []struct {
+		metric     aatypes.MetricType
+		target     float64
+		wantMetric string
+		wantTarget string
+		wantClass  string
+	}{
+		{aatypes.MetricTypeALBRequestCountPerTarget, 1200, "rps", "20", ""},
+		{aatypes.MetricTypeECSServiceAverageCPUUtilization, 70, "cpu", "70", knativeHPAClass},
+		{aatypes.MetricTypeECSServiceAverageMemoryUtilization, 50, "memory", "256", knativeHPAClass},
+	}
+	for _, tt := range tests {
+		annotations := map[string]string{}
+		if note := knativeScalingAnnotations(policy(tt.metric, tt.target), podSpec, annotations); note != "" {
+			t.Errorf("%s: note = %q", tt.metric, note)
+		}
+		if annotations[knativeMetricAnnotation] != tt.wantMetric || annotations[knativeTargetAnnotation] != tt.wantTarget || annotations[knativeClassAnnotation] != tt.wantClass {
+			t.Errorf("%s: annotations = %v", tt.metric, annotations)
+		}
+	}
+
+	if note := knativeScalingAnnotations(&aatypes.TargetTrackingScalingPolicyConfiguration{TargetValue: aws.Float64(5)}, podSpec, map[string]string{}); note == "" {
+		t.Errorf("custom metric policy mapped without a note")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// kubectlRunner executes kubectl commands, optionally pinned to a kubeconfig context
+type kubectlRunner struct {
+	Context string
+}
+
+// run executes kubectl with the given arguments, feeding stdin when provided,
+// and returns its standard output
+func (k *kubectlRunner) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return nil, fmt.Errorf("kubectl not found in PATH: %w", err)
+	}
+
+	fullArgs := args
+	if k.Context != "" {
+		fullArgs = append([]string{"--context", k.Context}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "kubectl", fullArgs...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("kubectl %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// fieldManager is the server-side apply field manager of the objects
+// ecs2k8s applies. managedFields then tell the fields ecs2k8s owns apart from
+// later manual edits, which a re-apply leaves alone unless it sets them too.
+const fieldManager = "ecs2k8s"
+
+// apply applies a YAML document stream with kubectl server-side apply. With
+// forceConflicts, fields another manager changed are taken back; otherwise
+// the apply fails on them.
+func (k *kubectlRunner) apply(ctx context.Context, manifests []byte, namespace string, forceConflicts bool) ([]byte, error) {
+	return k.run(ctx, manifests, applyArgs(namespace, forceConflicts)...)
+}
+
+// applyArgs returns the kubectl arguments of apply
+func applyArgs(namespace string, forceConflicts bool) []string {
+	args := []string{"apply", "--server-side", "--field-manager", fieldManager, "-f", "-"}
+	if forceConflicts {
+		args = append(args, "--force-conflicts")
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	return args
+}
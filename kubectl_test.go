@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestApplyArgs tests the server-side apply arguments of kubectl apply
+func TestApplyArgs(t *testing.T) {
+	tests := []struct {
+		namespace      string
+		forceConflicts bool
+		want           string
+	}{
+		{want: "apply --server-side --field-manager ecs2k8s -f -"},
+		{namespace: "prod", want: "apply --server-side --field-manager ecs2k8s -f - --namespace prod"},
+		{namespace: "prod", forceConflicts: true, want: "apply --server-side --field-manager ecs2k8s -f - --force-conflicts --namespace prod"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(applyArgs(tt.namespace, tt.forceConflicts), " "); got != tt.want {
+			t.Errorf("applyArgs(%q, %v) = %q, want %q", tt.namespace, tt.forceConflicts, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -18,11 +19,17 @@ type KustomizeConfig struct {
 	Bases        []string                 `yaml:"bases,omitempty"`
 	Resources    []string                 `yaml:"resources,omitempty"`
 	Patches      []map[string]interface{} `yaml:"patches,omitempty"`
+	Components   []string                 `yaml:"components,omitempty"`
 	Namespace    string                   `yaml:"namespace,omitempty"`
 	Images       []map[string]interface{} `yaml:"images,omitempty"`
 	CommonLabels map[string]string        `yaml:"commonLabels,omitempty"`
+	// CommonAnnotations keeps the full values of labels that had to be shortened
+	CommonAnnotations map[string]string `yaml:"commonAnnotations,omitempty"`
 }
 
+// irsaAnnotation is the EKS IRSA ServiceAccount annotation, applied by the irsa component
+const irsaAnnotation = "eks.amazonaws.com/role-arn"
+
 // NamespacePatch represents a namespace patch for overlays
 type NamespacePatch struct {
 	Op    string      `json:"op"`
@@ -31,7 +38,8 @@ type NamespacePatch struct {
 }
 
 // createKustomizeStructure creates a kustomize directory structure with base and overlays
-func createKustomizeStructure(clusterName string, taskDefInfos []*TaskDefInfo, outputDir string) error {
+func createKustomizeStructure(model *ConversionModel, outputDir string) error {
+	clusterName, taskDefInfos := model.Cluster, model.infos()
 	if !strings.Contains(outputDir, clusterName) {
 		outputDir = filepath.Join(outputDir, clusterName)
 	}
@@ -55,10 +63,16 @@ func createKustomizeStructure(clusterName string, taskDefInfos []*TaskDefInfo, o
 	}
 
 	// Create base kustomization
-	if err := createBaseKustomization(kustomizeBasePath, taskDefInfos); err != nil {
+	if err := createBaseKustomization(kustomizeBasePath, model); err != nil {
 		return fmt.Errorf("failed to create base kustomization: %w", err)
 	}
 
+	// Create optional components overlays can opt into
+	components, err := createKustomizeComponents(filepath.Join(outputDir, "kustomize", clusterName, "components"), taskDefInfos)
+	if err != nil {
+		return fmt.Errorf("failed to create kustomize components: %w", err)
+	}
+
 	// Create overlay kustomizations
 	overlayNamespaces := map[string]string{
 		"dev":     "development",
@@ -68,7 +82,7 @@ func createKustomizeStructure(clusterName string, taskDefInfos []*TaskDefInfo, o
 
 	for overlayName, namespace := range overlayNamespaces {
 		overlayPath := filepath.Join(overlaysPath, overlayName)
-		if err := createOverlayKustomization(overlayPath, overlayName, namespace, taskDefInfos); err != nil {
+		if err := createOverlayKustomization(overlayPath, overlayName, namespace, taskDefInfos, components); err != nil {
 			return fmt.Errorf("failed to create %s overlay: %w", overlayName, err)
 		}
 	}
@@ -82,8 +96,27 @@ func createKustomizeStructure(clusterName string, taskDefInfos []*TaskDefInfo, o
 	return nil
 }
 
-// createBaseKustomization creates the base kustomization.yaml and base manifests
-func createBaseKustomization(basePath string, taskDefInfos []*TaskDefInfo) error {
+// kustomizeResourceDirs are the base directories of the documents by kind;
+// other kinds, such as custom resources, go to extras
+var kustomizeResourceDirs = map[string]string{
+	"Deployment":         "deployments",
+	"StatefulSet":        "deployments",
+	"Service":            "services",
+	"ConfigMap":          "configmaps",
+	"Secret":             "secrets",
+	"ServiceAccount":     "serviceaccounts",
+	"Role":               "rbac",
+	"RoleBinding":        "rbac",
+	"ClusterRole":        "rbac",
+	"ClusterRoleBinding": "rbac",
+	"Job":                "jobs",
+}
+
+// createBaseKustomization creates the base kustomization.yaml and base
+// manifests. The manifests are the documents of the raw output before the
+// user overrides, which the base applies as patches so they survive
+// regeneration.
+func createBaseKustomization(basePath string, model *ConversionModel) error {
 	// Create subdirectories for different resource types
 	resourceDirs := []string{
 		filepath.Join(basePath, "deployments"),
@@ -91,6 +124,10 @@ func createBaseKustomization(basePath string, taskDefInfos []*TaskDefInfo) error
 		filepath.Join(basePath, "configmaps"),
 		filepath.Join(basePath, "secrets"),
 		filepath.Join(basePath, "serviceaccounts"),
+		filepath.Join(basePath, "rbac"),
+		filepath.Join(basePath, "jobs"),
+		filepath.Join(basePath, "extras"),
+		filepath.Join(basePath, "patches"),
 	}
 
 	for _, dir := range resourceDirs {
@@ -101,83 +138,56 @@ func createBaseKustomization(basePath string, taskDefInfos []*TaskDefInfo) error
 
 	// Write base manifests
 	var resourceList []string
+	var patches []map[string]interface{}
 
-	for _, taskDefInfo := range taskDefInfos {
-		taskName := taskDefInfo.Name
+	for _, workload := range model.Workloads {
+		taskName := workload.Name
+		kinds := workload.Info.Manifests.Kinds
 
-		// Write deployment
-		deployment := generateBaseDeployment(taskName, taskDefInfo)
-		deploymentFile := filepath.Join(basePath, "deployments", fmt.Sprintf("%s-deployment.yaml", taskName))
-		if data, err := yaml.Marshal(deployment); err == nil {
-			if err := os.WriteFile(deploymentFile, data, 0o644); err != nil {
-				log.Printf("Warning: Failed to write deployment %s: %v", deploymentFile, err)
-			} else {
-				resourceList = append(resourceList, fmt.Sprintf("deployments/%s-deployment.yaml", taskName))
+		for _, filename := range sortedDocKeys(workload.Base) {
+			doc := workload.Base[filename]
+			kind := documentKind(doc)
+			if !kinds.allows(kind) {
+				continue
 			}
-		}
-
-		// Write services
-		if len(taskDefInfo.Manifests.Services) > 0 {
-			for i, svc := range taskDefInfo.Manifests.Services {
-				svcMap := serializeService(svc)
-				serviceFile := filepath.Join(basePath, "services", fmt.Sprintf("%s-service.yaml", svc.Name))
-				if data, err := yaml.Marshal(svcMap); err == nil {
-					if err := os.WriteFile(serviceFile, data, 0o644); err != nil {
-						log.Printf("Warning: Failed to write service %s: %v", serviceFile, err)
-					} else {
-						if i == 0 {
-							resourceList = append(resourceList, fmt.Sprintf("services/%s-service.yaml", svc.Name))
-						}
-					}
-				}
+			if kind == "ServiceAccount" {
+				// The IRSA role binding is applied by the irsa component
+				serviceAccount := withOwnMetadata(doc)
+				stripAnnotation(serviceAccount, irsaAnnotation)
+				doc = serviceAccount
 			}
-		}
 
-		// Write configmaps
-		if len(taskDefInfo.Manifests.ConfigMaps) > 0 {
-			for i, cm := range taskDefInfo.Manifests.ConfigMaps {
-				if cm == nil {
-					continue
-				}
-				cmMap := serializeConfigMap(cm)
-				configmapFile := filepath.Join(basePath, "configmaps", fmt.Sprintf("%s-configmap-%d.yaml", taskName, i))
-				if data, err := yaml.Marshal(cmMap); err == nil {
-					if err := os.WriteFile(configmapFile, data, 0o644); err != nil {
-						log.Printf("Warning: Failed to write configmap %s: %v", configmapFile, err)
-					} else {
-						resourceList = append(resourceList, fmt.Sprintf("configmaps/%s-configmap-%d.yaml", taskName, i))
-					}
-				}
+			dir := kustomizeResourceDirs[kind]
+			if dir == "" {
+				dir = "extras"
 			}
+			resource := dir + "/" + filename
+			if err := writeYAMLFile(filepath.Join(basePath, resource), doc); err != nil {
+				return fmt.Errorf("failed to write %s: %w", resource, err)
+			}
+			resourceList = append(resourceList, resource)
 		}
 
-		// Write secrets
-		if len(taskDefInfo.Manifests.Secrets) > 0 {
-			for i, secret := range taskDefInfo.Manifests.Secrets {
-				if secret == nil {
-					continue
-				}
-				secretMap := serializeSecret(secret)
-				secretFile := filepath.Join(basePath, "secrets", fmt.Sprintf("%s-secret-%d.yaml", taskName, i))
-				if data, err := yaml.Marshal(secretMap); err == nil {
-					if err := os.WriteFile(secretFile, data, 0o644); err != nil {
-						log.Printf("Warning: Failed to write secret %s: %v", secretFile, err)
-					} else {
-						resourceList = append(resourceList, fmt.Sprintf("secrets/%s-secret-%d.yaml", taskName, i))
-					}
-				}
-			}
+		// ECS task definition tags label every object of the service
+		if patch := tagLabelsPatch(workload); patch != nil {
+			patches = append(patches, patch)
 		}
 
-		// Write service accounts
-		if taskDefInfo.Manifests.ServiceAccount != nil {
-			saMap := serializeServiceAccount(taskDefInfo.Manifests.ServiceAccount)
-			serviceAccountFile := filepath.Join(basePath, "serviceaccounts", fmt.Sprintf("%s-serviceaccount.yaml", taskName))
-			if data, err := yaml.Marshal(saMap); err == nil {
-				if err := os.WriteFile(serviceAccountFile, data, 0o644); err != nil {
-					log.Printf("Warning: Failed to write serviceaccount %s: %v", serviceAccountFile, err)
+		// Emit user overrides as patches so they survive regeneration
+		for i, override := range workload.Info.Manifests.Overrides {
+			patchName := fmt.Sprintf("%s-override-%d.yaml", taskName, i)
+			patchFile := filepath.Join(basePath, "patches", patchName)
+			if data, err := yaml.Marshal(override.Patch); err == nil {
+				if err := os.WriteFile(patchFile, data, 0o644); err != nil {
+					log.Printf("Warning: Failed to write override patch %s: %v", patchFile, err)
 				} else {
-					resourceList = append(resourceList, fmt.Sprintf("serviceaccounts/%s-serviceaccount.yaml", taskName))
+					patches = append(patches, map[string]interface{}{
+						"target": map[string]interface{}{
+							"kind": override.Kind,
+							"name": override.Name,
+						},
+						"path": fmt.Sprintf("patches/%s", patchName),
+					})
 				}
 			}
 		}
@@ -191,6 +201,7 @@ func createBaseKustomization(basePath string, taskDefInfos []*TaskDefInfo) error
 			"name": "base",
 		},
 		Resources: resourceList,
+		Patches:   patches,
 		CommonLabels: map[string]string{
 			"managed-by": "ecs2k8s",
 		},
@@ -210,8 +221,46 @@ func createBaseKustomization(basePath string, taskDefInfos []*TaskDefInfo) error
 	return nil
 }
 
-// createOverlayKustomization creates overlay kustomization files for different environments
-func createOverlayKustomization(overlayPath, overlayName, namespace string, taskDefInfos []*TaskDefInfo) error {
+// tagLabelsPatch returns a base patch adding the task definition tags as
+// labels to the objects of a service, its equivalent of commonLabels. Unlike
+// commonLabels it leaves selectors alone, so retagging never changes them.
+func tagLabelsPatch(workload *Workload) map[string]interface{} {
+	labels := tagLabels(workload.Info.Tags)
+	if len(labels) == 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, doc := range workload.Docs {
+		manifest, _ := doc.(map[string]interface{})
+		metadata, _ := manifest["metadata"].(map[string]interface{})
+		if name, _ := metadata["name"].(string); name != "" {
+			names[regexp.QuoteMeta(name)] = true
+		}
+	}
+
+	// Kustomize ignores the kind and name of a patch with a target
+	patch, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": apiVersionCore,
+		"kind":       "not-important",
+		"metadata": map[string]interface{}{
+			"name":   "not-important",
+			"labels": labels,
+		},
+	})
+	if err != nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"target": map[string]interface{}{
+			"name": "^(" + strings.Join(sortedSet(names), "|") + ")$",
+		},
+		"patch": string(patch),
+	}
+}
+
+// createOverlayKustomization creates overlay kustomization files for different
+// environments, referencing the components in components
+func createOverlayKustomization(overlayPath, overlayName, namespace string, taskDefInfos []*TaskDefInfo, components []string) error {
 	// Create patches subdirectory
 	patchesDir := filepath.Join(overlayPath, "patches")
 	if err := os.MkdirAll(patchesDir, 0o755); err != nil {
@@ -221,8 +270,9 @@ func createOverlayKustomization(overlayPath, overlayName, namespace string, task
 	// Create namespace patch for each deployment
 	for _, taskDefInfo := range taskDefInfos {
 		taskName := taskDefInfo.Name
-		patchContent := fmt.Sprintf(`apiVersion: apps/v1
-kind: Deployment
+		apiVersion, kind := kustomizeWorkload(taskDefInfo.Manifests)
+		patchContent := fmt.Sprintf(`apiVersion: %s
+kind: %s
 metadata:
   name: %s
   namespace: %s
@@ -231,7 +281,7 @@ spec:
     metadata:
       labels:
         environment: %s
-`, taskName, namespace, overlayName)
+`, taskDefInfo.Manifests.APIVersions.resolve(kind, apiVersion), kind, taskName, namespace, overlayName)
 
 		patchFile := filepath.Join(patchesDir, fmt.Sprintf("%s-namespace-patch.yaml", taskName))
 		if err := os.WriteFile(patchFile, []byte(patchContent), 0o644); err != nil {
@@ -244,11 +294,8 @@ spec:
 	for _, taskDefInfo := range taskDefInfos {
 		taskName := taskDefInfo.Name
 		patches = append(patches, map[string]interface{}{
-			"target": map[string]interface{}{
-				"kind": "Deployment",
-				"name": taskName,
-			},
-			"path": fmt.Sprintf("patches/%s-namespace-patch.yaml", taskName),
+			"target": kustomizeWorkloadTarget(taskDefInfo),
+			"path":   fmt.Sprintf("patches/%s-namespace-patch.yaml", taskName),
 		})
 	}
 
@@ -258,9 +305,10 @@ spec:
 		Metadata: map[string]interface{}{
 			"name": overlayName,
 		},
-		Resources: []string{"../../base"},
-		Namespace: namespace,
-		Patches:   patches,
+		Resources:  []string{"../../base"},
+		Components: components,
+		Namespace:  namespace,
+		Patches:    patches,
 		CommonLabels: map[string]string{
 			"environment": overlayName,
 		},
@@ -289,10 +337,14 @@ func createRootKustomization(rootPath, clusterName string) error {
 			"name": clusterName,
 		},
 		CommonLabels: map[string]string{
-			"cluster":    clusterName,
+			"cluster":    safeLabelValue(clusterName),
 			"managed-by": "ecs2k8s",
 		},
 	}
+	// ECS cluster names may be longer than label values allow
+	if rootKustomize.CommonLabels["cluster"] != clusterName {
+		rootKustomize.CommonAnnotations = map[string]string{"cluster": clusterName}
+	}
 
 	kustomizeFile := filepath.Join(rootPath, "kustomization.yaml")
 	data, err := yaml.Marshal(rootKustomize)
@@ -308,39 +360,176 @@ func createRootKustomization(rootPath, clusterName string) error {
 	return nil
 }
 
-// generateBaseDeployment creates a base deployment manifest
-func generateBaseDeployment(taskName string, taskDefInfo *TaskDefInfo) map[string]interface{} {
-	deployment := map[string]interface{}{
-		"apiVersion": "apps/v1",
-		"kind":       "Deployment",
-		"metadata": map[string]interface{}{
-			"name": taskName,
-			"labels": map[string]string{
-				"app": taskName,
-			},
-		},
-		"spec": map[string]interface{}{
-			"replicas": 1,
-			"selector": map[string]interface{}{
-				"matchLabels": map[string]string{
-					"app": taskName,
+// createKustomizeComponents writes the irsa and monitoring Kustomize components
+// and returns the paths generated overlays reference. The irsa component is
+// only written when a ServiceAccount has an IAM role.
+func createKustomizeComponents(componentsPath string, taskDefInfos []*TaskDefInfo) ([]string, error) {
+	// Patches only match objects of the same apiVersion
+	apiVersions := conversionAPIVersions(taskDefInfos)
+
+	// irsa: binds ServiceAccounts to their ECS IAM roles
+	var irsaPatches []map[string]interface{}
+	irsaFiles := map[string]interface{}{}
+	for _, taskDefInfo := range taskDefInfos {
+		sa := taskDefInfo.Manifests.ServiceAccount
+		if sa == nil || sa.Annotations[irsaAnnotation] == "" {
+			continue
+		}
+		patchName := fmt.Sprintf("%s-irsa-patch.yaml", taskDefInfo.Name)
+		irsaFiles[patchName] = map[string]interface{}{
+			"apiVersion": apiVersionCore,
+			"kind":       "ServiceAccount",
+			"metadata": map[string]interface{}{
+				"name": sa.Name,
+				"annotations": map[string]string{
+					irsaAnnotation: sa.Annotations[irsaAnnotation],
 				},
 			},
-			"template": map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"labels": map[string]string{
-						"app": taskName,
+		}
+		irsaPatches = append(irsaPatches, map[string]interface{}{
+			"target": map[string]interface{}{"kind": "ServiceAccount", "name": sa.Name},
+			"path":   patchName,
+		})
+	}
+	var components []string
+	if len(irsaPatches) > 0 {
+		if err := writeKustomizeComponent(filepath.Join(componentsPath, "irsa"), irsaPatches, irsaFiles, apiVersions); err != nil {
+			return nil, err
+		}
+		components = append(components, overlayComponentPath("irsa"))
+	}
+
+	// monitoring: Prometheus scrape annotations on the first container port
+	var monitoringPatches []map[string]interface{}
+	monitoringFiles := map[string]interface{}{}
+	for _, taskDefInfo := range taskDefInfos {
+		podSpec := taskDefInfo.Manifests.Deployment
+		if podSpec == nil {
+			continue
+		}
+		port := int32(0)
+		if knative := taskDefInfo.Manifests.Knative; knative != nil {
+			// The Knative Service only keeps the port requests are routed to
+			port = knative.Port
+		} else {
+			for _, c := range podSpec.Containers {
+				if len(c.Ports) > 0 {
+					port = c.Ports[0].ContainerPort
+					break
+				}
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		patchName := fmt.Sprintf("%s-monitoring-patch.yaml", taskDefInfo.Name)
+		apiVersion, kind := kustomizeWorkload(taskDefInfo.Manifests)
+		monitoringFiles[patchName] = map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": taskDefInfo.Name},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]string{
+							"prometheus.io/scrape": "true",
+							"prometheus.io/port":   fmt.Sprintf("%d", port),
+						},
 					},
 				},
-				"spec": serializePodSpec(taskDefInfo.Manifests.Deployment),
 			},
-		},
+		}
+		monitoringPatches = append(monitoringPatches, map[string]interface{}{
+			"target": kustomizeWorkloadTarget(taskDefInfo),
+			"path":   patchName,
+		})
+	}
+	if err := writeKustomizeComponent(filepath.Join(componentsPath, "monitoring"), monitoringPatches, monitoringFiles, apiVersions); err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+// kustomizeWorkload returns the apiVersion and kind of the workload of a
+// service in the Kustomize base: a Knative Service, StatefulSet or Deployment
+func kustomizeWorkload(manifests K8sManifests) (string, string) {
+	if manifests.Knative != nil {
+		return apiVersionKnativeServing, "Service"
+	}
+	return apiVersionApps, workloadKind(manifests)
+}
+
+// kustomizeWorkloadTarget selects the workload of a service in a patch. The
+// group keeps a Knative Service apart from a core Service of the same name.
+func kustomizeWorkloadTarget(taskDefInfo *TaskDefInfo) map[string]interface{} {
+	apiVersion, kind := kustomizeWorkload(taskDefInfo.Manifests)
+	group, _, _ := strings.Cut(apiVersion, "/")
+	return map[string]interface{}{"group": group, "kind": kind, "name": taskDefInfo.Name}
+}
+
+// writeKustomizeComponent writes a Component kustomization with its patch files
+func writeKustomizeComponent(componentPath string, patches []map[string]interface{}, files map[string]interface{}, apiVersions apiVersionOverrides) error {
+	if err := os.MkdirAll(componentPath, 0o755); err != nil {
+		return fmt.Errorf("failed to create component directory %s: %w", componentPath, err)
 	}
 
-	return deployment
+	for name, doc := range files {
+		apiVersions.apply(doc)
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal component patch %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(componentPath, name), data, 0o644); err != nil {
+			return fmt.Errorf("failed to write component patch %s: %w", name, err)
+		}
+	}
+
+	component := KustomizeConfig{
+		APIVersion: "kustomize.config.k8s.io/v1alpha1",
+		Kind:       "Component",
+		Patches:    patches,
+	}
+	data, err := yaml.Marshal(component)
+	if err != nil {
+		return fmt.Errorf("failed to marshal component kustomization: %w", err)
+	}
+
+	kustomizeFile := filepath.Join(componentPath, "kustomization.yaml")
+	if err := os.WriteFile(kustomizeFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write component kustomization.yaml: %w", err)
+	}
+
+	log.Printf("Created component at: %s", kustomizeFile)
+	return nil
+}
+
+// overlayComponentPath returns the path of a component relative to an overlay
+func overlayComponentPath(name string) string {
+	return "../../components/" + name
+}
+
+// stripAnnotation removes an annotation from a serialized manifest without
+// modifying the source object's annotation map
+func stripAnnotation(doc map[string]interface{}, key string) {
+	metadata, ok := doc["metadata"].(map[string]interface{})
+	if !ok || metadata["annotations"] == nil {
+		return
+	}
+
+	remaining := mergeMetadataMap(metadata["annotations"], nil)
+	delete(remaining, key)
+	if len(remaining) == 0 {
+		delete(metadata, "annotations")
+		return
+	}
+	metadata["annotations"] = remaining
 }
 
 // CreateKustomizeChart is the main entry point for creating Kustomize structure
 func CreateKustomizeChart(clusterName string, taskDefInfos []*TaskDefInfo, outputDir string) error {
-	return createKustomizeStructure(clusterName, taskDefInfos, outputDir)
+	model, err := newConversionModel(clusterName, taskDefInfos)
+	if err != nil {
+		return err
+	}
+	return createKustomizeStructure(model, outputDir)
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestCreateKustomizeComponents tests that the monitoring patches target the
+// workload kind of each service and that overlays only reference irsa when
+// it has patches
+func TestCreateKustomizeComponents(t *testing.T) {
+	build := func(family string) *TaskDefInfo {
+		info, err := buildTaskDefInfo(appTaskDef(family), family)
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+		}
+		return info
+	}
+	web, db, fn := build("web"), build("db"), build("fn")
+	db.Manifests.StatefulSet = &StatefulSetSettings{}
+	fn.Manifests.Knative = &KnativeSettings{Container: "app", Port: 8080}
+
+	dir := t.TempDir()
+	components, err := createKustomizeComponents(dir, []*TaskDefInfo{web, db, fn})
+	if err != nil {
+		t.Fatalf("createKustomizeComponents() error = %v", err)
+	}
+	if want := []string{"../../components/irsa"}; !reflect.DeepEqual(components, want) {
+		t.Errorf("components = %v, want %v", components, want)
+	}
+
+	var monitoring KustomizeConfig
+	readYAMLFile(t, filepath.Join(dir, "monitoring", "kustomization.yaml"), &monitoring)
+	targets := map[string]map[string]interface{}{}
+	for _, patch := range monitoring.Patches {
+		target := patch["target"].(map[string]interface{})
+		targets[target["name"].(string)] = target
+	}
+	wantTargets := map[string]map[string]interface{}{
+		"web": {"group": "apps", "kind": "Deployment", "name": "web"},
+		"db":  {"group": "apps", "kind": "StatefulSet", "name": "db"},
+		"fn":  {"group": "serving.knative.dev", "kind": "Service", "name": "fn"},
+	}
+	if !reflect.DeepEqual(targets, wantTargets) {
+		t.Errorf("monitoring targets = %v, want %v", targets, wantTargets)
+	}
+
+	var patch struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Spec       struct {
+			Template struct {
+				Metadata struct {
+					Annotations map[string]string `yaml:"annotations"`
+				} `yaml:"metadata"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	}
+	readYAMLFile(t, filepath.Join(dir, "monitoring", "fn-monitoring-patch.yaml"), &patch)
+	if patch.APIVersion != apiVersionKnativeServing || patch.Kind != "Service" || patch.Spec.Template.Metadata.Annotations["prometheus.io/port"] != "8080" {
+		t.Errorf("Knative monitoring patch = %+v", patch)
+	}
+
+	// Without IAM roles there is nothing for irsa to patch
+	taskDef := appTaskDef("plain")
+	taskDef.TaskRoleArn = nil
+	plain, err := buildTaskDefInfo(taskDef, "plain")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	dir = t.TempDir()
+	components, err = createKustomizeComponents(dir, []*TaskDefInfo{plain})
+	if err != nil {
+		t.Fatalf("createKustomizeComponents() error = %v", err)
+	}
+	if len(components) != 0 {
+		t.Errorf("components = %v, want none without IAM roles", components)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "irsa")); !os.IsNotExist(err) {
+		t.Errorf("irsa component written without patches: %v", err)
+	}
+}
+
+// readYAMLFile decodes a YAML file written by a test
+func readYAMLFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to parse %s: %v", path, err)
+	}
+}
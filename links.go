@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerLink is a legacy bridge-mode link of a container definition,
+// "name" or "name:alias"
+type containerLink struct {
+	name  string
+	alias string
+}
+
+// parseContainerLinks returns the links of a container definition
+func parseContainerLinks(def types.ContainerDefinition) []containerLink {
+	var links []containerLink
+	for _, link := range def.Links {
+		name, alias, found := strings.Cut(strings.TrimSpace(link), ":")
+		if name == "" {
+			continue
+		}
+		if !found || alias == "" {
+			alias = name
+		}
+		links = append(links, containerLink{name: name, alias: alias})
+	}
+	return links
+}
+
+// applyLinks converts the legacy links between the containers of a task
+// definition. The containers of a pod share its network namespace, so a
+// linked container is reached on localhost: the link names and aliases
+// resolve to 127.0.0.1 through hostAliases, and the linking container gets
+// the <ALIAS>_PORT_* variables Docker sets for a link. Links to containers
+// outside the task definition are reported and stay unmapped.
+func applyLinks(taskDefInfo *TaskDefInfo) {
+	taskDef := taskDefInfo.Source
+	podSpec := taskDefInfo.Manifests.Deployment
+	if taskDef == nil || podSpec == nil {
+		return
+	}
+
+	defs := map[string]types.ContainerDefinition{}
+	for _, def := range taskDef.ContainerDefinitions {
+		defs[aws.ToString(def.Name)] = def
+	}
+
+	hostnames := map[string]bool{}
+	for _, def := range taskDef.ContainerDefinitions {
+		links := parseContainerLinks(def)
+		if len(links) == 0 {
+			continue
+		}
+		containerName := aws.ToString(def.Name)
+
+		missing := 0
+		linkEnv := map[string]string{}
+		for _, link := range links {
+			linked, ok := defs[link.name]
+			if !ok {
+				log.Printf("Warning: Container %s of %s links to %s, which is not in the task definition", containerName, taskDefInfo.Name, link.name)
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Link %s of container %s is not converted: no container %s in the task definition", link.name, containerName, link.name))
+				missing++
+				continue
+			}
+			hostnames[link.name] = true
+			hostnames[link.alias] = true
+			for name, value := range dockerLinkEnv(link.alias, linked.PortMappings) {
+				linkEnv[name] = value
+			}
+		}
+
+		if refs := linkReferences(def, links); len(refs) > 0 {
+			log.Printf("Warning: Environment of container %s of %s references linked container(s) by name: %s", containerName, taskDefInfo.Name, strings.Join(refs, ", "))
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Environment variable(s) of container %s reference links by name (%s); the names resolve to 127.0.0.1 through hostAliases, so the linked containers must listen on distinct ports", containerName, strings.Join(refs, ", ")))
+		}
+
+		if added := setLinkEnv(taskDefInfo, sanitizeName(containerName), linkEnv); len(added) > 0 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Link variables set in container %s: %s", containerName, strings.Join(added, ", ")))
+		}
+		if missing == 0 {
+			taskDefInfo.Unmapped = removeField(taskDefInfo.Unmapped, fmt.Sprintf("containerDefinitions[%s].links", containerName))
+		}
+	}
+	if len(hostnames) == 0 {
+		return
+	}
+
+	aliases := sortedSet(hostnames)
+	podSpec.HostAliases = append(podSpec.HostAliases, corev1.HostAlias{IP: "127.0.0.1", Hostnames: aliases})
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Linked containers run in the same pod and reach each other on localhost; %s resolve to 127.0.0.1 through hostAliases. The containers share the port space of the pod, unlike in bridge mode.", strings.Join(aliases, ", ")))
+}
+
+// dockerLinkEnv returns the variables Docker sets in a linking container for
+// the ports of a linked container, pointing at localhost
+func dockerLinkEnv(alias string, portMappings []types.PortMapping) map[string]string {
+	prefix := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(alias))
+	env := map[string]string{}
+	for _, pm := range portMappings {
+		if pm.ContainerPort == nil {
+			continue
+		}
+		port := aws.ToInt32(pm.ContainerPort)
+		proto := strings.ToLower(string(pm.Protocol))
+		if proto == "" {
+			proto = "tcp"
+		}
+		url := fmt.Sprintf("%s://127.0.0.1:%d", proto, port)
+		portPrefix := fmt.Sprintf("%s_PORT_%d_%s", prefix, port, strings.ToUpper(proto))
+		env[portPrefix] = url
+		env[portPrefix+"_ADDR"] = "127.0.0.1"
+		env[portPrefix+"_PORT"] = fmt.Sprint(port)
+		env[portPrefix+"_PROTO"] = proto
+		// <ALIAS>_PORT is the first port of the linked container
+		if _, ok := env[prefix+"_PORT"]; !ok {
+			env[prefix+"_PORT"] = url
+		}
+	}
+	return env
+}
+
+// linkReferences returns the link names and aliases the environment values
+// of a container definition refer to as hostnames
+func linkReferences(def types.ContainerDefinition, links []containerLink) []string {
+	names := map[string]bool{}
+	for _, link := range links {
+		names[link.name] = true
+		names[link.alias] = true
+	}
+
+	referenced := map[string]bool{}
+	for _, env := range def.Environment {
+		tokens := strings.FieldsFunc(aws.ToString(env.Value), func(r rune) bool {
+			return !(r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		})
+		for _, token := range tokens {
+			if names[token] {
+				referenced[aws.ToString(env.Name)+"="+token] = true
+			}
+		}
+	}
+	return sortedSet(referenced)
+}
+
+// setLinkEnv sets the link variables a container does not define yet, in the
+// pod spec and the container config, and returns the names set
+func setLinkEnv(taskDefInfo *TaskDefInfo, containerName string, linkEnv map[string]string) []string {
+	podSpec := taskDefInfo.Manifests.Deployment
+	names := make([]string, 0, len(linkEnv))
+	for name := range linkEnv {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var added []string
+	set := func(containers []corev1.Container) {
+		for i := range containers {
+			c := &containers[i]
+			if c.Name != containerName {
+				continue
+			}
+			defined := map[string]bool{}
+			for _, env := range c.Env {
+				defined[env.Name] = true
+			}
+			for _, name := range names {
+				if defined[name] {
+					continue
+				}
+				c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: linkEnv[name]})
+				added = append(added, name)
+			}
+		}
+	}
+	set(podSpec.InitContainers)
+	set(podSpec.Containers)
+
+	for i := range taskDefInfo.Containers {
+		containerConfig := &taskDefInfo.Containers[i]
+		if containerConfig.Name != containerName {
+			continue
+		}
+		if containerConfig.EnvVars == nil {
+			containerConfig.EnvVars = map[string]string{}
+		}
+		for _, name := range added {
+			containerConfig.EnvVars[name] = linkEnv[name]
+		}
+	}
+	return added
+}
+
+// linkGroups groups container definitions connected by links, directly or
+// through other containers, in the order of their first container
+func linkGroups(defs []types.ContainerDefinition) [][]types.ContainerDefinition {
+	group := make([]int, len(defs))
+	index := map[string]int{}
+	for i, def := range defs {
+		group[i] = i
+		index[aws.ToString(def.Name)] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if group[i] != i {
+			group[i] = find(group[i])
+		}
+		return group[i]
+	}
+	for i, def := range defs {
+		for _, link := range parseContainerLinks(def) {
+			if j, ok := index[link.name]; ok {
+				a, b := find(i), find(j)
+				if a > b {
+					a, b = b, a
+				}
+				group[b] = a
+			}
+		}
+	}
+
+	var groups [][]types.ContainerDefinition
+	position := map[int]int{}
+	for i, def := range defs {
+		root := find(i)
+		p, ok := position[root]
+		if !ok {
+			p = len(groups)
+			position[root] = p
+			groups = append(groups, nil)
+		}
+		groups[p] = append(groups[p], def)
+	}
+	return groups
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// linkedTaskDef returns a bridge-mode task definition whose app container
+// links to its db container as database
+func linkedTaskDef() *types.TaskDefinition {
+	return &types.TaskDefinition{
+		Family:      aws.String("shop"),
+		NetworkMode: types.NetworkModeBridge,
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:         aws.String("app"),
+				Image:        aws.String("shop:1"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8080)}},
+				Links:        []string{"db:database"},
+				Environment: []types.KeyValuePair{
+					{Name: aws.String("DATABASE_URL"), Value: aws.String("postgres://database:5432/shop")},
+					{Name: aws.String("DATABASE_PORT"), Value: aws.String("5432")},
+				},
+			},
+			{
+				Name:         aws.String("db"),
+				Image:        aws.String("postgres:16"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(5432), Protocol: types.TransportProtocolTcp}},
+			},
+			{
+				Name:         aws.String("cron"),
+				Image:        aws.String("cron:1"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(9000)}},
+			},
+		},
+	}
+}
+
+// TestApplyLinks tests converting links into localhost host aliases and the
+// Docker link variables
+func TestApplyLinks(t *testing.T) {
+	info, err := buildTaskDefInfo(linkedTaskDef(), "shop")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	podSpec := info.Manifests.Deployment
+	if len(podSpec.HostAliases) != 1 || podSpec.HostAliases[0].IP != "127.0.0.1" || !reflect.DeepEqual(podSpec.HostAliases[0].Hostnames, []string{"database", "db"}) {
+		t.Errorf("HostAliases = %+v, want database and db on 127.0.0.1", podSpec.HostAliases)
+	}
+
+	env := map[string]string{}
+	for _, e := range podSpec.Containers[0].Env {
+		if e.Value != "" {
+			env[e.Name] = e.Value
+		}
+	}
+	if env["DATABASE_PORT_5432_TCP"] != "tcp://127.0.0.1:5432" || env["DATABASE_PORT_5432_TCP_ADDR"] != "127.0.0.1" {
+		t.Errorf("app env = %v, want the database link variables", env)
+	}
+	if info.Containers[0].EnvVars["DATABASE_PORT_5432_TCP_PROTO"] != "tcp" {
+		t.Errorf("app EnvVars = %v, want the link variables", info.Containers[0].EnvVars)
+	}
+	if info.Containers[0].EnvVars["DATABASE_PORT"] != "5432" {
+		t.Errorf("DATABASE_PORT = %q, want the value of the task definition", info.Containers[0].EnvVars["DATABASE_PORT"])
+	}
+
+	var referenced bool
+	for _, note := range info.Notes {
+		if strings.Contains(note, "DATABASE_URL=database") {
+			referenced = true
+		}
+	}
+	if !referenced {
+		t.Errorf("Notes = %v, want a warning about DATABASE_URL", info.Notes)
+	}
+	for _, field := range info.Unmapped {
+		if strings.HasSuffix(field, ".links") {
+			t.Errorf("Unmapped = %v, want the links mapped", info.Unmapped)
+		}
+	}
+
+	// A link to a container outside the task definition stays unmapped
+	taskDef := linkedTaskDef()
+	taskDef.ContainerDefinitions[0].Links = []string{"cache"}
+	info, err = buildTaskDefInfo(taskDef, "shop")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	if len(info.Manifests.Deployment.HostAliases) != 0 {
+		t.Errorf("HostAliases = %+v, want none", info.Manifests.Deployment.HostAliases)
+	}
+	if !reflect.DeepEqual(info.Unmapped, []string{"networkMode", "containerDefinitions[app].links"}) {
+		t.Errorf("Unmapped = %v, want the unresolved links", info.Unmapped)
+	}
+}
+
+// TestSplitContainersKeepsLinks tests that linked containers stay in one pod
+func TestSplitContainersKeepsLinks(t *testing.T) {
+	info, err := buildTaskDefInfo(linkedTaskDef(), "shop")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	splits, err := splitContainers(info, nil)
+	if err != nil {
+		t.Fatalf("splitContainers() error = %v", err)
+	}
+	if len(splits) != 2 || splits[0].Name != "shop-app" || splits[1].Name != "shop-cron" {
+		t.Fatalf("splits = %d, want shop-app and shop-cron", len(splits))
+	}
+	containers := splits[0].Manifests.Deployment.Containers
+	if len(containers) != 2 || containers[0].Name != "app" || containers[1].Name != "db" {
+		t.Errorf("shop-app containers = %+v, want app and db", containers)
+	}
+	if len(splits[0].Manifests.Deployment.HostAliases) != 1 {
+		t.Errorf("shop-app HostAliases = %+v, want the link aliases", splits[0].Manifests.Deployment.HostAliases)
+	}
+}
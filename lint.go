@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of lint findings, from most to least severe. Off disables a check.
+const (
+	lintError   = "error"
+	lintWarning = "warning"
+	lintInfo    = "info"
+	lintOff     = "off"
+)
+
+// lintSeverityRank orders the severities for the report
+var lintSeverityRank = map[string]int{lintError: 0, lintWarning: 1, lintInfo: 2}
+
+// Best-practice checks run over the generated objects (--lint)
+const (
+	lintMissingProbes    = "missing-probes"
+	lintMissingLimits    = "missing-limits"
+	lintDefaultNamespace = "default-namespace"
+	lintLatestTag        = "latest-tag"
+	lintManifestSize     = "manifest-size"
+)
+
+// defaultLintSeverities are the severities of the checks unless overridden with --lint-severity
+var defaultLintSeverities = map[string]string{
+	lintMissingProbes:    lintWarning,
+	lintMissingLimits:    lintWarning,
+	lintDefaultNamespace: lintInfo,
+	lintLatestTag:        lintWarning,
+	lintManifestSize:     lintError,
+}
+
+// Object sizes the manifest-size check reports. kubectl apply stores the
+// object in an annotation limited to 256KiB; etcd rejects objects over ~1.5MiB
+// and ConfigMaps and Secrets over 1MiB.
+const (
+	lintLastAppliedLimit = 256 << 10
+	lintObjectLimit      = 1 << 20
+)
+
+// lintClusterScopedKinds are generated kinds without a namespace
+var lintClusterScopedKinds = map[string]bool{
+	"ClusterRole":        true,
+	"ClusterRoleBinding": true,
+	"NodePool":           true,
+	"EC2NodeClass":       true,
+	"ClusterPolicy":      true,
+}
+
+// LintFinding is a best-practice problem of a generated object
+type LintFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	// File is the manifest of the object, relative to the output directory
+	File    string `json:"file"`
+	Message string `json:"message"`
+}
+
+// linter checks the generated objects against Kubernetes best practices,
+// similar to kube-score and Polaris
+type linter struct {
+	// severities are the severities of the checks; off checks are skipped
+	severities map[string]string
+}
+
+// newLinter parses repeatable --lint-severity values of the form check=severity.
+// It returns nil unless --lint is set.
+func newLinter(enabled bool, severityFlags []string) (*linter, error) {
+	if !enabled {
+		if len(severityFlags) > 0 {
+			return nil, fmt.Errorf("--lint-severity requires --lint")
+		}
+		return nil, nil
+	}
+
+	l := &linter{severities: map[string]string{}}
+	for check, severity := range defaultLintSeverities {
+		l.severities[check] = severity
+	}
+	for _, flag := range severityFlags {
+		check, severity, ok := strings.Cut(flag, "=")
+		if _, known := defaultLintSeverities[check]; !ok || !known {
+			return nil, fmt.Errorf("invalid --lint-severity %q (must be check=severity with a check of: %s)", flag, strings.Join(lintChecks(), ", "))
+		}
+		if _, valid := lintSeverityRank[severity]; !valid && severity != lintOff {
+			return nil, fmt.Errorf("invalid --lint-severity %q (severity must be error, warning, info or off)", flag)
+		}
+		l.severities[check] = severity
+	}
+	return l, nil
+}
+
+// lintChecks returns the names of the checks, sorted
+func lintChecks() []string {
+	var checks []string
+	for check := range defaultLintSeverities {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+// lint records the findings of every object generated for a service in
+// taskDefInfo.Lint and returns the number of errors
+func (l *linter) lint(taskDefInfo *TaskDefInfo) (int, error) {
+	files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		return 0, err
+	}
+
+	taskDefInfo.Lint = nil
+	var defaultNamespace []string
+	for _, filename := range sortedDocKeys(files) {
+		data, err := yaml.Marshal(files[filename])
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
+		}
+		var object map[string]interface{}
+		if err := yaml.Unmarshal(data, &object); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		kind, _ := object["kind"].(string)
+
+		switch {
+		case len(data) > lintObjectLimit:
+			l.add(taskDefInfo, lintManifestSize, filename, fmt.Sprintf("%s is %dKiB, over the 1MiB Kubernetes accepts for ConfigMaps and Secrets and close to the etcd object limit", kind, len(data)>>10))
+		case len(data) > lintLastAppliedLimit:
+			l.add(taskDefInfo, lintManifestSize, filename, fmt.Sprintf("%s is %dKiB, too large for kubectl apply's last-applied annotation; use kubectl apply --server-side", kind, len(data)>>10))
+		}
+
+		metadata, _ := object["metadata"].(map[string]interface{})
+		if ns, _ := metadata["namespace"].(string); (ns == "" || ns == "default") && !lintClusterScopedKinds[kind] {
+			defaultNamespace = append(defaultNamespace, filename)
+		}
+
+		for _, container := range lintContainers(object) {
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			if _, tag, digest := splitImageReference(image); digest == "" && (tag == "" || tag == "latest") {
+				l.add(taskDefInfo, lintLatestTag, filename, fmt.Sprintf("Container %s runs %s, which is not pinned to a tag or digest", name, image))
+			}
+			var missing []string
+			for _, probe := range []string{"readinessProbe", "livenessProbe"} {
+				if container[probe] == nil {
+					missing = append(missing, probe)
+				}
+			}
+			// Jobs run to completion and are not probed
+			if len(missing) > 0 && kind != "Job" {
+				l.add(taskDefInfo, lintMissingProbes, filename, fmt.Sprintf("Container %s has no %s", name, strings.Join(missing, " or ")))
+			}
+			resources, _ := container["resources"].(map[string]interface{})
+			limits, _ := resources["limits"].(map[string]interface{})
+			missing = nil
+			for _, resource := range []string{"cpu", "memory"} {
+				if limits[resource] == nil {
+					missing = append(missing, resource)
+				}
+			}
+			if len(missing) > 0 {
+				l.add(taskDefInfo, lintMissingLimits, filename, fmt.Sprintf("Container %s has no %s limit", name, strings.Join(missing, " or ")))
+			}
+		}
+	}
+
+	if len(defaultNamespace) > 0 {
+		l.add(taskDefInfo, lintDefaultNamespace, strings.Join(defaultNamespace, ", "), fmt.Sprintf("%d object(s) are deployed to the default namespace", len(defaultNamespace)))
+	}
+
+	sort.SliceStable(taskDefInfo.Lint, func(i, j int) bool {
+		return lintSeverityRank[taskDefInfo.Lint[i].Severity] < lintSeverityRank[taskDefInfo.Lint[j].Severity]
+	})
+	failures := 0
+	for _, finding := range taskDefInfo.Lint {
+		if finding.Severity == lintError {
+			failures++
+		}
+	}
+	return failures, nil
+}
+
+// add records a finding unless its check is off
+func (l *linter) add(taskDefInfo *TaskDefInfo, check, file, message string) {
+	severity := l.severities[check]
+	if severity == lintOff {
+		return
+	}
+	taskDefInfo.Lint = append(taskDefInfo.Lint, LintFinding{Check: check, Severity: severity, File: file, Message: message})
+}
+
+// lintContainers returns the containers of the pod template of a workload
+// (Deployment, Rollout, Knative Service, Job), excluding init containers
+func lintContainers(object map[string]interface{}) []map[string]interface{} {
+	spec, _ := object["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	podSpec, _ := template["spec"].(map[string]interface{})
+	items, _ := podSpec["containers"].([]interface{})
+
+	var containers []map[string]interface{}
+	for _, item := range items {
+		if container, ok := item.(map[string]interface{}); ok {
+			containers = append(containers, container)
+		}
+	}
+	return containers
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestLint tests the best-practice checks and their configurable severities
+func TestLint(t *testing.T) {
+	for _, flags := range [][]string{{"latest-tag"}, {"unknown=error"}, {"latest-tag=fatal"}} {
+		if _, err := newLinter(true, flags); err == nil {
+			t.Errorf("newLinter(%v) succeeded", flags)
+		}
+	}
+	if _, err := newLinter(false, []string{"latest-tag=error"}); err == nil {
+		t.Error("--lint-severity without --lint succeeded")
+	}
+	if l, err := newLinter(false, nil); l != nil || err != nil {
+		t.Errorf("newLinter(false) = %v, %v; want nil", l, err)
+	}
+
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "app", Image: "nginx"},
+					{
+						Name:  "pinned",
+						Image: "registry.example.com:5000/api:1.2",
+						Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("250m"),
+							corev1.ResourceMemory: resource.MustParse("512Mi"),
+						}},
+						ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{}}},
+						LivenessProbe:  &corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{}}},
+					},
+				}},
+			},
+		}
+	}
+
+	l, err := newLinter(true, nil)
+	if err != nil {
+		t.Fatalf("newLinter() error = %v", err)
+	}
+	info := newInfo()
+	failures, err := l.lint(info)
+	if err != nil {
+		t.Fatalf("lint() error = %v", err)
+	}
+	if failures != 0 {
+		t.Errorf("lint() = %d errors, want 0", failures)
+	}
+	checks := map[string]string{}
+	for _, finding := range info.Lint {
+		if strings.Contains(finding.Message, "Container pinned ") {
+			t.Errorf("unexpected finding for the pinned container: %+v", finding)
+		}
+		checks[finding.Check] = finding.Severity
+	}
+	want := map[string]string{
+		lintLatestTag:        lintWarning,
+		lintMissingProbes:    lintWarning,
+		lintMissingLimits:    lintWarning,
+		lintDefaultNamespace: lintInfo,
+	}
+	for check, severity := range want {
+		if checks[check] != severity {
+			t.Errorf("finding %s has severity %q, want %q", check, checks[check], severity)
+		}
+	}
+
+	l, err = newLinter(true, []string{"latest-tag=error", "default-namespace=off"})
+	if err != nil {
+		t.Fatalf("newLinter() error = %v", err)
+	}
+	info = newInfo()
+	info.Manifests.ConfigMaps = []*corev1.ConfigMap{{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default"},
+		Data:       map[string]string{"blob": strings.Repeat("x", 300<<10)},
+	}}
+	if failures, err = l.lint(info); err != nil {
+		t.Fatalf("lint() error = %v", err)
+	}
+	if failures != 2 {
+		t.Errorf("lint() = %d errors, want 2 (latest-tag, manifest-size)", failures)
+	}
+	if info.Lint[0].Severity != lintError || info.Lint[1].Severity != lintError {
+		t.Errorf("findings are not ordered by severity: %+v", info.Lint)
+	}
+	var size *LintFinding
+	for i, finding := range info.Lint {
+		if finding.Check == lintDefaultNamespace {
+			t.Errorf("disabled check reported: %+v", finding)
+		}
+		if finding.Check == lintManifestSize {
+			size = &info.Lint[i]
+		}
+	}
+	if size == nil || size.File != "web-configmap.yaml" || !strings.Contains(size.Message, "server-side") {
+		t.Errorf("manifest-size finding = %+v", size)
+	}
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// targetGroupInfo is the load balancer configuration of an ECS target group
+type targetGroupInfo struct {
+	Arn      string
+	Name     string
+	Protocol string
+	// Routes are the listener rule conditions forwarding to this target group
+	Routes []ingressRoute
+	// Rules are the listener rules forwarding to this target group
+	Rules []listenerRule
+	// Source is the described target group, including its health check
+	Source *elbv2types.TargetGroup
+}
+
+// ingressRoute is a host/path condition of an ALB listener rule
+type ingressRoute struct {
+	Host string
+	Path string
+}
+
+// listenerRule is an ALB listener rule forwarding to a target group
+type listenerRule struct {
+	Arn         string
+	ListenerArn string
+	IsDefault   bool
+	// TargetGroupArns are all target groups the rule forwards to
+	TargetGroupArns []string
+}
+
+// loadBalancerRules are the listener routes and rules of a load balancer per target group
+type loadBalancerRules struct {
+	routes map[string][]ingressRoute
+	rules  map[string][]listenerRule
+}
+
+// targetGroupAttachment links a target group to a container port of a service
+type targetGroupAttachment struct {
+	TargetGroupArn string
+	ContainerName  string
+	ContainerPort  int32
+}
+
+// loadBalancerResolver describes target groups and their listener rules
+type loadBalancerResolver struct {
+	client *elbv2.Client
+	// rulesByLoadBalancer caches listener routes and rules per load balancer
+	rulesByLoadBalancer map[string]*loadBalancerRules
+	// cutoverWeight is the percentage of traffic shifted to Kubernetes by the
+	// generated cutover scripts, or cutoverDisabled
+	cutoverWeight int
+	// targetGroupBindings binds the Services to the existing ECS target groups
+	targetGroupBindings bool
+}
+
+// newLoadBalancerResolver creates a resolver backed by an ELBv2 client
+func newLoadBalancerResolver(client *elbv2.Client) *loadBalancerResolver {
+	return &loadBalancerResolver{
+		client:              client,
+		rulesByLoadBalancer: map[string]*loadBalancerRules{},
+		cutoverWeight:       cutoverDisabled,
+	}
+}
+
+// apply maps the target groups attached to a task definition's services onto
+// its Kubernetes Services, readiness probes and, for several target groups, an
+// Ingress
+func (r *loadBalancerResolver) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	attachments := containerTargetGroups(serviceTargetGroups(taskDefInfo.Services), taskDefInfo.Containers)
+	if len(attachments) == 0 {
+		return
+	}
+
+	targetGroups := map[string]*targetGroupInfo{}
+	if r != nil {
+		var arns []string
+		for _, a := range attachments {
+			arns = append(arns, a.TargetGroupArn)
+		}
+		described, err := r.describeTargetGroups(ctx, arns)
+		if err != nil {
+			log.Printf("Warning: Failed to describe target groups of %s: %v", taskDefInfo.Name, err)
+		}
+		targetGroups = described
+	}
+
+	applyTargetGroups(taskDefInfo, attachments, targetGroups)
+	applyHealthCheckProbes(taskDefInfo, attachments, targetGroups)
+	if r != nil && r.targetGroupBindings {
+		applyTargetGroupBindings(taskDefInfo, attachments, targetGroups)
+	}
+	if r != nil && r.cutoverWeight != cutoverDisabled {
+		applyCutover(taskDefInfo, attachments, targetGroups, r.cutoverWeight)
+	}
+}
+
+// serviceTargetGroups lists the distinct target group attachments of ECS services
+func serviceTargetGroups(services []types.Service) []targetGroupAttachment {
+	seen := map[string]bool{}
+	var attachments []targetGroupAttachment
+
+	for _, svc := range services {
+		for _, lb := range svc.LoadBalancers {
+			arn := aws.ToString(lb.TargetGroupArn)
+			if arn == "" || lb.ContainerName == nil || lb.ContainerPort == nil {
+				// Classic load balancers have no target group
+				continue
+			}
+			key := fmt.Sprintf("%s|%s|%d", arn, *lb.ContainerName, *lb.ContainerPort)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			attachments = append(attachments, targetGroupAttachment{
+				TargetGroupArn: arn,
+				ContainerName:  sanitizeName(*lb.ContainerName),
+				ContainerPort:  *lb.ContainerPort,
+			})
+		}
+	}
+
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].TargetGroupArn < attachments[j].TargetGroupArn
+	})
+	return attachments
+}
+
+// applyTargetGroups makes sure every attached container port is exposed by the
+// container's Service and generates Ingress rules when several target groups
+// front the same task definition
+func applyTargetGroups(taskDefInfo *TaskDefInfo, attachments []targetGroupAttachment, targetGroups map[string]*targetGroupInfo) {
+	manifests := &taskDefInfo.Manifests
+
+	for _, a := range attachments {
+		svc := findService(manifests.Services, a.ContainerName)
+		if svc == nil {
+			svc = createService(a.ContainerName, taskDefInfo.Name, []types.PortMapping{{ContainerPort: aws.Int32(a.ContainerPort)}})
+			manifests.Services = append(manifests.Services, svc)
+		}
+		if !hasServicePort(svc, a.ContainerPort) {
+			svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+				Port:       a.ContainerPort,
+				TargetPort: intstr.FromInt32(a.ContainerPort),
+				Protocol:   corev1.ProtocolTCP,
+			})
+			log.Printf("Info: Added port %d to Service %s for target group %s", a.ContainerPort, svc.Name, targetGroupName(a.TargetGroupArn))
+		}
+		nameServicePorts(svc)
+	}
+
+	distinct := map[string]bool{}
+	for _, a := range attachments {
+		distinct[a.TargetGroupArn] = true
+	}
+	if len(distinct) < 2 {
+		return
+	}
+
+	ingress := buildTargetGroupIngress(taskDefInfo.Name, attachments, targetGroups)
+	if ingress == nil {
+		return
+	}
+	manifests.Extras = append(manifests.Extras, ExtraObject{Suffix: "ingress", Object: ingress})
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Attached to %d target groups; generated an Ingress with one rule per target group", len(distinct)))
+	log.Printf("✓ Created Ingress for %s covering %d target groups", taskDefInfo.Name, len(distinct))
+}
+
+// applyTargetGroupBindings registers the pods in the target groups of the ECS
+// service, so the existing ALB sends traffic to them alongside the ECS tasks
+func applyTargetGroupBindings(taskDefInfo *TaskDefInfo, attachments []targetGroupAttachment, targetGroups map[string]*targetGroupInfo) {
+	for _, a := range attachments {
+		name := sanitizeName(targetGroupName(a.TargetGroupArn))
+		target := map[string]string{"targetGroupARN": a.TargetGroupArn}
+		if tg, ok := targetGroups[a.TargetGroupArn]; ok && tg.Source != nil && tg.Source.TargetType == elbv2types.TargetTypeEnumInstance {
+			// Instance target groups register nodes and need a NodePort Service
+			target["targetType"] = "instance"
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Target group %s has target type instance: change Service %s to type NodePort for its TargetGroupBinding", name, a.ContainerName))
+		}
+
+		binding := buildTargetGroupBinding(name, taskDefInfo.Name, a.ContainerName, a.ContainerPort, target)
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: name + "-targetgroupbinding", Object: binding})
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("TargetGroupBinding %s registers the pods in the existing target group; the ALB splits traffic between ECS tasks and pods until the ECS service is scaled down", name))
+		log.Printf("✓ Created TargetGroupBinding %s for %s", name, taskDefInfo.Name)
+	}
+}
+
+// buildTargetGroupIngress creates an ALB Ingress routing each target group's
+// listener conditions to the matching Service port
+func buildTargetGroupIngress(taskDefName string, attachments []targetGroupAttachment, targetGroups map[string]*targetGroupInfo) map[string]interface{} {
+	// Rules are grouped by host; an empty host matches all hosts
+	pathsByHost := map[string][]map[string]interface{}{}
+	seenPaths := map[string]bool{}
+
+	for _, a := range attachments {
+		routes := []ingressRoute{{Path: "/"}}
+		if tg, ok := targetGroups[a.TargetGroupArn]; ok && len(tg.Routes) > 0 {
+			routes = tg.Routes
+		} else {
+			log.Printf("Warning: No listener rules known for target group %s, routing / to it", targetGroupName(a.TargetGroupArn))
+		}
+
+		for _, route := range routes {
+			key := route.Host + "|" + route.Path
+			if seenPaths[key] {
+				log.Printf("Warning: Route %s%s is used by several target groups, keeping the first", route.Host, route.Path)
+				continue
+			}
+			seenPaths[key] = true
+
+			path, pathType := ingressPath(route.Path)
+			pathsByHost[route.Host] = append(pathsByHost[route.Host], map[string]interface{}{
+				"path":     path,
+				"pathType": pathType,
+				"backend": map[string]interface{}{
+					"service": map[string]interface{}{
+						"name": a.ContainerName,
+						"port": map[string]interface{}{"number": a.ContainerPort},
+					},
+				},
+			})
+		}
+	}
+
+	hosts := make([]string, 0, len(pathsByHost))
+	for host := range pathsByHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var rules []map[string]interface{}
+	for _, host := range hosts {
+		rule := map[string]interface{}{
+			"http": map[string]interface{}{"paths": pathsByHost[host]},
+		}
+		if host != "" {
+			rule["host"] = host
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"apiVersion": apiVersionNetworking,
+		"kind":       "Ingress",
+		"metadata": map[string]interface{}{
+			"name":   taskDefName,
+			"labels": map[string]string{"app": taskDefName},
+			"annotations": map[string]string{
+				"alb.ingress.kubernetes.io/target-type": "ip",
+			},
+		},
+		"spec": map[string]interface{}{
+			"ingressClassName": "alb",
+			"rules":            rules,
+		},
+	}
+}
+
+// ingressPath converts an ALB path pattern into an Ingress path and pathType
+func ingressPath(pattern string) (string, string) {
+	if pattern == "" || pattern == "/" || pattern == "/*" || pattern == "*" {
+		return "/", "Prefix"
+	}
+
+	trimmed := strings.TrimSuffix(pattern, "*")
+	if !strings.ContainsAny(trimmed, "*?") {
+		if trimmed != pattern {
+			// "/api/*" and "/api*" both become the /api prefix
+			trimmed = strings.TrimSuffix(trimmed, "/")
+			if trimmed == "" {
+				trimmed = "/"
+			}
+			return trimmed, "Prefix"
+		}
+		return pattern, "Exact"
+	}
+
+	// Wildcards in the middle of a pattern are controller-specific
+	return pattern, "ImplementationSpecific"
+}
+
+// describeTargetGroups describes target groups and resolves the listener rules
+// that forward to them
+func (r *loadBalancerResolver) describeTargetGroups(ctx context.Context, arns []string) (map[string]*targetGroupInfo, error) {
+	result := map[string]*targetGroupInfo{}
+
+	unique := map[string]bool{}
+	var pending []string
+	for _, arn := range arns {
+		if !unique[arn] {
+			unique[arn] = true
+			pending = append(pending, arn)
+		}
+	}
+
+	for start := 0; start < len(pending); start += 20 {
+		end := start + 20
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		out, err := r.client.DescribeTargetGroups(ctx, &elbv2.DescribeTargetGroupsInput{
+			TargetGroupArns: pending[start:end],
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to describe target groups: %w", err)
+		}
+
+		for i := range out.TargetGroups {
+			tg := out.TargetGroups[i]
+			info := &targetGroupInfo{
+				Arn:      aws.ToString(tg.TargetGroupArn),
+				Name:     aws.ToString(tg.TargetGroupName),
+				Protocol: string(tg.Protocol),
+				Source:   &tg,
+			}
+
+			for _, lbArn := range tg.LoadBalancerArns {
+				lbRules, err := r.loadBalancerRoutes(ctx, lbArn)
+				if err != nil {
+					log.Printf("Warning: Failed to read listener rules of %s: %v", lbArn, err)
+					continue
+				}
+				info.Routes = append(info.Routes, lbRules.routes[info.Arn]...)
+				info.Rules = append(info.Rules, lbRules.rules[info.Arn]...)
+			}
+
+			result[info.Arn] = info
+		}
+	}
+
+	return result, nil
+}
+
+// loadBalancerRoutes returns the host/path conditions and the rules per target
+// group of all listener rules of a load balancer
+func (r *loadBalancerResolver) loadBalancerRoutes(ctx context.Context, lbArn string) (*loadBalancerRules, error) {
+	if lbRules, ok := r.rulesByLoadBalancer[lbArn]; ok {
+		return lbRules, nil
+	}
+
+	lbRules := &loadBalancerRules{
+		routes: map[string][]ingressRoute{},
+		rules:  map[string][]listenerRule{},
+	}
+
+	listeners := elbv2.NewDescribeListenersPaginator(r.client, &elbv2.DescribeListenersInput{
+		LoadBalancerArn: aws.String(lbArn),
+	})
+	for listeners.HasMorePages() {
+		page, err := listeners.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe listeners: %w", err)
+		}
+
+		for _, listener := range page.Listeners {
+			var marker *string
+			for {
+				out, err := r.client.DescribeRules(ctx, &elbv2.DescribeRulesInput{
+					ListenerArn: listener.ListenerArn,
+					Marker:      marker,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to describe rules of listener %s: %w", aws.ToString(listener.ListenerArn), err)
+				}
+
+				for _, rule := range out.Rules {
+					ruleRoutes := ruleConditionRoutes(rule.Conditions)
+					tgArns := forwardTargetGroups(rule.Actions)
+					for _, tgArn := range tgArns {
+						lbRules.routes[tgArn] = appendUniqueRoutes(lbRules.routes[tgArn], ruleRoutes...)
+						lbRules.rules[tgArn] = append(lbRules.rules[tgArn], listenerRule{
+							Arn:             aws.ToString(rule.RuleArn),
+							ListenerArn:     aws.ToString(listener.ListenerArn),
+							IsDefault:       aws.ToBool(rule.IsDefault),
+							TargetGroupArns: tgArns,
+						})
+					}
+				}
+
+				if out.NextMarker == nil {
+					break
+				}
+				marker = out.NextMarker
+			}
+		}
+	}
+
+	r.rulesByLoadBalancer[lbArn] = lbRules
+	return lbRules, nil
+}
+
+// ruleConditionRoutes expands the host-header and path-pattern conditions of a
+// listener rule; the default rule has no conditions and matches "/"
+func ruleConditionRoutes(conditions []elbv2types.RuleCondition) []ingressRoute {
+	var hosts, paths []string
+	for _, cond := range conditions {
+		switch aws.ToString(cond.Field) {
+		case "host-header":
+			if cond.HostHeaderConfig != nil {
+				hosts = append(hosts, cond.HostHeaderConfig.Values...)
+			} else {
+				hosts = append(hosts, cond.Values...)
+			}
+		case "path-pattern":
+			if cond.PathPatternConfig != nil {
+				paths = append(paths, cond.PathPatternConfig.Values...)
+			} else {
+				paths = append(paths, cond.Values...)
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+
+	var routes []ingressRoute
+	for _, host := range hosts {
+		for _, path := range paths {
+			routes = append(routes, ingressRoute{Host: host, Path: path})
+		}
+	}
+	return routes
+}
+
+// forwardTargetGroups lists the target groups a rule's actions forward to
+func forwardTargetGroups(actions []elbv2types.Action) []string {
+	var arns []string
+	for _, action := range actions {
+		if action.Type != elbv2types.ActionTypeEnumForward {
+			continue
+		}
+		if action.TargetGroupArn != nil {
+			arns = append(arns, *action.TargetGroupArn)
+		}
+		if action.ForwardConfig != nil {
+			for _, tg := range action.ForwardConfig.TargetGroups {
+				if tg.TargetGroupArn != nil {
+					arns = append(arns, *tg.TargetGroupArn)
+				}
+			}
+		}
+	}
+	return arns
+}
+
+// appendUniqueRoutes appends routes that aren't already present
+func appendUniqueRoutes(routes []ingressRoute, add ...ingressRoute) []ingressRoute {
+	for _, route := range add {
+		found := false
+		for _, existing := range routes {
+			if existing == route {
+				found = true
+				break
+			}
+		}
+		if !found {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// containerTargetGroups keeps the attachments of containers converted into the
+// pod, dropping those of containers split into other services
+func containerTargetGroups(attachments []targetGroupAttachment, containers []ContainerConfig) []targetGroupAttachment {
+	names := map[string]bool{}
+	for _, c := range containers {
+		names[c.Name] = true
+	}
+
+	var kept []targetGroupAttachment
+	for _, a := range attachments {
+		if names[a.ContainerName] {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// targetGroupName extracts the name from a target group ARN
+// (arn:aws:elasticloadbalancing:region:account:targetgroup/name/id)
+func targetGroupName(arn string) string {
+	parts := strings.Split(arn, "/")
+	if len(parts) >= 3 {
+		return parts[len(parts)-2]
+	}
+	return arn
+}
+
+// findService returns the Service generated for a container
+func findService(services []*corev1.Service, containerName string) *corev1.Service {
+	for _, svc := range services {
+		if svc != nil && svc.Name == containerName {
+			return svc
+		}
+	}
+	return nil
+}
+
+// hasServicePort checks if a Service exposes a port
+func hasServicePort(svc *corev1.Service, port int32) bool {
+	for _, p := range svc.Spec.Ports {
+		if p.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// nameServicePorts names the ports of multi-port Services, which Kubernetes requires
+func nameServicePorts(svc *corev1.Service) {
+	if len(svc.Spec.Ports) < 2 {
+		return
+	}
+	for i := range svc.Spec.Ports {
+		if svc.Spec.Ports[i].Name == "" {
+			svc.Spec.Ports[i].Name = fmt.Sprintf("port-%d", svc.Spec.Ports[i].Port)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// TestIngressPath tests conversion of ALB path patterns to Ingress paths
+func TestIngressPath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		pathType string
+	}{
+		{pattern: "/", path: "/", pathType: "Prefix"},
+		{pattern: "/*", path: "/", pathType: "Prefix"},
+		{pattern: "/api/*", path: "/api", pathType: "Prefix"},
+		{pattern: "/api*", path: "/api", pathType: "Prefix"},
+		{pattern: "/health", path: "/health", pathType: "Exact"},
+		{pattern: "/v?/users/*", path: "/v?/users/*", pathType: "ImplementationSpecific"},
+	}
+
+	for _, tt := range tests {
+		path, pathType := ingressPath(tt.pattern)
+		if path != tt.path || pathType != tt.pathType {
+			t.Errorf("ingressPath(%q) = (%q, %q), want (%q, %q)", tt.pattern, path, pathType, tt.path, tt.pathType)
+		}
+	}
+}
+
+// TestApplyTargetGroups tests that every target group attachment is exposed
+// and that several target groups produce an Ingress
+func TestApplyTargetGroups(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:         aws.String("web"),
+				Image:        aws.String("nginx:latest"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8080)}},
+			},
+		},
+	}
+
+	taskDefInfo, err := buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	taskDefInfo.Services = []types.Service{
+		{
+			ServiceName: aws.String("web"),
+			LoadBalancers: []types.LoadBalancer{
+				{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-http/1"), ContainerName: aws.String("web"), ContainerPort: aws.Int32(8080)},
+				{TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-admin/2"), ContainerName: aws.String("web"), ContainerPort: aws.Int32(9090)},
+			},
+		},
+	}
+
+	targetGroups := map[string]*targetGroupInfo{
+		"arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-admin/2": {
+			Routes: []ingressRoute{{Path: "/admin/*"}},
+		},
+	}
+	applyTargetGroups(taskDefInfo, serviceTargetGroups(taskDefInfo.Services), targetGroups)
+
+	svc := findService(taskDefInfo.Manifests.Services, "web")
+	if svc == nil {
+		t.Fatalf("Service for container web not found")
+	}
+	if !hasServicePort(svc, 8080) || !hasServicePort(svc, 9090) {
+		t.Errorf("Service ports = %+v, want 8080 and 9090", svc.Spec.Ports)
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "" {
+			t.Errorf("multi-port Service has unnamed port %d", p.Port)
+		}
+	}
+
+	if len(taskDefInfo.Manifests.Extras) != 1 || taskDefInfo.Manifests.Extras[0].Suffix != "ingress" {
+		t.Fatalf("expected one ingress extra, got %+v", taskDefInfo.Manifests.Extras)
+	}
+}
+
+// TestApplyTargetGroupBindings tests binding Services to the existing target groups
+func TestApplyTargetGroupBindings(t *testing.T) {
+	const httpArn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/Web-HTTP/1"
+	const bridgeArn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web-bridge/2"
+	attachments := []targetGroupAttachment{
+		{TargetGroupArn: httpArn, ContainerName: "web", ContainerPort: 8080},
+		{TargetGroupArn: bridgeArn, ContainerName: "web", ContainerPort: 9090},
+	}
+	targetGroups := map[string]*targetGroupInfo{
+		bridgeArn: {Source: &elbv2types.TargetGroup{TargetType: elbv2types.TargetTypeEnumInstance}},
+	}
+
+	taskDefInfo := &TaskDefInfo{Name: "web"}
+	applyTargetGroupBindings(taskDefInfo, attachments, targetGroups)
+
+	extras := taskDefInfo.Manifests.Extras
+	if len(extras) != 2 || extras[0].Suffix != "web-http-targetgroupbinding" {
+		t.Fatalf("extras = %+v, want two TargetGroupBindings", extras)
+	}
+	spec := extras[0].Object["spec"].(map[string]interface{})
+	if spec["targetGroupARN"] != httpArn || spec["targetType"] != "ip" {
+		t.Errorf("spec = %v, want ip binding to %s", spec, httpArn)
+	}
+	if spec := extras[1].Object["spec"].(map[string]interface{}); spec["targetType"] != "instance" {
+		t.Errorf("targetType = %v, want instance", spec["targetType"])
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockFileName is the workspace lock held in the output directory during a run
+const lockFileName = ".ecs2k8s.lock"
+
+// staleLockAge is the age after which a lock of another host is considered
+// abandoned; its process cannot be checked from here
+const staleLockAge = 2 * time.Hour
+
+// workspaceLock records the run holding the output directory
+type workspaceLock struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// acquireWorkspaceLock locks an output directory so concurrent conversions of
+// the same cluster don't interleave their writes. Stale locks, left by a run
+// that is no longer alive, are replaced; forceUnlock replaces any lock. The
+// returned function releases the lock.
+func acquireWorkspaceLock(dir string, forceUnlock bool) (func(), error) {
+	path := filepath.Join(dir, lockFileName)
+	host, _ := os.Hostname()
+	lock := workspaceLock{
+		PID:       os.Getpid(),
+		Host:      host,
+		Command:   strings.Join(os.Args, " "),
+		StartedAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	// One retry after removing a stale or forcibly unlocked lock
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, writeErr := f.Write(data)
+			closeErr := f.Close()
+			if err := errors.Join(writeErr, closeErr); err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock %s: %w", path, err)
+			}
+			return func() { releaseWorkspaceLock(path, lock.PID) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock %s: %w", path, err)
+		}
+
+		held, err := readWorkspaceLock(path)
+		switch {
+		case err != nil:
+			// Unreadable locks are left by a run interrupted while writing one
+			log.Printf("Warning: Replacing unreadable lock %s: %v", path, err)
+		case forceUnlock:
+			log.Printf("Warning: Removing lock of PID %d on %s (--force-unlock)", held.PID, held.Host)
+		case held.isStale(host, time.Now()):
+			log.Printf("Warning: Removing stale lock of PID %d on %s from %s", held.PID, held.Host, held.StartedAt.Format(time.RFC3339))
+		default:
+			return nil, fmt.Errorf("output directory %s is locked by PID %d on %s since %s (%s); pass --force-unlock if no other conversion is running",
+				dir, held.PID, held.Host, held.StartedAt.Format(time.RFC3339), held.Command)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove lock %s: %w", path, err)
+		}
+	}
+
+	return nil, fmt.Errorf("output directory %s was locked by another run while acquiring the lock", dir)
+}
+
+// readWorkspaceLock reads the lock file of an output directory
+func readWorkspaceLock(path string) (*workspaceLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock workspaceLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// isStale reports whether the run holding the lock is gone: on this host its
+// process no longer exists, elsewhere the lock has outlived staleLockAge
+func (l *workspaceLock) isStale(host string, now time.Time) bool {
+	if l.Host == host {
+		return !processRunning(l.PID)
+	}
+	return now.Sub(l.StartedAt) > staleLockAge
+}
+
+// releaseWorkspaceLock removes the lock if it is still held by this process
+func releaseWorkspaceLock(path string, pid int) {
+	held, err := readWorkspaceLock(path)
+	if err != nil || held.PID != pid {
+		log.Printf("Warning: Lock %s was taken over by another run, leaving it in place", path)
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("Warning: Failed to remove lock %s: %v", path, err)
+	}
+}
+
+// processRunning reports whether a process exists on this host. Finding the
+// process fails on Windows once it exited; elsewhere signal 0 probes it.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAcquireWorkspaceLock tests that a second run cannot lock the same directory
+func TestAcquireWorkspaceLock(t *testing.T) {
+	dir := t.TempDir()
+
+	release, err := acquireWorkspaceLock(dir, false)
+	if err != nil {
+		t.Fatalf("acquireWorkspaceLock() error = %v", err)
+	}
+	if _, err := acquireWorkspaceLock(dir, false); err == nil {
+		t.Fatalf("second acquireWorkspaceLock() succeeded while locked")
+	}
+
+	release()
+	if _, err := os.Stat(filepath.Join(dir, lockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("lock file still present after release: %v", err)
+	}
+
+	release, err = acquireWorkspaceLock(dir, false)
+	if err != nil {
+		t.Fatalf("acquireWorkspaceLock() after release error = %v", err)
+	}
+	release()
+}
+
+// TestAcquireWorkspaceLockReplacesStale tests stale lock detection and --force-unlock
+func TestAcquireWorkspaceLockReplacesStale(t *testing.T) {
+	host, _ := os.Hostname()
+	writeLock := func(t *testing.T, dir string, lock workspaceLock) {
+		data, _ := json.Marshal(lock)
+		if err := os.WriteFile(filepath.Join(dir, lockFileName), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lock  workspaceLock
+		force bool
+		ok    bool
+	}{
+		{"running process", workspaceLock{PID: os.Getpid(), Host: host, StartedAt: time.Now()}, false, false},
+		{"force unlock", workspaceLock{PID: os.Getpid(), Host: host, StartedAt: time.Now()}, true, true},
+		{"exited process", workspaceLock{PID: 1 << 22, Host: host, StartedAt: time.Now()}, false, true},
+		{"recent lock of another host", workspaceLock{PID: 1, Host: "other-" + host, StartedAt: time.Now()}, false, false},
+		{"old lock of another host", workspaceLock{PID: 1, Host: "other-" + host, StartedAt: time.Now().Add(-3 * time.Hour)}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLock(t, dir, tt.lock)
+
+			release, err := acquireWorkspaceLock(dir, tt.force)
+			if (err == nil) != tt.ok {
+				t.Fatalf("acquireWorkspaceLock() error = %v, want success %v", err, tt.ok)
+			}
+			if release != nil {
+				release()
+			}
+		})
+	}
+}
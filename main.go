@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/krishnaduttPanchagnula/ecs2k8s/validators"
 )
@@ -22,35 +26,592 @@ func main() {
 		Long: `ecs2k8s converts AWS ECS clusters and task definitions into equivalent
 Kubernetes manifests (Deployment, Service, ConfigMap, Secret) and optionally
 generates a Helm chart or Kustomize structure for easy deployment and management.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			region, _ := cmd.Flags().GetString("region")
-			if region == "" {
-				return fmt.Errorf("region flag is required")
+		// Every command describes task definitions through the shared cache
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			noCache, _ := cmd.Flags().GetBool("no-cache")
+			cacheDir, _ := cmd.Flags().GetString("cache-dir")
+			switch {
+			case noCache:
+				taskDefinitions.dir = ""
+			case cacheDir != "":
+				taskDefinitions.dir = cacheDir
+			default:
+				taskDefinitions.dir = defaultTaskDefCacheDir()
 			}
 
-			if err := validateRegion(region); err != nil {
+			proxy, _ := cmd.Flags().GetString("proxy")
+			noProxy, _ := cmd.Flags().GetString("no-proxy")
+			caBundle, _ := cmd.Flags().GetString("ca-bundle")
+			if err := awsHTTP.configure(proxy, noProxy, cmd.Flags().Changed("no-proxy"), caBundle); err != nil {
 				return err
 			}
+			awsEndpoints.fips, _ = cmd.Flags().GetBool("use-fips-endpoint")
+			awsEndpoints.dualStack, _ = cmd.Flags().GetBool("use-dualstack-endpoint")
 
-			createHelm, _ := cmd.Flags().GetBool("create-helm")
-			createKustomize, _ := cmd.Flags().GetBool("create-kustomize")
-
-			return runEcs2K8s(region, createHelm, createKustomize)
+			cpuProfile, _ := cmd.Flags().GetString("cpu-profile")
+			memProfile, _ := cmd.Flags().GetString("mem-profile")
+			return profiling.start(cpuProfile, memProfile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := parseRunOptions(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			return runEcs2K8s(opts)
 		},
 	}
 
-	rootCmd.Flags().StringP("region", "r", "", "AWS region (required)")
-	rootCmd.Flags().BoolP("create-helm", "H", false, "Create Helm chart (default: false)")
-	rootCmd.Flags().BoolP("create-kustomize", "K", false, "Create Kustomize structure with base and overlays (default: false)")
+	addConversionFlags(rootCmd.Flags())
+
+	rootCmd.PersistentFlags().String("cache-dir", "", "Directory caching described task definition revisions between runs (default: <user cache dir>/"+taskDefCacheDirName+")")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Describe every task definition from ECS instead of reusing cached revisions")
+	rootCmd.PersistentFlags().String("proxy", "", "HTTP(S) or SOCKS5 proxy URL of the AWS API calls (default: HTTPS_PROXY or HTTP_PROXY)")
+	rootCmd.PersistentFlags().String("no-proxy", "", "Comma-separated hosts, domains and CIDRs reaching AWS without the proxy, such as VPC endpoints (default: NO_PROXY)")
+	rootCmd.PersistentFlags().String("ca-bundle", "", "PEM file of CA certificates trusted for AWS API calls in addition to the system roots, e.g. of a TLS-inspecting proxy (default: AWS_CA_BUNDLE)")
+	rootCmd.PersistentFlags().Bool("use-fips-endpoint", false, "Call the FIPS 140 endpoints of the AWS APIs, available in the US and GovCloud regions (default: AWS_USE_FIPS_ENDPOINT)")
+	rootCmd.PersistentFlags().Bool("use-dualstack-endpoint", false, "Call the dualstack (IPv4 and IPv6) endpoints of the AWS APIs, for IPv6-only networks (default: AWS_USE_DUALSTACK_ENDPOINT)")
+	rootCmd.PersistentFlags().String("cpu-profile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	rootCmd.PersistentFlags().String("mem-profile", "", "Write a memory allocation profile of the run to this file, for go tool pprof")
+
+	rootCmd.AddCommand(newServeCommand())
+	rootCmd.AddCommand(newOperatorCommand())
+	rootCmd.AddCommand(newDriftCommand())
+	rootCmd.AddCommand(newWatchCommand())
+	rootCmd.AddCommand(newPlanCapacityCommand())
+	rootCmd.AddCommand(newSimulateSchedulingCommand())
+	rootCmd.AddCommand(newVerifyCommand())
+	rootCmd.AddCommand(newAnalyzeCommand())
+	rootCmd.AddCommand(newPruneCommand())
+	rootCmd.AddCommand(newPreflightCommand())
+	rootCmd.AddCommand(newDoctorCommand())
+	rootCmd.AddCommand(newVersionCommand())
+
+	err := rootCmd.Execute()
+	profiling.stop()
+	if err != nil {
+		if isPartialSuccess(err) {
+			log.Print(err)
+			os.Exit(exitPartialSuccess)
+		}
+		log.Fatal(err)
+	}
+}
+
+// addConversionFlags defines the flags of a conversion run
+func addConversionFlags(flags *pflag.FlagSet) {
+	flags.StringP("region", "r", "", "AWS region (required unless reading a --from-* input, default: region of its ARNs)")
+	flags.StringP("cluster", "c", "", "ECS cluster name or ARN (skips the interactive selection)")
+	flags.Bool("all-clusters", false, "Convert every ECS cluster in the region, each into its own output directory")
+	flags.BoolP("assume-yes", "y", false, "Never prompt; fail when the cluster cannot be determined from the flags")
+	flags.Bool("show-arns", false, "Show full cluster ARNs in the interactive selection")
+	flags.String("prompt-style", "", "Style of the interactive selection: arrows, ascii (arrow keys without unicode glyphs) or plain (numbered list read line by line, for screen readers) (default: plain when TERM=dumb, else arrows)")
+	flags.String("output-naming", outputNamingName, "Output directory naming: name, account (name-<account-id>) or hash (name-<arn-hash>)")
+	flags.String("overrides-dir", "", "Directory of per-service override patches merged into the output (default: <output>/overrides)")
+	flags.BoolP("create-helm", "H", false, "Create Helm chart (default: false)")
+	flags.BoolP("create-kustomize", "K", false, "Create Kustomize structure with base and overlays (default: false)")
+	flags.String("crossplane", "", "Create a Crossplane export in <output>/crossplane: objects (provider-kubernetes Objects) or composition (XRD, Compositions and claims)")
+	flags.String("crossplane-provider-config", "default", "provider-kubernetes ProviderConfig referenced by the Crossplane Objects")
+	flags.String("admission-policies", "", "Create starter admission policies in <output>/"+admissionDirName+" locking in the registries, labels and unprivileged containers of the converted services: kyverno (ClusterPolicies) or gatekeeper (constraints)")
+	flags.String("fleet", "", "YAML file of accounts (roleArn, optional region and clusters) converted concurrently into a directory per account, with a fleet report")
+	flags.Bool("anonymize", false, "Hash account IDs, strip ARNs and secret values, and replace image registries with placeholders in all output, for sharing conversions")
+	flags.Bool("split-containers", false, "Convert each container, except the sidecars declared in the config file, into its own Deployment and Service instead of one multi-container pod")
+	flags.Bool("save-source", false, "Write the source task definition as <task-def>-source.json (DescribeTaskDefinition JSON) next to the manifests")
+	flags.String("profile", "", "Target platform preset adjusting identity annotations, ingress class and pod security: "+strings.Join(profileNames, ", ")+" (default: EKS with the ECS defaults)")
+	flags.String("identity-map", "", "YAML file mapping ECS IAM role ARNs or names to the GKE service accounts or AKS client IDs of --profile=gke/aks")
+	flags.String("output", outputDeployment, "Workload generated per service: deployment, or knative (a Knative Service for services serving one container port)")
+	flags.Bool("continue-on-error", false, "Run every output stage (Helm, Kustomize, Crossplane, report, ...) even after one fails; the run exits with code 2 when manifests were written despite failures")
+	flags.Bool("force-unlock", false, "Remove the lock of another run on the output directory (only when no other conversion is running)")
+	flags.Bool("stdout", false, "Write all manifests as one multi-document YAML stream to stdout instead of files (logs go to stderr)")
+	flags.Bool("create-keda", false, "Create KEDA ScaledObjects for services consuming SQS or Kafka")
+	flags.Bool("create-karpenter", false, "Create a Karpenter NodePool and EC2NodeClass in <output>/infra matching the architectures, placement and spot usage of the cluster")
+	flags.Bool("target-group-bindings", false, "Generate TargetGroupBindings registering the pods in the ECS services' existing target groups (AWS Load Balancer Controller)")
+	flags.Int("cutover-weight", 0, "Percent of ALB traffic to shift to Kubernetes: generates TargetGroupBindings and cutover/<task-def>.sh with weighted listener rules")
+	flags.String("rollouts", "", "Create progressive delivery stubs with automatic rollback: argo (Rollout + AnalysisTemplate) or flagger (Canary)")
+	flags.String("zero-trust", "", "Restrict pod ingress to what the services' security groups allow: cilium (CiliumNetworkPolicy with mutual authentication) or network-policy (NetworkPolicy)")
+	flags.String("image-pull-policy", string(defaultImagePullPolicy), "Image pull policy of every container: Always, IfNotPresent or Never")
+	flags.String("probe-source", probeSourceBoth, "Probes of containers with both an ECS container health check and a target group health check: container (both probes from the container check), alb (readiness from the target group, container check dropped) or both (liveness from the container check, readiness from the target group)")
+	flags.Bool("aws-env", true, "Add AWS_REGION and AWS_DEFAULT_REGION, which ECS sets implicitly, to containers that don't define them")
+	flags.StringArray("label", nil, "Label added to every generated object as [kind:]key=value (repeatable)")
+	flags.StringArray("annotation", nil, "Annotation added to every generated object as [kind:]key=value (repeatable)")
+	flags.StringSlice("include-kinds", nil, "Only generate objects of these kinds, e.g. Deployment,Service (case-insensitive; raw manifests, Helm templates and Kustomize resources)")
+	flags.StringSlice("exclude-kinds", nil, "Do not generate objects of these kinds, e.g. Secret (case-insensitive; wins over --include-kinds)")
+	flags.StringArray("api-version", nil, "apiVersion of generated objects as [kind:]group/version, e.g. keda.sh/v1alpha1 or ingress:networking.k8s.io/v1 (repeatable; the schema must match the generated one)")
+	flags.StringArray("values-override", nil, "Value set in the generated Helm values.yaml as key.path=value, e.g. services.*.namespace=prod or defaultReplicas=2; * matches every key (repeatable, requires --create-helm)")
+	flags.Bool("helm-tests", false, "Write helm-unittest suites asserting each service renders its Deployment, Service and ServiceAccount with the generated values to the chart's tests directory (requires --create-helm)")
+	flags.Bool("helm-group-by-family", false, "Group the services of the Helm values.yaml by task definition family, with the values shared by a family's services in its defaults (requires --create-helm)")
+	flags.Bool("resolve-secrets", false, "Resolve ECS container secrets from Secrets Manager and SSM Parameter Store into Kubernetes Secrets")
+	flags.String("secrets-mode", secretsModeKubernetes, "Where resolved secrets go: kubernetes (Secrets) or vault (KV v2, implies --resolve-secrets)")
+	flags.String("vault-addr", "", "Vault address for --secrets-mode=vault (default: $VAULT_ADDR); the token is read from $VAULT_TOKEN")
+	flags.String("vault-mount", "secret", "KV v2 mount secrets are written to with --secrets-mode=vault")
+	flags.String("vault-path", "ecs2k8s", "Path prefix of the written secrets, followed by <task-def>/<container>")
+	flags.String("vault-role", "", "Vault Kubernetes auth role of the pods (default: the service name)")
+	flags.String("vault-injection", vaultInjectionAgent, "How pods receive Vault secrets: agent (injector annotations) or static-secret (VaultStaticSecret)")
+	flags.Bool("alerts", false, "Create a PrometheusRule per service alerting on crash-looping containers, high restart rates and replicas below desired")
+	flags.Bool("convert-alarms", false, "Convert the CloudWatch alarms on the CPUUtilization and MemoryUtilization of each ECS service into a PrometheusRule")
+	flags.Bool("headless-workers", false, "Generate a headless Service for workers, services without port mappings, so their pods can be discovered through DNS")
+	flags.Bool("smart-templates", false, "Convert well-known images with opinionated templates: standard probes for nginx, redis, postgres and rabbitmq, and StatefulSets with a PersistentVolumeClaim for the databases")
+	flags.String("cert-manager-issuer", "", "ClusterIssuer of the cert-manager Certificate stubs generated for services reading TLS certificate, key or keystore files; the issued Secret is mounted at the files' paths")
+	flags.Bool("conversion-records", false, "Add a ConversionRecord custom resource per service listing its source ARNs, generated objects and unmapped fields (CRD: ecs2k8s operator --print-crd)")
+	flags.Bool("grafana-dashboard", false, "Write "+grafanaDashboardFileName+", a starter Grafana dashboard of the converted services' CPU, memory, restarts and replicas from cAdvisor and kube-state-metrics")
+	flags.Bool("events", false, "Write "+eventsFileName+" with a timestamped timeline per service (discovered, warnings, converted with the written files, failed) for migration tracking tools")
+	flags.Bool("sign", false, "Write "+checksumsFileName+" and an in-toto SLSA provenance ("+provenanceFileName+") recording the source cluster ARN and ecs2k8s version to each cluster's output directory")
+	flags.String("cosign", "", "Sign the provenance of --sign with cosign into "+sigstoreBundleName+": a key reference (file, KMS URI) or keyless for Sigstore keyless signing (requires cosign in PATH)")
+	flags.Bool("bundle", false, "Package each cluster's output directory into <output>-<timestamp>.tar.gz next to it, with an "+bundleIndexName+" listing its files and objects")
+	flags.Bool("decommission-plan", false, "Write decommission-plan.md/.json listing the source services, task definitions, load balancers and scaling policies with their owning IaC")
+	flags.String("aws-batch", awsBatchExclude, "Task definitions managed by AWS Batch compute environments, which run as jobs rather than services: exclude them, or convert them into suspended Jobs (job) or Argo WorkflowTemplates (argo) in <output>/"+jobsDirName)
+	flags.Bool("step-functions", false, "Discover the Step Functions state machines running task definitions on the cluster (ecs:runTask) and write suspended Jobs of them with a "+stepFunctionsFileName+" mapping into <output>/"+jobsDirName)
+	flags.String("from-cfn-template", "", "Convert the ECS services of a CloudFormation template (JSON or YAML) instead of reading the ECS API")
+	flags.String("from-terraform-state", "", "Convert the aws_ecs_service resources of a Terraform state file instead of reading the ECS API")
+	flags.String("from-cdk-out", "", "Convert the ECS services of the templates in a cdk.out directory instead of reading the ECS API")
+	flags.String("from-json", "", "Convert ECS API responses exported with the AWS CLI (describe-services, describe-task-definition --include TAGS) from a JSON file or a directory of them instead of reading the ECS API")
+	flags.Bool("offline", false, "Guarantee the conversion runs from exported data only: requires a --from-* input and refuses every AWS call")
+	flags.String("config", "", "Path to a YAML config file with default and per-service conversion settings")
+	flags.String("anti-affinity", "", "Spread replicas of a service across nodes: soft (preferred) or hard (required)")
+	flags.Bool("rightsize", false, "Set requests from CloudWatch utilization instead of the ECS reservations")
+	flags.String("min-cpu", "", "Minimum CPU request and limit of every container, e.g. 50m (for LimitRange minimums)")
+	flags.String("min-memory", "", "Minimum memory request and limit of every container, e.g. 64Mi")
+	flags.StringArray("policy", nil, "Rego policy file or directory (package ecs2k8s) whose deny and warn rules are evaluated against every generated object with the opa CLI (repeatable)")
+	flags.Bool("strict", false, "Fail the run and skip writing services whose objects violate a --policy deny rule, instead of listing violations in the report")
+	flags.Bool("lint", false, "Check the generated objects for missing probes and limits, default namespace usage, unpinned image tags and oversized manifests, listing findings in the report")
+	flags.StringArray("lint-severity", nil, "Severity of a --lint check as check=severity, e.g. latest-tag=error or default-namespace=off (repeatable; error findings fail the run)")
+	flags.Int("rightsize-days", 14, "Days of CloudWatch utilization history used by --rightsize")
+	flags.Float64("rightsize-percentile", 95, "Utilization percentile used by --rightsize")
+}
+
+// parseRunOptions reads and validates the flags of a conversion run
+func parseRunOptions(flags *pflag.FlagSet) (*runOptions, error) {
+	region, _ := flags.GetString("region")
+	cluster, _ := flags.GetString("cluster")
+	allClusters, _ := flags.GetBool("all-clusters")
+	assumeYes, _ := flags.GetBool("assume-yes")
+	showARNs, _ := flags.GetBool("show-arns")
+	promptStyle, _ := flags.GetString("prompt-style")
+	outputNaming, _ := flags.GetString("output-naming")
+	overridesDir, _ := flags.GetString("overrides-dir")
+	createHelm, _ := flags.GetBool("create-helm")
+	createKustomize, _ := flags.GetBool("create-kustomize")
+	stdout, _ := flags.GetBool("stdout")
+	createKEDA, _ := flags.GetBool("create-keda")
+	rollouts, _ := flags.GetString("rollouts")
+	zeroTrust, _ := flags.GetString("zero-trust")
+	createKarpenter, _ := flags.GetBool("create-karpenter")
+	cutoverWeight, _ := flags.GetInt("cutover-weight")
+	targetGroupBindings, _ := flags.GetBool("target-group-bindings")
+	fromCFNTemplate, _ := flags.GetString("from-cfn-template")
+	fromTerraformState, _ := flags.GetString("from-terraform-state")
+	fromCDKOut, _ := flags.GetString("from-cdk-out")
+	fromJSON, _ := flags.GetString("from-json")
+	offline, _ := flags.GetBool("offline")
+	decommissionPlan, _ := flags.GetBool("decommission-plan")
+	stepFunctions, _ := flags.GetBool("step-functions")
+	awsBatch, _ := flags.GetString("aws-batch")
+	events, _ := flags.GetBool("events")
+	grafana, _ := flags.GetBool("grafana-dashboard")
+	alerts, _ := flags.GetBool("alerts")
+	convertAlarms, _ := flags.GetBool("convert-alarms")
+	smartTemplates, _ := flags.GetBool("smart-templates")
+	headlessWorkers, _ := flags.GetBool("headless-workers")
+	conversionRecords, _ := flags.GetBool("conversion-records")
+	certManagerIssuer, _ := flags.GetString("cert-manager-issuer")
+	bundle, _ := flags.GetBool("bundle")
+	sign, _ := flags.GetBool("sign")
+	cosign, _ := flags.GetString("cosign")
+	crossplane, _ := flags.GetString("crossplane")
+	admissionPolicies, _ := flags.GetString("admission-policies")
+	crossplaneProviderConfig, _ := flags.GetString("crossplane-provider-config")
+	forceUnlock, _ := flags.GetBool("force-unlock")
+	continueOnError, _ := flags.GetBool("continue-on-error")
+	fleetPath, _ := flags.GetString("fleet")
+	anonymize, _ := flags.GetBool("anonymize")
+	splitContainers, _ := flags.GetBool("split-containers")
+	saveSource, _ := flags.GetBool("save-source")
+	profileName, _ := flags.GetString("profile")
+	identityMapPath, _ := flags.GetString("identity-map")
+	output, _ := flags.GetString("output")
+	minCPU, _ := flags.GetString("min-cpu")
+	minMemory, _ := flags.GetString("min-memory")
+	policyPaths, _ := flags.GetStringArray("policy")
+	strict, _ := flags.GetBool("strict")
+	lint, _ := flags.GetBool("lint")
+	lintSeverities, _ := flags.GetStringArray("lint-severity")
+	configPath, _ := flags.GetString("config")
+	antiAffinity, _ := flags.GetString("anti-affinity")
+	rightsize, _ := flags.GetBool("rightsize")
+	rightsizeDays, _ := flags.GetInt("rightsize-days")
+	rightsizePercentile, _ := flags.GetFloat64("rightsize-percentile")
+	imagePullPolicy, _ := flags.GetString("image-pull-policy")
+	awsEnv, _ := flags.GetBool("aws-env")
+	probeSource, _ := flags.GetString("probe-source")
+	labels, _ := flags.GetStringArray("label")
+	annotations, _ := flags.GetStringArray("annotation")
+	apiVersionFlags, _ := flags.GetStringArray("api-version")
+	includeKinds, _ := flags.GetStringSlice("include-kinds")
+	excludeKinds, _ := flags.GetStringSlice("exclude-kinds")
+	valuesOverrideFlags, _ := flags.GetStringArray("values-override")
+	helmTests, _ := flags.GetBool("helm-tests")
+	helmByFamily, _ := flags.GetBool("helm-group-by-family")
+	resolveSecrets, _ := flags.GetBool("resolve-secrets")
+	secretsMode, _ := flags.GetString("secrets-mode")
+	vaultAddr, _ := flags.GetString("vault-addr")
+	vaultMount, _ := flags.GetString("vault-mount")
+	vaultPath, _ := flags.GetString("vault-path")
+	vaultRole, _ := flags.GetString("vault-role")
+	vaultInjection, _ := flags.GetString("vault-injection")
+
+	if stdout && decommissionPlan {
+		return nil, fmt.Errorf("--decommission-plan writes files and cannot be combined with --stdout")
+	}
+	if stdout && stepFunctions {
+		return nil, fmt.Errorf("--step-functions writes files and cannot be combined with --stdout")
+	}
+
+	if stdout && saveSource {
+		return nil, fmt.Errorf("--save-source writes files and cannot be combined with --stdout")
+	}
+
+	if stdout && bundle {
+		return nil, fmt.Errorf("--bundle writes files and cannot be combined with --stdout")
+	}
+	var signing *signer
+	if sign {
+		if stdout {
+			return nil, fmt.Errorf("--sign writes files and cannot be combined with --stdout")
+		}
+		signing = &signer{cosign: cosign}
+	} else if cosign != "" {
+		return nil, fmt.Errorf("--cosign signs the provenance of --sign and requires --sign")
+	}
+
+	if stdout && events {
+		return nil, fmt.Errorf("--events writes files and cannot be combined with --stdout")
+	}
+
+	if stdout && (createHelm || createKustomize || crossplane != "") {
+		return nil, fmt.Errorf("--stdout only streams raw manifests and cannot be combined with --create-helm, --create-kustomize or --crossplane")
+	}
+
+	if !isValidCrossplaneMode(crossplane) {
+		return nil, fmt.Errorf("invalid --crossplane %q (must be one of: %s)", crossplane, strings.Join(crossplaneModes, ", "))
+	}
+
+	if !isValidAdmissionController(admissionPolicies) {
+		return nil, fmt.Errorf("invalid --admission-policies %q (must be one of: %s)", admissionPolicies, strings.Join(admissionControllers, ", "))
+	}
+
+	if allClusters && cluster != "" {
+		return nil, fmt.Errorf("--cluster and --all-clusters are mutually exclusive")
+	}
 
-	err := rootCmd.MarkFlagRequired("region")
+	if !isValidImagePullPolicy(imagePullPolicy) {
+		return nil, fmt.Errorf("invalid --image-pull-policy %q (must be Always, IfNotPresent or Never)", imagePullPolicy)
+	}
+
+	if !isValidAntiAffinity(antiAffinity) {
+		return nil, fmt.Errorf("invalid --anti-affinity %q (must be soft or hard)", antiAffinity)
+	}
+
+	conversionConfig, err := loadConversionConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if antiAffinity != "" {
+		conversionConfig.Defaults.AntiAffinity = antiAffinity
+	}
+
+	metadata, err := parseObjectMetadata(labels, annotations)
 	if err != nil {
-		log.Fatalf("Failed to mark flag as required: %v", err)
+		return nil, err
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+	apiVersions, err := parseAPIVersionOverrides(apiVersionFlags)
+	if err != nil {
+		return nil, err
 	}
+
+	kinds, err := parseKindFilter(includeKinds, excludeKinds)
+	if err != nil {
+		return nil, err
+	}
+
+	valuesOverrides, err := parseValuesOverrides(valuesOverrideFlags)
+	if err != nil {
+		return nil, err
+	}
+	if len(valuesOverrides) > 0 && !createHelm {
+		return nil, fmt.Errorf("--values-override sets values of the Helm chart and requires --create-helm")
+	}
+	if helmTests && !createHelm {
+		return nil, fmt.Errorf("--helm-tests writes unit tests of the Helm chart and requires --create-helm")
+	}
+	if helmByFamily && !createHelm {
+		return nil, fmt.Errorf("--helm-group-by-family arranges the Helm values and requires --create-helm")
+	}
+
+	policies, err := newPolicyEngine(policyPaths, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	linter, err := newLinter(lint, lintSeverities)
+	if err != nil {
+		return nil, err
+	}
+
+	if !flags.Changed("cutover-weight") {
+		cutoverWeight = cutoverDisabled
+	} else if !isValidCutoverWeight(cutoverWeight) {
+		return nil, fmt.Errorf("invalid --cutover-weight %d (must be 0-100)", cutoverWeight)
+	} else if stdout {
+		return nil, fmt.Errorf("--cutover-weight writes scripts and cannot be combined with --stdout")
+	} else if targetGroupBindings {
+		return nil, fmt.Errorf("--cutover-weight and --target-group-bindings are mutually exclusive")
+	}
+
+	if !isValidProbeSource(probeSource) {
+		return nil, fmt.Errorf("invalid --probe-source %q (must be one of: %s)", probeSource, strings.Join(probeSources, ", "))
+	}
+	if !isValidRollouts(rollouts) {
+		return nil, fmt.Errorf("invalid --rollouts %q (must be one of: %s)", rollouts, strings.Join(rolloutsProviders, ", "))
+	}
+	if !isValidZeroTrust(zeroTrust) {
+		return nil, fmt.Errorf("invalid --zero-trust %q (must be one of: %s)", zeroTrust, strings.Join(zeroTrustModes, ", "))
+	}
+	if !isValidAWSBatchMode(awsBatch) {
+		return nil, fmt.Errorf("invalid --aws-batch %q (must be one of: %s)", awsBatch, strings.Join(awsBatchModes, ", "))
+	}
+
+	if !isValidSecretsMode(secretsMode) {
+		return nil, fmt.Errorf("invalid --secrets-mode %q (must be one of: %s)", secretsMode, strings.Join(secretsModes, ", "))
+	}
+
+	var secrets secretStore
+	if resolveSecrets {
+		secrets = kubernetesSecretStore{}
+	}
+	if secretsMode == secretsModeVault {
+		if !isValidVaultInjection(vaultInjection) {
+			return nil, fmt.Errorf("invalid --vault-injection %q (must be one of: %s)", vaultInjection, strings.Join(vaultInjections, ", "))
+		}
+		if vaultAddr == "" {
+			vaultAddr = os.Getenv("VAULT_ADDR")
+		}
+		vaultToken := os.Getenv("VAULT_TOKEN")
+		if vaultAddr == "" || vaultToken == "" {
+			return nil, fmt.Errorf("--secrets-mode=vault requires --vault-addr (or VAULT_ADDR) and VAULT_TOKEN")
+		}
+		secrets = newVaultSecretStore(vaultAddr, vaultToken, vaultMount, vaultPath, vaultRole, vaultInjection)
+	}
+
+	if splitContainers && rightsize {
+		return nil, fmt.Errorf("--rightsize measures whole ECS tasks and cannot be combined with --split-containers")
+	}
+
+	if rightsize {
+		if err := isValidRightsizeWindow(rightsizeDays, rightsizePercentile); err != nil {
+			return nil, err
+		}
+	}
+
+	iac := iacInputs{cfnTemplate: fromCFNTemplate, terraformState: fromTerraformState, cdkOut: fromCDKOut, exportedJSON: fromJSON}
+	if err := iac.validate(); err != nil {
+		return nil, err
+	}
+	if offline && !iac.enabled() {
+		return nil, fmt.Errorf("--offline never calls AWS and requires --from-json, --from-cfn-template, --from-terraform-state or --from-cdk-out")
+	}
+	// IaC inputs default the region to the one of their ARNs
+	if region == "" && !iac.enabled() {
+		return nil, fmt.Errorf("region flag is required")
+	}
+	if region != "" {
+		if err := validateRegion(region); err != nil {
+			return nil, err
+		}
+		if err := awsEndpoints.validate(region); err != nil {
+			return nil, err
+		}
+	}
+	if iac.enabled() {
+		// IaC inputs are converted offline
+		liveOnly := []struct {
+			flag string
+			set  bool
+		}{
+			{"--resolve-secrets", secrets != nil},
+			{"--rightsize", rightsize},
+			{"--convert-alarms", convertAlarms},
+			{"--create-keda", createKEDA},
+			{"--target-group-bindings", targetGroupBindings},
+			{"--cutover-weight", cutoverWeight != cutoverDisabled},
+			{"--decommission-plan", decommissionPlan},
+			{"--step-functions", stepFunctions},
+			{"--zero-trust", zeroTrust != ""},
+		}
+		for _, f := range liveOnly {
+			if f.set {
+				return nil, fmt.Errorf("%s reads live AWS resources and cannot be combined with --from-cfn-template, --from-terraform-state, --from-cdk-out or --from-json", f.flag)
+			}
+		}
+	}
+
+	var fleet *FleetConfig
+	if fleetPath != "" {
+		switch {
+		case cluster != "" || allClusters:
+			return nil, fmt.Errorf("--fleet lists the clusters of each account and cannot be combined with --cluster or --all-clusters")
+		case stdout:
+			return nil, fmt.Errorf("--fleet writes a directory per account and cannot be combined with --stdout")
+		case iac.enabled():
+			return nil, fmt.Errorf("--fleet reads live AWS accounts and cannot be combined with --from-cfn-template, --from-terraform-state, --from-cdk-out or --from-json")
+		}
+		if fleet, err = loadFleetConfig(fleetPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var anon *anonymizer
+	if anonymize {
+		if anon, err = newAnonymizer(); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := lookupProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil && !profile.EKS {
+		eksOnly := []struct {
+			flag string
+			set  bool
+		}{
+			{"--create-karpenter", createKarpenter},
+			{"--target-group-bindings", targetGroupBindings},
+			{"--cutover-weight", cutoverWeight != cutoverDisabled},
+		}
+		for _, f := range eksOnly {
+			if f.set {
+				return nil, fmt.Errorf("%s requires EKS and cannot be combined with --profile=%s", f.flag, profile.Name)
+			}
+		}
+	}
+
+	identities, err := loadIdentityMap(identityMapPath)
+	if err != nil {
+		return nil, err
+	}
+	if identities != nil && (profile == nil || profile.IdentityAnnotation == irsaAnnotation) {
+		return nil, fmt.Errorf("--identity-map requires --profile=%s or --profile=%s", profileGKE, profileAKS)
+	}
+
+	floor, err := parseResourceFloor(minCPU, minMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValidOutputWorkload(output) {
+		return nil, fmt.Errorf("invalid --output %q (must be one of: %s)", output, strings.Join(outputWorkloads, ", "))
+	}
+	if output == outputKnative {
+		deploymentOnly := []struct {
+			flag string
+			set  bool
+		}{
+			{"--create-helm", createHelm},
+			{"--create-kustomize", createKustomize},
+			{"--create-keda", createKEDA},
+			{"--rollouts", rollouts != ""},
+			{"--target-group-bindings", targetGroupBindings},
+			{"--cutover-weight", cutoverWeight != cutoverDisabled},
+			{"--smart-templates", smartTemplates},
+			{"--headless-workers", headlessWorkers},
+		}
+		for _, f := range deploymentOnly {
+			if f.set {
+				return nil, fmt.Errorf("%s generates Deployment or Service resources and cannot be combined with --output=knative", f.flag)
+			}
+		}
+	}
+
+	if !isValidOutputNaming(outputNaming) {
+		return nil, fmt.Errorf("invalid --output-naming %q (must be one of: %s)", outputNaming, strings.Join(outputNamingModes, ", "))
+	}
+	if promptStyle == "" {
+		promptStyle = defaultPromptStyle()
+	}
+	if !isValidPromptStyle(promptStyle) {
+		return nil, fmt.Errorf("invalid --prompt-style %q (must be one of: %s)", promptStyle, strings.Join(promptStyles, ", "))
+	}
+
+	recorded, err := recordFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runOptions{
+		region:              region,
+		cluster:             cluster,
+		showARNs:            showARNs,
+		promptStyle:         promptStyle,
+		outputNaming:        outputNaming,
+		overridesDir:        overridesDir,
+		createHelm:          createHelm,
+		createKustomize:     createKustomize,
+		stdout:              stdout,
+		createKEDA:          createKEDA,
+		rollouts:            rollouts,
+		zeroTrust:           zeroTrust,
+		createKarpenter:     createKarpenter,
+		cutoverWeight:       cutoverWeight,
+		targetGroupBindings: targetGroupBindings,
+		iac:                 iac,
+		offline:             offline,
+		decommissionPlan:    decommissionPlan,
+		stepFunctions:       stepFunctions,
+		awsBatch:            awsBatch,
+		events:              events,
+		grafanaDashboard:    grafana,
+		alerts:              alerts,
+		convertAlarms:       convertAlarms,
+		smartTemplates:      smartTemplates,
+		headlessWorkers:     headlessWorkers,
+		conversionRecords:   conversionRecords,
+		certManagerIssuer:   certManagerIssuer,
+		bundle:              bundle,
+		signing:             signing,
+		crossplane:          crossplane,
+		crossplaneProvider:  crossplaneProviderConfig,
+		admissionPolicies:   admissionPolicies,
+		forceUnlock:         forceUnlock,
+		continueOnError:     continueOnError,
+		fleet:               fleet,
+		anonymizer:          anon,
+		splitContainers:     splitContainers,
+		saveSource:          saveSource,
+		profile:             profile,
+		identities:          identities,
+		output:              output,
+		resourceFloor:       floor,
+		policies:            policies,
+		linter:              linter,
+		allClusters:         allClusters,
+		assumeYes:           assumeYes,
+		config:              conversionConfig,
+		metadata:            metadata,
+		apiVersions:         apiVersions,
+		kinds:               kinds,
+		valuesOverrides:     valuesOverrides,
+		helmTests:           helmTests,
+		helmByFamily:        helmByFamily,
+		imagePullPolicy:     imagePullPolicy,
+		awsEnv:              awsEnv,
+		probeSource:         probeSource,
+		secrets:             secrets,
+		rightsize:           rightsize,
+		rightsizeDays:       rightsizeDays,
+		rightsizePercentile: rightsizePercentile,
+		flags:               recorded,
+	}, nil
 }
 
 // validateRegion checks if the provided region is a valid AWS region using validators package
@@ -120,19 +681,127 @@ func createOutputDirectory(outputDir string) error {
 	return nil
 }
 
-func runEcs2K8s(region string, createHelm bool, createKustomize bool) error {
+// runOptions holds the settings of a conversion run
+type runOptions struct {
+	region              string
+	cluster             string
+	showARNs            bool
+	promptStyle         string
+	outputNaming        string
+	overridesDir        string
+	createHelm          bool
+	createKustomize     bool
+	stdout              bool
+	createKEDA          bool
+	rollouts            string
+	zeroTrust           string
+	createKarpenter     bool
+	cutoverWeight       int
+	targetGroupBindings bool
+	iac                 iacInputs
+	decommissionPlan    bool
+	stepFunctions       bool
+	awsBatch            string
+	crossplane          string
+	crossplaneProvider  string
+	forceUnlock         bool
+	// continueOnError runs the remaining output stages after one fails (--continue-on-error)
+	continueOnError bool
+	// offline refuses every call to AWS (--offline)
+	offline bool
+	// fleet converts the accounts of a --fleet file instead of the current one
+	fleet *FleetConfig
+	// anonymizer removes identifying values from the output (--anonymize)
+	anonymizer *anonymizer
+	// splitContainers converts each non-sidecar container into its own Deployment
+	splitContainers bool
+	// saveSource writes the source task definition next to the manifests
+	saveSource bool
+	// events writes the conversion timeline of each cluster (--events)
+	events bool
+	// grafanaDashboard writes a starter Grafana dashboard of each cluster (--grafana-dashboard)
+	grafanaDashboard bool
+	// alerts adds a PrometheusRule to each service (--alerts)
+	alerts bool
+	// convertAlarms converts the CloudWatch alarms of each service (--convert-alarms)
+	convertAlarms bool
+	// smartTemplates converts well-known images with opinionated templates (--smart-templates)
+	smartTemplates bool
+	// headlessWorkers generates a headless Service for each worker (--headless-workers)
+	headlessWorkers bool
+	// conversionRecords adds a ConversionRecord custom resource to each service (--conversion-records)
+	conversionRecords bool
+	// certManagerIssuer is the ClusterIssuer of the Certificates of TLS files (--cert-manager-issuer)
+	certManagerIssuer string
+	// bundle packages each cluster's output into a tar.gz with an index (--bundle)
+	bundle bool
+	// signing writes the checksums and provenance of each cluster's output (--sign)
+	signing *signer
+	// profile adjusts the output to the target platform (--profile)
+	profile *platformProfile
+	// identities replace the ECS IAM roles on GKE and AKS (--identity-map)
+	identities identityMap
+	// output is the workload generated per service (--output)
+	output string
+	// resourceFloor is the minimum CPU and memory of every container (--min-cpu, --min-memory)
+	resourceFloor *resourceFloor
+	// policies are the organization guardrails evaluated against the output (--policy, --strict)
+	policies *policyEngine
+	// linter checks the output against best practices (--lint, --lint-severity)
+	linter *linter
+	// admissionPolicies is the admission controller policy stubs are created for (--admission-policies)
+	admissionPolicies string
+	// outputRoot is the directory cluster output directories are created in (default: cwd)
+	outputRoot          string
+	allClusters         bool
+	assumeYes           bool
+	config              *ConversionConfig
+	metadata            *ObjectMetadata
+	apiVersions         apiVersionOverrides
+	kinds               *kindFilter
+	valuesOverrides     valuesOverrides
+	helmTests           bool
+	helmByFamily        bool
+	imagePullPolicy     string
+	awsEnv              bool
+	probeSource         string
+	secrets             secretStore
+	rightsize           bool
+	rightsizeDays       int
+	rightsizePercentile float64
+	// flags are the flags shaping the manifests of each service, recorded in
+	// the conversion state for watch
+	flags []string
+}
+
+func runEcs2K8s(opts *runOptions) error {
 	ctx := context.Background()
+	region := opts.region
+	createHelm := opts.createHelm
+	createKustomize := opts.createKustomize
 
-	log.Printf("Loading AWS configuration for region: %s", region)
 	log.Printf("Create Helm chart: %v", createHelm)
 	log.Printf("Create Kustomize structure: %v", createKustomize)
 
+	if opts.iac.enabled() {
+		return convertIaC(ctx, opts)
+	}
+	// Every path below calls AWS
+	if opts.offline {
+		return fmt.Errorf("--offline refuses to call AWS: convert exported data with --from-json or an IaC input")
+	}
+
 	// Load AWS config
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	log.Printf("Loading AWS configuration for region: %s", region)
+	cfg, err := loadAWSConfig(ctx, region)
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
+	if opts.fleet != nil {
+		return convertFleet(ctx, cfg, opts.fleet, opts)
+	}
+
 	// Create ECS client
 	ecsClient := ecs.NewFromConfig(cfg)
 
@@ -144,139 +813,592 @@ func runEcs2K8s(region string, createHelm bool, createKustomize bool) error {
 
 	// 1. Discover ECS clusters
 	log.Printf("Discovering ECS clusters in region %s...", region)
-	clusters, err := listClusters(ctx, ecsClient)
+	clusterArns, err := listClusters(ctx, ecsClient)
 	if err != nil {
 		return fmt.Errorf("failed to list clusters: %w", err)
 	}
 
-	log.Printf("Found %d cluster(s)", len(clusters))
+	log.Printf("Found %d cluster(s)", len(clusterArns))
 
-	// 2. Interactive cluster selection
-	selectedCluster, err := selectCluster(clusters)
+	// 2. Cluster selection (by name or ARN, interactive if not provided)
+	selected, err := chooseClusters(clusterArns, opts)
 	if err != nil {
 		return fmt.Errorf("cluster selection failed: %w", err)
 	}
 
-	log.Printf("Selected cluster: %s", selectedCluster)
+	var failed []string
+	partial := true
+	for _, clusterArn := range selected {
+		if err := convertCluster(ctx, cfg, ecsClient, clusterArn, opts); err != nil {
+			if len(selected) == 1 {
+				return err
+			}
+			log.Printf("Error: Cluster %s: %v", extractClusterName(clusterArn), err)
+			failed = append(failed, extractClusterName(clusterArn))
+			partial = partial && isPartialSuccess(err)
+		}
+	}
+	if len(failed) > 0 {
+		return clusterFailures(failed, partial, len(selected))
+	}
+	return nil
+}
+
+// convertIaC converts the clusters declared in the --from-* input
+func convertIaC(ctx context.Context, opts *runOptions) error {
+	clusters, err := opts.iac.load()
+	if err != nil {
+		return err
+	}
+	selected, err := selectIaCClusters(clusters, opts.cluster)
+	if err != nil {
+		return err
+	}
+	if opts.region == "" {
+		if opts.region = iacRegion(selected); opts.region != "" {
+			log.Printf("Info: Using region %s of the ARNs in %s", opts.region, selected[0].Source)
+		} else {
+			log.Printf("Warning: No --region given and no ARN in the input names one, AWS_REGION is not set on the containers")
+		}
+	}
+
+	var failed []string
+	partial := true
+	for _, cluster := range selected {
+		log.Printf("Converting cluster %s declared in %s (%d service(s))", cluster.Name, cluster.Source, len(cluster.Services))
+		if err := convertServices(ctx, nil, cluster.Name, nil, cluster.Services, cluster.taskDefInfo, opts); err != nil {
+			if len(selected) == 1 {
+				return err
+			}
+			log.Printf("Error: Cluster %s: %v", cluster.Name, err)
+			failed = append(failed, cluster.Name)
+			partial = partial && isPartialSuccess(err)
+		}
+	}
+	if len(failed) > 0 {
+		return clusterFailures(failed, partial, len(selected))
+	}
+	return nil
+}
+
+// chooseClusters determines the clusters to convert from --cluster,
+// --all-clusters or the interactive prompt. It never prompts with --assume-yes
+// or without a terminal, failing instead when the choice is ambiguous.
+func chooseClusters(clusterArns []string, opts *runOptions) ([]string, error) {
+	switch {
+	case opts.allClusters:
+		return clusterArns, nil
+	case opts.cluster != "":
+		clusterArn, err := resolveClusterRef(opts.cluster, clusterArns)
+		if err != nil {
+			return nil, err
+		}
+		return []string{clusterArn}, nil
+	case opts.assumeYes && len(clusterArns) == 1:
+		log.Printf("Info: Using the only cluster in the region: %s", clusterArns[0])
+		return clusterArns, nil
+	case opts.assumeYes:
+		return nil, fmt.Errorf("found %d clusters and --assume-yes disables the prompt: pass --cluster or --all-clusters", len(clusterArns))
+	case !stdinIsTerminal():
+		return nil, fmt.Errorf("stdin is not a terminal: pass --cluster or --all-clusters to run non-interactively")
+	}
+
+	clusterArn, err := selectCluster(clusterArns, opts.showARNs, newSelector(opts.promptStyle))
+	if err != nil {
+		return nil, err
+	}
+	return []string{clusterArn}, nil
+}
+
+// taskDefInfoFetcher converts the task definition a cluster's services run
+type taskDefInfoFetcher func(taskDefArn string, services []types.Service) (*TaskDefInfo, error)
+
+// convertCluster converts the services of one ECS cluster into its output directory
+func convertCluster(ctx context.Context, cfg aws.Config, ecsClient *ecs.Client, clusterArn string, opts *runOptions) error {
+	selectedCluster := extractClusterName(clusterArn)
+	log.Printf("Selected cluster: %s (%s)", selectedCluster, clusterArn)
 
 	// 2a. Validate selected cluster
-	if err := validateSelectedCluster(ctx, selectedCluster, ecsClient); err != nil {
+	if err := validateSelectedCluster(ctx, clusterArn, ecsClient); err != nil {
 		return fmt.Errorf("cluster validation failed: %w", err)
 	}
 
+	log.Printf("Retrieving task definitions from cluster %s...", selectedCluster)
+	services, err := describeClusterServices(ctx, ecsClient, clusterArn)
+	if err != nil {
+		return fmt.Errorf("failed to list task definitions: %w", err)
+	}
+
+	settings := readClusterSettings(ctx, ecsClient, clusterArn)
+	settings.applyDefaultStrategy(services)
+
+	fetch := func(taskDefArn string, services []types.Service) (*TaskDefInfo, error) {
+		return fetchTaskDefInfo(ctx, ecsClient, taskDefArn, services)
+	}
+	return convertServices(ctx, &cfg, clusterArn, settings, services, fetch, opts)
+}
+
+// convertServices converts the task definitions run by a cluster's services
+// into its output directory. cfg and settings are nil for clusters read from
+// IaC inputs, which are converted without calling AWS.
+func convertServices(ctx context.Context, cfg *aws.Config, clusterArn string, settings *ClusterSettings, services []types.Service, fetch taskDefInfoFetcher, opts *runOptions) (retErr error) {
+	region := opts.region
+	createHelm := opts.createHelm
+	createKustomize := opts.createKustomize
+	selectedCluster := extractClusterName(clusterArn)
+
 	// 3. Create output directory
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get current working directory: %w", err)
 	}
 
-	outputDir := filepath.Join(cwd, selectedCluster)
-	log.Printf("Output directory: %s", outputDir)
+	outputRoot := opts.outputRoot
+	if outputRoot == "" {
+		outputRoot = cwd
+	}
+	outputName := clusterOutputName(clusterArn, opts.outputNaming)
+	if opts.anonymizer != nil {
+		outputName = opts.anonymizer.text(outputName)
+	}
+	outputDir := filepath.Join(outputRoot, outputName)
+	// Nothing is written to disk when streaming to stdout
+	var runLog *runLogger
+	if !opts.stdout {
+		log.Printf("Output directory: %s", outputDir)
+		if err := createOutputDirectory(outputDir); err != nil {
+			return err
+		}
+		release, err := acquireWorkspaceLock(outputDir, opts.forceUnlock)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		if opts.bundle {
+			// Deferred before the anonymizer so it packages the anonymized output
+			defer func() {
+				path, err := writeBundle(outputDir, selectedCluster, time.Now())
+				if err != nil {
+					log.Printf("Error: %v", err)
+					if retErr == nil {
+						retErr = err
+					}
+					return
+				}
+				log.Printf("✓ Bundled the output into %s", path)
+			}()
+		}
 
-	if err := createOutputDirectory(outputDir); err != nil {
-		return err
+		if opts.signing != nil {
+			// Runs after the anonymizer and before the bundle, which then carries the signatures
+			started := time.Now()
+			defer func() {
+				if retErr != nil {
+					return
+				}
+				sourceArn := clusterArn
+				if opts.anonymizer != nil {
+					sourceArn = opts.anonymizer.text(clusterArn)
+				}
+				if err := opts.signing.sign(ctx, outputDir, sourceArn, region, started, time.Now()); err != nil {
+					log.Printf("Error: %v", err)
+					retErr = err
+					return
+				}
+				log.Printf("✓ Wrote %s and %s", checksumsFileName, provenanceFileName)
+			}()
+		}
+
+		if opts.anonymizer != nil {
+			// Runs before the lock is released, also when the conversion fails midway
+			defer func() {
+				if err := opts.anonymizer.outputDir(outputDir); err != nil && retErr == nil {
+					retErr = err
+				}
+			}()
+		}
+
+		// Deferred last so the anonymizer and bundle include the finished log
+		runLog = startRunLog(selectedCluster, region)
+		defer func() {
+			if err := runLog.finish(outputDir); err != nil {
+				log.Printf("Error: %v", err)
+				if retErr == nil {
+					retErr = err
+				}
+			}
+		}()
 	}
 
-	// 4. Process task definitions
-	log.Printf("Retrieving task definitions from cluster %s...", selectedCluster)
-	taskDefs, err := listTaskDefinitions(ctx, ecsClient, selectedCluster)
+	overrides, err := loadOutputOverrides(outputDir, opts.overridesDir)
 	if err != nil {
-		return fmt.Errorf("failed to list task definitions: %w", err)
+		return err
 	}
 
+	// 4. Process task definitions
+	taskDefs := serviceTaskDefinitions(selectedCluster, services)
+	servicesByTaskDef := servicesByTaskDefinition(services)
+
 	if len(taskDefs) == 0 {
 		log.Printf("No task definitions found in cluster %s. Nothing to convert.", selectedCluster)
+		if isAWSBatchCluster(selectedCluster) {
+			log.Printf("Info: Cluster %s belongs to an AWS Batch compute environment; its jobs are defined by AWS Batch job definitions, not ECS services", selectedCluster)
+		}
 		return nil
 	}
 
 	log.Printf("Found %d task definition(s) to convert", len(taskDefs))
 
+	pipeline := newServicePipeline(cfg, selectedCluster, overrides, opts)
+
+	var events *eventRecorder
+	if opts.events && !opts.stdout {
+		events = newEventRecorder(selectedCluster, region)
+	}
+
 	successCount := 0
 	failureCount := 0
+	policyFailures := 0
+	lintErrors := 0
 	var taskDefInfos []*TaskDefInfo
-
+	model := &ConversionModel{Cluster: selectedCluster}
+	streamDocs := map[string]interface{}{}
+	taskDefNames := nameClaims{}
+
+	var converted []*TaskDefInfo
+	// split records the sidecars of the task definitions split by --split-containers
+	var split map[string][]string
+	if opts.splitContainers {
+		split = map[string][]string{}
+	}
+	var batchTaskDefs []*AWSBatchTaskDefinition
+	batchDocs := map[string]interface{}{}
 	for _, taskDefArn := range taskDefs {
-		if taskDefArn == "" {
-			log.Printf("Warning: Empty task definition ARN encountered, skipping")
+		taskDefInfo, err := fetch(taskDefArn, servicesByTaskDef[taskDefArn])
+		if err != nil {
+			log.Printf("Error: %v", err)
+			events.failed(nil, taskDefArn, err)
 			failureCount++
 			continue
 		}
-
-		// Validate task definition ARN before fetching
-		if err := validateTaskDefArn(ctx, taskDefArn, ecsClient); err != nil {
-			log.Printf("Warning: Task definition validation failed for %s: %v (attempting to continue)", taskDefArn, err)
-		}
-
-		taskDef, err := getTaskDefinition(ctx, ecsClient, taskDefArn)
-		if err != nil {
-			log.Printf("Error: Failed to get task definition %s: %v", taskDefArn, err)
-			failureCount++
+		// AWS Batch runs its task definitions as jobs, never as services
+		if reason := awsBatchReason(taskDefInfo, selectedCluster); reason != "" {
+			entry, doc := convertAWSBatch(taskDefInfo, reason, opts.awsBatch)
+			if doc != nil {
+				batchDocs[entry.File] = doc
+			}
+			batchTaskDefs = append(batchTaskDefs, entry)
 			continue
 		}
+		resolveCanaryImages(taskDefInfo, fetch)
+
+		fetched := []*TaskDefInfo{taskDefInfo}
+		if opts.splitContainers {
+			sidecars := opts.config.forService(taskDefInfo.Name).Sidecars
+			split[taskDefInfo.Name] = append([]string{}, sidecars...)
+			if fetched, err = splitContainers(taskDefInfo, sidecars); err != nil {
+				log.Printf("Error: %v", err)
+				events.failed(taskDefInfo, taskDefArn, err)
+				failureCount++
+				continue
+			}
+		}
+		for _, info := range fetched {
+			if _, err := taskDefNames.claim(info.SourceName); err != nil {
+				log.Printf("Error: Skipping task definition %s: %v", taskDefArn, err)
+				events.failed(info, taskDefArn, err)
+				failureCount++
+				continue
+			}
+			events.discovered(info)
+			converted = append(converted, info)
+		}
+	}
 
-		if taskDef == nil {
-			log.Printf("Error: Task definition %s is nil", taskDefArn)
+	if err := pipeline.resolve(ctx, converted); err != nil {
+		return err
+	}
+
+	for _, taskDefInfo := range converted {
+		runLog.setService(taskDefInfo.Name)
+		if err := pipeline.apply(ctx, taskDefInfo); err != nil {
+			log.Printf("Error: %v", err)
+			events.failed(taskDefInfo, "", err)
 			failureCount++
 			continue
 		}
+		if opts.anonymizer != nil {
+			if err := opts.anonymizer.taskDefInfo(taskDefInfo); err != nil {
+				log.Printf("Error: Failed to anonymize %s: %v", taskDefInfo.Name, err)
+				events.failed(taskDefInfo, "", err)
+				failureCount++
+				continue
+			}
+		}
+		applyConfigChecksums(taskDefInfo)
+
+		if opts.policies != nil {
+			violations, err := opts.policies.check(ctx, taskDefInfo)
+			if err != nil {
+				log.Printf("Error: %v", err)
+				events.failed(taskDefInfo, "", err)
+				failureCount++
+				continue
+			}
+			if len(violations) > 0 && opts.policies.strict {
+				for _, v := range violations {
+					log.Printf("Error: Policy violation in %s: %s", taskDefInfo.Name, v)
+				}
+				events.failed(taskDefInfo, "", fmt.Errorf("%d policy violation(s): %s", len(violations), strings.Join(violations, "; ")))
+				failureCount++
+				policyFailures++
+				continue
+			}
+		}
 
-		// Extract task definition name
-		taskDefName := extractTaskDefName(taskDefArn)
-		if taskDefName == "" {
-			log.Printf("Error: Could not extract task definition name from ARN: %s", taskDefArn)
-			failureCount++
-			continue
+		if opts.linter != nil {
+			failures, err := opts.linter.lint(taskDefInfo)
+			if err != nil {
+				log.Printf("Warning: Failed to lint %s: %v", taskDefInfo.Name, err)
+			}
+			for _, finding := range taskDefInfo.Lint {
+				if finding.Severity != lintInfo {
+					log.Printf("Lint %s in %s (%s): %s", finding.Severity, finding.File, finding.Check, finding.Message)
+				}
+			}
+			lintErrors += failures
 		}
 
-		// Convert to TaskDefInfo for Helm support
-		taskDefInfo, err := convertTaskDefToInfo(taskDef, taskDefName)
+		// The documents are rendered once; every output format reads them
+		workload, err := newWorkload(taskDefInfo)
 		if err != nil {
-			log.Printf("Error: Failed to convert task definition %s to info: %v", taskDefName, err)
+			log.Printf("Error: Failed to render manifests for %s: %v", taskDefInfo.Name, err)
+			events.failed(taskDefInfo, "", err)
 			failureCount++
 			continue
 		}
 
-		taskDefInfo.Manifests = K8sManifests{}
-
-		// Generate K8s manifests
-		manifests, err := convertTaskDefToK8s(taskDef)
-		if err != nil {
-			log.Printf("Error: Failed to convert task definition %s: %v", taskDefArn, err)
-			failureCount++
+		if opts.stdout {
+			for filename, doc := range workload.Docs {
+				streamDocs[filename] = doc
+			}
+			successCount++
+			taskDefInfos = append(taskDefInfos, taskDefInfo)
+			model.Workloads = append(model.Workloads, workload)
 			continue
 		}
 
-		taskDefInfo.Manifests = manifests
-
 		// Write manifests to files
-		if err := writeManifests(outputDir, taskDefName, manifests); err != nil {
-			log.Printf("Error: Failed to write manifests for %s: %v", taskDefName, err)
+		if err := writeWorkload(outputDir, workload); err != nil {
+			log.Printf("Error: Failed to write manifests for %s: %v", taskDefInfo.Name, err)
+			events.failed(taskDefInfo, "", err)
 			failureCount++
 		} else {
-			log.Printf("✓ Generated manifests for %s", taskDefName)
+			log.Printf("✓ Generated manifests for %s", taskDefInfo.Name)
+			if taskDefInfo.CutoverScript != "" {
+				if err := writeCutoverScript(outputDir, taskDefInfo.Name, taskDefInfo.CutoverScript); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+			if opts.saveSource {
+				if err := writeSourceTaskDefinition(outputDir, taskDefInfo.Name, taskDefInfo.Source); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}
+			events.converted(taskDefInfo, serviceOutputs(taskDefInfo, opts.saveSource))
+			runLog.decisions(taskDefInfo)
 			successCount++
 			taskDefInfos = append(taskDefInfos, taskDefInfo)
+			model.Workloads = append(model.Workloads, workload)
 		}
 	}
 
-	// 5. Create Helm chart if requested
-	if createHelm && len(taskDefInfos) > 0 {
-		log.Printf("Creating Helm chart for cluster: %s", selectedCluster)
-		if err := CreateHelmChart(selectedCluster, taskDefInfos, outputDir); err != nil {
-			log.Printf("Error: Failed to create Helm chart: %v", err)
-			return err
+	runLog.setService("")
+
+	clusterOwner := &ObjectOwner{Cluster: selectedCluster}
+	var infraDocs map[string]interface{}
+	if opts.createKarpenter && len(taskDefInfos) > 0 {
+		infraDocs = karpenterManifests(selectedCluster, taskDefInfos)
+		opts.kinds.filter(infraDocs)
+		for _, doc := range infraDocs {
+			opts.metadata.apply(doc)
+			clusterOwner.apply(doc)
+			opts.apiVersions.apply(doc)
 		}
 	}
 
-	// 6. Create Kustomize structure if requested
-	if createKustomize && len(taskDefInfos) > 0 {
-		log.Printf("Creating Kustomize structure for cluster: %s", selectedCluster)
-		if err := CreateKustomizeChart(selectedCluster, taskDefInfos, outputDir); err != nil {
-			log.Printf("Error: Failed to create Kustomize structure: %v", err)
+	var admissionDocs map[string]interface{}
+	if opts.admissionPolicies != "" && len(taskDefInfos) > 0 {
+		admissionDocs = admissionManifests(opts.admissionPolicies, selectedCluster, taskDefInfos)
+		opts.kinds.filter(admissionDocs)
+		for _, doc := range admissionDocs {
+			opts.metadata.apply(doc)
+			clusterOwner.apply(doc)
+			opts.apiVersions.apply(doc)
+		}
+	}
+
+	opts.kinds.filter(batchDocs)
+	for _, doc := range batchDocs {
+		opts.metadata.apply(doc)
+		clusterOwner.apply(doc)
+		opts.apiVersions.apply(doc)
+	}
+
+	stages := &stageRunner{continueOnError: opts.continueOnError}
+
+	if opts.stdout {
+		for filename, doc := range infraDocs {
+			streamDocs[infraDirName+"/"+filename] = doc
+		}
+		for filename, doc := range admissionDocs {
+			streamDocs[admissionDirName+"/"+filename] = doc
+		}
+		for filename, doc := range batchDocs {
+			streamDocs[jobsDirName+"/"+filename] = doc
+		}
+		if err := writeManifestStream(streamDocs, successCount+len(batchDocs), failureCount, opts.anonymizer); err != nil {
 			return err
 		}
+		if policyFailures > 0 {
+			return fmt.Errorf("%d task definition(s) violate the --policy deny rules", policyFailures)
+		}
+		if lintErrors > 0 {
+			return fmt.Errorf("--lint found %d error(s) in the generated objects", lintErrors)
+		}
+		if successCount == 0 {
+			return nil
+		}
+		return stages.result(successCount, failureCount)
+	}
+
+	// The manifests of each service are written; every later stage is
+	// isolated so a failure keeps the output of the earlier ones
+	if len(infraDocs) > 0 {
+		stages.run("Karpenter manifests", func() error {
+			if err := writeInfraManifests(outputDir, infraDocs); err != nil {
+				return err
+			}
+			log.Printf("✓ Generated Karpenter NodePool and EC2NodeClass")
+			return nil
+		})
+	}
+
+	if len(admissionDocs) > 0 {
+		stages.run("admission policies", func() error {
+			if err := writeAdmissionManifests(outputDir, admissionDocs); err != nil {
+				return err
+			}
+			log.Printf("✓ Generated %d %s admission policy stub(s)", len(admissionDocs), opts.admissionPolicies)
+			return nil
+		})
+	}
+
+	if len(batchDocs) > 0 {
+		stages.run("AWS Batch jobs", func() error {
+			if err := writeJobManifests(outputDir, batchDocs); err != nil {
+				return err
+			}
+			log.Printf("✓ Converted %d task definition(s) managed by AWS Batch (--aws-batch %s)", len(batchDocs), opts.awsBatch)
+			return nil
+		})
+	}
+
+	// Record what this run was generated from for drift detection
+	stages.run("conversion state", func() error {
+		state, err := newConversionState(region, selectedCluster, taskDefInfos)
+		if err != nil {
+			return fmt.Errorf("failed to build conversion state: %w", err)
+		}
+		if strings.HasPrefix(clusterArn, "arn:") {
+			state.ClusterARN = clusterArn
+		}
+		state.Flags = opts.flags
+		state.Split = split
+		return writeConversionState(outputDir, state)
+	})
+
+	stages.run("conversion report", func() error {
+		return writeConversionReport(outputDir, selectedCluster, region, settings, taskDefInfos, batchTaskDefs)
+	})
+
+	stages.run("conversion events", func() error {
+		return events.write(outputDir)
+	})
+
+	if opts.grafanaDashboard && len(taskDefInfos) > 0 {
+		stages.run("Grafana dashboard", func() error {
+			if err := writeGrafanaDashboard(outputDir, selectedCluster, taskDefInfos); err != nil {
+				return err
+			}
+			log.Printf("✓ Wrote Grafana dashboard %s", grafanaDashboardFileName)
+			return nil
+		})
 	}
 
+	if opts.decommissionPlan && len(taskDefInfos) > 0 {
+		stages.run("decommission plan", func() error {
+			log.Printf("Collecting source resources for the decommission plan...")
+			plan := newDecommissionPlanner(*cfg, selectedCluster).plan(ctx, region, taskDefInfos)
+			if err := writeDecommissionPlan(outputDir, plan); err != nil {
+				return err
+			}
+			log.Printf("✓ Wrote decommission plan with %d resource(s)", len(plan.Resources))
+			return nil
+		})
+	}
+
+	if opts.stepFunctions {
+		stages.run("Step Functions jobs", func() error {
+			log.Printf("Discovering Step Functions state machines running tasks on %s...", selectedCluster)
+			tasks, docs, err := newStepFunctionsDiscoverer(*cfg).discover(ctx, selectedCluster)
+			if err != nil {
+				return err
+			}
+			opts.kinds.filter(docs)
+			for _, doc := range docs {
+				opts.metadata.apply(doc)
+				clusterOwner.apply(doc)
+				opts.apiVersions.apply(doc)
+			}
+			mapping := &StepFunctionsMapping{Cluster: selectedCluster, Region: region, Tasks: tasks}
+			if err := writeStepFunctionsJobs(outputDir, mapping, docs); err != nil {
+				return err
+			}
+			log.Printf("✓ Found %d runTask state(s) in Step Functions, wrote %d Job(s)", len(tasks), len(docs))
+			return nil
+		})
+	}
+
+	// 5. Export the Helm chart, Kustomize structure and Crossplane package
+	// requested. They read the same conversion model and write to their own
+	// directories, so they run concurrently.
+	var exporters []outputStage
+	if createHelm && len(model.Workloads) > 0 {
+		exporters = append(exporters, outputStage{"Helm chart", func() error {
+			log.Printf("Creating Helm chart for cluster: %s", selectedCluster)
+			return createHelmChart(model, outputDir, helmChartOptions{
+				overrides: opts.valuesOverrides,
+				tests:     opts.helmTests,
+				byFamily:  opts.helmByFamily,
+			})
+		}})
+	}
+	if createKustomize && len(model.Workloads) > 0 {
+		exporters = append(exporters, outputStage{"Kustomize structure", func() error {
+			log.Printf("Creating Kustomize structure for cluster: %s", selectedCluster)
+			return createKustomizeStructure(model, outputDir)
+		}})
+	}
+	if opts.crossplane != "" && len(model.Workloads) > 0 {
+		exporters = append(exporters, outputStage{"Crossplane " + opts.crossplane, func() error {
+			log.Printf("Creating Crossplane %s for cluster: %s", opts.crossplane, selectedCluster)
+			return createCrossplanePackage(model, outputDir, opts.crossplane, opts.crossplaneProvider)
+		}})
+	}
+	stages.runParallel(exporters)
+
 	// Summary
 	log.Printf("\n")
 	log.Printf("========================================")
@@ -285,22 +1407,75 @@ func runEcs2K8s(region string, createHelm bool, createKustomize bool) error {
 	log.Printf("Successfully converted: %d task definition(s)", successCount)
 	log.Printf("Failed: %d task definition(s)", failureCount)
 	log.Printf("Output directory: %s", outputDir)
+	log.Printf("Report: %s/%s", filepath.Base(outputDir), reportFileName)
+	for _, taskDefInfo := range taskDefInfos {
+		if len(taskDefInfo.Unmapped) > 0 {
+			log.Printf("Unconverted fields in %s: %s", taskDefInfo.Name, strings.Join(taskDefInfo.Unmapped, ", "))
+		}
+	}
 	if createHelm {
-		log.Printf("Helm chart: %s/helm/%s", selectedCluster, selectedCluster)
+		log.Printf("Helm chart: %s/helm/%s", filepath.Base(outputDir), helmChartName(selectedCluster))
 	}
 	if createKustomize {
-		log.Printf("Kustomize structure: %s/kustomize/%s", selectedCluster, selectedCluster)
+		log.Printf("Kustomize structure: %s/kustomize/%s", filepath.Base(outputDir), selectedCluster)
+	}
+	if opts.crossplane != "" {
+		log.Printf("Crossplane %s: %s/crossplane", opts.crossplane, filepath.Base(outputDir))
+	}
+	for _, f := range stages.failures {
+		log.Printf("Failed stage: %s (%v)", f.Stage, f.Err)
+	}
+	if len(stages.skipped) > 0 {
+		log.Printf("Skipped stages: %s", strings.Join(stages.skipped, ", "))
 	}
 	log.Printf("========================================\n")
 
-	if successCount == 0 {
+	if policyFailures > 0 {
+		return fmt.Errorf("%d task definition(s) violate the --policy deny rules", policyFailures)
+	}
+	if lintErrors > 0 {
+		return fmt.Errorf("--lint found %d error(s) in the generated objects; see %s", lintErrors, reportFileName)
+	}
+	if successCount == 0 && len(batchDocs) == 0 {
 		return fmt.Errorf("no task definitions were successfully converted")
 	}
+	if err := stages.result(successCount, failureCount); err != nil {
+		return err
+	}
 
 	log.Printf("✅ Conversion complete!")
 	return nil
 }
 
+// writeManifestStream writes the rendered documents of a cluster to stdout as a
+// single multi-document YAML stream, anonymized with --anonymize
+func writeManifestStream(docs map[string]interface{}, successCount, failureCount int, anon *anonymizer) error {
+	if anon != nil {
+		// Anonymizing rewrites names across documents, so it needs the whole stream
+		data, err := renderYAMLStream(docs)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write([]byte(anon.text(string(data)))); err != nil {
+			return fmt.Errorf("failed to write manifests to stdout: %w", err)
+		}
+	} else {
+		w := bufio.NewWriter(os.Stdout)
+		if err := writeYAMLStream(w, docs); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to write manifests to stdout: %w", err)
+		}
+	}
+
+	log.Printf("Streamed %d document(s) for %d task definition(s) to stdout (%d failed)", len(docs), successCount, failureCount)
+	if successCount == 0 {
+		return fmt.Errorf("no task definitions were successfully converted")
+	}
+	return nil
+}
+
 // validateSelectedCluster validates the selected cluster using validators package
 func validateSelectedCluster(ctx context.Context, clusterName string, ecsClient *ecs.Client) error {
 	cv := &validators.ClusterValidator{ClusterName: clusterName}
@@ -350,3 +1525,58 @@ func validateTaskDefArn(ctx context.Context, taskDefArn string, ecsClient *ecs.C
 
 	return nil
 }
+
+// fetchTaskDefInfo describes a task definition and converts it into a TaskDefInfo
+// with its generated Kubernetes manifests and the ECS services using it attached
+func fetchTaskDefInfo(ctx context.Context, ecsClient *ecs.Client, taskDefArn string, services []types.Service) (*TaskDefInfo, error) {
+	if taskDefArn == "" {
+		return nil, fmt.Errorf("empty task definition ARN encountered")
+	}
+
+	// Validate task definition ARN before fetching; cached revisions are known to exist
+	validationClient := ecsClient
+	if taskDefinitions.cached(taskDefArn) {
+		validationClient = nil
+	}
+	if err := validateTaskDefArn(ctx, taskDefArn, validationClient); err != nil {
+		log.Printf("Warning: Task definition validation failed for %s: %v (attempting to continue)", taskDefArn, err)
+	}
+
+	taskDef, tags, err := taskDefinitions.get(ctx, ecsClient, taskDefArn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task definition %s: %w", taskDefArn, err)
+	}
+
+	// Extract task definition name
+	taskDefName := extractTaskDefName(taskDefArn)
+	if taskDefName == "" {
+		return nil, fmt.Errorf("could not extract task definition name from ARN: %s", taskDefArn)
+	}
+
+	taskDefInfo, err := newServiceTaskDefInfo(taskDef, taskDefName, services)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		taskDefInfo.Tags = map[string]string{}
+		for _, tag := range tags {
+			taskDefInfo.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return taskDefInfo, nil
+}
+
+// newServiceTaskDefInfo converts a task definition and applies the settings
+// of the ECS services using it
+func newServiceTaskDefInfo(taskDef *types.TaskDefinition, taskDefName string, services []types.Service) (*TaskDefInfo, error) {
+	taskDefInfo, err := buildTaskDefInfo(taskDef, taskDefName)
+	if err != nil {
+		return nil, err
+	}
+
+	taskDefInfo.Services = services
+	taskDefInfo.Canaries = canaryTaskSets(services)
+	applyCircuitBreaker(taskDefInfo)
+	applyCapacityProviderStrategy(taskDefInfo)
+	return taskDefInfo, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestChooseClustersNonInteractive tests cluster selection without prompting
+func TestChooseClustersNonInteractive(t *testing.T) {
+	one := []string{"arn:aws:ecs:us-east-1:123456789012:cluster/prod"}
+	two := append([]string{"arn:aws:ecs:us-east-1:123456789012:cluster/staging"}, one...)
+
+	tests := []struct {
+		name     string
+		clusters []string
+		opts     runOptions
+		want     []string
+		wantErr  bool
+	}{
+		{name: "all clusters", clusters: two, opts: runOptions{allClusters: true, assumeYes: true}, want: two},
+		{name: "cluster by name", clusters: two, opts: runOptions{cluster: "prod", assumeYes: true}, want: one},
+		{name: "single cluster", clusters: one, opts: runOptions{assumeYes: true}, want: one},
+		{name: "ambiguous with assume-yes", clusters: two, opts: runOptions{assumeYes: true}, wantErr: true},
+		{name: "unknown cluster", clusters: two, opts: runOptions{cluster: "dev", assumeYes: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chooseClusters(tt.clusters, &tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("chooseClusters() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("chooseClusters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chooseClusters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteManifestStream tests the --stdout stream: one document per
+// generated file in file name order, each opened by a separator, without the
+// excluded kinds and with the namespace of each object
+func TestWriteManifestStream(t *testing.T) {
+	kinds, err := parseKindFilter(nil, []string{"ConfigMap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]interface{}{}
+	for _, family := range []string{"web", "api"} {
+		info, err := buildTaskDefInfo(appTaskDef(family), family)
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+		}
+		info.Manifests.Kinds = kinds
+		workload, err := newWorkload(info)
+		if err != nil {
+			t.Fatalf("newWorkload(%s) error = %v", family, err)
+		}
+		for filename, doc := range workload.Docs {
+			if family == "api" {
+				setManifestNamespace(doc, "payments")
+			}
+			docs[filename] = doc
+		}
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	streamErr := writeManifestStream(docs, 2, 0, nil)
+	os.Stdout = stdout
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamErr != nil {
+		t.Fatalf("writeManifestStream() error = %v", streamErr)
+	}
+
+	if !strings.HasPrefix(string(data), "---\n") {
+		t.Errorf("stream doesn't open with a separator:\n%s", data)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var got, namespaces []string
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid stream: %v\n%s", err, data)
+		}
+		if doc.Kind == "ConfigMap" {
+			t.Errorf("excluded ConfigMap %s streamed", doc.Metadata.Name)
+		}
+		got = append(got, doc.Kind)
+		namespaces = append(namespaces, doc.Metadata.Namespace)
+	}
+
+	keys := sortedDocKeys(docs)
+	if len(got) != len(keys) || strings.Count(string(data), "---\n") != len(keys) {
+		t.Fatalf("streamed %d document(s) with %d separator(s), want %d: %v", len(got), strings.Count(string(data), "---\n"), len(keys), keys)
+	}
+	for i, key := range keys {
+		if kind := documentKind(docs[key].(map[string]interface{})); got[i] != kind {
+			t.Errorf("document %d = %s, want %s of %s", i, got[i], kind, key)
+		}
+		if strings.HasPrefix(key, "api-") && namespaces[i] != "payments" {
+			t.Errorf("%s namespace = %q, want payments", key, namespaces[i])
+		}
+		if strings.HasPrefix(key, "web-") && namespaces[i] == "payments" {
+			t.Errorf("%s namespace = payments, want its own", key)
+		}
+	}
+	if !strings.HasPrefix(keys[0], "api-") {
+		t.Errorf("documents = %v, want them ordered by file name", keys)
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MetadataRule is a label or annotation added to generated objects, optionally
+// limited to one kind
+type MetadataRule struct {
+	// Kind restricts the rule to objects of this kind (case-insensitive); empty matches all
+	Kind  string
+	Key   string
+	Value string
+}
+
+// ObjectMetadata holds the labels and annotations injected into every generated object
+type ObjectMetadata struct {
+	Labels      []MetadataRule
+	Annotations []MetadataRule
+}
+
+// helmMetadataKinds are the kinds rendered by the Helm chart templates
+var helmMetadataKinds = []string{
+	"Deployment",
+	"StatefulSet",
+	"Service",
+	"ConfigMap",
+	"ServiceAccount",
+	"Role",
+	"RoleBinding",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Job",
+}
+
+// parseObjectMetadata parses repeatable --label and --annotation values of the
+// form [kind:]key=value. It returns nil when neither is set.
+func parseObjectMetadata(labels, annotations []string) (*ObjectMetadata, error) {
+	if len(labels) == 0 && len(annotations) == 0 {
+		return nil, nil
+	}
+
+	metadata := &ObjectMetadata{}
+	for _, flag := range labels {
+		rule, err := parseMetadataRule(flag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --label %q: %w", flag, err)
+		}
+		if safe := safeLabelValue(rule.Value); safe != rule.Value {
+			log.Printf("Warning: --label %s is not a valid label value; labelling %q and keeping the full value in the annotation %s", flag, safe, rule.Key)
+		}
+		metadata.Labels = append(metadata.Labels, rule)
+	}
+	for _, flag := range annotations {
+		rule, err := parseMetadataRule(flag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --annotation %q: %w", flag, err)
+		}
+		metadata.Annotations = append(metadata.Annotations, rule)
+	}
+
+	return metadata, nil
+}
+
+// parseMetadataRule splits [kind:]key=value. Qualified names cannot contain a
+// colon, so the first colon before "=" separates the kind.
+func parseMetadataRule(flag string) (MetadataRule, error) {
+	key, value, ok := strings.Cut(flag, "=")
+	if !ok {
+		return MetadataRule{}, fmt.Errorf("expected [kind:]key=value")
+	}
+
+	var rule MetadataRule
+	if kind, name, scoped := strings.Cut(key, ":"); scoped {
+		if kind == "" {
+			return MetadataRule{}, fmt.Errorf("kind cannot be empty")
+		}
+		rule.Kind = kind
+		key = name
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return MetadataRule{}, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	rule.Key = key
+	rule.Value = value
+	return rule, nil
+}
+
+// apply adds the matching labels and annotations to a serialized manifest
+func (m *ObjectMetadata) apply(doc interface{}) {
+	manifest, ok := doc.(map[string]interface{})
+	if m == nil || !ok {
+		return
+	}
+	metadata, ok := manifest["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	kind, _ := manifest["kind"].(string)
+	if labels := metadataForKind(m.Labels, kind); len(labels) > 0 {
+		metadata["labels"] = mergeMetadataMap(metadata["labels"], labels)
+	}
+	if annotations := metadataForKind(m.Annotations, kind); len(annotations) > 0 {
+		metadata["annotations"] = mergeMetadataMap(metadata["annotations"], annotations)
+	}
+}
+
+// metadataForKind collects the rules matching a kind; later rules win
+func metadataForKind(rules []MetadataRule, kind string) map[string]string {
+	values := map[string]string{}
+	for _, rule := range rules {
+		if rule.Kind == "" || strings.EqualFold(rule.Kind, kind) {
+			values[rule.Key] = rule.Value
+		}
+	}
+	return values
+}
+
+// helmValues returns the labels and annotations per kind rendered by the Helm templates
+func (m *ObjectMetadata) helmValues() map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	values := map[string]interface{}{}
+	for _, kind := range helmMetadataKinds {
+		entry := map[string]interface{}{}
+		annotations := metadataForKind(m.Annotations, kind)
+		if labels := metadataForKind(m.Labels, kind); len(labels) > 0 {
+			var overflow map[string]string
+			entry["labels"], overflow = safeLabels(labels)
+			for k, v := range overflow {
+				annotations[k] = v
+			}
+		}
+		if len(annotations) > 0 {
+			entry["annotations"] = annotations
+		}
+		if len(entry) > 0 {
+			values[kind] = entry
+		}
+	}
+	return values
+}
+
+// mergeMetadataMap merges values into an existing labels or annotations map,
+// which may be typed or generic depending on how the manifest was serialized
+func mergeMetadataMap(existing interface{}, values map[string]string) map[string]interface{} {
+	merged := map[string]interface{}{}
+	switch current := existing.(type) {
+	case map[string]string:
+		for k, v := range current {
+			merged[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range current {
+			merged[k] = v
+		}
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
+// conversionMetadata returns the injected metadata, which is shared by every service
+func conversionMetadata(taskDefInfos []*TaskDefInfo) *ObjectMetadata {
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Manifests.Metadata != nil {
+			return taskDefInfo.Manifests.Metadata
+		}
+	}
+	return nil
+}
+
+// safeLabelValue returns value if it is a valid label value. Otherwise
+// characters labels don't allow become '-', the value is trimmed to start and
+// end with an alphanumeric, and values over 63 characters are truncated with a
+// hash of the original so distinct values keep distinct labels.
+func safeLabelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	safe := strings.Trim(b.String(), "-_.")
+	if len(safe) > validation.LabelValueMaxLength {
+		safe = strings.TrimRight(safe[:validation.LabelValueMaxLength-nameHashLength-1], "-_.") + "-" + nameHash(value)
+	}
+	if safe == "" {
+		return nameHash(value)
+	}
+	return safe
+}
+
+// tagLabels returns the ECS tags usable as labels: tags whose key is not a
+// valid label key, such as the aws: tags, are skipped and values made safe
+func tagLabels(tags map[string]string) map[string]string {
+	labels := map[string]string{}
+	for key, value := range tags {
+		if len(validation.IsQualifiedName(key)) > 0 {
+			continue
+		}
+		labels[key] = safeLabelValue(value)
+	}
+	return labels
+}
+
+// propagatedTags returns the tags ECS puts on the tasks of the services: the
+// service's own tags with propagateTags SERVICE, the task definition's with
+// TASK_DEFINITION, and none otherwise. Services sharing the task definition
+// are merged in order; the first value of a key wins.
+func propagatedTags(taskDefInfo *TaskDefInfo) map[string]string {
+	tags := map[string]string{}
+	for _, svc := range taskDefInfo.Services {
+		source := map[string]string{}
+		switch svc.PropagateTags {
+		case types.PropagateTagsService:
+			for _, tag := range svc.Tags {
+				source[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		case types.PropagateTagsTaskDefinition:
+			source = taskDefInfo.Tags
+		}
+		for _, key := range sortedKeys(source) {
+			if current, ok := tags[key]; ok {
+				if current != source[key] {
+					taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS service %s propagates tag %s=%s; the pods keep %s", aws.ToString(svc.ServiceName), key, source[key], current))
+				}
+				continue
+			}
+			tags[key] = source[key]
+		}
+	}
+	return tags
+}
+
+// applyPropagatedTags labels the pod template with the tags ECS propagates to
+// the tasks, so pods carry them like the running tasks do. A value that is not
+// a valid label value keeps its full value in a pod annotation of the same
+// key. Existing pod labels and the app selector label are not replaced.
+func applyPropagatedTags(taskDefInfo *TaskDefInfo) {
+	tags := propagatedTags(taskDefInfo)
+	labels := tagLabels(tags)
+	manifests := &taskDefInfo.Manifests
+	for _, key := range sortedKeys(labels) {
+		if _, ok := manifests.PodLabels[key]; ok || key == "app" {
+			continue
+		}
+		if manifests.PodLabels == nil {
+			manifests.PodLabels = map[string]string{}
+		}
+		manifests.PodLabels[key] = labels[key]
+		if labels[key] != tags[key] {
+			if manifests.PodAnnotations == nil {
+				manifests.PodAnnotations = map[string]string{}
+			}
+			manifests.PodAnnotations[key] = tags[key]
+		}
+	}
+}
+
+// limitLabelValues makes the label values of a serialized manifest valid. A
+// label whose value had to be changed keeps its full value in an annotation of
+// the same key; selectors get the same safe values so they still match.
+func limitLabelValues(doc interface{}) {
+	manifest, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	limitMetadataLabels(manifest["metadata"])
+	spec, ok := manifest["spec"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if template, ok := spec["template"].(map[string]interface{}); ok {
+		limitMetadataLabels(template["metadata"])
+	}
+	switch selector := spec["selector"].(type) {
+	case map[string]interface{}:
+		if matchLabels, ok := selector["matchLabels"]; ok {
+			selector["matchLabels"], _ = safeLabels(matchLabels)
+		} else {
+			spec["selector"], _ = safeLabels(selector)
+		}
+	case map[string]string:
+		spec["selector"], _ = safeLabels(selector)
+	}
+}
+
+// limitMetadataLabels applies safe label values to a metadata block, moving
+// the changed values into its annotations
+func limitMetadataLabels(block interface{}) {
+	metadata, ok := block.(map[string]interface{})
+	if !ok || metadata["labels"] == nil {
+		return
+	}
+	labels, overflow := safeLabels(metadata["labels"])
+	metadata["labels"] = labels
+	if len(overflow) > 0 {
+		metadata["annotations"] = mergeMetadataMap(metadata["annotations"], overflow)
+	}
+}
+
+// safeLabels returns a labels map with safe values and the original values of
+// the labels that changed. Maps without changes are returned as they are.
+func safeLabels(labels interface{}) (interface{}, map[string]string) {
+	values := map[string]string{}
+	switch current := labels.(type) {
+	case map[string]string:
+		for k, v := range current {
+			values[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range current {
+			if s, ok := v.(string); ok {
+				values[k] = s
+			}
+		}
+	default:
+		return labels, nil
+	}
+
+	overflow := map[string]string{}
+	for k, v := range values {
+		if safe := safeLabelValue(v); safe != v {
+			overflow[k] = v
+			values[k] = safe
+		}
+	}
+	if len(overflow) == 0 {
+		return labels, nil
+	}
+	return mergeMetadataMap(labels, values), overflow
+}
+
+// Ownership labels stamped on every generated object so later runs can find
+// and prune the objects of a cluster (ecs2k8s prune)
+const (
+	labelManagedBy      = "app.kubernetes.io/managed-by"
+	labelSourceCluster  = "ecs2k8s.io/source-cluster"
+	labelTaskDefinition = "ecs2k8s.io/task-definition"
+	managedByValue      = "ecs2k8s"
+)
+
+// ObjectOwner identifies the ECS cluster and task definition family a
+// generated object was converted from
+type ObjectOwner struct {
+	Cluster string
+	// Family is empty for objects shared by the services of a cluster, such
+	// as Karpenter NodePools and admission policies
+	Family string
+}
+
+// ownerOf returns the owner of the objects generated for a service. Split
+// containers keep the family of the task definition they were split from.
+func ownerOf(cluster string, taskDefInfo *TaskDefInfo) *ObjectOwner {
+	family := taskDefInfo.SourceName
+	if taskDefInfo.Source != nil && taskDefInfo.Source.Family != nil {
+		family = *taskDefInfo.Source.Family
+	}
+	return &ObjectOwner{Cluster: cluster, Family: family}
+}
+
+// labels returns the ownership labels
+func (o *ObjectOwner) labels() map[string]string {
+	labels := map[string]string{
+		labelManagedBy:     managedByValue,
+		labelSourceCluster: safeLabelValue(o.Cluster),
+	}
+	if o.Family != "" {
+		labels[labelTaskDefinition] = safeLabelValue(o.Family)
+	}
+	return labels
+}
+
+// apply stamps the ownership labels on a serialized manifest. They are added
+// after --label so the objects stay prunable.
+func (o *ObjectOwner) apply(doc interface{}) {
+	manifest, ok := doc.(map[string]interface{})
+	if o == nil || !ok {
+		return
+	}
+	if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+		metadata["labels"] = mergeMetadataMap(metadata["labels"], o.labels())
+	}
+}
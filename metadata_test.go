@@ -0,0 +1,290 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestParseMetadataRule tests parsing of [kind:]key=value flags
+func TestParseMetadataRule(t *testing.T) {
+	tests := []struct {
+		flag    string
+		want    MetadataRule
+		wantErr bool
+	}{
+		{flag: "team=payments", want: MetadataRule{Key: "team", Value: "payments"}},
+		{flag: "deployment:team=payments", want: MetadataRule{Kind: "deployment", Key: "team", Value: "payments"}},
+		{flag: "example.com/owner=a:b", want: MetadataRule{Key: "example.com/owner", Value: "a:b"}},
+		{flag: "service:example.com/scrape=true", want: MetadataRule{Kind: "service", Key: "example.com/scrape", Value: "true"}},
+		{flag: "team", wantErr: true},
+		{flag: ":team=payments", wantErr: true},
+		{flag: "bad key=x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMetadataRule(tt.flag)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseMetadataRule(%q) = %+v, want error", tt.flag, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMetadataRule(%q) error = %v", tt.flag, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMetadataRule(%q) = %+v, want %+v", tt.flag, got, tt.want)
+		}
+	}
+}
+
+// TestObjectMetadataApply tests that scoped rules only reach matching kinds
+func TestObjectMetadataApply(t *testing.T) {
+	metadata, err := parseObjectMetadata(
+		[]string{"team=payments", "deployment:tier=backend"},
+		[]string{"service:example.com/scrape=true"},
+	)
+	if err != nil {
+		t.Fatalf("parseObjectMetadata() error = %v", err)
+	}
+
+	manifests, err := convertTaskDefToK8s(&types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:         aws.String("web"),
+				Image:        aws.String("nginx:latest"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("convertTaskDefToK8s() error = %v", err)
+	}
+	manifests.Metadata = metadata
+
+	files, err := renderManifests("web", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+
+	deployment := files["web-deployment.yaml"].(map[string]interface{})["metadata"].(map[string]interface{})
+	labels := deployment["labels"].(map[string]interface{})
+	if labels["app"] != "web" || labels["team"] != "payments" || labels["tier"] != "backend" {
+		t.Errorf("deployment labels = %v", labels)
+	}
+	if _, ok := deployment["annotations"]; ok {
+		t.Errorf("deployment annotations = %v, want none", deployment["annotations"])
+	}
+
+	service := files["web-service.yaml"].(map[string]interface{})["metadata"].(map[string]interface{})
+	serviceLabels := service["labels"].(map[string]interface{})
+	if serviceLabels["team"] != "payments" || serviceLabels["tier"] != nil {
+		t.Errorf("service labels = %v", serviceLabels)
+	}
+	if annotations := service["annotations"].(map[string]interface{}); annotations["example.com/scrape"] != "true" {
+		t.Errorf("service annotations = %v", annotations)
+	}
+}
+
+// TestSafeLabelValue tests shortening of values labels cannot hold
+func TestSafeLabelValue(t *testing.T) {
+	roleARN := "arn:aws:iam::123456789012:role/" + strings.Repeat("r", 60)
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"valid", "Payments_Team.v1", "Payments_Team.v1"},
+		{"exactly 63", strings.Repeat("a", 63), strings.Repeat("a", 63)},
+		{"64 characters", strings.Repeat("a", 64), strings.Repeat("a", 54) + "-" + nameHash(strings.Repeat("a", 64))},
+		{"invalid characters", "team/payments", "team-payments"},
+		{"invalid edges", "-payments-", "payments"},
+		{"only invalid", "///", nameHash("///")},
+		{"role ARN", roleARN, "arn-aws-iam--123456789012-role-" + strings.Repeat("r", 23) + "-" + nameHash(roleARN)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeLabelValue(tt.value); got != tt.want {
+				t.Errorf("safeLabelValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLimitLabelValues tests that long label values move to annotations and
+// selectors keep matching the shortened labels
+func TestLimitLabelValues(t *testing.T) {
+	long := strings.Repeat("x", 70)
+	metadata, err := parseObjectMetadata([]string{"owner=" + long}, nil)
+	if err != nil {
+		t.Fatalf("parseObjectMetadata() error = %v", err)
+	}
+
+	manifests, err := convertTaskDefToK8s(&types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:         aws.String("web"),
+				Image:        aws.String("nginx:latest"),
+				PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("convertTaskDefToK8s() error = %v", err)
+	}
+	manifests.Metadata = metadata
+	manifests.Services[0].Spec.Selector["owner"] = long
+
+	files, err := renderManifests("web", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+
+	deployment := files["web-deployment.yaml"].(map[string]interface{})["metadata"].(map[string]interface{})
+	labels := deployment["labels"].(map[string]interface{})
+	if labels["owner"] != safeLabelValue(long) || labels["app"] != "web" {
+		t.Errorf("deployment labels = %v", labels)
+	}
+	if annotations := deployment["annotations"].(map[string]interface{}); annotations["owner"] != long {
+		t.Errorf("deployment annotations = %v, want owner=%s", annotations, long)
+	}
+
+	selector := files["web-service.yaml"].(map[string]interface{})["spec"].(map[string]interface{})["selector"].(map[string]interface{})
+	if selector["owner"] != safeLabelValue(long) || selector["app"] != "web" {
+		t.Errorf("service selector = %v", selector)
+	}
+
+	values := metadata.helmValues()["Deployment"].(map[string]interface{})
+	if values["labels"].(map[string]interface{})["owner"] != safeLabelValue(long) || values["annotations"].(map[string]string)["owner"] != long {
+		t.Errorf("helm values = %v", values)
+	}
+}
+
+// TestTagLabels tests that ECS tags become labels of a service's Kustomize objects
+func TestTagLabels(t *testing.T) {
+	labels := tagLabels(map[string]string{
+		"team":                          "payments",
+		"example.com/cost-center":       "cc 1234",
+		"aws:cloudformation:stack-name": "web-stack",
+		"Name with spaces":              "x",
+	})
+	want := map[string]string{"team": "payments", "example.com/cost-center": "cc-1234"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("tagLabels() = %v, want %v", labels, want)
+	}
+
+	info := &TaskDefInfo{
+		Name: "web",
+		Tags: map[string]string{"team": "payments"},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+			ConfigMaps: []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "web.config"}}},
+		},
+	}
+	workload, err := newWorkload(info)
+	if err != nil {
+		t.Fatalf("newWorkload() error = %v", err)
+	}
+	patch := tagLabelsPatch(workload)
+	if patch == nil {
+		t.Fatal("tagLabelsPatch() = nil")
+	}
+	if got := patch["target"].(map[string]interface{})["name"]; got != `^(web|web\.config)$` {
+		t.Errorf("patch target = %v", got)
+	}
+	if !strings.Contains(patch["patch"].(string), "team: payments") {
+		t.Errorf("patch = %s", patch["patch"])
+	}
+
+	info.Tags = map[string]string{"aws:ecs:cluster": "prod"}
+	if patch := tagLabelsPatch(workload); patch != nil {
+		t.Errorf("tagLabelsPatch() without label-safe tags = %v", patch)
+	}
+}
+
+// TestApplyPropagatedTags tests labelling pods with the tags ECS propagates
+// to the tasks of a service
+func TestApplyPropagatedTags(t *testing.T) {
+	info := &TaskDefInfo{
+		Name: "web",
+		Tags: map[string]string{"team": "payments", "app": "legacy"},
+		Services: []types.Service{
+			{ServiceName: aws.String("web"), PropagateTags: types.PropagateTagsTaskDefinition},
+			{
+				ServiceName:   aws.String("web-canary"),
+				PropagateTags: types.PropagateTagsService,
+				Tags: []types.Tag{
+					{Key: aws.String("team"), Value: aws.String("checkout")},
+					{Key: aws.String("owner"), Value: aws.String("arn:aws:iam::123456789012:role/web")},
+				},
+			},
+		},
+	}
+	applyPropagatedTags(info)
+
+	want := map[string]string{"team": "payments", "owner": safeLabelValue("arn:aws:iam::123456789012:role/web")}
+	if !reflect.DeepEqual(info.Manifests.PodLabels, want) {
+		t.Errorf("pod labels = %v, want %v", info.Manifests.PodLabels, want)
+	}
+	if got := info.Manifests.PodAnnotations["owner"]; got != "arn:aws:iam::123456789012:role/web" {
+		t.Errorf("owner annotation = %q, want the full ARN", got)
+	}
+	if len(info.Notes) != 1 || !strings.Contains(info.Notes[0], "team=checkout") {
+		t.Errorf("notes = %v, want the conflicting team tag", info.Notes)
+	}
+
+	info = &TaskDefInfo{
+		Name:     "web",
+		Tags:     map[string]string{"team": "payments"},
+		Services: []types.Service{{ServiceName: aws.String("web"), PropagateTags: types.PropagateTagsNone}},
+	}
+	applyPropagatedTags(info)
+	if len(info.Manifests.PodLabels) != 0 {
+		t.Errorf("pod labels without propagateTags = %v, want none", info.Manifests.PodLabels)
+	}
+}
+
+// TestObjectOwner tests that every rendered object carries the ownership labels
+func TestObjectOwner(t *testing.T) {
+	source := &types.TaskDefinition{Family: aws.String("web_app")}
+	info := &TaskDefInfo{Name: "web-app-api", SourceName: "web_app-api", Source: source}
+	owner := ownerOf("prod", info)
+	if owner.Family != "web_app" {
+		t.Errorf("ownerOf().Family = %q, want the family of the source", owner.Family)
+	}
+
+	manifests := K8sManifests{
+		Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: "api:1"}}},
+		ConfigMaps: []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "web-app-api"}}},
+		Metadata:   &ObjectMetadata{Labels: []MetadataRule{{Key: labelManagedBy, Value: "someone-else"}}},
+		Owner:      owner,
+	}
+	files, err := renderManifests("web-app-api", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	for filename, doc := range files {
+		labels := doc.(map[string]interface{})["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+		if labels[labelManagedBy] != managedByValue || labels[labelSourceCluster] != "prod" || labels[labelTaskDefinition] != "web_app" {
+			t.Errorf("%s labels = %v", filename, labels)
+		}
+	}
+
+	shared := map[string]interface{}{"kind": "NodePool", "metadata": map[string]interface{}{"name": "prod"}}
+	(&ObjectOwner{Cluster: "prod"}).apply(shared)
+	labels := shared["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+	if _, ok := labels[labelTaskDefinition]; ok || labels[labelSourceCluster] != "prod" {
+		t.Errorf("shared object labels = %v", labels)
+	}
+}
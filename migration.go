@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// migrationTag marks a task definition family whose containers all run once
+// per release, as a tag key or as the value of any tag (e.g. type=migration)
+const migrationTag = "migration"
+
+// helmHooks lists the Helm hook events a migration Job can run on
+var helmHooks = []string{
+	"pre-install", "post-install",
+	"pre-upgrade", "post-upgrade",
+	"pre-rollback", "post-rollback",
+	"pre-delete", "post-delete",
+}
+
+// defaultMigrationHooks run migrations before the services of a release are
+// installed or upgraded
+var defaultMigrationHooks = []string{"pre-install", "pre-upgrade"}
+
+// isValidHelmHook checks a migrations hook of the config file
+func isValidHelmHook(hook string) bool {
+	for _, h := range helmHooks {
+		if h == hook {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationJob holds the containers of a service that run to completion as a
+// Job, which Helm runs as a hook, instead of in its Deployment
+type MigrationJob struct {
+	// Hooks are the Helm hook events running the Job
+	Hooks   []string        `json:"hooks"`
+	PodSpec *corev1.PodSpec `json:"podSpec"`
+	// Containers are the container configs of the Job used for Helm values
+	Containers []ContainerConfig `json:"-"`
+}
+
+// isMigrationFamily reports whether the tags of a task definition mark it as a
+// migration
+func isMigrationFamily(tags map[string]string) bool {
+	for key, value := range tags {
+		if strings.EqualFold(key, migrationTag) && !strings.EqualFold(value, "false") {
+			return true
+		}
+		if strings.EqualFold(value, migrationTag) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationContainers returns the names of the containers that run as the
+// migration Job: the configured ones, every container of a family tagged
+// migration, or else the non-essential containers with a command, which ECS
+// runs to completion next to the essential ones
+func migrationContainers(taskDefInfo *TaskDefInfo, settings *MigrationSettings) map[string]bool {
+	names := map[string]bool{}
+	if settings != nil && len(settings.Containers) > 0 {
+		for _, name := range settings.Containers {
+			names[sanitizeName(name)] = true
+		}
+		return names
+	}
+
+	family := isMigrationFamily(taskDefInfo.Tags)
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		if family || (def.Essential != nil && !*def.Essential && len(def.Command) > 0) {
+			names[sanitizeName(aws.ToString(def.Name))] = true
+		}
+	}
+	return names
+}
+
+// applyMigrations moves the migration containers of a service out of its
+// Deployment into a MigrationJob. A service left without containers has no
+// Deployment or Services.
+func applyMigrations(taskDefInfo *TaskDefInfo, settings *MigrationSettings) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || taskDefInfo.Source == nil || (settings != nil && settings.Disabled) {
+		return
+	}
+	names := migrationContainers(taskDefInfo, settings)
+	if len(names) == 0 {
+		return
+	}
+
+	hooks := defaultMigrationHooks
+	if settings != nil && len(settings.Hooks) > 0 {
+		hooks = settings.Hooks
+	}
+	job := &MigrationJob{Hooks: hooks, PodSpec: podSpec.DeepCopy()}
+	job.PodSpec.Containers = nil
+	job.PodSpec.Affinity = nil
+	job.PodSpec.RestartPolicy = corev1.RestartPolicyNever
+
+	var kept []corev1.Container
+	for _, c := range podSpec.Containers {
+		if !names[c.Name] {
+			kept = append(kept, c)
+			continue
+		}
+		// The Deployment ignores ECS entryPoint and command; a migration needs them
+		for _, def := range taskDefInfo.Source.ContainerDefinitions {
+			if sanitizeName(aws.ToString(def.Name)) == c.Name {
+				c.Command, c.Args = def.EntryPoint, def.Command
+			}
+		}
+		job.PodSpec.Containers = append(job.PodSpec.Containers, c)
+	}
+	if len(job.PodSpec.Containers) == 0 {
+		log.Printf("Warning: No container of %s matches the configured migration containers", taskDefInfo.Name)
+		return
+	}
+
+	var configs []ContainerConfig
+	for _, containerConfig := range taskDefInfo.Containers {
+		if !names[containerConfig.Name] {
+			configs = append(configs, containerConfig)
+			continue
+		}
+		for _, c := range job.PodSpec.Containers {
+			if c.Name == containerConfig.Name {
+				containerConfig.Command, containerConfig.Args = c.Command, c.Args
+			}
+		}
+		job.Containers = append(job.Containers, containerConfig)
+	}
+	taskDefInfo.Containers = configs
+	taskDefInfo.Manifests.Migration = job
+
+	if len(kept) == 0 {
+		taskDefInfo.Manifests.Deployment = nil
+		taskDefInfo.Manifests.Services = nil
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Task definition %s runs as a Job on the Helm %s hooks instead of a Deployment", taskDefInfo.SourceName, strings.Join(hooks, ", ")))
+		return
+	}
+	podSpec.Containers = kept
+	for _, c := range job.PodSpec.Containers {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s runs as a Job on the Helm %s hooks instead of in the Deployment", c.Name, strings.Join(hooks, ", ")))
+	}
+}
+
+// renderMigrationJob serializes the migration Job of a service. The hook
+// annotations are ignored outside Helm, where the Job runs once when applied.
+func renderMigrationJob(taskDefName string, job *MigrationJob) map[string]interface{} {
+	name := sanitizeName(taskDefName + "-migration")
+	return map[string]interface{}{
+		"apiVersion": apiVersionBatch,
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+			"labels": map[string]string{
+				"app": taskDefName,
+			},
+			"annotations": map[string]string{
+				"helm.sh/hook":               strings.Join(job.Hooks, ","),
+				"helm.sh/hook-weight":        "-5",
+				"helm.sh/hook-delete-policy": "before-hook-creation",
+			},
+		},
+		"spec": map[string]interface{}{
+			// A failed migration fails the release instead of being retried
+			"backoffLimit": 0,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]string{"app": name},
+				},
+				"spec": serializePodSpec(job.PodSpec),
+			},
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestApplyMigrations tests moving one-shot migration containers into a hook Job
+func TestApplyMigrations(t *testing.T) {
+	newInfo := func(t *testing.T, tags map[string]string) *TaskDefInfo {
+		taskDef := &types.TaskDefinition{
+			Family: aws.String("shop"),
+			ContainerDefinitions: []types.ContainerDefinition{
+				{Name: aws.String("web"), Image: aws.String("shop:1"), PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}}},
+				{Name: aws.String("migrate"), Image: aws.String("shop:1"), Essential: aws.Bool(false), Command: []string{"rake", "db:migrate"}},
+			},
+		}
+		info, err := buildTaskDefInfo(taskDef, "shop")
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo() error = %v", err)
+		}
+		info.Tags = tags
+		return info
+	}
+
+	info := newInfo(t, nil)
+	applyMigrations(info, nil)
+	job := info.Manifests.Migration
+	if job == nil {
+		t.Fatal("applyMigrations() did not detect the non-essential command container")
+	}
+	if len(job.PodSpec.Containers) != 1 || !reflect.DeepEqual(job.PodSpec.Containers[0].Args, []string{"rake", "db:migrate"}) {
+		t.Errorf("job containers = %+v", job.PodSpec.Containers)
+	}
+	if !reflect.DeepEqual(job.Hooks, defaultMigrationHooks) {
+		t.Errorf("job hooks = %v, want %v", job.Hooks, defaultMigrationHooks)
+	}
+	if len(info.Manifests.Deployment.Containers) != 1 || info.Manifests.Deployment.Containers[0].Name != "web" {
+		t.Errorf("deployment containers = %+v, want web only", info.Manifests.Deployment.Containers)
+	}
+	if len(info.Containers) != 1 || len(job.Containers) != 1 || job.Containers[0].Name != "migrate" {
+		t.Errorf("container configs = %+v, job = %+v", info.Containers, job.Containers)
+	}
+
+	files, err := renderManifests(info.Name, info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	rendered, ok := files["shop-migration-job.yaml"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("rendered files = %v, want shop-migration-job.yaml", sortedDocKeys(files))
+	}
+	annotations := rendered["metadata"].(map[string]interface{})["annotations"].(map[string]string)
+	if annotations["helm.sh/hook"] != "pre-install,pre-upgrade" {
+		t.Errorf("job annotations = %v", annotations)
+	}
+
+	family := newInfo(t, map[string]string{"type": "migration"})
+	applyMigrations(family, &MigrationSettings{Hooks: []string{"pre-upgrade"}})
+	if family.Manifests.Deployment != nil || family.Manifests.Services != nil {
+		t.Error("a family tagged migration kept its Deployment or Services")
+	}
+	if family.Manifests.Migration == nil || len(family.Manifests.Migration.PodSpec.Containers) != 2 {
+		t.Errorf("family job = %+v, want both containers", family.Manifests.Migration)
+	}
+
+	disabled := newInfo(t, nil)
+	applyMigrations(disabled, &MigrationSettings{Disabled: true})
+	if disabled.Manifests.Migration != nil || len(disabled.Manifests.Deployment.Containers) != 2 {
+		t.Error("disabled migrations still moved containers")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ConversionModel is the intermediate representation of the converted
+// services of a cluster. It is built once per run, and the raw, Helm,
+// Kustomize and Crossplane exporters read it concurrently, so they must not
+// modify it.
+type ConversionModel struct {
+	Cluster   string
+	Workloads []*Workload
+}
+
+// Workload is a converted service of the model, with what the exporters
+// would otherwise derive from its TaskDefInfo each their own way
+type Workload struct {
+	Info *TaskDefInfo
+	Name string
+	// Family is the ECS task definition family, shared by split containers
+	Family string
+	// RoleArn is the IAM role bound to the ServiceAccount: the task role, or
+	// the execution role without one
+	RoleArn string
+	// Base are the rendered documents keyed by file name before the user
+	// overrides and the kind filter, which Kustomize applies as patches
+	Base map[string]interface{}
+	// Docs are the final documents, as written to the raw output
+	Docs map[string]interface{}
+}
+
+// newWorkload renders a converted service into the model
+func newWorkload(taskDefInfo *TaskDefInfo) (*Workload, error) {
+	base, err := renderBaseManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := finishManifests(base, taskDefInfo.Manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	roleArn := taskDefInfo.TaskRoleArn
+	if roleArn == "" {
+		roleArn = taskDefInfo.ExecutionRoleArn
+	}
+	return &Workload{
+		Info:    taskDefInfo,
+		Name:    taskDefInfo.Name,
+		Family:  ownerOf("", taskDefInfo).Family,
+		RoleArn: roleArn,
+		Base:    base,
+		Docs:    docs,
+	}, nil
+}
+
+// newConversionModel renders the converted services of a cluster
+func newConversionModel(cluster string, taskDefInfos []*TaskDefInfo) (*ConversionModel, error) {
+	model := &ConversionModel{Cluster: cluster}
+	for _, taskDefInfo := range taskDefInfos {
+		workload, err := newWorkload(taskDefInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render manifests for %s: %w", taskDefInfo.Name, err)
+		}
+		model.Workloads = append(model.Workloads, workload)
+	}
+	return model, nil
+}
+
+// infos returns the converted services of the model
+func (m *ConversionModel) infos() []*TaskDefInfo {
+	infos := make([]*TaskDefInfo, 0, len(m.Workloads))
+	for _, workload := range m.Workloads {
+		infos = append(infos, workload.Info)
+	}
+	return infos
+}
+
+// writeWorkload writes the documents of a workload to the output directory
+func writeWorkload(outputDir string, workload *Workload) error {
+	if err := validateManifestOutput(outputDir, workload.Name); err != nil {
+		return err
+	}
+	return writeManifestFiles(outputDir, workload.Docs)
+}
+
+// withOwnMetadata returns a copy of a rendered document whose metadata can
+// be changed without changing the model
+func withOwnMetadata(doc interface{}) map[string]interface{} {
+	manifest, _ := doc.(map[string]interface{})
+	copied := make(map[string]interface{}, len(manifest))
+	for k, v := range manifest {
+		copied[k] = v
+	}
+	metadata := map[string]interface{}{}
+	if m, ok := manifest["metadata"].(map[string]interface{}); ok {
+		for k, v := range m {
+			metadata[k] = v
+		}
+	}
+	copied["metadata"] = metadata
+	return copied
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestConversionModel tests that the exporters read the documents rendered
+// once into the conversion model
+func TestConversionModel(t *testing.T) {
+	info := &TaskDefInfo{
+		Name:             "web",
+		SourceName:       "web",
+		ExecutionRoleArn: "arn:aws:iam::123456789012:role/exec",
+		Containers:       []ContainerConfig{{Name: "web", Image: "nginx:1.27"}},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:1.27"}}},
+			ServiceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+				Name:        "web-sa",
+				Annotations: map[string]string{irsaAnnotation: "arn:aws:iam::123456789012:role/exec"},
+			}},
+			Migration: &MigrationJob{PodSpec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "migrate", Image: "web:1"}}}},
+			Owner:     &ObjectOwner{Cluster: "prod", Family: "web"},
+			Overrides: []OverridePatch{{
+				Source: "web.yaml", Kind: "Deployment", Name: "web",
+				Patch: map[string]interface{}{"spec": map[string]interface{}{"replicas": 3}},
+			}},
+		},
+	}
+	model, err := newConversionModel("prod", []*TaskDefInfo{info})
+	if err != nil {
+		t.Fatalf("newConversionModel() error = %v", err)
+	}
+	workload := model.Workloads[0]
+	if workload.Family != "web" || workload.RoleArn != info.ExecutionRoleArn {
+		t.Errorf("workload family = %q, role = %q", workload.Family, workload.RoleArn)
+	}
+	replicas := func(docs map[string]interface{}) interface{} {
+		return docs["web-deployment.yaml"].(map[string]interface{})["spec"].(map[string]interface{})["replicas"]
+	}
+	if replicas(workload.Base) != 1 || replicas(workload.Docs) != float64(3) {
+		t.Errorf("replicas base = %v, docs = %v, want the override in the docs only", replicas(workload.Base), replicas(workload.Docs))
+	}
+
+	outputDir := t.TempDir()
+	stages := &stageRunner{}
+	stages.runParallel([]outputStage{
+		{"Helm chart", func() error { return createHelmChart(model, outputDir, helmChartOptions{}) }},
+		{"Kustomize structure", func() error { return createKustomizeStructure(model, outputDir) }},
+		{"Crossplane objects", func() error { return createCrossplanePackage(model, outputDir, crossplaneObjects, "default") }},
+	})
+	if len(stages.failures) > 0 {
+		t.Fatalf("exporters failed: %+v", stages.failures)
+	}
+
+	// Kustomize writes the raw documents before the overrides, now including
+	// the migration Job, and leaves the IRSA role to its component
+	basePath := filepath.Join(outputDir, "prod", "kustomize", "prod", "base")
+	var deployment, serviceAccount map[string]interface{}
+	readYAML(t, filepath.Join(basePath, "deployments", "web-deployment.yaml"), &deployment)
+	metadata := deployment["metadata"].(map[string]interface{})
+	if metadata["labels"].(map[string]interface{})[labelManagedBy] != managedByValue {
+		t.Errorf("Kustomize Deployment labels = %v, want the ownership labels of the raw output", metadata["labels"])
+	}
+	if _, err := os.Stat(filepath.Join(basePath, "jobs", "web-migration-job.yaml")); err != nil {
+		t.Errorf("Kustomize base has no migration Job: %v", err)
+	}
+	readYAML(t, filepath.Join(basePath, "serviceaccounts", "web-serviceaccount.yaml"), &serviceAccount)
+	if annotations := serviceAccount["metadata"].(map[string]interface{})["annotations"]; annotations != nil {
+		t.Errorf("Kustomize ServiceAccount annotations = %v, want the IRSA role left to the component", annotations)
+	}
+	saDoc := workload.Docs["web-serviceaccount.yaml"].(map[string]interface{})
+	if saDoc["metadata"].(map[string]interface{})["annotations"] == nil {
+		t.Errorf("stripping the IRSA annotation changed the model")
+	}
+
+	for _, path := range []string{
+		filepath.Join(outputDir, "prod", "helm", "prod", "values.yaml"),
+		filepath.Join(outputDir, "crossplane", "objects", "web-deployment.yaml"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("exporter output missing: %v", err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxNameLength is the length limit of RFC 1123 labels, which also bounds
+// label values such as app: <name>
+const maxNameLength = 63
+
+// nameHashLength is the number of hex digits appended to truncated names
+const nameHashLength = 8
+
+// sanitizeName turns an ECS name into an RFC 1123 label usable for Kubernetes
+// object names, label values and file names: lowercase alphanumerics and '-',
+// starting and ending with an alphanumeric. Names longer than 63 characters
+// are truncated and suffixed with a hash of the original so they stay unique.
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			// Underscores, dots and anything else ECS or IaC names allow
+			b.WriteRune('-')
+		}
+	}
+	sanitized := strings.Trim(b.String(), "-")
+	if sanitized == "" && name != "" {
+		return nameHash(name)
+	}
+
+	if len(sanitized) > maxNameLength {
+		prefix := strings.TrimRight(sanitized[:maxNameLength-nameHashLength-1], "-")
+		sanitized = prefix + "-" + nameHash(name)
+	}
+	return sanitized
+}
+
+// nameHash is the short hash that keeps truncated names distinct
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// nameClaims maps sanitized names to the source names they were derived from,
+// detecting distinct sources that sanitize to the same Kubernetes name
+type nameClaims map[string]string
+
+// claim records the sanitized name of a source name and fails if another
+// source already maps to it, as their manifests would overwrite each other
+func (c nameClaims) claim(source string) (string, error) {
+	name := sanitizeName(source)
+	if prev, ok := c[name]; ok && prev != source {
+		return name, fmt.Errorf("%s and %s both map to Kubernetes name %s", prev, source, name)
+	}
+	c[name] = source
+	return name, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+var rfc1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// TestSanitizeName tests conversion of ECS names into RFC 1123 labels
+func TestSanitizeName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", "web-app", "web-app"},
+		{"uppercase", "WebApp", "webapp"},
+		{"underscores", "my_web_app", "my-web-app"},
+		{"dots and edges", "_api.v2_", "api-v2"},
+		{"empty", "", ""},
+		{"exactly 63", strings.Repeat("a", 63), strings.Repeat("a", 63)},
+		{"too long", long, strings.Repeat("a", 54) + "-" + nameHash(long)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeName(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if got != "" && !rfc1123Label.MatchString(got) {
+				t.Errorf("sanitizeName(%q) = %q is not an RFC 1123 label", tt.in, got)
+			}
+		})
+	}
+
+	// Truncated names sharing a prefix stay distinct
+	if sanitizeName(long+"x") == sanitizeName(long+"y") {
+		t.Errorf("truncated names collide")
+	}
+	if got := sanitizeName("___"); !rfc1123Label.MatchString(got) {
+		t.Errorf("sanitizeName(\"___\") = %q is not an RFC 1123 label", got)
+	}
+}
+
+// TestNameClaims tests detection of source names sanitizing to the same name
+func TestNameClaims(t *testing.T) {
+	claims := nameClaims{}
+	if _, err := claims.claim("Web_App"); err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+	if _, err := claims.claim("Web_App"); err != nil {
+		t.Errorf("claim() of the same source error = %v", err)
+	}
+	if _, err := claims.claim("web-app"); err == nil {
+		t.Errorf("claim() of a colliding source succeeded")
+	}
+}
+
+// TestConvertTaskDefToK8sSanitizesNames tests that ECS names become valid Kubernetes names
+func TestConvertTaskDefToK8sSanitizesNames(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/My_Service:3"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("Web_Server"),
+			Image:        aws.String("nginx"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			Environment:  []types.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("prod")}},
+		}},
+	}
+
+	info, err := buildTaskDefInfo(taskDef, "My_Service")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	if info.Name != "my-service" || info.SourceName != "My_Service" {
+		t.Errorf("Name = %q, SourceName = %q", info.Name, info.SourceName)
+	}
+	if got := info.Manifests.Deployment.Containers[0].Name; got != "web-server" {
+		t.Errorf("container name = %q, want web-server", got)
+	}
+	if got := info.Manifests.ConfigMaps[0].Name; got != "web-server-config" {
+		t.Errorf("ConfigMap name = %q, want web-server-config", got)
+	}
+	if got := info.Manifests.Services[0].Spec.Selector["app"]; got != "my-service" {
+		t.Errorf("Service selector = %q, want my-service", got)
+	}
+
+	files, err := renderManifests(info.Name, info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	if _, ok := files["my-service-deployment.yaml"]; !ok {
+		t.Errorf("missing my-service-deployment.yaml in %v", sortedDocKeys(files))
+	}
+
+	taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, types.ContainerDefinition{
+		Name:  aws.String("web-server"),
+		Image: aws.String("nginx"),
+	})
+	if _, err := convertTaskDefToK8s(taskDef); err == nil {
+		t.Errorf("convertTaskDefToK8s() with colliding container names succeeded")
+	}
+}
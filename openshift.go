@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restrictedV2AllowedCapabilities are the capabilities the OpenShift
+// restricted-v2 SCC lets containers add
+var restrictedV2AllowedCapabilities = map[string]bool{
+	"NET_BIND_SERVICE": true,
+}
+
+// ingressToRoutes replaces the Ingress of a service with OpenShift Routes, one
+// per host and path, since the default router serves Routes
+func ingressToRoutes(taskDefInfo *TaskDefInfo) {
+	manifests := &taskDefInfo.Manifests
+
+	var extras []ExtraObject
+	var routes []map[string]interface{}
+	for _, extra := range manifests.Extras {
+		if extra.Object["kind"] != "Ingress" {
+			extras = append(extras, extra)
+			continue
+		}
+
+		spec, _ := extra.Object["spec"].(map[string]interface{})
+		rules, _ := spec["rules"].([]map[string]interface{})
+		for _, rule := range rules {
+			host, _ := rule["host"].(string)
+			http, _ := rule["http"].(map[string]interface{})
+			paths, _ := http["paths"].([]map[string]interface{})
+			for _, path := range paths {
+				route, note := buildRoute(host, path)
+				if route == nil {
+					continue
+				}
+				routes = append(routes, route)
+				if note != "" {
+					taskDefInfo.Notes = append(taskDefInfo.Notes, note)
+				}
+			}
+		}
+	}
+
+	for i, route := range routes {
+		name, suffix := taskDefInfo.Name, "route"
+		if len(routes) > 1 {
+			name = fmt.Sprintf("%s-%d", taskDefInfo.Name, i+1)
+			suffix = fmt.Sprintf("route-%d", i+1)
+		}
+		route["metadata"] = map[string]interface{}{
+			"name":   name,
+			"labels": map[string]string{"app": taskDefInfo.Name},
+		}
+		extras = append(extras, ExtraObject{Suffix: suffix, Object: route})
+	}
+	manifests.Extras = extras
+}
+
+// buildRoute converts one Ingress path into a Route. Routes match paths by
+// prefix, so exact and wildcard paths are reported.
+func buildRoute(host string, path map[string]interface{}) (map[string]interface{}, string) {
+	backend, _ := path["backend"].(map[string]interface{})
+	service, _ := backend["service"].(map[string]interface{})
+	serviceName, _ := service["name"].(string)
+	if serviceName == "" {
+		return nil, ""
+	}
+	port, _ := service["port"].(map[string]interface{})
+
+	spec := map[string]interface{}{
+		"to": map[string]interface{}{
+			"kind":   "Service",
+			"name":   serviceName,
+			"weight": 100,
+		},
+	}
+	if host != "" {
+		spec["host"] = host
+	}
+	value, _ := path["path"].(string)
+	if value != "" && value != "/" {
+		spec["path"] = value
+	}
+	if number, ok := port["number"]; ok {
+		spec["port"] = map[string]interface{}{"targetPort": number}
+	}
+
+	var note string
+	if pathType, _ := path["pathType"].(string); pathType != "Prefix" {
+		note = fmt.Sprintf("Route to %s matches %s%s as a prefix; the ALB rule was %s", serviceName, host, value, strings.ToLower(pathType))
+	}
+
+	return map[string]interface{}{
+		"apiVersion": apiVersionOpenShiftRoute,
+		"kind":       "Route",
+		"spec":       spec,
+	}, note
+}
+
+// applyRestrictedSecurityContext sets the container securityContext accepted by
+// the restricted-v2 SCC. No UID is set: OpenShift assigns one from the
+// namespace's range, so images must run as an arbitrary non-root user.
+func applyRestrictedSecurityContext(taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	allowPrivilegeEscalation := false
+	runAsNonRoot := true
+	contexts := map[string]*corev1.SecurityContext{}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if c.SecurityContext == nil {
+			c.SecurityContext = &corev1.SecurityContext{}
+		}
+		sc := c.SecurityContext
+		sc.RunAsUser = nil
+		sc.RunAsGroup = nil
+		sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+		sc.RunAsNonRoot = &runAsNonRoot
+		if sc.Capabilities == nil {
+			sc.Capabilities = &corev1.Capabilities{}
+		}
+		sc.Capabilities.Drop = []corev1.Capability{"ALL"}
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+		contexts[c.Name] = sc
+	}
+	if podSpec.SecurityContext != nil {
+		podSpec.SecurityContext.RunAsUser = nil
+		podSpec.SecurityContext.RunAsGroup = nil
+		podSpec.SecurityContext.FSGroup = nil
+	}
+
+	// The Helm chart renders containers from the container configs
+	for i := range taskDefInfo.Containers {
+		taskDefInfo.Containers[i].SecurityContext = contexts[taskDefInfo.Containers[i].Name]
+	}
+}
+
+// restrictedV2Violations lists the settings of a task definition the
+// restricted-v2 SCC rejects or overrides
+func restrictedV2Violations(taskDef *types.TaskDefinition) []string {
+	if taskDef == nil {
+		return nil
+	}
+
+	var violations []string
+	for _, c := range taskDef.ContainerDefinitions {
+		name := aws.ToString(c.Name)
+		if aws.ToBool(c.Privileged) {
+			violations = append(violations, fmt.Sprintf("container %s is privileged", name))
+		}
+		if user := aws.ToString(c.User); user != "" {
+			violations = append(violations, fmt.Sprintf("container %s runs as user %s; restricted-v2 assigns a UID from the namespace range", name, user))
+		}
+		if c.LinuxParameters != nil && c.LinuxParameters.Capabilities != nil {
+			for _, capability := range c.LinuxParameters.Capabilities.Add {
+				if !restrictedV2AllowedCapabilities[strings.TrimPrefix(strings.ToUpper(capability), "CAP_")] {
+					violations = append(violations, fmt.Sprintf("container %s adds capability %s", name, capability))
+				}
+			}
+		}
+		for _, pm := range c.PortMappings {
+			if pm.HostPort != nil && *pm.HostPort != 0 && taskDef.NetworkMode != types.NetworkModeAwsvpc {
+				violations = append(violations, fmt.Sprintf("container %s uses host port %d", name, *pm.HostPort))
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestOpenShiftProfile tests Routes and restricted-v2 compatible pods for OpenShift
+func TestOpenShiftProfile(t *testing.T) {
+	targetGroups := map[string]*targetGroupInfo{
+		"tg-web": {Routes: []ingressRoute{{Host: "shop.example.com", Path: "/*"}, {Host: "shop.example.com", Path: "/health"}}},
+	}
+	info := &TaskDefInfo{
+		Name: "web",
+		Source: &types.TaskDefinition{
+			ContainerDefinitions: []types.ContainerDefinition{{
+				Name: aws.String("web"),
+				User: aws.String("0"),
+				LinuxParameters: &types.LinuxParameters{
+					Capabilities: &types.KernelCapabilities{Add: []string{"NET_BIND_SERVICE", "SYS_ADMIN"}},
+				},
+			}},
+		},
+		Containers: []ContainerConfig{{Name: "web"}},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			Extras: []ExtraObject{{
+				Suffix: "ingress",
+				Object: buildTargetGroupIngress("web", []targetGroupAttachment{{TargetGroupArn: "tg-web", ContainerName: "web", ContainerPort: 8080}}, targetGroups),
+			}},
+		},
+	}
+
+	platformProfiles[profileOpenShift].apply(info, nil)
+
+	files, err := renderManifests(info.Name, info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	if _, ok := files["web-ingress.yaml"]; ok {
+		t.Errorf("Ingress generated for OpenShift")
+	}
+	route, ok := files["web-route-2.yaml"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("files = %v, want web-route-1.yaml and web-route-2.yaml", fileNames(files))
+	}
+	spec := route["spec"].(map[string]interface{})
+	if spec["host"] != "shop.example.com" || spec["path"] != "/health" || spec["to"].(map[string]interface{})["name"] != "web" {
+		t.Errorf("Route spec = %v", spec)
+	}
+	if !hasNote(info.Notes, "matches shop.example.com/health as a prefix") {
+		t.Errorf("notes = %v, want the exact path reported", info.Notes)
+	}
+
+	sc := info.Manifests.Deployment.Containers[0].SecurityContext
+	if sc == nil || sc.RunAsUser != nil || !*sc.RunAsNonRoot || *sc.AllowPrivilegeEscalation || sc.Capabilities.Drop[0] != "ALL" {
+		t.Errorf("securityContext = %+v, want restricted-v2 without a UID", sc)
+	}
+	if info.Containers[0].SecurityContext != sc {
+		t.Errorf("container config securityContext not set for the Helm chart")
+	}
+	if !hasNote(info.Notes, "restricted-v2 SCC: container web runs as user 0") || !hasNote(info.Notes, "adds capability SYS_ADMIN") || hasNote(info.Notes, "adds capability NET_BIND_SERVICE") {
+		t.Errorf("notes = %v", info.Notes)
+	}
+}
+
+// fileNames returns the keys of a map of rendered files
+func fileNames(files map[string]interface{}) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	return names
+}
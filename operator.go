@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/cobra"
+)
+
+// ecsMirrorResource is the kubectl resource name of the ECSMirror CRD
+const ecsMirrorResource = "ecsmirrors.ecs2k8s.io"
+
+// ECSMirror is the custom resource reconciled by the operator
+type ECSMirror struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec ECSMirrorSpec `json:"spec"`
+}
+
+// ECSMirrorSpec selects the ECS services to mirror into Kubernetes
+type ECSMirrorSpec struct {
+	Region string `json:"region"`
+	// Cluster is the ECS cluster name to mirror
+	Cluster string `json:"cluster"`
+	// Services restricts mirroring to the named ECS services (all services when empty)
+	Services []string `json:"services,omitempty"`
+	// TargetNamespace is where objects are applied (defaults to the ECSMirror namespace)
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// Suspend pauses reconciliation of this mirror
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// ECSMirrorStatus is written back to the ECSMirror status subresource
+type ECSMirrorStatus struct {
+	LastSyncTime   string   `json:"lastSyncTime,omitempty"`
+	SyncedServices []string `json:"syncedServices,omitempty"`
+	Failures       []string `json:"failures,omitempty"`
+	Message        string   `json:"message,omitempty"`
+}
+
+// ecsMirrorList is the result of `kubectl get ecsmirrors -o json`
+type ecsMirrorList struct {
+	Items []ECSMirror `json:"items"`
+}
+
+// mirrorKubectl is the kubectl access of the operator
+type mirrorKubectl interface {
+	run(ctx context.Context, stdin []byte, args ...string) ([]byte, error)
+	apply(ctx context.Context, manifests []byte, namespace string, forceConflicts bool) ([]byte, error)
+}
+
+// mirrorOperator periodically converts and applies ECS services selected by ECSMirror resources
+type mirrorOperator struct {
+	kubectl   mirrorKubectl
+	namespace string
+	// forceConflicts takes back fields changed by hand on every resync
+	forceConflicts bool
+	// fetch reads the task definitions of the services a mirror selects, the
+	// failures of those it could not read and the AWS config they were read
+	// with
+	fetch func(ctx context.Context, spec ECSMirrorSpec) ([]*TaskDefInfo, []string, *aws.Config, error)
+}
+
+// newOperatorCommand creates the `operator` subcommand
+func newOperatorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Continuously mirror ECS services into Kubernetes via ECSMirror resources",
+		Long: `operator runs a controller loop that watches ECSMirror custom resources and,
+on every resync interval, converts the selected ECS services and applies the
+equivalent Kubernetes objects. This enables a gradual, continuously-synced
+migration instead of a one-shot export.
+
+Install the CRDs first (ECSMirror and the ConversionRecord of --conversion-records):
+  ecs2k8s operator --print-crd | kubectl apply -f -`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printCRD, _ := cmd.Flags().GetBool("print-crd")
+			if printCRD {
+				_, err := fmt.Fprint(os.Stdout, ecsMirrorCRD+"---\n"+conversionRecordCRD)
+				return err
+			}
+
+			interval, _ := cmd.Flags().GetDuration("interval")
+			kubeContext, _ := cmd.Flags().GetString("kubecontext")
+			namespace, _ := cmd.Flags().GetString("namespace")
+			forceConflicts, _ := cmd.Flags().GetBool("force-conflicts")
+
+			if interval < time.Minute {
+				return fmt.Errorf("interval must be at least 1m (got %s)", interval)
+			}
+
+			op := &mirrorOperator{
+				kubectl:        &kubectlRunner{Context: kubeContext},
+				namespace:      namespace,
+				forceConflicts: forceConflicts,
+				fetch:          fetchMirrorTaskDefInfos,
+			}
+			return op.run(interval)
+		},
+	}
+
+	cmd.Flags().Duration("interval", 5*time.Minute, "Resync interval")
+	cmd.Flags().String("kubecontext", "", "Kubeconfig context to use (default: current context)")
+	cmd.Flags().StringP("namespace", "n", "", "Only reconcile ECSMirror resources in this namespace (default: all namespaces)")
+	cmd.Flags().Bool("force-conflicts", false, "Take back fields of the applied objects that were changed by another field manager, such as kubectl edit, instead of failing the sync")
+	cmd.Flags().Bool("print-crd", false, "Print the ECSMirror and ConversionRecord CustomResourceDefinitions and exit")
+
+	return cmd
+}
+
+// run reconciles all mirrors every interval until interrupted
+func (o *mirrorOperator) run(interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("ecs2k8s operator started (resync interval: %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.reconcileAll(ctx); err != nil {
+			log.Printf("Error: Reconciliation failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Operator shutting down")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileAll lists ECSMirror resources and reconciles each of them
+func (o *mirrorOperator) reconcileAll(ctx context.Context) error {
+	args := []string{"get", ecsMirrorResource, "-o", "json"}
+	if o.namespace != "" {
+		args = append(args, "--namespace", o.namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+
+	out, err := o.kubectl.run(ctx, nil, args...)
+	if err != nil {
+		return err
+	}
+
+	var mirrors ecsMirrorList
+	if err := json.Unmarshal(out, &mirrors); err != nil {
+		return fmt.Errorf("failed to parse ECSMirror list: %w", err)
+	}
+
+	for _, mirror := range mirrors.Items {
+		if mirror.Spec.Suspend {
+			log.Printf("Info: ECSMirror %s/%s is suspended, skipping", mirror.Metadata.Namespace, mirror.Metadata.Name)
+			continue
+		}
+
+		status := o.reconcile(ctx, mirror)
+		if err := o.updateStatus(ctx, mirror, status); err != nil {
+			log.Printf("Warning: Failed to update status of ECSMirror %s/%s: %v", mirror.Metadata.Namespace, mirror.Metadata.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcile converts the services selected by a mirror and applies them
+func (o *mirrorOperator) reconcile(ctx context.Context, mirror ECSMirror) ECSMirrorStatus {
+	status := ECSMirrorStatus{LastSyncTime: time.Now().UTC().Format(time.RFC3339)}
+	spec := mirror.Spec
+
+	log.Printf("Reconciling ECSMirror %s/%s (cluster %s)", mirror.Metadata.Namespace, mirror.Metadata.Name, spec.Cluster)
+
+	if spec.Cluster == "" || spec.Region == "" {
+		status.Message = "spec.cluster and spec.region are required"
+		return status
+	}
+
+	targetNamespace := spec.TargetNamespace
+	if targetNamespace == "" {
+		targetNamespace = mirror.Metadata.Namespace
+	}
+
+	taskDefInfos, failures, cfg, err := o.fetch(ctx, spec)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	status.Failures = failures
+
+	// The same steps as convert with its default flags, so a mirrored service
+	// matches its converted manifests
+	pipeline := newServicePipeline(cfg, spec.Cluster, nil, mirrorOptions(spec.Region))
+	if err := pipeline.resolve(ctx, taskDefInfos); err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	docs := map[string]interface{}{}
+	for _, taskDefInfo := range taskDefInfos {
+		if err := pipeline.apply(ctx, taskDefInfo); err != nil {
+			status.Failures = append(status.Failures, err.Error())
+			continue
+		}
+		applyConfigChecksums(taskDefInfo)
+
+		files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+		if err != nil {
+			status.Failures = append(status.Failures, err.Error())
+			continue
+		}
+
+		for filename, doc := range files {
+			setManifestNamespace(doc, targetNamespace)
+			docs[filename] = doc
+		}
+		status.SyncedServices = append(status.SyncedServices, taskDefInfo.Name)
+	}
+
+	if len(docs) == 0 {
+		status.Message = "no services converted"
+		return status
+	}
+
+	stream, err := renderYAMLStream(docs)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+
+	if _, err := o.kubectl.apply(ctx, stream, targetNamespace, o.forceConflicts); err != nil {
+		status.Message = err.Error()
+		if !o.forceConflicts && strings.Contains(err.Error(), "conflict") {
+			status.Message += " (fields were changed by another field manager; revert them, or run the operator with --force-conflicts to take them back)"
+		}
+		status.SyncedServices = nil
+		return status
+	}
+
+	status.Message = fmt.Sprintf("applied %d object(s) for %d service(s) to namespace %s", len(docs), len(status.SyncedServices), targetNamespace)
+	log.Printf("✓ %s", status.Message)
+	return status
+}
+
+// mirrorOptions are the conversion options of the operator, the defaults of
+// the convert flags
+func mirrorOptions(region string) *runOptions {
+	return &runOptions{
+		region:          region,
+		imagePullPolicy: string(defaultImagePullPolicy),
+		awsEnv:          true,
+		probeSource:     probeSourceBoth,
+		config:          &ConversionConfig{Services: map[string]ServiceSettings{}},
+	}
+}
+
+// fetchMirrorTaskDefInfos reads the task definitions of the ECS services a
+// mirror selects
+func fetchMirrorTaskDefInfos(ctx context.Context, spec ECSMirrorSpec) ([]*TaskDefInfo, []string, *aws.Config, error) {
+	cfg, err := loadAWSConfig(ctx, spec.Region)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	ecsClient := ecs.NewFromConfig(cfg)
+
+	services, err := describeClusterServices(ctx, ecsClient, spec.Cluster)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	services = filterServicesByName(services, spec.Services)
+	readClusterSettings(ctx, ecsClient, spec.Cluster).applyDefaultStrategy(services)
+	servicesByTaskDef := servicesByTaskDefinition(services)
+
+	var taskDefInfos []*TaskDefInfo
+	var failures []string
+	for _, taskDefArn := range serviceTaskDefinitions(spec.Cluster, services) {
+		taskDefInfo, err := fetchTaskDefInfo(ctx, ecsClient, taskDefArn, servicesByTaskDef[taskDefArn])
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		taskDefInfos = append(taskDefInfos, taskDefInfo)
+	}
+	return taskDefInfos, failures, &cfg, nil
+}
+
+// updateStatus writes the reconciliation result to the ECSMirror status subresource
+func (o *mirrorOperator) updateStatus(ctx context.Context, mirror ECSMirror, status ECSMirrorStatus) error {
+	patch, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return fmt.Errorf("failed to marshal status patch: %w", err)
+	}
+
+	_, err = o.kubectl.run(ctx, nil,
+		"patch", ecsMirrorResource, mirror.Metadata.Name,
+		"--namespace", mirror.Metadata.Namespace,
+		"--subresource", "status",
+		"--type", "merge",
+		"--field-manager", fieldManager,
+		"--patch", string(patch),
+	)
+	return err
+}
+
+// filterServicesByName keeps only services whose name is in names (all when names is empty)
+func filterServicesByName(services []types.Service, names []string) []types.Service {
+	if len(names) == 0 {
+		return services
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var filtered []types.Service
+	for _, svc := range services {
+		if wanted[aws.ToString(svc.ServiceName)] {
+			filtered = append(filtered, svc)
+		}
+	}
+	return filtered
+}
+
+// ecsMirrorCRD is the CustomResourceDefinition for ECSMirror
+const ecsMirrorCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: ecsmirrors.ecs2k8s.io
+spec:
+  group: ecs2k8s.io
+  names:
+    kind: ECSMirror
+    listKind: ECSMirrorList
+    plural: ecsmirrors
+    singular: ecsmirror
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      subresources:
+        status: {}
+      additionalPrinterColumns:
+        - name: Cluster
+          type: string
+          jsonPath: .spec.cluster
+        - name: Last Sync
+          type: string
+          jsonPath: .status.lastSyncTime
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [region, cluster]
+              properties:
+                region:
+                  type: string
+                cluster:
+                  type: string
+                services:
+                  type: array
+                  items:
+                    type: string
+                targetNamespace:
+                  type: string
+                suspend:
+                  type: boolean
+            status:
+              type: object
+              properties:
+                lastSyncTime:
+                  type: string
+                syncedServices:
+                  type: array
+                  items:
+                    type: string
+                failures:
+                  type: array
+                  items:
+                    type: string
+                message:
+                  type: string
+`
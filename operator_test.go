@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// fakeMirrorKubectl serves the ECSMirror list and records status patches and
+// applied manifests
+type fakeMirrorKubectl struct {
+	mirrors  string
+	applyErr error
+	patches  map[string]ECSMirrorStatus
+	applied  string
+	forced   bool
+}
+
+func (k *fakeMirrorKubectl) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	switch args[0] {
+	case "get":
+		return []byte(k.mirrors), nil
+	case "patch":
+		var patch struct {
+			Status ECSMirrorStatus `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(args[len(args)-1]), &patch); err != nil {
+			return nil, err
+		}
+		if k.patches == nil {
+			k.patches = map[string]ECSMirrorStatus{}
+		}
+		k.patches[args[2]] = patch.Status
+		return nil, nil
+	}
+	return nil, errors.New("unexpected kubectl " + strings.Join(args, " "))
+}
+
+func (k *fakeMirrorKubectl) apply(ctx context.Context, manifests []byte, namespace string, forceConflicts bool) ([]byte, error) {
+	k.applied, k.forced = string(manifests), forceConflicts
+	return nil, k.applyErr
+}
+
+// mirrorFetcher returns converted task definitions of the given families
+func mirrorFetcher(t *testing.T, fetched *int, families ...string) func(context.Context, ECSMirrorSpec) ([]*TaskDefInfo, []string, *aws.Config, error) {
+	return func(ctx context.Context, spec ECSMirrorSpec) ([]*TaskDefInfo, []string, *aws.Config, error) {
+		*fetched++
+		var infos []*TaskDefInfo
+		for _, family := range families {
+			info, err := buildTaskDefInfo(appTaskDef(family), family)
+			if err != nil {
+				t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil, nil, nil
+	}
+}
+
+// TestFilterServicesByName tests selecting the services of a mirror
+func TestFilterServicesByName(t *testing.T) {
+	services := []types.Service{
+		{ServiceName: aws.String("web")},
+		{ServiceName: aws.String("api")},
+		{ServiceName: aws.String("worker")},
+	}
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"all", nil, []string{"web", "api", "worker"}},
+		{"selected", []string{"worker", "web"}, []string{"web", "worker"}},
+		{"unknown", []string{"billing"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, svc := range filterServicesByName(services, tt.names) {
+				got = append(got, aws.ToString(svc.ServiceName))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterServicesByName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReconcileAll tests that suspended mirrors are skipped and mirrors
+// without a cluster or region report it in their status
+func TestReconcileAll(t *testing.T) {
+	kubectl := &fakeMirrorKubectl{mirrors: `{"items": [
+	  {"metadata": {"name": "paused", "namespace": "team"}, "spec": {"region": "us-east-1", "cluster": "prod", "suspend": true}},
+	  {"metadata": {"name": "incomplete", "namespace": "team"}, "spec": {"cluster": "prod"}}
+	]}`}
+	fetched := 0
+	op := &mirrorOperator{kubectl: kubectl, fetch: mirrorFetcher(t, &fetched, "web")}
+
+	if err := op.reconcileAll(context.Background()); err != nil {
+		t.Fatalf("reconcileAll() error = %v", err)
+	}
+	if fetched != 0 || kubectl.applied != "" {
+		t.Errorf("fetched %d time(s), applied %q, want nothing", fetched, kubectl.applied)
+	}
+	if _, ok := kubectl.patches["paused"]; ok {
+		t.Error("suspended mirror status patched")
+	}
+	if got := kubectl.patches["incomplete"].Message; got != "spec.cluster and spec.region are required" {
+		t.Errorf("incomplete mirror message = %q, want the required fields", got)
+	}
+}
+
+// TestReconcile tests that a mirror applies the objects convert generates
+// and reports conflicts with other field managers
+func TestReconcile(t *testing.T) {
+	var mirror ECSMirror
+	mirror.Metadata.Name, mirror.Metadata.Namespace = "prod", "team"
+	mirror.Spec = ECSMirrorSpec{Region: "eu-west-1", Cluster: "prod", TargetNamespace: "apps"}
+
+	kubectl := &fakeMirrorKubectl{}
+	fetched := 0
+	op := &mirrorOperator{kubectl: kubectl, fetch: mirrorFetcher(t, &fetched, "web", "api")}
+
+	status := op.reconcile(context.Background(), mirror)
+	if !reflect.DeepEqual(status.SyncedServices, []string{"web", "api"}) || !strings.Contains(status.Message, "to namespace apps") {
+		t.Errorf("status = %+v, want web and api applied to apps", status)
+	}
+	for _, want := range []string{"namespace: apps", "name: AWS_REGION", "value: eu-west-1", "name: api-app-config"} {
+		if !strings.Contains(kubectl.applied, want) {
+			t.Errorf("applied manifests lack %q:\n%s", want, kubectl.applied)
+		}
+	}
+
+	kubectl.applyErr = errors.New("kubectl apply failed: Apply failed with 1 conflict: conflict with \"kubectl-edit\"")
+	status = op.reconcile(context.Background(), mirror)
+	if status.SyncedServices != nil || !strings.Contains(status.Message, "--force-conflicts") {
+		t.Errorf("status = %+v, want the conflict hint", status)
+	}
+
+	op.forceConflicts = true
+	status = op.reconcile(context.Background(), mirror)
+	if !kubectl.forced || strings.Contains(status.Message, "--force-conflicts") {
+		t.Errorf("forced = %v, message = %q, want a forced apply without the hint", kubectl.forced, status.Message)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// overridesDirName is the default overrides directory inside the output directory
+const overridesDirName = "overrides"
+
+// OverridePatch is a user-maintained partial manifest merged into a generated object
+type OverridePatch struct {
+	// Source is the file the patch was read from
+	Source string
+	Kind   string
+	Name   string
+	Patch  map[string]interface{}
+}
+
+// strategicMergeTypes maps built-in kinds to the structs carrying their
+// strategic merge keys; other kinds fall back to a JSON merge patch
+var strategicMergeTypes = map[string]func() interface{}{
+	"Deployment":         func() interface{} { return &appsv1.Deployment{} },
+	"StatefulSet":        func() interface{} { return &appsv1.StatefulSet{} },
+	"Service":            func() interface{} { return &corev1.Service{} },
+	"ConfigMap":          func() interface{} { return &corev1.ConfigMap{} },
+	"Secret":             func() interface{} { return &corev1.Secret{} },
+	"ServiceAccount":     func() interface{} { return &corev1.ServiceAccount{} },
+	"Ingress":            func() interface{} { return &networkingv1.Ingress{} },
+	"Role":               func() interface{} { return &rbacv1.Role{} },
+	"RoleBinding":        func() interface{} { return &rbacv1.RoleBinding{} },
+	"ClusterRole":        func() interface{} { return &rbacv1.ClusterRole{} },
+	"ClusterRoleBinding": func() interface{} { return &rbacv1.ClusterRoleBinding{} },
+}
+
+// loadOverrides reads override patches keyed by service name from
+// <dir>/<service>.yaml and <dir>/<service>/*.yaml. A missing directory yields
+// no overrides.
+func loadOverrides(dir string) (map[string][]OverridePatch, error) {
+	overrides := map[string][]OverridePatch{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return overrides, nil
+		}
+		return nil, fmt.Errorf("failed to read overrides directory %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			files, err := filepath.Glob(filepath.Join(path, "*.y*ml"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", path, err)
+			}
+			sort.Strings(files)
+			for _, file := range files {
+				patches, err := readOverrideFile(file)
+				if err != nil {
+					return nil, err
+				}
+				overrides[entry.Name()] = append(overrides[entry.Name()], patches...)
+			}
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		if ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		patches, err := readOverrideFile(path)
+		if err != nil {
+			return nil, err
+		}
+		service := strings.TrimSuffix(entry.Name(), ext)
+		overrides[service] = append(overrides[service], patches...)
+	}
+
+	return overrides, nil
+}
+
+// readOverrideFile decodes every YAML document of an override file
+func readOverrideFile(path string) ([]OverridePatch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open override %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var patches []OverridePatch
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse override %s: %w", path, err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		kind, _ := doc["kind"].(string)
+		metadata, _ := doc["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		if kind == "" || name == "" {
+			return nil, fmt.Errorf("override %s: every document needs kind and metadata.name", path)
+		}
+
+		patches = append(patches, OverridePatch{Source: path, Kind: kind, Name: name, Patch: doc})
+	}
+
+	return patches, nil
+}
+
+// applyOverrides merges override patches into rendered documents matched by kind and name
+func applyOverrides(files map[string]interface{}, patches []OverridePatch) error {
+	for _, patch := range patches {
+		matched := false
+
+		for _, filename := range sortedDocKeys(files) {
+			doc, ok := toSerializable(files[filename]).(map[string]interface{})
+			if !ok || doc["kind"] != patch.Kind {
+				continue
+			}
+			metadata, _ := doc["metadata"].(map[string]interface{})
+			if metadata["name"] != patch.Name {
+				continue
+			}
+
+			merged, err := mergeOverride(doc, patch)
+			if err != nil {
+				return err
+			}
+			files[filename] = merged
+			matched = true
+			log.Printf("Info: Applied override %s to %s %s", patch.Source, patch.Kind, patch.Name)
+		}
+
+		if !matched {
+			log.Printf("Warning: Override %s targets %s %s, which was not generated", patch.Source, patch.Kind, patch.Name)
+		}
+	}
+	return nil
+}
+
+// mergeOverride merges a patch into a document using a strategic merge for
+// built-in kinds and a JSON merge patch otherwise
+func mergeOverride(doc map[string]interface{}, patch OverridePatch) (map[string]interface{}, error) {
+	patchMap, ok := toSerializable(patch.Patch).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("override %s is not an object", patch.Source)
+	}
+
+	if newType, ok := strategicMergeTypes[patch.Kind]; ok {
+		merged, err := strategicpatch.StrategicMergeMapPatch(doc, patchMap, newType())
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply override %s: %w", patch.Source, err)
+		}
+		return merged, nil
+	}
+
+	return jsonMergePatch(doc, patchMap), nil
+}
+
+// jsonMergePatch applies an RFC 7386 merge patch: objects merge recursively,
+// null deletes a key and any other value replaces it
+func jsonMergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		patchObj, isObj := value.(map[string]interface{})
+		docObj, docIsObj := doc[key].(map[string]interface{})
+		if isObj && docIsObj {
+			doc[key] = jsonMergePatch(docObj, patchObj)
+			continue
+		}
+		doc[key] = value
+	}
+	return doc
+}
+
+// loadOutputOverrides reads the overrides of the output directory of a
+// cluster, from overridesDir when set
+func loadOutputOverrides(outputDir, overridesDir string) (map[string][]OverridePatch, error) {
+	// Overrides live next to the output by default so they survive regeneration
+	if overridesDir == "" {
+		overridesDir = filepath.Join(outputDir, overridesDirName)
+	}
+	overrides, err := loadOverrides(overridesDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(overrides) > 0 {
+		log.Printf("Loaded overrides for %d service(s) from %s", len(overrides), overridesDir)
+	}
+	return overrides, nil
+}
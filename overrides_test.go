@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestOverridesMergeIntoManifests tests that override files are merged into
+// the rendered manifests by kind and name
+func TestOverridesMergeIntoManifests(t *testing.T) {
+	dir := t.TempDir()
+	override := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          imagePullPolicy: Always
+`
+	if err := os.WriteFile(filepath.Join(dir, "web.yaml"), []byte(override), 0o644); err != nil {
+		t.Fatalf("failed to write override: %v", err)
+	}
+
+	overrides, err := loadOverrides(dir)
+	if err != nil {
+		t.Fatalf("loadOverrides() error = %v", err)
+	}
+	if len(overrides["web"]) != 1 {
+		t.Fatalf("loadOverrides() returned %d patches for web, want 1", len(overrides["web"]))
+	}
+
+	manifests, err := convertTaskDefToK8s(&types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("web"), Image: aws.String("nginx:latest")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("convertTaskDefToK8s() error = %v", err)
+	}
+	manifests.Overrides = overrides["web"]
+
+	files, err := renderManifests("web", manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+
+	deployment, ok := files["web-deployment.yaml"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("deployment not rendered: %T", files["web-deployment.yaml"])
+	}
+	spec := deployment["spec"].(map[string]interface{})
+	if spec["replicas"] != float64(3) {
+		t.Errorf("replicas = %v, want 3", spec["replicas"])
+	}
+
+	containers := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	if len(containers) != 1 {
+		t.Fatalf("strategic merge changed container count to %d", len(containers))
+	}
+	container := containers[0].(map[string]interface{})
+	if container["imagePullPolicy"] != "Always" || container["image"] == nil {
+		t.Errorf("container not merged by name: %+v", container)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// policyQuery is the Rego package policies define their rules in. Each
+// generated object is the input; deny and warn rules return messages.
+const policyQuery = "data.ecs2k8s"
+
+// PolicyResult holds the messages of the deny and warn rules for one object
+type PolicyResult struct {
+	Deny []string `json:"deny"`
+	Warn []string `json:"warn"`
+}
+
+// policyEngine evaluates organization guardrails written in Rego against the
+// generated objects (--policy), with the opa CLI
+type policyEngine struct {
+	// paths are the policy files and directories passed to opa as data
+	paths []string
+	// strict fails services violating a deny rule instead of reporting them (--strict)
+	strict bool
+	// eval evaluates the policies for one JSON input and returns opa's JSON output
+	eval func(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// newPolicyEngine checks the policy paths and that opa is installed. It
+// returns nil when no policies are given.
+func newPolicyEngine(paths []string, strict bool) (*policyEngine, error) {
+	if len(paths) == 0 {
+		if strict {
+			return nil, fmt.Errorf("--strict requires --policy")
+		}
+		return nil, nil
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read policy %s: %w", path, err)
+		}
+	}
+	if _, err := exec.LookPath("opa"); err != nil {
+		return nil, fmt.Errorf("--policy evaluates Rego with the opa CLI, which was not found in PATH: %w", err)
+	}
+
+	p := &policyEngine{paths: paths, strict: strict}
+	p.eval = p.opaEval
+	return p, nil
+}
+
+// opaEval runs opa eval with the policies as data and input on stdin
+func (p *policyEngine) opaEval(ctx context.Context, input []byte) ([]byte, error) {
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, path := range p.paths {
+		args = append(args, "--data", path)
+	}
+	args = append(args, policyQuery)
+
+	cmd := exec.CommandContext(ctx, "opa", args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("opa eval failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// evaluate returns the deny and warn messages of the policies for one object
+func (p *policyEngine) evaluate(ctx context.Context, object interface{}) (*PolicyResult, error) {
+	input, err := json.Marshal(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode policy input: %w", err)
+	}
+	out, err := p.eval(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return parseOPAResult(out)
+}
+
+// parseOPAResult reads the value of the policy package from opa eval's JSON
+// output. Packages without rules for an object yield no result.
+func parseOPAResult(out []byte) (*PolicyResult, error) {
+	var output struct {
+		Result []struct {
+			Expressions []struct {
+				Value PolicyResult `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse opa output: %w", err)
+	}
+
+	result := &PolicyResult{}
+	for _, r := range output.Result {
+		for _, e := range r.Expressions {
+			result.Deny = append(result.Deny, e.Value.Deny...)
+			result.Warn = append(result.Warn, e.Value.Warn...)
+		}
+	}
+	sort.Strings(result.Deny)
+	sort.Strings(result.Warn)
+	return result, nil
+}
+
+// check evaluates the policies against every object generated for a service.
+// Warnings and, unless strict, violations are added to the notes. It returns
+// the violations of deny rules.
+func (p *policyEngine) check(ctx context.Context, taskDefInfo *TaskDefInfo) ([]string, error) {
+	files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	var filenames []string
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var violations []string
+	for _, filename := range filenames {
+		result, err := p.evaluate(ctx, files[filename])
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate policies for %s: %w", filename, err)
+		}
+		for _, msg := range result.Warn {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Policy warning for %s: %s", filename, msg))
+		}
+		for _, msg := range result.Deny {
+			violations = append(violations, fmt.Sprintf("%s: %s", filename, msg))
+			if !p.strict {
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Policy violation in %s: %s", filename, msg))
+			}
+		}
+	}
+	return violations, nil
+}
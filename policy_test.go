@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPolicyEngine tests reporting deny and warn rules per generated object
+func TestPolicyEngine(t *testing.T) {
+	// Stands in for opa eval with policies denying LoadBalancer Services and
+	// warning about containers without limits
+	eval := func(_ context.Context, input []byte) ([]byte, error) {
+		var object map[string]interface{}
+		if err := json.Unmarshal(input, &object); err != nil {
+			return nil, err
+		}
+		value := PolicyResult{}
+		switch object["kind"] {
+		case "Service":
+			if object["spec"].(map[string]interface{})["type"] == "LoadBalancer" {
+				value.Deny = append(value.Deny, "no LoadBalancer Services")
+			}
+		case "Deployment":
+			value.Warn = append(value.Warn, "must have resource limits")
+		}
+		return json.Marshal(map[string]interface{}{
+			"result": []interface{}{map[string]interface{}{
+				"expressions": []interface{}{map[string]interface{}{"value": value, "text": policyQuery}},
+			}},
+		})
+	}
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+				Services: []*corev1.Service{{
+					ObjectMeta: metav1.ObjectMeta{Name: "web"},
+					Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: []corev1.ServicePort{{Port: 80}}},
+				}},
+			},
+		}
+	}
+
+	info := newInfo()
+	violations, err := (&policyEngine{eval: eval}).check(context.Background(), info)
+	if err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if len(violations) != 1 || violations[0] != "web-service.yaml: no LoadBalancer Services" {
+		t.Errorf("violations = %v", violations)
+	}
+	if !hasNote(info.Notes, "Policy violation in web-service.yaml: no LoadBalancer Services") || !hasNote(info.Notes, "Policy warning for web-deployment.yaml: must have resource limits") {
+		t.Errorf("notes = %v", info.Notes)
+	}
+
+	// In strict mode violations fail the service instead of being reported
+	strict := newInfo()
+	if violations, _ := (&policyEngine{eval: eval, strict: true}).check(context.Background(), strict); len(violations) != 1 || hasNote(strict.Notes, "Policy violation") {
+		t.Errorf("strict violations = %v, notes = %v", violations, strict.Notes)
+	}
+
+	if _, err := newPolicyEngine(nil, true); err == nil {
+		t.Errorf("--strict without --policy accepted")
+	}
+}
+
+// TestParseOPAResult tests reading opa eval output, including undefined packages
+func TestParseOPAResult(t *testing.T) {
+	result, err := parseOPAResult([]byte(`{"result":[{"expressions":[{"value":{"deny":["b","a"],"warn":[]},"text":"data.ecs2k8s"}]}]}`))
+	if err != nil {
+		t.Fatalf("parseOPAResult() error = %v", err)
+	}
+	if len(result.Deny) != 2 || result.Deny[0] != "a" || len(result.Warn) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+
+	if result, err := parseOPAResult([]byte(`{}`)); err != nil || len(result.Deny) != 0 {
+		t.Errorf("undefined result = %+v, %v", result, err)
+	}
+	if _, err := parseOPAResult([]byte(`not json`)); err == nil {
+		t.Errorf("invalid output parsed")
+	}
+}
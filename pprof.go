@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profiler writes the CPU and heap profiles of a run (--cpu-profile,
+// --mem-profile), to be read with go tool pprof
+type profiler struct {
+	cpu     *os.File
+	memPath string
+}
+
+// profiling is the profiler of the running command
+var profiling = &profiler{}
+
+// start starts CPU profiling to cpuPath and records memPath for stop. Empty
+// paths disable the profile.
+func (p *profiler) start(cpuPath, memPath string) error {
+	p.memPath = memPath
+	if cpuPath == "" {
+		return nil
+	}
+	f, err := os.Create(cpuPath)
+	if err != nil {
+		return fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	p.cpu = f
+	return nil
+}
+
+// stop finishes the CPU profile and writes the heap profile. Failures are
+// logged, since the run itself has already finished.
+func (p *profiler) stop() {
+	if p.cpu != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			log.Printf("Warning: Failed to write CPU profile: %v", err)
+		}
+		p.cpu = nil
+	}
+	if p.memPath == "" {
+		return
+	}
+
+	f, err := os.Create(p.memPath)
+	if err != nil {
+		log.Printf("Warning: Failed to create memory profile: %v", err)
+		return
+	}
+	defer f.Close()
+	// The allocs profile also holds the allocations of objects already freed,
+	// which is where large conversions spend their memory
+	runtime.GC()
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		log.Printf("Warning: Failed to write memory profile: %v", err)
+	}
+	p.memPath = ""
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	autoscalingtypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/spf13/cobra"
+)
+
+// preflightName names the placeholder resources the preflight calls refer to
+const preflightName = "ecs2k8s-preflight"
+
+// accessDeniedCodes are the error codes of AWS APIs refusing an action
+var accessDeniedCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+	"UnauthorizedException": true,
+}
+
+// PreflightCheck is the outcome of calling one API the conversion needs
+type PreflightCheck struct {
+	Action string
+	// Feature is the flag needing an optional action; empty when required
+	Feature string
+	Allowed bool
+	Detail  string
+}
+
+// preflightProbe calls an API to find out whether its action is allowed
+type preflightProbe struct {
+	action  string
+	feature string
+	call    func(ctx context.Context) error
+}
+
+// preflightOptions holds the inputs of the preflight subcommand
+type preflightOptions struct {
+	region      string
+	cluster     string
+	printPolicy bool
+}
+
+// newPreflightCommand creates the `preflight` subcommand
+func newPreflightCommand() *cobra.Command {
+	opts := &preflightOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check the AWS permissions of the caller before a conversion",
+		Long: `preflight calls every read-only AWS API a conversion uses, with placeholder
+resources or DryRun where the API has one, and lists whether each action is
+allowed. Nothing is created or changed.
+
+When an action is denied, a minimal IAM policy granting every action ecs2k8s
+uses is printed, ready to attach to the caller. Actions needed only by some
+flags (--rightsize, --convert-alarms, --create-keda, --decommission-plan,
+--step-functions) are
+reported but don't fail the check. The secrets read by --resolve-secrets are
+not probed, since their permissions are scoped to the secrets themselves.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPreflight(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (default: from the AWS configuration)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster the cluster-scoped calls refer to (default: default)")
+	cmd.Flags().BoolVar(&opts.printPolicy, "print-policy", false, "Print the minimal IAM policy even when every action is allowed")
+
+	return cmd
+}
+
+// runPreflight executes the preflight subcommand
+func runPreflight(opts *preflightOptions) error {
+	ctx := context.Background()
+
+	if opts.region != "" {
+		if err := validateRegion(opts.region); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadAWSConfig(ctx, opts.region)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return fmt.Errorf("no AWS region configured; pass --region")
+	}
+
+	// GetCallerIdentity needs no permission, so it only fails without credentials
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("AWS credentials not configured or invalid: %w", err)
+	}
+	callerArn := aws.ToString(identity.Arn)
+	fmt.Fprintf(os.Stdout, "Caller: %s (region %s)\n\n", callerArn, cfg.Region)
+
+	cluster := opts.cluster
+	if cluster == "" {
+		cluster = "default"
+	}
+	var checks []PreflightCheck
+	for _, probe := range preflightProbes(cfg, cluster, arnPartition(callerArn), aws.ToString(identity.Account)) {
+		allowed, detail := preflightResult(probe.call(ctx))
+		checks = append(checks, PreflightCheck{Action: probe.action, Feature: probe.feature, Allowed: allowed, Detail: detail})
+	}
+
+	denied := printPreflightSummary(os.Stdout, checks)
+	if len(denied) > 0 || opts.printPolicy {
+		policy, err := preflightPolicy(checks)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "\nMinimal IAM policy for ecs2k8s:\n\n%s\n", policy)
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("preflight failed: %s denied", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// preflightProbes returns the calls checking each action. Calls on single
+// resources refer to placeholders in the caller's account, so resource-level
+// policies are evaluated as for real resources and the call fails with a
+// not-found error once it is authorized.
+func preflightProbes(cfg aws.Config, cluster, partition, account string) []preflightProbe {
+	ecsClient := ecs.NewFromConfig(cfg)
+	elbClient := elbv2.NewFromConfig(cfg)
+	ec2Client := ec2.NewFromConfig(cfg)
+	autoscalingClient := applicationautoscaling.NewFromConfig(cfg)
+	cloudwatchClient := cloudwatch.NewFromConfig(cfg)
+	statesClient := newStepFunctionsClient(cfg)
+
+	elbArn := func(resource string) string {
+		return fmt.Sprintf("arn:%s:elasticloadbalancing:%s:%s:%s", partition, cfg.Region, account, resource)
+	}
+	listenerArn := elbArn("listener/app/" + preflightName + "/0000000000000000/0000000000000000")
+	targetGroupArn := elbArn("targetgroup/" + preflightName + "/0000000000000000")
+	taskDefArn := fmt.Sprintf("arn:%s:ecs:%s:%s:task-definition/%s:1", partition, cfg.Region, account, preflightName)
+	stateMachineArn := fmt.Sprintf("arn:%s:states:%s:%s:stateMachine:%s", partition, cfg.Region, account, preflightName)
+
+	return []preflightProbe{
+		{action: "ecs:ListClusters", call: func(ctx context.Context) error {
+			_, err := ecsClient.ListClusters(ctx, &ecs.ListClustersInput{MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "ecs:DescribeClusters", call: func(ctx context.Context) error {
+			_, err := ecsClient.DescribeClusters(ctx, &ecs.DescribeClustersInput{Clusters: []string{cluster}})
+			return err
+		}},
+		{action: "ecs:ListServices", call: func(ctx context.Context) error {
+			_, err := ecsClient.ListServices(ctx, &ecs.ListServicesInput{Cluster: aws.String(cluster), MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "ecs:DescribeServices", call: func(ctx context.Context) error {
+			_, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{Cluster: aws.String(cluster), Services: []string{preflightName}})
+			return err
+		}},
+		{action: "ecs:DescribeTaskDefinition", call: func(ctx context.Context) error {
+			_, err := ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(taskDefArn)})
+			return err
+		}},
+		{action: "ecs:DescribeCapacityProviders", call: func(ctx context.Context) error {
+			_, err := ecsClient.DescribeCapacityProviders(ctx, &ecs.DescribeCapacityProvidersInput{MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "ecs:ListTasks", call: func(ctx context.Context) error {
+			_, err := ecsClient.ListTasks(ctx, &ecs.ListTasksInput{Cluster: aws.String(cluster), MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "ecs:GetTaskProtection", call: func(ctx context.Context) error {
+			_, err := ecsClient.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{Cluster: aws.String(cluster), Tasks: []string{"00000000000000000000000000000000"}})
+			return err
+		}},
+		{action: "elasticloadbalancing:DescribeTargetGroups", call: func(ctx context.Context) error {
+			_, err := elbClient.DescribeTargetGroups(ctx, &elbv2.DescribeTargetGroupsInput{PageSize: aws.Int32(1)})
+			return err
+		}},
+		{action: "elasticloadbalancing:DescribeListeners", call: func(ctx context.Context) error {
+			_, err := elbClient.DescribeListeners(ctx, &elbv2.DescribeListenersInput{ListenerArns: []string{listenerArn}})
+			return err
+		}},
+		{action: "elasticloadbalancing:DescribeRules", call: func(ctx context.Context) error {
+			_, err := elbClient.DescribeRules(ctx, &elbv2.DescribeRulesInput{ListenerArn: aws.String(listenerArn)})
+			return err
+		}},
+		{action: "ec2:DescribeSubnets", call: func(ctx context.Context) error {
+			_, err := ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{DryRun: aws.Bool(true)})
+			return err
+		}},
+		{action: "application-autoscaling:DescribeScalableTargets", feature: "--create-keda", call: func(ctx context.Context) error {
+			_, err := autoscalingClient.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{ServiceNamespace: autoscalingtypes.ServiceNamespaceEcs, MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "application-autoscaling:DescribeScalingPolicies", feature: "--create-keda", call: func(ctx context.Context) error {
+			_, err := autoscalingClient.DescribeScalingPolicies(ctx, &applicationautoscaling.DescribeScalingPoliciesInput{ServiceNamespace: autoscalingtypes.ServiceNamespaceEcs, MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{action: "cloudwatch:GetMetricData", feature: "--rightsize", call: func(ctx context.Context) error {
+			// An empty query fails validation, which is checked after authorization
+			_, err := cloudwatchClient.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{})
+			return err
+		}},
+		{action: "cloudwatch:DescribeAlarmsForMetric", feature: "--convert-alarms", call: func(ctx context.Context) error {
+			_, err := cloudwatchClient.DescribeAlarmsForMetric(ctx, &cloudwatch.DescribeAlarmsForMetricInput{Namespace: aws.String("AWS/ECS"), MetricName: aws.String("CPUUtilization")})
+			return err
+		}},
+		{action: "ecs:ListTagsForResource", feature: "--decommission-plan", call: func(ctx context.Context) error {
+			_, err := ecsClient.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{ResourceArn: aws.String(taskDefArn)})
+			return err
+		}},
+		{action: "elasticloadbalancing:DescribeTags", feature: "--decommission-plan", call: func(ctx context.Context) error {
+			_, err := elbClient.DescribeTags(ctx, &elbv2.DescribeTagsInput{ResourceArns: []string{targetGroupArn}})
+			return err
+		}},
+		{action: "ec2:DescribeSecurityGroups", feature: "--zero-trust", call: func(ctx context.Context) error {
+			_, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{DryRun: aws.Bool(true)})
+			return err
+		}},
+		{action: "states:ListStateMachines", feature: "--step-functions", call: func(ctx context.Context) error {
+			_, err := statesClient.listStateMachines(ctx)
+			return err
+		}},
+		{action: "states:DescribeStateMachine", feature: "--step-functions", call: func(ctx context.Context) error {
+			_, err := statesClient.describeStateMachine(ctx, stateMachineArn)
+			return err
+		}},
+	}
+}
+
+// preflightResult interprets the error of a probe. Any error other than a
+// denial, e.g. a placeholder not being found, means the action is allowed.
+func preflightResult(err error) (bool, string) {
+	if err == nil {
+		return true, ""
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false, err.Error()
+	}
+	code := apiErr.ErrorCode()
+	switch {
+	case accessDeniedCodes[code]:
+		return false, apiErr.ErrorMessage()
+	case code == "DryRunOperation":
+		return true, ""
+	}
+	return true, "call returned " + code
+}
+
+// arnPartition returns the partition of an ARN, e.g. aws-cn
+func arnPartition(arn string) string {
+	if parts := strings.SplitN(arn, ":", 3); len(parts) == 3 && parts[1] != "" {
+		return parts[1]
+	}
+	return "aws"
+}
+
+// partitionDNSSuffix returns the DNS suffix of the service endpoints of a
+// partition, e.g. amazonaws.com.cn for aws-cn
+func partitionDNSSuffix(partition string) string {
+	switch partition {
+	case "aws-cn":
+		return "amazonaws.com.cn"
+	case "aws-iso":
+		return "c2s.ic.gov"
+	case "aws-iso-b":
+		return "sc2s.sgov.gov"
+	}
+	return "amazonaws.com"
+}
+
+// printPreflightSummary writes the checks as a table and returns the denied
+// required actions
+func printPreflightSummary(w io.Writer, checks []PreflightCheck) []string {
+	var denied []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tNEEDED BY\tRESULT\tDETAIL")
+	for _, check := range checks {
+		feature := check.Feature
+		if feature == "" {
+			feature = "conversion"
+		}
+		result := "allowed"
+		if !check.Allowed {
+			result = "DENIED"
+			if check.Feature == "" {
+				denied = append(denied, check.Action)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", check.Action, feature, result, check.Detail)
+	}
+	tw.Flush()
+	return denied
+}
+
+// preflightPolicy returns an IAM policy granting every checked action
+func preflightPolicy(checks []PreflightCheck) (string, error) {
+	var actions []string
+	for _, check := range checks {
+		actions = append(actions, check.Action)
+	}
+	sort.Strings(actions)
+
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Sid":      "Ecs2k8sReadOnly",
+			"Effect":   "Allow",
+			"Action":   actions,
+			"Resource": "*",
+		}},
+	}
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to render the IAM policy: %w", err)
+	}
+	return string(data), nil
+}
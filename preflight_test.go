@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+// TestPreflightResult tests telling denied actions from other API errors
+func TestPreflightResult(t *testing.T) {
+	tests := []struct {
+		err     error
+		allowed bool
+	}{
+		{nil, true},
+		{&smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform: ecs:ListClusters"}, false},
+		{&smithy.GenericAPIError{Code: "UnauthorizedOperation"}, false},
+		{&smithy.GenericAPIError{Code: "DryRunOperation"}, true},
+		{&smithy.GenericAPIError{Code: "ClusterNotFoundException"}, true},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if allowed, _ := preflightResult(tt.err); allowed != tt.allowed {
+			t.Errorf("preflightResult(%v) = %v, want %v", tt.err, allowed, tt.allowed)
+		}
+	}
+
+	if got := arnPartition("arn:aws-cn:iam::123456789012:user/ops"); got != "aws-cn" {
+		t.Errorf("arnPartition() = %q, want aws-cn", got)
+	}
+}
+
+// TestPreflightSummary tests the summary and the policy of the checks
+func TestPreflightSummary(t *testing.T) {
+	checks := []PreflightCheck{
+		{Action: "ecs:ListClusters", Allowed: true},
+		{Action: "elasticloadbalancing:DescribeRules", Detail: "not authorized"},
+		{Action: "cloudwatch:GetMetricData", Feature: "--rightsize"},
+	}
+
+	var out bytes.Buffer
+	denied := printPreflightSummary(&out, checks)
+	if !reflect.DeepEqual(denied, []string{"elasticloadbalancing:DescribeRules"}) {
+		t.Errorf("denied = %v, want only the required action", denied)
+	}
+	if !strings.Contains(out.String(), "--rightsize") || !strings.Contains(out.String(), "DENIED") {
+		t.Errorf("printPreflightSummary() = %q", out.String())
+	}
+
+	policy, err := preflightPolicy(checks)
+	if err != nil {
+		t.Fatalf("preflightPolicy() error = %v", err)
+	}
+	var parsed struct {
+		Statement []struct {
+			Action   []string
+			Resource string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &parsed); err != nil {
+		t.Fatalf("policy is not JSON: %v", err)
+	}
+	want := []string{"cloudwatch:GetMetricData", "ecs:ListClusters", "elasticloadbalancing:DescribeRules"}
+	if len(parsed.Statement) != 1 || !reflect.DeepEqual(parsed.Statement[0].Action, want) || parsed.Statement[0].Resource != "*" {
+		t.Errorf("policy = %s, want every action sorted", policy)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// probeAllContainers is the probes key matching every container of a service
+const probeAllContainers = "*"
+
+// ContainerProbes holds the probe templates of a container
+type ContainerProbes struct {
+	Liveness  *ProbeSpec `yaml:"liveness,omitempty"`
+	Readiness *ProbeSpec `yaml:"readiness,omitempty"`
+}
+
+// ProbeSpec is a probe template; exactly one of HTTPGet and TCPSocket is set
+type ProbeSpec struct {
+	HTTPGet             *HTTPGetProbe   `yaml:"httpGet,omitempty"`
+	TCPSocket           *TCPSocketProbe `yaml:"tcpSocket,omitempty"`
+	InitialDelaySeconds int32           `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int32           `yaml:"periodSeconds,omitempty"`
+	TimeoutSeconds      int32           `yaml:"timeoutSeconds,omitempty"`
+	SuccessThreshold    int32           `yaml:"successThreshold,omitempty"`
+	FailureThreshold    int32           `yaml:"failureThreshold,omitempty"`
+}
+
+// HTTPGetProbe checks an HTTP path. Port defaults to the container's first port.
+type HTTPGetProbe struct {
+	Path   string `yaml:"path"`
+	Port   int32  `yaml:"port,omitempty"`
+	Scheme string `yaml:"scheme,omitempty"`
+}
+
+// TCPSocketProbe checks that a port accepts connections. Port defaults to the
+// container's first port.
+type TCPSocketProbe struct {
+	Port int32 `yaml:"port,omitempty"`
+}
+
+// validate checks that a probe template is usable
+func (p *ProbeSpec) validate() error {
+	if p == nil {
+		return nil
+	}
+	if (p.HTTPGet == nil) == (p.TCPSocket == nil) {
+		return fmt.Errorf("exactly one of httpGet and tcpSocket must be set")
+	}
+
+	port := int32(0)
+	if p.HTTPGet != nil {
+		port = p.HTTPGet.Port
+		if p.HTTPGet.Scheme != "" && p.HTTPGet.Scheme != string(corev1.URISchemeHTTP) && p.HTTPGet.Scheme != string(corev1.URISchemeHTTPS) {
+			return fmt.Errorf("httpGet.scheme must be HTTP or HTTPS (got %q)", p.HTTPGet.Scheme)
+		}
+	} else {
+		port = p.TCPSocket.Port
+	}
+	if port < 0 || port > 65535 {
+		return fmt.Errorf("port %d is out of range", port)
+	}
+
+	for name, value := range map[string]int32{
+		"initialDelaySeconds": p.InitialDelaySeconds,
+		"periodSeconds":       p.PeriodSeconds,
+		"timeoutSeconds":      p.TimeoutSeconds,
+		"successThreshold":    p.SuccessThreshold,
+		"failureThreshold":    p.FailureThreshold,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+// buildProbe converts a probe template into a Kubernetes probe, using
+// defaultPort when the template has no port
+func buildProbe(spec *ProbeSpec, defaultPort int32) (*corev1.Probe, error) {
+	probe := &corev1.Probe{
+		InitialDelaySeconds: spec.InitialDelaySeconds,
+		PeriodSeconds:       spec.PeriodSeconds,
+		TimeoutSeconds:      spec.TimeoutSeconds,
+		SuccessThreshold:    spec.SuccessThreshold,
+		FailureThreshold:    spec.FailureThreshold,
+	}
+
+	switch {
+	case spec.HTTPGet != nil:
+		port := spec.HTTPGet.Port
+		if port == 0 {
+			port = defaultPort
+		}
+		if port == 0 {
+			return nil, fmt.Errorf("httpGet probe has no port and the container exposes none")
+		}
+		path := spec.HTTPGet.Path
+		if path == "" {
+			path = "/"
+		}
+		probe.HTTPGet = &corev1.HTTPGetAction{
+			Path:   path,
+			Port:   intstr.FromInt32(port),
+			Scheme: corev1.URIScheme(spec.HTTPGet.Scheme),
+		}
+	case spec.TCPSocket != nil:
+		port := spec.TCPSocket.Port
+		if port == 0 {
+			port = defaultPort
+		}
+		if port == 0 {
+			return nil, fmt.Errorf("tcpSocket probe has no port and the container exposes none")
+		}
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt32(port)}
+	}
+
+	return probe, nil
+}
+
+// applyProbes injects the configured probes into the containers of a converted
+// task definition. A container-specific entry takes precedence over "*".
+func applyProbes(taskDefInfo *TaskDefInfo, probes map[string]ContainerProbes) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || len(probes) == 0 {
+		return
+	}
+
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		settings, ok := probes[c.Name]
+		if !ok {
+			settings, ok = probes[probeAllContainers]
+		}
+		if !ok {
+			continue
+		}
+
+		defaultPort := int32(0)
+		if len(c.Ports) > 0 {
+			defaultPort = c.Ports[0].ContainerPort
+		}
+
+		if settings.Liveness != nil {
+			probe, err := buildProbe(settings.Liveness, defaultPort)
+			if err != nil {
+				log.Printf("Warning: Skipping liveness probe for %s/%s: %v", taskDefInfo.Name, c.Name, err)
+			} else {
+				c.LivenessProbe = probe
+			}
+		}
+		if settings.Readiness != nil {
+			probe, err := buildProbe(settings.Readiness, defaultPort)
+			if err != nil {
+				log.Printf("Warning: Skipping readiness probe for %s/%s: %v", taskDefInfo.Name, c.Name, err)
+			} else {
+				c.ReadinessProbe = probe
+			}
+		}
+	}
+
+	syncContainerProbes(taskDefInfo)
+}
+
+// syncContainerProbes copies the pod spec probes into the container configs used for Helm values
+func syncContainerProbes(taskDefInfo *TaskDefInfo) {
+	for _, c := range taskDefInfo.Manifests.Deployment.Containers {
+		for i := range taskDefInfo.Containers {
+			if taskDefInfo.Containers[i].Name != c.Name {
+				continue
+			}
+			taskDefInfo.Containers[i].LivenessProbe = c.LivenessProbe
+			taskDefInfo.Containers[i].ReadinessProbe = c.ReadinessProbe
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyProbes tests probe injection by container name with a "*" fallback
+func TestApplyProbes(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{
+		Name:       "web",
+		Containers: []ContainerConfig{{Name: "app"}, {Name: "sidecar"}},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}},
+					{Name: "sidecar", Ports: []corev1.ContainerPort{{ContainerPort: 9901}}},
+				},
+			},
+		},
+	}
+
+	applyProbes(taskDefInfo, map[string]ContainerProbes{
+		"app": {
+			Readiness: &ProbeSpec{HTTPGet: &HTTPGetProbe{Path: "/healthz"}, InitialDelaySeconds: 5},
+			Liveness:  &ProbeSpec{HTTPGet: &HTTPGetProbe{Path: "/livez", Port: 8081}},
+		},
+		probeAllContainers: {
+			Readiness: &ProbeSpec{TCPSocket: &TCPSocketProbe{}},
+		},
+	})
+
+	app := taskDefInfo.Manifests.Deployment.Containers[0]
+	if app.ReadinessProbe == nil || app.ReadinessProbe.HTTPGet == nil {
+		t.Fatalf("app readiness probe = %+v, want httpGet", app.ReadinessProbe)
+	}
+	if got := app.ReadinessProbe.HTTPGet; got.Path != "/healthz" || got.Port.IntValue() != 8080 {
+		t.Errorf("app readiness httpGet = %s:%d, want /healthz:8080", got.Path, got.Port.IntValue())
+	}
+	if app.ReadinessProbe.InitialDelaySeconds != 5 {
+		t.Errorf("app readiness initialDelaySeconds = %d, want 5", app.ReadinessProbe.InitialDelaySeconds)
+	}
+	if app.LivenessProbe == nil || app.LivenessProbe.HTTPGet.Port.IntValue() != 8081 {
+		t.Errorf("app liveness probe = %+v, want port 8081", app.LivenessProbe)
+	}
+
+	sidecar := taskDefInfo.Manifests.Deployment.Containers[1]
+	if sidecar.ReadinessProbe == nil || sidecar.ReadinessProbe.TCPSocket == nil || sidecar.ReadinessProbe.TCPSocket.Port.IntValue() != 9901 {
+		t.Errorf("sidecar readiness probe = %+v, want tcpSocket 9901", sidecar.ReadinessProbe)
+	}
+	if sidecar.LivenessProbe != nil {
+		t.Errorf("sidecar liveness probe = %+v, want none", sidecar.LivenessProbe)
+	}
+
+	if taskDefInfo.Containers[0].ReadinessProbe != app.ReadinessProbe {
+		t.Errorf("container config probes not synced")
+	}
+}
+
+// TestProbeSpecValidate tests probe template validation
+func TestProbeSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    *ProbeSpec
+		wantErr bool
+	}{
+		{name: "nil", spec: nil},
+		{name: "http", spec: &ProbeSpec{HTTPGet: &HTTPGetProbe{Path: "/", Port: 80}}},
+		{name: "tcp", spec: &ProbeSpec{TCPSocket: &TCPSocketProbe{}}},
+		{name: "no handler", spec: &ProbeSpec{}, wantErr: true},
+		{name: "both handlers", spec: &ProbeSpec{HTTPGet: &HTTPGetProbe{}, TCPSocket: &TCPSocketProbe{}}, wantErr: true},
+		{name: "bad port", spec: &ProbeSpec{TCPSocket: &TCPSocketProbe{Port: 70000}}, wantErr: true},
+		{name: "bad scheme", spec: &ProbeSpec{HTTPGet: &HTTPGetProbe{Scheme: "ftp"}}, wantErr: true},
+		{name: "negative delay", spec: &ProbeSpec{TCPSocket: &TCPSocketProbe{}, InitialDelaySeconds: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.spec.validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
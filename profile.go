@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Conversion profiles of the supported target platforms
+const (
+	profileEKSFargate = "eks-fargate"
+	profileEKSManaged = "eks-managed"
+	profileGKE        = "gke"
+	profileAKS        = "aks"
+	profileOpenShift  = "openshift"
+)
+
+// profileNames lists the valid --profile values
+var profileNames = []string{profileEKSFargate, profileEKSManaged, profileGKE, profileAKS, profileOpenShift}
+
+// Pod Security Standard enforced by a namespace, see
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/
+const podSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// openShiftPrivilegedSCCRole grants the privileged SecurityContextConstraints
+const openShiftPrivilegedSCCRole = "system:openshift:scc:privileged"
+
+// platformProfile adjusts the generated manifests to a target platform
+type platformProfile struct {
+	Name string
+	// EKS reports whether the platform is EKS, which AWS-only features such as
+	// Karpenter and target group bindings require
+	EKS bool
+	// Fargate pods cannot share host namespaces
+	Fargate bool
+	// IdentityAnnotation binds a ServiceAccount to a cloud identity; its value
+	// is derived from the ECS role ARN by identityValue
+	IdentityAnnotation string
+	identityValue      func(roleARN string) string
+	// IdentityPodLabels are set on pods using a workload identity
+	IdentityPodLabels map[string]string
+	// IngressClass replaces the ALB ingress class
+	IngressClass string
+	// Routes replaces the Ingress with OpenShift Routes
+	Routes bool
+	// StorageClass is recommended for converting EFS volumes
+	StorageClass string
+	// SCC binds pods that need host namespaces to the privileged OpenShift
+	// SecurityContextConstraints instead of labelling the namespace
+	SCC bool
+}
+
+// platformProfiles are the presets selected with --profile
+var platformProfiles = map[string]*platformProfile{
+	profileEKSFargate: {
+		Name:               profileEKSFargate,
+		EKS:                true,
+		Fargate:            true,
+		IdentityAnnotation: irsaAnnotation,
+		IngressClass:       "alb",
+		StorageClass:       "efs-sc",
+	},
+	profileEKSManaged: {
+		Name:               profileEKSManaged,
+		EKS:                true,
+		IdentityAnnotation: irsaAnnotation,
+		IngressClass:       "alb",
+		StorageClass:       "efs-sc",
+	},
+	profileGKE: {
+		Name:               profileGKE,
+		IdentityAnnotation: "iam.gke.io/gcp-service-account",
+		// Google service account IDs are 6-30 characters
+		identityValue: func(roleARN string) string {
+			id := sanitizeName(roleName(roleARN))
+			if len(id) > 30 {
+				id = strings.TrimRight(id[:30], "-")
+			}
+			return id + "@PROJECT_ID.iam.gserviceaccount.com"
+		},
+		IngressClass: "gce",
+		StorageClass: "standard-rwx",
+	},
+	profileAKS: {
+		Name:               profileAKS,
+		IdentityAnnotation: "azure.workload.identity/client-id",
+		identityValue:      func(string) string { return "CLIENT_ID" },
+		IdentityPodLabels:  map[string]string{"azure.workload.identity/use": "true"},
+		IngressClass:       "webapprouting.kubernetes.azure.com",
+		StorageClass:       "azurefile-csi",
+	},
+	profileOpenShift: {
+		Name: profileOpenShift,
+		// ROSA binds IAM roles with the same annotation as IRSA
+		IdentityAnnotation: irsaAnnotation,
+		Routes:             true,
+		StorageClass:       "efs-sc",
+		SCC:                true,
+	},
+}
+
+// lookupProfile returns the profile named by --profile, or nil when unset
+func lookupProfile(name string) (*platformProfile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := platformProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid --profile %q (must be one of: %s)", name, strings.Join(profileNames, ", "))
+	}
+	return profile, nil
+}
+
+// apply adjusts a service's identity, ingress and pod security settings to the
+// platform and records what remains to be done in its notes
+func (p *platformProfile) apply(taskDefInfo *TaskDefInfo, identities identityMap) {
+	if p == nil {
+		return
+	}
+
+	p.applyIdentity(taskDefInfo, identities)
+	if p.Routes {
+		ingressToRoutes(taskDefInfo)
+	} else {
+		p.applyIngress(taskDefInfo)
+	}
+	p.applyPodSecurity(taskDefInfo)
+
+	if taskDefInfo.Source != nil {
+		for _, volume := range taskDefInfo.Source.Volumes {
+			if volume.EfsVolumeConfiguration != nil {
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("EFS volume %s: create a ReadWriteMany PersistentVolumeClaim with StorageClass %s", aws.ToString(volume.Name), p.StorageClass))
+			}
+		}
+	}
+}
+
+// applyIdentity moves the IRSA role of the ServiceAccount to the platform's
+// workload identity annotation, using the identity mapped to the role or else
+// a placeholder
+func (p *platformProfile) applyIdentity(taskDefInfo *TaskDefInfo, identities identityMap) {
+	sa := taskDefInfo.Manifests.ServiceAccount
+	if sa == nil || sa.Annotations[irsaAnnotation] == "" || p.IdentityAnnotation == irsaAnnotation {
+		return
+	}
+
+	roleARN := sa.Annotations[irsaAnnotation]
+	identity, mapped := identities.lookup(roleARN)
+	if !mapped {
+		identity = p.identityValue(roleARN)
+	}
+	delete(sa.Annotations, irsaAnnotation)
+	sa.Annotations[p.IdentityAnnotation] = identity
+
+	for key, value := range p.IdentityPodLabels {
+		if taskDefInfo.Manifests.PodLabels == nil {
+			taskDefInfo.Manifests.PodLabels = map[string]string{}
+		}
+		taskDefInfo.Manifests.PodLabels[key] = value
+	}
+
+	taskDefInfo.Identity = &IdentityBinding{
+		ServiceAccount: sa.Name,
+		RoleARN:        roleARN,
+		Annotation:     p.IdentityAnnotation,
+		Identity:       identity,
+		Mapped:         mapped,
+	}
+}
+
+// applyIngress sets the platform's ingress class and drops the AWS Load
+// Balancer Controller annotations outside EKS
+func (p *platformProfile) applyIngress(taskDefInfo *TaskDefInfo) {
+	for _, extra := range taskDefInfo.Manifests.Extras {
+		if extra.Object["kind"] != "Ingress" {
+			continue
+		}
+		if spec, ok := extra.Object["spec"].(map[string]interface{}); ok {
+			spec["ingressClassName"] = p.IngressClass
+		}
+		if p.EKS {
+			continue
+		}
+
+		metadata, _ := extra.Object["metadata"].(map[string]interface{})
+		switch annotations := metadata["annotations"].(type) {
+		case map[string]string:
+			for key := range annotations {
+				if strings.HasPrefix(key, "alb.ingress.kubernetes.io/") {
+					delete(annotations, key)
+				}
+			}
+		case map[string]interface{}:
+			for key := range annotations {
+				if strings.HasPrefix(key, "alb.ingress.kubernetes.io/") {
+					delete(annotations, key)
+				}
+			}
+		}
+	}
+}
+
+// applyPodSecurity reports pods sharing host namespaces, which need the
+// privileged Pod Security level, and binds them to the privileged SCC on
+// OpenShift. Other OpenShift pods get a securityContext admitted by restricted-v2.
+func (p *platformProfile) applyPodSecurity(taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return
+	}
+	if !podSpec.HostIPC && !podSpec.HostPID {
+		if p.SCC {
+			applyRestrictedSecurityContext(taskDefInfo)
+			for _, violation := range restrictedV2Violations(taskDefInfo.Source) {
+				taskDefInfo.Notes = append(taskDefInfo.Notes, "restricted-v2 SCC: "+violation)
+			}
+		}
+		return
+	}
+
+	switch {
+	case p.Fargate:
+		taskDefInfo.Notes = append(taskDefInfo.Notes, "Fargate does not run pods sharing host namespaces (hostIPC/hostPID); schedule this service on a managed node group")
+	case p.SCC:
+		sa := taskDefInfo.Manifests.ServiceAccount
+		if sa == nil {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, "Pods share host namespaces but have no ServiceAccount to bind to the privileged SCC")
+			return
+		}
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{
+			Suffix: "scc-rolebinding",
+			Object: map[string]interface{}{
+				"apiVersion": apiVersionRBAC,
+				"kind":       "RoleBinding",
+				"metadata": map[string]interface{}{
+					"name":   taskDefInfo.Name + "-scc-privileged",
+					"labels": map[string]string{"app": taskDefInfo.Name},
+				},
+				"subjects": []map[string]interface{}{
+					{"kind": rbacv1.ServiceAccountKind, "name": sa.Name, "namespace": sa.Namespace},
+				},
+				"roleRef": map[string]interface{}{
+					"apiGroup": rbacv1.GroupName,
+					"kind":     "ClusterRole",
+					"name":     openShiftPrivilegedSCCRole,
+				},
+			},
+		})
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Pods share host namespaces; ServiceAccount %s is bound to the privileged SCC", sa.Name))
+	default:
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Pods share host namespaces; label the namespace %s=privileged", podSecurityEnforceLabel))
+	}
+}
+
+// roleName returns the name of an IAM role ARN, without its path
+func roleName(roleARN string) string {
+	return roleARN[strings.LastIndex(roleARN, "/")+1:]
+}
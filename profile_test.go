@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPlatformProfiles tests adjusting identity, ingress and pod security to a platform
+func TestPlatformProfiles(t *testing.T) {
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Source: &types.TaskDefinition{
+				Volumes: []types.Volume{{Name: aws.String("shared"), EfsVolumeConfiguration: &types.EFSVolumeConfiguration{FileSystemId: aws.String("fs-1")}}},
+			},
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{HostPID: true, ServiceAccountName: "default-sa"},
+				ServiceAccount: &corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "default-sa",
+						Namespace:   "default",
+						Annotations: map[string]string{irsaAnnotation: "arn:aws:iam::123456789012:role/service/web-task-role"},
+					},
+				},
+				Extras: []ExtraObject{{
+					Suffix: "ingress",
+					Object: buildTargetGroupIngress("web", []targetGroupAttachment{{TargetGroupArn: "tg", ContainerName: "web", ContainerPort: 80}}, nil),
+				}},
+			},
+		}
+	}
+
+	if _, err := lookupProfile("nomad"); err == nil {
+		t.Errorf("lookupProfile(nomad) succeeded")
+	}
+	if profile, err := lookupProfile(""); profile != nil || err != nil {
+		t.Errorf("lookupProfile(\"\") = %v, %v, want no profile", profile, err)
+	}
+
+	gke := newInfo()
+	platformProfiles[profileGKE].apply(gke, nil)
+	annotations := gke.Manifests.ServiceAccount.Annotations
+	if annotations[irsaAnnotation] != "" || annotations["iam.gke.io/gcp-service-account"] != "web-task-role@PROJECT_ID.iam.gserviceaccount.com" {
+		t.Errorf("GKE ServiceAccount annotations = %v", annotations)
+	}
+	ingress := gke.Manifests.Extras[0].Object
+	if got := ingress["spec"].(map[string]interface{})["ingressClassName"]; got != "gce" {
+		t.Errorf("GKE ingressClassName = %v, want gce", got)
+	}
+	if got := ingress["metadata"].(map[string]interface{})["annotations"].(map[string]string); len(got) != 0 {
+		t.Errorf("GKE Ingress annotations = %v, want ALB annotations removed", got)
+	}
+	if !hasNote(gke.Notes, "StorageClass standard-rwx") || !hasNote(gke.Notes, podSecurityEnforceLabel+"=privileged") {
+		t.Errorf("GKE notes = %v", gke.Notes)
+	}
+
+	aks := newInfo()
+	platformProfiles[profileAKS].apply(aks, nil)
+	files, err := renderManifests(aks.Name, aks.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	labels := files["web-deployment.yaml"].(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})["metadata"].(map[string]interface{})["labels"].(map[string]string)
+	if labels["azure.workload.identity/use"] != "true" || labels["app"] != "web" {
+		t.Errorf("AKS pod labels = %v", labels)
+	}
+
+	eks := newInfo()
+	platformProfiles[profileEKSManaged].apply(eks, nil)
+	if eks.Manifests.ServiceAccount.Annotations[irsaAnnotation] == "" {
+		t.Errorf("EKS dropped the IRSA annotation")
+	}
+	if got := eks.Manifests.Extras[0].Object["metadata"].(map[string]interface{})["annotations"].(map[string]string); got["alb.ingress.kubernetes.io/target-type"] != "ip" {
+		t.Errorf("EKS Ingress annotations = %v", got)
+	}
+
+	fargate := newInfo()
+	platformProfiles[profileEKSFargate].apply(fargate, nil)
+	if !hasNote(fargate.Notes, "Fargate does not run pods sharing host namespaces") {
+		t.Errorf("Fargate notes = %v", fargate.Notes)
+	}
+
+	openshift := newInfo()
+	platformProfiles[profileOpenShift].apply(openshift, nil)
+	if len(openshift.Manifests.Extras) != 2 {
+		t.Fatalf("OpenShift extras = %d, want Ingress and SCC RoleBinding", len(openshift.Manifests.Extras))
+	}
+	binding := openshift.Manifests.Extras[1].Object
+	if binding["roleRef"].(map[string]interface{})["name"] != openShiftPrivilegedSCCRole {
+		t.Errorf("OpenShift SCC RoleBinding = %v", binding)
+	}
+}
+
+// hasNote reports whether a note contains substr
+func hasNote(notes []string, substr string) bool {
+	for _, note := range notes {
+		if strings.Contains(note, substr) {
+			return true
+		}
+	}
+	return false
+}
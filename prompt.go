@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Prompt styles of --prompt-style
+const (
+	// promptStyleArrows selects with the arrow keys, drawing unicode glyphs
+	promptStyleArrows = "arrows"
+	// promptStyleASCII selects with the arrow keys, drawing ASCII only
+	promptStyleASCII = "ascii"
+	// promptStylePlain asks for a number line by line, without redrawing the
+	// terminal, for screen readers and minimal terminals
+	promptStylePlain = "plain"
+)
+
+var promptStyles = []string{promptStyleArrows, promptStyleASCII, promptStylePlain}
+
+// promptPageSize is the number of choices shown at once
+const promptPageSize = 10
+
+// isValidPromptStyle checks if style is a supported prompt style
+func isValidPromptStyle(style string) bool {
+	for _, s := range promptStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPromptStyle returns the prompt style used without --prompt-style:
+// plain on terminals that can't move the cursor, arrows otherwise
+func defaultPromptStyle() string {
+	if term := os.Getenv("TERM"); term == "dumb" {
+		return promptStylePlain
+	}
+	return promptStyleArrows
+}
+
+// selector asks the user to choose one of several items
+type selector interface {
+	// choose returns the index of the chosen item
+	choose(label string, items []string) (int, error)
+}
+
+// newSelector returns the selector of a prompt style. Prompts are written to
+// stderr to keep stdout clean for --stdout, which pipes manifests to other tools.
+func newSelector(style string) selector {
+	switch style {
+	case promptStylePlain:
+		return &plainSelector{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+	case promptStyleASCII:
+		return &arrowSelector{ascii: true}
+	}
+	return &arrowSelector{}
+}
+
+// arrowSelector is the promptui list, paged and searchable with /
+type arrowSelector struct {
+	ascii bool
+}
+
+func (s *arrowSelector) choose(label string, items []string) (int, error) {
+	templates := &promptui.SelectTemplates{
+		Label:    "{{ . }}?",
+		Active:   "➤ {{ . }}",
+		Inactive: "  {{ . }}",
+	}
+	if s.ascii {
+		templates = &promptui.SelectTemplates{
+			Label:    "{{ . }}?",
+			Active:   "> {{ . }}",
+			Inactive: "  {{ . }}",
+			Selected: "Selected: {{ . }}",
+			Help:     `Use the arrow keys to navigate, left and right to page{{ if .Search }}, / to search{{ end }}`,
+		}
+	}
+
+	prompt := promptui.Select{
+		Label:     label,
+		Items:     items,
+		Size:      promptPageSize,
+		Stdout:    os.Stderr,
+		Templates: templates,
+		Searcher: func(input string, index int) bool {
+			return matchesFilter(items[index], input)
+		},
+		// Long lists are easier to narrow down than to scroll
+		StartInSearchMode: len(items) > promptPageSize,
+	}
+	index, _, err := prompt.Run()
+	return index, err
+}
+
+// plainSelector lists numbered items a page at a time and reads the answer
+// as a line: a number chooses, n and p page, other text filters the list
+type plainSelector struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (s *plainSelector) choose(label string, items []string) (int, error) {
+	filter := ""
+	page := 0
+	for {
+		var matches []int
+		for i, item := range items {
+			if matchesFilter(item, filter) {
+				matches = append(matches, i)
+			}
+		}
+		pages := (len(matches) + promptPageSize - 1) / promptPageSize
+		if page >= pages {
+			page = 0
+		}
+
+		if filter != "" {
+			fmt.Fprintf(s.out, "%s (%d of %d matching %q):\n", label, len(matches), len(items), filter)
+		} else {
+			fmt.Fprintf(s.out, "%s (%d):\n", label, len(items))
+		}
+		start := page * promptPageSize
+		end := min(start+promptPageSize, len(matches))
+		for _, i := range matches[start:end] {
+			fmt.Fprintf(s.out, "  %d) %s\n", i+1, items[i])
+		}
+		if pages > 1 {
+			fmt.Fprintf(s.out, "Page %d of %d. n: next page, p: previous page.\n", page+1, pages)
+		}
+		fmt.Fprint(s.out, "Enter a number, text to filter, an empty line to clear the filter, or q to quit: ")
+
+		line, err := s.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return -1, fmt.Errorf("no selection: %w", err)
+		}
+		answer := strings.TrimSpace(line)
+		switch {
+		case answer == "q":
+			return -1, promptui.ErrAbort
+		case answer == "n":
+			page = (page + 1) % max(pages, 1)
+		case answer == "p":
+			page = (page + max(pages, 1) - 1) % max(pages, 1)
+		case answer == "":
+			filter, page = "", 0
+		default:
+			if n, err := strconv.Atoi(answer); err == nil {
+				if n >= 1 && n <= len(items) {
+					return n - 1, nil
+				}
+				fmt.Fprintf(s.out, "%d is not a listed number.\n", n)
+				continue
+			}
+			filter, page = answer, 0
+		}
+	}
+}
+
+// matchesFilter reports whether an item contains the filter, ignoring case
+func matchesFilter(item, filter string) bool {
+	return strings.Contains(strings.ToLower(item), strings.ToLower(strings.TrimSpace(filter)))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestPlainSelector tests paging, filtering and choosing by number
+func TestPlainSelector(t *testing.T) {
+	var items []string
+	for i := 1; i <= 25; i++ {
+		items = append(items, fmt.Sprintf("cluster-%02d", i))
+	}
+	items = append(items, "payments-prod")
+
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"3\n", 2},
+		{"n\nn\n26\n", 25},
+		// Numbers keep referring to the full list while filtering
+		{"PAY\n26\n", 25},
+		{"99\n1\n", 0},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		s := &plainSelector{in: bufio.NewReader(strings.NewReader(tt.input)), out: &out}
+		got, err := s.choose("Select ECS cluster", items)
+		if err != nil || got != tt.want {
+			t.Errorf("choose(%q) = %d, %v; want %d", tt.input, got, err, tt.want)
+		}
+		if strings.ContainsAny(out.String(), "\x1b➤") {
+			t.Errorf("choose(%q) wrote escape sequences or glyphs: %q", tt.input, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	s := &plainSelector{in: bufio.NewReader(strings.NewReader("pay\n")), out: &out}
+	if _, err := s.choose("Select ECS cluster", items); err == nil {
+		t.Error("choose() without an answer succeeded")
+	}
+	if !strings.Contains(out.String(), "Page 1 of 3") || !strings.Contains(out.String(), "26) payments-prod") {
+		t.Errorf("output = %q, want the pages and the filtered list", out.String())
+	}
+
+	s = &plainSelector{in: bufio.NewReader(strings.NewReader("q\n")), out: &out}
+	if _, err := selectCluster([]string{"arn:aws:ecs:us-east-1:123456789012:cluster/prod"}, false, s); err == nil {
+		t.Error("selectCluster() after q succeeded")
+	}
+}
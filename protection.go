@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Pod annotations keeping the cluster autoscaler and Karpenter from evicting
+// protected pods when they scale nodes in
+const (
+	safeToEvictAnnotation  = "cluster-autoscaler.kubernetes.io/safe-to-evict"
+	doNotDisruptAnnotation = "karpenter.sh/do-not-disrupt"
+)
+
+// getTaskProtectionBatch is the most tasks GetTaskProtection accepts per call
+const getTaskProtectionBatch = 10
+
+// protectionResolver maps ECS scale-in protection onto Kubernetes disruption
+// controls. A service is protected when a capacity provider of its strategy
+// has managed termination protection, or when any of its running tasks has
+// task scale-in protection.
+type protectionResolver struct {
+	client  *ecs.Client
+	cluster string
+	// capacityProviders caches whether a capacity provider has managed
+	// termination protection
+	capacityProviders map[string]bool
+}
+
+// newProtectionResolver creates a resolver for the services of a cluster
+func newProtectionResolver(client *ecs.Client, cluster string) *protectionResolver {
+	return &protectionResolver{client: client, cluster: cluster, capacityProviders: map[string]bool{}}
+}
+
+// apply adds a PodDisruptionBudget with maxUnavailable: 0 and the eviction
+// annotations to a protected service. Lookup failures are logged and leave the
+// service unprotected.
+func (p *protectionResolver) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	if taskDefInfo.Manifests.Deployment == nil || len(taskDefInfo.Services) == 0 {
+		return
+	}
+
+	var reasons []string
+	providers, err := p.protectedCapacityProviders(ctx, taskDefInfo.Services)
+	if err != nil {
+		log.Printf("Warning: Failed to describe the capacity providers of %s: %v", taskDefInfo.Name, err)
+	}
+	if len(providers) > 0 {
+		reasons = append(reasons, fmt.Sprintf("capacity provider %s has managed termination protection", strings.Join(providers, ", ")))
+	}
+	tasks, err := p.protectedTasks(ctx, taskDefInfo.Services)
+	if err != nil {
+		log.Printf("Warning: Failed to read the task protection of %s: %v", taskDefInfo.Name, err)
+	}
+	if tasks > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d running task(s) have scale-in protection", tasks))
+	}
+	if len(reasons) == 0 {
+		return
+	}
+
+	applyDisruptionProtection(taskDefInfo, strings.Join(reasons, " and "))
+}
+
+// applyDisruptionProtection blocks voluntary disruptions of a service's pods
+// and records the trade-off in the report
+func applyDisruptionProtection(taskDefInfo *TaskDefInfo, reason string) {
+	if taskDefInfo.Manifests.PodAnnotations == nil {
+		taskDefInfo.Manifests.PodAnnotations = map[string]string{}
+	}
+	taskDefInfo.Manifests.PodAnnotations[safeToEvictAnnotation] = "false"
+	taskDefInfo.Manifests.PodAnnotations[doNotDisruptAnnotation] = "true"
+
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{
+		Suffix: "pdb",
+		Object: map[string]interface{}{
+			"apiVersion": apiVersionPolicy,
+			"kind":       "PodDisruptionBudget",
+			"metadata": map[string]interface{}{
+				"name":   taskDefInfo.Name,
+				"labels": map[string]string{"app": taskDefInfo.Name},
+			},
+			"spec": map[string]interface{}{
+				"maxUnavailable": 0,
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]string{"app": taskDefInfo.Name},
+				},
+			},
+		},
+	})
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS scale-in protection is enabled (%s): a PodDisruptionBudget with maxUnavailable: 0 and the %s=false and %s=true pod annotations keep the pods from being evicted. "+
+		"Unlike ECS task protection this never expires: node drains, node pool upgrades and consolidation block until the PodDisruptionBudget is removed, so remove it once the service tolerates being rescheduled", reason, safeToEvictAnnotation, doNotDisruptAnnotation))
+}
+
+// protectedCapacityProviders returns the capacity providers of the services'
+// strategies with managed termination protection, sorted
+func (p *protectionResolver) protectedCapacityProviders(ctx context.Context, services []types.Service) ([]string, error) {
+	var unknown []string
+	names := map[string]bool{}
+	for _, svc := range services {
+		for _, item := range svc.CapacityProviderStrategy {
+			name := aws.ToString(item.CapacityProvider)
+			names[name] = true
+			if _, ok := p.capacityProviders[name]; !ok && !strings.HasPrefix(name, "FARGATE") {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		out, err := p.client.DescribeCapacityProviders(ctx, &ecs.DescribeCapacityProvidersInput{CapacityProviders: unknown})
+		if err != nil {
+			return nil, err
+		}
+		for _, provider := range out.CapacityProviders {
+			asg := provider.AutoScalingGroupProvider
+			p.capacityProviders[aws.ToString(provider.Name)] = asg != nil && asg.ManagedTerminationProtection == types.ManagedTerminationProtectionEnabled
+		}
+	}
+
+	var protected []string
+	for name := range names {
+		if p.capacityProviders[name] {
+			protected = append(protected, name)
+		}
+	}
+	sort.Strings(protected)
+	return protected, nil
+}
+
+// protectedTasks counts the running tasks of the services with task scale-in
+// protection
+func (p *protectionResolver) protectedTasks(ctx context.Context, services []types.Service) (int, error) {
+	var taskArns []string
+	for _, svc := range services {
+		paginator := ecs.NewListTasksPaginator(p.client, &ecs.ListTasksInput{
+			Cluster:     aws.String(p.cluster),
+			ServiceName: svc.ServiceName,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, err
+			}
+			taskArns = append(taskArns, page.TaskArns...)
+		}
+	}
+
+	protected := 0
+	for start := 0; start < len(taskArns); start += getTaskProtectionBatch {
+		end := start + getTaskProtectionBatch
+		if end > len(taskArns) {
+			end = len(taskArns)
+		}
+		out, err := p.client.GetTaskProtection(ctx, &ecs.GetTaskProtectionInput{
+			Cluster: aws.String(p.cluster),
+			Tasks:   taskArns[start:end],
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, task := range out.ProtectedTasks {
+			if task.ProtectionEnabled {
+				protected++
+			}
+		}
+	}
+	return protected, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyDisruptionProtection tests mapping ECS scale-in protection onto a PodDisruptionBudget
+func TestApplyDisruptionProtection(t *testing.T) {
+	info := &TaskDefInfo{
+		Name: "web",
+		Manifests: K8sManifests{
+			Deployment:     &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+			PodAnnotations: map[string]string{"team": "payments"},
+		},
+	}
+	applyDisruptionProtection(info, "2 running task(s) have scale-in protection")
+
+	annotations := info.Manifests.PodAnnotations
+	if annotations[safeToEvictAnnotation] != "false" || annotations[doNotDisruptAnnotation] != "true" || annotations["team"] != "payments" {
+		t.Errorf("pod annotations = %v", annotations)
+	}
+
+	files, err := renderManifests(info.Name, info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	pdb, ok := files["web-pdb.yaml"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("rendered files = %v, want web-pdb.yaml", sortedDocKeys(files))
+	}
+	if pdb["kind"] != "PodDisruptionBudget" || pdb["spec"].(map[string]interface{})["maxUnavailable"] != 0 {
+		t.Errorf("pdb = %v", pdb)
+	}
+	if len(info.Notes) != 1 || !strings.Contains(info.Notes[0], "never expires") {
+		t.Errorf("notes = %v, want the trade-off", info.Notes)
+	}
+}
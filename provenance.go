@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Build information, set by the release build with -ldflags -X
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// Files --sign writes to the root of the output directory
+const (
+	checksumsFileName  = "SHA256SUMS"
+	provenanceFileName = "provenance.json"
+	// sigstoreBundleName holds the cosign signature of the provenance
+	sigstoreBundleName = provenanceFileName + ".sigstore.json"
+)
+
+// cosignKeyless signs with a short-lived Sigstore certificate of the caller's
+// OIDC identity instead of a key (--cosign keyless)
+const cosignKeyless = "keyless"
+
+// provenanceBuildType identifies conversions in the provenance predicate
+const provenanceBuildType = "https://github.com/krishnaduttPanchagnula/ecs2k8s/conversion/v1"
+
+// ProvenanceStatement is an in-toto v1 statement with a SLSA v1 provenance
+// predicate, naming every generated file by its digest
+type ProvenanceStatement struct {
+	Type          string              `json:"_type"`
+	Subject       []ProvenanceSubject `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     SLSAProvenance      `json:"predicate"`
+}
+
+// ProvenanceSubject is a generated file
+type ProvenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SLSAProvenance is the SLSA v1 provenance of a conversion
+type SLSAProvenance struct {
+	BuildDefinition struct {
+		BuildType string `json:"buildType"`
+		// ExternalParameters are the source cluster and region
+		ExternalParameters map[string]string `json:"externalParameters"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  time.Time `json:"startedOn"`
+			FinishedOn time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// signer writes the checksums and provenance of an output directory (--sign),
+// and signs the provenance with cosign when a key or keyless signing is set
+type signer struct {
+	// cosign is the cosign key reference, cosignKeyless, or empty for no signature
+	cosign string
+}
+
+// sign writes SHA256SUMS and provenance.json to the output directory, then
+// the Sigstore bundle of the provenance. The provenance records the source
+// cluster ARN and the version of ecs2k8s.
+func (s *signer) sign(ctx context.Context, outputDir, clusterArn, region string, started, finished time.Time) error {
+	subjects, err := outputDigests(outputDir)
+	if err != nil {
+		return err
+	}
+
+	var sums strings.Builder
+	for _, subject := range subjects {
+		fmt.Fprintf(&sums, "%s  %s\n", subject.Digest["sha256"], subject.Name)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, checksumsFileName), []byte(sums.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", checksumsFileName, err)
+	}
+
+	statement := provenanceStatement(subjects, clusterArn, region, started, finished)
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	provenancePath := filepath.Join(outputDir, provenanceFileName)
+	if err := os.WriteFile(provenancePath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", provenanceFileName, err)
+	}
+
+	if s.cosign == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("cosign"); err != nil {
+		return fmt.Errorf("--cosign needs cosign in PATH: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, "cosign", cosignArgs(s.cosign, filepath.Join(outputDir, sigstoreBundleName), provenancePath)...)
+	// Keyless signing may open a browser and prompt for the OIDC login
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cosign failed to sign %s: %w", provenanceFileName, err)
+	}
+	return nil
+}
+
+// cosignArgs returns the arguments of cosign signing a blob into a bundle
+func cosignArgs(key, bundlePath, blobPath string) []string {
+	args := []string{"sign-blob", "--yes", "--bundle", bundlePath}
+	if key != cosignKeyless {
+		args = append(args, "--key", key)
+	}
+	return append(args, blobPath)
+}
+
+// provenanceStatement returns the provenance of the generated files
+func provenanceStatement(subjects []ProvenanceSubject, clusterArn, region string, started, finished time.Time) ProvenanceStatement {
+	statement := ProvenanceStatement{
+		Type:          "https://in-toto.io/Statement/v1",
+		Subject:       subjects,
+		PredicateType: "https://slsa.dev/provenance/v1",
+	}
+	predicate := &statement.Predicate
+	predicate.BuildDefinition.BuildType = provenanceBuildType
+	predicate.BuildDefinition.ExternalParameters = map[string]string{"cluster": clusterArn}
+	if region != "" {
+		predicate.BuildDefinition.ExternalParameters["region"] = region
+	}
+	predicate.RunDetails.Builder.ID = "https://github.com/krishnaduttPanchagnula/ecs2k8s"
+	build := buildInfo()
+	predicate.RunDetails.Builder.Version = map[string]string{"ecs2k8s": build.Version, "commit": build.Commit, "date": build.Date}
+	predicate.RunDetails.Metadata.StartedOn = started.UTC()
+	predicate.RunDetails.Metadata.FinishedOn = finished.UTC()
+	return statement
+}
+
+// outputDigests returns the SHA-256 digests of the files of the output
+// directory, sorted by path. The workspace lock and the files of a previous
+// --sign are left out.
+func outputDigests(outputDir string) ([]ProvenanceSubject, error) {
+	var subjects []ProvenanceSubject
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		switch rel {
+		case lockFileName, checksumsFileName, provenanceFileName, sigstoreBundleName:
+			return nil
+		}
+
+		digest, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		subjects = append(subjects, ProvenanceSubject{
+			Name:   filepath.ToSlash(rel),
+			Digest: map[string]string{"sha256": digest},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", outputDir, err)
+	}
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+	return subjects, nil
+}
+
+// fileDigest returns the hex SHA-256 digest of a file
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
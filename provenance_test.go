@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignOutput tests the checksums and provenance written with --sign
+func TestSignOutput(t *testing.T) {
+	outputDir := t.TempDir()
+	files := map[string]string{
+		"web-deployment.yaml":        "kind: Deployment\n",
+		"helm/prod/values.yaml":      "services: {}\n",
+		lockFileName:                 "locked\n",
+		provenanceFileName:           "stale\n",
+		"helm/prod/templates/_h.tpl": "{{/* */}}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	arn := "arn:aws:ecs:us-east-1:123456789012:cluster/prod"
+	if err := (&signer{}).sign(context.Background(), outputDir, arn, "us-east-1", started, started.Add(time.Minute)); err != nil {
+		t.Fatalf("sign() error = %v", err)
+	}
+
+	sums, err := os.ReadFile(filepath.Join(outputDir, checksumsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("kind: Deployment\n"))
+	lines := strings.Split(strings.TrimSpace(string(sums)), "\n")
+	if len(lines) != 3 || lines[2] != hex.EncodeToString(digest[:])+"  web-deployment.yaml" || !strings.HasSuffix(lines[0], "  helm/prod/templates/_h.tpl") {
+		t.Errorf("%s = %q, want the three generated files sorted", checksumsFileName, sums)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, provenanceFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement ProvenanceStatement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		t.Fatalf("provenance is not JSON: %v", err)
+	}
+	predicate := statement.Predicate
+	if len(statement.Subject) != 3 || statement.PredicateType != "https://slsa.dev/provenance/v1" {
+		t.Errorf("statement = %+v, want 3 subjects and a SLSA v1 predicate", statement)
+	}
+	if predicate.BuildDefinition.ExternalParameters["cluster"] != arn || predicate.RunDetails.Builder.Version["ecs2k8s"] != buildInfo().Version {
+		t.Errorf("predicate = %+v, want the cluster ARN and the ecs2k8s version", predicate)
+	}
+	if !predicate.RunDetails.Metadata.StartedOn.Equal(started) {
+		t.Errorf("startedOn = %v, want %v", predicate.RunDetails.Metadata.StartedOn, started)
+	}
+
+	if got := strings.Join(cosignArgs(cosignKeyless, "b.json", "p.json"), " "); got != "sign-blob --yes --bundle b.json p.json" {
+		t.Errorf("keyless cosign args = %q", got)
+	}
+	if got := strings.Join(cosignArgs("awskms:///alias/ecs2k8s", "b.json", "p.json"), " "); got != "sign-blob --yes --bundle b.json --key awskms:///alias/ecs2k8s p.json" {
+		t.Errorf("key cosign args = %q", got)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/spf13/cobra"
+)
+
+// pruneResources are the kubectl resources the generated objects can be of.
+// Custom resources whose CRD is not installed are skipped.
+var pruneResources = []string{
+	"deployments.apps",
+	"statefulsets.apps",
+	"poddisruptionbudgets.policy",
+	"services",
+	"configmaps",
+	"secrets",
+	"serviceaccounts",
+	"roles.rbac.authorization.k8s.io",
+	"rolebindings.rbac.authorization.k8s.io",
+	"clusterroles.rbac.authorization.k8s.io",
+	"clusterrolebindings.rbac.authorization.k8s.io",
+	"scaledobjects.keda.sh",
+	"prometheusrules.monitoring.coreos.com",
+	"rollouts.argoproj.io",
+	"canaries.flagger.app",
+	"targetgroupbindings.elbv2.k8s.aws",
+	"services.serving.knative.dev",
+	"routes.route.openshift.io",
+	"vaultstaticsecrets.secrets.hashicorp.com",
+	"conversionrecords.ecs2k8s.io",
+	"certificates.cert-manager.io",
+}
+
+// PrunedObject is a previously applied object of a task definition family
+// that no longer runs in ECS
+type PrunedObject struct {
+	Resource  string
+	Name      string
+	Namespace string
+	Family    string
+}
+
+// pruneOptions holds the inputs of the prune subcommand
+type pruneOptions struct {
+	region      string
+	cluster     string
+	kubeContext string
+	namespace   string
+	dryRun      bool
+}
+
+// newPruneCommand creates the `prune` subcommand
+func newPruneCommand() *cobra.Command {
+	opts := &pruneOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete applied objects of services that no longer exist in ECS",
+		Long: `prune finds the objects in the Kubernetes cluster that were converted from an
+ECS cluster, by the ` + labelManagedBy + ` and ` + labelSourceCluster + `
+labels stamped on every generated object, and deletes those whose task
+definition family (` + labelTaskDefinition + `) is no longer run by any
+service of the ECS cluster.
+
+Objects shared by the services of a cluster, such as Karpenter NodePools,
+are never pruned. Helm releases are owned by Helm; uninstall them instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster the objects were converted from")
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (default: from the AWS configuration)")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context (default: current context)")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Only prune objects in this namespace (default: all namespaces)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List the objects that would be deleted without deleting them")
+	_ = cmd.MarkFlagRequired("cluster")
+
+	return cmd
+}
+
+// runPrune executes the prune subcommand
+func runPrune(opts *pruneOptions) error {
+	ctx := context.Background()
+
+	if opts.region != "" {
+		if err := validateRegion(opts.region); err != nil {
+			return err
+		}
+	}
+	cfg, err := loadAWSConfig(ctx, opts.region)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	log.Printf("Reading current ECS services from cluster %s...", opts.cluster)
+	services, err := describeClusterServices(ctx, ecs.NewFromConfig(cfg), opts.cluster)
+	if err != nil {
+		return err
+	}
+	// An empty or mistyped cluster would otherwise prune every object
+	if len(services) == 0 {
+		return fmt.Errorf("found no services in ECS cluster %s; refusing to prune every converted object", opts.cluster)
+	}
+	families := map[string]bool{}
+	for _, taskDefArn := range serviceTaskDefinitions(opts.cluster, services) {
+		families[safeLabelValue(extractTaskDefName(taskDefArn))] = true
+	}
+
+	kubectl := &kubectlRunner{Context: opts.kubeContext}
+	selector := fmt.Sprintf("%s=%s,%s=%s,%s", labelManagedBy, managedByValue, labelSourceCluster, safeLabelValue(opts.cluster), labelTaskDefinition)
+	var objects []PrunedObject
+	for _, resource := range pruneResources {
+		args := []string{"get", resource, "-l", selector, "-o", "json"}
+		if opts.namespace != "" {
+			args = append(args, "--namespace", opts.namespace)
+		} else {
+			args = append(args, "--all-namespaces")
+		}
+		out, err := kubectl.run(ctx, nil, args...)
+		if err != nil {
+			if strings.Contains(err.Error(), "doesn't have a resource type") {
+				continue
+			}
+			return err
+		}
+		found, err := parsePruneList(resource, out)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, found...)
+	}
+
+	stale := staleObjects(objects, families)
+	printPruneSummary(os.Stdout, stale, opts.dryRun)
+	if opts.dryRun || len(stale) == 0 {
+		return nil
+	}
+
+	for _, object := range stale {
+		args := []string{"delete", object.Resource, object.Name, "--ignore-not-found"}
+		if object.Namespace != "" {
+			args = append(args, "--namespace", object.Namespace)
+		}
+		if _, err := kubectl.run(ctx, nil, args...); err != nil {
+			return err
+		}
+	}
+	log.Printf("✓ Pruned %d object(s) of cluster %s", len(stale), opts.cluster)
+	return nil
+}
+
+// parsePruneList reads the objects of `kubectl get <resource> -o json`
+func parsePruneList(resource string, data []byte) ([]PrunedObject, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string            `json:"name"`
+				Namespace string            `json:"namespace"`
+				Labels    map[string]string `json:"labels"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", resource, err)
+	}
+
+	var objects []PrunedObject
+	for _, item := range list.Items {
+		objects = append(objects, PrunedObject{
+			Resource:  resource,
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Family:    item.Metadata.Labels[labelTaskDefinition],
+		})
+	}
+	return objects, nil
+}
+
+// staleObjects returns the objects whose family is not in families, sorted
+func staleObjects(objects []PrunedObject, families map[string]bool) []PrunedObject {
+	var stale []PrunedObject
+	for _, object := range objects {
+		if object.Family != "" && !families[object.Family] {
+			stale = append(stale, object)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		a, b := stale[i], stale[j]
+		if a.Family != b.Family {
+			return a.Family < b.Family
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Name < b.Name
+	})
+	return stale
+}
+
+// printPruneSummary writes the objects to delete as a table
+func printPruneSummary(w io.Writer, stale []PrunedObject, dryRun bool) {
+	if len(stale) == 0 {
+		fmt.Fprintln(w, "No objects to prune.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAMILY\tNAMESPACE\tRESOURCE\tNAME")
+	for _, object := range stale {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", object.Family, object.Namespace, object.Resource, object.Name)
+	}
+	tw.Flush()
+
+	if dryRun {
+		fmt.Fprintf(w, "\n%d object(s) would be pruned (--dry-run)\n", len(stale))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestStaleObjects tests selecting the objects of families no longer in ECS
+func TestStaleObjects(t *testing.T) {
+	list := `{"items": [
+		{"metadata": {"name": "web", "namespace": "shop", "labels": {"ecs2k8s.io/task-definition": "web"}}},
+		{"metadata": {"name": "legacy", "namespace": "shop", "labels": {"ecs2k8s.io/task-definition": "legacy"}}},
+		{"metadata": {"name": "unlabelled", "namespace": "shop"}}
+	]}`
+	objects, err := parsePruneList("deployments.apps", []byte(list))
+	if err != nil {
+		t.Fatalf("parsePruneList() error = %v", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("parsePruneList() returned %d objects, want 3", len(objects))
+	}
+
+	stale := staleObjects(objects, map[string]bool{"web": true})
+	want := []PrunedObject{{Resource: "deployments.apps", Name: "legacy", Namespace: "shop", Family: "legacy"}}
+	if !reflect.DeepEqual(stale, want) {
+		t.Errorf("staleObjects() = %+v, want %+v", stale, want)
+	}
+
+	var out bytes.Buffer
+	printPruneSummary(&out, stale, true)
+	if !strings.Contains(out.String(), "legacy") || !strings.Contains(out.String(), "1 object(s) would be pruned") {
+		t.Errorf("printPruneSummary() = %q", out.String())
+	}
+
+	if _, err := parsePruneList("services", []byte("not json")); err == nil {
+		t.Error("parsePruneList() accepted invalid JSON")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RBACManifests holds the RBAC objects generated for a service's ServiceAccount.
+// Either the namespaced Role/RoleBinding pair or the cluster-scoped pair is set.
+type RBACManifests struct {
+	Role               *rbacv1.Role               `json:"role,omitempty"`
+	RoleBinding        *rbacv1.RoleBinding        `json:"rolebinding,omitempty"`
+	ClusterRole        *rbacv1.ClusterRole        `json:"clusterrole,omitempty"`
+	ClusterRoleBinding *rbacv1.ClusterRoleBinding `json:"clusterrolebinding,omitempty"`
+}
+
+// createRBAC generates minimal RBAC objects binding the configured rules to the
+// service's ServiceAccount
+func createRBAC(taskDefName string, settings *RBACSettings, manifests *K8sManifests) {
+	sa := manifests.ServiceAccount
+	if sa == nil {
+		log.Printf("Warning: RBAC configured for %s but no ServiceAccount was generated, skipping", taskDefName)
+		return
+	}
+
+	var rules []rbacv1.PolicyRule
+	for _, rule := range settings.Rules {
+		apiGroups := rule.APIGroups
+		if len(apiGroups) == 0 {
+			// Default to the core API group
+			apiGroups = []string{""}
+		}
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: apiGroups,
+			Resources: rule.Resources,
+			Verbs:     rule.Verbs,
+		})
+	}
+
+	roleName := fmt.Sprintf("%s-role", taskDefName)
+	labels := map[string]string{"app": taskDefName}
+	subjects := []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      sa.Name,
+			Namespace: sa.Namespace,
+		},
+	}
+
+	if settings.ClusterRoleBinding {
+		manifests.RBAC = &RBACManifests{
+			ClusterRole: &rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: roleName, Labels: labels},
+				Rules:      rules,
+			},
+			ClusterRoleBinding: &rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-rolebinding", taskDefName), Labels: labels},
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: roleName},
+			},
+		}
+		log.Printf("✓ Created ClusterRole and ClusterRoleBinding %s for ServiceAccount %s", roleName, sa.Name)
+		return
+	}
+
+	manifests.RBAC = &RBACManifests{
+		Role: &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: roleName, Namespace: sa.Namespace, Labels: labels},
+			Rules:      rules,
+		},
+		RoleBinding: &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-rolebinding", taskDefName), Namespace: sa.Namespace, Labels: labels},
+			Subjects:   subjects,
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: roleName},
+		},
+	}
+	log.Printf("✓ Created Role and RoleBinding %s for ServiceAccount %s", roleName, sa.Name)
+}
+
+// serializeRBAC converts the RBAC objects to YAML-ready maps keyed by file suffix
+func serializeRBAC(rbac *RBACManifests) map[string]interface{} {
+	docs := map[string]interface{}{}
+	if rbac == nil {
+		return docs
+	}
+
+	if rbac.Role != nil {
+		docs["role"] = map[string]interface{}{
+			"apiVersion": apiVersionRBAC,
+			"kind":       "Role",
+			"metadata":   serializeRBACMetadata(rbac.Role.ObjectMeta),
+			"rules":      serializePolicyRules(rbac.Role.Rules),
+		}
+	}
+	if rbac.RoleBinding != nil {
+		docs["rolebinding"] = map[string]interface{}{
+			"apiVersion": apiVersionRBAC,
+			"kind":       "RoleBinding",
+			"metadata":   serializeRBACMetadata(rbac.RoleBinding.ObjectMeta),
+			"subjects":   serializeSubjects(rbac.RoleBinding.Subjects),
+			"roleRef":    serializeRoleRef(rbac.RoleBinding.RoleRef),
+		}
+	}
+	if rbac.ClusterRole != nil {
+		docs["clusterrole"] = map[string]interface{}{
+			"apiVersion": apiVersionRBAC,
+			"kind":       "ClusterRole",
+			"metadata":   serializeRBACMetadata(rbac.ClusterRole.ObjectMeta),
+			"rules":      serializePolicyRules(rbac.ClusterRole.Rules),
+		}
+	}
+	if rbac.ClusterRoleBinding != nil {
+		docs["clusterrolebinding"] = map[string]interface{}{
+			"apiVersion": apiVersionRBAC,
+			"kind":       "ClusterRoleBinding",
+			"metadata":   serializeRBACMetadata(rbac.ClusterRoleBinding.ObjectMeta),
+			"subjects":   serializeSubjects(rbac.ClusterRoleBinding.Subjects),
+			"roleRef":    serializeRoleRef(rbac.ClusterRoleBinding.RoleRef),
+		}
+	}
+
+	return docs
+}
+
+// serializeRBACMetadata converts object metadata of an RBAC object
+func serializeRBACMetadata(meta metav1.ObjectMeta) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name": meta.Name,
+	}
+	if meta.Namespace != "" {
+		metadata["namespace"] = meta.Namespace
+	}
+	if len(meta.Labels) > 0 {
+		metadata["labels"] = meta.Labels
+	}
+	return metadata
+}
+
+// serializePolicyRules converts RBAC policy rules
+func serializePolicyRules(rules []rbacv1.PolicyRule) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, rule := range rules {
+		result = append(result, map[string]interface{}{
+			"apiGroups": rule.APIGroups,
+			"resources": rule.Resources,
+			"verbs":     rule.Verbs,
+		})
+	}
+	return result
+}
+
+// serializeSubjects converts RBAC binding subjects
+func serializeSubjects(subjects []rbacv1.Subject) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, subject := range subjects {
+		s := map[string]interface{}{
+			"kind": subject.Kind,
+			"name": subject.Name,
+		}
+		if subject.Namespace != "" {
+			s["namespace"] = subject.Namespace
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// serializeRoleRef converts an RBAC role reference
+func serializeRoleRef(ref rbacv1.RoleRef) map[string]interface{} {
+	return map[string]interface{}{
+		"apiGroup": ref.APIGroup,
+		"kind":     ref.Kind,
+		"name":     ref.Name,
+	}
+}
+
+// rbacValues builds the Helm values describing a service's RBAC objects
+func rbacValues(rbac *RBACManifests) map[string]interface{} {
+	if rbac == nil {
+		return nil
+	}
+
+	var rules []rbacv1.PolicyRule
+	clusterScoped := false
+	switch {
+	case rbac.Role != nil:
+		rules = rbac.Role.Rules
+	case rbac.ClusterRole != nil:
+		rules = rbac.ClusterRole.Rules
+		clusterScoped = true
+	default:
+		return nil
+	}
+
+	return map[string]interface{}{
+		"clusterRoleBinding": clusterScoped,
+		"rules":              serializePolicyRules(rules),
+	}
+}
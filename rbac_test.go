@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestCreateRBAC tests generating the namespaced and cluster-scoped RBAC
+// objects of a ServiceAccount
+func TestCreateRBAC(t *testing.T) {
+	rules := []RBACRule{
+		{Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"patch"}},
+	}
+	wantRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"patch"}},
+	}
+	wantSubjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "web-sa", Namespace: "prod"}}
+
+	t.Run("role", func(t *testing.T) {
+		manifests := &K8sManifests{ServiceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "web-sa", Namespace: "prod"}}}
+		createRBAC("web", &RBACSettings{Rules: rules}, manifests)
+
+		rbac := manifests.RBAC
+		if rbac == nil || rbac.Role == nil || rbac.RoleBinding == nil {
+			t.Fatalf("createRBAC() = %+v, want Role and RoleBinding", rbac)
+		}
+		if rbac.ClusterRole != nil || rbac.ClusterRoleBinding != nil {
+			t.Errorf("createRBAC() generated cluster-scoped objects for a namespaced config")
+		}
+		if !reflect.DeepEqual(rbac.Role.Rules, wantRules) {
+			t.Errorf("Role rules = %+v, want %+v", rbac.Role.Rules, wantRules)
+		}
+		if rbac.Role.Name != "web-role" || rbac.Role.Namespace != "prod" {
+			t.Errorf("Role = %s/%s, want prod/web-role", rbac.Role.Namespace, rbac.Role.Name)
+		}
+		if !reflect.DeepEqual(rbac.RoleBinding.Subjects, wantSubjects) {
+			t.Errorf("RoleBinding subjects = %+v, want %+v", rbac.RoleBinding.Subjects, wantSubjects)
+		}
+		if ref := rbac.RoleBinding.RoleRef; ref.Kind != "Role" || ref.Name != "web-role" {
+			t.Errorf("RoleBinding roleRef = %+v, want Role web-role", ref)
+		}
+	})
+
+	t.Run("cluster role", func(t *testing.T) {
+		manifests := &K8sManifests{ServiceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "web-sa", Namespace: "prod"}}}
+		createRBAC("web", &RBACSettings{Rules: rules, ClusterRoleBinding: true}, manifests)
+
+		rbac := manifests.RBAC
+		if rbac == nil || rbac.ClusterRole == nil || rbac.ClusterRoleBinding == nil {
+			t.Fatalf("createRBAC() = %+v, want ClusterRole and ClusterRoleBinding", rbac)
+		}
+		if rbac.Role != nil || rbac.RoleBinding != nil {
+			t.Errorf("createRBAC() generated namespaced objects for a cluster-scoped config")
+		}
+		if !reflect.DeepEqual(rbac.ClusterRole.Rules, wantRules) {
+			t.Errorf("ClusterRole rules = %+v, want %+v", rbac.ClusterRole.Rules, wantRules)
+		}
+		if rbac.ClusterRole.Namespace != "" {
+			t.Errorf("ClusterRole namespace = %q, want none", rbac.ClusterRole.Namespace)
+		}
+		if !reflect.DeepEqual(rbac.ClusterRoleBinding.Subjects, wantSubjects) {
+			t.Errorf("ClusterRoleBinding subjects = %+v, want %+v", rbac.ClusterRoleBinding.Subjects, wantSubjects)
+		}
+		if ref := rbac.ClusterRoleBinding.RoleRef; ref.Kind != "ClusterRole" || ref.Name != "web-role" {
+			t.Errorf("ClusterRoleBinding roleRef = %+v, want ClusterRole web-role", ref)
+		}
+	})
+
+	t.Run("no service account", func(t *testing.T) {
+		manifests := &K8sManifests{}
+		createRBAC("web", &RBACSettings{Rules: rules}, manifests)
+		if manifests.RBAC != nil {
+			t.Errorf("createRBAC() = %+v, want nil without a ServiceAccount", manifests.RBAC)
+		}
+	})
+}
+
+// TestSerializeRBAC tests the documents written for the RBAC objects
+func TestSerializeRBAC(t *testing.T) {
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "web-sa", Namespace: "prod"}}
+	rules := []RBACRule{{Resources: []string{"pods"}, Verbs: []string{"get"}}}
+
+	tests := []struct {
+		name          string
+		clusterScoped bool
+		roleSuffix    string
+		wantKinds     map[string]string
+		wantNamespace string
+	}{
+		{"role", false, "role", map[string]string{"role": "Role", "rolebinding": "RoleBinding"}, "prod"},
+		{"cluster role", true, "clusterrole", map[string]string{"clusterrole": "ClusterRole", "clusterrolebinding": "ClusterRoleBinding"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifests := &K8sManifests{ServiceAccount: sa}
+			createRBAC("web", &RBACSettings{Rules: rules, ClusterRoleBinding: tt.clusterScoped}, manifests)
+
+			docs := serializeRBAC(manifests.RBAC)
+			if len(docs) != len(tt.wantKinds) {
+				t.Fatalf("serializeRBAC() returned %d documents, want %d", len(docs), len(tt.wantKinds))
+			}
+			for suffix, kind := range tt.wantKinds {
+				doc, ok := docs[suffix].(map[string]interface{})
+				if !ok {
+					t.Fatalf("serializeRBAC() has no %s document", suffix)
+				}
+				if doc["apiVersion"] != apiVersionRBAC || doc["kind"] != kind {
+					t.Errorf("%s = %v %v, want %s %s", suffix, doc["apiVersion"], doc["kind"], apiVersionRBAC, kind)
+				}
+				metadata := doc["metadata"].(map[string]interface{})
+				namespace, _ := metadata["namespace"].(string)
+				if namespace != tt.wantNamespace {
+					t.Errorf("%s namespace = %q, want %q", suffix, namespace, tt.wantNamespace)
+				}
+			}
+
+			role := docs[tt.roleSuffix].(map[string]interface{})
+			wantRules := []map[string]interface{}{{"apiGroups": []string{""}, "resources": []string{"pods"}, "verbs": []string{"get"}}}
+			if !reflect.DeepEqual(role["rules"], wantRules) {
+				t.Errorf("rules = %v, want %v", role["rules"], wantRules)
+			}
+		})
+	}
+
+	if docs := serializeRBAC(nil); len(docs) != 0 {
+		t.Errorf("serializeRBAC(nil) = %v, want no documents", docs)
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reportFileName is the Markdown conversion report written into the output directory
+const reportFileName = "conversion-report.md"
+
+// writeConversionReport writes a Markdown report describing the conversion
+// decisions for each service, and the task definitions managed by AWS Batch.
+// settings is nil without AWS access.
+func writeConversionReport(outputDir, clusterName, region string, settings *ClusterSettings, taskDefInfos []*TaskDefInfo, batch []*AWSBatchTaskDefinition) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# ecs2k8s Conversion Report\n\n")
+	fmt.Fprintf(&b, "- **Cluster:** %s\n", clusterName)
+	fmt.Fprintf(&b, "- **Region:** %s\n", region)
+	fmt.Fprintf(&b, "- **Generated:** %s\n\n", time.Now().UTC().Format(time.RFC3339))
+
+	fmt.Fprintf(&b, "## Services\n\n")
+	fmt.Fprintf(&b, "| Service | Type | Task Definition | Containers |\n")
+	fmt.Fprintf(&b, "|---------|------|-----------------|------------|\n")
+	for _, taskDefInfo := range taskDefInfos {
+		taskDefArn := ""
+		if taskDefInfo.Source != nil {
+			taskDefArn = aws.ToString(taskDefInfo.Source.TaskDefinitionArn)
+		}
+		var containers []string
+		for _, c := range taskDefInfo.Containers {
+			containers = append(containers, c.Name)
+		}
+		workloadType := "service"
+		if isWorker(taskDefInfo) {
+			workloadType = "worker"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", taskDefInfo.Name, workloadType, taskDefArn, strings.Join(containers, ", "))
+	}
+
+	writeAWSBatchSection(&b, batch)
+	writeClusterSection(&b, clusterName, settings)
+	writeFieldMappingSection(&b, taskDefInfos)
+	writeNotesSection(&b, taskDefInfos)
+	writeLintSection(&b, taskDefInfos)
+	writeRightsizingSection(&b, taskDefInfos)
+	writeZonesSection(&b, taskDefInfos)
+	writeIdentitySection(&b, taskDefInfos)
+	writeUnmappedSection(&b, taskDefInfos)
+
+	reportPath := filepath.Join(outputDir, reportFileName)
+	if err := os.WriteFile(reportPath, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", reportPath, err)
+	}
+
+	return nil
+}
+
+// writeAWSBatchSection lists the task definitions managed by AWS Batch, which
+// are not converted as services
+func writeAWSBatchSection(b *strings.Builder, batch []*AWSBatchTaskDefinition) {
+	if len(batch) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## AWS Batch\n\n")
+	fmt.Fprintf(b, "AWS Batch runs these task definitions as jobs; converting them as services would keep restarting containers meant to exit.\n\n")
+	fmt.Fprintf(b, "| Task Definition | Detected By | Output |\n")
+	fmt.Fprintf(b, "|-----------------|-------------|--------|\n")
+	for _, entry := range batch {
+		output := "excluded"
+		if entry.File != "" {
+			output = jobsDirName + "/" + entry.File
+		}
+		name := entry.Name
+		if entry.TaskDefinitionArn != "" {
+			name = entry.TaskDefinitionArn
+		}
+		fmt.Fprintf(b, "| %s | %s | %s |\n", name, entry.Reason, output)
+	}
+}
+
+// fieldMapping pairs a source ECS field with the Kubernetes fields it became.
+// A mapping without Kubernetes fields was dropped.
+type fieldMapping struct {
+	ECS        string
+	Kubernetes []string
+}
+
+// writeFieldMappingSection shows the key ECS fields of each service next to the
+// Kubernetes fields they became, for reviewers validating the conversion
+func writeFieldMappingSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	hasMappings := false
+	for _, taskDefInfo := range taskDefInfos {
+		mappings := fieldMappings(taskDefInfo)
+		if len(mappings) == 0 {
+			continue
+		}
+		if !hasMappings {
+			fmt.Fprintf(b, "\n## Field Mapping\n")
+			hasMappings = true
+		}
+		fmt.Fprintf(b, "\n### %s\n\n", taskDefInfo.Name)
+		fmt.Fprintf(b, "| ECS | Kubernetes |\n")
+		fmt.Fprintf(b, "|-----|------------|\n")
+		for _, m := range mappings {
+			converted := "dropped"
+			if len(m.Kubernetes) > 0 {
+				converted = "`" + strings.Join(m.Kubernetes, "`<br>`") + "`"
+			}
+			fmt.Fprintf(b, "| `%s` | %s |\n", m.ECS, converted)
+		}
+	}
+}
+
+// fieldMappings lists the task definition and container fields of a service
+// with the values of the generated Deployment they map to
+func fieldMappings(taskDefInfo *TaskDefInfo) []fieldMapping {
+	taskDef := taskDefInfo.Source
+	podSpec := taskDefInfo.Manifests.Deployment
+	if taskDef == nil || podSpec == nil {
+		return nil
+	}
+
+	mappings := []fieldMapping{{
+		ECS:        "family: " + aws.ToString(taskDef.Family),
+		Kubernetes: []string{"metadata.name: " + taskDefInfo.Name},
+	}}
+	if role := aws.ToString(taskDef.TaskRoleArn); role != "" {
+		m := fieldMapping{ECS: "taskRoleArn: " + role}
+		if sa := taskDefInfo.Manifests.ServiceAccount; sa != nil {
+			for _, key := range sortedKeys(sa.Annotations) {
+				m.Kubernetes = append(m.Kubernetes, fmt.Sprintf("serviceAccount %s: %s=%s", sa.Name, key, sa.Annotations[key]))
+			}
+		}
+		mappings = append(mappings, m)
+	}
+
+	for _, def := range taskDef.ContainerDefinitions {
+		var c *corev1.Container
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == sanitizeName(aws.ToString(def.Name)) {
+				c = &podSpec.Containers[i]
+			}
+		}
+		if c == nil {
+			// Containers of other services with --split-containers
+			continue
+		}
+		prefix := fmt.Sprintf("containerDefinitions[%s].", aws.ToString(def.Name))
+		field := fmt.Sprintf("containers[%s].", c.Name)
+
+		mappings = append(mappings, fieldMapping{
+			ECS:        prefix + "image: " + aws.ToString(def.Image),
+			Kubernetes: []string{field + "image: " + c.Image},
+		})
+		mappings = append(mappings, fieldMapping{
+			ECS:        fmt.Sprintf("%scpu: %d", prefix, def.Cpu),
+			Kubernetes: resourceFields(field, c.Resources, corev1.ResourceCPU),
+		})
+		memory := prefix + "memory: " + optionalInt32(def.Memory)
+		if def.MemoryReservation != nil {
+			memory += ", memoryReservation: " + optionalInt32(def.MemoryReservation)
+		}
+		mappings = append(mappings, fieldMapping{ECS: memory, Kubernetes: resourceFields(field, c.Resources, corev1.ResourceMemory)})
+		for _, pm := range def.PortMappings {
+			m := fieldMapping{ECS: fmt.Sprintf("%sportMappings: %d/%s", prefix, aws.ToInt32(pm.ContainerPort), strings.ToLower(string(pm.Protocol)))}
+			for _, port := range c.Ports {
+				if port.ContainerPort == aws.ToInt32(pm.ContainerPort) {
+					m.Kubernetes = append(m.Kubernetes, fmt.Sprintf("%sports: %d/%s", field, port.ContainerPort, port.Protocol))
+				}
+			}
+			mappings = append(mappings, m)
+		}
+		if len(def.Environment) > 0 {
+			mappings = append(mappings, fieldMapping{
+				ECS:        fmt.Sprintf("%senvironment: %d variable(s)", prefix, len(def.Environment)),
+				Kubernetes: envFields(field, taskDefInfo.Manifests, c),
+			})
+		}
+		if len(def.Secrets) > 0 {
+			m := fieldMapping{ECS: fmt.Sprintf("%ssecrets: %d secret(s)", prefix, len(def.Secrets))}
+			var refs int
+			for _, env := range c.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					refs++
+				}
+			}
+			if refs > 0 {
+				m.Kubernetes = []string{fmt.Sprintf("%senv: %d secretKeyRef(s)", field, refs)}
+			}
+			mappings = append(mappings, m)
+		}
+	}
+
+	for _, unmapped := range taskDefInfo.Unmapped {
+		mappings = append(mappings, fieldMapping{ECS: unmapped})
+	}
+	return mappings
+}
+
+// resourceFields lists the request and limit of a resource of a container
+func resourceFields(field string, resources corev1.ResourceRequirements, name corev1.ResourceName) []string {
+	var fields []string
+	if q, ok := resources.Requests[name]; ok {
+		fields = append(fields, fmt.Sprintf("%sresources.requests.%s: %s", field, name, q.String()))
+	}
+	if q, ok := resources.Limits[name]; ok {
+		fields = append(fields, fmt.Sprintf("%sresources.limits.%s: %s", field, name, q.String()))
+	}
+	return fields
+}
+
+// envFields lists where the environment of a container went: plain env
+// values and the ConfigMaps and Secrets generated from it
+func envFields(field string, manifests K8sManifests, c *corev1.Container) []string {
+	var values int
+	for _, env := range c.Env {
+		if env.ValueFrom == nil {
+			values++
+		}
+	}
+
+	var fields []string
+	if values > 0 {
+		fields = append(fields, fmt.Sprintf("%senv: %d value(s)", field, values))
+	}
+	for _, cm := range manifests.ConfigMaps {
+		if cm.Name == sanitizeName(c.Name+"-config") {
+			fields = append(fields, fmt.Sprintf("configMap %s: %d key(s)", cm.Name, len(cm.Data)))
+		}
+	}
+	for _, secret := range manifests.Secrets {
+		if secret.Name == sanitizeName(c.Name+"-secret") {
+			fields = append(fields, fmt.Sprintf("secret %s: %d key(s)", secret.Name, len(secret.StringData)+len(secret.Data)))
+		}
+	}
+	return fields
+}
+
+// optionalInt32 formats an optional ECS integer, "-" when unset
+func optionalInt32(v *int32) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+// writeNotesSection lists the conversion decisions recorded per service
+func writeNotesSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	hasNotes := false
+	for _, taskDefInfo := range taskDefInfos {
+		if len(taskDefInfo.Notes) == 0 {
+			continue
+		}
+		if !hasNotes {
+			fmt.Fprintf(b, "\n## Notes\n")
+			hasNotes = true
+		}
+		fmt.Fprintf(b, "\n### %s\n\n", taskDefInfo.Name)
+		for _, note := range taskDefInfo.Notes {
+			fmt.Fprintf(b, "- %s\n", note)
+		}
+	}
+}
+
+// writeLintSection lists the best-practice findings of --lint, most severe first
+func writeLintSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	counts := map[string]int{}
+	var rows []string
+	for _, taskDefInfo := range taskDefInfos {
+		for _, finding := range taskDefInfo.Lint {
+			counts[finding.Severity]++
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %s |",
+				taskDefInfo.Name, finding.Severity, finding.Check, finding.File, finding.Message))
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## Lint\n\n")
+	fmt.Fprintf(b, "%d error(s), %d warning(s), %d info finding(s) in the generated objects. Severities are set with `--lint-severity`.\n\n",
+		counts[lintError], counts[lintWarning], counts[lintInfo])
+	fmt.Fprintf(b, "| Service | Severity | Check | File | Finding |\n")
+	fmt.Fprintf(b, "|---------|----------|-------|------|---------|\n")
+	for _, row := range rows {
+		fmt.Fprintln(b, row)
+	}
+}
+
+// writeRightsizingSection documents requests changed by --rightsize
+func writeRightsizingSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	var rows []string
+	for _, taskDefInfo := range taskDefInfos {
+		for _, change := range taskDefInfo.Rightsizing {
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %s | %.1f%% | %d |",
+				taskDefInfo.Name, change.Container, change.Resource, change.Before, change.After, change.Utilization, change.Samples))
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## Rightsizing\n\n")
+	fmt.Fprintf(b, "Requests were derived from CloudWatch utilization instead of the ECS reservations. Limits are unchanged.\n\n")
+	fmt.Fprintf(b, "| Service | Container | Resource | ECS Request | New Request | Observed Utilization | Datapoints |\n")
+	fmt.Fprintf(b, "|---------|-----------|----------|-------------|-------------|----------------------|------------|\n")
+	for _, row := range rows {
+		fmt.Fprintln(b, row)
+	}
+}
+
+// writeZonesSection documents the availability zones of the ECS services and
+// the constraints keeping them on Kubernetes
+func writeZonesSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	var rows []string
+	for _, taskDefInfo := range taskDefInfos {
+		if zones := taskDefInfo.Zones; zones != nil {
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s |",
+				taskDefInfo.Name, strings.Join(zones.Subnets, ", "), strings.Join(zones.Zones, ", "), zones.Placement))
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## Zone Footprint\n\n")
+	fmt.Fprintf(b, "Availability zones of the subnets each ECS service runs in. Zone names are per AWS account; check them when the Kubernetes cluster runs in another account.\n\n")
+	fmt.Fprintf(b, "| Service | Subnets | Zones | Kubernetes |\n")
+	fmt.Fprintf(b, "|---------|---------|-------|------------|\n")
+	for _, row := range rows {
+		fmt.Fprintln(b, row)
+	}
+}
+
+// writeIdentitySection documents the workload identities replacing the ECS IAM roles
+func writeIdentitySection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	var bound []*TaskDefInfo
+	for _, taskDefInfo := range taskDefInfos {
+		if taskDefInfo.Identity != nil {
+			bound = append(bound, taskDefInfo)
+		}
+	}
+	if len(bound) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## Workload Identity\n\n")
+	fmt.Fprintf(b, "The ECS IAM roles cannot be assumed outside EKS. Grant each identity the permissions of its role; placeholders come from roles missing in `--identity-map`.\n\n")
+	fmt.Fprintf(b, "| Service | ServiceAccount | ECS Role | Annotation | Identity |\n")
+	fmt.Fprintf(b, "|---------|----------------|----------|------------|----------|\n")
+	for _, taskDefInfo := range bound {
+		binding := taskDefInfo.Identity
+		identity := fmt.Sprintf("`%s`", binding.Identity)
+		if !binding.Mapped {
+			identity += " (placeholder)"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | `%s` | %s |\n", taskDefInfo.Name, binding.ServiceAccount, binding.RoleARN, binding.Annotation, identity)
+	}
+}
+
+// writeUnmappedSection lists source fields that were dropped during conversion
+func writeUnmappedSection(b *strings.Builder, taskDefInfos []*TaskDefInfo) {
+	hasUnmapped := false
+	for _, taskDefInfo := range taskDefInfos {
+		if len(taskDefInfo.Unmapped) == 0 {
+			continue
+		}
+		if !hasUnmapped {
+			fmt.Fprintf(b, "\n## Unconverted Fields\n\n")
+			fmt.Fprintf(b, "These ECS fields are set in the source but have no equivalent in the generated manifests. Review them before cutting over.\n")
+			hasUnmapped = true
+		}
+		fmt.Fprintf(b, "\n### %s\n\n", taskDefInfo.Name)
+		for _, field := range taskDefInfo.Unmapped {
+			fmt.Fprintf(b, "- `%s`\n", field)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestFieldMappingSection tests listing ECS fields next to the Kubernetes fields they became
+func TestFieldMappingSection(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		Family:      aws.String("web"),
+		TaskRoleArn: aws.String("arn:aws:iam::123456789012:role/web"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:              aws.String("web_app"),
+			Image:             aws.String("web:1"),
+			Cpu:               256,
+			Memory:            aws.Int32(1024),
+			MemoryReservation: aws.Int32(512),
+			PortMappings:      []types.PortMapping{{ContainerPort: aws.Int32(8080), Protocol: types.TransportProtocolTcp}},
+			Environment:       []types.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("prod")}},
+		}},
+	}
+	info, err := buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	info.Unmapped = []string{"volumes"}
+
+	dir := t.TempDir()
+	if err := writeConversionReport(dir, "shop", "us-east-1", nil, []*TaskDefInfo{info}, nil); err != nil {
+		t.Fatalf("writeConversionReport() error = %v", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## Field Mapping",
+		"| `family: web` | `metadata.name: web` |",
+		"| `taskRoleArn: arn:aws:iam::123456789012:role/web` | `serviceAccount ",
+		"| `containerDefinitions[web_app].image: web:1` | `containers[web-app].image: web:1` |",
+		"| `containerDefinitions[web_app].cpu: 256` | `containers[web-app].resources.requests.cpu: 256m`<br>`containers[web-app].resources.limits.cpu: 256m` |",
+		"| `containerDefinitions[web_app].memory: 1024, memoryReservation: 512` | `containers[web-app].resources.requests.memory: 512Mi`<br>`containers[web-app].resources.limits.memory: 1Gi` |",
+		"| `containerDefinitions[web_app].portMappings: 8080/tcp` | `containers[web-app].ports: 8080/TCP` |",
+		"| `volumes` | dropped |",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ecsResourcesAnnotation records the ECS cpu units, memory and memoryReservation MiB of each
+// container on the pod template, so converted values can be audited against the source
+const ecsResourcesAnnotation = "ecs2k8s.io/ecs-resources"
+
+// Annotations recording the task-level ECS values on the Deployment, so its
+// settings can be compared against the source long after the migration
+const (
+	originalCPUAnnotation     = "ecs2k8s.io/original-cpu"
+	originalMemoryAnnotation  = "ecs2k8s.io/original-memory"
+	taskDefRevisionAnnotation = "ecs2k8s.io/taskdef-revision"
+)
+
+// lowCPUUnits is the ECS cpu below which a container is reported without
+// --min-cpu, since LimitRange minimums commonly reject smaller requests
+const lowCPUUnits = 100
+
+// Limits of cpuToQuantity and memoryToQuantity
+const (
+	maxCPUUnits  = 16000
+	maxMemoryMiB = 262144
+)
+
+// resourceFloor is the minimum CPU and memory of every container (--min-cpu, --min-memory)
+type resourceFloor struct {
+	CPU    *resource.Quantity
+	Memory *resource.Quantity
+}
+
+// parseResourceFloor parses --min-cpu and --min-memory. It returns nil when neither is set.
+func parseResourceFloor(cpu, memory string) (*resourceFloor, error) {
+	if cpu == "" && memory == "" {
+		return nil, nil
+	}
+
+	floor := &resourceFloor{}
+	for _, f := range []struct {
+		flag  string
+		value string
+		dest  **resource.Quantity
+	}{
+		{"--min-cpu", cpu, &floor.CPU},
+		{"--min-memory", memory, &floor.Memory},
+	} {
+		if f.value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", f.flag, f.value, err)
+		}
+		if q.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid %s %q (must be positive)", f.flag, f.value)
+		}
+		*f.dest = &q
+	}
+	return floor, nil
+}
+
+// auditResources records the ECS resources of each container, reports values
+// that were defaulted, capped or are below common LimitRange minimums, and
+// raises requests and limits to the floor
+func auditResources(taskDefInfo *TaskDefInfo, floor *resourceFloor) {
+	if taskDefInfo.Source == nil || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	source := map[string]map[string]int32{}
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		if def.Name == nil || *def.Name == "" {
+			continue
+		}
+		name := sanitizeName(*def.Name)
+
+		values := map[string]int32{}
+		switch {
+		case def.Cpu <= 0:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s has no ECS cpu; its CPU defaulted to 100m", name))
+		case def.Cpu > maxCPUUnits:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: ECS cpu %d was capped at %dm", name, def.Cpu, maxCPUUnits))
+		case def.Cpu < lowCPUUnits && (floor == nil || floor.CPU == nil):
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: ECS cpu %d maps to %dm, which LimitRange minimums may reject; raise it with --min-cpu", name, def.Cpu, def.Cpu))
+		}
+		if def.Cpu > 0 {
+			values["cpu"] = def.Cpu
+		}
+
+		hasMemory := def.Memory != nil && *def.Memory > 0
+		hasReservation := def.MemoryReservation != nil && *def.MemoryReservation > 0
+		switch {
+		case !hasMemory && !hasReservation:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s has no ECS memory; its memory defaulted to 128Mi", name))
+		case !hasMemory:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s has only an ECS memoryReservation; it requests %dMi without a memory limit", name, *def.MemoryReservation))
+		case *def.Memory > maxMemoryMiB:
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: ECS memory %d was capped at 256Gi", name, *def.Memory))
+		}
+		if hasMemory {
+			values["memory"] = *def.Memory
+		}
+		if hasReservation {
+			values["memoryReservation"] = *def.MemoryReservation
+		}
+		source[name] = values
+	}
+
+	if data, err := json.Marshal(source); err != nil {
+		log.Printf("Warning: Failed to record the ECS resources of %s: %v", taskDefInfo.Name, err)
+	} else {
+		if taskDefInfo.Manifests.PodAnnotations == nil {
+			taskDefInfo.Manifests.PodAnnotations = map[string]string{}
+		}
+		taskDefInfo.Manifests.PodAnnotations[ecsResourcesAnnotation] = string(data)
+	}
+	recordOriginalValues(taskDefInfo)
+
+	if floor != nil {
+		floor.apply(taskDefInfo)
+	}
+}
+
+// recordOriginalValues annotates the Deployment with the task cpu, memory and
+// revision of the ECS task definition. Task cpu and memory are only set for
+// Fargate and tasks sized at the task level.
+func recordOriginalValues(taskDefInfo *TaskDefInfo) {
+	source := taskDefInfo.Source
+	annotations := map[string]string{}
+	if cpu := aws.ToString(source.Cpu); cpu != "" {
+		annotations[originalCPUAnnotation] = cpu
+	}
+	if memory := aws.ToString(source.Memory); memory != "" {
+		annotations[originalMemoryAnnotation] = memory
+	}
+	if source.Revision > 0 {
+		annotations[taskDefRevisionAnnotation] = strconv.Itoa(int(source.Revision))
+	}
+	if len(annotations) == 0 {
+		return
+	}
+
+	if taskDefInfo.Manifests.DeploymentAnnotations == nil {
+		taskDefInfo.Manifests.DeploymentAnnotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		taskDefInfo.Manifests.DeploymentAnnotations[key] = value
+	}
+}
+
+// apply raises the requests and limits of every container to the floor
+func (f *resourceFloor) apply(taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		for _, r := range []struct {
+			name  corev1.ResourceName
+			floor *resource.Quantity
+		}{
+			{corev1.ResourceCPU, f.CPU},
+			{corev1.ResourceMemory, f.Memory},
+		} {
+			if r.floor == nil {
+				continue
+			}
+			for _, l := range []struct {
+				kind string
+				list corev1.ResourceList
+			}{
+				{"request", c.Resources.Requests},
+				{"limit", c.Resources.Limits},
+			} {
+				current, ok := l.list[r.name]
+				if !ok || current.Cmp(*r.floor) >= 0 {
+					continue
+				}
+				l.list[r.name] = r.floor.DeepCopy()
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s: %s %s raised from %s to %s", c.Name, r.name, l.kind, current.String(), r.floor.String()))
+			}
+		}
+	}
+
+	// The Helm chart renders resources from the container configs
+	for i := range taskDefInfo.Containers {
+		c := &taskDefInfo.Containers[i]
+		c.CPU = raiseQuantity(c.CPU, f.CPU)
+		c.RequestCPU = raiseQuantity(c.RequestCPU, f.CPU)
+		c.Memory = raiseQuantity(c.Memory, f.Memory)
+		c.RequestMemory = raiseQuantity(c.RequestMemory, f.Memory)
+	}
+}
+
+// raiseQuantity returns value, or floor when value is set and smaller
+func raiseQuantity(value string, floor *resource.Quantity) string {
+	if value == "" || floor == nil {
+		return value
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Cmp(*floor) >= 0 {
+		return value
+	}
+	return floor.String()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestAuditResources tests recording the ECS values, reporting adjustments and applying the floor
+func TestAuditResources(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("web"), Image: aws.String("web:1"), Cpu: 10, Memory: aws.Int32(32)},
+			{Name: aws.String("log_router"), Image: aws.String("fluent-bit:1")},
+		},
+	}
+	newInfo := func() *TaskDefInfo {
+		info, err := buildTaskDefInfo(taskDef, "web")
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo() error = %v", err)
+		}
+		return info
+	}
+
+	info := newInfo()
+	auditResources(info, nil)
+	if got := info.Manifests.PodAnnotations[ecsResourcesAnnotation]; got != `{"log-router":{},"web":{"cpu":10,"memory":32}}` {
+		t.Errorf("%s = %s", ecsResourcesAnnotation, got)
+	}
+	for _, want := range []string{
+		"Container web: ECS cpu 10 maps to 10m",
+		"Container log-router has no ECS cpu; its CPU defaulted to 100m",
+		"Container log-router has no ECS memory; its memory defaulted to 128Mi",
+	} {
+		if !hasNote(info.Notes, want) {
+			t.Errorf("notes = %v, want %q", info.Notes, want)
+		}
+	}
+
+	if _, err := parseResourceFloor("-1", ""); err == nil {
+		t.Errorf("parseResourceFloor(-1) succeeded")
+	}
+	floor, err := parseResourceFloor("50m", "64Mi")
+	if err != nil {
+		t.Fatalf("parseResourceFloor() error = %v", err)
+	}
+
+	floored := newInfo()
+	auditResources(floored, floor)
+	resources := floored.Manifests.Deployment.Containers[0].Resources
+	if cpu := resources.Requests[corev1.ResourceCPU]; cpu.String() != "50m" {
+		t.Errorf("cpu request = %s, want 50m", cpu.String())
+	}
+	if memory := resources.Limits[corev1.ResourceMemory]; memory.String() != "64Mi" {
+		t.Errorf("memory limit = %s, want 64Mi", memory.String())
+	}
+	if sidecar := floored.Manifests.Deployment.Containers[1].Resources.Requests[corev1.ResourceCPU]; sidecar.String() != "100m" {
+		t.Errorf("sidecar cpu request = %s, want the 100m default kept", sidecar.String())
+	}
+	if floored.Containers[0].CPU != "50m" || floored.Containers[0].Memory != "64Mi" {
+		t.Errorf("container config = %+v", floored.Containers[0])
+	}
+	if !hasNote(floored.Notes, "Container web: cpu request raised from 10m to 50m") || hasNote(floored.Notes, "maps to 10m") {
+		t.Errorf("notes = %v", floored.Notes)
+	}
+}
+
+// TestMemoryReservation tests mapping memoryReservation to the request and memory to the limit
+func TestMemoryReservation(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("web"), Image: aws.String("web:1"), Memory: aws.Int32(1024), MemoryReservation: aws.Int32(512)},
+			{Name: aws.String("worker"), Image: aws.String("worker:1"), MemoryReservation: aws.Int32(256)},
+		},
+	}
+	info, err := buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	web := info.Manifests.Deployment.Containers[0].Resources
+	if request, limit := web.Requests[corev1.ResourceMemory], web.Limits[corev1.ResourceMemory]; request.String() != "512Mi" || limit.String() != "1Gi" {
+		t.Errorf("web memory request = %s, limit = %s, want 512Mi and 1Gi", request.String(), limit.String())
+	}
+	worker := info.Manifests.Deployment.Containers[1].Resources
+	if request := worker.Requests[corev1.ResourceMemory]; request.String() != "256Mi" {
+		t.Errorf("worker memory request = %s, want 256Mi", request.String())
+	}
+	if _, ok := worker.Limits[corev1.ResourceMemory]; ok {
+		t.Errorf("worker has a memory limit without an ECS hard limit")
+	}
+	if info.Containers[0].Memory != "1Gi" || info.Containers[0].RequestMemory != "512Mi" || info.Containers[1].Memory != "" {
+		t.Errorf("container configs = %+v", info.Containers)
+	}
+
+	auditResources(info, nil)
+	if got := info.Manifests.PodAnnotations[ecsResourcesAnnotation]; got != `{"web":{"memory":1024,"memoryReservation":512},"worker":{"memoryReservation":256}}` {
+		t.Errorf("%s = %s", ecsResourcesAnnotation, got)
+	}
+	if !hasNote(info.Notes, "Container worker has only an ECS memoryReservation") || hasNote(info.Notes, "memory defaulted") {
+		t.Errorf("notes = %v", info.Notes)
+	}
+}
+
+// TestRecordOriginalValues tests annotating the Deployment with the task-level ECS values
+func TestRecordOriginalValues(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		Family:   aws.String("web"),
+		Revision: 42,
+		Cpu:      aws.String("256"),
+		Memory:   aws.String("512"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("web"), Image: aws.String("web:1")},
+		},
+	}
+	info, err := buildTaskDefInfo(taskDef, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	auditResources(info, nil)
+
+	files, err := renderManifests("web", info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	annotations := files["web-deployment.yaml"].(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]string)
+	want := map[string]string{
+		originalCPUAnnotation:     "256",
+		originalMemoryAnnotation:  "512",
+		taskDefRevisionAnnotation: "42",
+	}
+	for key, value := range want {
+		if annotations[key] != value {
+			t.Errorf("%s = %q, want %q", key, annotations[key], value)
+		}
+	}
+
+	// EC2 tasks sized per container have no task cpu and memory
+	taskDef.Cpu, taskDef.Memory = nil, nil
+	info, _ = buildTaskDefInfo(taskDef, "web")
+	auditResources(info, nil)
+	if _, ok := info.Manifests.DeploymentAnnotations[originalCPUAnnotation]; ok || info.Manifests.DeploymentAnnotations[taskDefRevisionAnnotation] != "42" {
+		t.Errorf("annotations = %v, want only the revision", info.Manifests.DeploymentAnnotations)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// rightsizeHeadroom is added on top of the observed percentile usage
+	rightsizeHeadroom = 1.15
+	// rightsizeMinCPUMillis and rightsizeMinMemoryMiB are the lowest requests set
+	rightsizeMinCPUMillis = 10
+	rightsizeMinMemoryMiB = 32
+)
+
+// ResourceChange records a request changed by rightsizing, for the report
+type ResourceChange struct {
+	Container string
+	Resource  string
+	Before    string
+	After     string
+	// Utilization is the observed percentile utilization of the task reservation
+	Utilization float64
+	Samples     int
+}
+
+// rightsizer sets container requests from CloudWatch ECS service utilization
+type rightsizer struct {
+	client     *cloudwatch.Client
+	cluster    string
+	days       int
+	percentile float64
+}
+
+// isValidRightsizeWindow checks the --rightsize-days and --rightsize-percentile values
+func isValidRightsizeWindow(days int, percentile float64) error {
+	// CloudWatch keeps 1-hour datapoints for 455 days
+	if days < 1 || days > 455 {
+		return fmt.Errorf("--rightsize-days must be between 1 and 455 (got %d)", days)
+	}
+	if percentile <= 0 || percentile > 100 {
+		return fmt.Errorf("--rightsize-percentile must be in (0, 100] (got %g)", percentile)
+	}
+	return nil
+}
+
+// apply rightsizes the requests of a converted task definition using the
+// utilization of the ECS services running it
+func (r *rightsizer) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || taskDefInfo.Source == nil {
+		return
+	}
+	if len(taskDefInfo.Services) == 0 {
+		log.Printf("Info: No ECS service runs %s, keeping ECS reservations as requests", taskDefInfo.Name)
+		return
+	}
+
+	taskCPU, taskMemory := taskReservation(taskDefInfo)
+
+	for _, metric := range []struct {
+		name     string
+		resource corev1.ResourceName
+		reserved float64
+	}{
+		{"CPUUtilization", corev1.ResourceCPU, taskCPU},
+		{"MemoryUtilization", corev1.ResourceMemory, taskMemory},
+	} {
+		if metric.reserved <= 0 {
+			continue
+		}
+
+		var values []float64
+		for _, svc := range taskDefInfo.Services {
+			serviceValues, err := r.serviceMetric(ctx, aws.ToString(svc.ServiceName), metric.name)
+			if err != nil {
+				log.Printf("Warning: Failed to read %s for service %s: %v", metric.name, aws.ToString(svc.ServiceName), err)
+				continue
+			}
+			values = append(values, serviceValues...)
+		}
+
+		if len(values) == 0 {
+			log.Printf("Warning: No %s datapoints for %s in the last %d day(s), keeping ECS reservations", metric.name, taskDefInfo.Name, r.days)
+			continue
+		}
+
+		utilization := percentile(values, r.percentile)
+		// ECS utilization is a percentage of the task reservation
+		observed := utilization / 100 * metric.reserved
+		changes := scaleRequests(podSpec, metric.resource, observed)
+		for i := range changes {
+			changes[i].Utilization = utilization
+			changes[i].Samples = len(values)
+		}
+		taskDefInfo.Rightsizing = append(taskDefInfo.Rightsizing, changes...)
+
+		syncContainerRequests(taskDefInfo)
+	}
+}
+
+// serviceMetric returns the per-period average of an AWS/ECS service metric
+func (r *rightsizer) serviceMetric(ctx context.Context, serviceName, metricName string) ([]float64, error) {
+	end := time.Now()
+	start := end.AddDate(0, 0, -r.days)
+
+	// CloudWatch keeps 1-minute datapoints for 15 days, read in 5-minute
+	// periods; longer windows use hourly datapoints
+	period := int32(300)
+	if r.days > 15 {
+		period = 3600
+	}
+
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(start),
+		EndTime:   aws.Time(end),
+		MetricDataQueries: []cwtypes.MetricDataQuery{
+			{
+				Id: aws.String("usage"),
+				MetricStat: &cwtypes.MetricStat{
+					Metric: &cwtypes.Metric{
+						Namespace:  aws.String("AWS/ECS"),
+						MetricName: aws.String(metricName),
+						Dimensions: []cwtypes.Dimension{
+							{Name: aws.String("ClusterName"), Value: aws.String(r.cluster)},
+							{Name: aws.String("ServiceName"), Value: aws.String(serviceName)},
+						},
+					},
+					Period: aws.Int32(period),
+					Stat:   aws.String("Average"),
+				},
+			},
+		},
+	}
+
+	var values []float64
+	paginator := cloudwatch.NewGetMetricDataPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metric data: %w", err)
+		}
+		for _, result := range page.MetricDataResults {
+			values = append(values, result.Values...)
+		}
+	}
+
+	return values, nil
+}
+
+// taskReservation returns the CPU units and memory MiB reserved by a task,
+// falling back to the sum of container reservations without task-level sizes
+func taskReservation(taskDefInfo *TaskDefInfo) (cpu, memory float64) {
+	taskDef := taskDefInfo.Source
+
+	if v, err := strconv.ParseFloat(aws.ToString(taskDef.Cpu), 64); err == nil {
+		cpu = v
+	}
+	if v, err := strconv.ParseFloat(aws.ToString(taskDef.Memory), 64); err == nil {
+		memory = v
+	}
+
+	if cpu == 0 || memory == 0 {
+		var sumCPU, sumMemory float64
+		for _, c := range taskDef.ContainerDefinitions {
+			sumCPU += float64(c.Cpu)
+			if c.Memory != nil {
+				sumMemory += float64(*c.Memory)
+			} else if c.MemoryReservation != nil {
+				sumMemory += float64(*c.MemoryReservation)
+			}
+		}
+		if cpu == 0 {
+			cpu = sumCPU
+		}
+		if memory == 0 {
+			memory = sumMemory
+		}
+	}
+
+	return cpu, memory
+}
+
+// scaleRequests distributes an observed task-level usage (CPU units or MiB)
+// across containers in proportion to their current requests
+func scaleRequests(podSpec *corev1.PodSpec, name corev1.ResourceName, observed float64) []ResourceChange {
+	var total float64
+	for _, c := range podSpec.Containers {
+		total += requestValue(c.Resources.Requests, name)
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	var changes []ResourceChange
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		current := requestValue(c.Resources.Requests, name)
+		if current <= 0 {
+			continue
+		}
+
+		target := observed * (current / total) * rightsizeHeadroom
+		var qty resource.Quantity
+		if name == corev1.ResourceCPU {
+			millis := int64(math.Ceil(math.Max(target, rightsizeMinCPUMillis)))
+			qty = *resource.NewMilliQuantity(millis, resource.DecimalSI)
+		} else {
+			mib := int64(math.Ceil(math.Max(target, rightsizeMinMemoryMiB)))
+			qty = *resource.NewQuantity(mib*1024*1024, resource.BinarySI)
+		}
+
+		// Never request more than the limit
+		if limit, ok := c.Resources.Limits[name]; ok && qty.Cmp(limit) > 0 {
+			qty = limit.DeepCopy()
+		}
+
+		before := c.Resources.Requests[name]
+		c.Resources.Requests[name] = qty
+		changes = append(changes, ResourceChange{
+			Container: c.Name,
+			Resource:  string(name),
+			Before:    before.String(),
+			After:     qty.String(),
+		})
+	}
+
+	return changes
+}
+
+// requestValue returns a request in ECS units: CPU millicores or memory MiB
+func requestValue(requests corev1.ResourceList, name corev1.ResourceName) float64 {
+	qty, ok := requests[name]
+	if !ok {
+		return 0
+	}
+	if name == corev1.ResourceCPU {
+		return float64(qty.MilliValue())
+	}
+	return float64(qty.Value()) / (1024 * 1024)
+}
+
+// syncContainerRequests copies rightsized requests to the container configs
+// used for Helm values
+func syncContainerRequests(taskDefInfo *TaskDefInfo) {
+	for _, c := range taskDefInfo.Manifests.Deployment.Containers {
+		for i := range taskDefInfo.Containers {
+			if taskDefInfo.Containers[i].Name != c.Name {
+				continue
+			}
+			if qty, ok := c.Resources.Requests[corev1.ResourceCPU]; ok {
+				taskDefInfo.Containers[i].RequestCPU = qty.String()
+			}
+			if qty, ok := c.Resources.Requests[corev1.ResourceMemory]; ok {
+				taskDefInfo.Containers[i].RequestMemory = qty.String()
+			}
+		}
+	}
+}
+
+// percentile returns the p-th percentile (0-100] of values using nearest-rank
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// TestPercentile tests nearest-rank percentile selection
+func TestPercentile(t *testing.T) {
+	values := []float64{50, 10, 40, 20, 30, 60, 70, 80, 90, 100}
+
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{p: 50, want: 50},
+		{p: 95, want: 100},
+		{p: 90, want: 90},
+		{p: 1, want: 10},
+		{p: 100, want: 100},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); got != tt.want {
+			t.Errorf("percentile(p%g) = %g, want %g", tt.p, got, tt.want)
+		}
+	}
+
+	if got := percentile(nil, 95); got != 0 {
+		t.Errorf("percentile(nil) = %g, want 0", got)
+	}
+}
+
+// TestScaleRequests tests distributing observed task usage across containers
+func TestScaleRequests(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("768m")},
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("768m")},
+				},
+			},
+			{
+				Name: "sidecar",
+				Resources: corev1.ResourceRequirements{
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("256m")},
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("256m")},
+				},
+			},
+		},
+	}
+
+	// 200 CPU units observed for the task: 150 for app, 50 for sidecar, plus headroom
+	changes := scaleRequests(podSpec, corev1.ResourceCPU, 200)
+	if len(changes) != 2 {
+		t.Fatalf("scaleRequests() returned %d changes, want 2", len(changes))
+	}
+
+	want := map[string]string{"app": "173m", "sidecar": "58m"}
+	for _, c := range podSpec.Containers {
+		got := c.Resources.Requests[corev1.ResourceCPU]
+		if got.String() != want[c.Name] {
+			t.Errorf("container %s cpu request = %s, want %s", c.Name, got.String(), want[c.Name])
+		}
+		limit := c.Resources.Limits[corev1.ResourceCPU]
+		if got.Cmp(limit) > 0 {
+			t.Errorf("container %s request %s exceeds limit %s", c.Name, got.String(), limit.String())
+		}
+	}
+}
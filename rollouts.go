@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// defaultProgressDeadlineSeconds is the Kubernetes default progress deadline
+	defaultProgressDeadlineSeconds = 600
+	// circuitBreakerMinFailures is the fewest failed tasks that trip the ECS circuit breaker
+	circuitBreakerMinFailures = 3
+)
+
+const (
+	rolloutsArgo    = "argo"
+	rolloutsFlagger = "flagger"
+)
+
+// rolloutsProviders lists the accepted --rollouts values
+var rolloutsProviders = []string{rolloutsArgo, rolloutsFlagger}
+
+// isValidRollouts checks if the progressive delivery provider is supported
+func isValidRollouts(provider string) bool {
+	if provider == "" {
+		return true
+	}
+	for _, p := range rolloutsProviders {
+		if provider == p {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCircuitBreaker maps the ECS deployment circuit breaker of the task
+// definition's services onto the Deployment's progressDeadlineSeconds. The
+// deadline gives new pods the usual 10 minutes plus the health check grace
+// period before the rollout is reported as failed.
+func applyCircuitBreaker(taskDefInfo *TaskDefInfo) {
+	enabled, rollback := false, false
+	var alarms []string
+	for _, svc := range taskDefInfo.Services {
+		cfg := svc.DeploymentConfiguration
+		if cfg == nil {
+			continue
+		}
+		if cb := cfg.DeploymentCircuitBreaker; cb != nil && cb.Enable {
+			enabled = true
+			rollback = rollback || cb.Rollback
+		}
+		if cfg.Alarms != nil && cfg.Alarms.Enable {
+			alarms = append(alarms, cfg.Alarms.AlarmNames...)
+		}
+	}
+	if !enabled {
+		return
+	}
+
+	deadline := int32(defaultProgressDeadlineSeconds) + healthCheckGracePeriod(taskDefInfo.Services)
+	taskDefInfo.Manifests.ProgressDeadlineSeconds = aws.Int32(deadline)
+
+	note := fmt.Sprintf("ECS deployment circuit breaker mapped to progressDeadlineSeconds: %d; a stalled rollout is marked Progressing=False", deadline)
+	if rollback {
+		note += ", but Kubernetes does not roll back automatically. Use `kubectl rollout undo` or --rollouts for automatic rollback"
+	}
+	taskDefInfo.Notes = append(taskDefInfo.Notes, note)
+	if len(alarms) > 0 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS deployment alarms %v have no Deployment equivalent; port them to the analysis of --rollouts", alarms))
+	}
+}
+
+// applyRollouts adds progressive delivery stubs taking over the automatic
+// rollback of the ECS circuit breaker: an Argo Rollout referencing the
+// Deployment with an AnalysisTemplate, or a Flagger Canary
+func applyRollouts(taskDefInfo *TaskDefInfo, provider string) {
+	if provider == "" || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+	if taskDefInfo.Manifests.StatefulSet != nil {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("%s stubs target Deployments; none were generated for StatefulSet %s", provider, taskDefInfo.Name))
+		return
+	}
+
+	deadline := int32(defaultProgressDeadlineSeconds)
+	if taskDefInfo.Manifests.ProgressDeadlineSeconds != nil {
+		deadline = *taskDefInfo.Manifests.ProgressDeadlineSeconds
+	}
+	labels := map[string]string{"app": taskDefInfo.Name}
+
+	switch provider {
+	case rolloutsArgo:
+		analysisName := taskDefInfo.Name + "-analysis"
+		rollout := map[string]interface{}{
+			"apiVersion": apiVersionArgoRollouts,
+			"kind":       "Rollout",
+			"metadata": map[string]interface{}{
+				"name":   taskDefInfo.Name,
+				"labels": labels,
+			},
+			"spec": map[string]interface{}{
+				"workloadRef": map[string]interface{}{
+					"apiVersion": apiVersionApps,
+					"kind":       "Deployment",
+					"name":       taskDefInfo.Name,
+					// The Deployment is scaled down once the Rollout is healthy
+					"scaleDown": "progressively",
+				},
+				"progressDeadlineSeconds": deadline,
+				"progressDeadlineAbort":   true,
+				"strategy": map[string]interface{}{
+					"canary": map[string]interface{}{
+						"analysis": map[string]interface{}{
+							"templates": []map[string]string{{"templateName": analysisName}},
+						},
+						"steps": []map[string]interface{}{
+							{"setWeight": 20},
+							{"pause": map[string]string{"duration": "2m"}},
+							{"setWeight": 50},
+							{"pause": map[string]string{"duration": "2m"}},
+						},
+					},
+				},
+			},
+		}
+		analysis := map[string]interface{}{
+			"apiVersion": apiVersionArgoRollouts,
+			"kind":       "AnalysisTemplate",
+			"metadata": map[string]interface{}{
+				"name":   analysisName,
+				"labels": labels,
+			},
+			"spec": map[string]interface{}{
+				"metrics": []map[string]interface{}{{
+					// Stub: replace the query with the service's error rate
+					"name":             "success-rate",
+					"interval":         "1m",
+					"failureLimit":     circuitBreakerMinFailures,
+					"successCondition": "result[0] >= 0.99",
+					"provider": map[string]interface{}{
+						"prometheus": map[string]string{
+							"address": "http://prometheus.monitoring:9090",
+							"query":   fmt.Sprintf(`sum(rate(http_requests_total{app="%s",code!~"5.."}[1m])) / sum(rate(http_requests_total{app="%s"}[1m]))`, taskDefInfo.Name, taskDefInfo.Name),
+						},
+					},
+				}},
+			},
+		}
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras,
+			ExtraObject{Suffix: "rollout", Object: rollout},
+			ExtraObject{Suffix: "analysistemplate", Object: analysis},
+		)
+	case rolloutsFlagger:
+		analysis := map[string]interface{}{
+			"interval":   "1m",
+			"threshold":  circuitBreakerMinFailures,
+			"maxWeight":  50,
+			"stepWeight": 10,
+			"metrics": []map[string]interface{}{
+				{"name": "request-success-rate", "interval": "1m", "thresholdRange": map[string]int{"min": 99}},
+				{"name": "request-duration", "interval": "1m", "thresholdRange": map[string]int{"max": 500}},
+			},
+		}
+		spec := map[string]interface{}{
+			"targetRef": map[string]string{
+				"apiVersion": apiVersionApps,
+				"kind":       "Deployment",
+				"name":       taskDefInfo.Name,
+			},
+			"progressDeadlineSeconds": deadline,
+			"analysis":                analysis,
+		}
+		if len(taskDefInfo.Manifests.Services) > 0 && len(taskDefInfo.Manifests.Services[0].Spec.Ports) > 0 {
+			spec["service"] = map[string]interface{}{
+				"port": taskDefInfo.Manifests.Services[0].Spec.Ports[0].Port,
+			}
+		}
+		canary := map[string]interface{}{
+			"apiVersion": apiVersionFlagger,
+			"kind":       "Canary",
+			"metadata": map[string]interface{}{
+				"name":   taskDefInfo.Name,
+				"labels": labels,
+			},
+			"spec": spec,
+		}
+		taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: "canary", Object: canary})
+	}
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Generated %s progressive delivery stubs; review the analysis metrics before use", provider))
+	log.Printf("✓ Created %s rollout stubs for %s", provider, taskDefInfo.Name)
+}
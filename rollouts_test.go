@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyCircuitBreaker tests mapping the ECS circuit breaker to progressDeadlineSeconds
+func TestApplyCircuitBreaker(t *testing.T) {
+	taskDefInfo := &TaskDefInfo{
+		Name: "web",
+		Services: []types.Service{{
+			HealthCheckGracePeriodSeconds: aws.Int32(60),
+			DeploymentConfiguration: &types.DeploymentConfiguration{
+				DeploymentCircuitBreaker: &types.DeploymentCircuitBreaker{Enable: true, Rollback: true},
+			},
+		}},
+	}
+
+	applyCircuitBreaker(taskDefInfo)
+
+	if got := aws.ToInt32(taskDefInfo.Manifests.ProgressDeadlineSeconds); got != 660 {
+		t.Errorf("progressDeadlineSeconds = %d, want 660", got)
+	}
+	if len(taskDefInfo.Notes) != 1 {
+		t.Errorf("notes = %v, want a rollback note", taskDefInfo.Notes)
+	}
+
+	disabled := &TaskDefInfo{Name: "web", Services: []types.Service{{DeploymentConfiguration: &types.DeploymentConfiguration{}}}}
+	applyCircuitBreaker(disabled)
+	if disabled.Manifests.ProgressDeadlineSeconds != nil {
+		t.Errorf("progressDeadlineSeconds set without a circuit breaker")
+	}
+}
+
+// TestApplyRollouts tests the Argo Rollouts and Flagger stubs
+func TestApplyRollouts(t *testing.T) {
+	tests := map[string][]string{
+		rolloutsArgo:    {"Rollout", "AnalysisTemplate"},
+		rolloutsFlagger: {"Canary"},
+		"":              nil,
+	}
+
+	for provider, kinds := range tests {
+		taskDefInfo := &TaskDefInfo{
+			Name:      "web",
+			Manifests: K8sManifests{Deployment: &corev1.PodSpec{}, ProgressDeadlineSeconds: aws.Int32(660)},
+		}
+		applyRollouts(taskDefInfo, provider)
+
+		if len(taskDefInfo.Manifests.Extras) != len(kinds) {
+			t.Fatalf("%q: extras = %d, want %d", provider, len(taskDefInfo.Manifests.Extras), len(kinds))
+		}
+		for i, kind := range kinds {
+			obj := taskDefInfo.Manifests.Extras[i].Object
+			if obj["kind"] != kind {
+				t.Errorf("%q: extra %d kind = %v, want %s", provider, i, obj["kind"], kind)
+			}
+		}
+		if provider != "" {
+			spec := taskDefInfo.Manifests.Extras[0].Object["spec"].(map[string]interface{})
+			if spec["progressDeadlineSeconds"] != int32(660) {
+				t.Errorf("%q: progressDeadlineSeconds = %v, want 660", provider, spec["progressDeadlineSeconds"])
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runLogFileName is the structured log of a conversion written into its
+// output directory
+const runLogFileName = "conversion.log.json"
+
+// Levels of run log entries
+const (
+	runLogError    = "error"
+	runLogWarning  = "warning"
+	runLogInfo     = "info"
+	runLogNote     = "note"
+	runLogUnmapped = "unmapped"
+)
+
+// logTimestamp matches the date and time the standard logger prefixes lines with
+var logTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+// RunLogEntry is a log line or generation decision of a conversion
+type RunLogEntry struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	// Service is the Kubernetes name of the task definition being converted
+	// when the entry was logged
+	Service string `json:"service,omitempty"`
+	Message string `json:"message"`
+}
+
+// RunLog is the full log of a cluster's conversion, kept after the terminal
+// scrollback is gone
+type RunLog struct {
+	Cluster    string        `json:"cluster"`
+	Region     string        `json:"region"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Entries    []RunLogEntry `json:"entries"`
+}
+
+// runLogger captures the standard logger's output while a cluster is
+// converted. A nil logger records nothing.
+type runLogger struct {
+	mu      sync.Mutex
+	log     RunLog
+	service string
+	partial []byte
+	now     func() time.Time
+}
+
+// logTee copies the standard logger's output to the active run loggers.
+// Clusters converted concurrently by --fleet each capture every line.
+var logTee = &runLogTee{loggers: map[*runLogger]bool{}}
+
+// runLogTee is an io.Writer dispatching to the active run loggers
+type runLogTee struct {
+	once    sync.Once
+	mu      sync.Mutex
+	loggers map[*runLogger]bool
+}
+
+// Write passes log output on to every active run logger
+func (t *runLogTee) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for l := range t.loggers {
+		l.Write(p)
+	}
+	return len(p), nil
+}
+
+// startRunLog starts capturing the log of a cluster's conversion
+func startRunLog(cluster, region string) *runLogger {
+	l := &runLogger{now: func() time.Time { return time.Now().UTC() }}
+	l.log = RunLog{Cluster: cluster, Region: region, StartedAt: l.now()}
+
+	logTee.once.Do(func() {
+		log.SetOutput(io.MultiWriter(log.Writer(), logTee))
+	})
+	logTee.mu.Lock()
+	logTee.loggers[l] = true
+	logTee.mu.Unlock()
+	return l
+}
+
+// Write records each complete log line as an entry
+func (l *runLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(l.partial[:i])
+		l.partial = l.partial[i+1:]
+		l.line(line)
+	}
+	return len(p), nil
+}
+
+// line records a log line, reading its level from the message prefix
+func (l *runLogger) line(line string) {
+	message := strings.TrimSpace(logTimestamp.ReplaceAllString(line, ""))
+	if message == "" || strings.Trim(message, "=") == "" {
+		return
+	}
+
+	level := runLogInfo
+	for prefix, prefixLevel := range map[string]string{"Error:": runLogError, "Warning:": runLogWarning, "Info:": runLogInfo} {
+		if strings.HasPrefix(message, prefix) {
+			level = prefixLevel
+			message = strings.TrimSpace(strings.TrimPrefix(message, prefix))
+		}
+	}
+	if strings.HasPrefix(message, "Lint "+lintError) {
+		level = runLogError
+	} else if strings.HasPrefix(message, "Lint "+lintWarning) {
+		level = runLogWarning
+	}
+	l.log.Entries = append(l.log.Entries, RunLogEntry{Time: l.now(), Level: level, Service: l.service, Message: message})
+}
+
+// setService attributes the following entries to a service; empty clears it
+func (l *runLogger) setService(name string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.service = name
+}
+
+// decisions records the notes and unconverted fields of a converted service,
+// which the report lists without timestamps
+func (l *runLogger) decisions(taskDefInfo *TaskDefInfo) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, note := range taskDefInfo.Notes {
+		l.log.Entries = append(l.log.Entries, RunLogEntry{Time: l.now(), Level: runLogNote, Service: taskDefInfo.Name, Message: note})
+	}
+	for _, field := range taskDefInfo.Unmapped {
+		l.log.Entries = append(l.log.Entries, RunLogEntry{Time: l.now(), Level: runLogUnmapped, Service: taskDefInfo.Name, Message: field})
+	}
+}
+
+// finish stops capturing and writes the log into the output directory
+func (l *runLogger) finish(outputDir string) error {
+	if l == nil {
+		return nil
+	}
+	logTee.mu.Lock()
+	delete(logTee.loggers, l)
+	logTee.mu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.log.FinishedAt = l.now()
+
+	data, err := json.MarshalIndent(l.log, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run log: %w", err)
+	}
+	path := filepath.Join(outputDir, runLogFileName)
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write run log %s: %w", path, err)
+	}
+	return nil
+}
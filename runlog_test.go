@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunLog tests capturing the log lines and decisions of a conversion
+func TestRunLog(t *testing.T) {
+	l := startRunLog("prod", "us-east-1")
+	log.Printf("Found %d task definition(s) to convert", 1)
+	l.setService("web")
+	log.Printf("Warning: Failed to describe target groups of %s: %s", "web", "denied")
+	log.Printf("Lint %s in %s (%s): %s", lintError, "web-deployment.yaml", "latest-tag", "image uses latest")
+	l.decisions(&TaskDefInfo{Name: "web", Notes: []string{"ECS cpu 10 maps to 10m"}, Unmapped: []string{"volumes"}})
+	l.setService("")
+	log.Printf("Error: %s", "failed to write report")
+
+	dir := t.TempDir()
+	if err := l.finish(dir); err != nil {
+		t.Fatalf("finish() error = %v", err)
+	}
+	// Lines logged after the conversion finished are not captured
+	log.Printf("Info: after the conversion")
+
+	data, err := os.ReadFile(filepath.Join(dir, runLogFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runLog RunLog
+	if err := json.Unmarshal(data, &runLog); err != nil {
+		t.Fatalf("run log is not JSON: %v", err)
+	}
+	if runLog.Cluster != "prod" || runLog.FinishedAt.Before(runLog.StartedAt) {
+		t.Errorf("run log = %s %v-%v", runLog.Cluster, runLog.StartedAt, runLog.FinishedAt)
+	}
+
+	want := []RunLogEntry{
+		{Level: runLogInfo, Message: "Found 1 task definition(s) to convert"},
+		{Level: runLogWarning, Service: "web", Message: "Failed to describe target groups of web: denied"},
+		{Level: runLogError, Service: "web", Message: "Lint error in web-deployment.yaml (latest-tag): image uses latest"},
+		{Level: runLogNote, Service: "web", Message: "ECS cpu 10 maps to 10m"},
+		{Level: runLogUnmapped, Service: "web", Message: "volumes"},
+		{Level: runLogError, Message: "failed to write report"},
+	}
+	if len(runLog.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(runLog.Entries), len(want), runLog.Entries)
+	}
+	for i, entry := range runLog.Entries {
+		if entry.Level != want[i].Level || entry.Service != want[i].Service || entry.Message != want[i].Message {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// defaultMaxPods is the pod limit of an inventory node without maxPods
+	defaultMaxPods = 110
+	// hostnameLabel and instanceTypeLabel are set on the simulated nodes of an inventory
+	hostnameLabel     = "kubernetes.io/hostname"
+	instanceTypeLabel = "node.kubernetes.io/instance-type"
+)
+
+// NodeInventory declares the nodes of a target cluster for simulate-scheduling
+type NodeInventory struct {
+	NodeGroups []NodeGroup `yaml:"nodeGroups"`
+}
+
+// NodeGroup is a set of identical nodes of an inventory
+type NodeGroup struct {
+	Name  string `yaml:"name"`
+	Count int    `yaml:"count"`
+	// InstanceType sets the allocatable CPU and memory of the instance types
+	// plan-capacity knows, unless CPU and Memory are set
+	InstanceType string `yaml:"instanceType,omitempty"`
+	// CPU and Memory are the allocatable resources of each node
+	CPU     string `yaml:"cpu,omitempty"`
+	Memory  string `yaml:"memory,omitempty"`
+	MaxPods int    `yaml:"maxPods,omitempty"`
+	// Zones spreads the nodes round-robin over topology.kubernetes.io/zone values
+	Zones  []string          `yaml:"zones,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Taints []InventoryTaint  `yaml:"taints,omitempty"`
+}
+
+// InventoryTaint is a taint of the nodes of a group
+type InventoryTaint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value,omitempty"`
+	Effect string `yaml:"effect"`
+}
+
+// simNode is a node of the simulation with its remaining capacity
+type simNode struct {
+	name          string
+	labels        map[string]string
+	taints        []corev1.Taint
+	unschedulable bool
+	cpuMillis     int64
+	memoryBytes   int64
+	pods          int64
+	// placed are the pods running on the node and placed by the simulation
+	placed []simPod
+}
+
+// simPod is a pod placed on a node, as seen by the affinity rules of others
+type simPod struct {
+	namespace string
+	labels    map[string]string
+}
+
+// ServiceSchedule is the simulated placement of the replicas of a service
+type ServiceSchedule struct {
+	Name      string
+	Namespace string
+	Replicas  int32
+	Scheduled int32
+	// Reason tells why the first unscheduled replica fits no node
+	Reason string
+}
+
+// schedulingOptions holds the inputs of the simulate-scheduling subcommand
+type schedulingOptions struct {
+	dir         string
+	cluster     string
+	nodes       string
+	kubeContext string
+	desiredECS  bool
+}
+
+// newSimulateSchedulingCommand creates the `simulate-scheduling` subcommand
+func newSimulateSchedulingCommand() *cobra.Command {
+	opts := &schedulingOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "simulate-scheduling",
+		Short: "Check that the converted Deployments can be scheduled on a target cluster",
+		Long: `simulate-scheduling bin-packs the replicas of the Deployments in an output
+directory onto the nodes of a target cluster, largest pods first, and reports
+the services some replicas of which fit no node, with the reason as the
+Kubernetes scheduler would give it.
+
+The nodes are declared in a --nodes inventory file, or read with kubectl from
+the cluster of --kubecontext, together with the requests of the pods already
+running there. The simulation honors resource requests, the pod limit of the
+nodes, node selectors, required node affinity, taints and tolerations,
+required pod anti-affinity and topology spread constraints that must not be
+violated. Preferred rules and pod affinity are ignored.
+
+Replica counts come from the ECS desiredCount recorded in ` + stateFileName + `
+when available, as for plan-capacity.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSimulateScheduling(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Output directory of a conversion (default: ./<cluster>)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name, used for the default --dir")
+	cmd.Flags().StringVar(&opts.nodes, "nodes", "", "Node inventory YAML file declaring the node groups of the target cluster")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context of the target cluster to read the nodes from without --nodes (default: current context)")
+	cmd.Flags().BoolVar(&opts.desiredECS, "ecs-desired-count", true, "Use the ECS desiredCount from the state file as replica count")
+
+	return cmd
+}
+
+// runSimulateScheduling executes the simulate-scheduling subcommand
+func runSimulateScheduling(ctx context.Context, opts *schedulingOptions) error {
+	if opts.nodes != "" && opts.kubeContext != "" {
+		return fmt.Errorf("--nodes and --kubecontext are mutually exclusive")
+	}
+	dir := opts.dir
+	if dir == "" {
+		if opts.cluster == "" {
+			return fmt.Errorf("either --dir or --cluster is required")
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		dir = filepath.Join(cwd, opts.cluster)
+	}
+
+	deployments, err := readDeployments(dir)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		return fmt.Errorf("no Deployments found in %s", dir)
+	}
+	var desired map[string]int32
+	if opts.desiredECS {
+		if state, err := readConversionState(dir); err == nil {
+			desired = desiredCounts(state)
+		}
+	}
+
+	var nodes []*simNode
+	var source string
+	if opts.nodes != "" {
+		nodes, err = readNodeInventory(opts.nodes)
+		source = opts.nodes
+	} else {
+		nodes, err = readClusterNodes(ctx, &kubectlRunner{Context: opts.kubeContext})
+		source = "the Kubernetes cluster"
+		if opts.kubeContext != "" {
+			source = "context " + opts.kubeContext
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes found in %s", source)
+	}
+
+	schedules := simulateScheduling(nodes, deployments, desired)
+	fmt.Printf("Simulated %d Deployment(s) on %d node(s) of %s\n\n", len(deployments), len(nodes), source)
+	unschedulable := printSchedulingReport(os.Stdout, schedules, nodes)
+	if len(unschedulable) > 0 {
+		return fmt.Errorf("%d service(s) can't be fully scheduled: %s", len(unschedulable), strings.Join(unschedulable, ", "))
+	}
+	return nil
+}
+
+// readNodeInventory reads the nodes declared in a node inventory file
+func readNodeInventory(path string) ([]*simNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node inventory %s: %w", path, err)
+	}
+	var inventory NodeInventory
+	if err := yaml.Unmarshal(data, &inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse node inventory %s: %w", path, err)
+	}
+
+	var nodes []*simNode
+	for i, group := range inventory.NodeGroups {
+		groupNodes, err := group.nodes()
+		if err != nil {
+			return nil, fmt.Errorf("node group %d (%s) of %s: %w", i+1, group.Name, path, err)
+		}
+		nodes = append(nodes, groupNodes...)
+	}
+	return nodes, nil
+}
+
+// nodes returns the simulated nodes of a node group
+func (g NodeGroup) nodes() ([]*simNode, error) {
+	if g.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if g.Count < 1 {
+		return nil, fmt.Errorf("count must be at least 1 (got %d)", g.Count)
+	}
+
+	var cpuMillis, memoryBytes int64
+	for _, it := range capacityInstanceTypes {
+		if it.Name == g.InstanceType {
+			cpuMillis = int64(float64(it.VCPU*1000) * allocatableCPUFraction)
+			memoryBytes = int64(float64(int64(it.MemoryGiB)<<30) * allocatableMemoryFraction)
+		}
+	}
+	if g.CPU != "" {
+		q, err := resource.ParseQuantity(g.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu %q: %w", g.CPU, err)
+		}
+		cpuMillis = q.MilliValue()
+	}
+	if g.Memory != "" {
+		q, err := resource.ParseQuantity(g.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory %q: %w", g.Memory, err)
+		}
+		memoryBytes = q.Value()
+	}
+	if cpuMillis == 0 || memoryBytes == 0 {
+		return nil, fmt.Errorf("cpu and memory are required for instance type %q", g.InstanceType)
+	}
+	maxPods := g.MaxPods
+	if maxPods == 0 {
+		maxPods = defaultMaxPods
+	}
+
+	var taints []corev1.Taint
+	for _, t := range g.Taints {
+		switch corev1.TaintEffect(t.Effect) {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("taint %s has invalid effect %q", t.Key, t.Effect)
+		}
+		taints = append(taints, corev1.Taint{Key: t.Key, Value: t.Value, Effect: corev1.TaintEffect(t.Effect)})
+	}
+
+	nodes := make([]*simNode, 0, g.Count)
+	for i := 0; i < g.Count; i++ {
+		name := fmt.Sprintf("%s-%d", g.Name, i+1)
+		nodeLabels := map[string]string{hostnameLabel: name}
+		if g.InstanceType != "" {
+			nodeLabels[instanceTypeLabel] = g.InstanceType
+		}
+		if len(g.Zones) > 0 {
+			nodeLabels[zoneLabel] = g.Zones[i%len(g.Zones)]
+		}
+		for k, v := range g.Labels {
+			nodeLabels[k] = v
+		}
+		nodes = append(nodes, &simNode{
+			name:        name,
+			labels:      nodeLabels,
+			taints:      taints,
+			cpuMillis:   cpuMillis,
+			memoryBytes: memoryBytes,
+			pods:        int64(maxPods),
+		})
+	}
+	return nodes, nil
+}
+
+// readClusterNodes reads the allocatable capacity of the nodes of a cluster,
+// less the requests of the pods running on them
+func readClusterNodes(ctx context.Context, kubectl *kubectlRunner) ([]*simNode, error) {
+	out, err := kubectl.run(ctx, nil, "get", "nodes", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var nodeList corev1.NodeList
+	if err := json.Unmarshal(out, &nodeList); err != nil {
+		return nil, fmt.Errorf("failed to parse nodes: %w", err)
+	}
+
+	byName := map[string]*simNode{}
+	var nodes []*simNode
+	for _, node := range nodeList.Items {
+		allocatable := node.Status.Allocatable
+		n := &simNode{
+			name:          node.Name,
+			labels:        node.Labels,
+			taints:        node.Spec.Taints,
+			unschedulable: node.Spec.Unschedulable,
+			cpuMillis:     allocatable.Cpu().MilliValue(),
+			memoryBytes:   allocatable.Memory().Value(),
+			pods:          allocatable.Pods().Value(),
+		}
+		byName[node.Name] = n
+		nodes = append(nodes, n)
+	}
+
+	out, err = kubectl.run(ctx, nil, "get", "pods", "--all-namespaces", "--field-selector", "status.phase!=Succeeded,status.phase!=Failed", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var podList corev1.PodList
+	if err := json.Unmarshal(out, &podList); err != nil {
+		return nil, fmt.Errorf("failed to parse pods: %w", err)
+	}
+	for _, pod := range podList.Items {
+		if n, ok := byName[pod.Spec.NodeName]; ok {
+			cpu, memory := podRequests(&pod.Spec)
+			n.place(cpu, memory, simPod{namespace: pod.Namespace, labels: pod.Labels})
+		}
+	}
+
+	return nodes, nil
+}
+
+// place takes the requests of a pod from the remaining capacity of the node
+func (n *simNode) place(cpuMillis, memoryBytes int64, pod simPod) {
+	n.cpuMillis -= cpuMillis
+	n.memoryBytes -= memoryBytes
+	n.pods--
+	n.placed = append(n.placed, pod)
+}
+
+// schedulingPod is a replica of a Deployment to place
+type schedulingPod struct {
+	schedule    *ServiceSchedule
+	spec        *corev1.PodSpec
+	pod         simPod
+	cpuMillis   int64
+	memoryBytes int64
+}
+
+// simulateScheduling places the replicas of the Deployments on the nodes,
+// largest first, each on the feasible node it leaves the least CPU on
+func simulateScheduling(nodes []*simNode, deployments []*appsv1.Deployment, desired map[string]int32) []ServiceSchedule {
+	schedules := make([]ServiceSchedule, len(deployments))
+	var pods []schedulingPod
+	for i, d := range deployments {
+		namespace := d.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		schedules[i] = ServiceSchedule{Name: d.Name, Namespace: namespace, Replicas: deploymentReplicas(d, desired)}
+		cpu, memory := podRequests(&d.Spec.Template.Spec)
+		for r := int32(0); r < schedules[i].Replicas; r++ {
+			pods = append(pods, schedulingPod{
+				schedule:    &schedules[i],
+				spec:        &d.Spec.Template.Spec,
+				pod:         simPod{namespace: namespace, labels: d.Spec.Template.Labels},
+				cpuMillis:   cpu,
+				memoryBytes: memory,
+			})
+		}
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		if pods[i].cpuMillis != pods[j].cpuMillis {
+			return pods[i].cpuMillis > pods[j].cpuMillis
+		}
+		return pods[i].memoryBytes > pods[j].memoryBytes
+	})
+
+	for _, pod := range pods {
+		var best *simNode
+		reasons := map[string]int{}
+		for _, node := range nodes {
+			if reason := pod.unfit(node, nodes); reason != "" {
+				reasons[reason]++
+				continue
+			}
+			if best == nil || node.cpuMillis < best.cpuMillis {
+				best = node
+			}
+		}
+		if best == nil {
+			if pod.schedule.Reason == "" {
+				pod.schedule.Reason = unschedulableReason(len(nodes), reasons)
+			}
+			continue
+		}
+		best.place(pod.cpuMillis, pod.memoryBytes, pod.pod)
+		pod.schedule.Scheduled++
+	}
+
+	return schedules
+}
+
+// unfit returns why the pod doesn't fit a node, in the terms of the
+// Kubernetes scheduler, or "" when it fits
+func (p schedulingPod) unfit(node *simNode, nodes []*simNode) string {
+	switch {
+	case node.unschedulable:
+		return "node(s) were unschedulable"
+	case !matchesNodeSelector(p.spec, node):
+		return "node(s) didn't match Pod's node affinity/selector"
+	case !toleratesTaints(p.spec.Tolerations, node.taints):
+		return "node(s) had untolerated taint"
+	case node.pods < 1:
+		return "Too many pods"
+	case node.cpuMillis < p.cpuMillis:
+		return "Insufficient cpu"
+	case node.memoryBytes < p.memoryBytes:
+		return "Insufficient memory"
+	case !p.satisfiesAntiAffinity(node, nodes):
+		return "node(s) didn't match pod anti-affinity rules"
+	case !p.satisfiesSpread(node, nodes):
+		return "node(s) didn't match pod topology spread constraints"
+	}
+	return ""
+}
+
+// matchesNodeSelector checks the node selector and the required node
+// affinity of a pod
+func matchesNodeSelector(spec *corev1.PodSpec, node *simNode) bool {
+	for k, v := range spec.NodeSelector {
+		if node.labels[k] != v {
+			return false
+		}
+	}
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// The terms are ORed, the requirements of a term ANDed
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		matched := len(term.MatchExpressions)+len(term.MatchFields) > 0
+		for _, req := range term.MatchExpressions {
+			value, ok := node.labels[req.Key]
+			matched = matched && matchesRequirement(req, value, ok)
+		}
+		for _, req := range term.MatchFields {
+			matched = matched && req.Key == "metadata.name" && matchesRequirement(req, node.name, true)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRequirement checks a node selector requirement against the value of
+// a node label, ok telling whether the node has the label
+func matchesRequirement(req corev1.NodeSelectorRequirement, value string, ok bool) bool {
+	contains := func() bool {
+		for _, v := range req.Values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+	switch req.Operator {
+	case corev1.NodeSelectorOpIn:
+		return ok && contains()
+	case corev1.NodeSelectorOpNotIn:
+		return !ok || !contains()
+	case corev1.NodeSelectorOpExists:
+		return ok
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !ok || len(req.Values) != 1 {
+			return false
+		}
+		actual, err1 := strconv.ParseInt(value, 10, 64)
+		bound, err2 := strconv.ParseInt(req.Values[0], 10, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if req.Operator == corev1.NodeSelectorOpGt {
+			return actual > bound
+		}
+		return actual < bound
+	}
+	return false
+}
+
+// toleratesTaints checks that the tolerations cover the taints keeping pods
+// off a node; PreferNoSchedule taints don't
+func toleratesTaints(tolerations []corev1.Toleration, taints []corev1.Taint) bool {
+	for _, taint := range taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, t := range tolerations {
+			if t.Effect != "" && t.Effect != taint.Effect {
+				continue
+			}
+			if t.Key != "" && t.Key != taint.Key {
+				continue
+			}
+			if t.Operator == corev1.TolerationOpExists || t.Value == taint.Value {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiesAntiAffinity checks that no pod matching a required anti-affinity
+// term of the pod runs in the topology domain of the node
+func (p schedulingPod) satisfiesAntiAffinity(node *simNode, nodes []*simNode) bool {
+	affinity := p.spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return true
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		domain, ok := node.labels[term.TopologyKey]
+		if !ok {
+			continue
+		}
+		namespaces := term.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{p.pod.namespace}
+		}
+		for _, other := range nodes {
+			if other.labels[term.TopologyKey] == domain && countMatchingPods(other, term.LabelSelector, namespaces) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// satisfiesSpread checks the topology spread constraints of the pod that must
+// not be violated: placing it on the node keeps the difference to the domain
+// with the fewest matching pods within maxSkew
+func (p schedulingPod) satisfiesSpread(node *simNode, nodes []*simNode) bool {
+	for _, constraint := range p.spec.TopologySpreadConstraints {
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+		domain, ok := node.labels[constraint.TopologyKey]
+		if !ok {
+			return false
+		}
+		// The domains are those of the nodes the pod may run on
+		counts := map[string]int{}
+		for _, other := range nodes {
+			value, ok := other.labels[constraint.TopologyKey]
+			if !ok || !matchesNodeSelector(p.spec, other) {
+				continue
+			}
+			counts[value] += countMatchingPods(other, constraint.LabelSelector, []string{p.pod.namespace})
+		}
+		minCount := -1
+		for _, count := range counts {
+			if minCount < 0 || count < minCount {
+				minCount = count
+			}
+		}
+		if counts[domain]+1-max(minCount, 0) > int(constraint.MaxSkew) {
+			return false
+		}
+	}
+	return true
+}
+
+// countMatchingPods counts the pods of a node in the namespaces matching a
+// label selector
+func countMatchingPods(node *simNode, labelSelector *metav1.LabelSelector, namespaces []string) int {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil || labelSelector == nil {
+		return 0
+	}
+	count := 0
+	for _, pod := range node.placed {
+		for _, namespace := range namespaces {
+			if pod.namespace == namespace && selector.Matches(labels.Set(pod.labels)) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// unschedulableReason formats why a pod fits no node, as the scheduler does
+func unschedulableReason(nodes int, reasons map[string]int) string {
+	parts := make([]string, 0, len(reasons))
+	for reason, count := range reasons {
+		parts = append(parts, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("0/%d nodes are available: %s", nodes, strings.Join(parts, ", "))
+}
+
+// printSchedulingReport writes the placement of each service and the
+// remaining capacity of the nodes, and returns the services with replicas
+// fitting no node
+func printSchedulingReport(w io.Writer, schedules []ServiceSchedule, nodes []*simNode) []string {
+	var unschedulable []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tNAMESPACE\tSCHEDULED\tRESULT\tREASON")
+	for _, s := range schedules {
+		result := "ok"
+		if s.Scheduled < s.Replicas {
+			result = "UNSCHEDULABLE"
+			unschedulable = append(unschedulable, s.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%s\t%s\n", s.Name, s.Namespace, s.Scheduled, s.Replicas, result, s.Reason)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tPODS\tFREE CPU\tFREE MEMORY")
+	for _, n := range nodes {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", n.name, len(n.placed), formatMillis(n.cpuMillis), formatGiB(n.memoryBytes))
+	}
+	tw.Flush()
+	return unschedulable
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestSimulateScheduling tests bin-packing the converted Deployments onto the
+// nodes of an inventory
+func TestSimulateScheduling(t *testing.T) {
+	dir := t.TempDir()
+	inventory := filepath.Join(dir, "nodes.yaml")
+	if err := os.WriteFile(inventory, []byte(`nodeGroups:
+  - name: general
+    count: 2
+    instanceType: m6i.large
+    zones: [us-east-1a, us-east-1b]
+  - name: spot
+    count: 1
+    cpu: "4"
+    memory: 8Gi
+    maxPods: 2
+    labels: {karpenter.sh/capacity-type: spot}
+    taints: [{key: spot, value: "true", effect: NoSchedule}]
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := readNodeInventory(inventory)
+	if err != nil {
+		t.Fatalf("readNodeInventory() error = %v", err)
+	}
+	if len(nodes) != 3 || nodes[0].cpuMillis != 1800 || nodes[1].labels[zoneLabel] != "us-east-1b" || nodes[2].pods != 2 {
+		t.Fatalf("inventory nodes = %+v", nodes)
+	}
+
+	deployment := func(name string, replicas int, cpu, spec string) string {
+		return `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ` + name + `
+spec:
+  replicas: ` + strconv.Itoa(replicas) + `
+  template:
+    metadata:
+      labels: {app: ` + name + `}
+    spec:
+` + spec + `      containers:
+        - name: app
+          resources:
+            requests: {cpu: ` + cpu + `, memory: 512Mi}
+`
+	}
+	manifests := map[string]string{
+		// Hard anti-affinity allows one replica on each of the untainted nodes
+		"web-deployment.yaml": deployment("web", 3, "500m", `      affinity:
+        podAntiAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            - labelSelector: {matchLabels: {app: web}}
+              topologyKey: kubernetes.io/hostname
+`),
+		"batch-deployment.yaml": deployment("batch", 1, "3", `      nodeSelector: {karpenter.sh/capacity-type: spot}
+      tolerations: [{key: spot, operator: Exists, effect: NoSchedule}]
+`),
+		"big-deployment.yaml": deployment("big", 1, "8", ""),
+		"zonal-deployment.yaml": deployment("zonal", 2, "100m", `      topologySpreadConstraints:
+        - maxSkew: 1
+          topologyKey: topology.kubernetes.io/zone
+          whenUnsatisfiable: DoNotSchedule
+          labelSelector: {matchLabels: {app: zonal}}
+`),
+	}
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deployments, err := readDeployments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedules := simulateScheduling(nodes, deployments, nil)
+	byName := map[string]ServiceSchedule{}
+	for _, s := range schedules {
+		byName[s.Name] = s
+	}
+	if s := byName["batch"]; s.Scheduled != 1 {
+		t.Errorf("batch = %+v, want it on the spot node", s)
+	}
+	if s := byName["web"]; s.Scheduled != 2 || !strings.Contains(s.Reason, "1 node(s) had untolerated taint") || !strings.Contains(s.Reason, "2 node(s) didn't match pod anti-affinity rules") {
+		t.Errorf("web = %+v, want 2/3 kept apart", s)
+	}
+	if s := byName["big"]; s.Scheduled != 0 || s.Reason != "0/3 nodes are available: 1 node(s) had untolerated taint, 2 Insufficient cpu" {
+		t.Errorf("big = %+v", s)
+	}
+	zones := map[string]bool{}
+	for _, n := range nodes[:2] {
+		for _, pod := range n.placed {
+			if pod.labels["app"] == "zonal" {
+				zones[n.labels[zoneLabel]] = true
+			}
+		}
+	}
+	if s := byName["zonal"]; s.Scheduled != 2 || len(zones) != 2 {
+		t.Errorf("zonal = %+v in zones %v, want one replica per zone", s, zones)
+	}
+
+	var out bytes.Buffer
+	unschedulable := printSchedulingReport(&out, schedules, nodes)
+	if strings.Join(unschedulable, ",") != "big,web" || !strings.Contains(out.String(), "UNSCHEDULABLE") {
+		t.Errorf("report unschedulable = %v:\n%s", unschedulable, out.String())
+	}
+
+	if err := os.WriteFile(inventory, []byte("nodeGroups:\n  - {name: bad, count: 1, cpu: '2', memory: 4Gi, taints: [{key: a, effect: Never}]}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readNodeInventory(inventory); err == nil || !strings.Contains(err.Error(), "invalid effect") {
+		t.Errorf("readNodeInventory(bad taint) error = %v", err)
+	}
+}
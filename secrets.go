@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretResolver resolves the valueFrom reference of an ECS container secret
+type SecretResolver interface {
+	// Name identifies the resolver in logs
+	Name() string
+	// Supports reports whether the resolver handles a reference
+	Supports(ref string) bool
+	// Resolve returns the plain-text value of a reference
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// secretResolverFactory creates a resolver from the run's AWS configuration
+type secretResolverFactory func(cfg aws.Config) SecretResolver
+
+// registeredSecretResolvers are additional resolvers (e.g. Vault) added with
+// registerSecretResolver, consulted before the built-in AWS resolvers
+var registeredSecretResolvers []secretResolverFactory
+
+// registerSecretResolver adds a resolver; call it from an init function in a
+// separate file to support another secret backend
+func registerSecretResolver(factory secretResolverFactory) {
+	registeredSecretResolvers = append(registeredSecretResolvers, factory)
+}
+
+// secretStore receives the resolved secrets of a container and wires them into
+// the converted workload
+type secretStore interface {
+	store(ctx context.Context, taskDefInfo *TaskDefInfo, containerName string, values map[string]string) error
+}
+
+// secretResolverChain resolves references with the first resolver supporting them
+type secretResolverChain struct {
+	resolvers []SecretResolver
+	// secrets receives the resolved values of each container
+	secrets secretStore
+	// cache avoids fetching a secret shared by several containers twice
+	cache map[string]string
+}
+
+// newSecretResolverChain creates the registered resolvers followed by the
+// built-in Secrets Manager and SSM Parameter Store resolvers
+func newSecretResolverChain(cfg aws.Config, secrets secretStore) *secretResolverChain {
+	chain := &secretResolverChain{secrets: secrets, cache: map[string]string{}}
+	for _, factory := range registeredSecretResolvers {
+		chain.resolvers = append(chain.resolvers, factory(cfg))
+	}
+	chain.resolvers = append(chain.resolvers,
+		&secretsManagerResolver{client: secretsmanager.NewFromConfig(cfg)},
+		// SSM accepts plain parameter names, so it goes last
+		&ssmParameterResolver{client: ssm.NewFromConfig(cfg)},
+	)
+	return chain
+}
+
+// resolve returns the value of a reference using the first supporting resolver
+func (c *secretResolverChain) resolve(ctx context.Context, ref string) (string, error) {
+	if value, ok := c.cache[ref]; ok {
+		return value, nil
+	}
+
+	for _, resolver := range c.resolvers {
+		if !resolver.Supports(ref) {
+			continue
+		}
+		value, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", resolver.Name(), err)
+		}
+		c.cache[ref] = value
+		return value, nil
+	}
+
+	return "", fmt.Errorf("no secret resolver supports %s", ref)
+}
+
+// apply resolves the secrets of every container of a converted task definition
+// and hands the values to the secrets pipeline
+func (c *secretResolverChain) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	if taskDefInfo.Source == nil || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		if len(def.Secrets) == 0 {
+			continue
+		}
+		containerName := aws.ToString(def.Name)
+
+		values := map[string]string{}
+		failed := 0
+		for _, secret := range def.Secrets {
+			name, ref := aws.ToString(secret.Name), aws.ToString(secret.ValueFrom)
+			value, err := c.resolve(ctx, ref)
+			if err != nil {
+				log.Printf("Warning: Failed to resolve secret %s of %s/%s: %v", name, taskDefInfo.Name, containerName, err)
+				failed++
+				continue
+			}
+			values[name] = value
+		}
+
+		if failed > 0 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("%d secret(s) of container %s could not be resolved and are not set", failed, containerName))
+		} else {
+			taskDefInfo.Unmapped = removeField(taskDefInfo.Unmapped, fmt.Sprintf("containerDefinitions[%s].secrets", containerName))
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := c.secrets.store(ctx, taskDefInfo, sanitizeName(containerName), values); err != nil {
+			log.Printf("Warning: Failed to store secrets of %s/%s: %v", taskDefInfo.Name, containerName, err)
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Secrets of container %s could not be stored and are not set: %v", containerName, err))
+		}
+	}
+}
+
+// kubernetesSecretStore writes resolved values into a Kubernetes Secret per container
+type kubernetesSecretStore struct{}
+
+// store adds the Secret and references it from the container's environment
+func (kubernetesSecretStore) store(ctx context.Context, taskDefInfo *TaskDefInfo, containerName string, values map[string]string) error {
+	secretName := resolvedSecretName(containerName)
+	taskDefInfo.Manifests.Secrets = append(taskDefInfo.Manifests.Secrets, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretName},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: values,
+	})
+	addSecretKeyRefs(taskDefInfo.Manifests.Deployment, containerName, secretName, sortedSecretNames(values))
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Resolved %d ECS secret(s) of container %s into Secret %s", len(values), containerName, secretName))
+	log.Printf("✓ Resolved %d secret(s) for %s/%s", len(values), taskDefInfo.Name, containerName)
+	return nil
+}
+
+// resolvedSecretName is the name of the Secret holding a container's resolved secrets
+func resolvedSecretName(containerName string) string {
+	return sanitizeName(fmt.Sprintf("%s-ecs-secrets", containerName))
+}
+
+// sortedSecretNames returns the variable names of resolved secrets in sorted order
+func sortedSecretNames(values map[string]string) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addSecretKeyRefs sets environment variables of a container from keys of a Secret
+func addSecretKeyRefs(podSpec *corev1.PodSpec, containerName, secretName string, names []string) {
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name != containerName {
+			continue
+		}
+		for _, name := range names {
+			podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Key:                  name,
+					},
+				},
+			})
+		}
+	}
+}
+
+// removeField removes a field path from a list of unmapped fields
+func removeField(fields []string, field string) []string {
+	var kept []string
+	for _, f := range fields {
+		if f != field {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// secretsManagerResolver resolves AWS Secrets Manager ARNs, including the
+// ECS json-key, version-stage and version-id suffixes
+type secretsManagerResolver struct {
+	client *secretsmanager.Client
+}
+
+// Name identifies the resolver in logs
+func (r *secretsManagerResolver) Name() string {
+	return "secretsmanager"
+}
+
+// Supports reports whether ref is a Secrets Manager ARN
+func (r *secretsManagerResolver) Supports(ref string) bool {
+	return strings.HasPrefix(ref, "arn:") && strings.Contains(ref, ":secretsmanager:")
+}
+
+// Resolve fetches the secret string and extracts the JSON key if one is referenced
+func (r *secretsManagerResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	secretID, jsonKey, versionStage, versionID := splitSecretsManagerRef(ref)
+
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)}
+	if versionStage != "" {
+		input.VersionStage = aws.String(versionStage)
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	out, err := r.client.GetSecretValue(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s is binary, only string secrets are supported", secretID)
+	}
+	if jsonKey == "" {
+		return *out.SecretString, nil
+	}
+
+	return secretJSONKey(*out.SecretString, jsonKey)
+}
+
+// splitSecretsManagerRef splits arn:aws:secretsmanager:region:account:secret:name[:json-key[:version-stage[:version-id]]]
+func splitSecretsManagerRef(ref string) (secretID, jsonKey, versionStage, versionID string) {
+	parts := strings.Split(ref, ":")
+	if len(parts) <= 7 {
+		return ref, "", "", ""
+	}
+
+	extra := append(parts[7:], "", "", "")
+	return strings.Join(parts[:7], ":"), extra[0], extra[1], extra[2]
+}
+
+// secretJSONKey returns a key of a JSON secret; non-string values are returned as JSON
+func secretJSONKey(secretString, key string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ssmParameterResolver resolves SSM Parameter Store names and ARNs
+type ssmParameterResolver struct {
+	client *ssm.Client
+}
+
+// Name identifies the resolver in logs
+func (r *ssmParameterResolver) Name() string {
+	return "ssm"
+}
+
+// Supports reports whether ref is an SSM parameter ARN or a bare parameter name
+func (r *ssmParameterResolver) Supports(ref string) bool {
+	if strings.HasPrefix(ref, "arn:") {
+		return strings.Contains(ref, ":ssm:")
+	}
+	return ref != ""
+}
+
+// Resolve fetches the decrypted parameter value
+func (r *ssmParameterResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	out, err := r.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(ref),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.Parameter == nil {
+		return "", fmt.Errorf("parameter %s not found", ref)
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeSecretResolver resolves "fake:" references from a map
+type fakeSecretResolver struct {
+	values map[string]string
+}
+
+func (r *fakeSecretResolver) Name() string { return "fake" }
+
+func (r *fakeSecretResolver) Supports(ref string) bool { return strings.HasPrefix(ref, "fake:") }
+
+func (r *fakeSecretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	value, ok := r.values[ref]
+	if !ok {
+		return "", fmt.Errorf("%s not found", ref)
+	}
+	return value, nil
+}
+
+// TestSplitSecretsManagerRef tests parsing ECS Secrets Manager references
+func TestSplitSecretsManagerRef(t *testing.T) {
+	base := "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-AbCdEf"
+
+	tests := []struct {
+		ref                                 string
+		secretID, jsonKey, stage, versionID string
+	}{
+		{ref: base, secretID: base},
+		{ref: base + ":password::", secretID: base, jsonKey: "password"},
+		{ref: base + ":password:AWSPREVIOUS:", secretID: base, jsonKey: "password", stage: "AWSPREVIOUS"},
+		{ref: base + "::AWSCURRENT:abc-123", secretID: base, stage: "AWSCURRENT", versionID: "abc-123"},
+	}
+
+	for _, tt := range tests {
+		secretID, jsonKey, stage, versionID := splitSecretsManagerRef(tt.ref)
+		if secretID != tt.secretID || jsonKey != tt.jsonKey || stage != tt.stage || versionID != tt.versionID {
+			t.Errorf("splitSecretsManagerRef(%q) = %q, %q, %q, %q", tt.ref, secretID, jsonKey, stage, versionID)
+		}
+	}
+}
+
+// TestSecretJSONKey tests extracting keys from JSON secrets
+func TestSecretJSONKey(t *testing.T) {
+	secret := `{"username":"app","port":5432}`
+
+	if got, err := secretJSONKey(secret, "username"); err != nil || got != "app" {
+		t.Errorf("secretJSONKey(username) = %q, %v", got, err)
+	}
+	if got, err := secretJSONKey(secret, "port"); err != nil || got != "5432" {
+		t.Errorf("secretJSONKey(port) = %q, %v", got, err)
+	}
+	if _, err := secretJSONKey(secret, "missing"); err == nil {
+		t.Errorf("secretJSONKey(missing) succeeded, want error")
+	}
+}
+
+// TestSecretResolverChainApply tests resolving container secrets into a Secret and secretKeyRef env vars
+func TestSecretResolverChainApply(t *testing.T) {
+	chain := &secretResolverChain{
+		resolvers: []SecretResolver{&fakeSecretResolver{values: map[string]string{"fake:db": "s3cret"}}},
+		secrets:   kubernetesSecretStore{},
+		cache:     map[string]string{},
+	}
+
+	taskDefInfo := &TaskDefInfo{
+		Name: "web",
+		Source: &types.TaskDefinition{
+			ContainerDefinitions: []types.ContainerDefinition{{
+				Name: aws.String("app"),
+				Secrets: []types.Secret{
+					{Name: aws.String("DB_PASSWORD"), ValueFrom: aws.String("fake:db")},
+				},
+			}},
+		},
+		Unmapped: []string{"containerDefinitions[app].secrets"},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		},
+	}
+
+	chain.apply(context.Background(), taskDefInfo)
+
+	if len(taskDefInfo.Manifests.Secrets) != 1 || taskDefInfo.Manifests.Secrets[0].StringData["DB_PASSWORD"] != "s3cret" {
+		t.Fatalf("secrets = %+v, want app-ecs-secrets with DB_PASSWORD", taskDefInfo.Manifests.Secrets)
+	}
+	env := taskDefInfo.Manifests.Deployment.Containers[0].Env
+	if len(env) != 1 || env[0].ValueFrom == nil || env[0].ValueFrom.SecretKeyRef.Name != "app-ecs-secrets" {
+		t.Errorf("env = %+v, want a secretKeyRef to app-ecs-secrets", env)
+	}
+	if len(taskDefInfo.Unmapped) != 0 {
+		t.Errorf("unmapped = %v, want secrets covered", taskDefInfo.Unmapped)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// maxConvertRequestBytes limits the size of a /convert request body
+const maxConvertRequestBytes = 10 << 20
+
+// ConvertRequest is the body accepted by POST /convert. Exactly one of
+// TaskDefinition or Cluster must be set.
+type ConvertRequest struct {
+	// TaskDefinition is an ECS task definition in DescribeTaskDefinition JSON form
+	TaskDefinition *types.TaskDefinition `json:"taskDefinition,omitempty"`
+	// Cluster is the name of an ECS cluster whose services should be converted
+	Cluster string `json:"cluster,omitempty"`
+	// Region overrides the server's default region for cluster conversions
+	Region string `json:"region,omitempty"`
+}
+
+// ConvertResponse is returned by POST /convert
+type ConvertResponse struct {
+	Services []ConvertedService `json:"services"`
+	Failures []string           `json:"failures,omitempty"`
+}
+
+// ConvertedService holds the rendered manifests for a single task definition
+type ConvertedService struct {
+	Name      string            `json:"name"`
+	Manifests map[string]string `json:"manifests"`
+	// Unmapped lists source fields that were not converted
+	Unmapped []string `json:"unmapped,omitempty"`
+}
+
+// conversionServer serves the conversion API
+type conversionServer struct {
+	defaultRegion string
+	// fetchCluster reads the task definitions of the services of a cluster and
+	// the failures of those it could not read
+	fetchCluster func(ctx context.Context, region, cluster string) ([]*TaskDefInfo, []string, error)
+}
+
+// newServeCommand creates the `serve` subcommand that runs ecs2k8s as a daemon
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run ecs2k8s as an HTTP API server",
+		Long: `serve starts a long-running HTTP server exposing the conversion as an API,
+so internal developer platforms can call ecs2k8s as a service instead of
+shelling out to the CLI.
+
+Endpoints:
+  POST /convert   convert a task definition JSON or an ECS cluster reference
+  POST /events    with --events-dir, convert the task definition family
+                  registered in an EventBridge event and commit it to git
+  GET  /healthz   liveness check
+
+POST /events consumes the "AWS API Call via CloudTrail" events of
+RegisterTaskDefinition, delivered by an EventBridge API destination. The
+services of the registered family are regenerated in the output directory of
+a previous conversion, a clone of a git repository, and committed there
+(--events-push pushes them). When ` + eventsTokenEnv + ` is set, requests
+must carry it as a bearer token.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listen, _ := cmd.Flags().GetString("listen")
+			region, _ := cmd.Flags().GetString("region")
+			eventsDir, _ := cmd.Flags().GetString("events-dir")
+			eventsPush, _ := cmd.Flags().GetBool("events-push")
+
+			if region != "" {
+				if err := validateRegion(region); err != nil {
+					return err
+				}
+			}
+			if eventsPush && eventsDir == "" {
+				return fmt.Errorf("--events-push requires --events-dir")
+			}
+
+			var events *eventConverter
+			if eventsDir != "" {
+				var err error
+				if events, err = newEventConverter(cmd.Context(), eventsDir, region, eventsPush); err != nil {
+					return err
+				}
+			}
+
+			return runServer(listen, region, events)
+		},
+	}
+
+	cmd.Flags().StringP("listen", "l", ":8080", "Address to listen on")
+	cmd.Flags().StringP("region", "r", "", "Default AWS region for cluster conversions")
+	cmd.Flags().String("events-dir", "", "Serve POST /events, regenerating the cluster converted into this directory (a git clone) on task definition registrations")
+	cmd.Flags().Bool("events-push", false, "Push the commits of POST /events to the upstream of the branch")
+
+	return cmd
+}
+
+// runServer starts the HTTP server and blocks until it is interrupted. POST
+// /events is served with an event converter.
+func runServer(listen, defaultRegion string, events *eventConverter) error {
+	srv := &conversionServer{defaultRegion: defaultRegion, fetchCluster: fetchClusterTaskDefInfos}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", srv.handleConvert)
+	if events != nil {
+		mux.HandleFunc("/events", events.handleEvents)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	httpServer := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("ecs2k8s API server listening on %s", listen)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down API server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+// handleConvert handles POST /convert
+func (s *conversionServer) handleConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+
+	var req ConvertRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConvertRequestBytes))
+	if err := decoder.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	if (req.TaskDefinition == nil) == (req.Cluster == "") {
+		writeJSONError(w, http.StatusBadRequest, "exactly one of taskDefinition or cluster must be set")
+		return
+	}
+
+	var resp *ConvertResponse
+	var err error
+	if req.TaskDefinition != nil {
+		resp, err = convertTaskDefinitionRequest(req.TaskDefinition)
+	} else {
+		resp, err = s.convertClusterRequest(r.Context(), req)
+	}
+
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// convertTaskDefinitionRequest converts a single task definition supplied in the request body
+func convertTaskDefinitionRequest(taskDef *types.TaskDefinition) (*ConvertResponse, error) {
+	taskDefName := ""
+	if taskDef.TaskDefinitionArn != nil {
+		taskDefName = extractTaskDefName(*taskDef.TaskDefinitionArn)
+	}
+	if taskDefName == "" && taskDef.Family != nil {
+		taskDefName = *taskDef.Family
+	}
+	if taskDefName == "" {
+		return nil, fmt.Errorf("task definition must include taskDefinitionArn or family")
+	}
+
+	taskDefInfo, err := buildTaskDefInfo(taskDef, taskDefName)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := renderConvertedService(taskDefInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConvertResponse{Services: []ConvertedService{*svc}}, nil
+}
+
+// convertClusterRequest converts every service task definition in an ECS cluster
+func (s *conversionServer) convertClusterRequest(ctx context.Context, req ConvertRequest) (*ConvertResponse, error) {
+	region := req.Region
+	if region == "" {
+		region = s.defaultRegion
+	}
+	if region == "" {
+		return nil, fmt.Errorf("region is required for cluster conversions (set it in the request or with --region)")
+	}
+	if err := validateRegion(region); err != nil {
+		return nil, err
+	}
+
+	taskDefInfos, failures, err := s.fetchCluster(ctx, region, req.Cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ConvertResponse{Services: []ConvertedService{}, Failures: failures}
+	for _, taskDefInfo := range taskDefInfos {
+		svc, err := renderConvertedService(taskDefInfo)
+		if err != nil {
+			resp.Failures = append(resp.Failures, err.Error())
+			continue
+		}
+		resp.Services = append(resp.Services, *svc)
+	}
+
+	sort.Slice(resp.Services, func(i, j int) bool {
+		return resp.Services[i].Name < resp.Services[j].Name
+	})
+
+	return resp, nil
+}
+
+// fetchClusterTaskDefInfos reads the task definitions of the services of an
+// ECS cluster with their propagated tags and load balancers
+func fetchClusterTaskDefInfos(ctx context.Context, region, cluster string) ([]*TaskDefInfo, []string, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	ecsClient := ecs.NewFromConfig(cfg)
+
+	if err := validateSelectedCluster(ctx, cluster, ecsClient); err != nil {
+		return nil, nil, fmt.Errorf("cluster validation failed: %w", err)
+	}
+
+	services, err := describeClusterServices(ctx, ecsClient, cluster)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list task definitions: %w", err)
+	}
+
+	readClusterSettings(ctx, ecsClient, cluster).applyDefaultStrategy(services)
+
+	taskDefs := serviceTaskDefinitions(cluster, services)
+	servicesByTaskDef := servicesByTaskDefinition(services)
+
+	loadBalancers := newLoadBalancerResolver(elbv2.NewFromConfig(cfg))
+
+	var taskDefInfos []*TaskDefInfo
+	var failures []string
+	for _, taskDefArn := range taskDefs {
+		taskDefInfo, err := fetchTaskDefInfo(ctx, ecsClient, taskDefArn, servicesByTaskDef[taskDefArn])
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		applyPropagatedTags(taskDefInfo)
+		loadBalancers.apply(ctx, taskDefInfo)
+		taskDefInfos = append(taskDefInfos, taskDefInfo)
+	}
+	return taskDefInfos, failures, nil
+}
+
+// renderConvertedService renders the manifests of a TaskDefInfo as YAML strings
+func renderConvertedService(taskDefInfo *TaskDefInfo) (*ConvertedService, error) {
+	applyConfigChecksums(taskDefInfo)
+	files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &ConvertedService{
+		Name:      taskDefInfo.Name,
+		Manifests: make(map[string]string, len(files)),
+		Unmapped:  taskDefInfo.Unmapped,
+	}
+
+	for filename, content := range files {
+		data, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
+		}
+		svc.Manifests[filename] = string(data)
+	}
+
+	return svc, nil
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Warning: Failed to encode response: %v", err)
+	}
+}
+
+// writeJSONError writes a JSON error response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postConvert sends a request to POST /convert and decodes the response
+func postConvert(t *testing.T, srv *conversionServer, method, body string) (int, ConvertResponse, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/convert", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.handleConvert(rec, req)
+
+	var resp ConvertResponse
+	var failure struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	json.Unmarshal(rec.Body.Bytes(), &failure)
+	return rec.Code, resp, failure.Error
+}
+
+// TestHandleConvertValidation tests the method and body checks of POST /convert
+func TestHandleConvertValidation(t *testing.T) {
+	srv := &conversionServer{}
+
+	req := httptest.NewRequest(http.MethodGet, "/convert", nil)
+	rec := httptest.NewRecorder()
+	srv.handleConvert(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET status = %d, Allow = %q, want %d and POST", rec.Code, rec.Header().Get("Allow"), http.StatusMethodNotAllowed)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"neither", `{}`, "exactly one of taskDefinition or cluster"},
+		{"both", `{"cluster": "prod", "taskDefinition": {"family": "web"}}`, "exactly one of taskDefinition or cluster"},
+		{"invalid json", `{"cluster":`, "invalid request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, msg := postConvert(t, srv, http.MethodPost, tt.body)
+			if code != http.StatusBadRequest || !strings.Contains(msg, tt.want) {
+				t.Errorf("status = %d, error = %q, want %d with %q", code, msg, http.StatusBadRequest, tt.want)
+			}
+		})
+	}
+
+	if code, _, msg := postConvert(t, srv, http.MethodPost, `{"cluster": "prod"}`); code != http.StatusUnprocessableEntity || !strings.Contains(msg, "region is required") {
+		t.Errorf("cluster without region: status = %d, error = %q, want the missing region", code, msg)
+	}
+	if code, _, msg := postConvert(t, srv, http.MethodPost, `{"taskDefinition": {"containerDefinitions": []}}`); code != http.StatusUnprocessableEntity || !strings.Contains(msg, "taskDefinitionArn or family") {
+		t.Errorf("task definition without a name: status = %d, error = %q, want the missing family", code, msg)
+	}
+}
+
+// TestHandleConvertTaskDefinition tests converting a task definition in the request body
+func TestHandleConvertTaskDefinition(t *testing.T) {
+	body := `{"taskDefinition": {
+	  "taskDefinitionArn": "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3",
+	  "family": "web",
+	  "containerDefinitions": [{"name": "app", "image": "nginx:1.27", "portMappings": [{"containerPort": 80}]}]
+	}}`
+	code, resp, msg := postConvert(t, &conversionServer{}, http.MethodPost, body)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, error = %q, want %d", code, msg, http.StatusOK)
+	}
+	if len(resp.Services) != 1 || resp.Services[0].Name != "web" {
+		t.Fatalf("services = %+v, want web", resp.Services)
+	}
+	var deployment string
+	for name, manifest := range resp.Services[0].Manifests {
+		if strings.Contains(manifest, "kind: Deployment") {
+			deployment = name
+			if !strings.Contains(manifest, "image: nginx:1.27") {
+				t.Errorf("%s = %s, want the nginx image", name, manifest)
+			}
+		}
+	}
+	if deployment == "" {
+		t.Errorf("manifests = %v, want a Deployment", resp.Services[0].Manifests)
+	}
+}
+
+// TestHandleConvertCluster tests converting the services of a cluster read
+// with a stubbed client
+func TestHandleConvertCluster(t *testing.T) {
+	var gotRegion, gotCluster string
+	srv := &conversionServer{
+		defaultRegion: "us-east-1",
+		fetchCluster: func(_ context.Context, region, cluster string) ([]*TaskDefInfo, []string, error) {
+			gotRegion, gotCluster = region, cluster
+			var infos []*TaskDefInfo
+			for _, family := range []string{"web", "api"} {
+				info, err := buildTaskDefInfo(appTaskDef(family), family)
+				if err != nil {
+					return nil, nil, err
+				}
+				infos = append(infos, info)
+			}
+			return infos, []string{"failed to describe worker"}, nil
+		},
+	}
+
+	code, resp, msg := postConvert(t, srv, http.MethodPost, `{"cluster": "prod", "region": "eu-west-1"}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, error = %q, want %d", code, msg, http.StatusOK)
+	}
+	if gotRegion != "eu-west-1" || gotCluster != "prod" {
+		t.Errorf("fetched %s in %s, want prod in eu-west-1", gotCluster, gotRegion)
+	}
+	if len(resp.Services) != 2 || resp.Services[0].Name != "api" || resp.Services[1].Name != "web" {
+		t.Errorf("services = %+v, want api and web", resp.Services)
+	}
+	if len(resp.Failures) != 1 || resp.Failures[0] != "failed to describe worker" {
+		t.Errorf("failures = %v, want the worker", resp.Failures)
+	}
+
+	postConvert(t, srv, http.MethodPost, `{"cluster": "prod"}`)
+	if gotRegion != "us-east-1" {
+		t.Errorf("region = %s, want the default us-east-1", gotRegion)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// StatefulSetSettings render the pod spec of a service as a StatefulSet with
+// a PersistentVolumeClaim per pod instead of a Deployment
+type StatefulSetSettings struct {
+	// ServiceName is the Service governing the network identity of the pods
+	ServiceName  string        `json:"serviceName"`
+	VolumeClaims []VolumeClaim `json:"volumeClaims"`
+}
+
+// VolumeClaim is a PersistentVolumeClaim template mounted into a container
+type VolumeClaim struct {
+	Name      string `json:"name"`
+	Container string `json:"container"`
+	MountPath string `json:"mountPath"`
+	Size      string `json:"size"`
+}
+
+// smartTemplate is the opinionated conversion of a well-known image
+type smartTemplate struct {
+	// Port is the image's default port, probed when the container maps none
+	Port      int32
+	Liveness  func(port int32) corev1.ProbeHandler
+	Readiness func(port int32) corev1.ProbeHandler
+	// TimeoutSeconds of the probes, for images whose checks run a CLI
+	TimeoutSeconds int32
+	// DataPath is where a database keeps its data. Images with one become a
+	// StatefulSet with a DataSize claim mounted there.
+	DataPath string
+	DataSize string
+	// Env is set on containers that don't define it
+	Env map[string]string
+}
+
+// smartTemplates are the templates of --smart-templates by image name
+var smartTemplates = map[string]smartTemplate{
+	"nginx": {
+		Port:      80,
+		Liveness:  tcpSocketHandler,
+		Readiness: httpGetHandler("/"),
+	},
+	"redis": {
+		Port:      6379,
+		Liveness:  execHandler("redis-cli", "ping"),
+		Readiness: execHandler("redis-cli", "ping"),
+		DataPath:  "/data",
+		DataSize:  "1Gi",
+	},
+	"postgres": {
+		Port:      5432,
+		Liveness:  execHandler("sh", "-c", `pg_isready -h 127.0.0.1 -U "${POSTGRES_USER:-postgres}"`),
+		Readiness: execHandler("sh", "-c", `pg_isready -h 127.0.0.1 -U "${POSTGRES_USER:-postgres}"`),
+		DataPath:  "/var/lib/postgresql/data",
+		DataSize:  "10Gi",
+		// initdb refuses the lost+found directory at the root of most volumes
+		Env: map[string]string{"PGDATA": "/var/lib/postgresql/data/pgdata"},
+	},
+	"rabbitmq": {
+		Port:           5672,
+		Liveness:       execHandler("rabbitmq-diagnostics", "-q", "ping"),
+		Readiness:      execHandler("rabbitmq-diagnostics", "-q", "check_port_connectivity"),
+		TimeoutSeconds: 15,
+		DataPath:       "/var/lib/rabbitmq",
+		DataSize:       "5Gi",
+	},
+}
+
+// tcpSocketHandler checks that the port accepts connections
+func tcpSocketHandler(port int32) corev1.ProbeHandler {
+	return corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(port)}}
+}
+
+// httpGetHandler returns a check of an HTTP path on the port
+func httpGetHandler(path string) func(port int32) corev1.ProbeHandler {
+	return func(port int32) corev1.ProbeHandler {
+		return corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: path, Port: intstr.FromInt32(port)}}
+	}
+}
+
+// execHandler returns a check running a command in the container
+func execHandler(command ...string) func(port int32) corev1.ProbeHandler {
+	return func(int32) corev1.ProbeHandler {
+		return corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: command}}
+	}
+}
+
+// smartImageName returns the name a well-known image is recognized by: the
+// last path segment of its repository, e.g. postgres for
+// public.ecr.aws/docker/library/postgres:16
+func smartImageName(image string) string {
+	repository, _, _ := splitImageReference(image)
+	return path.Base(repository)
+}
+
+// applySmartTemplates converts the containers of well-known images the way
+// they are usually run on Kubernetes: standard probes where the task
+// definition has none, and for databases a StatefulSet keeping the data on a
+// PersistentVolumeClaim per pod instead of a Deployment
+func applySmartTemplates(taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return
+	}
+
+	var claims []VolumeClaim
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		image := smartImageName(c.Image)
+		tmpl, ok := smartTemplates[image]
+		if !ok {
+			continue
+		}
+
+		port := tmpl.Port
+		if len(c.Ports) > 0 {
+			port = c.Ports[0].ContainerPort
+		}
+		var applied []string
+		if c.LivenessProbe == nil {
+			c.LivenessProbe = tmpl.probe(tmpl.Liveness(port))
+			applied = append(applied, "liveness probe")
+		}
+		if c.ReadinessProbe == nil && !isWorker(taskDefInfo) {
+			c.ReadinessProbe = tmpl.probe(tmpl.Readiness(port))
+			applied = append(applied, "readiness probe")
+		}
+		for _, name := range sortedKeys(tmpl.Env) {
+			if definesEnv(taskDefInfo, c, name) {
+				continue
+			}
+			c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: tmpl.Env[name]})
+			for j := range taskDefInfo.Containers {
+				if taskDefInfo.Containers[j].Name == c.Name {
+					if taskDefInfo.Containers[j].EnvVars == nil {
+						taskDefInfo.Containers[j].EnvVars = map[string]string{}
+					}
+					taskDefInfo.Containers[j].EnvVars[name] = tmpl.Env[name]
+				}
+			}
+			applied = append(applied, name)
+		}
+		if tmpl.DataPath != "" {
+			if claim, ok := dataClaim(taskDefInfo, c, tmpl); ok {
+				claims = append(claims, claim)
+				applied = append(applied, fmt.Sprintf("%s PersistentVolumeClaim at %s", claim.Size, claim.MountPath))
+			}
+		}
+
+		if len(applied) > 0 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s runs %s; --smart-templates added the %s", c.Name, image, strings.Join(applied, ", ")))
+			log.Printf("✓ Applied the %s template to %s/%s", image, taskDefInfo.Name, c.Name)
+		}
+	}
+	syncContainerProbes(taskDefInfo)
+
+	if len(claims) > 0 {
+		toStatefulSet(taskDefInfo, claims)
+	}
+}
+
+// probe returns a probe with the template's timeout
+func (t smartTemplate) probe(handler corev1.ProbeHandler) *corev1.Probe {
+	probe := &corev1.Probe{ProbeHandler: handler, PeriodSeconds: 10, FailureThreshold: 3, TimeoutSeconds: 5}
+	if t.TimeoutSeconds > 0 {
+		probe.TimeoutSeconds = t.TimeoutSeconds
+	}
+	return probe
+}
+
+// definesEnv reports whether the task definition or converted container sets
+// an environment variable, directly or as a secret
+func definesEnv(taskDefInfo *TaskDefInfo, c *corev1.Container, name string) bool {
+	for _, env := range c.Env {
+		if env.Name == name {
+			return true
+		}
+	}
+	if taskDefInfo.Source == nil {
+		return false
+	}
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		if sanitizeName(aws.ToString(def.Name)) != c.Name {
+			continue
+		}
+		for _, env := range def.Environment {
+			if aws.ToString(env.Name) == name {
+				return true
+			}
+		}
+		for _, secret := range def.Secrets {
+			if aws.ToString(secret.Name) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dataClaim mounts a PersistentVolumeClaim at the data path of a database
+// container. A volume already mounted there is replaced unless it is
+// persistent, e.g. an EFS volume, in which case the container keeps it.
+func dataClaim(taskDefInfo *TaskDefInfo, c *corev1.Container, tmpl smartTemplate) (VolumeClaim, bool) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	claim := VolumeClaim{Name: "data", Container: c.Name, MountPath: tmpl.DataPath, Size: tmpl.DataSize}
+
+	for _, mount := range c.VolumeMounts {
+		if path.Clean(mount.MountPath) != tmpl.DataPath {
+			continue
+		}
+		for i, volume := range podSpec.Volumes {
+			if volume.Name != mount.Name {
+				continue
+			}
+			if volume.EmptyDir == nil && volume.HostPath == nil {
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Container %s keeps volume %s at %s, which is already persistent", c.Name, volume.Name, tmpl.DataPath))
+				return VolumeClaim{}, false
+			}
+			podSpec.Volumes = append(podSpec.Volumes[:i], podSpec.Volumes[i+1:]...)
+			break
+		}
+		// The claim takes over the name of the mounted volume
+		claim.Name = mount.Name
+		return claim, true
+	}
+
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == claim.Name {
+			claim.Name = c.Name + "-data"
+		}
+	}
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: claim.Name, MountPath: claim.MountPath})
+	return claim, true
+}
+
+// toStatefulSet renders the service as a StatefulSet with the claims
+func toStatefulSet(taskDefInfo *TaskDefInfo, claims []VolumeClaim) {
+	manifests := &taskDefInfo.Manifests
+	settings := &StatefulSetSettings{ServiceName: taskDefInfo.Name, VolumeClaims: claims}
+	if len(manifests.Services) > 0 {
+		settings.ServiceName = manifests.Services[0].Name
+	} else {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("StatefulSet %s references Service %s for the network identity of its pods; create it as a headless Service", taskDefInfo.Name, settings.ServiceName))
+	}
+	manifests.StatefulSet = settings
+
+	if manifests.ProgressDeadlineSeconds != nil {
+		manifests.ProgressDeadlineSeconds = nil
+		taskDefInfo.Notes = append(taskDefInfo.Notes, "StatefulSets have no progressDeadlineSeconds; the ECS circuit breaker is not mapped")
+	}
+	for _, svc := range taskDefInfo.Services {
+		if svc.DesiredCount > 1 {
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS service %s runs %d tasks; each StatefulSet replica gets its own volume, and the data is not replicated between them", aws.ToString(svc.ServiceName), svc.DesiredCount))
+		}
+	}
+	log.Printf("✓ Converted %s to a StatefulSet with %d PersistentVolumeClaim template(s)", taskDefInfo.Name, len(claims))
+}
+
+// workloadKind returns the kind the pod spec of a service is rendered as
+func workloadKind(manifests K8sManifests) string {
+	if manifests.StatefulSet != nil {
+		return "StatefulSet"
+	}
+	return "Deployment"
+}
+
+// volumeClaimTemplates returns the claim templates of the StatefulSet
+func (s *StatefulSetSettings) volumeClaimTemplates() []map[string]interface{} {
+	var templates []map[string]interface{}
+	for _, claim := range s.VolumeClaims {
+		templates = append(templates, map[string]interface{}{
+			"metadata": map[string]interface{}{"name": claim.Name},
+			"spec": map[string]interface{}{
+				"accessModes": []string{"ReadWriteOnce"},
+				"resources": map[string]interface{}{
+					"requests": map[string]string{"storage": claim.Size},
+				},
+			},
+		})
+	}
+	return templates
+}
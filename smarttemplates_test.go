@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestSmartImageName tests recognizing well-known images
+func TestSmartImageName(t *testing.T) {
+	tests := map[string]string{
+		"nginx":             "nginx",
+		"nginx:1.27-alpine": "nginx",
+		"public.ecr.aws/docker/library/postgres:16": "postgres",
+		"bitnami/redis@sha256:abc":                  "redis",
+		"registry.local:5000/team/app:1.0":          "app",
+	}
+	for image, want := range tests {
+		if got := smartImageName(image); got != want {
+			t.Errorf("smartImageName(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+// TestApplySmartTemplates tests the nginx probes and the postgres StatefulSet
+func TestApplySmartTemplates(t *testing.T) {
+	nginx, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("web"),
+			Image:        aws.String("nginx:1.27"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8080)}},
+		}},
+	}, "web")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	applySmartTemplates(nginx)
+	c := nginx.Manifests.Deployment.Containers[0]
+	if c.ReadinessProbe == nil || c.ReadinessProbe.HTTPGet == nil || c.ReadinessProbe.HTTPGet.Port.IntValue() != 8080 {
+		t.Errorf("nginx readiness probe = %+v, want an HTTP check of port 8080", c.ReadinessProbe)
+	}
+	if c.LivenessProbe == nil || c.LivenessProbe.TCPSocket == nil {
+		t.Errorf("nginx liveness probe = %+v, want a TCP check", c.LivenessProbe)
+	}
+	if nginx.Manifests.StatefulSet != nil {
+		t.Errorf("nginx became a StatefulSet")
+	}
+
+	db, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family:   aws.String("db"),
+		Revision: 3,
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:  aws.String("postgres"),
+			Image: aws.String("postgres:16"),
+		}},
+	}, "db")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	applySmartTemplates(db)
+
+	statefulSet := db.Manifests.StatefulSet
+	if statefulSet == nil || len(statefulSet.VolumeClaims) != 1 {
+		t.Fatalf("statefulSet = %+v, want one volume claim", statefulSet)
+	}
+	claim := statefulSet.VolumeClaims[0]
+	if claim.MountPath != "/var/lib/postgresql/data" || claim.Size != "10Gi" {
+		t.Errorf("claim = %+v, want 10Gi at the postgres data directory", claim)
+	}
+	c = db.Manifests.Deployment.Containers[0]
+	if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].Name != claim.Name {
+		t.Errorf("volume mounts = %+v, want the claim", c.VolumeMounts)
+	}
+	if db.Containers[0].EnvVars["PGDATA"] != "/var/lib/postgresql/data/pgdata" {
+		t.Errorf("env = %v, want PGDATA below the volume root", db.Containers[0].EnvVars)
+	}
+
+	files, err := renderManifests("db", db.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	doc, ok := files["db-statefulset.yaml"].(map[string]interface{})
+	if !ok || doc["kind"] != "StatefulSet" {
+		t.Fatalf("files = %v, want db-statefulset.yaml", sortedDocKeys(files))
+	}
+	spec := doc["spec"].(map[string]interface{})
+	if spec["serviceName"] != "db" || len(spec["volumeClaimTemplates"].([]map[string]interface{})) != 1 {
+		t.Errorf("spec = %v, want the service name and claim template", spec)
+	}
+	containers := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]map[string]interface{})
+	if containers[0]["volumeMounts"] == nil {
+		t.Errorf("container = %v, want the claim mount", containers[0])
+	}
+	if _, ok := files["db-deployment.yaml"]; ok {
+		t.Errorf("db-deployment.yaml rendered next to the StatefulSet")
+	}
+}
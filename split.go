@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// splitContainers converts each container of a task definition that is not a
+// declared sidecar into its own service, named <task-def>-<container>, with the
+// sidecars added to every pod. The ConfigMaps, Secrets and Services of the
+// sidecars are generated once, with the first service, and shared by the
+// others. Containers connected by links stay in one service, named after the
+// first of them. Task definitions with a single non-sidecar container, or
+// whose containers are all linked, are returned as is.
+func splitContainers(taskDefInfo *TaskDefInfo, sidecars []string) ([]*TaskDefInfo, error) {
+	taskDef := taskDefInfo.Source
+	if taskDef == nil {
+		return []*TaskDefInfo{taskDefInfo}, nil
+	}
+
+	declared := map[string]bool{}
+	for _, name := range sidecars {
+		declared[sanitizeName(name)] = true
+	}
+
+	var primaries, sidecarDefs []types.ContainerDefinition
+	for _, def := range taskDef.ContainerDefinitions {
+		if declared[sanitizeName(aws.ToString(def.Name))] {
+			sidecarDefs = append(sidecarDefs, def)
+		} else {
+			primaries = append(primaries, def)
+		}
+	}
+	if len(primaries) < 2 {
+		return []*TaskDefInfo{taskDefInfo}, nil
+	}
+	groups := linkGroups(primaries)
+	if len(groups) < 2 {
+		log.Printf("Info: Not splitting task definition %s: its containers are linked", taskDefInfo.Name)
+		taskDefInfo.Notes = append(taskDefInfo.Notes, "Containers are not split (--split-containers): they are connected by links and share the pod")
+		return []*TaskDefInfo{taskDefInfo}, nil
+	}
+
+	shared := map[string]bool{}
+	for _, def := range sidecarDefs {
+		name := sanitizeName(aws.ToString(def.Name))
+		shared[name] = true
+		shared[sanitizeName(name+"-config")] = true
+		shared[sanitizeName(name+"-secret")] = true
+	}
+
+	var splits []*TaskDefInfo
+	for i, group := range groups {
+		primary := group[0]
+		sourceName := fmt.Sprintf("%s-%s", taskDefInfo.SourceName, aws.ToString(primary.Name))
+		defs := append(append([]types.ContainerDefinition{}, group...), sidecarDefs...)
+
+		// Converted under its own family so labels and selectors use the split name
+		converted := *taskDef
+		converted.ContainerDefinitions = defs
+		converted.TaskDefinitionArn = nil
+		converted.Family = aws.String(sourceName)
+
+		split, err := newServiceTaskDefInfo(&converted, sourceName, taskDefInfo.Services)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split container %s of %s: %w", aws.ToString(primary.Name), taskDefInfo.Name, err)
+		}
+
+		// The source still points at the ECS task definition for the report and state
+		source := *taskDef
+		source.ContainerDefinitions = defs
+		split.Source = &source
+		split.Tags = taskDefInfo.Tags
+
+		if i > 0 {
+			dropSharedObjects(&split.Manifests, shared)
+		}
+		if len(group) > 1 {
+			split.Notes = append(split.Notes, fmt.Sprintf("Linked containers %s of task definition %s run in their own Deployment %s (--split-containers)", containerNames(group), taskDefInfo.SourceName, split.Name))
+		} else {
+			split.Notes = append(split.Notes, fmt.Sprintf("Container %s of task definition %s runs in its own Deployment %s (--split-containers)", aws.ToString(primary.Name), taskDefInfo.SourceName, split.Name))
+		}
+		if len(sidecarDefs) > 0 {
+			split.Notes = append(split.Notes, fmt.Sprintf("Sidecars added to the pod: %s", containerNames(sidecarDefs)))
+		}
+		splits = append(splits, split)
+	}
+
+	log.Printf("Info: Split task definition %s into %d Deployments", taskDefInfo.Name, len(splits))
+	return splits, nil
+}
+
+// dropSharedObjects removes the sidecar objects already generated with the first
+// service of a split task definition
+func dropSharedObjects(manifests *K8sManifests, shared map[string]bool) {
+	var configMaps []*corev1.ConfigMap
+	for _, cm := range manifests.ConfigMaps {
+		if cm != nil && !shared[cm.Name] {
+			configMaps = append(configMaps, cm)
+		}
+	}
+	manifests.ConfigMaps = configMaps
+
+	var secrets []*corev1.Secret
+	for _, secret := range manifests.Secrets {
+		if secret != nil && !shared[secret.Name] {
+			secrets = append(secrets, secret)
+		}
+	}
+	manifests.Secrets = secrets
+
+	var services []*corev1.Service
+	for _, svc := range manifests.Services {
+		if svc != nil && !shared[svc.Name] {
+			services = append(services, svc)
+		}
+	}
+	manifests.Services = services
+}
+
+// containerNames lists the names of container definitions for notes
+func containerNames(defs []types.ContainerDefinition) string {
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, aws.ToString(def.Name))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestSplitContainers tests converting each non-sidecar container into its own service
+func TestSplitContainers(t *testing.T) {
+	container := func(name string, port int32) types.ContainerDefinition {
+		return types.ContainerDefinition{
+			Name:         aws.String(name),
+			Image:        aws.String(name + ":1"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(port)}},
+			Environment:  []types.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("prod")}},
+		}
+	}
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/shop:4"),
+		Family:            aws.String("shop"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			container("web", 80),
+			container("worker", 9000),
+			container("envoy", 9901),
+		},
+	}
+	info, err := buildTaskDefInfo(taskDef, "shop")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+
+	splits, err := splitContainers(info, []string{"Envoy"})
+	if err != nil {
+		t.Fatalf("splitContainers() error = %v", err)
+	}
+	if len(splits) != 2 {
+		t.Fatalf("got %d splits, want 2", len(splits))
+	}
+
+	for i, want := range []string{"shop-web", "shop-worker"} {
+		split := splits[i]
+		if split.Name != want {
+			t.Errorf("split %d Name = %q, want %q", i, split.Name, want)
+		}
+		containers := split.Manifests.Deployment.Containers
+		if len(containers) != 2 || containers[1].Name != "envoy" {
+			t.Errorf("%s containers = %+v, want primary and envoy", want, containers)
+		}
+		for _, svc := range split.Manifests.Services {
+			if svc.Spec.Selector["app"] != want {
+				t.Errorf("%s Service %s selector = %v", want, svc.Name, svc.Spec.Selector)
+			}
+		}
+		if got := aws.ToString(split.Source.TaskDefinitionArn); got != aws.ToString(taskDef.TaskDefinitionArn) {
+			t.Errorf("%s source ARN = %q", want, got)
+		}
+	}
+
+	// Sidecar objects are generated with the first service only
+	if got := len(splits[0].Manifests.ConfigMaps); got != 2 {
+		t.Errorf("first split has %d ConfigMaps, want 2", got)
+	}
+	if got := len(splits[1].Manifests.ConfigMaps); got != 1 || splits[1].Manifests.ConfigMaps[0].Name != "worker-config" {
+		t.Errorf("second split ConfigMaps = %d, want worker-config only", got)
+	}
+	if got := len(splits[1].Manifests.Services); got != 1 || splits[1].Manifests.Services[0].Name != "worker" {
+		t.Errorf("second split Services = %d, want worker only", got)
+	}
+
+	// A single container besides the sidecars is not split
+	single, err := splitContainers(info, []string{"worker", "envoy"})
+	if err != nil {
+		t.Fatalf("splitContainers() error = %v", err)
+	}
+	if len(single) != 1 || single[0] != info {
+		t.Errorf("splitContainers() with one primary returned %d infos", len(single))
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// capacityTypeLabel is the node label Karpenter (and EKS managed node groups
+// via eks.amazonaws.com/capacityType) use to distinguish spot from on-demand
+const capacityTypeLabel = "karpenter.sh/capacity-type"
+
+// spotToleration lets pods onto spot nodes tainted with the capacity type
+var spotToleration = corev1.Toleration{
+	Key:      capacityTypeLabel,
+	Operator: corev1.TolerationOpEqual,
+	Value:    "spot",
+	Effect:   corev1.TaintEffectNoSchedule,
+}
+
+// applyCapacityProviderStrategy preserves the spot share of the services'
+// capacity provider strategy: all-spot services are pinned to spot nodes, and
+// mixed strategies prefer spot and on-demand nodes in proportion to the weights
+func applyCapacityProviderStrategy(taskDefInfo *TaskDefInfo) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return
+	}
+
+	var spotWeight, totalWeight int32
+	var strategy []string
+	for _, svc := range taskDefInfo.Services {
+		for _, item := range svc.CapacityProviderStrategy {
+			name := aws.ToString(item.CapacityProvider)
+			strategy = append(strategy, fmt.Sprintf("%s:%d", name, item.Weight))
+			totalWeight += item.Weight
+			if isSpotCapacityProvider(name) {
+				spotWeight += item.Weight
+			}
+			if item.Base > 0 {
+				taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Capacity provider %s has base %d, which has no Kubernetes equivalent; only the weights are mapped", name, item.Base))
+			}
+		}
+	}
+	if spotWeight == 0 || totalWeight == 0 {
+		return
+	}
+
+	podSpec.Tolerations = append(podSpec.Tolerations, spotToleration)
+
+	if spotWeight == totalWeight {
+		if podSpec.NodeSelector == nil {
+			podSpec.NodeSelector = map[string]string{}
+		}
+		podSpec.NodeSelector[capacityTypeLabel] = "spot"
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Capacity provider strategy %s runs on spot only: pods select %s=spot nodes", strings.Join(strategy, ","), capacityTypeLabel))
+		log.Printf("✓ Pinned %s to spot capacity", taskDefInfo.Name)
+		return
+	}
+
+	spotPercent := int32(math.Round(float64(spotWeight) * 100 / float64(totalWeight)))
+	// Preferred weights must be 1-100
+	spotPercent = min(max(spotPercent, 1), 99)
+	preferCapacity := func(value string, weight int32) corev1.PreferredSchedulingTerm {
+		return corev1.PreferredSchedulingTerm{
+			Weight: weight,
+			Preference: corev1.NodeSelectorTerm{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      capacityTypeLabel,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{value},
+				}},
+			},
+		}
+	}
+
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &corev1.Affinity{}
+	}
+	if podSpec.Affinity.NodeAffinity == nil {
+		podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := podSpec.Affinity.NodeAffinity
+	nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		preferCapacity("spot", spotPercent),
+		preferCapacity("on-demand", 100-spotPercent),
+	)
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Capacity provider strategy %s is %d%% spot: pods tolerate spot nodes and prefer spot/on-demand with weights %d/%d. The scheduler treats the weights as a preference, not an exact split", strings.Join(strategy, ","), spotPercent, spotPercent, 100-spotPercent))
+	log.Printf("✓ Mapped %d%% spot capacity of %s to node affinity", spotPercent, taskDefInfo.Name)
+}
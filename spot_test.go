@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyCapacityProviderStrategy tests mapping spot capacity providers to scheduling constraints
+func TestApplyCapacityProviderStrategy(t *testing.T) {
+	newTaskDefInfo := func(strategy ...types.CapacityProviderStrategyItem) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name:      "web",
+			Services:  []types.Service{{CapacityProviderStrategy: strategy}},
+			Manifests: K8sManifests{Deployment: &corev1.PodSpec{}},
+		}
+	}
+
+	spotOnly := newTaskDefInfo(types.CapacityProviderStrategyItem{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1})
+	applyCapacityProviderStrategy(spotOnly)
+	podSpec := spotOnly.Manifests.Deployment
+	if podSpec.NodeSelector[capacityTypeLabel] != "spot" || len(podSpec.Tolerations) != 1 {
+		t.Errorf("spot only: nodeSelector = %v, tolerations = %v", podSpec.NodeSelector, podSpec.Tolerations)
+	}
+	if podSpec.Affinity != nil {
+		t.Errorf("spot only: affinity = %+v, want none", podSpec.Affinity)
+	}
+
+	mixed := newTaskDefInfo(
+		types.CapacityProviderStrategyItem{CapacityProvider: aws.String("FARGATE"), Weight: 1, Base: 2},
+		types.CapacityProviderStrategyItem{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 3},
+	)
+	applyCapacityProviderStrategy(mixed)
+	podSpec = mixed.Manifests.Deployment
+	if len(podSpec.NodeSelector) != 0 || len(podSpec.Tolerations) != 1 {
+		t.Errorf("mixed: nodeSelector = %v, tolerations = %v", podSpec.NodeSelector, podSpec.Tolerations)
+	}
+	terms := podSpec.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(terms) != 2 || terms[0].Weight != 75 || terms[1].Weight != 25 {
+		t.Errorf("mixed: preferred terms = %+v, want spot 75 and on-demand 25", terms)
+	}
+	if len(mixed.Notes) != 2 {
+		t.Errorf("mixed: notes = %v, want a base note and a weight note", mixed.Notes)
+	}
+
+	onDemand := newTaskDefInfo(types.CapacityProviderStrategyItem{CapacityProvider: aws.String("FARGATE"), Weight: 1})
+	applyCapacityProviderStrategy(onDemand)
+	if podSpec := onDemand.Manifests.Deployment; podSpec.Tolerations != nil || podSpec.Affinity != nil {
+		t.Errorf("on-demand: pod spec = %+v, want unchanged", podSpec)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// exitPartialSuccess is the exit code of a run that wrote manifests but
+// failed to convert some services or to complete some output stages
+const exitPartialSuccess = 2
+
+// stageFailure is an output stage that returned an error
+type stageFailure struct {
+	Stage string
+	Err   error
+}
+
+// stageRunner runs the output stages of a cluster (Helm, Kustomize, report,
+// ...) after its manifests are written. A failing stage does not undo the
+// output of earlier ones; later stages are skipped unless continueOnError is
+// set (--continue-on-error).
+type stageRunner struct {
+	continueOnError bool
+	failures        []stageFailure
+	skipped         []string
+}
+
+// outputStage is a named stage of runParallel
+type outputStage struct {
+	name string
+	fn   func() error
+}
+
+// run executes a stage, or skips it after an earlier failure
+func (s *stageRunner) run(stage string, fn func() error) {
+	if s.skip(stage) {
+		return
+	}
+	s.record(stage, fn())
+}
+
+// runParallel executes independent stages concurrently, such as the
+// exporters of the conversion model, or skips them after an earlier failure.
+// A failing stage doesn't stop the others already running; failures are
+// recorded in the order of the stages.
+func (s *stageRunner) runParallel(stages []outputStage) {
+	var run []outputStage
+	for _, stage := range stages {
+		if !s.skip(stage.name) {
+			run = append(run, stage)
+		}
+	}
+
+	errs := make([]error, len(run))
+	var wg sync.WaitGroup
+	for i, stage := range run {
+		wg.Add(1)
+		go func(i int, fn func() error) {
+			defer wg.Done()
+			errs[i] = fn()
+		}(i, stage.fn)
+	}
+	wg.Wait()
+
+	for i, stage := range run {
+		s.record(stage.name, errs[i])
+	}
+}
+
+// skip records a stage as skipped after an earlier failure
+func (s *stageRunner) skip(stage string) bool {
+	if len(s.failures) == 0 || s.continueOnError {
+		return false
+	}
+	log.Printf("Skipping %s after a failed stage (use --continue-on-error to run every stage)", stage)
+	s.skipped = append(s.skipped, stage)
+	return true
+}
+
+// record records the failure of a stage
+func (s *stageRunner) record(stage string, err error) {
+	if err != nil {
+		log.Printf("Error: %s failed: %v", stage, err)
+		s.failures = append(s.failures, stageFailure{Stage: stage, Err: err})
+	}
+}
+
+// partialSuccessError reports a run that wrote manifests but had failed
+// services or stages. main exits with exitPartialSuccess for it.
+type partialSuccessError struct {
+	err error
+}
+
+func (e *partialSuccessError) Error() string {
+	return "partial success: " + e.err.Error()
+}
+
+func (e *partialSuccessError) Unwrap() error {
+	return e.err
+}
+
+// result returns the outcome of a cluster's conversion: nil when everything
+// succeeded and a partialSuccessError when manifests were written despite
+// failures
+func (s *stageRunner) result(converted, failed int) error {
+	if len(s.failures) == 0 && failed == 0 {
+		return nil
+	}
+	parts := []string{fmt.Sprintf("converted %d task definition(s)", converted)}
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	}
+	for _, f := range s.failures {
+		parts = append(parts, fmt.Sprintf("%s failed: %v", f.Stage, f.Err))
+	}
+	if len(s.skipped) > 0 {
+		parts = append(parts, "skipped "+strings.Join(s.skipped, ", "))
+	}
+	return &partialSuccessError{err: errors.New(strings.Join(parts, "; "))}
+}
+
+// clusterFailures aggregates the errors of a multi-cluster run. It is a
+// partial success when every failed cluster converted some services.
+func clusterFailures(failed []string, partial bool, total int) error {
+	err := fmt.Errorf("conversion failed for %d of %d cluster(s): %s", len(failed), total, strings.Join(failed, ", "))
+	if partial {
+		return &partialSuccessError{err: err}
+	}
+	return err
+}
+
+// isPartialSuccess reports whether err is a partial success
+func isPartialSuccess(err error) bool {
+	var partial *partialSuccessError
+	return errors.As(err, &partial)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestStageRunner tests that failed stages skip the remaining ones unless
+// --continue-on-error is set, and that failures are a partial success
+func TestStageRunner(t *testing.T) {
+	fail := func() error { return errors.New("disk full") }
+
+	for _, continueOnError := range []bool{false, true} {
+		stages := &stageRunner{continueOnError: continueOnError}
+		var ran []string
+		stages.run("report", func() error { ran = append(ran, "report"); return nil })
+		stages.run("Helm chart", func() error { ran = append(ran, "helm"); return fail() })
+		stages.run("Kustomize structure", func() error { ran = append(ran, "kustomize"); return nil })
+
+		want := "report,helm"
+		if continueOnError {
+			want = "report,helm,kustomize"
+		}
+		if got := strings.Join(ran, ","); got != want {
+			t.Errorf("continueOnError=%v: ran %s, want %s", continueOnError, got, want)
+		}
+		if len(stages.failures) != 1 || stages.failures[0].Stage != "Helm chart" {
+			t.Errorf("continueOnError=%v: failures = %+v", continueOnError, stages.failures)
+		}
+
+		err := stages.result(3, 0)
+		if !isPartialSuccess(err) {
+			t.Fatalf("continueOnError=%v: result() = %v, want a partial success", continueOnError, err)
+		}
+		if !strings.Contains(err.Error(), "Helm chart failed: disk full") {
+			t.Errorf("result() = %q", err)
+		}
+		if skipped := strings.Contains(err.Error(), "skipped Kustomize structure"); skipped == continueOnError {
+			t.Errorf("continueOnError=%v: result() = %q", continueOnError, err)
+		}
+	}
+
+	// Exporters run concurrently; all of them run and fail in stage order
+	parallel := &stageRunner{}
+	parallel.runParallel([]outputStage{
+		{"Helm chart", fail},
+		{"Kustomize structure", func() error { return nil }},
+		{"Crossplane objects", fail},
+	})
+	if len(parallel.failures) != 2 || parallel.failures[0].Stage != "Helm chart" || parallel.failures[1].Stage != "Crossplane objects" {
+		t.Errorf("runParallel() failures = %+v", parallel.failures)
+	}
+	parallel.runParallel([]outputStage{{"report", func() error { t.Error("ran a stage after a failure"); return nil }}})
+	if len(parallel.skipped) != 1 {
+		t.Errorf("runParallel() after a failure skipped %v", parallel.skipped)
+	}
+
+	if err := (&stageRunner{}).result(2, 0); err != nil {
+		t.Errorf("result() without failures = %v", err)
+	}
+	if err := (&stageRunner{}).result(2, 1); !isPartialSuccess(err) || !strings.Contains(err.Error(), "1 failed") {
+		t.Errorf("result() with a failed service = %v", err)
+	}
+
+	if err := clusterFailures([]string{"prod"}, true, 2); !isPartialSuccess(err) {
+		t.Errorf("clusterFailures(partial) = %v", err)
+	}
+	if err := clusterFailures([]string{"prod"}, false, 2); isPartialSuccess(err) {
+		t.Errorf("clusterFailures() = %v, want a full failure", err)
+	}
+}
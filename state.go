@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/pflag"
+)
+
+// stateFileName is the conversion state file written into the output directory
+const stateFileName = ".ecs2k8s-state.json"
+
+// volatileTaskDefFields are task definition fields that change on every
+// registration without changing runtime behavior
+var volatileTaskDefFields = map[string]bool{
+	"RegisteredAt":      true,
+	"RegisteredBy":      true,
+	"DeregisteredAt":    true,
+	"Revision":          true,
+	"TaskDefinitionArn": true,
+	"Status":            true,
+}
+
+// ConversionState records what a conversion run was generated from
+type ConversionState struct {
+	Cluster     string `json:"cluster"`
+	ClusterARN  string `json:"clusterArn,omitempty"`
+	Region      string `json:"region"`
+	GeneratedAt string `json:"generatedAt"`
+	// Flags are the flags of the run shaping the manifests of each service,
+	// replayed by watch when it regenerates a service
+	Flags []string `json:"flags,omitempty"`
+	// Split lists the task definitions converted with --split-containers, with
+	// their sidecar containers, so their services are read split the same way
+	Split    map[string][]string     `json:"split,omitempty"`
+	Services map[string]ServiceState `json:"services"`
+}
+
+// ServiceState records the source fields of a converted task definition
+type ServiceState struct {
+	TaskDefinitionArn string            `json:"taskDefinitionArn"`
+	Fields            map[string]string `json:"fields"`
+}
+
+// newConversionState builds the state for a set of converted task definitions
+func newConversionState(region, clusterName string, taskDefInfos []*TaskDefInfo) (*ConversionState, error) {
+	state := &ConversionState{
+		Cluster:     clusterName,
+		Region:      region,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Services:    map[string]ServiceState{},
+	}
+
+	for _, taskDefInfo := range taskDefInfos {
+		svcState, err := snapshotServiceState(taskDefInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", taskDefInfo.Name, err)
+		}
+		state.Services[taskDefInfo.Name] = svcState
+	}
+
+	return state, nil
+}
+
+// snapshotServiceState flattens the task definition and service fields of a TaskDefInfo
+func snapshotServiceState(taskDefInfo *TaskDefInfo) (ServiceState, error) {
+	svcState := ServiceState{Fields: map[string]string{}}
+
+	if taskDefInfo.Source != nil {
+		svcState.TaskDefinitionArn = aws.ToString(taskDefInfo.Source.TaskDefinitionArn)
+
+		fields, err := flattenObject(taskDefInfo.Source)
+		if err != nil {
+			return svcState, err
+		}
+		for key, value := range fields {
+			if volatileTaskDefFields[strings.SplitN(key, ".", 2)[0]] {
+				continue
+			}
+			svcState.Fields["taskDefinition."+key] = value
+		}
+	}
+
+	for _, svc := range taskDefInfo.Services {
+		fields, err := flattenObject(serviceSnapshot(svc))
+		if err != nil {
+			return svcState, err
+		}
+		prefix := fmt.Sprintf("service[%s].", aws.ToString(svc.ServiceName))
+		for key, value := range fields {
+			svcState.Fields[prefix+key] = value
+		}
+	}
+
+	return svcState, nil
+}
+
+// serviceSnapshot keeps the ECS service settings that affect the generated output
+func serviceSnapshot(svc types.Service) map[string]interface{} {
+	return map[string]interface{}{
+		"DesiredCount":             svc.DesiredCount,
+		"LaunchType":               svc.LaunchType,
+		"LoadBalancers":            svc.LoadBalancers,
+		"NetworkConfiguration":     svc.NetworkConfiguration,
+		"CapacityProviderStrategy": svc.CapacityProviderStrategy,
+		"DeploymentConfiguration":  svc.DeploymentConfiguration,
+		"PlacementConstraints":     svc.PlacementConstraints,
+		"PlacementStrategy":        svc.PlacementStrategy,
+		"ServiceRegistries":        svc.ServiceRegistries,
+	}
+}
+
+// flattenObject converts a value into dotted-path keys with string values
+func flattenObject(v interface{}) (map[string]string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal object: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal object: %w", err)
+	}
+
+	out := map[string]string{}
+	flattenValue("", generic, out)
+	return out, nil
+}
+
+// flattenValue recursively flattens a decoded JSON value, dropping empty values
+func flattenValue(prefix string, v interface{}, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenValue(path, child, out)
+		}
+	case []interface{}:
+		for i, child := range val {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	case nil:
+		return
+	case string:
+		if val != "" {
+			out[prefix] = val
+		}
+	default:
+		out[prefix] = fmt.Sprintf("%v", val)
+	}
+}
+
+// writeConversionState writes the state file into the output directory
+func writeConversionState(outputDir string, state *ConversionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	statePath := filepath.Join(outputDir, stateFileName)
+	if err := os.WriteFile(statePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", statePath, err)
+	}
+
+	return nil
+}
+
+// readConversionState loads the state file from an output directory
+func readConversionState(outputDir string) (*ConversionState, error) {
+	statePath := filepath.Join(outputDir, stateFileName)
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", statePath, err)
+	}
+
+	var state ConversionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", statePath, err)
+	}
+
+	if state.Services == nil {
+		state.Services = map[string]ServiceState{}
+	}
+
+	return &state, nil
+}
+
+// splitServices splits the task definitions the conversion split with
+// --split-containers, so their services match the recorded state
+func (s *ConversionState) splitServices(taskDefInfos []*TaskDefInfo) []*TaskDefInfo {
+	if len(s.Split) == 0 {
+		return taskDefInfos
+	}
+	var split []*TaskDefInfo
+	for _, taskDefInfo := range taskDefInfos {
+		sidecars, ok := s.Split[taskDefInfo.Name]
+		if !ok {
+			split = append(split, taskDefInfo)
+			continue
+		}
+		services, err := splitContainers(taskDefInfo, sidecars)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			continue
+		}
+		split = append(split, services...)
+	}
+	return split
+}
+
+// replayedFlags are the flags of a conversion shaping the manifests of each
+// service. They are recorded in the state, so watch and the /events endpoint
+// regenerate a service the way the conversion did.
+var replayedFlags = map[string]bool{
+	"overrides-dir": true, "split-containers": true, "profile": true, "identity-map": true, "output": true,
+	"create-keda": true, "target-group-bindings": true, "cutover-weight": true, "rollouts": true, "zero-trust": true,
+	"image-pull-policy": true, "probe-source": true, "aws-env": true,
+	"label": true, "annotation": true, "include-kinds": true, "exclude-kinds": true, "api-version": true,
+	"resolve-secrets": true, "secrets-mode": true, "vault-addr": true, "vault-mount": true, "vault-path": true, "vault-role": true, "vault-injection": true,
+	"alerts": true, "convert-alarms": true, "headless-workers": true, "smart-templates": true, "cert-manager-issuer": true, "conversion-records": true,
+	"config": true, "anti-affinity": true, "rightsize": true, "rightsize-days": true, "rightsize-percentile": true, "min-cpu": true, "min-memory": true,
+}
+
+// replayedPathFlags are the replayed flags naming files, recorded as absolute
+// paths since watch may run from another directory
+var replayedPathFlags = map[string]bool{"overrides-dir": true, "identity-map": true, "config": true}
+
+// recordFlags returns the replayed flags set on the command line as arguments
+func recordFlags(flags *pflag.FlagSet) ([]string, error) {
+	var args []string
+	var err error
+	flags.Visit(func(f *pflag.Flag) {
+		if !replayedFlags[f.Name] || err != nil {
+			return
+		}
+		values := []string{f.Value.String()}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			if replayedPathFlags[f.Name] && value != "" {
+				if value, err = filepath.Abs(value); err != nil {
+					err = fmt.Errorf("failed to resolve --%s: %w", f.Name, err)
+					return
+				}
+			}
+			args = append(args, "--"+f.Name+"="+value)
+		}
+	})
+	return args, err
+}
+
+// replayRunOptions returns the options of the conversion that wrote a state,
+// from the flags it recorded, for the services of a cluster in region
+func replayRunOptions(state *ConversionState, region string) (*runOptions, error) {
+	flags := pflag.NewFlagSet("state", pflag.ContinueOnError)
+	addConversionFlags(flags)
+	if err := flags.Parse(append(append([]string{}, state.Flags...), "--region="+region)); err != nil {
+		return nil, fmt.Errorf("invalid flags recorded in %s: %w", stateFileName, err)
+	}
+	opts, err := parseRunOptions(flags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flags recorded in %s: %w", stateFileName, err)
+	}
+	return opts, nil
+}
+
+// sortedKeys returns the keys of a string map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/spf13/pflag"
+)
+
+// TestSnapshotServiceState tests that volatile task definition fields are
+// dropped and service fields are prefixed with the service name
+func TestSnapshotServiceState(t *testing.T) {
+	taskDefArn := "arn:aws:ecs:us-east-1:123456789012:task-definition/web:7"
+	taskDefInfo := &TaskDefInfo{
+		Name: "web",
+		Source: &types.TaskDefinition{
+			TaskDefinitionArn: aws.String(taskDefArn),
+			Family:            aws.String("web"),
+			Revision:          7,
+			RegisteredAt:      aws.Time(time.Now()),
+			Cpu:               aws.String("256"),
+			ContainerDefinitions: []types.ContainerDefinition{
+				{Name: aws.String("app"), Image: aws.String("nginx:1.25")},
+			},
+		},
+		Services: []types.Service{
+			{ServiceName: aws.String("web-svc"), DesiredCount: 3, LaunchType: types.LaunchTypeFargate},
+		},
+	}
+
+	state, err := snapshotServiceState(taskDefInfo)
+	if err != nil {
+		t.Fatalf("snapshotServiceState() error = %v", err)
+	}
+	if state.TaskDefinitionArn != taskDefArn {
+		t.Errorf("TaskDefinitionArn = %q, want %q", state.TaskDefinitionArn, taskDefArn)
+	}
+
+	want := map[string]string{
+		"taskDefinition.Family":                        "web",
+		"taskDefinition.Cpu":                           "256",
+		"taskDefinition.ContainerDefinitions[0].Name":  "app",
+		"taskDefinition.ContainerDefinitions[0].Image": "nginx:1.25",
+		"service[web-svc].DesiredCount":                "3",
+		"service[web-svc].LaunchType":                  "FARGATE",
+	}
+	for key, value := range want {
+		if got := state.Fields[key]; got != value {
+			t.Errorf("Fields[%s] = %q, want %q", key, got, value)
+		}
+	}
+	for _, key := range []string{"taskDefinition.Revision", "taskDefinition.RegisteredAt", "taskDefinition.TaskDefinitionArn", "DesiredCount"} {
+		if value, ok := state.Fields[key]; ok {
+			t.Errorf("Fields[%s] = %q, want it dropped", key, value)
+		}
+	}
+}
+
+// TestFlattenObject tests the dotted paths of nested values
+func TestFlattenObject(t *testing.T) {
+	got, err := flattenObject(map[string]interface{}{
+		"name":  "web",
+		"empty": "",
+		"ports": []interface{}{map[string]interface{}{"port": 80}},
+		"unset": nil,
+	})
+	if err != nil {
+		t.Fatalf("flattenObject() error = %v", err)
+	}
+	want := map[string]string{"name": "web", "ports[0].port": "80"}
+	if len(got) != len(want) {
+		t.Errorf("flattenObject() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("flattenObject()[%s] = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+// TestRecordFlags tests that only the flags shaping the manifests are
+// recorded, with absolute paths and one argument per repeated value, and that
+// they replay into the same options
+func TestRecordFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addConversionFlags(flags)
+	args := []string{
+		"--region=us-east-1", "--cluster=prod", "--create-helm", "--overrides-dir=patches",
+		"--label=team=payments", "--label=Deployment:tier=web", "--exclude-kinds=Secret,ConfigMap", "--aws-env=false",
+	}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, err := recordFlags(flags)
+	if err != nil {
+		t.Fatalf("recordFlags() error = %v", err)
+	}
+	patches, _ := filepath.Abs("patches")
+	want := []string{
+		"--aws-env=false", "--exclude-kinds=Secret", "--exclude-kinds=ConfigMap",
+		"--label=team=payments", "--label=Deployment:tier=web", "--overrides-dir=" + patches,
+	}
+	if strings.Join(recorded, " ") != strings.Join(want, " ") {
+		t.Errorf("recordFlags() = %v, want %v", recorded, want)
+	}
+
+	opts, err := replayRunOptions(&ConversionState{Flags: recorded}, "eu-west-1")
+	if err != nil {
+		t.Fatalf("replayRunOptions() error = %v", err)
+	}
+	if opts.region != "eu-west-1" || opts.awsEnv || opts.overridesDir != patches || opts.createHelm || strings.Join(opts.flags, " ") != strings.Join(want, " ") {
+		t.Errorf("replayRunOptions() = region %q, awsEnv %v, overridesDir %q, createHelm %v, flags %v", opts.region, opts.awsEnv, opts.overridesDir, opts.createHelm, opts.flags)
+	}
+
+	if _, err := replayRunOptions(&ConversionState{Flags: []string{"--output=lambda"}}, "us-east-1"); err == nil {
+		t.Errorf("replayRunOptions() with an invalid recorded --output: error = nil")
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// jobsDirName is the output subdirectory for the Jobs of task definitions run
+// by Step Functions state machines
+const jobsDirName = "jobs"
+
+// stepFunctionsFileName is the document mapping the runTask states of the
+// state machines to the generated Jobs
+const stepFunctionsFileName = "step-functions.yaml"
+
+// runTaskResource is the Step Functions service integration running an ECS
+// task, optionally followed by .sync or .waitForTaskToken
+const runTaskResource = ":states:::ecs:runTask"
+
+// StepFunctionsMapping is the document mapping the state machines running task
+// definitions in a cluster to their Jobs
+type StepFunctionsMapping struct {
+	Cluster string              `yaml:"cluster"`
+	Region  string              `yaml:"region"`
+	Tasks   []StepFunctionsTask `yaml:"tasks"`
+}
+
+// StepFunctionsTask is a runTask state of a state machine
+type StepFunctionsTask struct {
+	StateMachine    string `yaml:"stateMachine"`
+	StateMachineArn string `yaml:"stateMachineArn"`
+	State           string `yaml:"state"`
+	// Integration is the resource of the state: ecs:runTask, ecs:runTask.sync
+	// or ecs:runTask.waitForTaskToken
+	Integration    string `yaml:"integration"`
+	TaskDefinition string `yaml:"taskDefinition"`
+	// Job is the generated Job; empty when the task definition is only known
+	// at run time
+	Job   string   `yaml:"job,omitempty"`
+	Notes []string `yaml:"notes,omitempty"`
+}
+
+// runTaskState is a runTask state found in a state machine definition
+type runTaskState struct {
+	name        string
+	integration string
+	// cluster is "default" when the state names none, as ECS does; empty when
+	// it is resolved from the input at run time
+	cluster string
+	// taskDefinition is empty when it is resolved at run time
+	taskDefinition string
+	overrides      []runTaskOverride
+	notes          []string
+}
+
+// runTaskOverride is a static container override of a runTask state
+type runTaskOverride struct {
+	name        string
+	command     []string
+	environment map[string]string
+}
+
+// runTaskStates returns the runTask states of an Amazon States Language
+// definition, including those nested in Parallel and Map states, sorted by name
+func runTaskStates(definition string) ([]runTaskState, error) {
+	var machine map[string]interface{}
+	if err := json.Unmarshal([]byte(definition), &machine); err != nil {
+		return nil, fmt.Errorf("failed to parse state machine definition: %w", err)
+	}
+
+	var found []runTaskState
+	var walk func(states map[string]interface{})
+	walk = func(states map[string]interface{}) {
+		for name, value := range states {
+			state, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, branch := range asSlice(state["Branches"]) {
+				if b, ok := branch.(map[string]interface{}); ok {
+					walk(asMap(b["States"]))
+				}
+			}
+			for _, key := range []string{"Iterator", "ItemProcessor"} {
+				walk(asMap(asMap(state[key])["States"]))
+			}
+
+			resource, _ := state["Resource"].(string)
+			if state["Type"] != "Task" || !strings.Contains(resource, runTaskResource) {
+				continue
+			}
+			found = append(found, parseRunTaskState(name, resource, state))
+		}
+	}
+	walk(asMap(machine["States"]))
+
+	sort.Slice(found, func(i, j int) bool { return found[i].name < found[j].name })
+	return found, nil
+}
+
+// parseRunTaskState reads the cluster, task definition and container overrides
+// of a runTask state. JSONPath (Parameters) and JSONata (Arguments) values
+// resolved from the state input are reported instead.
+func parseRunTaskState(name, resource string, state map[string]interface{}) runTaskState {
+	rt := runTaskState{name: name, integration: "ecs:runTask"}
+	if i := strings.Index(resource, runTaskResource); i >= 0 {
+		rt.integration = "ecs:runTask" + resource[i+len(runTaskResource):]
+	}
+
+	params := asMap(state["Parameters"])
+	if params == nil {
+		params = asMap(state["Arguments"])
+	}
+
+	rt.cluster = "default"
+	if cluster, ok := staticString(params, "Cluster"); ok {
+		rt.cluster = cluster
+	} else if isDynamic(params, "Cluster") {
+		rt.cluster = ""
+		rt.notes = append(rt.notes, "The cluster is resolved from the state input at run time")
+	}
+	if taskDef, ok := staticString(params, "TaskDefinition"); ok {
+		rt.taskDefinition = taskDef
+	} else {
+		rt.notes = append(rt.notes, "The task definition is resolved from the state input at run time; no Job is generated")
+	}
+
+	overrides := asMap(params["Overrides"])
+	if isDynamic(params, "Overrides") || isDynamic(overrides, "ContainerOverrides") {
+		rt.notes = append(rt.notes, "Container overrides are resolved from the state input at run time and are not applied")
+	}
+	for _, item := range asSlice(overrides["ContainerOverrides"]) {
+		o := asMap(item)
+		containerName, ok := staticString(o, "Name")
+		if !ok {
+			continue
+		}
+		override := runTaskOverride{name: containerName, environment: map[string]string{}}
+		for _, arg := range asSlice(o["Command"]) {
+			if s, ok := arg.(string); ok {
+				override.command = append(override.command, s)
+			}
+		}
+		for _, env := range asSlice(o["Environment"]) {
+			e := asMap(env)
+			envName, nameOK := staticString(e, "Name")
+			value, valueOK := staticString(e, "Value")
+			if nameOK && valueOK {
+				override.environment[envName] = value
+			} else if nameOK {
+				rt.notes = append(rt.notes, fmt.Sprintf("Environment variable %s of container %s is resolved at run time and is not set", envName, containerName))
+			}
+		}
+		for key := range o {
+			if isDynamicKey(key) {
+				rt.notes = append(rt.notes, fmt.Sprintf("Override %s of container %s is resolved at run time and is not applied", key, containerName))
+			}
+		}
+		rt.overrides = append(rt.overrides, override)
+	}
+	for _, key := range []string{"LaunchType", "CapacityProviderStrategy", "PlatformVersion", "NetworkConfiguration"} {
+		if _, ok := params[key]; ok {
+			rt.notes = append(rt.notes, fmt.Sprintf("%s of the state is not converted; the Job runs on the nodes of the Kubernetes cluster", key))
+		}
+	}
+	if _, ok := state["Retry"]; ok {
+		rt.notes = append(rt.notes, "The Retry policy of the state is kept in the state machine; the Job has no retries (backoffLimit 0)")
+	}
+	return rt
+}
+
+// staticString returns a string parameter set in the definition itself
+func staticString(params map[string]interface{}, key string) (string, bool) {
+	s, ok := params[key].(string)
+	if !ok || isJSONata(s) {
+		return "", false
+	}
+	return s, true
+}
+
+// isDynamic reports whether a parameter is resolved from the state input, by
+// a JSONPath key (Key.$) or a JSONata expression
+func isDynamic(params map[string]interface{}, key string) bool {
+	if _, ok := params[key+".$"]; ok {
+		return true
+	}
+	s, ok := params[key].(string)
+	return ok && isJSONata(s)
+}
+
+// isDynamicKey reports whether a parameter key is a JSONPath key
+func isDynamicKey(key string) bool {
+	return strings.HasSuffix(key, ".$")
+}
+
+// isJSONata reports whether a value is a JSONata expression
+func isJSONata(s string) bool {
+	return strings.HasPrefix(s, "{%") && strings.HasSuffix(s, "%}")
+}
+
+// asMap returns a JSON object, or nil
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// asSlice returns a JSON array, or nil
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+// runsOnCluster reports whether a runTask state targets a cluster, given by
+// name or ARN
+func (rt runTaskState) runsOnCluster(cluster string) bool {
+	return rt.cluster != "" && extractClusterName(rt.cluster) == extractClusterName(cluster)
+}
+
+// stepFunctionsJob converts the task definition run by a runTask state into a
+// suspended Job, with the static container overrides of the state applied
+func stepFunctionsJob(stateMachine string, rt runTaskState, taskDef *types.TaskDefinition) (string, map[string]interface{}, []string, error) {
+	family := aws.ToString(taskDef.Family)
+	taskDefInfo, err := buildTaskDefInfo(taskDef, family)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if taskDefInfo.Manifests.Deployment == nil {
+		return "", nil, nil, fmt.Errorf("task definition %s has no containers", family)
+	}
+	podSpec := taskPodSpec(taskDefInfo)
+	notes := append([]string{}, rt.notes...)
+
+	overrides := map[string]runTaskOverride{}
+	for _, o := range rt.overrides {
+		overrides[sanitizeName(o.name)] = o
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		o, ok := overrides[c.Name]
+		if !ok {
+			continue
+		}
+		delete(overrides, c.Name)
+		if len(o.command) > 0 {
+			c.Args = o.command
+		}
+		for _, name := range sortedKeys(o.environment) {
+			c.Env = setEnvVar(c.Env, name, o.environment[name])
+		}
+	}
+	var unknown []string
+	for name := range overrides {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		notes = append(notes, fmt.Sprintf("The state overrides container %s, which is not in task definition %s", name, family))
+	}
+
+	name := sanitizeName(stateMachine + "-" + rt.name)
+	job := renderTaskJob(name, sanitizeName(family), map[string]string{
+		"ecs2k8s.io/state-machine":   stateMachine,
+		"ecs2k8s.io/state":           rt.name,
+		"ecs2k8s.io/task-definition": aws.ToString(taskDef.TaskDefinitionArn),
+	}, podSpec)
+	return name, job, notes, nil
+}
+
+// taskPodSpec returns the pod spec of a task definition run to completion.
+// ECS runs the entryPoint and command of the containers, which the
+// Deployment leaves to the image.
+func taskPodSpec(taskDefInfo *TaskDefInfo) *corev1.PodSpec {
+	podSpec := taskDefInfo.Manifests.Deployment.DeepCopy()
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		for _, def := range taskDefInfo.Source.ContainerDefinitions {
+			if sanitizeName(aws.ToString(def.Name)) == c.Name {
+				c.Command, c.Args = def.EntryPoint, def.Command
+			}
+		}
+	}
+	return podSpec
+}
+
+// renderTaskJob serializes a suspended Job of a task, so applying it runs
+// nothing: whatever replaces the orchestrator of the task starts copies of it
+func renderTaskJob(name, app string, annotations map[string]string, podSpec *corev1.PodSpec) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": apiVersionBatch,
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   "default",
+			"labels":      map[string]string{"app": app},
+			"annotations": annotations,
+		},
+		"spec": map[string]interface{}{
+			"suspend": true,
+			// Retries are left to the orchestrator
+			"backoffLimit": 0,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]string{"app": name},
+				},
+				"spec": serializePodSpec(podSpec),
+			},
+		},
+	}
+}
+
+// setEnvVar sets a variable, replacing its value when it is already defined
+func setEnvVar(env []corev1.EnvVar, name, value string) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			env[i] = corev1.EnvVar{Name: name, Value: value}
+			return env
+		}
+	}
+	return append(env, corev1.EnvVar{Name: name, Value: value})
+}
+
+// stepFunctionsDiscoverer finds the state machines running the task
+// definitions of a cluster and converts them into Jobs
+type stepFunctionsDiscoverer struct {
+	states *stepFunctionsClient
+	// describe returns a task definition by family, family:revision or ARN
+	describe func(ctx context.Context, taskDef string) (*types.TaskDefinition, error)
+}
+
+// newStepFunctionsDiscoverer creates the discoverer of a region
+func newStepFunctionsDiscoverer(cfg aws.Config) *stepFunctionsDiscoverer {
+	ecsClient := ecs.NewFromConfig(cfg)
+	return &stepFunctionsDiscoverer{
+		states: newStepFunctionsClient(cfg),
+		describe: func(ctx context.Context, taskDef string) (*types.TaskDefinition, error) {
+			out, err := ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(taskDef)})
+			if err != nil {
+				return nil, err
+			}
+			return out.TaskDefinition, nil
+		},
+	}
+}
+
+// discover returns the runTask states of the state machines targeting a
+// cluster and the Jobs of their task definitions, keyed by file name
+func (d *stepFunctionsDiscoverer) discover(ctx context.Context, cluster string) ([]StepFunctionsTask, map[string]interface{}, error) {
+	machines, err := d.states.listStateMachines(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tasks []StepFunctionsTask
+	docs := map[string]interface{}{}
+	taskDefs := map[string]*types.TaskDefinition{}
+	for _, machine := range machines {
+		definition, err := d.states.describeStateMachine(ctx, machine.StateMachineArn)
+		if err != nil {
+			return nil, nil, err
+		}
+		states, err := runTaskStates(definition)
+		if err != nil {
+			log.Printf("Warning: Skipping state machine %s: %v", machine.Name, err)
+			continue
+		}
+
+		for _, rt := range states {
+			if rt.cluster != "" && !rt.runsOnCluster(cluster) {
+				continue
+			}
+			task := StepFunctionsTask{
+				StateMachine:    machine.Name,
+				StateMachineArn: machine.StateMachineArn,
+				State:           rt.name,
+				Integration:     rt.integration,
+				TaskDefinition:  rt.taskDefinition,
+				Notes:           rt.notes,
+			}
+			if rt.taskDefinition == "" || rt.cluster == "" {
+				tasks = append(tasks, task)
+				continue
+			}
+
+			taskDef, ok := taskDefs[rt.taskDefinition]
+			if !ok {
+				if taskDef, err = d.describe(ctx, rt.taskDefinition); err != nil {
+					log.Printf("Warning: Failed to describe task definition %s run by %s/%s: %v", rt.taskDefinition, machine.Name, rt.name, err)
+					task.Notes = append(task.Notes, fmt.Sprintf("Task definition could not be described: %v", err))
+					tasks = append(tasks, task)
+					continue
+				}
+				taskDefs[rt.taskDefinition] = taskDef
+			}
+
+			name, job, notes, err := stepFunctionsJob(machine.Name, rt, taskDef)
+			if err != nil {
+				log.Printf("Warning: Failed to convert task definition %s run by %s/%s: %v", rt.taskDefinition, machine.Name, rt.name, err)
+				task.Notes = append(task.Notes, fmt.Sprintf("Task definition could not be converted: %v", err))
+				tasks = append(tasks, task)
+				continue
+			}
+			task.Job, task.Notes = name, notes
+			docs[name+"-job.yaml"] = job
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, docs, nil
+}
+
+// writeStepFunctionsJobs writes the Jobs and the mapping document into
+// <output>/jobs
+func writeStepFunctionsJobs(outputDir string, mapping *StepFunctionsMapping, docs map[string]interface{}) error {
+	if err := writeJobManifests(outputDir, docs); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(outputDir, jobsDirName, stepFunctionsFileName)
+	if err := writeYAMLFile(filePath, mapping); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+	log.Printf("Wrote: %s", filePath)
+	return nil
+}
+
+// writeJobManifests writes the Jobs of tasks run outside ECS services into
+// <output>/jobs
+func writeJobManifests(outputDir string, docs map[string]interface{}) error {
+	jobsDir := filepath.Join(outputDir, jobsDirName)
+	if err := os.MkdirAll(jobsDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create jobs directory %s: %w", jobsDir, err)
+	}
+
+	for _, filename := range sortedDocKeys(docs) {
+		filePath := filepath.Join(jobsDir, filename)
+		if err := writeYAMLFile(filePath, docs[filename]); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+		}
+		log.Printf("Wrote: %s", filePath)
+	}
+	return nil
+}
+
+// stateMachineListItem is a state machine returned by ListStateMachines
+type stateMachineListItem struct {
+	StateMachineArn string `json:"stateMachineArn"`
+	Name            string `json:"name"`
+}
+
+// stepFunctionsClient calls the read-only Step Functions actions the
+// discovery needs over the AWS JSON 1.0 protocol, signed with SigV4
+type stepFunctionsClient struct {
+	cfg      aws.Config
+	endpoint string
+}
+
+// newStepFunctionsClient creates the client of the region of cfg, using the
+// FIPS or dual-stack endpoint when selected
+func newStepFunctionsClient(cfg aws.Config) *stepFunctionsClient {
+	suffix := "amazonaws.com"
+	if strings.HasPrefix(cfg.Region, "cn-") {
+		suffix = "amazonaws.com.cn"
+	}
+	host := fmt.Sprintf("states.%s.%s", cfg.Region, suffix)
+	switch {
+	case awsEndpoints.fips:
+		host = fmt.Sprintf("states-fips.%s.%s", cfg.Region, suffix)
+	case awsEndpoints.dualStack:
+		host = fmt.Sprintf("states.%s.api.aws", cfg.Region)
+	}
+	return &stepFunctionsClient{cfg: cfg, endpoint: "https://" + host}
+}
+
+// listStateMachines returns the state machines of the region
+func (c *stepFunctionsClient) listStateMachines(ctx context.Context) ([]stateMachineListItem, error) {
+	var machines []stateMachineListItem
+	input := map[string]interface{}{"maxResults": 1000}
+	for {
+		var out struct {
+			StateMachines []stateMachineListItem `json:"stateMachines"`
+			NextToken     string                 `json:"nextToken"`
+		}
+		if err := c.call(ctx, "ListStateMachines", input, &out); err != nil {
+			return nil, fmt.Errorf("failed to list state machines: %w", err)
+		}
+		machines = append(machines, out.StateMachines...)
+		if out.NextToken == "" {
+			return machines, nil
+		}
+		input["nextToken"] = out.NextToken
+	}
+}
+
+// describeStateMachine returns the definition of a state machine
+func (c *stepFunctionsClient) describeStateMachine(ctx context.Context, arn string) (string, error) {
+	var out struct {
+		Definition string `json:"definition"`
+	}
+	if err := c.call(ctx, "DescribeStateMachine", map[string]string{"stateMachineArn": arn}, &out); err != nil {
+		return "", fmt.Errorf("failed to describe state machine %s: %w", arn, err)
+	}
+	return out.Definition, nil
+}
+
+// call invokes a Step Functions action. Error responses are returned as
+// smithy API errors, like the errors of the SDK clients.
+func (c *stepFunctionsClient) call(ctx context.Context, action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AWSStepFunctions."+action)
+
+	if c.cfg.Credentials == nil {
+		return fmt.Errorf("no AWS credentials configured")
+	}
+	creds, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "states", c.cfg.Region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	var client interface {
+		Do(*http.Request) (*http.Response, error)
+	} = http.DefaultClient
+	if c.cfg.HTTPClient != nil {
+		client = c.cfg.HTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		code := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+		if code == "" {
+			code = resp.Status
+		}
+		return &smithy.GenericAPIError{Code: code, Message: apiErr.Message}
+	}
+	return json.Unmarshal(data, output)
+}
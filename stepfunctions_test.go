@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+)
+
+// nightlyDefinition runs the report task definition on the prod cluster, in
+// a Parallel branch the export task definition resolved from the input, and
+// a task on another cluster
+const nightlyDefinition = `{
+  "StartAt": "Fan out",
+  "States": {
+    "Fan out": {
+      "Type": "Parallel",
+      "Branches": [{
+        "StartAt": "Report",
+        "States": {
+          "Report": {
+            "Type": "Task",
+            "Resource": "arn:aws:states:::ecs:runTask.sync",
+            "Parameters": {
+              "Cluster": "arn:aws:ecs:us-east-1:123456789012:cluster/prod",
+              "TaskDefinition": "report:3",
+              "LaunchType": "FARGATE",
+              "Overrides": {
+                "ContainerOverrides": [{
+                  "Name": "report",
+                  "Command": ["--date", "yesterday"],
+                  "Environment": [
+                    {"Name": "MODE", "Value": "nightly"},
+                    {"Name": "RUN_ID", "Value.$": "$$.Execution.Name"}
+                  ]
+                }]
+              }
+            },
+            "Retry": [{"ErrorEquals": ["States.ALL"], "MaxAttempts": 2}],
+            "End": true
+          }
+        }
+      }, {
+        "StartAt": "Export",
+        "States": {
+          "Export": {
+            "Type": "Task",
+            "Resource": "arn:aws:states:::ecs:runTask",
+            "Parameters": {"Cluster": "prod", "TaskDefinition.$": "$.taskDefinition"},
+            "End": true
+          }
+        }
+      }],
+      "Next": "Elsewhere"
+    },
+    "Elsewhere": {
+      "Type": "Task",
+      "Resource": "arn:aws:states:::ecs:runTask",
+      "Parameters": {"Cluster": "staging", "TaskDefinition": "report"},
+      "End": true
+    }
+  }
+}`
+
+// TestRunTaskStates tests finding the runTask states of a state machine
+func TestRunTaskStates(t *testing.T) {
+	states, err := runTaskStates(nightlyDefinition)
+	if err != nil {
+		t.Fatalf("runTaskStates() error = %v", err)
+	}
+	if len(states) != 3 || states[0].name != "Elsewhere" || states[1].name != "Export" || states[2].name != "Report" {
+		t.Fatalf("states = %+v, want Elsewhere, Export and Report", states)
+	}
+
+	report := states[2]
+	if report.integration != "ecs:runTask.sync" || report.taskDefinition != "report:3" || !report.runsOnCluster("prod") {
+		t.Errorf("Report = %+v, want report:3 run on prod with .sync", report)
+	}
+	if len(report.overrides) != 1 || !reflect.DeepEqual(report.overrides[0].command, []string{"--date", "yesterday"}) || !reflect.DeepEqual(report.overrides[0].environment, map[string]string{"MODE": "nightly"}) {
+		t.Errorf("Report overrides = %+v, want the static command and MODE", report.overrides)
+	}
+	if notes := strings.Join(report.notes, "\n"); !strings.Contains(notes, "RUN_ID") || !strings.Contains(notes, "LaunchType") || !strings.Contains(notes, "Retry") {
+		t.Errorf("Report notes = %v, want RUN_ID, LaunchType and Retry", report.notes)
+	}
+
+	if export := states[1]; export.taskDefinition != "" || !export.runsOnCluster("prod") {
+		t.Errorf("Export = %+v, want a task definition resolved at run time", export)
+	}
+	if states[0].runsOnCluster("prod") {
+		t.Errorf("Elsewhere runs on %q, want staging", states[0].cluster)
+	}
+
+	if _, err := runTaskStates("{"); err == nil {
+		t.Error("runTaskStates() error = nil, want a parse error")
+	}
+}
+
+// TestStepFunctionsDiscover tests converting the runTask states of the state
+// machines of a region into Jobs through the Step Functions API
+func TestStepFunctionsDiscover(t *testing.T) {
+	const machineArn = "arn:aws:states:us-east-1:123456789012:stateMachine:nightly"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("request is not signed: %v", r.Header)
+		}
+		var input map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&input)
+		switch r.Header.Get("X-Amz-Target") {
+		case "AWSStepFunctions.ListStateMachines":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"stateMachines": []map[string]string{{"stateMachineArn": machineArn, "name": "nightly"}},
+			})
+		case "AWSStepFunctions.DescribeStateMachine":
+			if input["stateMachineArn"] != machineArn {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"com.amazonaws.swf.service.v2.model#StateMachineDoesNotExist","message":"not found"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"definition": nightlyDefinition})
+		default:
+			t.Errorf("unexpected action %s", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKID", "secret", "")}
+	states := newStepFunctionsClient(cfg)
+	states.endpoint = server.URL
+	d := &stepFunctionsDiscoverer{
+		states: states,
+		describe: func(ctx context.Context, taskDef string) (*types.TaskDefinition, error) {
+			return &types.TaskDefinition{
+				TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/report:3"),
+				Family:            aws.String("report"),
+				ContainerDefinitions: []types.ContainerDefinition{{
+					Name:        aws.String("report"),
+					Image:       aws.String("report:1"),
+					EntryPoint:  []string{"/bin/report"},
+					Command:     []string{"--date", "today"},
+					Environment: []types.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("manual")}},
+				}},
+			}, nil
+		},
+	}
+
+	tasks, docs, err := d.discover(context.Background(), "prod")
+	if err != nil {
+		t.Fatalf("discover() error = %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].State != "Export" || tasks[0].Job != "" || tasks[1].State != "Report" || tasks[1].Job != "nightly-report" {
+		t.Fatalf("tasks = %+v, want Export without a Job and Report as nightly-report", tasks)
+	}
+
+	job, ok := docs["nightly-report-job.yaml"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("docs = %v, want nightly-report-job.yaml", sortedDocKeys(docs))
+	}
+	spec := job["spec"].(map[string]interface{})
+	if spec["suspend"] != true {
+		t.Errorf("suspend = %v, want true", spec["suspend"])
+	}
+	podSpec := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	data, _ := json.Marshal(podSpec)
+	for _, want := range []string{`"restartPolicy":"Never"`, `"command":["/bin/report"]`, `"args":["--date","yesterday"]`, `"value":"nightly"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("pod spec = %s, want %s", data, want)
+		}
+	}
+
+	_, err = states.describeStateMachine(context.Background(), "arn:aws:states:us-east-1:123456789012:stateMachine:missing")
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "StateMachineDoesNotExist" {
+		t.Errorf("describeStateMachine() error = %v, want StateMachineDoesNotExist", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// taskDefCacheDirName is the directory under the user cache directory holding
+// described task definitions
+const taskDefCacheDirName = "ecs2k8s/task-definitions"
+
+// revisionQualified matches task definition references that pin a revision
+var revisionQualified = regexp.MustCompile(`^(arn:[^:]+:ecs:[^:]+:\d+:task-definition/)?[A-Za-z0-9_-]+:\d+$`)
+
+// taskDefinitionCache keeps described task definitions so a revision is only
+// fetched once. Registered revisions are immutable, so definitions referenced
+// by a revision-qualified ARN are also kept on disk and reused by later runs;
+// only their status and tags can go stale once a revision is deregistered or
+// retagged.
+type taskDefinitionCache struct {
+	mu sync.Mutex
+	// dir holds one JSON file per revision; empty keeps definitions in memory only
+	dir     string
+	entries map[string]*cachedTaskDefinition
+}
+
+// cachedTaskDefinition is the on-disk form of a cached revision
+type cachedTaskDefinition struct {
+	TaskDefinitionArn string                `json:"taskDefinitionArn"`
+	TaskDefinition    *types.TaskDefinition `json:"taskDefinition"`
+	// Tags is empty, not null, for untagged revisions; entries without it
+	// were cached before tags were described and are fetched again
+	Tags []types.Tag `json:"tags"`
+}
+
+// taskDefinitions is the cache shared by every conversion of the process
+var taskDefinitions = newTaskDefinitionCache("")
+
+// newTaskDefinitionCache creates a cache persisting revisions in dir
+func newTaskDefinitionCache(dir string) *taskDefinitionCache {
+	return &taskDefinitionCache{dir: dir, entries: map[string]*cachedTaskDefinition{}}
+}
+
+// defaultTaskDefCacheDir returns the cache directory used unless --cache-dir is set
+func defaultTaskDefCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		log.Printf("Warning: No user cache directory, task definitions are not cached between runs: %v", err)
+		return ""
+	}
+	return filepath.Join(dir, taskDefCacheDirName)
+}
+
+// cached reports whether a task definition is served without calling ECS
+func (c *taskDefinitionCache) cached(taskDefArn string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[taskDefArn]; ok {
+		return true
+	}
+	return c.load(taskDefArn) != nil
+}
+
+// get returns a task definition and its tags from the cache, describing and
+// caching them on a miss
+func (c *taskDefinitionCache) get(ctx context.Context, client *ecs.Client, taskDefArn string) (*types.TaskDefinition, []types.Tag, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[taskDefArn]; ok {
+		return entry.TaskDefinition, entry.Tags, nil
+	}
+	if entry := c.load(taskDefArn); entry != nil {
+		c.entries[taskDefArn] = entry
+		return entry.TaskDefinition, entry.Tags, nil
+	}
+
+	taskDef, tags, err := getTaskDefinition(ctx, client, taskDefArn)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Unpinned references (family or family:latest) move with new registrations
+	if revisionQualified.MatchString(taskDefArn) {
+		c.entries[taskDefArn] = &cachedTaskDefinition{TaskDefinitionArn: taskDefArn, TaskDefinition: taskDef, Tags: tags}
+		c.store(taskDefArn, taskDef, tags)
+	}
+	return taskDef, tags, nil
+}
+
+// path is the cache file of a revision
+func (c *taskDefinitionCache) path(taskDefArn string) string {
+	sum := sha256.Sum256([]byte(taskDefArn))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// load reads a revision from disk; unreadable entries count as misses
+func (c *taskDefinitionCache) load(taskDefArn string) *cachedTaskDefinition {
+	if c.dir == "" || !revisionQualified.MatchString(taskDefArn) {
+		return nil
+	}
+	data, err := os.ReadFile(c.path(taskDefArn))
+	if err != nil {
+		return nil
+	}
+	var entry cachedTaskDefinition
+	if err := json.Unmarshal(data, &entry); err != nil || entry.TaskDefinitionArn != taskDefArn || entry.TaskDefinition == nil {
+		log.Printf("Warning: Ignoring invalid cache entry for %s", taskDefArn)
+		return nil
+	}
+	if entry.Tags == nil {
+		return nil
+	}
+	return &entry
+}
+
+// store writes a revision to disk. Failures only cost a fetch on the next run.
+func (c *taskDefinitionCache) store(taskDefArn string, taskDef *types.TaskDefinition, tags []types.Tag) {
+	if c.dir == "" {
+		return
+	}
+	if err := c.write(taskDefArn, taskDef, tags); err != nil {
+		log.Printf("Warning: Failed to cache task definition %s: %v", taskDefArn, err)
+	}
+}
+
+// write replaces the cache file of a revision atomically, so concurrent runs
+// never read a partial entry
+func (c *taskDefinitionCache) write(taskDefArn string, taskDef *types.TaskDefinition, tags []types.Tag) error {
+	if tags == nil {
+		tags = []types.Tag{}
+	}
+	data, err := json.Marshal(cachedTaskDefinition{TaskDefinitionArn: taskDefArn, TaskDefinition: taskDef, Tags: tags})
+	if err != nil {
+		return fmt.Errorf("failed to encode: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory %s: %w", c.dir, err)
+	}
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(taskDefArn))
+}
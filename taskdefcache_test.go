@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestRevisionQualified tests which references pin an immutable revision
+func TestRevisionQualified(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"arn:aws:ecs:us-east-1:123456789012:task-definition/web:42", true},
+		{"arn:aws-cn:ecs:cn-north-1:123456789012:task-definition/my_web-app:1", true},
+		{"web:42", true},
+		{"arn:aws:ecs:us-east-1:123456789012:task-definition/web", false},
+		{"web", false},
+		{"web:latest", false},
+	}
+	for _, tt := range tests {
+		if got := revisionQualified.MatchString(tt.ref); got != tt.want {
+			t.Errorf("revisionQualified(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+// TestTaskDefinitionCacheDisk tests that revisions cached by one run are
+// served to the next without describing them
+func TestTaskDefinitionCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	arn := "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3"
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String(arn),
+		Family:            aws.String("web"),
+		Revision:          3,
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("web"),
+			Image:        aws.String("nginx:1.27"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80), Protocol: types.TransportProtocolTcp}},
+		}},
+		NetworkMode: types.NetworkModeAwsvpc,
+	}
+	tags := []types.Tag{{Key: aws.String("team"), Value: aws.String("payments")}}
+	newTaskDefinitionCache(dir).store(arn, taskDef, tags)
+
+	// A fresh cache, as in a later run; a nil client fails on any ECS call
+	cache := newTaskDefinitionCache(dir)
+	if !cache.cached(arn) {
+		t.Fatalf("cached(%s) = false after store", arn)
+	}
+	got, gotTags, err := cache.get(context.Background(), nil, arn)
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	if aws.ToString(got.ContainerDefinitions[0].Image) != "nginx:1.27" || got.NetworkMode != types.NetworkModeAwsvpc ||
+		aws.ToInt32(got.ContainerDefinitions[0].PortMappings[0].ContainerPort) != 80 {
+		t.Errorf("get() = %+v, want the stored definition", got)
+	}
+	if len(gotTags) != 1 || aws.ToString(gotTags[0].Value) != "payments" {
+		t.Errorf("get() tags = %+v, want the stored tags", gotTags)
+	}
+
+	// Other revisions and unpinned references are not served from disk
+	if cache.cached("arn:aws:ecs:us-east-1:123456789012:task-definition/web:4") {
+		t.Errorf("cached() = true for an uncached revision")
+	}
+	if cache.cached("arn:aws:ecs:us-east-1:123456789012:task-definition/web") {
+		t.Errorf("cached() = true for an unpinned reference")
+	}
+
+	// Entries cached before tags were described are fetched again
+	legacy := "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2"
+	data, err := json.Marshal(map[string]interface{}{"taskDefinitionArn": legacy, "taskDefinition": taskDef})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cache.path(legacy), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cache.cached(legacy) {
+		t.Errorf("cached() = true for an entry without tags")
+	}
+
+	// Entries for another ARN are ignored
+	if err := os.Rename(cache.path(arn), cache.path(arn+"0")); err != nil {
+		t.Fatal(err)
+	}
+	if newTaskDefinitionCache(dir).cached(arn + "0") {
+		t.Errorf("cached() = true for an entry of another ARN")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// Task set statuses: the primary task set serves the service's traffic, and
+// active task sets run next to it, e.g. a canary of an external deployment
+const (
+	taskSetPrimary = "PRIMARY"
+	taskSetActive  = "ACTIVE"
+)
+
+// CanaryTaskSet is an active task set running another task definition next to
+// the primary task set of a service
+type CanaryTaskSet struct {
+	ID             string
+	Service        string
+	TaskDefinition string
+	// Weight is the share of the service's desired count the task set runs, in percent
+	Weight float64
+	// Images are the container images of the task set's task definition by
+	// container name, filled once the task definition is read
+	Images map[string]string
+}
+
+// serviceTaskDefinition returns the task definition a service runs: its own,
+// or for services deployed with task sets (EXTERNAL or CODE_DEPLOY deployment
+// controller) the one of the primary task set
+func serviceTaskDefinition(svc types.Service) string {
+	if taskDef := aws.ToString(svc.TaskDefinition); taskDef != "" {
+		return taskDef
+	}
+	for _, taskSet := range svc.TaskSets {
+		if aws.ToString(taskSet.Status) == taskSetPrimary {
+			return aws.ToString(taskSet.TaskDefinition)
+		}
+	}
+	return ""
+}
+
+// canaryTaskSets lists the active task sets of the services that run another
+// task definition than the primary one
+func canaryTaskSets(services []types.Service) []CanaryTaskSet {
+	var canaries []CanaryTaskSet
+	for _, svc := range services {
+		primary := serviceTaskDefinition(svc)
+		for _, taskSet := range svc.TaskSets {
+			taskDef := aws.ToString(taskSet.TaskDefinition)
+			if aws.ToString(taskSet.Status) != taskSetActive || taskDef == "" || taskDef == primary {
+				continue
+			}
+			canary := CanaryTaskSet{
+				ID:             aws.ToString(taskSet.Id),
+				Service:        aws.ToString(svc.ServiceName),
+				TaskDefinition: taskDef,
+			}
+			if taskSet.Scale != nil && taskSet.Scale.Unit == types.ScaleUnitPercent {
+				canary.Weight = taskSet.Scale.Value
+			}
+			canaries = append(canaries, canary)
+		}
+	}
+	return canaries
+}
+
+// resolveCanaryImages reads the task definitions of the canary task sets of a
+// task definition's services for the images they run
+func resolveCanaryImages(taskDefInfo *TaskDefInfo, fetch taskDefInfoFetcher) {
+	for i := range taskDefInfo.Canaries {
+		canary := &taskDefInfo.Canaries[i]
+		canaryInfo, err := fetch(canary.TaskDefinition, nil)
+		if err != nil {
+			log.Printf("Warning: Failed to read task definition %s of task set %s: %v", canary.TaskDefinition, canary.ID, err)
+			continue
+		}
+		canary.Images = map[string]string{}
+		for _, container := range canaryInfo.Containers {
+			canary.Images[container.Name] = container.Image
+		}
+	}
+}
+
+// applyTaskSets maps the split between the primary task set and a canary task
+// set: the Deployment runs the primary task definition, and an Argo Rollout
+// from --rollouts=argo starts with the canary's weight and pauses, like the
+// external deployment controller holding the split until it promotes
+func applyTaskSets(taskDefInfo *TaskDefInfo) {
+	if len(taskDefInfo.Canaries) == 0 || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	var rollout map[string]interface{}
+	for _, extra := range taskDefInfo.Manifests.Extras {
+		if extra.Suffix == "rollout" {
+			rollout = extra.Object
+		}
+	}
+
+	for _, canary := range taskDefInfo.Canaries {
+		note := fmt.Sprintf("ECS service %s runs task set %s (%s) with %g%% of its tasks next to the primary task set; the Deployment runs the primary task definition",
+			canary.Service, canary.ID, taskDefLabel(canary.TaskDefinition), canary.Weight)
+		if changed := changedImages(taskDefInfo, canary); len(changed) > 0 {
+			note += fmt.Sprintf(". The canary runs %s", strings.Join(changed, ", "))
+		}
+
+		if rollout == nil {
+			note += ". Use --rollouts=argo to keep the canary split"
+			taskDefInfo.Notes = append(taskDefInfo.Notes, note)
+			continue
+		}
+
+		weight := int(math.Round(canary.Weight))
+		weight = min(max(weight, 1), 99)
+		canarySpec := rollout["spec"].(map[string]interface{})["strategy"].(map[string]interface{})["canary"].(map[string]interface{})
+		steps, _ := canarySpec["steps"].([]map[string]interface{})
+		canarySpec["steps"] = append([]map[string]interface{}{
+			{"setWeight": weight},
+			// Waits for `kubectl argo rollouts promote`, like the external controller
+			{"pause": map[string]interface{}{}},
+		}, steps...)
+		note += fmt.Sprintf(". The Rollout starts the next update with %d%% and pauses until promoted; apply the canary images to the Deployment to resume the split", weight)
+		taskDefInfo.Notes = append(taskDefInfo.Notes, note)
+		log.Printf("✓ Mapped task set %s of %s to a %d%% canary step", canary.ID, canary.Service, weight)
+		// One Rollout holds one split
+		break
+	}
+}
+
+// changedImages lists the containers whose canary image differs from the
+// primary's, as container=image
+func changedImages(taskDefInfo *TaskDefInfo, canary CanaryTaskSet) []string {
+	primary := map[string]string{}
+	for _, c := range taskDefInfo.Containers {
+		primary[c.Name] = c.Image
+	}
+	var changed []string
+	for name, image := range canary.Images {
+		if primary[name] != image {
+			changed = append(changed, fmt.Sprintf("%s=%s", name, image))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// taskDefLabel returns the family:revision of a task definition ARN
+func taskDefLabel(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// externalService returns a service of the EXTERNAL deployment controller
+// with a primary task set and a 10% canary task set
+func externalService() types.Service {
+	return types.Service{
+		ServiceName: aws.String("web"),
+		TaskSets: []types.TaskSet{
+			{
+				Id:             aws.String("ecs-svc/1"),
+				Status:         aws.String(taskSetPrimary),
+				TaskDefinition: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:3"),
+				Scale:          &types.Scale{Unit: types.ScaleUnitPercent, Value: 90},
+			},
+			{
+				Id:             aws.String("ecs-svc/2"),
+				Status:         aws.String(taskSetActive),
+				TaskDefinition: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:4"),
+				Scale:          &types.Scale{Unit: types.ScaleUnitPercent, Value: 10},
+			},
+		},
+	}
+}
+
+// TestServiceTaskDefinition tests reading the task definition of services
+// with and without task sets
+func TestServiceTaskDefinition(t *testing.T) {
+	if got := serviceTaskDefinition(externalService()); got != "arn:aws:ecs:us-east-1:123456789012:task-definition/web:3" {
+		t.Errorf("task set service task definition = %q, want the primary task set's", got)
+	}
+	svc := types.Service{TaskDefinition: aws.String("web:1")}
+	if got := serviceTaskDefinition(svc); got != "web:1" {
+		t.Errorf("service task definition = %q, want web:1", got)
+	}
+	if got := serviceTaskDefinition(types.Service{}); got != "" {
+		t.Errorf("empty service task definition = %q, want none", got)
+	}
+}
+
+// TestCanaryTaskSets tests listing the active task sets next to the primary one
+func TestCanaryTaskSets(t *testing.T) {
+	canaries := canaryTaskSets([]types.Service{externalService()})
+	if len(canaries) != 1 {
+		t.Fatalf("canaries = %+v, want one", canaries)
+	}
+	if canaries[0].ID != "ecs-svc/2" || canaries[0].Weight != 10 || canaries[0].Service != "web" {
+		t.Errorf("canary = %+v, want task set ecs-svc/2 of web at 10%%", canaries[0])
+	}
+}
+
+// TestApplyTaskSets tests mapping a canary task set to the Argo Rollout steps
+func TestApplyTaskSets(t *testing.T) {
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name:       "web",
+			Containers: []ContainerConfig{{Name: "app", Image: "web:1.0"}},
+			Manifests:  K8sManifests{Deployment: &corev1.PodSpec{}},
+			Canaries: []CanaryTaskSet{{
+				ID:             "ecs-svc/2",
+				Service:        "web",
+				TaskDefinition: "arn:aws:ecs:us-east-1:123456789012:task-definition/web:4",
+				Weight:         10,
+				Images:         map[string]string{"app": "web:1.1"},
+			}},
+		}
+	}
+
+	taskDefInfo := newInfo()
+	applyRollouts(taskDefInfo, rolloutsArgo)
+	applyTaskSets(taskDefInfo)
+
+	canary := taskDefInfo.Manifests.Extras[0].Object["spec"].(map[string]interface{})["strategy"].(map[string]interface{})["canary"].(map[string]interface{})
+	steps := canary["steps"].([]map[string]interface{})
+	if len(steps) != 6 || steps[0]["setWeight"] != 10 || steps[1]["pause"] == nil {
+		t.Errorf("steps = %v, want the 10%% canary weight and a pause first", steps)
+	}
+	note := taskDefInfo.Notes[len(taskDefInfo.Notes)-1]
+	if !strings.Contains(note, "web:4") || !strings.Contains(note, "app=web:1.1") {
+		t.Errorf("note = %q, want the canary task definition and image", note)
+	}
+
+	taskDefInfo = newInfo()
+	applyTaskSets(taskDefInfo)
+	if len(taskDefInfo.Manifests.Extras) != 0 || len(taskDefInfo.Notes) != 1 || !strings.Contains(taskDefInfo.Notes[0], "--rollouts=argo") {
+		t.Errorf("without rollouts: extras = %d, notes = %v, want a note suggesting --rollouts=argo", len(taskDefInfo.Manifests.Extras), taskDefInfo.Notes)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reviewAnnotation flags a generated object whose settings were guessed and
+// must be reviewed before it is applied
+const reviewAnnotation = "ecs2k8s.io/review"
+
+// tlsVolumeName is the pod volume of the Secret cert-manager issues
+const tlsVolumeName = "tls-certs"
+
+// Secret keys of the files of a cert-manager Certificate
+const (
+	tlsCertKey     = "tls.crt"
+	tlsKeyKey      = "tls.key"
+	tlsCAKey       = "ca.crt"
+	tlsPKCS12Key   = "keystore.p12"
+	tlsKeystoreKey = "keystore.jks"
+)
+
+// tlsFileExtensions are the extensions of certificate, key and keystore files
+var tlsFileExtensions = map[string]bool{
+	".pem": true, ".crt": true, ".cer": true, ".key": true, ".p12": true, ".pfx": true, ".jks": true,
+}
+
+// tlsNamePattern matches environment variables and mount paths about TLS
+var tlsNamePattern = regexp.MustCompile(`(?i)(tls|ssl|cert|pki|x509)`)
+
+// TLSFile is a certificate, key or keystore file a container reads
+type TLSFile struct {
+	Container string
+	// Env is the variable naming the file; empty for a mounted directory
+	Env  string
+	Path string
+	// Key is the Secret key mounted at Path; empty for a mounted directory
+	Key string
+}
+
+// detectTLSFiles lists the TLS files of the containers: absolute paths of
+// certificate files in TLS-related environment variables, and mount points at
+// certificate directories
+func detectTLSFiles(taskDefInfo *TaskDefInfo) []TLSFile {
+	if taskDefInfo.Source == nil {
+		return nil
+	}
+
+	var files []TLSFile
+	for _, def := range taskDefInfo.Source.ContainerDefinitions {
+		container := sanitizeName(aws.ToString(def.Name))
+		var dirs []string
+		seen := map[string]bool{}
+		for _, mount := range def.MountPoints {
+			dir := path.Clean(aws.ToString(mount.ContainerPath))
+			if strings.HasPrefix(dir, "/") && tlsNamePattern.MatchString(dir) {
+				dirs = append(dirs, dir)
+				files = append(files, TLSFile{Container: container, Path: dir})
+			}
+		}
+
+		for _, env := range def.Environment {
+			name, value := aws.ToString(env.Name), path.Clean(aws.ToString(env.Value))
+			if !strings.HasPrefix(value, "/") || !tlsFileExtensions[strings.ToLower(path.Ext(value))] {
+				continue
+			}
+			if !tlsNamePattern.MatchString(name) && !tlsNamePattern.MatchString(value) {
+				continue
+			}
+			if underAny(value, dirs) || seen[value] {
+				continue
+			}
+			seen[value] = true
+			files = append(files, TLSFile{Container: container, Env: name, Path: value, Key: tlsSecretKey(name, value)})
+		}
+	}
+	return files
+}
+
+// tlsSecretKey returns the key of the cert-manager Secret a file maps to
+func tlsSecretKey(env, file string) string {
+	ext := strings.ToLower(path.Ext(file))
+	switch ext {
+	case ".p12", ".pfx":
+		return tlsPKCS12Key
+	case ".jks":
+		return tlsKeystoreKey
+	}
+
+	tokens := map[string]bool{}
+	for _, token := range strings.FieldsFunc(strings.ToUpper(env+"_"+strings.TrimSuffix(path.Base(file), ext)), func(r rune) bool {
+		return r < 'A' || r > 'Z'
+	}) {
+		tokens[token] = true
+	}
+	switch {
+	case tokens["CA"] || tokens["BUNDLE"] || tokens["ROOT"]:
+		return tlsCAKey
+	case ext == ".key" || tokens["KEY"]:
+		return tlsKeyKey
+	}
+	return tlsCertKey
+}
+
+// underAny reports whether a file lies in one of the directories
+func underAny(file string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(file, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTLSFiles migrates the TLS files of a service. With an issuer, a
+// cert-manager Certificate stub issues a Secret that is mounted where the
+// containers read the files, file by file with subPath or as a whole at a
+// certificate directory. Without one, the files are listed in the notes.
+func applyTLSFiles(taskDefInfo *TaskDefInfo, issuer string) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	files := detectTLSFiles(taskDefInfo)
+	if podSpec == nil || len(files) == 0 {
+		return
+	}
+
+	var described []string
+	for _, file := range files {
+		if file.Env != "" {
+			described = append(described, fmt.Sprintf("%s=%s", file.Env, file.Path))
+		} else {
+			described = append(described, fmt.Sprintf("mount %s", file.Path))
+		}
+	}
+	if issuer == "" {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Service reads TLS files (%s); generate cert-manager Certificate stubs with --cert-manager-issuer", strings.Join(described, ", ")))
+		return
+	}
+
+	secretName := taskDefInfo.Name + "-tls"
+	keys := map[string]bool{}
+	for _, file := range files {
+		for i := range podSpec.Containers {
+			c := &podSpec.Containers[i]
+			if c.Name != file.Container {
+				continue
+			}
+			mount := corev1.VolumeMount{Name: tlsVolumeName, MountPath: file.Path, ReadOnly: true}
+			if file.Key != "" {
+				mount.SubPath = file.Key
+				keys[file.Key] = true
+			}
+			c.VolumeMounts = append(c.VolumeMounts, mount)
+		}
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name:         tlsVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+	})
+
+	dnsName := taskDefInfo.Name
+	if len(taskDefInfo.Manifests.Services) > 0 {
+		dnsName = taskDefInfo.Manifests.Services[0].Name
+	}
+	spec := map[string]interface{}{
+		"secretName": secretName,
+		"commonName": dnsName,
+		"dnsNames":   []string{dnsName},
+		"usages":     []string{"server auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  issuer,
+			"kind":  "ClusterIssuer",
+			"group": "cert-manager.io",
+		},
+	}
+	// A CA next to the certificate usually verifies clients (mTLS)
+	if keys[tlsCAKey] {
+		spec["usages"] = []string{"server auth", "client auth"}
+	}
+	keystores := map[string]interface{}{}
+	passwordRef := map[string]interface{}{"name": secretName + "-keystore", "key": "password"}
+	if keys[tlsPKCS12Key] {
+		keystores["pkcs12"] = map[string]interface{}{"create": true, "passwordSecretRef": passwordRef}
+	}
+	if keys[tlsKeystoreKey] {
+		keystores["jks"] = map[string]interface{}{"create": true, "passwordSecretRef": passwordRef}
+	}
+	if len(keystores) > 0 {
+		spec["keystores"] = keystores
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Certificate %s writes keystores protected by the password in Secret %s (key password); create it", secretName, secretName+"-keystore"))
+	}
+
+	certificate := map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]interface{}{
+			"name":      secretName,
+			"namespace": "default",
+			"labels":    map[string]string{"app": taskDefInfo.Name},
+			"annotations": map[string]string{
+				reviewAnnotation: "Generated from the TLS files of the ECS task definition; check dnsNames, usages and issuerRef",
+			},
+		},
+		"spec": spec,
+	}
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: "certificate", Object: certificate})
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Service reads TLS files (%s); they are mounted from Secret %s of the cert-manager Certificate stub, review it before use", strings.Join(described, ", "), secretName))
+	log.Printf("✓ Generated cert-manager Certificate %s for %d TLS file(s) of %s", secretName, len(files), taskDefInfo.Name)
+}
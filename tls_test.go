@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestTLSSecretKey tests mapping TLS files to the keys of the issued Secret
+func TestTLSSecretKey(t *testing.T) {
+	tests := []struct {
+		env, file, want string
+	}{
+		{"TLS_CERT_FILE", "/certs/server.crt", tlsCertKey},
+		{"TLS_KEY_FILE", "/certs/server.pem", tlsKeyKey},
+		{"SSL_KEY", "/certs/server.key", tlsKeyKey},
+		{"TLS_CA_FILE", "/certs/ca.pem", tlsCAKey},
+		{"SSL_CERT_FILE", "/etc/pki/root-bundle.crt", tlsCAKey},
+		{"KEYSTORE_CERT", "/certs/app.p12", tlsPKCS12Key},
+		{"TRUSTSTORE_CERT", "/certs/trust.jks", tlsKeystoreKey},
+	}
+	for _, tt := range tests {
+		if got := tlsSecretKey(tt.env, tt.file); got != tt.want {
+			t.Errorf("tlsSecretKey(%q, %q) = %q, want %q", tt.env, tt.file, got, tt.want)
+		}
+	}
+}
+
+// TestApplyTLSFiles tests mounting the Secret of a Certificate stub at the TLS
+// files and directories of a task definition
+func TestApplyTLSFiles(t *testing.T) {
+	newInfo := func() *TaskDefInfo {
+		info, err := buildTaskDefInfo(&types.TaskDefinition{
+			Family: aws.String("api"),
+			ContainerDefinitions: []types.ContainerDefinition{
+				{
+					Name:         aws.String("api"),
+					Image:        aws.String("api:1"),
+					PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(8443)}},
+					Environment: []types.KeyValuePair{
+						{Name: aws.String("TLS_CERT_FILE"), Value: aws.String("/certs/server.crt")},
+						{Name: aws.String("TLS_KEY_FILE"), Value: aws.String("/certs/server.key")},
+						{Name: aws.String("TLS_CA_FILE"), Value: aws.String("/certs/ca.pem")},
+						{Name: aws.String("CONFIG_FILE"), Value: aws.String("/app/config.yaml")},
+					},
+				},
+				{
+					Name:        aws.String("proxy"),
+					Image:       aws.String("envoy:1"),
+					MountPoints: []types.MountPoint{{SourceVolume: aws.String("certs"), ContainerPath: aws.String("/etc/envoy/tls/")}},
+					Environment: []types.KeyValuePair{{Name: aws.String("TLS_CERT"), Value: aws.String("/etc/envoy/tls/cert.pem")}},
+				},
+			},
+		}, "api")
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo() error = %v", err)
+		}
+		return info
+	}
+
+	info := newInfo()
+	applyTLSFiles(info, "")
+	if len(info.Manifests.Extras) != 0 || len(info.Notes) != 1 || !strings.Contains(info.Notes[0], "--cert-manager-issuer") {
+		t.Errorf("without an issuer: extras = %d, notes = %v, want a note only", len(info.Manifests.Extras), info.Notes)
+	}
+
+	info = newInfo()
+	applyTLSFiles(info, "internal-ca")
+	podSpec := info.Manifests.Deployment
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Secret == nil || podSpec.Volumes[0].Secret.SecretName != "api-tls" {
+		t.Fatalf("volumes = %+v, want the api-tls Secret", podSpec.Volumes)
+	}
+	api := podSpec.Containers[0].VolumeMounts
+	if len(api) != 3 || api[0].MountPath != "/certs/server.crt" || api[0].SubPath != tlsCertKey || api[2].SubPath != tlsCAKey || !api[0].ReadOnly {
+		t.Errorf("api mounts = %+v, want the certificate, key and CA files", api)
+	}
+	proxy := podSpec.Containers[1].VolumeMounts
+	if len(proxy) != 1 || proxy[0].MountPath != "/etc/envoy/tls" || proxy[0].SubPath != "" {
+		t.Errorf("proxy mounts = %+v, want the certificate directory", proxy)
+	}
+
+	certificate := info.Manifests.Extras[0].Object
+	spec := certificate["spec"].(map[string]interface{})
+	if spec["secretName"] != "api-tls" || spec["issuerRef"].(map[string]interface{})["name"] != "internal-ca" {
+		t.Errorf("spec = %v, want the api-tls Secret from internal-ca", spec)
+	}
+	if usages := spec["usages"].([]string); len(usages) != 2 || usages[1] != "client auth" {
+		t.Errorf("usages = %v, want client auth for mTLS", usages)
+	}
+	if certificate["metadata"].(map[string]interface{})["annotations"].(map[string]string)[reviewAnnotation] == "" {
+		t.Errorf("certificate is not flagged for review")
+	}
+
+	files, err := renderManifests("api", info.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	podTemplate := files["api-deployment.yaml"].(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	if podTemplate["volumes"] == nil {
+		t.Errorf("pod spec = %v, want the Secret volume", podTemplate)
+	}
+	if _, ok := files["api-certificate.yaml"]; !ok {
+		t.Errorf("files = %v, want api-certificate.yaml", sortedDocKeys(files))
+	}
+}
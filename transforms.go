@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// serviceTransforms are the conversion steps run on every fetched task
+// definition, shared by convert and the operator so both generate the same
+// objects for a service. Resolvers reading AWS are nil without AWS access or
+// when their flag is off.
+type serviceTransforms struct {
+	opts          *runOptions
+	loadBalancers *loadBalancerResolver
+	protection    *protectionResolver
+	zones         *zoneResolver
+	zeroTrust     *zeroTrustGenerator
+	secrets       *secretResolverChain
+	sizer         *rightsizer
+	alarms        *alarmConverter
+	keda          *kedaGenerator
+	knative       *knativeConverter
+}
+
+// newServiceTransforms creates the transforms of a cluster for the options of
+// a run; cfg is nil for conversions without AWS access
+func newServiceTransforms(cfg *aws.Config, cluster string, opts *runOptions) *serviceTransforms {
+	t := &serviceTransforms{opts: opts}
+
+	// Without AWS access, target groups are mapped from the services alone
+	if cfg != nil {
+		t.loadBalancers = newLoadBalancerResolver(elbv2.NewFromConfig(*cfg))
+		t.loadBalancers.cutoverWeight = opts.cutoverWeight
+		t.loadBalancers.targetGroupBindings = opts.targetGroupBindings
+	}
+
+	// Scale-in protection is read from the running tasks, so only with AWS access
+	if cfg != nil {
+		t.protection = newProtectionResolver(ecs.NewFromConfig(*cfg), cluster)
+		t.zones = newZoneResolver(ec2.NewFromConfig(*cfg))
+	}
+
+	if opts.zeroTrust != "" && cfg != nil {
+		t.zeroTrust = &zeroTrustGenerator{client: ec2.NewFromConfig(*cfg), mode: opts.zeroTrust}
+	}
+
+	if opts.secrets != nil {
+		if _, ok := opts.secrets.(kubernetesSecretStore); ok {
+			log.Printf("Warning: --resolve-secrets writes secret values in plain text to the generated Secrets")
+		}
+		t.secrets = newSecretResolverChain(*cfg, opts.secrets)
+	}
+
+	if opts.rightsize {
+		log.Printf("Rightsizing requests from the p%g utilization over the last %d day(s)", opts.rightsizePercentile, opts.rightsizeDays)
+		t.sizer = &rightsizer{
+			client:     cloudwatch.NewFromConfig(*cfg),
+			cluster:    cluster,
+			days:       opts.rightsizeDays,
+			percentile: opts.rightsizePercentile,
+		}
+	}
+
+	if opts.convertAlarms {
+		t.alarms = &alarmConverter{client: cloudwatch.NewFromConfig(*cfg), cluster: cluster}
+	}
+
+	if opts.createKEDA {
+		t.keda = &kedaGenerator{
+			client:  applicationautoscaling.NewFromConfig(*cfg),
+			cluster: cluster,
+			region:  opts.region,
+		}
+	}
+
+	if opts.output == outputKnative {
+		t.knative = &knativeConverter{cluster: cluster}
+		if cfg != nil {
+			t.knative.client = applicationautoscaling.NewFromConfig(*cfg)
+		}
+	}
+
+	return t
+}
+
+// apply runs the transforms on a task definition. Zero-trust policies must be
+// resolved for all services of the cluster first.
+func (t *serviceTransforms) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	opts := t.opts
+	setImagePullPolicy(taskDefInfo, opts.imagePullPolicy)
+	applyPropagatedTags(taskDefInfo)
+	if opts.awsEnv {
+		injectAWSEnv(taskDefInfo, opts.region)
+	}
+	t.loadBalancers.apply(ctx, taskDefInfo)
+	applyWorkers(taskDefInfo, opts.headlessWorkers)
+	applyContainerHealthChecks(taskDefInfo, opts.probeSource)
+	if t.protection != nil {
+		t.protection.apply(ctx, taskDefInfo)
+	}
+	if t.zones != nil {
+		t.zones.apply(ctx, taskDefInfo)
+	}
+	if t.secrets != nil {
+		t.secrets.apply(ctx, taskDefInfo)
+	}
+	opts.config.apply(taskDefInfo)
+	applyTLSFiles(taskDefInfo, opts.certManagerIssuer)
+	if opts.smartTemplates {
+		applySmartTemplates(taskDefInfo)
+	}
+	if t.sizer != nil {
+		t.sizer.apply(ctx, taskDefInfo)
+	}
+	auditResources(taskDefInfo, opts.resourceFloor)
+	if t.knative != nil {
+		t.knative.apply(ctx, taskDefInfo)
+	}
+	if t.keda != nil {
+		t.keda.apply(ctx, taskDefInfo)
+	}
+	applyRollouts(taskDefInfo, opts.rollouts)
+	applyTaskSets(taskDefInfo)
+	if opts.alerts {
+		applyAlertRules(taskDefInfo)
+	}
+	if t.alarms != nil {
+		t.alarms.apply(ctx, taskDefInfo)
+	}
+	if t.zeroTrust != nil {
+		t.zeroTrust.apply(taskDefInfo)
+	}
+}
+
+// servicePipeline runs the conversion steps of a run on the fetched services
+// of a cluster: the transforms, the overrides and metadata of the objects, the
+// renames of colliding objects and the platform profile. convert, watch and
+// the operator share it so a service regenerated on its own matches a full
+// conversion.
+type servicePipeline struct {
+	opts       *runOptions
+	cluster    string
+	transforms *serviceTransforms
+	overrides  map[string][]OverridePatch
+	objects    objectNames
+}
+
+// newServicePipeline creates the pipeline of a cluster for the options of a
+// run; cfg is nil for conversions without AWS access
+func newServicePipeline(cfg *aws.Config, cluster string, overrides map[string][]OverridePatch, opts *runOptions) *servicePipeline {
+	return &servicePipeline{
+		opts:       opts,
+		cluster:    cluster,
+		transforms: newServiceTransforms(cfg, cluster, opts),
+		overrides:  overrides,
+		objects:    objectNames{},
+	}
+}
+
+// resolve starts the conversion of the services of the cluster, in the order
+// they are applied: zero-trust policies refer to the pods of other services,
+// so all are analyzed first, and names claimed by a previous conversion are
+// released
+func (p *servicePipeline) resolve(ctx context.Context, taskDefInfos []*TaskDefInfo) error {
+	p.objects = objectNames{}
+	if p.transforms.zeroTrust != nil {
+		return p.transforms.zeroTrust.resolve(ctx, taskDefInfos)
+	}
+	return nil
+}
+
+// apply runs the conversion steps on a service of the cluster
+func (p *servicePipeline) apply(ctx context.Context, taskDefInfo *TaskDefInfo) error {
+	opts := p.opts
+	p.transforms.apply(ctx, taskDefInfo)
+	taskDefInfo.Manifests.Overrides = p.overrides[taskDefInfo.Name]
+	taskDefInfo.Manifests.Metadata = opts.metadata
+	taskDefInfo.Manifests.APIVersions = opts.apiVersions
+	taskDefInfo.Manifests.Kinds = opts.kinds
+	taskDefInfo.Manifests.Owner = ownerOf(extractClusterName(p.cluster), taskDefInfo)
+	p.objects.disambiguate(taskDefInfo)
+	opts.profile.apply(taskDefInfo, opts.identities)
+	if opts.conversionRecords {
+		return applyConversionRecord(taskDefInfo, extractClusterName(p.cluster), opts.region)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// objectKey identifies a generated object within the output set of a cluster
+type objectKey struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (k objectKey) String() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s %s", k.Kind, k.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", k.Kind, k.Namespace, k.Name)
+}
+
+// clusterScopedKinds are the generated kinds without a namespace
+var clusterScopedKinds = map[string]bool{
+	"ClusterRole":        true,
+	"ClusterRoleBinding": true,
+}
+
+// objectNames maps the objects generated for a cluster to the task definition
+// that generated them, so objects of different task definitions that would
+// overwrite each other once applied (e.g. the ConfigMaps of two containers
+// named "app") are renamed
+type objectNames map[objectKey]string
+
+// newObjectKey returns the key of an object, placing namespaced objects without
+// a namespace in "default" like the rendered manifests
+func newObjectKey(kind, namespace, name string) objectKey {
+	switch {
+	case clusterScopedKinds[kind]:
+		namespace = ""
+	case namespace == "":
+		namespace = "default"
+	}
+	return objectKey{Kind: kind, Namespace: namespace, Name: name}
+}
+
+// claim records an object of a task definition and returns the name it is
+// generated under: its own name, or a name prefixed with the task definition
+// (then numbered) when another object already uses it
+func (n objectNames) claim(owner, kind, namespace, name string) string {
+	key := newObjectKey(kind, namespace, name)
+	if _, taken := n[key]; !taken {
+		n[key] = owner
+		return name
+	}
+
+	base := sanitizeName(owner + "-" + name)
+	candidate := base
+	for i := 2; ; i++ {
+		key.Name = candidate
+		if _, taken := n[key]; !taken {
+			break
+		}
+		candidate = sanitizeName(base + "-" + strconv.Itoa(i))
+	}
+	n[key] = owner
+	return candidate
+}
+
+// disambiguate claims every object generated for a task definition, renaming
+// those colliding with objects already claimed and updating the references to
+// them. Renames are recorded in the report notes.
+func (n objectNames) disambiguate(taskDefInfo *TaskDefInfo) {
+	owner := taskDefInfo.Name
+	manifests := &taskDefInfo.Manifests
+
+	rename := func(kind, namespace, name string) string {
+		renamed := n.claim(owner, kind, namespace, name)
+		if renamed != name {
+			key := newObjectKey(kind, namespace, name)
+			log.Printf("Info: Renamed %s to %s, the name is already generated for %s", key, renamed, n[key])
+			taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("%s is generated as %s because %s already uses the name", key, renamed, n[key]))
+		}
+		return renamed
+	}
+	// The migration Job runs a copy of the pod spec, with the same references
+	renameRefs := func(kind, from, to string) {
+		renamePodRefs(manifests.Deployment, kind, from, to)
+		if manifests.Migration != nil {
+			renamePodRefs(manifests.Migration.PodSpec, kind, from, to)
+		}
+	}
+
+	if manifests.Deployment != nil {
+		// Task definition names are unique within a cluster
+		n.claim(owner, "Deployment", "default", owner)
+	}
+
+	for _, cm := range manifests.ConfigMaps {
+		if cm != nil {
+			name := rename("ConfigMap", cm.Namespace, cm.Name)
+			renameRefs("ConfigMap", cm.Name, name)
+			cm.Name = name
+		}
+	}
+
+	for _, secret := range manifests.Secrets {
+		if secret != nil {
+			name := rename("Secret", secret.Namespace, secret.Name)
+			renameRefs("Secret", secret.Name, name)
+			secret.Name = name
+		}
+	}
+
+	// The Secrets synced by the Vault Secrets Operator are not generated but
+	// still collide once synced
+	for _, extra := range manifests.Extras {
+		if kind, _ := extra.Object["kind"].(string); kind != "VaultStaticSecret" {
+			continue
+		}
+		spec, _ := extra.Object["spec"].(map[string]interface{})
+		destination, _ := spec["destination"].(map[string]interface{})
+		metadata, _ := extra.Object["metadata"].(map[string]interface{})
+		name, _ := destination["name"].(string)
+		if name == "" {
+			continue
+		}
+		namespace, _ := metadata["namespace"].(string)
+		renamed := rename("Secret", namespace, name)
+		renameRefs("Secret", name, renamed)
+		destination["name"] = renamed
+	}
+
+	for _, svc := range manifests.Services {
+		if svc == nil {
+			continue
+		}
+		name := rename("Service", svc.Namespace, svc.Name)
+		if name != svc.Name {
+			for _, extra := range manifests.Extras {
+				renameServiceRefs(extra.Object, svc.Name, name)
+			}
+			svc.Name = name
+		}
+	}
+
+	if sa := manifests.ServiceAccount; sa != nil {
+		name := rename("ServiceAccount", sa.Namespace, sa.Name)
+		if name != sa.Name {
+			if manifests.Deployment != nil && manifests.Deployment.ServiceAccountName == sa.Name {
+				manifests.Deployment.ServiceAccountName = name
+			}
+			if manifests.Migration != nil && manifests.Migration.PodSpec != nil && manifests.Migration.PodSpec.ServiceAccountName == sa.Name {
+				manifests.Migration.PodSpec.ServiceAccountName = name
+			}
+			renameRBACSubjects(manifests.RBAC, sa.Name, name)
+			sa.Name = name
+		}
+	}
+
+	if rbac := manifests.RBAC; rbac != nil {
+		if rbac.Role != nil {
+			name := rename("Role", rbac.Role.Namespace, rbac.Role.Name)
+			if rbac.RoleBinding != nil && rbac.RoleBinding.RoleRef.Name == rbac.Role.Name {
+				rbac.RoleBinding.RoleRef.Name = name
+			}
+			rbac.Role.Name = name
+		}
+		if rbac.RoleBinding != nil {
+			rbac.RoleBinding.Name = rename("RoleBinding", rbac.RoleBinding.Namespace, rbac.RoleBinding.Name)
+		}
+		if rbac.ClusterRole != nil {
+			name := rename("ClusterRole", "", rbac.ClusterRole.Name)
+			if rbac.ClusterRoleBinding != nil && rbac.ClusterRoleBinding.RoleRef.Name == rbac.ClusterRole.Name {
+				rbac.ClusterRoleBinding.RoleRef.Name = name
+			}
+			rbac.ClusterRole.Name = name
+		}
+		if rbac.ClusterRoleBinding != nil {
+			rbac.ClusterRoleBinding.Name = rename("ClusterRoleBinding", "", rbac.ClusterRoleBinding.Name)
+		}
+	}
+
+	for _, extra := range manifests.Extras {
+		kind, _ := extra.Object["kind"].(string)
+		metadata, ok := extra.Object["metadata"].(map[string]interface{})
+		if kind == "" || !ok {
+			continue
+		}
+		name, _ := metadata["name"].(string)
+		namespace, _ := metadata["namespace"].(string)
+		if name == "" {
+			continue
+		}
+		metadata["name"] = rename(kind, namespace, name)
+	}
+}
+
+// renameRBACSubjects points the RBAC bindings of a renamed ServiceAccount at its new name
+func renameRBACSubjects(rbac *RBACManifests, from, to string) {
+	if rbac == nil {
+		return
+	}
+	if rbac.RoleBinding != nil {
+		for i := range rbac.RoleBinding.Subjects {
+			if rbac.RoleBinding.Subjects[i].Kind == "ServiceAccount" && rbac.RoleBinding.Subjects[i].Name == from {
+				rbac.RoleBinding.Subjects[i].Name = to
+			}
+		}
+	}
+	if rbac.ClusterRoleBinding != nil {
+		for i := range rbac.ClusterRoleBinding.Subjects {
+			if rbac.ClusterRoleBinding.Subjects[i].Kind == "ServiceAccount" && rbac.ClusterRoleBinding.Subjects[i].Name == from {
+				rbac.ClusterRoleBinding.Subjects[i].Name = to
+			}
+		}
+	}
+}
+
+// renamePodRefs points the references of a pod to a renamed ConfigMap or
+// Secret, from environment variables, envFrom and volumes, at its new name
+func renamePodRefs(podSpec *corev1.PodSpec, kind, from, to string) {
+	if podSpec == nil || from == to {
+		return
+	}
+	rename := func(name *string) {
+		if *name == from {
+			*name = to
+		}
+	}
+
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for j := range containers {
+			c := &containers[j]
+			for i := range c.Env {
+				if source := c.Env[i].ValueFrom; source != nil {
+					if kind == "Secret" && source.SecretKeyRef != nil {
+						rename(&source.SecretKeyRef.Name)
+					}
+					if kind == "ConfigMap" && source.ConfigMapKeyRef != nil {
+						rename(&source.ConfigMapKeyRef.Name)
+					}
+				}
+			}
+			for i := range c.EnvFrom {
+				if kind == "Secret" && c.EnvFrom[i].SecretRef != nil {
+					rename(&c.EnvFrom[i].SecretRef.Name)
+				}
+				if kind == "ConfigMap" && c.EnvFrom[i].ConfigMapRef != nil {
+					rename(&c.EnvFrom[i].ConfigMapRef.Name)
+				}
+			}
+		}
+	}
+
+	for i := range podSpec.Volumes {
+		source := &podSpec.Volumes[i].VolumeSource
+		if kind == "Secret" && source.Secret != nil {
+			rename(&source.Secret.SecretName)
+		}
+		if kind == "ConfigMap" && source.ConfigMap != nil {
+			rename(&source.ConfigMap.Name)
+		}
+		if source.Projected == nil {
+			continue
+		}
+		for j := range source.Projected.Sources {
+			projection := &source.Projected.Sources[j]
+			if kind == "Secret" && projection.Secret != nil {
+				rename(&projection.Secret.Name)
+			}
+			if kind == "ConfigMap" && projection.ConfigMap != nil {
+				rename(&projection.ConfigMap.Name)
+			}
+		}
+	}
+}
+
+// renameServiceRefs points the Service references of an additional object, the
+// backends of an Ingress and the serviceRef of a TargetGroupBinding, at the new
+// name of a renamed Service
+func renameServiceRefs(obj interface{}, from, to string) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if ref, ok := value.(map[string]interface{}); ok && (key == "service" || key == "serviceRef") && ref["name"] == from {
+				ref["name"] = to
+				continue
+			}
+			renameServiceRefs(value, from, to)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			renameServiceRefs(item, from, to)
+		}
+	case []interface{}:
+		for _, item := range v {
+			renameServiceRefs(item, from, to)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// appTaskDef returns a task definition running a container named "app"
+func appTaskDef(family string) *types.TaskDefinition {
+	return &types.TaskDefinition{
+		Family:      aws.String(family),
+		TaskRoleArn: aws.String("arn:aws:iam::123456789012:role/" + family),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name:         aws.String("app"),
+			Image:        aws.String("nginx"),
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+			Environment:  []types.KeyValuePair{{Name: aws.String("MODE"), Value: aws.String("prod")}},
+		}},
+	}
+}
+
+// TestObjectNamesClaim tests renaming of objects whose kind, namespace and name are taken
+func TestObjectNamesClaim(t *testing.T) {
+	names := objectNames{}
+	if got := names.claim("web", "ConfigMap", "", "app-config"); got != "app-config" {
+		t.Errorf("first claim = %q, want app-config", got)
+	}
+	if got := names.claim("api", "Secret", "", "app-config"); got != "app-config" {
+		t.Errorf("claim of another kind = %q, want app-config", got)
+	}
+	if got := names.claim("api", "ConfigMap", "prod", "app-config"); got != "app-config" {
+		t.Errorf("claim in another namespace = %q, want app-config", got)
+	}
+	if got := names.claim("api", "ConfigMap", "default", "app-config"); got != "api-app-config" {
+		t.Errorf("colliding claim = %q, want api-app-config", got)
+	}
+	if got := names.claim("api", "ConfigMap", "", "app-config"); got != "api-app-config-2" {
+		t.Errorf("second colliding claim = %q, want api-app-config-2", got)
+	}
+	if got := names.claim("api", "ClusterRole", "prod", "reader"); got != "reader" {
+		t.Errorf("cluster-scoped claim = %q, want reader", got)
+	}
+	if got := names.claim("web", "ClusterRole", "default", "reader"); got != "web-reader" {
+		t.Errorf("cluster-scoped claim across namespaces = %q, want web-reader", got)
+	}
+}
+
+// TestObjectNamesDisambiguate tests that same-named containers of different
+// task definitions produce distinct objects and updated references
+func TestObjectNamesDisambiguate(t *testing.T) {
+	names := objectNames{}
+	var infos []*TaskDefInfo
+	for _, family := range []string{"web", "api"} {
+		info, err := buildTaskDefInfo(appTaskDef(family), family)
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+		}
+		info.Manifests.Extras = append(info.Manifests.Extras, ExtraObject{
+			Suffix: "app-targetgroupbinding",
+			Object: buildTargetGroupBinding("app-tg", info.Name, "app", 80, map[string]string{"targetGroupARN": "arn"}),
+		})
+		createRBAC(info.Name, &RBACSettings{Rules: []RBACRule{{Resources: []string{"pods"}, Verbs: []string{"get"}}}}, &info.Manifests)
+		names.disambiguate(info)
+		infos = append(infos, info)
+	}
+
+	web, api := infos[0].Manifests, infos[1].Manifests
+	if web.ConfigMaps[0].Name != "app-config" || web.Services[0].Name != "app" {
+		t.Errorf("first task definition renamed: ConfigMap %q, Service %q", web.ConfigMaps[0].Name, web.Services[0].Name)
+	}
+	if got := api.ConfigMaps[0].Name; got != "api-app-config" {
+		t.Errorf("ConfigMap = %q, want api-app-config", got)
+	}
+	if got := api.Services[0].Name; got != "api-app" {
+		t.Errorf("Service = %q, want api-app", got)
+	}
+	if got := api.ServiceAccount.Name; got != "api-default-sa" {
+		t.Errorf("ServiceAccount = %q, want api-default-sa", got)
+	}
+	if got := api.Deployment.ServiceAccountName; got != "api-default-sa" {
+		t.Errorf("serviceAccountName = %q, want api-default-sa", got)
+	}
+	if got := api.RBAC.RoleBinding.Subjects[0].Name; got != "api-default-sa" {
+		t.Errorf("RoleBinding subject = %q, want api-default-sa", got)
+	}
+
+	binding := api.Extras[0].Object
+	if got := binding["metadata"].(map[string]interface{})["name"]; got != "api-app-tg" {
+		t.Errorf("TargetGroupBinding name = %v, want api-app-tg", got)
+	}
+	if got := binding["spec"].(map[string]interface{})["serviceRef"].(map[string]interface{})["name"]; got != "api-app" {
+		t.Errorf("serviceRef = %v, want api-app", got)
+	}
+
+	notes := strings.Join(infos[1].Notes, "\n")
+	if !strings.Contains(notes, "ConfigMap default/app-config is generated as api-app-config because web already uses the name") {
+		t.Errorf("rename not reported in notes:\n%s", notes)
+	}
+	if len(infos[0].Notes) != 0 {
+		t.Errorf("first task definition has notes: %v", infos[0].Notes)
+	}
+}
+
+// TestObjectNamesDisambiguateSecrets tests that the pods of colliding
+// services keep reading their own Secrets once renamed
+func TestObjectNamesDisambiguateSecrets(t *testing.T) {
+	values := map[string]string{"DB_PASSWORD": "s3cret"}
+	vault := newVaultSecretStore("http://127.0.0.1:0", "tok", "secret", "ecs2k8s", "", vaultInjectionStaticSecret)
+
+	names := objectNames{}
+	var infos []*TaskDefInfo
+	for _, family := range []string{"web", "api"} {
+		info, err := buildTaskDefInfo(appTaskDef(family), family)
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+		}
+		if err := (kubernetesSecretStore{}).store(context.Background(), info, "app", values); err != nil {
+			t.Fatalf("store() error = %v", err)
+		}
+		info.Manifests.Deployment.Volumes = append(info.Manifests.Deployment.Volumes, corev1.Volume{
+			Name:         "secrets",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "app-ecs-secrets"}},
+		})
+		info.Manifests.Deployment.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-ecs-secrets"}},
+		}}
+		// The Vault destination of the worker container
+		vault.addStaticSecret(info, "worker", "ecs2k8s/"+family+"/worker", values)
+		names.disambiguate(info)
+		infos = append(infos, info)
+	}
+
+	for i, want := range []string{"app-ecs-secrets", "api-app-ecs-secrets"} {
+		manifests := infos[i].Manifests
+		if got := manifests.Secrets[0].Name; got != want {
+			t.Errorf("%s Secret = %q, want %q", infos[i].Name, got, want)
+		}
+		container := manifests.Deployment.Containers[0]
+		if got := container.Env[len(container.Env)-1].ValueFrom.SecretKeyRef.Name; got != want {
+			t.Errorf("%s DB_PASSWORD secretKeyRef = %q, want %q", infos[i].Name, got, want)
+		}
+		if got := container.EnvFrom[0].SecretRef.Name; got != want {
+			t.Errorf("%s envFrom = %q, want %q", infos[i].Name, got, want)
+		}
+		if got := manifests.Deployment.Volumes[0].Secret.SecretName; got != want {
+			t.Errorf("%s volume = %q, want %q", infos[i].Name, got, want)
+		}
+	}
+
+	staticSecret := infos[1].Manifests.Extras[0].Object
+	destination := staticSecret["spec"].(map[string]interface{})["destination"].(map[string]interface{})
+	if got := staticSecret["metadata"].(map[string]interface{})["name"]; got != "api-worker-ecs-secrets" {
+		t.Errorf("VaultStaticSecret = %v, want api-worker-ecs-secrets", got)
+	}
+	if got := destination["name"]; got != "api-worker-ecs-secrets" {
+		t.Errorf("VaultStaticSecret destination = %v, want api-worker-ecs-secrets", got)
+	}
+}
+
+// TestObjectNamesDisambiguateMigration tests that the migration Job of a
+// colliding service keeps reading its own renamed objects
+func TestObjectNamesDisambiguateMigration(t *testing.T) {
+	names := objectNames{}
+	var infos []*TaskDefInfo
+	for _, family := range []string{"web", "api"} {
+		taskDef := appTaskDef(family)
+		taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, types.ContainerDefinition{
+			Name:  aws.String("server"),
+			Image: aws.String("nginx"),
+		})
+		info, err := buildTaskDefInfo(taskDef, family)
+		if err != nil {
+			t.Fatalf("buildTaskDefInfo(%s) error = %v", family, err)
+		}
+		info.Manifests.Deployment.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}},
+		}}
+		applyMigrations(info, &MigrationSettings{Containers: []string{"app"}})
+		if info.Manifests.Migration == nil {
+			t.Fatalf("%s has no migration Job", family)
+		}
+		names.disambiguate(info)
+		infos = append(infos, info)
+	}
+
+	job := infos[1].Manifests.Migration.PodSpec
+	if got := job.ServiceAccountName; got != "api-default-sa" {
+		t.Errorf("migration serviceAccountName = %q, want api-default-sa", got)
+	}
+	if got := job.Containers[0].EnvFrom[0].ConfigMapRef.Name; got != "api-app-config" {
+		t.Errorf("migration envFrom = %q, want api-app-config", got)
+	}
+	if got := infos[0].Manifests.Migration.PodSpec.Containers[0].EnvFrom[0].ConfigMapRef.Name; got != "app-config" {
+		t.Errorf("first migration envFrom = %q, want app-config", got)
+	}
+}
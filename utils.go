@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -30,6 +36,55 @@ func extractClusterName(arn string) string {
 	return ""
 }
 
+// Output directory naming modes for --output-naming
+const (
+	outputNamingName    = "name"
+	outputNamingAccount = "account"
+	outputNamingHash    = "hash"
+)
+
+var outputNamingModes = []string{outputNamingName, outputNamingAccount, outputNamingHash}
+
+// isValidOutputNaming checks if mode is a supported output naming mode
+func isValidOutputNaming(mode string) bool {
+	for _, m := range outputNamingModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// extractAccountID returns the account ID field of an ARN
+// (arn:partition:service:region:account:resource)
+func extractAccountID(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
+
+// clusterOutputName returns the output directory name for a cluster. The
+// account and hash modes keep clusters that share a name in different
+// accounts or regions from writing into the same directory.
+func clusterOutputName(clusterArn, mode string) string {
+	name := extractClusterName(clusterArn)
+
+	switch mode {
+	case outputNamingAccount:
+		if account := extractAccountID(clusterArn); account != "" {
+			return fmt.Sprintf("%s-%s", name, account)
+		}
+		log.Printf("Warning: Could not extract account ID from %s, using cluster name only", clusterArn)
+	case outputNamingHash:
+		sum := sha256.Sum256([]byte(clusterArn))
+		return fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:])[:8])
+	}
+
+	return name
+}
+
 func extractTaskDefName(arn string) string {
 	if arn == "" {
 		log.Printf("Warning: Task definition ARN is empty")
@@ -83,12 +138,23 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 	}
 
 	if len(podSpec.Containers) > 0 {
-		var containersList []map[string]interface{}
+		containersList := make([]map[string]interface{}, 0, len(podSpec.Containers))
 		for _, container := range podSpec.Containers {
 			containerMap := map[string]interface{}{
 				"name":  container.Name,
 				"image": container.Image,
 			}
+			if container.ImagePullPolicy != "" {
+				containerMap["imagePullPolicy"] = string(container.ImagePullPolicy)
+			}
+
+			// Add the command of Jobs; Deployments keep the image entrypoint
+			if len(container.Command) > 0 {
+				containerMap["command"] = container.Command
+			}
+			if len(container.Args) > 0 {
+				containerMap["args"] = container.Args
+			}
 
 			// Add ports if present
 			if len(container.Ports) > 0 {
@@ -108,9 +174,17 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 				containerMap["ports"] = portsList
 			}
 
+			// Add probes if present
+			if container.LivenessProbe != nil {
+				containerMap["livenessProbe"] = toSerializable(container.LivenessProbe)
+			}
+			if container.ReadinessProbe != nil {
+				containerMap["readinessProbe"] = toSerializable(container.ReadinessProbe)
+			}
+
 			// Add environment variables if present
 			if len(container.Env) > 0 {
-				var envList []map[string]interface{}
+				envList := make([]map[string]interface{}, 0, len(container.Env))
 				for _, env := range container.Env {
 					envMap := map[string]interface{}{
 						"name": env.Name,
@@ -118,6 +192,9 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 					if env.Value != "" {
 						envMap["value"] = env.Value
 					}
+					if env.ValueFrom != nil {
+						envMap["valueFrom"] = toSerializable(env.ValueFrom)
+					}
 					envList = append(envList, envMap)
 				}
 				containerMap["env"] = envList
@@ -148,6 +225,23 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 				containerMap["resources"] = resourcesMap
 			}
 
+			if container.SecurityContext != nil {
+				containerMap["securityContext"] = toSerializable(container.SecurityContext)
+			}
+
+			// Keep the terminal of interactive containers
+			if container.Stdin {
+				containerMap["stdin"] = true
+			}
+			if container.TTY {
+				containerMap["tty"] = true
+			}
+
+			// Add volume mounts, e.g. the claims of a StatefulSet
+			if len(container.VolumeMounts) > 0 {
+				containerMap["volumeMounts"] = toSerializable(container.VolumeMounts)
+			}
+
 			containersList = append(containersList, containerMap)
 		}
 		result["containers"] = containersList
@@ -161,6 +255,17 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 				"name":  container.Name,
 				"image": container.Image,
 			}
+			if container.ImagePullPolicy != "" {
+				containerMap["imagePullPolicy"] = string(container.ImagePullPolicy)
+			}
+
+			// Add the command of Jobs; Deployments keep the image entrypoint
+			if len(container.Command) > 0 {
+				containerMap["command"] = container.Command
+			}
+			if len(container.Args) > 0 {
+				containerMap["args"] = container.Args
+			}
 			initContainersList = append(initContainersList, containerMap)
 		}
 		result["initContainers"] = initContainersList
@@ -176,13 +281,54 @@ func serializePodSpec(podSpec *corev1.PodSpec) map[string]interface{} {
 		result["serviceAccountName"] = podSpec.ServiceAccountName
 	}
 
+	// Add affinity rules if present
+	if podSpec.Affinity != nil {
+		result["affinity"] = toSerializable(podSpec.Affinity)
+	}
+
+	// Add node placement if present
+	if len(podSpec.NodeSelector) > 0 {
+		result["nodeSelector"] = podSpec.NodeSelector
+	}
+	if len(podSpec.Tolerations) > 0 {
+		result["tolerations"] = toSerializable(podSpec.Tolerations)
+	}
+	if len(podSpec.TopologySpreadConstraints) > 0 {
+		result["topologySpreadConstraints"] = toSerializable(podSpec.TopologySpreadConstraints)
+	}
+
+	if podSpec.SecurityContext != nil {
+		result["securityContext"] = toSerializable(podSpec.SecurityContext)
+	}
+
+	// Add volumes, e.g. the Secret of a cert-manager Certificate
+	if len(podSpec.Volumes) > 0 {
+		result["volumes"] = toSerializable(podSpec.Volumes)
+	}
+
+	// Add the hostnames of linked containers
+	if len(podSpec.HostAliases) > 0 {
+		result["hostAliases"] = toSerializable(podSpec.HostAliases)
+	}
+
+	// Add host and process namespace sharing if enabled
+	if podSpec.HostIPC {
+		result["hostIPC"] = true
+	}
+	if podSpec.HostPID {
+		result["hostPID"] = true
+	}
+	if podSpec.ShareProcessNamespace != nil {
+		result["shareProcessNamespace"] = *podSpec.ShareProcessNamespace
+	}
+
 	return result
 }
 
 // serializeServiceAccount converts a ServiceAccount to a map suitable for YAML marshaling
 func serializeServiceAccount(sa *corev1.ServiceAccount) map[string]interface{} {
 	result := map[string]interface{}{
-		"apiVersion": "v1",
+		"apiVersion": apiVersionCore,
 		"kind":       "ServiceAccount",
 	}
 
@@ -233,7 +379,7 @@ func serializeServiceAccount(sa *corev1.ServiceAccount) map[string]interface{} {
 // serializeConfigMap converts a ConfigMap to a clean map for YAML marshaling
 func serializeConfigMap(cm *corev1.ConfigMap) map[string]interface{} {
 	result := map[string]interface{}{
-		"apiVersion": "v1",
+		"apiVersion": apiVersionCore,
 		"kind":       "ConfigMap",
 		"metadata": map[string]interface{}{
 			"name": cm.Name,
@@ -254,7 +400,7 @@ func serializeConfigMap(cm *corev1.ConfigMap) map[string]interface{} {
 // serializeSecret converts a Secret to a clean map for YAML marshaling
 func serializeSecret(secret *corev1.Secret) map[string]interface{} {
 	result := map[string]interface{}{
-		"apiVersion": "v1",
+		"apiVersion": apiVersionCore,
 		"kind":       "Secret",
 		"metadata": map[string]interface{}{
 			"name": secret.Name,
@@ -279,7 +425,7 @@ func serializeSecret(secret *corev1.Secret) map[string]interface{} {
 // serializeService converts a Service to a clean map for YAML marshaling
 func serializeService(svc *corev1.Service) map[string]interface{} {
 	result := map[string]interface{}{
-		"apiVersion": "v1",
+		"apiVersion": apiVersionCore,
 		"kind":       "Service",
 		"metadata": map[string]interface{}{
 			"name": svc.Name,
@@ -296,6 +442,9 @@ func serializeService(svc *corev1.Service) map[string]interface{} {
 		"type":     string(svc.Spec.Type),
 		"selector": svc.Spec.Selector,
 	}
+	if svc.Spec.ClusterIP != "" {
+		spec["clusterIP"] = svc.Spec.ClusterIP
+	}
 
 	if len(svc.Spec.Ports) > 0 {
 		var ports []map[string]interface{}
@@ -318,6 +467,19 @@ func serializeService(svc *corev1.Service) map[string]interface{} {
 }
 
 func writeManifests(outputDir, taskDefName string, manifests K8sManifests) error {
+	if err := validateManifestOutput(outputDir, taskDefName); err != nil {
+		return err
+	}
+	files, err := renderManifests(taskDefName, manifests)
+	if err != nil {
+		return err
+	}
+	return writeManifestFiles(outputDir, files)
+}
+
+// validateManifestOutput checks the output directory and the task definition
+// name the manifest file names start with
+func validateManifestOutput(outputDir, taskDefName string) error {
 	if outputDir == "" {
 		return fmt.Errorf("output directory path cannot be empty")
 	}
@@ -341,14 +503,138 @@ func writeManifests(outputDir, taskDefName string, manifests K8sManifests) error
 	if !isValidFilename(taskDefName) {
 		return fmt.Errorf("invalid task definition name for filename: %s (contains invalid characters)", taskDefName)
 	}
+	return nil
+}
+
+// writeManifestFiles writes rendered documents keyed by file name to the
+// output directory
+func writeManifestFiles(outputDir string, files map[string]interface{}) error {
+	for filename, content := range files {
+		if !isValidFilename(filename) {
+			return fmt.Errorf("constructed filename %s contains invalid characters", filename)
+		}
+
+		filePath := filepath.Join(outputDir, filename)
+
+		// Prevent directory traversal
+		absFilePath, err := filepath.Abs(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for %s: %w", filePath, err)
+		}
+
+		absOutputDir, err := filepath.Abs(outputDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for output dir: %w", err)
+		}
+
+		if !strings.HasPrefix(absFilePath, absOutputDir) {
+			return fmt.Errorf("file path %s is outside output directory", filePath)
+		}
+
+		// Documents are encoded straight to the file, so a Deployment with many
+		// containers is never held as a whole in memory
+		if err := writeYAMLFile(filePath, content); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+		}
+
+		log.Printf("Wrote: %s", filePath)
+	}
+
+	return nil
+}
+
+// sourceTaskDefinition is the DescribeTaskDefinition response shape written by
+// --save-source, which POST /convert accepts for re-conversion
+type sourceTaskDefinition struct {
+	TaskDefinition *types.TaskDefinition `json:"taskDefinition"`
+}
+
+// writeSourceTaskDefinition writes the ECS task definition a service was
+// converted from as <task-def>-source.json
+func writeSourceTaskDefinition(outputDir, taskDefName string, taskDef *types.TaskDefinition) error {
+	if taskDef == nil {
+		return fmt.Errorf("no source task definition for %s", taskDefName)
+	}
+
+	data, err := json.MarshalIndent(sourceTaskDefinition{TaskDefinition: taskDef}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal source task definition of %s: %w", taskDefName, err)
+	}
+
+	path := filepath.Join(outputDir, taskDefName+"-source.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write source task definition %s: %w", path, err)
+	}
+	log.Printf("Wrote: %s", path)
+	return nil
+}
+
+// podTemplateMetadata returns the pod template metadata of a Deployment
+func podTemplateMetadata(name string, labels, annotations map[string]string) map[string]interface{} {
+	podLabels := map[string]string{}
+	for key, value := range labels {
+		podLabels[key] = value
+	}
+	podLabels["app"] = name
+
+	metadata := map[string]interface{}{
+		"labels": podLabels,
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	return metadata
+}
+
+// renderManifests builds the YAML-ready documents for a task definition keyed by
+// output filename
+func renderManifests(taskDefName string, manifests K8sManifests) (map[string]interface{}, error) {
+	base, err := renderBaseManifests(taskDefName, manifests)
+	if err != nil {
+		return nil, err
+	}
+	return finishManifests(base, manifests)
+}
+
+// finishManifests returns the rendered documents with the user overrides
+// merged and the --include-kinds/--exclude-kinds filter applied. The base
+// documents are left as they are.
+func finishManifests(base map[string]interface{}, manifests K8sManifests) (map[string]interface{}, error) {
+	files := make(map[string]interface{}, len(base))
+	for filename, doc := range base {
+		files[filename] = doc
+	}
+
+	// User overrides survive regeneration by being merged last
+	if err := applyOverrides(files, manifests.Overrides); err != nil {
+		return nil, err
+	}
+	manifests.Kinds.filter(files)
+
+	return files, nil
+}
+
+// renderBaseManifests builds the documents of a task definition with the
+// injected metadata, ownership labels and apiVersions, before the user
+// overrides and the kind filter
+func renderBaseManifests(taskDefName string, manifests K8sManifests) (map[string]interface{}, error) {
+	if taskDefName == "" {
+		return nil, fmt.Errorf("task definition name cannot be empty")
+	}
 
 	files := map[string]interface{}{}
 
-	// Deployment
-	if manifests.Deployment != nil {
+	// Knative Service, replacing the Deployment and Services
+	if manifests.Knative != nil && manifests.Deployment != nil {
+		files[fmt.Sprintf("%s-knative-service.yaml", taskDefName)] = renderKnativeService(taskDefName, manifests)
+	}
+
+	// Deployment, or StatefulSet for --smart-templates databases
+	if manifests.Deployment != nil && manifests.Knative == nil {
+		kind := workloadKind(manifests)
 		deployment := map[string]interface{}{
-			"apiVersion": "apps/v1",
-			"kind":       "Deployment",
+			"apiVersion": apiVersionApps,
+			"kind":       kind,
 			"metadata": map[string]interface{}{
 				"name":      taskDefName,
 				"namespace": "default",
@@ -364,16 +650,27 @@ func writeManifests(outputDir, taskDefName string, manifests K8sManifests) error
 					},
 				},
 				"template": map[string]interface{}{
-					"metadata": map[string]interface{}{
-						"labels": map[string]string{
-							"app": taskDefName,
-						},
-					},
-					"spec": serializePodSpec(manifests.Deployment),
+					"metadata": podTemplateMetadata(taskDefName, manifests.PodLabels, manifests.PodAnnotations),
+					"spec":     serializePodSpec(manifests.Deployment),
 				},
 			},
 		}
-		files[fmt.Sprintf("%s-deployment.yaml", taskDefName)] = deployment
+		if manifests.ProgressDeadlineSeconds != nil {
+			deployment["spec"].(map[string]interface{})["progressDeadlineSeconds"] = *manifests.ProgressDeadlineSeconds
+		}
+		if len(manifests.DeploymentAnnotations) > 0 {
+			deployment["metadata"].(map[string]interface{})["annotations"] = manifests.DeploymentAnnotations
+		}
+		if statefulSet := manifests.StatefulSet; statefulSet != nil {
+			deployment["spec"].(map[string]interface{})["serviceName"] = statefulSet.ServiceName
+			deployment["spec"].(map[string]interface{})["volumeClaimTemplates"] = statefulSet.volumeClaimTemplates()
+		}
+		files[fmt.Sprintf("%s-%s.yaml", taskDefName, strings.ToLower(kind))] = deployment
+	}
+
+	// Migration Job, run before the Deployment
+	if manifests.Migration != nil {
+		files[fmt.Sprintf("%s-migration-job.yaml", taskDefName)] = renderMigrationJob(taskDefName, manifests.Migration)
 	}
 
 	// ConfigMaps
@@ -391,7 +688,7 @@ func writeManifests(outputDir, taskDefName string, manifests K8sManifests) error
 
 	// Services
 	for _, svc := range manifests.Services {
-		if svc == nil {
+		if svc == nil || manifests.Knative != nil {
 			continue
 		}
 		svcMap := serializeService(svc)
@@ -421,40 +718,103 @@ func writeManifests(outputDir, taskDefName string, manifests K8sManifests) error
 		files[fmt.Sprintf("%s-serviceaccount.yaml", taskDefName)] = saManifest
 	}
 
-	// Write files
-	for filename, content := range files {
-		if !isValidFilename(filename) {
-			return fmt.Errorf("constructed filename %s contains invalid characters", filename)
-		}
+	// RBAC
+	for suffix, doc := range serializeRBAC(manifests.RBAC) {
+		files[fmt.Sprintf("%s-%s.yaml", taskDefName, suffix)] = doc
+	}
 
-		data, err := yaml.Marshal(content)
-		if err != nil {
-			return fmt.Errorf("failed to marshal YAML for %s: %w", filename, err)
-		}
+	// Additional objects (custom resources)
+	for _, extra := range manifests.Extras {
+		files[fmt.Sprintf("%s-%s.yaml", taskDefName, extra.Suffix)] = extra.Object
+	}
 
-		filePath := filepath.Join(outputDir, filename)
+	for _, doc := range files {
+		manifests.Metadata.apply(doc)
+		manifests.Owner.apply(doc)
+		manifests.APIVersions.apply(doc)
+		limitLabelValues(doc)
+	}
 
-		// Prevent directory traversal
-		absFilePath, err := filepath.Abs(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to resolve absolute path for %s: %w", filePath, err)
-		}
+	return files, nil
+}
 
-		absOutputDir, err := filepath.Abs(outputDir)
-		if err != nil {
-			return fmt.Errorf("failed to resolve absolute path for output dir: %w", err)
-		}
+// renderYAMLStream marshals documents into a single multi-document YAML stream,
+// ordered by key for stable output
+func renderYAMLStream(docs map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeYAMLStream(&buf, docs); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-		if !strings.HasPrefix(absFilePath, absOutputDir) {
-			return fmt.Errorf("file path %s is outside output directory", filePath)
+// writeYAMLStream writes the documents to w as a multi-document YAML stream
+// ordered by key
+func writeYAMLStream(w io.Writer, docs map[string]interface{}) error {
+	for _, key := range sortedDocKeys(docs) {
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		if err := encodeYAML(w, docs[key]); err != nil {
+			return fmt.Errorf("failed to marshal YAML for %s: %w", key, err)
 		}
+	}
+	return nil
+}
 
-		if err := os.WriteFile(filePath, data, 0o644); err != nil {
-			return fmt.Errorf("failed to write file %s: %w", filePath, err)
+// sortedDocKeys returns the keys of a document map in sorted order
+func sortedDocKeys(docs map[string]interface{}) []string {
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setManifestNamespace overrides metadata.namespace on a serialized manifest.
+// Cluster-scoped objects are left untouched, and ServiceAccount subjects of
+// RBAC bindings follow the new namespace.
+func setManifestNamespace(doc interface{}, namespace string) {
+	manifest, ok := doc.(map[string]interface{})
+	if !ok || namespace == "" {
+		return
+	}
+
+	if subjects, ok := manifest["subjects"].([]map[string]interface{}); ok {
+		for _, subject := range subjects {
+			if subject["kind"] == "ServiceAccount" {
+				subject["namespace"] = namespace
+			}
 		}
+	}
 
-		log.Printf("Wrote: %s", filePath)
+	switch manifest["kind"] {
+	case "ClusterRole", "ClusterRoleBinding":
+		return
 	}
 
-	return nil
+	metadata, ok := manifest["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	metadata["namespace"] = namespace
+}
+
+// toSerializable converts a typed Kubernetes object into generic maps via its
+// JSON tags, so nested specs marshal to YAML with their canonical field names
+func toSerializable(obj interface{}) interface{} {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		log.Printf("Warning: Failed to serialize %T: %v", obj, err)
+		return nil
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("Warning: Failed to deserialize %T: %v", obj, err)
+		return nil
+	}
+
+	return result
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestClusterOutputName tests the output directory names of the naming modes
+func TestClusterOutputName(t *testing.T) {
+	tests := []struct {
+		name       string
+		clusterArn string
+		mode       string
+		want       string
+	}{
+		{"name", "arn:aws:ecs:us-east-1:111111111111:cluster/prod", outputNamingName, "prod"},
+		{"default", "arn:aws:ecs:us-east-1:111111111111:cluster/prod", "", "prod"},
+		{"account", "arn:aws:ecs:us-east-1:111111111111:cluster/prod", outputNamingAccount, "prod-111111111111"},
+		{"other account", "arn:aws:ecs:us-east-1:222222222222:cluster/prod", outputNamingAccount, "prod-222222222222"},
+		{"account without arn", "prod", outputNamingAccount, "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clusterOutputName(tt.clusterArn, tt.mode); got != tt.want {
+				t.Errorf("clusterOutputName(%q, %q) = %q, want %q", tt.clusterArn, tt.mode, got, tt.want)
+			}
+		})
+	}
+
+	// Hashed names differ between regions and stay stable across runs
+	east := clusterOutputName("arn:aws:ecs:us-east-1:111111111111:cluster/prod", outputNamingHash)
+	west := clusterOutputName("arn:aws:ecs:us-west-2:111111111111:cluster/prod", outputNamingHash)
+	if east == west || len(east) != len("prod-")+8 {
+		t.Errorf("clusterOutputName() hash names = %q and %q, want distinct prod-<8 hex>", east, west)
+	}
+	if again := clusterOutputName("arn:aws:ecs:us-east-1:111111111111:cluster/prod", outputNamingHash); again != east {
+		t.Errorf("clusterOutputName() = %q, then %q, want a stable name", east, again)
+	}
+}
+
+// TestWriteSourceTaskDefinition tests that --save-source output reads back as
+// the converted task definition
+func TestWriteSourceTaskDefinition(t *testing.T) {
+	taskDef := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789:task-definition/my-web-app:1"),
+		NetworkMode:       types.NetworkModeAwsvpc,
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("web"), Image: aws.String("nginx:latest")},
+		},
+	}
+
+	dir := t.TempDir()
+	if err := writeSourceTaskDefinition(dir, "my-web-app", taskDef); err != nil {
+		t.Fatalf("writeSourceTaskDefinition() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "my-web-app-source.json"))
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	var source sourceTaskDefinition
+	if err := json.Unmarshal(data, &source); err != nil {
+		t.Fatalf("failed to parse source: %v", err)
+	}
+	if source.TaskDefinition == nil || aws.ToString(source.TaskDefinition.TaskDefinitionArn) != aws.ToString(taskDef.TaskDefinitionArn) {
+		t.Fatalf("source = %s", data)
+	}
+	if source.TaskDefinition.NetworkMode != types.NetworkModeAwsvpc || aws.ToString(source.TaskDefinition.ContainerDefinitions[0].Name) != "web" {
+		t.Errorf("source task definition = %+v", source.TaskDefinition)
+	}
+
+	if err := writeSourceTaskDefinition(dir, "missing", nil); err == nil {
+		t.Errorf("writeSourceTaskDefinition() without a source succeeded")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -29,8 +30,28 @@ var ValidAWSRegions = map[string]bool{
 	"ap-northeast-3": true,
 	"ca-central-1":   true,
 	"sa-east-1":      true,
+	"us-gov-east-1":  true,
+	"us-gov-west-1":  true,
 }
 
+// FIPSRegions contains the regions where ECS, and every other service
+// ecs2k8s calls, offer FIPS endpoints
+var FIPSRegions = map[string]bool{
+	"us-east-1":     true,
+	"us-east-2":     true,
+	"us-west-1":     true,
+	"us-west-2":     true,
+	"us-gov-east-1": true,
+	"us-gov-west-1": true,
+}
+
+// isolatedPartitionPrefixes are the region prefixes of the isolated
+// partitions, which have no dualstack endpoints
+var isolatedPartitionPrefixes = []string{"us-iso-", "us-isob-", "us-isof-", "eu-isoe-"}
+
+// regionQualifiers are the second part of regions of other partitions, such as us-gov-west-1
+var regionQualifiers = map[string]bool{"gov": true, "iso": true, "isob": true, "isof": true, "isoe": true}
+
 // RegionValidator validates AWS region input
 type RegionValidator struct {
 	Region string
@@ -50,6 +71,9 @@ func (rv *RegionValidator) ValidateFormat() error {
 	}
 
 	parts := strings.Split(rv.Region, "-")
+	if len(parts) == 4 && regionQualifiers[parts[1]] {
+		parts = append(parts[:1], parts[2:]...)
+	}
 	if len(parts) != 3 {
 		return fmt.Errorf("invalid region format: %s (expected format: xx-xxxx-x)", rv.Region)
 	}
@@ -100,6 +124,33 @@ func (rv *RegionValidator) Validate(ctx context.Context, ec2Client *ec2.Client)
 	return nil
 }
 
+// EndpointValidator validates the endpoint variants used in a region
+type EndpointValidator struct {
+	Region    string
+	FIPS      bool
+	DualStack bool
+}
+
+// Validate checks that the region offers the FIPS and dualstack endpoints
+func (ev *EndpointValidator) Validate() error {
+	if ev.FIPS && !FIPSRegions[ev.Region] {
+		regions := make([]string, 0, len(FIPSRegions))
+		for region := range FIPSRegions {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		return fmt.Errorf("FIPS endpoints are not available for ECS in region %s (available in: %s)", ev.Region, strings.Join(regions, ", "))
+	}
+	if ev.DualStack {
+		for _, prefix := range isolatedPartitionPrefixes {
+			if strings.HasPrefix(ev.Region, prefix) {
+				return fmt.Errorf("dualstack endpoints are not available in region %s", ev.Region)
+			}
+		}
+	}
+	return nil
+}
+
 // ClusterValidator validates ECS cluster
 type ClusterValidator struct {
 	ClusterName string
@@ -120,8 +171,15 @@ func (cv *ClusterValidator) ValidateName() error {
 	return nil
 }
 
-// ValidateFormat checks if cluster name has valid format
+// ValidateFormat checks if cluster name or ARN has valid format
 func (cv *ClusterValidator) ValidateFormat() error {
+	if strings.HasPrefix(cv.ClusterName, "arn:") {
+		if !isValidClusterARN(cv.ClusterName) {
+			return fmt.Errorf("invalid cluster ARN format: %s (expected arn:<partition>:ecs:<region>:<account>:cluster/<name>)", cv.ClusterName)
+		}
+		return nil
+	}
+
 	if !isValidClusterName(cv.ClusterName) {
 		return fmt.Errorf("invalid cluster name format: %s (must contain only alphanumeric characters, hyphens, and underscores)", cv.ClusterName)
 	}
@@ -365,6 +423,24 @@ func isValidClusterName(name string) bool {
 	return true
 }
 
+// isValidClusterARN checks if a cluster ARN has valid format
+func isValidClusterARN(arn string) bool {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "ecs" {
+		return false
+	}
+
+	if parts[1] == "" || parts[3] == "" || parts[4] == "" {
+		return false
+	}
+
+	if !strings.HasPrefix(parts[5], "cluster/") {
+		return false
+	}
+
+	return isValidClusterName(strings.TrimPrefix(parts[5], "cluster/"))
+}
+
 // isValidTaskDefName checks if task definition name has valid format
 func isValidTaskDefName(name string) bool {
 	if name == "" {
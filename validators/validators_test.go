@@ -45,6 +45,11 @@ func TestRegionValidatorFormat(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid region format",
 		},
+		{
+			name:    "valid GovCloud region",
+			region:  "us-gov-west-1",
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -198,6 +203,31 @@ func TestClusterValidatorFormat(t *testing.T) {
 			clusterArg: "my@cluster!",
 			wantErr:    true,
 		},
+		{
+			name:       "valid cluster ARN",
+			clusterArg: "arn:aws:ecs:us-east-1:123456789012:cluster/my-cluster",
+			wantErr:    false,
+		},
+		{
+			name:       "valid GovCloud cluster ARN",
+			clusterArg: "arn:aws-us-gov:ecs:us-gov-west-1:123456789012:cluster/my_cluster",
+			wantErr:    false,
+		},
+		{
+			name:       "invalid ARN for another service",
+			clusterArg: "arn:aws:eks:us-east-1:123456789012:cluster/my-cluster",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid ARN missing account",
+			clusterArg: "arn:aws:ecs:us-east-1::cluster/my-cluster",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid ARN with bad cluster name",
+			clusterArg: "arn:aws:ecs:us-east-1:123456789012:cluster/my cluster",
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -543,6 +573,30 @@ func TestValidAWSRegionsMap(t *testing.T) {
 	}
 }
 
+// TestEndpointValidator tests FIPS and dualstack endpoint validation
+func TestEndpointValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		ev      EndpointValidator
+		wantErr bool
+	}{
+		{name: "no variants", ev: EndpointValidator{Region: "eu-west-1"}},
+		{name: "FIPS in us-east-1", ev: EndpointValidator{Region: "us-east-1", FIPS: true}},
+		{name: "FIPS in GovCloud", ev: EndpointValidator{Region: "us-gov-west-1", FIPS: true, DualStack: true}},
+		{name: "FIPS in eu-west-1", ev: EndpointValidator{Region: "eu-west-1", FIPS: true}, wantErr: true},
+		{name: "dualstack in eu-west-1", ev: EndpointValidator{Region: "eu-west-1", DualStack: true}},
+		{name: "dualstack in an isolated region", ev: EndpointValidator{Region: "us-iso-east-1", DualStack: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ev.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // TestHelperFunctions tests helper functions
 func TestIsValidClusterName(t *testing.T) {
 	tests := []struct {
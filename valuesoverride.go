@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// valuesWildcard is the path segment of a --values-override matching every
+// key of a map, e.g. services.*.replicas
+const valuesWildcard = "*"
+
+// ValuesOverride sets a value of the generated Helm values.yaml
+type ValuesOverride struct {
+	// Path is the dotted key path, split into its segments
+	Path  []string
+	Value interface{}
+}
+
+// valuesOverrides are the --values-override settings; later ones win
+type valuesOverrides []ValuesOverride
+
+// parseValuesOverrides parses repeatable --values-override values of the form
+// key.path=value. Values are read like helm --set: integers and booleans keep
+// their type, anything else is a string, so a version such as 1.0 is kept.
+func parseValuesOverrides(flags []string) (valuesOverrides, error) {
+	var overrides valuesOverrides
+	for _, flag := range flags {
+		key, raw, ok := strings.Cut(flag, "=")
+		path := strings.Split(key, ".")
+		if !ok || key == "" || containsEmpty(path) {
+			return nil, fmt.Errorf("invalid --values-override %q (must be key.path=value)", flag)
+		}
+
+		var value interface{} = raw
+		var scalar interface{}
+		if err := yaml.Unmarshal([]byte(raw), &scalar); err == nil {
+			switch scalar.(type) {
+			case int, bool:
+				value = scalar
+			}
+		}
+		overrides = append(overrides, ValuesOverride{Path: path, Value: value})
+	}
+	return overrides, nil
+}
+
+// containsEmpty reports whether a path has an empty segment
+func containsEmpty(path []string) bool {
+	for _, segment := range path {
+		if segment == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the overrides in the values. Missing maps along a path are
+// created; a wildcard only matches existing keys.
+func (o valuesOverrides) apply(values map[string]interface{}) error {
+	for _, override := range o {
+		if err := setValuesPath(values, override.Path, override.Value); err != nil {
+			return fmt.Errorf("--values-override %s: %w", strings.Join(override.Path, "."), err)
+		}
+	}
+	return nil
+}
+
+// setValuesPath sets value at path below node
+func setValuesPath(node map[string]interface{}, path []string, value interface{}) error {
+	keys := []string{path[0]}
+	if path[0] == valuesWildcard {
+		keys = keys[:0]
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	for _, key := range keys {
+		if len(path) == 1 {
+			node[key] = value
+			continue
+		}
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			if node[key] != nil {
+				return fmt.Errorf("%s is not a map", key)
+			}
+			child = map[string]interface{}{}
+			node[key] = child
+		}
+		if err := setValuesPath(child, path[1:], value); err != nil {
+			return fmt.Errorf("%s.%w", key, err)
+		}
+	}
+	return nil
+}
+
+// overrideValues applies the overrides to the generated values. The values are
+// read back from their YAML first so every nested map has the same type.
+func overrideValues(values map[string]interface{}, overrides valuesOverrides) (map[string]interface{}, error) {
+	if len(overrides) == 0 {
+		return values, nil
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal values.yaml: %w", err)
+	}
+	generic := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, fmt.Errorf("failed to read values.yaml: %w", err)
+	}
+	if err := overrides.apply(generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestParseValuesOverrides tests parsing --values-override values
+func TestParseValuesOverrides(t *testing.T) {
+	overrides, err := parseValuesOverrides([]string{"services.*.replicas=3", "defaultNamespace=prod", "services.web.hostIPC=true", "image.tag=1.0"})
+	if err != nil {
+		t.Fatalf("parseValuesOverrides() error = %v", err)
+	}
+	want := []interface{}{3, "prod", true, "1.0"}
+	for i, override := range overrides {
+		if override.Value != want[i] {
+			t.Errorf("override %d value = %#v, want %#v", i, override.Value, want[i])
+		}
+	}
+
+	for _, flag := range []string{"replicas", "=3", "services..replicas=3"} {
+		if _, err := parseValuesOverrides([]string{flag}); err == nil {
+			t.Errorf("parseValuesOverrides(%q) succeeded", flag)
+		}
+	}
+}
+
+// TestOverrideValues tests applying --values-override to the generated values
+func TestOverrideValues(t *testing.T) {
+	outputDir := t.TempDir()
+	infos := []*TaskDefInfo{
+		{Name: "web", Containers: []ContainerConfig{{Name: "web", Image: "web:1"}}},
+		{Name: "worker", Containers: []ContainerConfig{{Name: "worker", Image: "worker:1"}}},
+	}
+	overrides, err := parseValuesOverrides([]string{"services.*.namespace=prod", "services.*.replicas=2", "services.web.podLabels.team=payments"})
+	if err != nil {
+		t.Fatalf("parseValuesOverrides() error = %v", err)
+	}
+	if err := CreateHelmChart("prod", infos, outputDir, helmChartOptions{overrides: overrides}); err != nil {
+		t.Fatalf("CreateHelmChart() error = %v", err)
+	}
+
+	var values map[string]interface{}
+	readYAML(t, filepath.Join(outputDir, "prod", "helm", "prod", "values.yaml"), &values)
+	services := values["services"].(map[string]interface{})
+	for _, name := range []string{"web", "worker"} {
+		service := services[name].(map[string]interface{})
+		if service["namespace"] != "prod" || service["replicas"] != 2 {
+			t.Errorf("%s: namespace = %v, replicas = %v, want prod and 2", name, service["namespace"], service["replicas"])
+		}
+	}
+	if labels, _ := services["web"].(map[string]interface{})["podLabels"].(map[string]interface{}); labels["team"] != "payments" {
+		t.Errorf("web podLabels = %v, want the created team label", labels)
+	}
+
+	notMap, _ := parseValuesOverrides([]string{"defaultReplicas.count=1"})
+	if _, err := overrideValues(map[string]interface{}{"defaultReplicas": 1}, notMap); err == nil {
+		t.Errorf("overriding below a scalar succeeded")
+	}
+}
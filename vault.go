@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	secretsModeKubernetes = "kubernetes"
+	secretsModeVault      = "vault"
+)
+
+// secretsModes lists the accepted --secrets-mode values
+var secretsModes = []string{secretsModeKubernetes, secretsModeVault}
+
+const (
+	// vaultInjectionAgent renders secrets into the pod with the Vault Agent injector
+	vaultInjectionAgent = "agent"
+	// vaultInjectionStaticSecret syncs secrets with a Vault Secrets Operator VaultStaticSecret
+	vaultInjectionStaticSecret = "static-secret"
+)
+
+// vaultInjections lists the accepted --vault-injection values
+var vaultInjections = []string{vaultInjectionAgent, vaultInjectionStaticSecret}
+
+// isValidSecretsMode checks if the secrets mode is supported
+func isValidSecretsMode(mode string) bool {
+	for _, m := range secretsModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidVaultInjection checks if the Vault injection method is supported
+func isValidVaultInjection(injection string) bool {
+	for _, i := range vaultInjections {
+		if injection == i {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultSecretStore writes resolved values to a Vault KV v2 secrets engine and
+// makes them available to the pods without Kubernetes Secrets in the output
+type vaultSecretStore struct {
+	client *http.Client
+	// addr is the Vault server address, e.g. https://vault.example.com:8200
+	addr  string
+	token string
+	// mount is the KV v2 secrets engine mount path
+	mount string
+	// pathPrefix is prepended to <task-def>/<container> for each written secret
+	pathPrefix string
+	// role is the Vault Kubernetes auth role; empty uses the service name
+	role string
+	// injection is vaultInjectionAgent or vaultInjectionStaticSecret
+	injection string
+}
+
+// newVaultSecretStore creates a Vault store authenticating with a token
+func newVaultSecretStore(addr, token, mount, pathPrefix, role, injection string) *vaultSecretStore {
+	return &vaultSecretStore{
+		client:     &http.Client{Timeout: 30 * time.Second},
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		mount:      strings.Trim(mount, "/"),
+		pathPrefix: strings.Trim(pathPrefix, "/"),
+		role:       role,
+		injection:  injection,
+	}
+}
+
+// store writes the values to Vault and wires them into the workload
+func (v *vaultSecretStore) store(ctx context.Context, taskDefInfo *TaskDefInfo, containerName string, values map[string]string) error {
+	secretPath := path.Join(v.pathPrefix, taskDefInfo.Name, containerName)
+	if err := v.writeKV(ctx, secretPath, values); err != nil {
+		return err
+	}
+	log.Printf("✓ Wrote %d secret(s) for %s/%s to Vault at %s/%s", len(values), taskDefInfo.Name, containerName, v.mount, secretPath)
+
+	switch v.injection {
+	case vaultInjectionStaticSecret:
+		v.addStaticSecret(taskDefInfo, containerName, secretPath, values)
+	default:
+		v.addAgentAnnotations(taskDefInfo, containerName, secretPath, values)
+	}
+	return nil
+}
+
+// writeKV creates a new version of a KV v2 secret
+func (v *vaultSecretStore) writeKV(ctx context.Context, secretPath string, values map[string]string) error {
+	body, err := json.Marshal(map[string]interface{}{"data": values})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", v.addr, v.mount, secretPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to Vault: %w", secretPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to write %s to Vault: %s: %s", secretPath, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// addAgentAnnotations annotates the pod for the Vault Agent injector, which
+// renders the secrets as a sourceable env file at /vault/secrets/<container>
+func (v *vaultSecretStore) addAgentAnnotations(taskDefInfo *TaskDefInfo, containerName, secretPath string, values map[string]string) {
+	role := v.role
+	if role == "" {
+		role = taskDefInfo.Name
+	}
+	dataPath := fmt.Sprintf("%s/data/%s", v.mount, secretPath)
+
+	var template strings.Builder
+	fmt.Fprintf(&template, "{{- with secret %q -}}\n", dataPath)
+	for _, name := range sortedSecretNames(values) {
+		fmt.Fprintf(&template, "export %s=\"{{ .Data.data.%s }}\"\n", name, name)
+	}
+	template.WriteString("{{- end }}\n")
+
+	if taskDefInfo.Manifests.PodAnnotations == nil {
+		taskDefInfo.Manifests.PodAnnotations = map[string]string{}
+	}
+	annotations := taskDefInfo.Manifests.PodAnnotations
+	annotations["vault.hashicorp.com/agent-inject"] = "true"
+	annotations["vault.hashicorp.com/role"] = role
+	annotations["vault.hashicorp.com/agent-inject-secret-"+containerName] = dataPath
+	annotations["vault.hashicorp.com/agent-inject-template-"+containerName] = template.String()
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Secrets of container %s are in Vault at %s; the Vault Agent renders them to /vault/secrets/%s, which the container must source to get them as environment variables (Vault role %s)", containerName, dataPath, containerName, role))
+}
+
+// addStaticSecret adds a Vault Secrets Operator VaultStaticSecret syncing the
+// Vault secret into the cluster and references its keys from the environment
+func (v *vaultSecretStore) addStaticSecret(taskDefInfo *TaskDefInfo, containerName, secretPath string, values map[string]string) {
+	secretName := resolvedSecretName(containerName)
+	staticSecret := map[string]interface{}{
+		"apiVersion": apiVersionVaultSecrets,
+		"kind":       "VaultStaticSecret",
+		"metadata": map[string]interface{}{
+			"name":   secretName,
+			"labels": map[string]string{"app": taskDefInfo.Name},
+		},
+		"spec": map[string]interface{}{
+			"type":         "kv-v2",
+			"mount":        v.mount,
+			"path":         secretPath,
+			"refreshAfter": "1h",
+			"destination": map[string]interface{}{
+				"name":   secretName,
+				"create": true,
+			},
+		},
+	}
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: secretName + "-vaultstaticsecret", Object: staticSecret})
+	addSecretKeyRefs(taskDefInfo.Manifests.Deployment, containerName, secretName, sortedSecretNames(values))
+
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Secrets of container %s are in Vault at %s/%s and synced into Secret %s by a VaultStaticSecret", containerName, v.mount, secretPath, secretName))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestVaultSecretStore tests writing resolved secrets to Vault KV v2 and wiring them into the pod
+func TestVaultSecretStore(t *testing.T) {
+	var gotPath, gotToken string
+	var gotBody map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotToken = r.URL.Path, r.Header.Get("X-Vault-Token")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newTaskDefInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			},
+		}
+	}
+	values := map[string]string{"DB_PASSWORD": "s3cret"}
+
+	agent := newVaultSecretStore(server.URL+"/", "tok", "secret", "ecs2k8s", "", vaultInjectionAgent)
+	taskDefInfo := newTaskDefInfo()
+	if err := agent.store(context.Background(), taskDefInfo, "app", values); err != nil {
+		t.Fatalf("store() error = %v", err)
+	}
+
+	if gotPath != "/v1/secret/data/ecs2k8s/web/app" || gotToken != "tok" {
+		t.Errorf("request = %s with token %q, want /v1/secret/data/ecs2k8s/web/app with tok", gotPath, gotToken)
+	}
+	if gotBody["data"]["DB_PASSWORD"] != "s3cret" {
+		t.Errorf("body = %v, want data.DB_PASSWORD", gotBody)
+	}
+
+	annotations := taskDefInfo.Manifests.PodAnnotations
+	if annotations["vault.hashicorp.com/role"] != "web" || annotations["vault.hashicorp.com/agent-inject-secret-app"] != "secret/data/ecs2k8s/web/app" {
+		t.Errorf("annotations = %v", annotations)
+	}
+	if !strings.Contains(annotations["vault.hashicorp.com/agent-inject-template-app"], `export DB_PASSWORD="{{ .Data.data.DB_PASSWORD }}"`) {
+		t.Errorf("template = %q", annotations["vault.hashicorp.com/agent-inject-template-app"])
+	}
+	if len(taskDefInfo.Manifests.Secrets) != 0 || len(taskDefInfo.Manifests.Deployment.Containers[0].Env) != 0 {
+		t.Errorf("agent injection must not create Secrets or secretKeyRefs")
+	}
+
+	static := newVaultSecretStore(server.URL, "tok", "secret", "ecs2k8s", "", vaultInjectionStaticSecret)
+	taskDefInfo = newTaskDefInfo()
+	if err := static.store(context.Background(), taskDefInfo, "app", values); err != nil {
+		t.Fatalf("store() error = %v", err)
+	}
+	if len(taskDefInfo.Manifests.Extras) != 1 || taskDefInfo.Manifests.Extras[0].Object["kind"] != "VaultStaticSecret" {
+		t.Errorf("extras = %+v, want a VaultStaticSecret", taskDefInfo.Manifests.Extras)
+	}
+	env := taskDefInfo.Manifests.Deployment.Containers[0].Env
+	if len(env) != 1 || env[0].ValueFrom.SecretKeyRef.Name != "app-ecs-secrets" {
+		t.Errorf("env = %+v, want a secretKeyRef to app-ecs-secrets", env)
+	}
+}
+
+// TestVaultSecretStoreError tests that Vault errors are surfaced
+func TestVaultSecretStoreError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	store := newVaultSecretStore(server.URL, "tok", "secret", "ecs2k8s", "", vaultInjectionAgent)
+	taskDefInfo := &TaskDefInfo{Name: "web", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}}
+	err := store.store(context.Background(), taskDefInfo, "app", map[string]string{"A": "b"})
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("store() error = %v, want permission denied", err)
+	}
+	if len(taskDefInfo.Manifests.PodAnnotations) != 0 {
+		t.Errorf("annotations set despite the failed write")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// Checks run by the verify subcommand
+const (
+	verifyCheckRollout   = "rollout"
+	verifyCheckReplicas  = "replicas"
+	verifyCheckEndpoints = "endpoints"
+)
+
+// VerifyCheck is the outcome of one verification of an applied object
+type VerifyCheck struct {
+	Object string
+	Check  string
+	Passed bool
+	Detail string
+}
+
+// verifyOptions holds the inputs of the verify subcommand
+type verifyOptions struct {
+	dir         string
+	cluster     string
+	kubeContext string
+	namespace   string
+	timeout     time.Duration
+	desiredECS  bool
+}
+
+// newVerifyCommand creates the `verify` subcommand
+func newVerifyCommand() *cobra.Command {
+	opts := &verifyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the applied manifests of a converted cluster before cutover",
+		Long: `verify checks the Deployments and Services of an output directory in the
+live Kubernetes cluster with kubectl and prints a pass/fail summary:
+
+  rollout    the Deployment rollout completes within --timeout
+  replicas   ready replicas reach the ECS desiredCount recorded in ` + stateFileName + `
+             (the Deployment's replicas without a state file)
+  endpoints  the Service has at least one ready endpoint
+
+It exits with an error when any check fails, so it can gate traffic cutover.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Output directory of a conversion (default: ./<cluster>)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name, used for the default --dir")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context (default: current context)")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Namespace the manifests were applied to (default: the namespace in the manifests)")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 5*time.Minute, "Time to wait for each Deployment rollout")
+	cmd.Flags().BoolVar(&opts.desiredECS, "ecs-desired-count", true, "Compare ready replicas with the ECS desiredCount from the state file")
+
+	return cmd
+}
+
+// runVerify executes the verify subcommand
+func runVerify(opts *verifyOptions) error {
+	ctx := context.Background()
+
+	dir := opts.dir
+	if dir == "" {
+		if opts.cluster == "" {
+			return fmt.Errorf("either --dir or --cluster is required")
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		dir = filepath.Join(cwd, opts.cluster)
+	}
+
+	deployments, err := readDeployments(dir)
+	if err != nil {
+		return err
+	}
+	services, err := readServices(dir)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 && len(services) == 0 {
+		return fmt.Errorf("no Deployments or Services found in %s", dir)
+	}
+
+	var desired map[string]int32
+	if opts.desiredECS {
+		if state, err := readConversionState(dir); err == nil {
+			desired = desiredCounts(state)
+		}
+	}
+
+	kubectl := &kubectlRunner{Context: opts.kubeContext}
+	var checks []VerifyCheck
+	for _, deployment := range deployments {
+		checks = append(checks, verifyDeployment(ctx, kubectl, deployment, opts.namespaceOf(deployment.Namespace), opts.timeout, desired)...)
+	}
+	for _, svc := range services {
+		checks = append(checks, verifyService(ctx, kubectl, svc.Name, opts.namespaceOf(svc.Namespace)))
+	}
+
+	failed := printVerifySummary(os.Stdout, checks)
+	if failed > 0 {
+		return fmt.Errorf("verification failed: %d of %d check(s) failed", failed, len(checks))
+	}
+	return nil
+}
+
+// namespaceOf returns --namespace, or else the namespace of a manifest
+func (o *verifyOptions) namespaceOf(namespace string) string {
+	switch {
+	case o.namespace != "":
+		return o.namespace
+	case namespace != "":
+		return namespace
+	}
+	return "default"
+}
+
+// readServices parses the Services of the YAML files in a directory
+func readServices(dir string) ([]*corev1.Service, error) {
+	docs, err := readManifestDocs(dir, "Service")
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*corev1.Service
+	for _, doc := range docs {
+		var svc corev1.Service
+		if err := decodeManifestDoc(doc, &svc); err != nil {
+			return nil, err
+		}
+		services = append(services, &svc)
+	}
+	return services, nil
+}
+
+// verifyDeployment waits for a Deployment rollout and compares its ready
+// replicas with the ECS desired count
+func verifyDeployment(ctx context.Context, kubectl *kubectlRunner, deployment *appsv1.Deployment, namespace string, timeout time.Duration, desired map[string]int32) []VerifyCheck {
+	object := "deployment/" + deployment.Name
+
+	rollout := VerifyCheck{Object: object, Check: verifyCheckRollout, Passed: true, Detail: "complete"}
+	if _, err := kubectl.run(ctx, nil, "rollout", "status", object, "--namespace", namespace, "--timeout", timeout.String()); err != nil {
+		rollout.Passed = false
+		rollout.Detail = err.Error()
+	}
+
+	want := int32(1)
+	if deployment.Spec.Replicas != nil {
+		want = *deployment.Spec.Replicas
+	}
+	if count, ok := desired[deployment.Name]; ok {
+		want = count
+	}
+
+	out, err := kubectl.run(ctx, nil, "get", object, "--namespace", namespace, "-o", "json")
+	if err != nil {
+		return []VerifyCheck{rollout, {Object: object, Check: verifyCheckReplicas, Detail: err.Error()}}
+	}
+	var live appsv1.Deployment
+	if err := json.Unmarshal(out, &live); err != nil {
+		return []VerifyCheck{rollout, {Object: object, Check: verifyCheckReplicas, Detail: fmt.Sprintf("failed to parse %s: %v", object, err)}}
+	}
+
+	return []VerifyCheck{rollout, replicaCheck(object, live.Status.ReadyReplicas, want)}
+}
+
+// replicaCheck passes when the ready replicas reach the desired count
+func replicaCheck(object string, ready, desired int32) VerifyCheck {
+	return VerifyCheck{
+		Object: object,
+		Check:  verifyCheckReplicas,
+		Passed: ready >= desired,
+		Detail: fmt.Sprintf("%d ready, %d desired", ready, desired),
+	}
+}
+
+// verifyService checks that a Service has ready endpoints
+func verifyService(ctx context.Context, kubectl *kubectlRunner, name, namespace string) VerifyCheck {
+	object := "service/" + name
+	out, err := kubectl.run(ctx, nil, "get", "endpointslices", "--namespace", namespace, "-l", discoveryv1.LabelServiceName+"="+name, "-o", "json")
+	if err != nil {
+		return VerifyCheck{Object: object, Check: verifyCheckEndpoints, Detail: err.Error()}
+	}
+
+	var slices discoveryv1.EndpointSliceList
+	if err := json.Unmarshal(out, &slices); err != nil {
+		return VerifyCheck{Object: object, Check: verifyCheckEndpoints, Detail: fmt.Sprintf("failed to parse endpoint slices of %s: %v", object, err)}
+	}
+	return endpointCheck(object, slices.Items)
+}
+
+// endpointCheck passes when the endpoint slices of a Service have a ready endpoint
+func endpointCheck(object string, slices []discoveryv1.EndpointSlice) VerifyCheck {
+	ready := 0
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			// A nil ready condition means ready
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready++
+			}
+		}
+	}
+
+	return VerifyCheck{
+		Object: object,
+		Check:  verifyCheckEndpoints,
+		Passed: ready > 0,
+		Detail: fmt.Sprintf("%d ready endpoint(s)", ready),
+	}
+}
+
+// printVerifySummary prints the checks as a table and returns the number of failures
+func printVerifySummary(w io.Writer, checks []VerifyCheck) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tCHECK\tRESULT\tDETAIL")
+
+	failed := 0
+	for _, check := range checks {
+		result := "PASS"
+		if !check.Passed {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", check.Object, check.Check, result, check.Detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", len(checks)-failed, failed)
+	return failed
+}
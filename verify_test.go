@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// TestReadServices tests reading the Services of an output directory
+func TestReadServices(t *testing.T) {
+	dir := t.TempDir()
+	manifests := map[string]string{
+		"web-service.yaml":    "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: shop\nspec:\n  ports:\n    - port: 80\n",
+		"web-deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+	}
+	for name, content := range manifests {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	services, err := readServices(dir)
+	if err != nil {
+		t.Fatalf("readServices() error = %v", err)
+	}
+	if len(services) != 1 || services[0].Name != "web" || services[0].Namespace != "shop" || services[0].Spec.Ports[0].Port != 80 {
+		t.Errorf("readServices() = %+v", services)
+	}
+
+	opts := &verifyOptions{}
+	if got := opts.namespaceOf(services[0].Namespace); got != "shop" {
+		t.Errorf("namespaceOf() = %q, want shop", got)
+	}
+	if got := opts.namespaceOf(""); got != "default" {
+		t.Errorf("namespaceOf(\"\") = %q, want default", got)
+	}
+	opts.namespace = "staging"
+	if got := opts.namespaceOf("shop"); got != "staging" {
+		t.Errorf("namespaceOf() with --namespace = %q, want staging", got)
+	}
+}
+
+// TestVerifyChecks tests the replica and endpoint checks and the summary
+func TestVerifyChecks(t *testing.T) {
+	if check := replicaCheck("deployment/web", 3, 3); !check.Passed {
+		t.Errorf("replicaCheck(3, 3) failed: %+v", check)
+	}
+	short := replicaCheck("deployment/web", 1, 3)
+	if short.Passed || short.Detail != "1 ready, 3 desired" {
+		t.Errorf("replicaCheck(1, 3) = %+v", short)
+	}
+
+	notReady := false
+	slices := []discoveryv1.EndpointSlice{{
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		},
+	}}
+	if check := endpointCheck("service/web", slices); check.Passed {
+		t.Errorf("endpointCheck() without ready endpoints passed: %+v", check)
+	}
+	slices[0].Endpoints = append(slices[0].Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}})
+	if check := endpointCheck("service/web", slices); !check.Passed || check.Detail != "1 ready endpoint(s)" {
+		t.Errorf("endpointCheck() = %+v", check)
+	}
+
+	var out strings.Builder
+	failed := printVerifySummary(&out, []VerifyCheck{
+		{Object: "deployment/web", Check: verifyCheckRollout, Passed: true, Detail: "complete"},
+		short,
+	})
+	if failed != 1 {
+		t.Errorf("printVerifySummary() = %d failures, want 1", failed)
+	}
+	if !strings.Contains(out.String(), "FAIL") || !strings.Contains(out.String(), "1 passed, 1 failed") {
+		t.Errorf("summary = %q", out.String())
+	}
+}
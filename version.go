@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// BuildInfo identifies the ecs2k8s binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// buildInfo returns the build information set by the release build. Builds
+// without it, such as go install, fall back to the module version and the
+// VCS revision the Go toolchain embeds.
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && embedded.Main.Version != "" && embedded.Main.Version != "(devel)" {
+		info.Version = embedded.Main.Version
+	}
+	for _, setting := range embedded.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "none":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.Date == "unknown":
+			info.Date = setting.Value
+		}
+	}
+	return info
+}
+
+// newVersionCommand creates the `version` subcommand
+func newVersionCommand() *cobra.Command {
+	var short, asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build information of ecs2k8s",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printVersion(os.Stdout, buildInfo(), short, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&short, "short", false, "Print only the version")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the build information as JSON")
+
+	return cmd
+}
+
+// printVersion writes the build information
+func printVersion(w io.Writer, info BuildInfo, short, asJSON bool) error {
+	switch {
+	case short:
+		_, err := fmt.Fprintln(w, info.Version)
+		return err
+	case asJSON:
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode build information: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "ecs2k8s %s\n  commit:   %s\n  built:    %s\n  go:       %s\n  platform: %s\n",
+		info.Version, info.Commit, info.Date, info.GoVersion, info.Platform)
+	return err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestPrintVersion tests the output formats of the version subcommand
+func TestPrintVersion(t *testing.T) {
+	info := BuildInfo{Version: "v1.4.0", Commit: "abc123", Date: "2026-01-02T03:04:05Z", GoVersion: "go1.22.0", Platform: "linux/amd64"}
+
+	var out bytes.Buffer
+	if err := printVersion(&out, info, true, false); err != nil || out.String() != "v1.4.0\n" {
+		t.Errorf("printVersion(short) = %q, %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := printVersion(&out, info, false, true); err != nil {
+		t.Fatalf("printVersion(json) error = %v", err)
+	}
+	var decoded BuildInfo
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded != info {
+		t.Errorf("printVersion(json) = %s, want %+v", out.String(), info)
+	}
+
+	out.Reset()
+	if err := printVersion(&out, info, false, false); err != nil || !strings.HasPrefix(out.String(), "ecs2k8s v1.4.0\n") || !strings.Contains(out.String(), "abc123") {
+		t.Errorf("printVersion() = %q, %v", out.String(), err)
+	}
+
+	if build := buildInfo(); build.Version == "" || build.GoVersion == "" || build.Platform == "" {
+		t.Errorf("buildInfo() = %+v, want every field set", build)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/spf13/cobra"
+)
+
+// watchOptions holds the inputs of the watch subcommand
+type watchOptions struct {
+	dir            string
+	region         string
+	cluster        string
+	interval       time.Duration
+	once           bool
+	commit         bool
+	apply          bool
+	kubeContext    string
+	namespace      string
+	forceConflicts bool
+}
+
+// watcher regenerates the manifests of the services that changed in ECS
+// since the state recorded in the output directory
+type watcher struct {
+	target *convertedCluster
+	// pipeline converts the services with the options recorded in the state
+	pipeline *servicePipeline
+	commit   bool
+	// push pushes the commits to the upstream of the branch
+	push bool
+	// trigger names what regenerated the services in the commit messages
+	trigger string
+	// kubectl applies the regenerated manifests; nil without --apply
+	kubectl        *kubectlRunner
+	namespace      string
+	forceConflicts bool
+}
+
+// newWatchCommand creates the `watch` subcommand
+func newWatchCommand() *cobra.Command {
+	opts := &watchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Regenerate the manifests of ECS services whenever they change",
+		Long: `watch polls the ECS services of a previously converted cluster every interval
+and compares them against the state recorded in the output directory
+(` + stateFileName + `), as drift does. The manifests of the services with a new
+task definition revision or changed service settings are regenerated in
+place and the state is updated; the other services are left alone.
+
+With --commit the regenerated files are committed to the git repository of
+the output directory, and with --apply they are applied to the Kubernetes
+cluster. Services removed from ECS keep their manifests; use prune to delete
+their objects.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.dir, "dir", "d", "", "Output directory of the previous conversion (default: ./<cluster>)")
+	cmd.Flags().StringVarP(&opts.region, "region", "r", "", "AWS region (default: region recorded in the state file)")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "c", "", "ECS cluster name (default: cluster recorded in the state file)")
+	cmd.Flags().DurationVar(&opts.interval, "interval", 5*time.Minute, "Polling interval")
+	cmd.Flags().BoolVar(&opts.once, "once", false, "Poll once and exit, e.g. from a scheduled job")
+	cmd.Flags().BoolVar(&opts.commit, "commit", false, "Commit the regenerated files to the git repository of the output directory")
+	cmd.Flags().BoolVar(&opts.apply, "apply", false, "Apply the regenerated manifests with kubectl server-side apply")
+	cmd.Flags().StringVar(&opts.kubeContext, "kubecontext", "", "Kubeconfig context for --apply (default: current context)")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Namespace to apply to with --apply (default: namespace of the manifests)")
+	cmd.Flags().BoolVar(&opts.forceConflicts, "force-conflicts", false, "Take back fields of the applied objects that were changed by another field manager")
+
+	return cmd
+}
+
+// runWatch executes the watch subcommand
+func runWatch(opts *watchOptions) error {
+	if opts.interval < time.Minute {
+		return fmt.Errorf("interval must be at least 1m (got %s)", opts.interval)
+	}
+	if !opts.apply && (opts.kubeContext != "" || opts.namespace != "" || opts.forceConflicts) {
+		return fmt.Errorf("--kubecontext, --namespace and --force-conflicts require --apply")
+	}
+
+	target, err := readConvertedCluster(opts.dir, opts.region, opts.cluster)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadAWSConfig(ctx, target.region)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	pipeline, err := newStatePipeline(&cfg, target)
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		target:         target,
+		pipeline:       pipeline,
+		commit:         opts.commit,
+		trigger:        "watch",
+		namespace:      opts.namespace,
+		forceConflicts: opts.forceConflicts,
+	}
+	if opts.apply {
+		w.kubectl = &kubectlRunner{Context: opts.kubeContext}
+	}
+
+	if opts.once {
+		return w.poll(ctx)
+	}
+
+	log.Printf("Watching cluster %s every %s (output directory: %s)", target.cluster, opts.interval, target.dir)
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil {
+			log.Printf("Error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Watch stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the ECS services of the cluster and regenerates the changed ones
+func (w *watcher) poll(ctx context.Context) error {
+	taskDefInfos, err := readECSTaskDefInfos(ctx, w.target.region, w.target.cluster)
+	if err != nil {
+		return err
+	}
+	_, err = w.sync(ctx, w.target.state.splitServices(taskDefInfos), "")
+	return err
+}
+
+// sync regenerates the manifests of the services that differ from the
+// recorded state, records them, then commits and applies them. taskDefInfos
+// are all the current services of the cluster; with a family, only the
+// services of that task definition family are compared. It returns the
+// updated services.
+func (w *watcher) sync(ctx context.Context, taskDefInfos []*TaskDefInfo, family string) ([]string, error) {
+	current, err := newConversionState(w.target.region, w.target.cluster, taskDefInfos)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := w.target.state
+	if family != "" {
+		previous = familyState(previous, family)
+		current = familyState(current, family)
+	}
+	drifts := compareConversionStates(previous, current)
+	if len(drifts) == 0 {
+		log.Printf("No ECS changes in cluster %s", w.target.cluster)
+		return nil, nil
+	}
+
+	// Conversions and other watchers write the same directory and state
+	release, err := acquireWorkspaceLock(w.target.dir, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Every service is converted again, in the order of a conversion, so the
+	// objects renamed for colliding names match the full conversion; only the
+	// changed ones are written
+	if err := w.pipeline.resolve(ctx, taskDefInfos); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*TaskDefInfo, len(taskDefInfos))
+	convertErrs := map[string]error{}
+	for _, taskDefInfo := range taskDefInfos {
+		byName[taskDefInfo.Name] = taskDefInfo
+		convertErrs[taskDefInfo.Name] = w.pipeline.apply(ctx, taskDefInfo)
+	}
+
+	// Services failing to regenerate keep their recorded state, so the next
+	// poll retries them
+	next := *w.target.state
+	next.Services = make(map[string]ServiceState, len(w.target.state.Services))
+	for name, svcState := range w.target.state.Services {
+		next.Services[name] = svcState
+	}
+
+	applyDocs := map[string]interface{}{}
+	var updated, failures []string
+	for _, drift := range drifts {
+		if drift.Status == "removed" {
+			log.Printf("Warning: Service %s was removed from ECS; its manifests are kept, run ecs2k8s prune to delete its objects", drift.Name)
+			delete(next.Services, drift.Name)
+			updated = append(updated, drift.Name)
+			continue
+		}
+
+		workload, err := w.regenerate(byName[drift.Name], convertErrs[drift.Name])
+		if err != nil {
+			log.Printf("Error: Failed to regenerate manifests for %s: %v", drift.Name, err)
+			failures = append(failures, drift.Name)
+			continue
+		}
+		log.Printf("✓ Regenerated manifests for %s (%s, %d field(s))", drift.Name, drift.Status, len(drift.Changes))
+		next.Services[drift.Name] = current.Services[drift.Name]
+		updated = append(updated, drift.Name)
+		for filename, doc := range workload.Docs {
+			applyDocs[filename] = doc
+		}
+	}
+
+	if len(updated) > 0 {
+		next.GeneratedAt = current.GeneratedAt
+		if err := writeConversionState(w.target.dir, &next); err != nil {
+			return nil, err
+		}
+		w.target.state = &next
+
+		if w.commit {
+			message := fmt.Sprintf("ecs2k8s %s: update %s of cluster %s", w.trigger, strings.Join(updated, ", "), extractClusterName(w.target.cluster))
+			if err := gitCommit(ctx, w.target.dir, message); err != nil {
+				return nil, err
+			}
+			log.Printf("✓ Committed the regenerated manifests")
+		}
+		if w.push {
+			if err := gitPush(ctx, w.target.dir); err != nil {
+				return nil, err
+			}
+			log.Printf("✓ Pushed the regenerated manifests")
+		}
+	}
+
+	if w.kubectl != nil && len(applyDocs) > 0 {
+		for _, doc := range applyDocs {
+			setManifestNamespace(doc, w.namespace)
+		}
+		stream, err := renderYAMLStream(applyDocs)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.kubectl.apply(ctx, stream, w.namespace, w.forceConflicts); err != nil {
+			return nil, err
+		}
+		log.Printf("✓ Applied %d object(s)", len(applyDocs))
+	}
+
+	if len(failures) > 0 {
+		return updated, fmt.Errorf("failed to regenerate %d service(s): %s", len(failures), strings.Join(failures, ", "))
+	}
+	return updated, nil
+}
+
+// familyState returns the recorded services of a task definition family
+func familyState(state *ConversionState, family string) *ConversionState {
+	scoped := *state
+	scoped.Services = map[string]ServiceState{}
+	for name, svcState := range state.Services {
+		if name == family || svcState.Fields["taskDefinition.Family"] == family {
+			scoped.Services[name] = svcState
+		}
+	}
+	return &scoped
+}
+
+// regenerate writes the manifests of a changed service converted by the
+// pipeline, unless its conversion failed
+func (w *watcher) regenerate(taskDefInfo *TaskDefInfo, convertErr error) (*Workload, error) {
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	applyConfigChecksums(taskDefInfo)
+
+	workload, err := newWorkload(taskDefInfo)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeWorkload(w.target.dir, workload); err != nil {
+		return nil, err
+	}
+	if taskDefInfo.CutoverScript != "" {
+		if err := writeCutoverScript(w.target.dir, taskDefInfo.Name, taskDefInfo.CutoverScript); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return workload, nil
+}
+
+// newStatePipeline creates the pipeline converting the services of a
+// converted cluster with the options its state recorded; cfg is nil without
+// AWS access
+func newStatePipeline(cfg *aws.Config, target *convertedCluster) (*servicePipeline, error) {
+	opts, err := replayRunOptions(target.state, target.region)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := loadOutputOverrides(target.dir, opts.overridesDir)
+	if err != nil {
+		return nil, err
+	}
+	return newServicePipeline(cfg, target.cluster, overrides, opts), nil
+}
+
+// gitCommit commits the changes of a directory to the git repository it is in.
+// Changes staged elsewhere in the repository and the workspace lock are left
+// out of the commit.
+func gitCommit(ctx context.Context, dir, message string) error {
+	if err := runGit(ctx, dir, "add", "--all", "--", ".", ":(exclude)"+lockFileName); err != nil {
+		return err
+	}
+	return runGit(ctx, dir, "commit", "--quiet", "--message", message, "--", ".")
+}
+
+// gitPush pushes the current branch of the repository of a directory
+func gitPush(ctx context.Context, dir string) error {
+	return runGit(ctx, dir, "push", "--quiet")
+}
+
+// runGit runs git in a directory
+func runGit(ctx context.Context, dir string, args ...string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git not found in PATH: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
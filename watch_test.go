@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// watchTaskDef returns a converted service running image
+func watchTaskDef(name, image string) *TaskDefInfo {
+	return &TaskDefInfo{
+		Name:       name,
+		SourceName: name,
+		Source: &types.TaskDefinition{
+			Family:               aws.String(name),
+			ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String(name), Image: aws.String(image)}},
+		},
+		Containers: []ContainerConfig{{Name: name, Image: image}},
+		Manifests: K8sManifests{
+			Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: name, Image: image}}},
+		},
+	}
+}
+
+// initGitRepo returns a temporary directory initialized as a git repository
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.email", "ci@example.com"},
+		{"config", "user.name", "ci"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return dir
+}
+
+// TestWatchSync tests that watch regenerates and commits only the services
+// that changed since the recorded state, with the recorded flags
+func TestWatchSync(t *testing.T) {
+	dir := initGitRepo(t)
+
+	previous, err := newConversionState("us-east-1", "prod", []*TaskDefInfo{
+		watchTaskDef("web", "web:1"),
+		watchTaskDef("db", "db:1"),
+		watchTaskDef("old", "old:1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.Flags = []string{"--label=team=payments", "--image-pull-policy=Always"}
+	if err := writeConversionState(dir, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	target := &convertedCluster{dir: dir, region: "us-east-1", cluster: "prod", state: previous}
+	pipeline, err := newStatePipeline(nil, target)
+	if err != nil {
+		t.Fatalf("newStatePipeline() error = %v", err)
+	}
+	w := &watcher{target: target, pipeline: pipeline, commit: true, trigger: "watch"}
+	current := []*TaskDefInfo{watchTaskDef("web", "web:2"), watchTaskDef("db", "db:1"), watchTaskDef("api", "api:1")}
+	if _, err := w.sync(context.Background(), current, ""); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	for _, name := range []string{"web", "api"} {
+		data, err := os.ReadFile(filepath.Join(dir, name+"-deployment.yaml"))
+		if err != nil {
+			t.Errorf("%s wasn't regenerated: %v", name, err)
+			continue
+		}
+		for _, want := range []string{"team: payments", "imagePullPolicy: Always"} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s-deployment.yaml lacks %q of the recorded flags:\n%s", name, want, data)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db-deployment.yaml")); err == nil {
+		t.Errorf("the unchanged db service was regenerated")
+	}
+	if _, err := os.Stat(filepath.Join(dir, lockFileName)); !os.IsNotExist(err) {
+		t.Errorf("the workspace lock wasn't released: %v", err)
+	}
+
+	state, err := readConversionState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Services["old"]; ok || len(state.Services) != 3 {
+		t.Errorf("state services = %v, want web, db and api", state.Services)
+	}
+
+	gitLog := func() (string, error) {
+		cmd := exec.Command("git", "log", "--format=%s")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	if out, err := gitLog(); err != nil || out != "ecs2k8s watch: update api, old, web of cluster prod" {
+		t.Errorf("git log = %q, %v", out, err)
+	}
+
+	// Nothing changed since, so the next poll writes and commits nothing
+	if _, err := w.sync(context.Background(), current, ""); err != nil {
+		t.Fatalf("second sync() error = %v", err)
+	}
+	if out, _ := gitLog(); strings.Count(out, "\n") != 0 {
+		t.Errorf("git log = %q, want a single commit", out)
+	}
+}
+
+// TestWatchSyncLocked tests that watch regenerates nothing while another run
+// holds the output directory
+func TestWatchSyncLocked(t *testing.T) {
+	dir := t.TempDir()
+	previous, err := newConversionState("us-east-1", "prod", []*TaskDefInfo{watchTaskDef("web", "web:1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := acquireWorkspaceLock(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	target := &convertedCluster{dir: dir, region: "us-east-1", cluster: "prod", state: previous}
+	pipeline, err := newStatePipeline(nil, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &watcher{target: target, pipeline: pipeline, trigger: "watch"}
+	if _, err := w.sync(context.Background(), []*TaskDefInfo{watchTaskDef("web", "web:2")}, ""); err == nil {
+		t.Errorf("sync() error = nil, want the held lock reported")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web-deployment.yaml")); err == nil {
+		t.Errorf("web was regenerated while the directory was locked")
+	}
+}
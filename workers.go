@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isWorker reports whether a converted service is a worker: none of its
+// containers maps a port, so it gets no Service and receives no traffic
+func isWorker(taskDefInfo *TaskDefInfo) bool {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil {
+		return false
+	}
+	for _, c := range podSpec.Containers {
+		if len(c.Ports) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// applyWorkers records the classification of a worker and, with headless,
+// adds a headless Service resolving the IPs of its pods
+func applyWorkers(taskDefInfo *TaskDefInfo, headless bool) {
+	if !isWorker(taskDefInfo) {
+		return
+	}
+
+	log.Printf("Info: %s maps no container port, converting it as a worker", taskDefInfo.Name)
+	taskDefInfo.Notes = append(taskDefInfo.Notes, "Worker: no container maps a port, so no Service is generated and no readiness probe is inferred from the container health check")
+	if !headless {
+		return
+	}
+
+	for _, svc := range taskDefInfo.Manifests.Services {
+		if svc.Name == taskDefInfo.Name {
+			return
+		}
+	}
+	taskDefInfo.Manifests.Services = append(taskDefInfo.Manifests.Services, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: taskDefInfo.Name},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  map[string]string{"app": taskDefInfo.Name},
+		},
+	})
+	taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("Headless Service %s resolves the pod IPs of the worker in DNS (--headless-workers)", taskDefInfo.Name))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// TestWorkers tests the classification and headless Service of services
+// without port mappings
+func TestWorkers(t *testing.T) {
+	healthCheck := &types.HealthCheck{Command: []string{"CMD", "/bin/healthcheck"}}
+	worker, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("queue-worker"),
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("worker"), Image: aws.String("worker:1"), HealthCheck: healthCheck},
+		},
+	}, "queue-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, err := buildTaskDefInfo(&types.TaskDefinition{
+		Family: aws.String("web"),
+		ContainerDefinitions: []types.ContainerDefinition{{
+			Name: aws.String("web"), Image: aws.String("nginx:1.27"), HealthCheck: healthCheck,
+			PortMappings: []types.PortMapping{{ContainerPort: aws.Int32(80)}},
+		}},
+	}, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isWorker(worker) || isWorker(web) {
+		t.Fatalf("isWorker(worker) = %v, isWorker(web) = %v", isWorker(worker), isWorker(web))
+	}
+
+	for _, taskDefInfo := range []*TaskDefInfo{worker, web} {
+		applyWorkers(taskDefInfo, true)
+		applyContainerHealthChecks(taskDefInfo, probeSourceContainer)
+	}
+
+	container := worker.Manifests.Deployment.Containers[0]
+	if container.LivenessProbe == nil || container.ReadinessProbe != nil {
+		t.Errorf("worker probes liveness = %v, readiness = %v, want no readiness probe inferred", container.LivenessProbe, container.ReadinessProbe)
+	}
+	if web.Manifests.Deployment.Containers[0].ReadinessProbe == nil {
+		t.Errorf("web has no readiness probe")
+	}
+	if len(web.Manifests.Services) != 1 || len(web.Notes) != 0 {
+		t.Errorf("web services = %d, notes = %v, want it left alone", len(web.Manifests.Services), web.Notes)
+	}
+
+	files, err := renderManifests(worker.Name, worker.Manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := files["queue-worker-service.yaml"].(map[string]interface{})["spec"].(map[string]interface{})
+	if spec["clusterIP"] != "None" || spec["selector"].(map[string]string)["app"] != "queue-worker" || spec["ports"] != nil {
+		t.Errorf("headless Service spec = %v", spec)
+	}
+	// Still classified as a worker with its headless Service
+	if !isWorker(worker) || len(worker.Notes) != 2 {
+		t.Errorf("worker notes = %v", worker.Notes)
+	}
+
+	dir := t.TempDir()
+	if err := writeConversionReport(dir, "prod", "us-east-1", nil, []*TaskDefInfo{worker, web}, nil); err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{"| queue-worker | worker |", "| web | service |"} {
+		if !strings.Contains(string(report), row) {
+			t.Errorf("report has no row %q", row)
+		}
+	}
+}
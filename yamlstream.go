@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlChunkNodes is the size, in map entries and sequence items, from which a
+// value is encoded piece by piece. The yaml.v3 emitter queues every event of a
+// document until it ends, so marshaling the Deployment of a task definition
+// with dozens of containers and long environments at once holds all of it.
+const yamlChunkNodes = 256
+
+// yamlEncoder writes documents exactly as yaml.Marshal renders them, but
+// marshals large maps and sequences one entry at a time. Each entry is
+// marshaled inside its ancestors, which fixes its indentation, and the lines
+// of the ancestors are dropped.
+type yamlEncoder struct {
+	w          io.Writer
+	chunkNodes int
+}
+
+// encodeYAML writes one document to w
+func encodeYAML(w io.Writer, doc interface{}) error {
+	e := &yamlEncoder{w: w, chunkNodes: yamlChunkNodes}
+	return e.encode(doc, func(v interface{}) interface{} { return v }, 0, false)
+}
+
+// marshalYAML renders one document like yaml.Marshal
+func marshalYAML(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAMLFile writes one document to a file without rendering it in memory
+func writeYAMLFile(path string, doc interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := encodeYAML(w, doc); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encode writes node, rendered as wrap(node), without its first skip lines,
+// which belong to the ancestors. A map that is a sequence item (inItem) starts
+// with the dash of the item.
+func (e *yamlEncoder) encode(node interface{}, wrap func(interface{}) interface{}, skip int, inItem bool) error {
+	if yamlNodes(node, e.chunkNodes) < e.chunkNodes {
+		return e.writeLines(wrap(node), skip, false)
+	}
+
+	switch v := node.(type) {
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		return e.encode(m, wrap, skip, inItem)
+	case map[string]interface{}:
+		keys, ok := yamlKeyOrder(v)
+		if !ok {
+			return e.writeLines(wrap(node), skip, false)
+		}
+		// Consecutive small entries are marshaled together
+		batch := map[string]interface{}{}
+		batchNodes, batchStart := 0, 0
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := e.writeLines(wrap(batch), skip, inItem && batchStart > 0)
+			batch, batchNodes = map[string]interface{}{}, 0
+			return err
+		}
+		for i, key := range keys {
+			nodes := yamlNodes(v[key], e.chunkNodes)
+			if nodes < e.chunkNodes || !isYAMLCollection(v[key]) {
+				if batchNodes+nodes+1 > e.chunkNodes {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+				if len(batch) == 0 {
+					batchStart = i
+				}
+				batch[key] = v[key]
+				batchNodes += nodes + 1
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+
+			key := key
+			entryWrap := func(value interface{}) interface{} {
+				return wrap(map[string]interface{}{key: value})
+			}
+			// The key line of a block value, rendered with a one-item stand-in
+			var standIn interface{} = []interface{}{nil}
+			switch v[key].(type) {
+			case map[string]interface{}, map[string]string:
+				standIn = map[string]interface{}{"": nil}
+			}
+			lines, err := yamlLines(entryWrap(standIn))
+			if err != nil {
+				return err
+			}
+			// Later entries of an item are aligned with the first one, after its dash
+			if err := e.write(lines[skip:skip+1], inItem && i > 0); err != nil {
+				return err
+			}
+			if err := e.encode(v[key], entryWrap, skip+1, false); err != nil {
+				return err
+			}
+		}
+		return flush()
+
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+		return e.encodeItems(items, wrap, skip)
+	case []interface{}:
+		return e.encodeItems(v, wrap, skip)
+	}
+	return e.writeLines(wrap(node), skip, false)
+}
+
+// encodeItems writes the items of a sequence one by one
+func (e *yamlEncoder) encodeItems(items []interface{}, wrap func(interface{}) interface{}, skip int) error {
+	itemWrap := func(value interface{}) interface{} {
+		return wrap([]interface{}{value})
+	}
+	// Consecutive small items are marshaled together
+	var batch []interface{}
+	batchNodes := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := e.writeLines(wrap(batch), skip, false)
+		batch, batchNodes = nil, 0
+		return err
+	}
+	for _, item := range items {
+		nodes := yamlNodes(item, e.chunkNodes)
+		if _, ok := item.(map[string]interface{}); ok && nodes >= e.chunkNodes {
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := e.encode(item, itemWrap, skip, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if batchNodes+nodes+1 > e.chunkNodes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, item)
+		batchNodes += nodes + 1
+	}
+	return flush()
+}
+
+// writeLines marshals doc and writes its lines after the first skip ones
+func (e *yamlEncoder) writeLines(doc interface{}, skip int, undash bool) error {
+	lines, err := yamlLines(doc)
+	if err != nil {
+		return err
+	}
+	return e.write(lines[skip:], undash)
+}
+
+// write writes lines, replacing the dash of the first one by a space if undash
+func (e *yamlEncoder) write(lines []string, undash bool) error {
+	for i, line := range lines {
+		if undash && i == 0 {
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			line = line[:indent] + "  " + strings.TrimPrefix(line[indent:], "- ")
+		}
+		if _, err := io.WriteString(e.w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlLines marshals doc and splits it into lines, keeping the line ends
+func yamlLines(doc interface{}) ([]string, error) {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	return lines[:len(lines)-1], nil
+}
+
+// yamlKeyOrder returns the keys of a map in the order yaml.Marshal writes
+// them, by marshaling the index of each key in place of its value. Keys
+// rendered on several lines can't be ordered.
+func yamlKeyOrder(m map[string]interface{}) ([]string, bool) {
+	keys := make([]string, 0, len(m))
+	indexes := make(map[string]int, len(m))
+	for key := range m {
+		indexes[key] = len(keys)
+		keys = append(keys, key)
+	}
+	lines, err := yamlLines(indexes)
+	if err != nil || len(lines) != len(keys) {
+		return nil, false
+	}
+
+	ordered := make([]string, 0, len(keys))
+	for _, line := range lines {
+		sep := strings.LastIndex(line, ": ")
+		if sep < 0 {
+			return nil, false
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(line[sep+2:]))
+		if err != nil || index < 0 || index >= len(keys) {
+			return nil, false
+		}
+		ordered = append(ordered, keys[index])
+	}
+	return ordered, true
+}
+
+// isYAMLCollection reports whether a value is a map or sequence the encoder
+// can split
+func isYAMLCollection(node interface{}) bool {
+	switch node.(type) {
+	case map[string]interface{}, map[string]string, []map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// yamlNodes counts the map entries and sequence items of a value, up to limit
+func yamlNodes(node interface{}, limit int) int {
+	count := 0
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for _, value := range v {
+			if count++; count >= limit {
+				return count
+			}
+			count += yamlNodes(value, limit-count)
+		}
+	case map[string]string:
+		count = len(v)
+	case []map[string]interface{}:
+		for _, item := range v {
+			if count++; count >= limit {
+				return count
+			}
+			count += yamlNodes(item, limit-count)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if count++; count >= limit {
+				return count
+			}
+			count += yamlNodes(item, limit-count)
+		}
+	case []string:
+		count = len(v)
+	}
+	return count
+}
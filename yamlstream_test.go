@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestEncodeYAML tests that chunked encoding renders documents exactly like
+// yaml.Marshal
+func TestEncodeYAML(t *testing.T) {
+	docs := map[string]interface{}{
+		"nested": map[string]interface{}{
+			"list": []interface{}{[]interface{}{1, 2}, map[string]interface{}{"a": 1, "b": []string{"x"}}, "plain"},
+			"spec": map[string]interface{}{
+				"containers": []map[string]interface{}{
+					{"name": "a", "args": []string{"a", "b"}, "env": []map[string]interface{}{{"name": "X", "value": "1"}, {"name": "Y", "value": "multi\nline\n"}}},
+					{"name": "b", "resources": map[string]interface{}{"limits": map[string]string{"cpu": "1", "memory": "1Gi"}}},
+				},
+				"empty": []string{}, "none": map[string]string{}, "10x": 1, "9x": 2, "quoted: key": "yes",
+			},
+		},
+		"labels": map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]string{"app": "web", "app.kubernetes.io/name": "web", "version": "1.0"}}},
+	}
+
+	taskDefInfo, err := buildTaskDefInfo(largeTaskDefinition(3, 5), "large")
+	if err != nil {
+		t.Fatalf("buildTaskDefInfo() error = %v", err)
+	}
+	files, err := renderManifests(taskDefInfo.Name, taskDefInfo.Manifests)
+	if err != nil {
+		t.Fatalf("renderManifests() error = %v", err)
+	}
+	for name, doc := range files {
+		docs[name] = doc
+	}
+
+	for name, doc := range docs {
+		want, err := yaml.Marshal(doc)
+		if err != nil {
+			t.Fatalf("yaml.Marshal(%s) error = %v", name, err)
+		}
+		for _, chunkNodes := range []int{1, 2, 3, 8, yamlChunkNodes} {
+			var buf bytes.Buffer
+			e := &yamlEncoder{w: &buf, chunkNodes: chunkNodes}
+			if err := e.encode(doc, func(v interface{}) interface{} { return v }, 0, false); err != nil {
+				t.Fatalf("encode(%s) error = %v", name, err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("encode(%s) with chunks of %d =\n%s\nwant\n%s", name, chunkNodes, buf.String(), want)
+			}
+		}
+	}
+}
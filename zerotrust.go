@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// Policy flavors of --zero-trust
+const (
+	// zeroTrustCilium generates CiliumNetworkPolicies requiring Cilium mutual
+	// authentication, which encrypts and authenticates service-to-service
+	// traffic without a service mesh
+	zeroTrustCilium = "cilium"
+	// zeroTrustNetworkPolicy generates NetworkPolicies, enforced by any CNI
+	// supporting them (Calico, Cilium, Antrea, VPC CNI network policies)
+	zeroTrustNetworkPolicy = "network-policy"
+)
+
+// zeroTrustModes lists the accepted --zero-trust values
+var zeroTrustModes = []string{zeroTrustCilium, zeroTrustNetworkPolicy}
+
+// isValidZeroTrust checks if the --zero-trust policy flavor is supported
+func isValidZeroTrust(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range zeroTrustModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// IngressRule is traffic a service accepts, translated from an inbound rule
+// of its security groups
+type IngressRule struct {
+	// Sources are the converted services whose security groups the rule allows
+	Sources []string
+	CIDRs   []string
+	// Protocol is TCP, UDP or SCTP; empty allows every protocol and port
+	Protocol string
+	FromPort int32
+	ToPort   int32
+}
+
+// ServiceIngress is the ingress a service's security groups allow
+type ServiceIngress struct {
+	SecurityGroups []string
+	Rules          []IngressRule
+	// Unmapped lists what the rules allow that has no pod equivalent, such
+	// as security groups of load balancers or of services not converted
+	Unmapped []string
+}
+
+// zeroTrustGenerator restricts the ingress of the pods to what the security
+// groups of the ECS services allow (--zero-trust)
+type zeroTrustGenerator struct {
+	client *ec2.Client
+	mode   string
+	// ingress is the analysis of every service, keyed by name
+	ingress map[string]*ServiceIngress
+}
+
+// resolve describes the security groups of the services and analyzes which
+// services may reach which. It runs before the services are converted, since
+// the policy of one service refers to the pods of the others.
+func (z *zeroTrustGenerator) resolve(ctx context.Context, taskDefInfos []*TaskDefInfo) error {
+	groupSet := map[string]bool{}
+	for _, taskDefInfo := range taskDefInfos {
+		for _, group := range serviceSecurityGroups(taskDefInfo) {
+			groupSet[group] = true
+		}
+	}
+	if len(groupSet) == 0 {
+		z.ingress = map[string]*ServiceIngress{}
+		return nil
+	}
+
+	var groups []ec2types.SecurityGroup
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(z.client, &ec2.DescribeSecurityGroupsInput{GroupIds: sortedSet(groupSet)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe security groups: %w", err)
+		}
+		groups = append(groups, page.SecurityGroups...)
+	}
+	z.ingress = analyzeSecurityGroups(taskDefInfos, groups)
+	log.Printf("Analyzed %d security group(s) of %d service(s) for --zero-trust", len(groups), len(taskDefInfos))
+	return nil
+}
+
+// analyzeSecurityGroups translates the inbound rules of the services'
+// security groups into ingress between services. A rule allowing a security
+// group allows every service running with that group.
+func analyzeSecurityGroups(taskDefInfos []*TaskDefInfo, groups []ec2types.SecurityGroup) map[string]*ServiceIngress {
+	members := map[string][]string{}
+	for _, taskDefInfo := range taskDefInfos {
+		for _, group := range serviceSecurityGroups(taskDefInfo) {
+			members[group] = append(members[group], taskDefInfo.Name)
+		}
+	}
+	byID := map[string]ec2types.SecurityGroup{}
+	for _, group := range groups {
+		byID[aws.ToString(group.GroupId)] = group
+	}
+
+	ingress := map[string]*ServiceIngress{}
+	for _, taskDefInfo := range taskDefInfos {
+		serviceGroups := serviceSecurityGroups(taskDefInfo)
+		if len(serviceGroups) == 0 {
+			continue
+		}
+		analysis := &ServiceIngress{SecurityGroups: serviceGroups}
+		unmapped := map[string]bool{}
+		for _, groupID := range serviceGroups {
+			group, ok := byID[groupID]
+			if !ok {
+				unmapped[fmt.Sprintf("rules of %s (not described)", groupID)] = true
+				continue
+			}
+			for _, permission := range group.IpPermissions {
+				protocol, ok := ingressProtocol(aws.ToString(permission.IpProtocol))
+				if !ok {
+					unmapped[fmt.Sprintf("%s traffic (protocol %s)", groupID, aws.ToString(permission.IpProtocol))] = true
+					continue
+				}
+				rule := IngressRule{Protocol: protocol}
+				if protocol != "" {
+					rule.FromPort, rule.ToPort = aws.ToInt32(permission.FromPort), aws.ToInt32(permission.ToPort)
+				}
+
+				sources := map[string]bool{}
+				for _, pair := range permission.UserIdGroupPairs {
+					source := aws.ToString(pair.GroupId)
+					if len(members[source]) == 0 {
+						unmapped[fmt.Sprintf("security group %s", source)] = true
+						continue
+					}
+					for _, name := range members[source] {
+						sources[name] = true
+					}
+				}
+				rule.Sources = sortedSet(sources)
+				for _, ipRange := range permission.IpRanges {
+					rule.CIDRs = append(rule.CIDRs, aws.ToString(ipRange.CidrIp))
+				}
+				for _, ipRange := range permission.Ipv6Ranges {
+					rule.CIDRs = append(rule.CIDRs, aws.ToString(ipRange.CidrIpv6))
+				}
+				for _, prefixList := range permission.PrefixListIds {
+					unmapped[fmt.Sprintf("prefix list %s", aws.ToString(prefixList.PrefixListId))] = true
+				}
+				if len(rule.Sources) > 0 || len(rule.CIDRs) > 0 {
+					analysis.Rules = append(analysis.Rules, rule)
+				}
+			}
+		}
+		analysis.Unmapped = sortedSet(unmapped)
+		ingress[taskDefInfo.Name] = analysis
+	}
+	return ingress
+}
+
+// ingressProtocol maps the protocol of a security group rule to a Kubernetes
+// protocol; empty for every protocol. ICMP and other protocols can't be
+// expressed.
+func ingressProtocol(protocol string) (string, bool) {
+	switch strings.ToLower(protocol) {
+	case "-1", "all":
+		return "", true
+	case "tcp", "6":
+		return "TCP", true
+	case "udp", "17":
+		return "UDP", true
+	case "sctp", "132":
+		return "SCTP", true
+	}
+	return "", false
+}
+
+// serviceSecurityGroups returns the sorted security groups of the services'
+// awsvpc configurations
+func serviceSecurityGroups(taskDefInfo *TaskDefInfo) []string {
+	groups := map[string]bool{}
+	for _, svc := range taskDefInfo.Services {
+		if svc.NetworkConfiguration == nil || svc.NetworkConfiguration.AwsvpcConfiguration == nil {
+			continue
+		}
+		for _, group := range svc.NetworkConfiguration.AwsvpcConfiguration.SecurityGroups {
+			groups[group] = true
+		}
+	}
+	return sortedSet(groups)
+}
+
+// apply adds the ingress policy of a converted service. Pods only accept the
+// traffic its security groups allow; everything else is denied.
+func (z *zeroTrustGenerator) apply(taskDefInfo *TaskDefInfo) {
+	analysis := z.ingress[taskDefInfo.Name]
+	if taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+	if analysis == nil {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, "--zero-trust generated no policy: the service has no awsvpc security groups to derive it from")
+		return
+	}
+
+	var policy map[string]interface{}
+	suffix := "networkpolicy"
+	if z.mode == zeroTrustCilium {
+		policy = ciliumNetworkPolicy(taskDefInfo.Name, analysis)
+		suffix = "ciliumnetworkpolicy"
+	} else {
+		policy = networkPolicy(taskDefInfo.Name, analysis)
+	}
+	taskDefInfo.Manifests.Extras = append(taskDefInfo.Manifests.Extras, ExtraObject{Suffix: suffix, Object: policy})
+
+	if len(analysis.Unmapped) > 0 {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("The security groups of the service also allow %s, which the %s doesn't; allow those sources (e.g. the ingress controller) before applying it", strings.Join(analysis.Unmapped, ", "), policy["kind"]))
+	}
+	if z.mode == zeroTrustNetworkPolicy {
+		taskDefInfo.Notes = append(taskDefInfo.Notes, "NetworkPolicies restrict but don't encrypt service-to-service traffic; enable your CNI's transparent encryption, or use --zero-trust=cilium for mutual authentication")
+	}
+	log.Printf("✓ Generated %s for %s from %d security group rule(s)", policy["kind"], taskDefInfo.Name, len(analysis.Rules))
+}
+
+// zeroTrustMetadata returns the metadata of the policy of a service
+func zeroTrustMetadata(name string, analysis *ServiceIngress) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      name + "-zero-trust",
+		"namespace": "default",
+		"labels":    map[string]string{"app": name},
+		"annotations": map[string]string{
+			reviewAnnotation: fmt.Sprintf("Generated from the inbound rules of security groups %s; traffic they allow from outside the converted services is denied", strings.Join(analysis.SecurityGroups, ", ")),
+		},
+	}
+}
+
+// ciliumNetworkPolicy returns the CiliumNetworkPolicy of a service. Traffic
+// from other services requires mutual authentication, so Cilium only lets it
+// through once both workloads proved their identity.
+func ciliumNetworkPolicy(name string, analysis *ServiceIngress) map[string]interface{} {
+	// An empty rule denies all ingress
+	ingress := []interface{}{map[string]interface{}{}}
+	for _, rule := range analysis.Rules {
+		toPorts := ciliumPorts(rule)
+		if len(rule.Sources) > 0 {
+			var endpoints []interface{}
+			for _, source := range rule.Sources {
+				endpoints = append(endpoints, map[string]interface{}{
+					"matchLabels": map[string]string{"app": source},
+				})
+			}
+			entry := map[string]interface{}{
+				"fromEndpoints":  endpoints,
+				"authentication": map[string]interface{}{"mode": "required"},
+			}
+			if toPorts != nil {
+				entry["toPorts"] = toPorts
+			}
+			ingress = append(ingress, entry)
+		}
+		if len(rule.CIDRs) > 0 {
+			entry := map[string]interface{}{"fromCIDR": rule.CIDRs}
+			if toPorts != nil {
+				entry["toPorts"] = toPorts
+			}
+			ingress = append(ingress, entry)
+		}
+	}
+	if len(ingress) > 1 {
+		ingress = ingress[1:]
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata":   zeroTrustMetadata(name, analysis),
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{
+				"matchLabels": map[string]string{"app": name},
+			},
+			"ingress": ingress,
+		},
+	}
+}
+
+// ciliumPorts returns the toPorts of a rule, nil for every port
+func ciliumPorts(rule IngressRule) []interface{} {
+	if rule.Protocol == "" {
+		return nil
+	}
+	port := map[string]interface{}{"protocol": rule.Protocol}
+	// A range of 0-65535 (or -1) is every port of the protocol
+	if rule.FromPort > 0 && rule.ToPort < 65535 {
+		port["port"] = strconv.Itoa(int(rule.FromPort))
+		if rule.ToPort > rule.FromPort {
+			port["endPort"] = rule.ToPort
+		}
+	} else {
+		port["port"] = "0"
+	}
+	return []interface{}{map[string]interface{}{"ports": []interface{}{port}}}
+}
+
+// networkPolicy returns the NetworkPolicy of a service
+func networkPolicy(name string, analysis *ServiceIngress) map[string]interface{} {
+	ingress := []interface{}{}
+	for _, rule := range analysis.Rules {
+		var from []interface{}
+		for _, source := range rule.Sources {
+			from = append(from, map[string]interface{}{
+				"podSelector": map[string]interface{}{
+					"matchLabels": map[string]string{"app": source},
+				},
+			})
+		}
+		for _, cidr := range rule.CIDRs {
+			from = append(from, map[string]interface{}{
+				"ipBlock": map[string]interface{}{"cidr": cidr},
+			})
+		}
+		entry := map[string]interface{}{"from": from}
+		if rule.Protocol != "" {
+			port := map[string]interface{}{"protocol": rule.Protocol}
+			if rule.FromPort > 0 && rule.ToPort < 65535 {
+				port["port"] = rule.FromPort
+				if rule.ToPort > rule.FromPort {
+					port["endPort"] = rule.ToPort
+				}
+			}
+			entry["ports"] = []interface{}{port}
+		}
+		ingress = append(ingress, entry)
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "NetworkPolicy",
+		"metadata":   zeroTrustMetadata(name, analysis),
+		"spec": map[string]interface{}{
+			"podSelector": map[string]interface{}{
+				"matchLabels": map[string]string{"app": name},
+			},
+			"policyTypes": []string{"Ingress"},
+			// No rules deny all ingress
+			"ingress": ingress,
+		},
+	}
+}
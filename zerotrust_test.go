@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestZeroTrustPolicies tests deriving ingress policies from the security
+// groups of the services
+func TestZeroTrustPolicies(t *testing.T) {
+	newInfo := func(name string, groups ...string) *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: name,
+			Services: []types.Service{{
+				ServiceName: aws.String(name),
+				NetworkConfiguration: &types.NetworkConfiguration{
+					AwsvpcConfiguration: &types.AwsVpcConfiguration{SecurityGroups: groups},
+				},
+			}},
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: name, Image: name + ":1"}}},
+			},
+		}
+	}
+	web := newInfo("web", "sg-web")
+	api := newInfo("api", "sg-api")
+	worker := newInfo("worker", "sg-worker")
+	legacy := &TaskDefInfo{Name: "legacy", Manifests: K8sManifests{Deployment: &corev1.PodSpec{}}}
+	infos := []*TaskDefInfo{web, api, worker, legacy}
+
+	groups := []ec2types.SecurityGroup{
+		{GroupId: aws.String("sg-web"), IpPermissions: []ec2types.IpPermission{{
+			IpProtocol:       aws.String("tcp"),
+			FromPort:         aws.Int32(8080),
+			ToPort:           aws.Int32(8080),
+			UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-alb")}},
+		}}},
+		{GroupId: aws.String("sg-api"), IpPermissions: []ec2types.IpPermission{
+			{
+				IpProtocol:       aws.String("tcp"),
+				FromPort:         aws.Int32(9000),
+				ToPort:           aws.Int32(9010),
+				UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-web")}, {GroupId: aws.String("sg-worker")}},
+			},
+			{IpProtocol: aws.String("icmp"), FromPort: aws.Int32(-1), ToPort: aws.Int32(-1), IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.0.0.0/8")}}},
+			{IpProtocol: aws.String("-1"), IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.1.0.0/16")}}},
+		}},
+		{GroupId: aws.String("sg-worker")},
+	}
+
+	z := &zeroTrustGenerator{mode: zeroTrustCilium, ingress: analyzeSecurityGroups(infos, groups)}
+	apiIngress := z.ingress["api"]
+	if len(apiIngress.Rules) != 2 || strings.Join(apiIngress.Rules[0].Sources, ",") != "web,worker" {
+		t.Fatalf("api ingress = %+v, want web and worker on 9000-9010 and the CIDR", apiIngress)
+	}
+	if len(apiIngress.Unmapped) != 1 || !strings.Contains(apiIngress.Unmapped[0], "icmp") {
+		t.Errorf("api unmapped = %v, want the ICMP rule", apiIngress.Unmapped)
+	}
+	if unmapped := z.ingress["web"].Unmapped; len(unmapped) != 1 || unmapped[0] != "security group sg-alb" {
+		t.Errorf("web unmapped = %v, want the load balancer group", unmapped)
+	}
+
+	for _, info := range infos {
+		z.apply(info)
+	}
+	policy := api.Manifests.Extras[0].Object
+	if policy["kind"] != "CiliumNetworkPolicy" {
+		t.Fatalf("api policy kind = %v", policy["kind"])
+	}
+	ingress := policy["spec"].(map[string]interface{})["ingress"].([]interface{})
+	fromServices := ingress[0].(map[string]interface{})
+	if fromServices["authentication"].(map[string]interface{})["mode"] != "required" {
+		t.Errorf("service ingress = %v, want mutual authentication", fromServices)
+	}
+	port := fromServices["toPorts"].([]interface{})[0].(map[string]interface{})["ports"].([]interface{})[0].(map[string]interface{})
+	if port["port"] != "9000" || port["endPort"] != int32(9010) || port["protocol"] != "TCP" {
+		t.Errorf("service ingress port = %v, want TCP 9000-9010", port)
+	}
+	if fromCIDR := ingress[1].(map[string]interface{}); fromCIDR["toPorts"] != nil || fromCIDR["fromCIDR"].([]string)[0] != "10.1.0.0/16" {
+		t.Errorf("CIDR ingress = %v, want every port from 10.1.0.0/16", fromCIDR)
+	}
+
+	// A service nothing may reach denies all ingress
+	workerIngress := worker.Manifests.Extras[0].Object["spec"].(map[string]interface{})["ingress"].([]interface{})
+	if len(workerIngress) != 1 || len(workerIngress[0].(map[string]interface{})) != 0 {
+		t.Errorf("worker ingress = %v, want the empty default-deny rule", workerIngress)
+	}
+	if len(legacy.Manifests.Extras) != 0 || len(legacy.Notes) != 1 {
+		t.Errorf("service without security groups: extras = %v, notes = %v", legacy.Manifests.Extras, legacy.Notes)
+	}
+
+	policy = networkPolicy("web", z.ingress["web"])
+	if rules := policy["spec"].(map[string]interface{})["ingress"].([]interface{}); len(rules) != 0 {
+		t.Errorf("web NetworkPolicy ingress = %v, want none from the load balancer group", rules)
+	}
+	policy = networkPolicy("api", apiIngress)
+	rule := policy["spec"].(map[string]interface{})["ingress"].([]interface{})[0].(map[string]interface{})
+	if from := rule["from"].([]interface{}); len(from) != 2 || rule["ports"].([]interface{})[0].(map[string]interface{})["endPort"] != int32(9010) {
+		t.Errorf("api NetworkPolicy rule = %v, want both services on 9000-9010", rule)
+	}
+
+	if !isValidZeroTrust("") || !isValidZeroTrust("cilium") || isValidZeroTrust("calico") {
+		t.Errorf("isValidZeroTrust() accepts the wrong modes")
+	}
+}
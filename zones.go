@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// zoneLabel is the well-known node label of the availability zone
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// ZoneFootprint records the availability zones an ECS service runs in,
+// resolved from the subnets of its awsvpc configuration
+type ZoneFootprint struct {
+	Subnets []string
+	Zones   []string
+	// Placement describes the scheduling constraint keeping the footprint
+	Placement string
+}
+
+// zoneResolver resolves the availability zones of the services' subnets
+type zoneResolver struct {
+	client *ec2.Client
+	// subnetZones caches the availability zone per subnet ID
+	subnetZones map[string]string
+}
+
+// newZoneResolver creates a resolver backed by an EC2 client
+func newZoneResolver(client *ec2.Client) *zoneResolver {
+	return &zoneResolver{client: client, subnetZones: map[string]string{}}
+}
+
+// apply records the zone footprint of a converted task definition and keeps
+// it on Kubernetes: single-zone services select nodes of their zone, and
+// multi-zone services spread their pods across zones
+func (z *zoneResolver) apply(ctx context.Context, taskDefInfo *TaskDefInfo) {
+	subnets := serviceSubnets(taskDefInfo)
+	if len(subnets) == 0 || taskDefInfo.Manifests.Deployment == nil {
+		return
+	}
+
+	if err := z.resolve(ctx, subnets); err != nil {
+		log.Printf("Warning: Failed to resolve the availability zones of %s: %v", taskDefInfo.Name, err)
+		return
+	}
+	zoneSet := map[string]bool{}
+	for _, subnet := range subnets {
+		if zone := z.subnetZones[subnet]; zone != "" {
+			zoneSet[zone] = true
+		}
+	}
+	applyZoneFootprint(taskDefInfo, subnets, sortedSet(zoneSet))
+}
+
+// resolve describes the subnets whose zone is not cached yet
+func (z *zoneResolver) resolve(ctx context.Context, subnets []string) error {
+	var unknown []string
+	for _, subnet := range subnets {
+		if _, ok := z.subnetZones[subnet]; !ok {
+			unknown = append(unknown, subnet)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	out, err := z.client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: unknown})
+	if err != nil {
+		return err
+	}
+	for _, subnet := range out.Subnets {
+		z.subnetZones[aws.ToString(subnet.SubnetId)] = aws.ToString(subnet.AvailabilityZone)
+	}
+	return nil
+}
+
+// serviceSubnets returns the sorted subnets of the services' awsvpc configurations
+func serviceSubnets(taskDefInfo *TaskDefInfo) []string {
+	subnets := map[string]bool{}
+	for _, svc := range taskDefInfo.Services {
+		if svc.NetworkConfiguration == nil || svc.NetworkConfiguration.AwsvpcConfiguration == nil {
+			continue
+		}
+		for _, subnet := range svc.NetworkConfiguration.AwsvpcConfiguration.Subnets {
+			subnets[subnet] = true
+		}
+	}
+	return sortedSet(subnets)
+}
+
+// applyZoneFootprint pins a single-zone service to its zone, or spreads a
+// multi-zone service across zones, and records the footprint for the report
+func applyZoneFootprint(taskDefInfo *TaskDefInfo, subnets, zones []string) {
+	podSpec := taskDefInfo.Manifests.Deployment
+	if podSpec == nil || len(zones) == 0 {
+		return
+	}
+
+	footprint := &ZoneFootprint{Subnets: subnets, Zones: zones}
+	if len(zones) == 1 {
+		if podSpec.NodeSelector == nil {
+			podSpec.NodeSelector = map[string]string{}
+		}
+		podSpec.NodeSelector[zoneLabel] = zones[0]
+		footprint.Placement = fmt.Sprintf("nodeSelector %s=%s", zoneLabel, zones[0])
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS runs the service in zone %s only; the pods select nodes of that zone so they keep their single-zone latency and data locality", zones[0]))
+	} else {
+		podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       zoneLabel,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": taskDefInfo.Name}},
+		})
+		footprint.Placement = fmt.Sprintf("topologySpreadConstraint on %s (maxSkew 1, ScheduleAnyway)", zoneLabel)
+		taskDefInfo.Notes = append(taskDefInfo.Notes, fmt.Sprintf("ECS runs the service in %d zones (%s); the pods are spread across zones as a preference, so a cluster without nodes in some zones still schedules them", len(zones), strings.Join(zones, ", ")))
+	}
+	taskDefInfo.Zones = footprint
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TestApplyZoneFootprint tests keeping the zone footprint of ECS services on Kubernetes
+func TestApplyZoneFootprint(t *testing.T) {
+	newInfo := func() *TaskDefInfo {
+		return &TaskDefInfo{
+			Name: "web",
+			Services: []types.Service{{
+				ServiceName: aws.String("web"),
+				NetworkConfiguration: &types.NetworkConfiguration{
+					AwsvpcConfiguration: &types.AwsVpcConfiguration{Subnets: []string{"subnet-b", "subnet-a", "subnet-b"}},
+				},
+			}},
+			Manifests: K8sManifests{
+				Deployment: &corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:1"}}},
+			},
+		}
+	}
+
+	single := newInfo()
+	subnets := serviceSubnets(single)
+	if strings.Join(subnets, ",") != "subnet-a,subnet-b" {
+		t.Errorf("serviceSubnets() = %v", subnets)
+	}
+	applyZoneFootprint(single, subnets, []string{"us-east-1a"})
+	if single.Manifests.Deployment.NodeSelector[zoneLabel] != "us-east-1a" || len(single.Manifests.Deployment.TopologySpreadConstraints) != 0 {
+		t.Errorf("single-zone pod spec = %+v", single.Manifests.Deployment)
+	}
+
+	multi := newInfo()
+	applyZoneFootprint(multi, subnets, []string{"us-east-1a", "us-east-1b"})
+	constraints := multi.Manifests.Deployment.TopologySpreadConstraints
+	if len(constraints) != 1 || constraints[0].TopologyKey != zoneLabel || constraints[0].LabelSelector.MatchLabels["app"] != "web" {
+		t.Errorf("multi-zone constraints = %+v", constraints)
+	}
+	if multi.Manifests.Deployment.NodeSelector != nil {
+		t.Errorf("multi-zone service got a node selector %v", multi.Manifests.Deployment.NodeSelector)
+	}
+
+	var b strings.Builder
+	writeZonesSection(&b, []*TaskDefInfo{single, multi, {Name: "batch"}})
+	report := b.String()
+	if !strings.Contains(report, "## Zone Footprint") || !strings.Contains(report, "us-east-1a, us-east-1b") || strings.Contains(report, "batch") {
+		t.Errorf("zone section = %q", report)
+	}
+}